@@ -51,6 +51,12 @@ const STOP_ANNOTATION = "kubeflow-resource-stopped"
 const LAST_ACTIVITY_ANNOTATION = "notebooks.kubeflow.org/last-activity"
 const LAST_ACTIVITY_CHECK_TIMESTAMP_ANNOTATION = "notebooks.kubeflow.org/last_activity_check_timestamp"
 
+// Per-Notebook overrides of the culling configuration. The idle timeout is a
+// number of minutes replacing CULL_IDLE_TIME, and culling can be disabled by
+// setting the culling-disabled annotation to "true".
+const IDLE_TIMEOUT_ANNOTATION = "notebooks.opendatahub.io/idle-timeout"
+const CULLING_DISABLED_ANNOTATION = "notebooks.opendatahub.io/culling-disabled"
+
 const (
 	KERNEL_EXECUTION_STATE_IDLE     = "idle"
 	KERNEL_EXECUTION_STATE_BUSY     = "busy"
@@ -182,6 +188,22 @@ func cullingCheckPeriodHasPassed(meta metav1.ObjectMeta, log logr.Logger) bool {
 	return nextCullingCheck.Before(currentTime)
 }
 
+// Returns the idle time (in minutes) after which the Notebook is culled
+func getCullIdleTime(meta metav1.ObjectMeta, log logr.Logger) int {
+	idleTime, ok := meta.GetAnnotations()[IDLE_TIMEOUT_ANNOTATION]
+	if !ok {
+		return CULL_IDLE_TIME
+	}
+	realIdleTime, err := strconv.Atoi(idleTime)
+	if err != nil || realIdleTime <= 0 {
+		log.Info(fmt.Sprintf(
+			"%s should be a positive Int. Got '%s' instead. Using CULL_IDLE_TIME.",
+			IDLE_TIMEOUT_ANNOTATION, idleTime))
+		return CULL_IDLE_TIME
+	}
+	return realIdleTime
+}
+
 // Culling Logic
 func notebookIsIdle(meta metav1.ObjectMeta, log logr.Logger) bool {
 	// Being idle means that the Notebook can be culled/stopped
@@ -190,6 +212,10 @@ func notebookIsIdle(meta metav1.ObjectMeta, log logr.Logger) bool {
 			log.Info("Notebook is already stopping")
 			return false
 		}
+		if meta.GetAnnotations()[CULLING_DISABLED_ANNOTATION] == "true" {
+			log.Info("Culling is disabled for this Notebook")
+			return false
+		}
 		// Read the current LAST_ACTIVITY_ANNOTATION
 		tempLastActivity := meta.GetAnnotations()[LAST_ACTIVITY_ANNOTATION]
 		LastActivity, err := time.Parse(time.RFC3339, tempLastActivity)
@@ -198,7 +224,7 @@ func notebookIsIdle(meta metav1.ObjectMeta, log logr.Logger) bool {
 			return false
 		}
 
-		timeCap := LastActivity.Add(time.Duration(CULL_IDLE_TIME) * time.Minute)
+		timeCap := LastActivity.Add(time.Duration(getCullIdleTime(meta, log)) * time.Minute)
 		if time.Now().After(timeCap) {
 			return true
 		}
@@ -246,6 +246,56 @@ func TestNotebookIsIdle(t *testing.T) {
 			},
 			result: false,
 		},
+		{
+			testName: "IDLE_TIMEOUT_ANNOTATION shortens the deadline.",
+			meta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					LAST_ACTIVITY_ANNOTATION: time.Now().Add(-3 * time.Minute).Format(time.RFC3339),
+					IDLE_TIMEOUT_ANNOTATION:  "2",
+				},
+			},
+			env: map[string]string{
+				"CULL_IDLE_TIME": "5",
+			},
+			result: true,
+		},
+		{
+			testName: "IDLE_TIMEOUT_ANNOTATION extends the deadline.",
+			meta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					LAST_ACTIVITY_ANNOTATION: time.Now().Add(-6 * time.Minute).Format(time.RFC3339),
+					IDLE_TIMEOUT_ANNOTATION:  "60",
+				},
+			},
+			env: map[string]string{
+				"CULL_IDLE_TIME": "5",
+			},
+			result: false,
+		},
+		{
+			testName: "IDLE_TIMEOUT_ANNOTATION is invalid.",
+			meta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					LAST_ACTIVITY_ANNOTATION: time.Now().Add(-6 * time.Minute).Format(time.RFC3339),
+					IDLE_TIMEOUT_ANNOTATION:  "1h",
+				},
+			},
+			env: map[string]string{
+				"CULL_IDLE_TIME": "5",
+			},
+			result: true,
+		},
+		{
+			testName: "CULLING_DISABLED_ANNOTATION is set.",
+			meta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					LAST_ACTIVITY_ANNOTATION:    "1900-08-30T15:37:36.990063Z",
+					CULLING_DISABLED_ANNOTATION: "true",
+				},
+			},
+			env:    map[string]string{},
+			result: false,
+		},
 	}
 
 	for _, c := range testCases {
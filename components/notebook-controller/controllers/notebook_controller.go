@@ -295,7 +295,7 @@ func createNotebookStatus(r *NotebookReconciler, nb *v1beta1.Notebook,
 	// Initialize Notebook CR Status
 	log.Info("Initializing Notebook CR Status")
 	status := v1beta1.NotebookStatus{
-		Conditions:     make([]v1beta1.NotebookCondition, 0),
+		Conditions:     externalNotebookConditions(nb, pod),
 		ReadyReplicas:  sts.Status.ReadyReplicas,
 		ContainerState: corev1.ContainerState{},
 	}
@@ -343,11 +343,32 @@ func createNotebookStatus(r *NotebookReconciler, nb *v1beta1.Notebook,
 		notebookConditions = append(notebookConditions, condition)
 	}
 
-	status.Conditions = notebookConditions
+	status.Conditions = append(notebookConditions, status.Conditions...)
 
 	return status, nil
 }
 
+// externalNotebookConditions returns the conditions of the notebook set by
+// the other controllers, which are kept along with the conditions mirrored
+// from the pod. Their type is qualified by a domain, e.g.
+// notebooks.opendatahub.io/OOMKilled, unlike the pod readiness gates.
+func externalNotebookConditions(nb *v1beta1.Notebook, pod *corev1.Pod) []v1beta1.NotebookCondition {
+	podConditions := map[string]bool{}
+	for _, condition := range pod.Status.Conditions {
+		podConditions[string(condition.Type)] = true
+	}
+	for _, gate := range pod.Spec.ReadinessGates {
+		podConditions[string(gate.ConditionType)] = true
+	}
+	conditions := make([]v1beta1.NotebookCondition, 0)
+	for _, condition := range nb.Status.Conditions {
+		if strings.Contains(condition.Type, "/") && !podConditions[condition.Type] {
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions
+}
+
 func PodCondToNotebookCond(podc corev1.PodCondition) v1beta1.NotebookCondition {
 
 	condition := v1beta1.NotebookCondition{}
@@ -272,6 +272,65 @@ func TestCreateNotebookStatus(t *testing.T) {
 				ContainerState: corev1.ContainerState{},
 			},
 		},
+		{
+			name: "keepingExternalConditions",
+			currentNb: nbv1beta1.Notebook{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "test",
+					Namespace: "kubeflow-user",
+				},
+				Status: nbv1beta1.NotebookStatus{
+					Conditions: []nbv1beta1.NotebookCondition{
+						{
+							Type:               "Ready",
+							LastProbeTime:      v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+							LastTransitionTime: v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+						},
+						{
+							Type:               "notebooks.opendatahub.io/OOMKilled",
+							Status:             "True",
+							LastProbeTime:      v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+							LastTransitionTime: v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+							Reason:             "OOMKilled",
+						},
+					},
+				},
+			},
+			pod: corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "test",
+					Namespace: "kubeflow-user",
+				},
+				Status: corev1.PodStatus{
+					Conditions: []corev1.PodCondition{
+						{
+							Type:               "PodScheduled",
+							LastProbeTime:      v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+							LastTransitionTime: v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+						},
+					},
+				},
+			},
+			sts: appsv1.StatefulSet{},
+			expectedNbStatus: nbv1beta1.NotebookStatus{
+				Conditions: []nbv1beta1.NotebookCondition{
+					{
+						Type:               "PodScheduled",
+						LastProbeTime:      v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+						LastTransitionTime: v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+					},
+					{
+						Type:               "notebooks.opendatahub.io/OOMKilled",
+						Status:             "True",
+						LastProbeTime:      v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+						LastTransitionTime: v1.Date(2022, time.Month(8), 30, 1, 10, 30, 0, time.UTC),
+						Reason:             "OOMKilled",
+					},
+				},
+				ReadyReplicas:  int32(0),
+				ContainerState: corev1.ContainerState{},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,49 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/controllers"
+)
+
+// annotationsSchema prints the JSON schema of the annotations honored by the
+// webhook and the controller on the standard output, e.g. generated at build
+// time for the dashboard and the CLI. The same schema is served by the
+// /annotations-schema endpoint of the metrics server.
+func annotationsSchema(args []string) {
+	var target string
+	flags := flag.NewFlagSet("annotations-schema", flag.ExitOnError)
+	flags.StringVar(&target, "target", string(controllers.AnnotationTargetNotebook),
+		"Kind of the objects whose annotations are described: Notebook or Namespace.")
+	_ = flags.Parse(args)
+
+	if controllers.AnnotationTarget(target) != controllers.AnnotationTargetNotebook &&
+		controllers.AnnotationTarget(target) != controllers.AnnotationTargetNamespace {
+		fmt.Fprintf(os.Stderr, "Invalid --target %q\n", target)
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(controllers.AnnotationSchema(controllers.AnnotationTarget(target))); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
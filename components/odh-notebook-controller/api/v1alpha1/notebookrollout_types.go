@@ -0,0 +1,106 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotebookRolloutSpec defines the notebooks to restart and the pace of the
+// restarts.
+type NotebookRolloutSpec struct {
+	// Selector selects the notebooks to restart. An empty selector selects
+	// all the notebooks.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// NamespaceSelector restricts the restarts to the notebooks of the
+	// matching namespaces. All the namespaces are selected when unset.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// MaxUnavailable is the maximum number of notebooks restarting at the
+	// same time.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxUnavailable int32 `json:"maxUnavailable,omitempty"`
+
+	// StartTime delays the restarts until the given time.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Deadline is the time by which all the notebooks must be restarted. The
+	// notebooks still pending at the deadline are restarted at once,
+	// regardless of MaxUnavailable.
+	// +optional
+	Deadline *metav1.Time `json:"deadline,omitempty"`
+}
+
+// NotebookRolloutStatus tracks the progress of the restarts.
+type NotebookRolloutStatus struct {
+	// Total is the number of selected notebooks.
+	Total int32 `json:"total"`
+	// Restarted is the number of notebooks restarted and ready.
+	Restarted int32 `json:"restarted"`
+	// InProgress is the number of notebooks restarting.
+	InProgress int32 `json:"inProgress"`
+	// Pending is the number of notebooks waiting to be restarted.
+	Pending int32 `json:"pending"`
+	// Skipped is the number of stopped notebooks, that get a new pod when
+	// started.
+	Skipped int32 `json:"skipped"`
+
+	// Conditions of the rollout, the Complete condition is true once all the
+	// notebooks are restarted.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConditionComplete reports that all the notebooks of the rollout are
+// restarted.
+const ConditionComplete = "Complete"
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.total`
+//+kubebuilder:printcolumn:name="Restarted",type=integer,JSONPath=`.status.restarted`
+//+kubebuilder:printcolumn:name="Pending",type=integer,JSONPath=`.status.pending`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotebookRollout restarts the selected notebooks gradually, for instance to
+// pick up a refreshed image.
+type NotebookRollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NotebookRolloutSpec   `json:"spec,omitempty"`
+	Status NotebookRolloutStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NotebookRolloutList contains a list of NotebookRollout
+type NotebookRolloutList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotebookRollout `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotebookRollout{}, &NotebookRolloutList{})
+}
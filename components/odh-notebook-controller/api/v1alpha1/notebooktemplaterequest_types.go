@@ -0,0 +1,90 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotebookTemplateRequestSpec defines the notebook to duplicate and the new
+// notebook.
+type NotebookTemplateRequestSpec struct {
+	// SourceNotebook is the name of the notebook of the namespace to
+	// duplicate.
+	// +kubebuilder:validation:MinLength=1
+	SourceNotebook string `json:"sourceNotebook"`
+
+	// NotebookName is the name of the new notebook. The name of the request
+	// is used when unset.
+	// +optional
+	NotebookName string `json:"notebookName,omitempty"`
+
+	// DisplayName is the name of the new notebook displayed by the dashboard.
+	// +optional
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// NotebookTemplateRequestStatus reports the new notebook.
+type NotebookTemplateRequestStatus struct {
+	// NotebookName is the name of the new notebook.
+	// +optional
+	NotebookName string `json:"notebookName,omitempty"`
+
+	// Claims are the fresh PersistentVolumeClaims created for the new
+	// notebook.
+	// +optional
+	Claims []string `json:"claims,omitempty"`
+
+	// Conditions of the request, the NotebookCreated condition is true once
+	// the new notebook and its claims are created.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConditionNotebookCreated reports that the notebook of the template request
+// is created.
+const ConditionNotebookCreated = "NotebookCreated"
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.sourceNotebook`
+//+kubebuilder:printcolumn:name="Notebook",type=string,JSONPath=`.status.notebookName`
+//+kubebuilder:printcolumn:name="Created",type=string,JSONPath=`.status.conditions[?(@.type=="NotebookCreated")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotebookTemplateRequest creates a new notebook from an existing one of the
+// namespace, with the same image, resources and data connections but fresh
+// storage.
+type NotebookTemplateRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NotebookTemplateRequestSpec   `json:"spec,omitempty"`
+	Status NotebookTemplateRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NotebookTemplateRequestList contains a list of NotebookTemplateRequest
+type NotebookTemplateRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotebookTemplateRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotebookTemplateRequest{}, &NotebookTemplateRequestList{})
+}
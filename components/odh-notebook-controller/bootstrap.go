@@ -0,0 +1,112 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/controllers"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// bootstrapTimeout bounds the time spent creating the webhook objects.
+const bootstrapTimeout = time.Minute
+
+// bootstrap creates or updates the webhook configuration, Service and
+// certificates of the controller, for the installations without the ODH
+// operator. The cluster is reached with the KUBECONFIG credentials or the
+// in-cluster service account.
+func bootstrap(args []string) {
+	var namespace, serviceName, secretName, configurationName, selector, clusterDomain string
+	var webhookPort int
+	var webhookTimeout, certValidity time.Duration
+	flags := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	flags.StringVar(&namespace, "namespace", "",
+		"Namespace of the controller. The namespace of the pod is used if empty.")
+	flags.StringVar(&serviceName, "service-name", controllers.DefaultWebhookServiceName,
+		"Name of the webhook Service.")
+	flags.StringVar(&secretName, "secret-name", controllers.DefaultWebhookCertSecretName,
+		"Name of the Secret holding the webhook certificates, mounted by the controller pods.")
+	flags.StringVar(&configurationName, "configuration-name", controllers.DefaultMutatingWebhookConfigurationName,
+		"Name of the MutatingWebhookConfiguration.")
+	flags.StringVar(&selector, "selector", "app=odh-notebook-controller",
+		"Comma-separated <key>=<value> labels of the controller pods, selected by the webhook Service.")
+	flags.StringVar(&clusterDomain, "cluster-domain", controllers.DefaultClusterDomain,
+		"DNS domain of the cluster, for the clusters installed with a custom domain.")
+	flags.IntVar(&webhookPort, "webhook-port", 8443,
+		"Port that the webhook server of the controller pods serves at.")
+	flags.DurationVar(&webhookTimeout, "webhook-timeout", controllers.DefaultWebhookTimeout,
+		"Timeout of the API server calling the notebook webhook, between 1s and 30s.")
+	flags.DurationVar(&certValidity, "cert-validity", controllers.DefaultWebhookCertValidity,
+		"Validity of the generated certificates. They are renewed by the next bootstrap 30 days before expiring.")
+	opts := zap.Options{
+		TimeEncoder: zapcore.TimeEncoderOfLayout(time.RFC3339),
+	}
+	opts.BindFlags(flags)
+	_ = flags.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("bootstrap")
+
+	podSelector, err := labels.ConvertSelectorToLabelsMap(selector)
+	if err != nil {
+		log.Error(err, "Invalid --selector")
+		os.Exit(1)
+	}
+	if webhookTimeout < time.Second || webhookTimeout > 30*time.Second {
+		log.Error(nil, "Invalid --webhook-timeout, must be between 1s and 30s")
+		os.Exit(1)
+	}
+	clusterDNSConfig := controllers.ClusterDNSConfig{ClusterDomain: clusterDomain}
+	if err = clusterDNSConfig.Validate(); err != nil {
+		log.Error(err, "Invalid --cluster-domain")
+		os.Exit(1)
+	}
+
+	cli, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		log.Error(err, "Unable to create the Kubernetes client")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bootstrapTimeout)
+	defer cancel()
+	err = (&controllers.WebhookBootstrap{
+		Client:            cli,
+		Log:               log,
+		Namespace:         namespace,
+		ServiceName:       serviceName,
+		SecretName:        secretName,
+		ConfigurationName: configurationName,
+		Selector:          podSelector,
+		Port:              webhookPort,
+		Timeout:           webhookTimeout,
+		CertValidity:      certValidity,
+		ClusterDNSConfig:  clusterDNSConfig,
+	}).Run(ctx)
+	if err != nil {
+		log.Error(err, "Unable to bootstrap the webhook")
+		os.Exit(1)
+	}
+	log.Info("Webhook bootstrapped")
+}
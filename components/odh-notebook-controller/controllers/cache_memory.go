@@ -0,0 +1,167 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultCacheMetricsInterval is the default interval of the reports of the
+// objects of the cache.
+const DefaultCacheMetricsInterval = time.Minute
+
+// StripCachedObject is the transform of the objects stored in the cache of
+// the controller, removing the fields it never reads: the managed fields of
+// the objects, and the last applied configuration of kubectl of the
+// ConfigMaps and Secrets, which duplicates their data. The managed fields
+// read by the controller are kept (see keepsManagedFields). The managed
+// fields omitted from the updates are kept by the API server; the last
+// applied configuration is not, but the controller only updates the
+// ConfigMaps and Secrets it creates, never applied by kubectl.
+func StripCachedObject(obj interface{}) (interface{}, error) {
+	object, ok := obj.(client.Object)
+	if !ok {
+		return obj, nil
+	}
+	if !keepsManagedFields(object) {
+		object.SetManagedFields(nil)
+	}
+	switch object.(type) {
+	case *corev1.ConfigMap, *corev1.Secret:
+		if annotations := object.GetAnnotations(); annotations[corev1.LastAppliedConfigAnnotation] != "" {
+			delete(annotations, corev1.LastAppliedConfigAnnotation)
+			object.SetAnnotations(annotations)
+		}
+	}
+	return object, nil
+}
+
+// keepsManagedFields returns true for the objects whose managed fields tell
+// the controller which field managers changed them, reported when their
+// changes are reverted (see reportFieldManagerConflicts and
+// reportTrustedCABundleTampering).
+func keepsManagedFields(object client.Object) bool {
+	switch object.(type) {
+	case *routev1.Route, *corev1.Service, *netv1.NetworkPolicy:
+		return true
+	case *corev1.ConfigMap:
+		return object.GetName() == WorkbenchTrustedCABundleConfigMapName
+	}
+	return false
+}
+
+// CachedKind is a kind of objects whose count and estimated memory in the
+// cache are reported.
+type CachedKind struct {
+	Kind string
+	// NewList returns an empty list of the objects of the kind.
+	NewList func() client.ObjectList
+}
+
+// DefaultCachedKinds are the kinds of objects cached for the reconciles of
+// the notebooks. Unless the cache is restricted, the ConfigMaps and Secrets
+// of all the namespaces are cached, which usually makes them the largest.
+var DefaultCachedKinds = []CachedKind{
+	{Kind: "Notebook", NewList: func() client.ObjectList { return &nbv1.NotebookList{} }},
+	{Kind: "ConfigMap", NewList: func() client.ObjectList { return &corev1.ConfigMapList{} }},
+	{Kind: "Secret", NewList: func() client.ObjectList { return &corev1.SecretList{} }},
+	{Kind: "Service", NewList: func() client.ObjectList { return &corev1.ServiceList{} }},
+	{Kind: "ServiceAccount", NewList: func() client.ObjectList { return &corev1.ServiceAccountList{} }},
+	{Kind: "NetworkPolicy", NewList: func() client.ObjectList { return &netv1.NetworkPolicyList{} }},
+	{Kind: "RoleBinding", NewList: func() client.ObjectList { return &rbacv1.RoleBindingList{} }},
+}
+
+// CacheMetricsReporter periodically reports the number of cached objects and
+// their estimated memory by kind. The estimate is the size of the protobuf
+// encoding of the objects, or of their JSON encoding for the custom
+// resources, a lower bound of the memory they use.
+type CacheMetricsReporter struct {
+	// Reader is the cache of the manager.
+	Reader client.Reader
+	// Kinds are the kinds of objects reported, DefaultCachedKinds if empty.
+	// They must be watched by the controllers, the reports would otherwise
+	// start their informers.
+	Kinds    []CachedKind
+	Interval time.Duration
+	Log      logr.Logger
+}
+
+// Start reports the cached objects every interval until the context is
+// cancelled.
+func (c *CacheMetricsReporter) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, c.Report, c.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes the leader only report its cache, filled by the
+// controllers it runs.
+func (c *CacheMetricsReporter) NeedLeaderElection() bool {
+	return true
+}
+
+// Report updates the metrics of the cached objects.
+func (c *CacheMetricsReporter) Report(ctx context.Context) {
+	kinds := c.Kinds
+	if len(kinds) == 0 {
+		kinds = DefaultCachedKinds
+	}
+	for _, kind := range kinds {
+		list := kind.NewList()
+		// The cached objects are only read, not copied
+		err := c.Reader.List(ctx, list, client.UnsafeDisableDeepCopy)
+		if err != nil {
+			c.Log.V(1).Info("Unable to list the cached objects", "kind", kind.Kind, "error", err.Error())
+			continue
+		}
+		count, size := 0, 0
+		err = meta.EachListItem(list, func(obj runtime.Object) error {
+			count++
+			size += estimatedObjectSize(obj)
+			return nil
+		})
+		if err != nil {
+			c.Log.Error(err, "Unable to measure the cached objects", "kind", kind.Kind)
+			continue
+		}
+		controllerCacheObjects.WithLabelValues(kind.Kind).Set(float64(count))
+		controllerCacheEstimatedBytes.WithLabelValues(kind.Kind).Set(float64(size))
+	}
+}
+
+// estimatedObjectSize returns the size of the encoding of the object.
+func estimatedObjectSize(obj runtime.Object) int {
+	if sized, ok := obj.(interface{ Size() int }); ok {
+		return sized.Size()
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
@@ -0,0 +1,90 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestStripCachedObject(t *testing.T) {
+	meta := func() metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: "obj", Namespace: "ns",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+			Annotations: map[string]string{
+				corev1.LastAppliedConfigAnnotation: `{"data":{"key":"value"}}`,
+				"team":                             "ds",
+			}}
+	}
+
+	transformed, err := StripCachedObject(&corev1.Secret{ObjectMeta: meta()})
+	require.NoError(t, err)
+	secret := transformed.(*corev1.Secret)
+	assert.Nil(t, secret.ManagedFields)
+	assert.Equal(t, map[string]string{"team": "ds"}, secret.Annotations)
+
+	// The last applied configuration of the other objects is kept
+	transformed, err = StripCachedObject(&corev1.ServiceAccount{ObjectMeta: meta()})
+	require.NoError(t, err)
+	serviceAccount := transformed.(*corev1.ServiceAccount)
+	assert.Nil(t, serviceAccount.ManagedFields)
+	assert.Contains(t, serviceAccount.Annotations, corev1.LastAppliedConfigAnnotation)
+
+	// The managed fields read by the controller are kept
+	configMap := &corev1.ConfigMap{ObjectMeta: meta()}
+	configMap.Name = WorkbenchTrustedCABundleConfigMapName
+	for _, object := range []client.Object{
+		&routev1.Route{ObjectMeta: meta()},
+		&corev1.Service{ObjectMeta: meta()},
+		&netv1.NetworkPolicy{ObjectMeta: meta()},
+		configMap,
+	} {
+		transformed, err = StripCachedObject(object)
+		require.NoError(t, err)
+		assert.Equal(t, []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+			transformed.(client.Object).GetManagedFields(), "%T", object)
+	}
+	assert.Equal(t, map[string]string{"team": "ds"}, configMap.Annotations)
+
+	// The objects which are not Kubernetes objects are unchanged
+	transformed, err = StripCachedObject("tombstone")
+	require.NoError(t, err)
+	assert.Equal(t, "tombstone", transformed)
+}
+
+func TestCacheMetricsReporter(t *testing.T) {
+	r := newTestReconciler(t, OAuthConfig{},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "ns"},
+			Data: map[string]string{"key": "value"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "ns"}})
+	reporter := &CacheMetricsReporter{Reader: r.Client, Log: logr.Discard(), Kinds: []CachedKind{
+		{Kind: "ConfigMap", NewList: func() client.ObjectList { return &corev1.ConfigMapList{} }},
+	}}
+	reporter.Report(context.Background())
+
+	assert.Equal(t, 2.0, metricValue(t, controllerCacheObjects.WithLabelValues("ConfigMap")).GetGauge().GetValue())
+	assert.Greater(t, metricValue(t, controllerCacheEstimatedBytes.WithLabelValues("ConfigMap")).GetGauge().GetValue(),
+		0.0)
+}
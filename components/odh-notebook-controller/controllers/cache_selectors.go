@@ -0,0 +1,119 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KubeRootCAConfigMapName is the ConfigMap holding the CA of the API server,
+// published in each namespace.
+const KubeRootCAConfigMapName = "kube-root-ca.crt"
+
+// CachedConfigMapNames are the ConfigMaps not created by the controller which
+// are read on each reconcile of the trusted CA bundles. They are cached by
+// name when the cache of the manager is restricted.
+var CachedConfigMapNames = []string{TrustedCABundleConfigMapName, KubeRootCAConfigMapName}
+
+// RestrictedCacheByObject returns the selectors restricting the Secrets and
+// ConfigMaps cached by the manager to the ones created by the controller. As
+// the controller owns some Secrets, the cache otherwise holds the Secrets and
+// ConfigMaps of all the namespaces, the largest objects of the clusters.
+func RestrictedCacheByObject() map[client.Object]cache.ByObject {
+	managed := labels.SelectorFromSet(labels.Set{LabelManagedBy: ManagedByValue})
+	return map[client.Object]cache.ByObject{
+		&corev1.Secret{}:    {Label: managed},
+		&corev1.ConfigMap{}: {Label: managed},
+	}
+}
+
+// NamedConfigMapCache caches the ConfigMaps of a name in all the namespaces,
+// left out of the restricted cache of the manager.
+type NamedConfigMapCache struct {
+	cache.Cache
+	Name string
+}
+
+// NewNamedConfigMapCache returns the cache of the ConfigMaps of the name, with
+// the scheme, the mapper and the transform of the options.
+func NewNamedConfigMapCache(config *rest.Config, options cache.Options, name string) (*NamedConfigMapCache, error) {
+	options.ByObject = map[client.Object]cache.ByObject{
+		&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", name)},
+	}
+	c, err := cache.New(config, options)
+	if err != nil {
+		return nil, err
+	}
+	return &NamedConfigMapCache{Cache: c, Name: name}, nil
+}
+
+// GetCache makes the manager start the cache along with its own, before the
+// controllers and the webhook read from it.
+func (c *NamedConfigMapCache) GetCache() cache.Cache {
+	return c.Cache
+}
+
+// RestrictedCacheClient is the client of the controller when the cache of
+// the manager is restricted. The ConfigMaps cached by name are read from
+// their caches, and the other Secrets and ConfigMaps missing from the cache,
+// e.g. created by the users or before the cache was restricted, from the API
+// server, as are all the lists of Secrets and ConfigMaps.
+type RestrictedCacheClient struct {
+	client.Client
+	// APIReader reads the Secrets and ConfigMaps missing from the cache, and
+	// lists the Secrets and ConfigMaps.
+	APIReader client.Reader
+	// ConfigMaps are the readers of the ConfigMaps cached by name.
+	ConfigMaps map[string]client.Reader
+}
+
+// Get reads the object from the cache, or from the API server if it is a
+// Secret or a ConfigMap missing from the cache.
+func (c *RestrictedCacheClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		if reader, ok := c.ConfigMaps[key.Name]; ok {
+			return reader.Get(ctx, key, obj, opts...)
+		}
+	case *corev1.Secret:
+	default:
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	err := c.Client.Get(ctx, key, obj, opts...)
+	if apierrs.IsNotFound(err) {
+		return c.APIReader.Get(ctx, key, obj, opts...)
+	}
+	return err
+}
+
+// List lists the objects from the cache, or from the API server if they are
+// Secrets or ConfigMaps, of which the cache only holds the managed ones.
+func (c *RestrictedCacheClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	switch list.(type) {
+	case *corev1.SecretList, *corev1.ConfigMapList:
+		return c.APIReader.List(ctx, list, opts...)
+	}
+	return c.Client.List(ctx, list, opts...)
+}
@@ -0,0 +1,93 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRestrictedCacheByObject(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	byObject := RestrictedCacheByObject()
+	require.Len(t, byObject, 2)
+	for object, selectors := range byObject {
+		assert.True(t, selectors.Label.Matches(labels.Set(NotebookObjectLabels(notebook, ComponentOAuthProxy))),
+			"%T", object)
+		assert.True(t, selectors.Label.Matches(labels.Set(trustedCABundleLabels())), "%T", object)
+		assert.False(t, selectors.Label.Matches(labels.Set{"team": "ds"}), "%T", object)
+	}
+}
+
+func TestRestrictedCacheClient(t *testing.T) {
+	managed := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "nb-oauth-config", Namespace: "ns",
+		Labels: map[string]string{LabelManagedBy: ManagedByValue}}}
+	user := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "aws-connection", Namespace: "ns"}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	caBundle := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: TrustedCABundleConfigMapName, Namespace: "ns"},
+		Data: map[string]string{"ca-bundle.crt": "cached"}}
+	apiCABundle := caBundle.DeepCopy()
+	apiCABundle.Data["ca-bundle.crt"] = "api"
+
+	c := &RestrictedCacheClient{
+		Client:    fake.NewClientBuilder().WithObjects(managed).Build(),
+		APIReader: fake.NewClientBuilder().WithObjects(managed, user, service, apiCABundle).Build(),
+		ConfigMaps: map[string]client.Reader{
+			TrustedCABundleConfigMapName: fake.NewClientBuilder().WithObjects(caBundle).Build(),
+		},
+	}
+	ctx := context.Background()
+
+	assert.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(managed), &corev1.Secret{}))
+	// The Secrets missing from the cache are read from the API server
+	assert.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(user), &corev1.Secret{}))
+	// The ConfigMaps cached by name are only read from their cache
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(caBundle), configMap))
+	assert.Equal(t, "cached", configMap.Data["ca-bundle.crt"])
+	err := c.Get(ctx, client.ObjectKey{Namespace: "other", Name: TrustedCABundleConfigMapName}, &corev1.ConfigMap{})
+	assert.True(t, apierrs.IsNotFound(err))
+	// The other objects are fully cached
+	err = c.Get(ctx, client.ObjectKeyFromObject(service), &corev1.Service{})
+	assert.True(t, apierrs.IsNotFound(err))
+
+	// The Secrets and ConfigMaps are listed from the API server, including the
+	// Secrets missing from the cache
+	secrets := &corev1.SecretList{}
+	require.NoError(t, c.List(ctx, secrets, client.InNamespace("ns")))
+	names := []string{}
+	for _, secret := range secrets.Items {
+		names = append(names, secret.Name)
+	}
+	assert.ElementsMatch(t, []string{managed.Name, user.Name}, names)
+	configMaps := &corev1.ConfigMapList{}
+	require.NoError(t, c.List(ctx, configMaps))
+	require.Len(t, configMaps.Items, 1)
+	assert.Equal(t, "api", configMaps.Items[0].Data["ca-bundle.crt"])
+	services := &corev1.ServiceList{}
+	require.NoError(t, c.List(ctx, services))
+	assert.Empty(t, services.Items)
+}
@@ -0,0 +1,85 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+// throttlingWarningInterval is the minimum interval between two client-side
+// throttling warnings, so that spawn storms do not flood the logs.
+const throttlingWarningInterval = time.Minute
+
+// ThrottlingObserver records how long the API requests of the Kubernetes
+// clients are delayed by their client-side rate limiter, warning when the
+// delay exceeds the configured threshold. Otherwise, spawn storms only show up
+// as unexplained notebook creation latencies.
+type ThrottlingObserver struct {
+	Log              logr.Logger
+	WarningThreshold time.Duration
+	WarningInterval  time.Duration
+
+	mu          sync.Mutex
+	lastWarning time.Time
+	suppressed  int
+}
+
+// NewThrottlingObserver returns an observer warning at most once per minute
+// about the requests delayed for longer than the given threshold.
+func NewThrottlingObserver(warningThreshold time.Duration, log logr.Logger) *ThrottlingObserver {
+	return &ThrottlingObserver{
+		Log:              log,
+		WarningThreshold: warningThreshold,
+		WarningInterval:  throttlingWarningInterval,
+	}
+}
+
+// RegisterThrottlingObserver installs the observer as the client-go rate
+// limiter latency metric, so that every client keeps its own rate limiter
+// while being observed. It must be called before the clients are used.
+func RegisterThrottlingObserver(observer *ThrottlingObserver) {
+	// clientmetrics.Register only applies its options once, and it is already
+	// called by controller-runtime without a rate limiter latency metric
+	clientmetrics.RateLimiterLatency = observer
+}
+
+// Observe records the time waited by an API request on the client-side rate
+// limiter.
+func (o *ThrottlingObserver) Observe(_ context.Context, verb string, u url.URL, latency time.Duration) {
+	clientThrottlingSeconds.Observe(latency.Seconds())
+	if o.WarningThreshold <= 0 || latency < o.WarningThreshold {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	if !o.lastWarning.IsZero() && now.Sub(o.lastWarning) < o.WarningInterval {
+		o.suppressed++
+		return
+	}
+	o.Log.Info("Warning: API request delayed by client-side throttling, "+
+		"consider increasing --kube-api-qps and --kube-api-burst",
+		"waited", latency.String(), "verb", verb, "path", u.Path, "suppressedWarnings", o.suppressed)
+	o.lastWarning = now
+	o.suppressed = 0
+}
@@ -0,0 +1,52 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottlingObserver(t *testing.T) {
+	warnings := []string{}
+	log := funcr.New(func(_, args string) { warnings = append(warnings, args) }, funcr.Options{})
+	observer := NewThrottlingObserver(time.Second, log)
+	ctx := context.Background()
+	u := url.URL{Path: "/api/v1/namespaces/ns/serviceaccounts"}
+
+	observer.Observe(ctx, "GET", u, 100*time.Millisecond)
+	assert.Empty(t, warnings, "below the threshold")
+
+	for i := 0; i < 3; i++ {
+		observer.Observe(ctx, "GET", u, 2*time.Second)
+	}
+	assert.Len(t, warnings, 1, "warnings within the interval are suppressed")
+
+	observer.lastWarning = observer.lastWarning.Add(-throttlingWarningInterval)
+	observer.Observe(ctx, "GET", u, 2*time.Second)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, warnings[1], `"suppressedWarnings"=2`)
+
+	observer.WarningThreshold = 0
+	observer.lastWarning = time.Time{}
+	observer.Observe(ctx, "GET", u, time.Minute)
+	assert.Len(t, warnings, 2, "warning disabled")
+}
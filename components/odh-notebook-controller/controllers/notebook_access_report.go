@@ -0,0 +1,234 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AccessReportConfigMapName is the name of the ConfigMap holding the
+	// access report of the notebooks of a namespace.
+	AccessReportConfigMapName = "notebooks-access-report"
+	// AccessReportKey is the ConfigMap key of the JSON report.
+	AccessReportKey = "report.json"
+	// LabelAccessReport identifies the access report ConfigMaps.
+	LabelAccessReport = "notebooks.opendatahub.io/access-report"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=delete
+
+// AccessReport summarizes the notebooks of a namespace for the auditors.
+type AccessReport struct {
+	// UpdatedAt is the time the report last changed.
+	UpdatedAt string                 `json:"updatedAt"`
+	Notebooks []NotebookAccessReport `json:"notebooks"`
+}
+
+// NotebookAccessReport describes the exposure of a notebook.
+type NotebookAccessReport struct {
+	Name string `json:"name"`
+	// Owner is the user who created the notebook, if known.
+	Owner string `json:"owner,omitempty"`
+	// URL is the URL of the notebook route, empty until the route is
+	// admitted.
+	URL string `json:"url,omitempty"`
+	// AuthMode is "oauth-proxy" when the notebook is behind the OAuth proxy,
+	// "none" otherwise.
+	AuthMode     string                 `json:"authMode"`
+	Images       []ContainerImageReport `json:"images"`
+	LastActivity string                 `json:"lastActivity,omitempty"`
+	Stopped      bool                   `json:"stopped"`
+}
+
+// ContainerImageReport holds the image of a notebook container, and its
+// digest once the container is running.
+type ContainerImageReport struct {
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	ImageID   string `json:"imageID,omitempty"`
+}
+
+// AccessReporter periodically writes the access report of the notebooks of
+// each namespace in a ConfigMap.
+type AccessReporter struct {
+	client.Client
+	Log logr.Logger
+	// Interval is the interval between two generations of the reports.
+	Interval time.Duration
+}
+
+// Start generates the reports until the context is cancelled.
+func (a *AccessReporter) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := a.GenerateReports(ctx); err != nil {
+			a.Log.Error(err, "Unable to generate the notebook access reports")
+		}
+	}, a.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes the reports generated by the leader only.
+func (a *AccessReporter) NeedLeaderElection() bool {
+	return true
+}
+
+// GenerateReports writes the access report of every namespace holding
+// notebooks, and deletes the reports of the namespaces without notebooks.
+func (a *AccessReporter) GenerateReports(ctx context.Context) error {
+	notebookList := &nbv1.NotebookList{}
+	if err := a.List(ctx, notebookList); err != nil {
+		return err
+	}
+	reports := map[string][]NotebookAccessReport{}
+	for i := range notebookList.Items {
+		notebook := &notebookList.Items[i]
+		report, err := a.notebookAccessReport(ctx, notebook)
+		if err != nil {
+			return err
+		}
+		reports[notebook.Namespace] = append(reports[notebook.Namespace], report)
+	}
+
+	for namespace, notebooks := range reports {
+		sort.Slice(notebooks, func(i, j int) bool { return notebooks[i].Name < notebooks[j].Name })
+		if err := a.writeReport(ctx, namespace, notebooks); err != nil {
+			return err
+		}
+	}
+
+	configMapList := &corev1.ConfigMapList{}
+	if err := a.List(ctx, configMapList, client.HasLabels{LabelAccessReport}); err != nil {
+		return err
+	}
+	for i := range configMapList.Items {
+		configMap := &configMapList.Items[i]
+		if _, ok := reports[configMap.Namespace]; ok || configMap.Name != AccessReportConfigMapName {
+			continue
+		}
+		a.Log.Info("Deleting the access report of a namespace without notebooks", "namespace", configMap.Namespace)
+		if err := a.Delete(ctx, configMap); err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// notebookAccessReport returns the access report of the notebook.
+func (a *AccessReporter) notebookAccessReport(ctx context.Context, notebook *nbv1.Notebook) (NotebookAccessReport, error) {
+	annotations := notebook.GetAnnotations()
+	report := NotebookAccessReport{
+		Name:         notebook.Name,
+		Owner:        annotations[AnnotationCreator],
+		AuthMode:     "none",
+		Images:       []ContainerImageReport{},
+		LastActivity: annotations[culler.LAST_ACTIVITY_ANNOTATION],
+		Stopped: metav1.HasAnnotation(notebook.ObjectMeta, culler.STOP_ANNOTATION) &&
+			annotations[culler.STOP_ANNOTATION] != AnnotationValueReconciliationLock,
+	}
+	if OAuthInjectionIsEnabled(notebook.ObjectMeta) {
+		report.AuthMode = "oauth-proxy"
+	}
+
+	route := &routev1.Route{}
+	err := a.Get(ctx, types.NamespacedName{Name: notebook.Name, Namespace: notebook.Namespace}, route)
+	if err == nil {
+		for _, ingress := range route.Status.Ingress {
+			if ingress.Host != "" {
+				report.URL = "https://" + ingress.Host
+				break
+			}
+		}
+	} else if !apierrs.IsNotFound(err) {
+		return report, err
+	}
+
+	pod := &corev1.Pod{}
+	imageIDs := map[string]string{}
+	err = a.Get(ctx, types.NamespacedName{Name: notebook.Name + "-0", Namespace: notebook.Namespace}, pod)
+	if err == nil {
+		for _, status := range pod.Status.ContainerStatuses {
+			imageIDs[status.Name] = status.ImageID
+		}
+	} else if !apierrs.IsNotFound(err) {
+		return report, err
+	}
+	for _, container := range notebook.Spec.Template.Spec.Containers {
+		report.Images = append(report.Images, ContainerImageReport{
+			Container: container.Name,
+			Image:     container.Image,
+			ImageID:   imageIDs[container.Name],
+		})
+	}
+	return report, nil
+}
+
+// writeReport creates or updates the access report ConfigMap of the
+// namespace, leaving it untouched if the notebooks did not change.
+func (a *AccessReporter) writeReport(ctx context.Context, namespace string, notebooks []NotebookAccessReport) error {
+	configMap := &corev1.ConfigMap{}
+	err := a.Get(ctx, types.NamespacedName{Name: AccessReportConfigMapName, Namespace: namespace}, configMap)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if exists {
+		current := AccessReport{}
+		if json.Unmarshal([]byte(configMap.Data[AccessReportKey]), &current) == nil {
+			currentNotebooks, _ := json.Marshal(current.Notebooks)
+			newNotebooks, _ := json.Marshal(notebooks)
+			if string(currentNotebooks) == string(newNotebooks) {
+				return nil
+			}
+		}
+	}
+
+	data, err := json.Marshal(AccessReport{
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		Notebooks: notebooks,
+	})
+	if err != nil {
+		return err
+	}
+	configMap.Name = AccessReportConfigMapName
+	configMap.Namespace = namespace
+	if configMap.Labels == nil {
+		configMap.Labels = map[string]string{}
+	}
+	configMap.Labels[LabelAccessReport] = "true"
+	configMap.Labels[LabelManagedBy] = ManagedByValue
+	configMap.Data = map[string]string{AccessReportKey: string(data)}
+	if !exists {
+		a.Log.Info("Creating the notebook access report", "namespace", namespace)
+		return a.Create(ctx, configMap)
+	}
+	a.Log.Info("Updating the notebook access report", "namespace", namespace)
+	return a.Update(ctx, configMap)
+}
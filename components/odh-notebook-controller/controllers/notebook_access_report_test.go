@@ -0,0 +1,93 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestGenerateAccessReports(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", Annotations: map[string]string{
+			AnnotationCreator:               "alice",
+			AnnotationInjectOAuth:           "true",
+			culler.LAST_ACTIVITY_ANNOTATION: "2024-01-01T00:00:00Z",
+		}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb", Image: "quay.io/jupyter:2024.1"}},
+		}}},
+	}
+	stopped := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "stopped", Namespace: "ns",
+		Annotations: map[string]string{culler.STOP_ANNOTATION: "2024-01-02T00:00:00Z"}}}
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+		Status:     routev1.RouteStatus{Ingress: []routev1.RouteIngress{{Host: "nb-ns.apps.example.com"}}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb-0", Namespace: "ns"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "nb", ImageID: "quay.io/jupyter@sha256:abc"},
+		}},
+	}
+	staleReport := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: AccessReportConfigMapName,
+		Namespace: "old", Labels: map[string]string{LabelAccessReport: "true"}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook, stopped, route, pod, staleReport)
+	a := &AccessReporter{Client: r.Client, Log: logr.Discard()}
+
+	require.NoError(t, a.GenerateReports(ctx))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, a.Get(ctx, client.ObjectKey{Namespace: "ns", Name: AccessReportConfigMapName}, configMap))
+	report := AccessReport{}
+	require.NoError(t, json.Unmarshal([]byte(configMap.Data[AccessReportKey]), &report))
+	assert.NotEmpty(t, report.UpdatedAt)
+	assert.Equal(t, []NotebookAccessReport{
+		{
+			Name:     "nb",
+			Owner:    "alice",
+			URL:      "https://nb-ns.apps.example.com",
+			AuthMode: "oauth-proxy",
+			Images: []ContainerImageReport{
+				{Container: "nb", Image: "quay.io/jupyter:2024.1", ImageID: "quay.io/jupyter@sha256:abc"},
+			},
+			LastActivity: "2024-01-01T00:00:00Z",
+		},
+		{Name: "stopped", AuthMode: "none", Images: []ContainerImageReport{}, Stopped: true},
+	}, report.Notebooks)
+
+	// The report of the namespace without notebooks is deleted
+	err := a.Get(ctx, client.ObjectKey{Namespace: "old", Name: AccessReportConfigMapName}, &corev1.ConfigMap{})
+	assert.True(t, apierrs.IsNotFound(err))
+
+	// The report is left untouched while the notebooks do not change
+	require.NoError(t, a.GenerateReports(ctx))
+	unchanged := &corev1.ConfigMap{}
+	require.NoError(t, a.Get(ctx, client.ObjectKey{Namespace: "ns", Name: AccessReportConfigMapName}, unchanged))
+	assert.Equal(t, configMap.ResourceVersion, unchanged.ResourceVersion)
+}
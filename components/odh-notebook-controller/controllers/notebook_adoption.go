@@ -0,0 +1,189 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationAdopt set to true brings a notebook created by the upstream
+	// notebook controller under the management of the ODH controller.
+	AnnotationAdopt = "notebooks.opendatahub.io/adopt"
+	// AnnotationAdoptionPlan reports the changes applied to the upstream
+	// notebook once it is adopted, as a JSON array.
+	AnnotationAdoptionPlan = "notebooks.opendatahub.io/adoption-plan"
+)
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
+
+// isODHKey returns true if the annotation or label key belongs to the ODH
+// stack.
+func isODHKey(key string) bool {
+	return strings.Contains(key, "opendatahub.io/")
+}
+
+// NotebookIsUnmanaged returns true if the notebook was created by the upstream
+// notebook controller, i.e. it has no ODH annotation or label, and it has not
+// been adopted yet.
+func NotebookIsUnmanaged(notebook *nbv1.Notebook) bool {
+	if adopt, _ := strconv.ParseBool(notebook.GetAnnotations()[AnnotationAdopt]); adopt {
+		return false
+	}
+	for key := range notebook.GetAnnotations() {
+		if key != AnnotationAdopt && key != AnnotationAdoptionPlan && isODHKey(key) {
+			return false
+		}
+	}
+	for key := range notebook.GetLabels() {
+		if isODHKey(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// AdoptionPlan returns the changes applied to the upstream notebook once it
+// is adopted, and the existing objects still exposing it.
+func (r *OpenshiftNotebookReconciler) AdoptionPlan(notebook *nbv1.Notebook, ctx context.Context) ([]string, error) {
+	plan := []string{}
+
+	namespace := &corev1.Namespace{}
+	err := r.Get(ctx, types.NamespacedName{Name: notebook.Namespace}, namespace)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return nil, err
+	}
+	oauth, _ := strconv.ParseBool(namespace.GetLabels()[LabelNamespaceInjectOAuth])
+	if oauth {
+		plan = append(plan, "inject the OAuth proxy and use the "+OAuthServiceAccountName(notebook, r.OAuthConfig)+
+			" service account")
+	}
+	if r.ExposureConfig.RouteIsEnabled(notebook) {
+		plan = append(plan, "expose the notebook through the Route "+notebook.Name)
+	}
+
+	caBundle := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Name: TrustedCABundleConfigMapName, Namespace: notebook.Namespace}, caBundle)
+	if err == nil {
+		plan = append(plan, "mount the "+WorkbenchTrustedCABundleConfigMapName+" CA bundle")
+	} else if !apierrs.IsNotFound(err) {
+		return nil, err
+	}
+	plan = append(plan, "restrict the ingress traffic of the notebook with network policies")
+
+	ingresses := &netv1.IngressList{}
+	err = r.List(ctx, ingresses, client.InNamespace(notebook.Namespace))
+	if err != nil {
+		return nil, err
+	}
+	for _, ingress := range ingresses.Items {
+		if ingressTargetsService(&ingress, notebook.Name) {
+			plan = append(plan, fmt.Sprintf("the Ingress %s still exposes the Service %s, delete it "+
+				"once the notebook is adopted", ingress.Name, notebook.Name))
+		}
+	}
+
+	if !notebookIsStopped(notebook.ObjectMeta) {
+		plan = append(plan, "apply the changes of the pod on the next restart of the notebook")
+	}
+	return plan, nil
+}
+
+// ingressTargetsService returns true if the Ingress routes traffic to the
+// named Service.
+func ingressTargetsService(ingress *netv1.Ingress, service string) bool {
+	backends := []*netv1.IngressBackend{ingress.Spec.DefaultBackend}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			backends = append(backends, &rule.HTTP.Paths[i].Backend)
+		}
+	}
+	for _, backend := range backends {
+		if backend != nil && backend.Service != nil && backend.Service.Name == service {
+			return true
+		}
+	}
+	return false
+}
+
+// ReconcileAdoption reports the adoption plan of the upstream notebooks not
+// adopted yet, and returns true if the notebook must be left unmanaged. The
+// plan is removed once the notebook is adopted.
+func (r *OpenshiftNotebookReconciler) ReconcileAdoption(notebook *nbv1.Notebook, ctx context.Context) (bool, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if !r.UpstreamAdoption {
+		return false, nil
+	}
+	current, reported := notebook.GetAnnotations()[AnnotationAdoptionPlan]
+	if !NotebookIsUnmanaged(notebook) {
+		if !reported {
+			return false, nil
+		}
+		log.Info("Adopting the upstream notebook")
+		r.recordEvent(notebook, corev1.EventTypeNormal, "Adopted",
+			"The notebook is now managed by the ODH notebook controller")
+		patch := client.RawPatch(types.MergePatchType,
+			[]byte(`{"metadata":{"annotations":{"`+AnnotationAdoptionPlan+`":null}}}`))
+		return false, r.Patch(ctx, notebook, patch)
+	}
+
+	plan, err := r.AdoptionPlan(notebook, ctx)
+	if err != nil {
+		log.Error(err, "Unable to plan the adoption of the notebook")
+		return true, err
+	}
+	value, err := json.Marshal(plan)
+	if err != nil {
+		return true, err
+	}
+	if string(value) == current {
+		return true, nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{AnnotationAdoptionPlan: string(value)},
+		},
+	})
+	if err != nil {
+		return true, err
+	}
+	log.Info("Reporting the adoption plan of the upstream notebook", "plan", plan)
+	err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+	if err != nil {
+		log.Error(err, "Unable to report the adoption plan of the notebook")
+		return true, err
+	}
+	r.recordEvent(notebook, corev1.EventTypeNormal, "AdoptionPlanned",
+		"Set the %s annotation to true to adopt the notebook, which will: %s", AnnotationAdopt,
+		strings.Join(plan, "; "))
+	return true, nil
+}
@@ -0,0 +1,90 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNotebookIsUnmanaged(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Annotations: map[string]string{
+		"notebooks.kubeflow.org/last-activity": "2024-01-01T00:00:00Z",
+		AnnotationAdoptionPlan:                 "[]",
+	}}}
+	assert.True(t, NotebookIsUnmanaged(notebook))
+
+	notebook.Annotations[AnnotationAdopt] = "true"
+	assert.False(t, NotebookIsUnmanaged(notebook))
+	delete(notebook.Annotations, AnnotationAdopt)
+	notebook.Labels = map[string]string{"opendatahub.io/dashboard": "true"}
+	assert.False(t, NotebookIsUnmanaged(notebook))
+}
+
+func TestReconcileAdoption(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns",
+		Labels: map[string]string{LabelNamespaceInjectOAuth: "true"}}}
+	ingress := &netv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb-ingress", Namespace: "ns"},
+		Spec: netv1.IngressSpec{DefaultBackend: &netv1.IngressBackend{
+			Service: &netv1.IngressServiceBackend{Name: "nb", Port: netv1.ServiceBackendPort{Number: 80}},
+		}},
+	}
+	r := newTestReconciler(t, OAuthConfig{}, notebook, namespace, ingress)
+	r.UpstreamAdoption = true
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	// The plan of the upstream notebook is reported
+	unmanaged, err := r.ReconcileAdoption(notebook, ctx)
+	require.NoError(t, err)
+	assert.True(t, unmanaged)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	plan := []string{}
+	require.NoError(t, json.Unmarshal([]byte(notebook.Annotations[AnnotationAdoptionPlan]), &plan))
+	assert.Contains(t, plan, "inject the OAuth proxy and use the nb service account")
+	assert.Contains(t, plan, "the Ingress nb-ingress still exposes the Service nb, delete it once the notebook is adopted")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "AdoptionPlanned")
+
+	// The unchanged plan is not reported again
+	unmanaged, err = r.ReconcileAdoption(notebook, ctx)
+	require.NoError(t, err)
+	assert.True(t, unmanaged)
+	assert.Empty(t, recorder.Events)
+
+	// The plan is removed once the notebook is adopted
+	notebook.Annotations[AnnotationAdopt] = "true"
+	require.NoError(t, r.Update(ctx, notebook))
+	unmanaged, err = r.ReconcileAdoption(notebook, ctx)
+	require.NoError(t, err)
+	assert.False(t, unmanaged)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	assert.NotContains(t, notebook.Annotations, AnnotationAdoptionPlan)
+	assert.Contains(t, <-recorder.Events, "Adopted")
+}
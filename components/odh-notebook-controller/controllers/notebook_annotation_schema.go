@@ -0,0 +1,381 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+)
+
+// AnnotationType is the type of the value of an annotation, whose values are
+// always strings.
+type AnnotationType string
+
+const (
+	AnnotationTypeString    AnnotationType = "string"
+	AnnotationTypeBoolean   AnnotationType = "boolean"
+	AnnotationTypeInteger   AnnotationType = "integer"
+	AnnotationTypeNumber    AnnotationType = "number"
+	AnnotationTypeEnum      AnnotationType = "enum"
+	AnnotationTypeList      AnnotationType = "list"
+	AnnotationTypeURL       AnnotationType = "url"
+	AnnotationTypeJSON      AnnotationType = "json"
+	AnnotationTypeTimestamp AnnotationType = "timestamp"
+)
+
+// AnnotationTarget is the kind of the objects an annotation is set on.
+type AnnotationTarget string
+
+const (
+	AnnotationTargetNotebook  AnnotationTarget = "Notebook"
+	AnnotationTargetNamespace AnnotationTarget = "Namespace"
+)
+
+// AnnotationSpec describes an annotation honored by the webhook or the
+// controller.
+type AnnotationSpec struct {
+	Name        string           `json:"name"`
+	Type        AnnotationType   `json:"type"`
+	Target      AnnotationTarget `json:"target"`
+	Description string           `json:"description"`
+	// Enum holds the allowed values of the enum annotations.
+	Enum []string `json:"enum,omitempty"`
+	// ControllerOwned annotations are set by the controller, the users
+	// cannot change them.
+	ControllerOwned bool `json:"controllerOwned,omitempty"`
+}
+
+// annotationTypePatterns are the patterns of the values of the annotation
+// types, as accepted by strconv.ParseBool, strconv.Atoi and strconv.ParseFloat.
+var annotationTypePatterns = map[AnnotationType]string{
+	AnnotationTypeBoolean: `^(1|t|T|TRUE|true|True|0|f|F|FALSE|false|False)$`,
+	AnnotationTypeInteger: `^[0-9]+$`,
+	AnnotationTypeNumber:  `^[0-9]+(\.[0-9]+)?$`,
+}
+
+// AnnotationSpecs are the annotations honored by the webhook and the
+// controller, sorted by target and name. The new annotations must be added
+// here, the schema served to the dashboard and the CLI and the admission
+// validation are derived from it.
+var AnnotationSpecs = sortAnnotationSpecs([]AnnotationSpec{
+	// The annotations of the users
+	{Name: AnnotationAdopt, Type: AnnotationTypeBoolean,
+		Description: "Brings a notebook created by the upstream notebook controller under the management of the controller."},
+	{Name: AnnotationAllowInPlaceUpdates, Type: AnnotationTypeBoolean,
+		Description: "Restarts the running notebook with the changes of the controller instead of holding them until its next restart."},
+	{Name: AnnotationAuthProvider, Type: AnnotationTypeString,
+		Description: "Auth provider protecting the notebook: openshift-oauth, oauth2-proxy, kube-rbac-proxy or none."},
+	{Name: AnnotationCullingDisabled, Type: AnnotationTypeBoolean,
+		Description: "Prevents the culler from stopping the idle notebook."},
+	{Name: AnnotationDisplayName, Type: AnnotationTypeString,
+		Description: "Name of the notebook displayed by the dashboard."},
+	{Name: AnnotationExpose, Type: AnnotationTypeBoolean,
+		Description: "Set to false to keep the notebook cluster internal, without Route."},
+	{Name: AnnotationExternalDNSHostname, Type: AnnotationTypeString,
+		Description: "Custom hostname of the notebook Route, registered in the DNS by external-dns."},
+	{Name: AnnotationExternalDNSTTL, Type: AnnotationTypeInteger,
+		Description: "TTL in seconds of the DNS records of the custom hostname."},
+	{Name: AnnotationHibernate, Type: AnnotationTypeBoolean,
+		Description: "Hibernates the notebook, which is resumed when the annotation is removed or set to false."},
+	{Name: AnnotationIdleTimeout, Type: AnnotationTypeInteger,
+		Description: "Idle time in minutes after which the culler stops the notebook."},
+	{Name: AnnotationInferenceEndpoints, Type: AnnotationTypeList,
+		Description: "Comma-separated <name>=<url> inference endpoints exposed as INFERENCE_ENDPOINT_<NAME> variables."},
+	{Name: AnnotationInjectOAuth, Type: AnnotationTypeBoolean,
+		Description: "Protects the notebook with the OAuth proxy sidecar."},
+	{Name: AnnotationLastImageSelection, Type: AnnotationTypeString,
+		Description: "ImageStream tag selected for the notebook, as <imagestream>:<tag>."},
+	{Name: AnnotationLocale, Type: AnnotationTypeString,
+		Description: "Locale of the notebook exposed as the LANG variable, among the locales allowed by the controller."},
+	{Name: AnnotationLogoutUrl, Type: AnnotationTypeURL,
+		Description: "Address the users are redirected to when they log out of the OAuth proxy."},
+	{Name: AnnotationModelRegistryURL, Type: AnnotationTypeURL,
+		Description: "Model registry endpoint exposed as the MODEL_REGISTRY_URL variable."},
+	{Name: AnnotationNotebookRestart, Type: AnnotationTypeString,
+		Description: "Restarts the notebook pod when changed."},
+	{Name: AnnotationOIDCClientSecret, Type: AnnotationTypeString,
+		Description: "Secret holding the OpenID Connect client of the oauth2-proxy of the notebook."},
+	{Name: AnnotationOAuthCookieExpire, Type: AnnotationTypeString,
+		Description: "Lifetime of the session cookies of the auth proxy, e.g. 8h, at most the lifetime of the controller."},
+	{Name: AnnotationOAuthCookieRefresh, Type: AnnotationTypeString,
+		Description: "Interval after which the session cookies of the auth proxy are refreshed, e.g. 1h."},
+	{Name: AnnotationOAuthProxyExtraArgs, Type: AnnotationTypeString,
+		Description: "Whitespace-separated arguments appended to the OAuth proxy, among the flags allowed by the controller."},
+	{Name: AnnotationOAuthProxyResources, Type: AnnotationTypeString,
+		Description: "Resources of the auth proxy container, e.g. cpu=500m,memory=256Mi, used as its requests and limits."},
+	{Name: AnnotationOAuthSAR, Type: AnnotationTypeJSON,
+		Description: "Additional subject access reviews the users must pass to access the notebook."},
+	{Name: AnnotationOAuthSecret, Type: AnnotationTypeString,
+		Description: "Secret holding the cookie secret of the OAuth proxy, instead of the generated one."},
+	{Name: AnnotationOAuthUpstreamCA, Type: AnnotationTypeString,
+		Description: "ConfigMap holding the CA of the upstream of the OAuth proxy in its ca.crt key."},
+	{Name: AnnotationPlacement, Type: AnnotationTypeString,
+		Description: "Placement of the external scheduler deciding the cluster the notebook runs on."},
+	{Name: AnnotationPlacementCluster, Type: AnnotationTypeString,
+		Description: "Cluster the notebook runs on."},
+	{Name: AnnotationPrimaryContainer, Type: AnnotationTypeString,
+		Description: "Name of the notebook container, when it is not named after the notebook."},
+	{Name: AnnotationSkipMutation, Type: AnnotationTypeBoolean,
+		Description: "Admits the notebook without the mutations of the webhook, when allowed by the controller for the requesting user."},
+	{Name: AnnotationSessionKeepalive, Type: AnnotationTypeString,
+		Description: "Time the idle sessions are kept alive, e.g. 4h, setting the Route timeouts, the cookie refresh and the idle timeout."},
+	{Name: AnnotationReconcile, Type: AnnotationTypeEnum, Enum: []string{AnnotationValueReconcileNow},
+		Description: "Requests an immediate reconcile of the notebook resources."},
+	{Name: AnnotationRestartAcknowledged, Type: AnnotationTypeBoolean,
+		Description: "Confirms the restart of the notebook requested by the controller."},
+	{Name: AnnotationRouterShard, Type: AnnotationTypeString,
+		Description: "Router shard publishing the notebook Route."},
+	{Name: AnnotationSCC, Type: AnnotationTypeString,
+		Description: "SecurityContextConstraint configured by the administrators used by the notebook pod."},
+	{Name: AnnotationServiceMesh, Type: AnnotationTypeBoolean,
+		Description: "Adds the notebook to the service mesh, exclusive with the OAuth proxy."},
+	{Name: AnnotationSidecars, Type: AnnotationTypeList,
+		Description: "Comma-separated names of the sidecar containers added by the users."},
+	{Name: AnnotationSpotInstance, Type: AnnotationTypeBoolean,
+		Description: "Runs the notebook on spot nodes."},
+	{Name: AnnotationStrictImageResolution, Type: AnnotationTypeBoolean,
+		Description: "Denies the notebook when its selected image cannot be resolved from the ImageStreams, it cannot relax the controller setting."},
+	{Name: AnnotationStrictReferenceValidation, Type: AnnotationTypeBoolean,
+		Description: "Denies the notebook when it references Secrets, ConfigMaps or PVCs missing from its namespace."},
+	{Name: AnnotationTimezone, Type: AnnotationTypeString,
+		Description: "IANA time zone of the notebook, e.g. Europe/Paris, exposed as the TZ variable."},
+	{Name: culler.STOP_ANNOTATION, Type: AnnotationTypeString,
+		Description: "Stops the notebook, set to the time it was stopped."},
+
+	// The annotations of the controller
+	{Name: AnnotationAdoptionPlan, Type: AnnotationTypeJSON, ControllerOwned: true,
+		Description: "Changes applied to the upstream notebook once it is adopted."},
+	{Name: AnnotationCreator, Type: AnnotationTypeString, ControllerOwned: true,
+		Description: "User who created the notebook."},
+	{Name: AnnotationDashboardDefaults, Type: AnnotationTypeList, ControllerOwned: true,
+		Description: "Settings of the dashboard configuration injected in the notebook."},
+	{Name: AnnotationGPUHours, Type: AnnotationTypeNumber, ControllerOwned: true,
+		Description: "Cumulative GPU-hours requested by the notebook, up to its last stop."},
+	{Name: AnnotationHibernated, Type: AnnotationTypeTimestamp, ControllerOwned: true,
+		Description: "Time the notebook was hibernated."},
+	{Name: AnnotationImagePullSecretsInjected, Type: AnnotationTypeList, ControllerOwned: true,
+		Description: "Pull secrets injected by the webhook."},
+	{Name: AnnotationLastAdmissionUID, Type: AnnotationTypeString, ControllerOwned: true,
+		Description: "UID of the last admission of the notebook by the webhook."},
+	{Name: AnnotationModelEnvInjected, Type: AnnotationTypeList, ControllerOwned: true,
+		Description: "Environment variables injected by the webhook from the model annotations."},
+	{Name: AnnotationMutationProvenance, Type: AnnotationTypeJSON, ControllerOwned: true,
+		Description: "Hash of the pod template produced by the webhook and version of the controller."},
+	{Name: AnnotationOAuthServiceAccount, Type: AnnotationTypeString, ControllerOwned: true,
+		Description: "Dedicated service account of the notebook, when it differs from the default one."},
+	{Name: AnnotationPipelinesAccess, Type: AnnotationTypeJSON, ControllerOwned: true,
+		Description: "RoleBindings granting the notebook access to the data science pipelines."},
+	{Name: AnnotationPlacementDecision, Type: AnnotationTypeString, ControllerOwned: true,
+		Description: "Cluster the notebook was placed on."},
+	{Name: AnnotationPropagatedLabels, Type: AnnotationTypeList, ControllerOwned: true,
+		Description: "Labels of the notebook propagated to the generated objects."},
+	{Name: AnnotationRecommendedSize, Type: AnnotationTypeString, ControllerOwned: true,
+		Description: "Size recommended for the notebook, advisory only."},
+	{Name: AnnotationRestartRequested, Type: AnnotationTypeTimestamp, ControllerOwned: true,
+		Description: "Time the controller requested the confirmation of the restart of the notebook."},
+	{Name: AnnotationRollout, Type: AnnotationTypeString, ControllerOwned: true,
+		Description: "UID of the last rollout that restarted the notebook."},
+	{Name: AnnotationRolloutRestartTime, Type: AnnotationTypeTimestamp, ControllerOwned: true,
+		Description: "Time the rollout restarted the notebook."},
+	{Name: AnnotationRunningHours, Type: AnnotationTypeNumber, ControllerOwned: true,
+		Description: "Cumulative hours the notebook ran, up to its last stop."},
+	{Name: AnnotationRunningSince, Type: AnnotationTypeTimestamp, ControllerOwned: true,
+		Description: "Start time of the running notebook."},
+	{Name: AnnotationImageDeprecated, Type: AnnotationTypeString, ControllerOwned: true,
+		Description: "Deprecation of the image selected by the notebook, from the annotations of its ImageStream tag."},
+	{Name: AnnotationSpotInjected, Type: AnnotationTypeJSON, ControllerOwned: true,
+		Description: "Spot settings injected by the webhook."},
+	{Name: AnnotationSpotInterrupted, Type: AnnotationTypeTimestamp, ControllerOwned: true,
+		Description: "Time the spot node of the notebook was reclaimed, removable to opt back in to spot nodes."},
+	{Name: AnnotationStoppedByNamespace, Type: AnnotationTypeTimestamp, ControllerOwned: true,
+		Description: "Stop request of the namespace which stopped the notebook."},
+	{Name: AnnotationTemplateRequest, Type: AnnotationTypeString, ControllerOwned: true,
+		Description: "UID of the NotebookTemplateRequest that created the notebook."},
+	{Name: AnnotationUpdatePending, Type: AnnotationTypeJSON, ControllerOwned: true,
+		Description: "Changes of the running notebook applied on its next restart."},
+	{Name: AnnotationVolumesAttached, Type: AnnotationTypeList, ControllerOwned: true,
+		Description: "Single-node volumes of the notebook still attached to another node."},
+
+	// The annotations of the namespaces
+	{Name: AnnotationImagePullSecrets, Type: AnnotationTypeList, Target: AnnotationTargetNamespace,
+		Description: "Pull secrets injected in the notebooks of the namespace."},
+	{Name: AnnotationNamespaceStopAll, Type: AnnotationTypeString, Target: AnnotationTargetNamespace,
+		Description: "Stops all the notebooks of the namespace, set to true or to the RFC3339 time they are stopped from."},
+	{Name: AnnotationNotebookDefaults, Type: AnnotationTypeJSON, Target: AnnotationTargetNamespace,
+		Description: "Namespace overrides of the default notebook metadata."},
+})
+
+// sortAnnotationSpecs defaults the target of the annotations to the notebooks
+// and sorts them by target and name.
+func sortAnnotationSpecs(specs []AnnotationSpec) []AnnotationSpec {
+	for i := range specs {
+		if specs[i].Target == "" {
+			specs[i].Target = AnnotationTargetNotebook
+		}
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		if specs[i].Target != specs[j].Target {
+			return specs[i].Target > specs[j].Target
+		}
+		return specs[i].Name < specs[j].Name
+	})
+	return specs
+}
+
+// annotationSpec returns the spec of the annotation of the target, nil if it
+// is not honored.
+func annotationSpec(target AnnotationTarget, name string) *AnnotationSpec {
+	for i := range AnnotationSpecs {
+		if AnnotationSpecs[i].Target == target && AnnotationSpecs[i].Name == name {
+			return &AnnotationSpecs[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks the value of the annotation against its type. The empty
+// values unset the annotations and are always valid.
+func (s AnnotationSpec) Validate(value string) error {
+	if value == "" {
+		return nil
+	}
+	valid := true
+	expected := "a " + string(s.Type)
+	switch s.Type {
+	case AnnotationTypeBoolean, AnnotationTypeInteger, AnnotationTypeNumber:
+		valid = regexp.MustCompile(annotationTypePatterns[s.Type]).MatchString(value)
+	case AnnotationTypeEnum:
+		valid = false
+		for _, allowed := range s.Enum {
+			valid = valid || value == allowed
+		}
+		expected = "one of " + strings.Join(s.Enum, ", ")
+	case AnnotationTypeURL:
+		parsed, err := url.Parse(value)
+		valid = err == nil && parsed.Scheme != "" && parsed.Host != ""
+		expected = "an absolute URL"
+	case AnnotationTypeJSON:
+		valid = json.Valid([]byte(value))
+		expected = "a JSON document"
+	case AnnotationTypeTimestamp:
+		_, err := time.Parse(time.RFC3339, value)
+		valid = err == nil
+		expected = "an RFC3339 time"
+	}
+	if !valid {
+		return fmt.Errorf("invalid %s annotation %q: expected %s", s.Name, value, expected)
+	}
+	return nil
+}
+
+// ValidateAnnotations checks the values of the annotations of the notebook
+// honored by the webhook and the controller against their schema. Only the
+// values set or changed by the request are checked, so that the notebooks
+// admitted before an annotation was validated can still be updated, e.g.
+// stopped. The old notebook is nil on creation.
+func ValidateAnnotations(notebook, oldNotebook *nbv1.Notebook) error {
+	var oldAnnotations map[string]string
+	if oldNotebook != nil {
+		oldAnnotations = oldNotebook.GetAnnotations()
+	}
+	keys := make([]string, 0, len(notebook.GetAnnotations()))
+	for key := range notebook.GetAnnotations() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := notebook.GetAnnotations()[key]
+		if oldValue, ok := oldAnnotations[key]; ok && oldValue == value {
+			continue
+		}
+		spec := annotationSpec(AnnotationTargetNotebook, key)
+		if spec == nil || spec.ControllerOwned {
+			continue
+		}
+		if err := spec.Validate(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnnotationSchema returns the JSON schema of the annotations of the target,
+// as a JSON object whose properties are the annotations.
+func AnnotationSchema(target AnnotationTarget) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, spec := range AnnotationSpecs {
+		if spec.Target != target {
+			continue
+		}
+		property := map[string]interface{}{
+			"type":        "string",
+			"description": spec.Description,
+			"x-odh-type":  spec.Type,
+		}
+		if pattern, ok := annotationTypePatterns[spec.Type]; ok {
+			property["pattern"] = pattern
+		}
+		switch spec.Type {
+		case AnnotationTypeEnum:
+			property["enum"] = spec.Enum
+		case AnnotationTypeURL:
+			property["format"] = "uri"
+		case AnnotationTypeJSON:
+			property["contentMediaType"] = "application/json"
+		case AnnotationTypeTimestamp:
+			property["format"] = "date-time"
+		}
+		if spec.ControllerOwned {
+			property["readOnly"] = true
+		}
+		properties[spec.Name] = property
+	}
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                fmt.Sprintf("Annotations of the %s objects", target),
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": map[string]interface{}{"type": "string"},
+	}
+}
+
+// AnnotationSchemaHandler serves the JSON schema of the annotations of the
+// notebooks, or of the target given by the target query parameter.
+func AnnotationSchemaHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		target := AnnotationTargetNotebook
+		if value := req.URL.Query().Get("target"); value != "" {
+			target = AnnotationTarget(value)
+		}
+		if target != AnnotationTargetNotebook && target != AnnotationTargetNamespace {
+			http.Error(w, fmt.Sprintf("unknown target %q", target), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(AnnotationSchema(target))
+	})
+}
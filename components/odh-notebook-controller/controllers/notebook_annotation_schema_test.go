@@ -0,0 +1,106 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAnnotationSpecsControllerOwned(t *testing.T) {
+	// The controller-owned annotations protected by the webhook are
+	// described by the schema
+	for key := range controllerAnnotations {
+		spec := annotationSpec(AnnotationTargetNotebook, key)
+		if assert.NotNil(t, spec, key) {
+			assert.True(t, spec.ControllerOwned, key)
+		}
+	}
+	names := map[AnnotationTarget]map[string]bool{}
+	for _, spec := range AnnotationSpecs {
+		if names[spec.Target] == nil {
+			names[spec.Target] = map[string]bool{}
+		}
+		assert.False(t, names[spec.Target][spec.Name], "duplicate annotation %s", spec.Name)
+		names[spec.Target][spec.Name] = true
+	}
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	newNotebook := func(annotations map[string]string) *nbv1.Notebook {
+		return &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", Annotations: annotations}}
+	}
+	valid := newNotebook(map[string]string{
+		AnnotationInjectOAuth:      "true",
+		AnnotationExternalDNSTTL:   "300",
+		AnnotationReconcile:        AnnotationValueReconcileNow,
+		AnnotationModelRegistryURL: "https://registry.example.com",
+		AnnotationOAuthSAR:         `{"resource":"services","verb":"get"}`,
+		AnnotationExpose:           "",
+		"example.com/unknown":      "anything",
+	})
+	assert.NoError(t, ValidateAnnotations(valid, nil))
+
+	for key, value := range map[string]string{
+		AnnotationInjectOAuth:      "yes",
+		AnnotationExternalDNSTTL:   "5m",
+		AnnotationReconcile:        "later",
+		AnnotationModelRegistryURL: "registry",
+		AnnotationOAuthSAR:         "{",
+	} {
+		err := ValidateAnnotations(newNotebook(map[string]string{key: value}), nil)
+		assert.ErrorContains(t, err, "invalid "+key+" annotation", key)
+	}
+
+	// The unchanged values and the controller-owned annotations are not
+	// checked
+	invalid := newNotebook(map[string]string{AnnotationSpotInstance: "maybe"})
+	assert.NoError(t, ValidateAnnotations(invalid, invalid.DeepCopy()))
+	assert.NoError(t, ValidateAnnotations(newNotebook(map[string]string{AnnotationRunningSince: "now"}), nil))
+}
+
+func TestAnnotationSchemaHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	AnnotationSchemaHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/annotations-schema", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	schema := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &schema))
+	properties := schema["properties"].(map[string]interface{})
+	property := properties[AnnotationReconcile].(map[string]interface{})
+	assert.Equal(t, []interface{}{AnnotationValueReconcileNow}, property["enum"])
+	property = properties[AnnotationRunningSince].(map[string]interface{})
+	assert.Equal(t, "date-time", property["format"])
+	assert.Equal(t, true, property["readOnly"])
+	assert.NotContains(t, properties, AnnotationNamespaceStopAll)
+
+	recorder = httptest.NewRecorder()
+	AnnotationSchemaHandler().ServeHTTP(recorder,
+		httptest.NewRequest(http.MethodGet, "/annotations-schema?target=Namespace", nil))
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &schema))
+	assert.Contains(t, schema["properties"], AnnotationNamespaceStopAll)
+
+	recorder = httptest.NewRecorder()
+	AnnotationSchemaHandler().ServeHTTP(recorder,
+		httptest.NewRequest(http.MethodGet, "/annotations-schema?target=Pod", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
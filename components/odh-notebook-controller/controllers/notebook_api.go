@@ -0,0 +1,118 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultNotebookAPIInitialBackoff is the delay before checking the
+	// Notebook API again the first time it is not served.
+	DefaultNotebookAPIInitialBackoff = time.Second
+	// DefaultNotebookAPIMaxBackoff caps the delay between two checks of the
+	// Notebook API.
+	DefaultNotebookAPIMaxBackoff = time.Minute
+)
+
+// errNotebookAPIUnavailable is reported by the readiness check until the
+// Notebook API is served.
+var errNotebookAPIUnavailable = errors.New("APIUnavailable: the Notebook API is not served by the cluster")
+
+// NotebookAPIIsServed returns true if the Notebook CRD is installed.
+func NotebookAPIIsServed(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(nbv1.GroupVersion.WithKind("Notebook").GroupKind(), nbv1.GroupVersion.Version)
+	return err == nil
+}
+
+// NotebookAPIWaiter waits with backoff for the Notebook CRD to be installed
+// before setting up the notebook controllers, rather than crash-looping when
+// the controller starts before the CRD, e.g. on operator ordering races.
+type NotebookAPIWaiter struct {
+	// Mapper discovers the Notebook API, it must not cache the missing
+	// kinds.
+	Mapper meta.RESTMapper
+	Log    logr.Logger
+	// InitialBackoff and MaxBackoff bound the delay between two checks.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Setup sets up the notebook controllers once the API is served.
+	Setup func() error
+
+	available atomic.Bool
+}
+
+// Start waits for the Notebook API and sets up the notebook controllers.
+func (w *NotebookAPIWaiter) Start(ctx context.Context) error {
+	notebookAPIAvailable.Set(0)
+	backoff := wait.Backoff{
+		Duration: w.InitialBackoff,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      w.MaxBackoff,
+	}
+	if backoff.Duration <= 0 {
+		backoff.Duration = DefaultNotebookAPIInitialBackoff
+	}
+	if backoff.Cap <= 0 {
+		backoff.Cap = DefaultNotebookAPIMaxBackoff
+	}
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(context.Context) (bool, error) {
+		if NotebookAPIIsServed(w.Mapper) {
+			return true, nil
+		}
+		w.Log.Info("The Notebook API is not served by the cluster, waiting for the Notebook CRD")
+		return false, nil
+	})
+	if err != nil {
+		// The context is cancelled, the manager is stopping
+		return nil
+	}
+
+	w.Log.Info("The Notebook API is served, starting the notebook controllers")
+	if err := w.Setup(); err != nil {
+		return err
+	}
+	w.available.Store(true)
+	notebookAPIAvailable.Set(1)
+	return nil
+}
+
+// NeedLeaderElection makes every replica wait for the API for its readiness,
+// the controllers set up afterwards still run on the leader only.
+func (w *NotebookAPIWaiter) NeedLeaderElection() bool {
+	return false
+}
+
+// Check is the readiness check of the Notebook API, failing with the
+// APIUnavailable state until the notebook controllers are set up.
+func (w *NotebookAPIWaiter) Check(_ *http.Request) error {
+	if !w.available.Load() {
+		return errNotebookAPIUnavailable
+	}
+	return nil
+}
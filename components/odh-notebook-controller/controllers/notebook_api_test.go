@@ -0,0 +1,57 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNotebookAPIWaiter(t *testing.T) {
+	setups := 0
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{nbv1.GroupVersion})
+	waiter := &NotebookAPIWaiter{
+		Mapper:         mapper,
+		Log:            logr.Discard(),
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Setup: func() error {
+			setups++
+			return nil
+		},
+	}
+
+	// The controllers are not set up while the CRD is missing
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, waiter.Start(ctx))
+	assert.Equal(t, 0, setups)
+	assert.ErrorIs(t, waiter.Check(nil), errNotebookAPIUnavailable)
+
+	// The controllers are set up once the CRD is installed
+	mapper.Add(nbv1.GroupVersion.WithKind("Notebook"), meta.RESTScopeNamespace)
+	require.NoError(t, waiter.Start(context.Background()))
+	assert.Equal(t, 1, setups)
+	assert.NoError(t, waiter.Check(nil))
+}
@@ -0,0 +1,617 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;list;watch;create;update;delete
+
+// AnnotationAuthProvider selects the auth provider protecting the notebook,
+// the default provider of the controller if its inject-oauth annotation is
+// true and the annotation is not set.
+const AnnotationAuthProvider = "notebooks.opendatahub.io/auth-provider"
+
+// The auth providers compiled in the controller.
+const (
+	// AuthProviderOpenShiftOAuth authenticates the users with the OpenShift
+	// OAuth proxy, logged in with their OpenShift account.
+	AuthProviderOpenShiftOAuth = "openshift-oauth"
+	// AuthProviderOAuth2Proxy authenticates the users with oauth2-proxy,
+	// logged in with the OpenID Connect provider of the controller.
+	AuthProviderOAuth2Proxy = "oauth2-proxy"
+	// AuthProviderKubeRBACProxy authenticates the bearer tokens of the
+	// clients with kube-rbac-proxy, authorized on the notebook by the
+	// Kubernetes RBAC.
+	AuthProviderKubeRBACProxy = "kube-rbac-proxy"
+	// AuthProviderNone injects no proxy, whatever the inject-oauth
+	// annotation of the notebook.
+	AuthProviderNone = "none"
+)
+
+const (
+	// KubeRBACProxyImage is the default image of the kube-rbac-proxy sidecar.
+	KubeRBACProxyImage = "quay.io/brancz/kube-rbac-proxy:v0.18.0"
+
+	kubeRBACProxyConfigKey    = "config.yaml"
+	kubeRBACProxyConfigVolume = "kube-rbac-proxy-config"
+	kubeRBACProxyConfigDir    = "/etc/kube-rbac-proxy"
+	authDelegatorClusterRole  = "system:auth-delegator"
+	// labelAuthDelegatorNamespace records the namespace of the notebook on
+	// its cluster scoped ClusterRoleBinding.
+	labelAuthDelegatorNamespace = "notebooks.opendatahub.io/notebook-namespace"
+)
+
+// AuthConfig holds the settings of the auth providers of the notebooks.
+type AuthConfig struct {
+	// DefaultProvider protects the notebooks whose inject-oauth annotation
+	// is true and without auth-provider annotation, AuthProviderOpenShiftOAuth
+	// if empty.
+	DefaultProvider string
+	// KubeRBACProxyImage is the image of the kube-rbac-proxy sidecar,
+	// KubeRBACProxyImage if empty.
+	KubeRBACProxyImage string
+	// OAuth2ProxyImage is the image of the oauth2-proxy sidecar,
+	// OAuth2ProxyImage if empty.
+	OAuth2ProxyImage string
+	// OIDCIssuerURL is the issuer of the OpenID Connect provider of
+	// oauth2-proxy, which cannot be selected if empty.
+	OIDCIssuerURL string
+	// OIDCClientID is the OpenID Connect client of oauth2-proxy, read from
+	// the client-id key of the Secret of the client if empty.
+	OIDCClientID string
+	// OIDCClientSecretName is the default Secret of the namespace of the
+	// notebooks holding the secret of the OpenID Connect client, under the
+	// client-secret key (see notebook_oauth2_proxy.go file).
+	OIDCClientSecretName string
+}
+
+// AuthInjector protects the notebooks with an auth proxy sidecar. The
+// injectors are registered by name with RegisterAuthInjector, e.g. from the
+// init function of a package compiled in by a downstream distribution, and
+// selected with the auth-provider annotation of the notebooks or the
+// --auth-provider flag. The proxy containers are named oauth-proxy and serve
+// HTTPS on the oauth-proxy port 8443 with the certificate of the <notebook>-tls
+// Secret, so that the OAuth Service and Route of the notebook expose any of
+// them. The pods of the protected notebooks run with the dedicated service
+// account of the notebook.
+type AuthInjector interface {
+	// Name is the name the injector is registered and selected with.
+	Name() string
+	// Inject mutates the notebook admitted by the webhook, e.g. injects the
+	// proxy sidecar and its volumes. The admission of the notebook is
+	// denied with the returned error.
+	Inject(ctx context.Context, w *NotebookWebhook, notebook *nbv1.Notebook) error
+	// Reconcile creates or updates the objects of the proxy other than the
+	// dedicated service account, Service and Route of the notebook.
+	Reconcile(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error
+}
+
+// CleaningAuthInjector is an AuthInjector deleting its objects from the
+// notebooks protected by another provider.
+type CleaningAuthInjector interface {
+	AuthInjector
+	// Cleanup deletes the objects of the provider, e.g. once the notebook
+	// selects another provider.
+	Cleanup(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error
+}
+
+var (
+	authInjectorsMu sync.RWMutex
+	authInjectors   = map[string]AuthInjector{}
+)
+
+// RegisterAuthInjector registers the injector under its name. It panics if
+// the name is empty or already registered.
+func RegisterAuthInjector(injector AuthInjector) {
+	authInjectorsMu.Lock()
+	defer authInjectorsMu.Unlock()
+	name := injector.Name()
+	if name == "" {
+		panic("the auth injectors must have a name")
+	}
+	if _, ok := authInjectors[name]; ok {
+		panic(fmt.Sprintf("the auth injector %s is already registered", name))
+	}
+	authInjectors[name] = injector
+}
+
+// RegisteredAuthInjectors returns the sorted names of the registered
+// injectors.
+func RegisteredAuthInjectors() []string {
+	authInjectorsMu.RLock()
+	defer authInjectorsMu.RUnlock()
+	names := make([]string, 0, len(authInjectors))
+	for name := range authInjectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupAuthInjector returns the injector registered under the name, nil if
+// none is.
+func LookupAuthInjector(name string) AuthInjector {
+	authInjectorsMu.RLock()
+	defer authInjectorsMu.RUnlock()
+	return authInjectors[name]
+}
+
+// ParseAuthProvider parses the default auth provider, which must be
+// registered and inject a proxy. AuthProviderOpenShiftOAuth is returned if
+// empty.
+func ParseAuthProvider(value string) (string, error) {
+	if value == "" {
+		return AuthProviderOpenShiftOAuth, nil
+	}
+	if value == AuthProviderNone || LookupAuthInjector(value) == nil {
+		providers := []string{}
+		for _, name := range RegisteredAuthInjectors() {
+			if name != AuthProviderNone {
+				providers = append(providers, name)
+			}
+		}
+		return "", fmt.Errorf("invalid auth provider %q, must be one of %v", value, providers)
+	}
+	return value, nil
+}
+
+// NotebookAuthProvider returns the auth provider of the notebook: the
+// provider of its auth-provider annotation, the default provider if its
+// inject-oauth annotation is true, AuthProviderNone otherwise.
+func NotebookAuthProvider(meta metav1.ObjectMeta, defaultProvider string) string {
+	if !OAuthInjectionIsEnabled(meta) {
+		return AuthProviderNone
+	}
+	if provider := meta.Annotations[AnnotationAuthProvider]; provider != "" {
+		return provider
+	}
+	if defaultProvider == "" {
+		return AuthProviderOpenShiftOAuth
+	}
+	return defaultProvider
+}
+
+// notebookAuthInjector returns the injector of the auth provider of the
+// notebook, or an error if the provider is not registered.
+func notebookAuthInjector(notebook *nbv1.Notebook, auth AuthConfig) (AuthInjector, error) {
+	provider := NotebookAuthProvider(notebook.ObjectMeta, auth.DefaultProvider)
+	injector := LookupAuthInjector(provider)
+	if injector == nil {
+		return nil, fmt.Errorf("unknown auth provider %q in the %s annotation, must be one of %v",
+			provider, AnnotationAuthProvider, RegisteredAuthInjectors())
+	}
+	return injector, nil
+}
+
+// ReconcileAuthProvider reconciles the objects of the auth provider of the
+// notebook, and deletes the objects of the other providers.
+func (r *OpenshiftNotebookReconciler) ReconcileAuthProvider(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	injector, err := notebookAuthInjector(notebook, r.AuthConfig)
+	if err != nil {
+		log.Error(err, "Unable to protect the notebook")
+		return NewUserActionableError("UnknownAuthProvider", err)
+	}
+
+	if injector.Name() != AuthProviderNone {
+		// Create the objects shared by the proxies (see notebook_oauth.go file)
+		err = r.ReconcileOAuthServiceAccount(notebook, ctx)
+		if err != nil {
+			return err
+		}
+
+		// Call the OAuth Service reconciler
+		err = r.ReconcileOAuthService(notebook, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = injector.Reconcile(ctx, r, notebook)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range RegisteredAuthInjectors() {
+		cleaner, ok := LookupAuthInjector(name).(CleaningAuthInjector)
+		if !ok || name == injector.Name() {
+			continue
+		}
+		if err := cleaner.Cleanup(ctx, r, notebook); err != nil {
+			log.Error(err, "Unable to clean up the objects of the auth provider", "provider", name)
+			return err
+		}
+	}
+	return nil
+}
+
+// setNotebookVolume replaces the volume of the same name in the pod template
+// of the notebook, or appends the volume.
+func setNotebookVolume(notebook *nbv1.Notebook, volume corev1.Volume) {
+	volumes := &notebook.Spec.Template.Spec.Volumes
+	for index := range *volumes {
+		if (*volumes)[index].Name == volume.Name {
+			(*volumes)[index] = volume
+			return
+		}
+	}
+	*volumes = append(*volumes, volume)
+}
+
+// secretVolume returns the volume of the Secret.
+func secretVolume(name, secretName string) corev1.Volume {
+	return corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  secretName,
+				DefaultMode: pointer.Int32Ptr(420),
+			},
+		},
+	}
+}
+
+// injectAuthProxy injects the proxy container in the notebook, along with
+// the volume of the certificate of its Service, and runs the pod with the
+// dedicated service account of the notebook.
+func injectAuthProxy(notebook *nbv1.Notebook, oauth OAuthConfig, proxyContainer corev1.Container,
+	probe corev1.ProbeHandler) {
+	proxyContainer.Name = OAuthProxyContainerName
+	proxyContainer.ImagePullPolicy = oauthProxyPullPolicy(oauth)
+	proxyContainer.Ports = []corev1.ContainerPort{{
+		Name:          OAuthServicePortName,
+		ContainerPort: 8443,
+		Protocol:      corev1.ProtocolTCP,
+	}}
+	proxyContainer.LivenessProbe = &corev1.Probe{
+		ProbeHandler:        probe,
+		InitialDelaySeconds: 30,
+		TimeoutSeconds:      1,
+		PeriodSeconds:       5,
+		SuccessThreshold:    1,
+		FailureThreshold:    3,
+	}
+	proxyContainer.ReadinessProbe = &corev1.Probe{
+		ProbeHandler:        probe,
+		InitialDelaySeconds: 5,
+		TimeoutSeconds:      1,
+		PeriodSeconds:       5,
+		SuccessThreshold:    1,
+		FailureThreshold:    3,
+	}
+	proxyContainer.Resources = oauthProxyResources(notebook, oauth)
+	proxyContainer.VolumeMounts = append(proxyContainer.VolumeMounts, corev1.VolumeMount{
+		Name:      "tls-certificates",
+		MountPath: "/etc/tls/private",
+	})
+	setOAuthProxyContainer(notebook, proxyContainer, oauth.NativeSidecar)
+	setNotebookVolume(notebook, secretVolume("tls-certificates", notebook.Name+"-tls"))
+
+	// Set a dedicated service account, do not use default
+	notebook.Spec.Template.Spec.ServiceAccountName = OAuthServiceAccountName(notebook, oauth)
+}
+
+// authInjector is an AuthInjector defined by its functions.
+type authInjector struct {
+	name      string
+	inject    func(ctx context.Context, w *NotebookWebhook, notebook *nbv1.Notebook) error
+	reconcile func(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error
+}
+
+func (a authInjector) Name() string {
+	return a.name
+}
+
+func (a authInjector) Inject(ctx context.Context, w *NotebookWebhook, notebook *nbv1.Notebook) error {
+	if a.inject == nil {
+		return nil
+	}
+	return a.inject(ctx, w, notebook)
+}
+
+func (a authInjector) Reconcile(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+	if a.reconcile == nil {
+		return nil
+	}
+	return a.reconcile(ctx, r, notebook)
+}
+
+// injectOpenShiftOAuth injects the OpenShift OAuth proxy (see
+// notebook_webhook.go file).
+func injectOpenShiftOAuth(ctx context.Context, w *NotebookWebhook, notebook *nbv1.Notebook) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	oauth := w.OAuthConfig
+	access, err := LoadNotebookAccessConfig(ctx, w.Client, notebook.Namespace)
+	if err != nil {
+		// Keep the subject access reviews of the cluster, which grant no
+		// more access, rather than rejecting the notebook
+		log.Error(err, "Unable to read the notebook access settings of the namespace")
+	}
+	oauth = access.Apply(oauth)
+	if _, err := NewOAuthSAR(notebook, oauth); err != nil {
+		return err
+	}
+	if notebook.GetAnnotations()[AnnotationLogoutUrl] == "" {
+		oauth.DefaultLogoutURL, err = w.LogoutConfig.DefaultLogoutURL(ctx, w.Client)
+		if err != nil {
+			// Keep the proxy without logout URL rather than rejecting the notebook
+			log.Error(err, "Unable to discover the default logout URL of the OAuth proxy")
+		}
+	}
+	return InjectOAuthProxy(notebook, oauth)
+}
+
+// reconcileOpenShiftOAuth reconciles the metrics and the cookie secret of the
+// OpenShift OAuth proxy.
+func reconcileOpenShiftOAuth(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+	// Call the OAuth metrics reconciler
+	err := r.ReconcileOAuthMetrics(notebook, ctx)
+	if err != nil {
+		return err
+	}
+
+	// Call the OAuth Secret reconciler
+	return r.ReconcileOAuthSecret(notebook, ctx)
+}
+
+// InjectKubeRBACProxy injects the kube-rbac-proxy sidecar, authorizing the
+// bearer tokens of the clients on the notebook. The authenticated user is
+// passed to the notebook in the X-Forwarded-User header, as by the OAuth
+// proxy.
+func InjectKubeRBACProxy(notebook *nbv1.Notebook, oauth OAuthConfig, auth AuthConfig) {
+	image := auth.KubeRBACProxyImage
+	if image == "" {
+		image = KubeRBACProxyImage
+	}
+	injectAuthProxy(notebook, oauth, corev1.Container{
+		Image: image,
+		Args: []string{
+			"--secure-listen-address=0.0.0.0:8443",
+			"--upstream=" + oauthUpstream + "/",
+			"--tls-cert-file=" + oauthTLSCertFile,
+			"--tls-private-key-file=" + oauthTLSKeyFile,
+			"--config-file=" + kubeRBACProxyConfigDir + "/" + kubeRBACProxyConfigKey,
+			"--auth-header-fields-enabled",
+			"--auth-header-user-field-name=X-Forwarded-User",
+			"--auth-header-groups-field-name=X-Forwarded-Groups",
+		},
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      kubeRBACProxyConfigVolume,
+			MountPath: kubeRBACProxyConfigDir,
+		}},
+	}, corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{
+			Port: intstr.FromString(OAuthServicePortName),
+		},
+	})
+	setNotebookVolume(notebook, corev1.Volume{
+		Name: kubeRBACProxyConfigVolume,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: kubeRBACProxyConfigMapName(notebook)},
+				DefaultMode:          pointer.Int32Ptr(420),
+			},
+		},
+	})
+}
+
+// kubeRBACProxyConfigMapName returns the name of the ConfigMap of the
+// kube-rbac-proxy configuration of the notebook.
+func kubeRBACProxyConfigMapName(notebook *nbv1.Notebook) string {
+	return notebook.Name + "-kube-rbac-proxy"
+}
+
+// NewKubeRBACProxyConfigMap defines the configuration of kube-rbac-proxy,
+// authorizing the requests of the clients allowed the verb of their request
+// on the notebook.
+func NewKubeRBACProxyConfigMap(notebook *nbv1.Notebook) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kubeRBACProxyConfigMapName(notebook),
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentOAuthProxy),
+		},
+		Data: map[string]string{
+			kubeRBACProxyConfigKey: fmt.Sprintf(`authorization:
+  resourceAttributes:
+    namespace: %s
+    apiGroup: %s
+    apiVersion: %s
+    resource: notebooks
+    name: %s
+`, notebook.Namespace, nbv1.GroupVersion.Group, nbv1.GroupVersion.Version, notebook.Name),
+		},
+	}
+}
+
+// authDelegatorName returns the name of the ClusterRoleBinding allowing the
+// kube-rbac-proxy of the notebook to review the tokens and the access of the
+// clients.
+func authDelegatorName(notebook *nbv1.Notebook) string {
+	return "notebook-auth-delegator-" + string(notebook.UID)
+}
+
+// NewAuthDelegatorClusterRoleBinding defines the ClusterRoleBinding granting
+// the system:auth-delegator ClusterRole to the dedicated service account of
+// the notebook. It is deleted along with the notebook by its cleanup
+// finalizer, as a cluster scoped object cannot be controlled by the notebook.
+func NewAuthDelegatorClusterRoleBinding(notebook *nbv1.Notebook, oauth OAuthConfig) *rbacv1.ClusterRoleBinding {
+	labels := NotebookObjectLabels(notebook, ComponentOAuthProxy)
+	labels[labelAuthDelegatorNamespace] = notebook.Namespace
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   authDelegatorName(notebook),
+			Labels: labels,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      OAuthServiceAccountName(notebook, oauth),
+			Namespace: notebook.Namespace,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     authDelegatorClusterRole,
+		},
+	}
+}
+
+// reconcileKubeRBACProxy reconciles the configuration of kube-rbac-proxy and
+// the ClusterRoleBinding of the service account of the notebook.
+func reconcileKubeRBACProxy(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	desiredConfigMap := NewKubeRBACProxyConfigMap(notebook)
+	foundConfigMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(desiredConfigMap), foundConfigMap)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating kube-rbac-proxy ConfigMap")
+		err = ctrl.SetControllerReference(notebook, desiredConfigMap, r.Scheme)
+		if err != nil {
+			log.Error(err, "Unable to add OwnerReference to the kube-rbac-proxy ConfigMap")
+			return err
+		}
+		err = r.Create(ctx, desiredConfigMap)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the kube-rbac-proxy ConfigMap")
+			return err
+		}
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the kube-rbac-proxy ConfigMap")
+		return err
+	} else if foundConfigMap.Data[kubeRBACProxyConfigKey] != desiredConfigMap.Data[kubeRBACProxyConfigKey] ||
+		mergeLabels(foundConfigMap, desiredConfigMap.Labels) {
+		log.Info("Reconciling kube-rbac-proxy ConfigMap")
+		foundConfigMap.Data = desiredConfigMap.Data
+		err = r.Update(ctx, foundConfigMap)
+		if err != nil {
+			log.Error(err, "Unable to reconcile the kube-rbac-proxy ConfigMap")
+			return err
+		}
+	}
+
+	desiredBinding := NewAuthDelegatorClusterRoleBinding(notebook, r.OAuthConfig)
+	foundBinding := &rbacv1.ClusterRoleBinding{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(desiredBinding), foundBinding)
+	if err == nil && (len(foundBinding.Subjects) != 1 || foundBinding.Subjects[0] != desiredBinding.Subjects[0]) {
+		// The service account of the notebook changed
+		log.Info("Reconciling auth delegator ClusterRoleBinding")
+		foundBinding.Subjects = desiredBinding.Subjects
+		err = r.Update(ctx, foundBinding)
+		if err != nil {
+			log.Error(err, "Unable to reconcile the auth delegator ClusterRoleBinding")
+		}
+		return err
+	} else if apierrs.IsNotFound(err) {
+		log.Info("Creating auth delegator ClusterRoleBinding")
+		err = r.Create(ctx, desiredBinding)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the auth delegator ClusterRoleBinding")
+			return err
+		}
+		return nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the auth delegator ClusterRoleBinding")
+	}
+	return err
+}
+
+// cleanupKubeRBACProxy deletes the configuration of kube-rbac-proxy and the
+// ClusterRoleBinding of the service account of the notebook.
+func cleanupKubeRBACProxy(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+	err := r.deleteControlledObject(ctx, notebook, kubeRBACProxyConfigMapName(notebook), &corev1.ConfigMap{})
+	if err != nil {
+		return err
+	}
+	return r.DeleteAuthDelegators(ctx, client.ObjectKeyFromObject(notebook))
+}
+
+// DeleteAuthDelegators deletes the auth delegator ClusterRoleBindings of the
+// notebook.
+func (r *OpenshiftNotebookReconciler) DeleteAuthDelegators(ctx context.Context, key types.NamespacedName) error {
+	bindings := &rbacv1.ClusterRoleBindingList{}
+	err := r.List(ctx, bindings, client.MatchingLabels{
+		LabelNotebookName:           key.Name,
+		labelAuthDelegatorNamespace: key.Namespace,
+		LabelManagedBy:              ManagedByValue,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range bindings.Items {
+		r.Log.Info("Deleting auth delegator ClusterRoleBinding", "notebook", key.Name,
+			"namespace", key.Namespace, "name", bindings.Items[i].Name)
+		err = r.Delete(ctx, &bindings.Items[i])
+		if err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// kubeRBACProxyInjector is the CleaningAuthInjector of kube-rbac-proxy.
+type kubeRBACProxyInjector struct{}
+
+func (kubeRBACProxyInjector) Name() string {
+	return AuthProviderKubeRBACProxy
+}
+
+func (kubeRBACProxyInjector) Inject(_ context.Context, w *NotebookWebhook, notebook *nbv1.Notebook) error {
+	InjectKubeRBACProxy(notebook, w.OAuthConfig, w.AuthConfig)
+	return nil
+}
+
+func (kubeRBACProxyInjector) Reconcile(ctx context.Context, r *OpenshiftNotebookReconciler,
+	notebook *nbv1.Notebook) error {
+	return reconcileKubeRBACProxy(ctx, r, notebook)
+}
+
+func (kubeRBACProxyInjector) Cleanup(ctx context.Context, r *OpenshiftNotebookReconciler,
+	notebook *nbv1.Notebook) error {
+	return cleanupKubeRBACProxy(ctx, r, notebook)
+}
+
+func init() {
+	RegisterAuthInjector(authInjector{name: AuthProviderOpenShiftOAuth,
+		inject: injectOpenShiftOAuth, reconcile: reconcileOpenShiftOAuth})
+	RegisterAuthInjector(authInjector{name: AuthProviderOAuth2Proxy,
+		inject: func(_ context.Context, w *NotebookWebhook, notebook *nbv1.Notebook) error {
+			return InjectOAuth2Proxy(notebook, w.OAuthConfig, w.AuthConfig)
+		},
+		reconcile: reconcileOAuth2Proxy})
+	RegisterAuthInjector(kubeRBACProxyInjector{})
+	RegisterAuthInjector(authInjector{name: AuthProviderNone})
+}
@@ -0,0 +1,138 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNotebookAuthProvider(t *testing.T) {
+	meta := func(annotations map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Annotations: annotations}
+	}
+	assert.Equal(t, AuthProviderNone, NotebookAuthProvider(meta(nil), AuthProviderKubeRBACProxy))
+	assert.Equal(t, AuthProviderOpenShiftOAuth,
+		NotebookAuthProvider(meta(map[string]string{AnnotationInjectOAuth: "true"}), ""))
+	assert.Equal(t, AuthProviderKubeRBACProxy,
+		NotebookAuthProvider(meta(map[string]string{AnnotationInjectOAuth: "true"}), AuthProviderKubeRBACProxy))
+	assert.Equal(t, AuthProviderOAuth2Proxy,
+		NotebookAuthProvider(meta(map[string]string{AnnotationAuthProvider: AuthProviderOAuth2Proxy}), ""))
+
+	// The none provider disables the injection of the inject-oauth annotation
+	disabled := meta(map[string]string{AnnotationInjectOAuth: "true", AnnotationAuthProvider: AuthProviderNone})
+	assert.False(t, OAuthInjectionIsEnabled(disabled))
+	assert.Equal(t, AuthProviderNone, NotebookAuthProvider(disabled, AuthProviderOpenShiftOAuth))
+}
+
+func TestParseAuthProvider(t *testing.T) {
+	provider, err := ParseAuthProvider("")
+	assert.NoError(t, err)
+	assert.Equal(t, AuthProviderOpenShiftOAuth, provider)
+
+	provider, err = ParseAuthProvider(AuthProviderKubeRBACProxy)
+	assert.NoError(t, err)
+	assert.Equal(t, AuthProviderKubeRBACProxy, provider)
+
+	for _, value := range []string{AuthProviderNone, "basic-auth"} {
+		_, err = ParseAuthProvider(value)
+		assert.Error(t, err, value)
+	}
+	assert.Panics(t, func() {
+		RegisterAuthInjector(authInjector{name: AuthProviderNone})
+	})
+}
+
+func TestInjectAuthProxies(t *testing.T) {
+	newNotebook := func() *nbv1.Notebook {
+		return &nbv1.Notebook{
+			ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+			Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "nb", Image: "jupyter:1"}},
+			}}},
+		}
+	}
+
+	notebook := newNotebook()
+	InjectKubeRBACProxy(notebook, OAuthConfig{ServiceAccountSuffix: "-oauth"}, AuthConfig{})
+	podSpec := notebook.Spec.Template.Spec
+	require.Len(t, podSpec.Containers, 2)
+	proxy := podSpec.Containers[1]
+	assert.Equal(t, OAuthProxyContainerName, proxy.Name)
+	assert.Equal(t, KubeRBACProxyImage, proxy.Image)
+	assert.Equal(t, OAuthServicePortName, proxy.Ports[0].Name)
+	assert.Contains(t, proxy.Args, "--config-file=/etc/kube-rbac-proxy/config.yaml")
+	assert.Contains(t, proxy.Args, "--auth-header-user-field-name=X-Forwarded-User")
+	assert.Equal(t, "nb-oauth", podSpec.ServiceAccountName)
+	volumes := map[string]bool{}
+	for _, volume := range podSpec.Volumes {
+		volumes[volume.Name] = true
+	}
+	assert.Equal(t, map[string]bool{"tls-certificates": true, kubeRBACProxyConfigVolume: true}, volumes)
+
+	// The proxy of the previous provider is replaced
+	assert.NoError(t, InjectOAuth2Proxy(notebook, OAuthConfig{}, AuthConfig{OIDCIssuerURL: "https://sso.example.com",
+		OIDCClientID: "notebooks", OIDCClientSecretName: "oidc-client"}))
+	require.Len(t, notebook.Spec.Template.Spec.Containers, 2)
+	proxy = notebook.Spec.Template.Spec.Containers[1]
+	assert.Equal(t, OAuth2ProxyImage, proxy.Image)
+	assert.Contains(t, proxy.Args, "--oidc-issuer-url=https://sso.example.com")
+
+	// oauth2-proxy cannot be injected without OpenID Connect provider
+	assert.Error(t, InjectOAuth2Proxy(newNotebook(), OAuthConfig{}, AuthConfig{}))
+}
+
+func TestReconcileAuthProviderKubeRBACProxy(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationAuthProvider: AuthProviderKubeRBACProxy}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	ctx := context.Background()
+
+	require.NoError(t, r.ReconcileAuthProvider(notebook, ctx))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "nb-kube-rbac-proxy"}, configMap))
+	assert.Contains(t, configMap.Data[kubeRBACProxyConfigKey], "resource: notebooks")
+	binding := &rbacv1.ClusterRoleBinding{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Name: "notebook-auth-delegator-nb-uid"}, binding))
+	assert.Equal(t, authDelegatorClusterRole, binding.RoleRef.Name)
+	assert.Equal(t, []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "nb", Namespace: "ns"}},
+		binding.Subjects)
+
+	// The objects of kube-rbac-proxy are deleted once another provider is
+	// selected
+	notebook.Annotations[AnnotationAuthProvider] = AuthProviderNone
+	require.NoError(t, r.ReconcileAuthProvider(notebook, ctx))
+	bindings := &rbacv1.ClusterRoleBindingList{}
+	require.NoError(t, r.List(ctx, bindings))
+	assert.Empty(t, bindings.Items)
+	configMaps := &corev1.ConfigMapList{}
+	require.NoError(t, r.List(ctx, configMaps))
+	assert.Empty(t, configMaps.Items)
+
+	// The unknown providers are reported to the users
+	notebook.Annotations[AnnotationAuthProvider] = "basic-auth"
+	err := r.ReconcileAuthProvider(notebook, ctx)
+	class, _ := ClassifyError(err)
+	assert.Equal(t, ErrorClassUserActionable, class)
+}
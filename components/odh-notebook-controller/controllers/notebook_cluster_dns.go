@@ -0,0 +1,117 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// DefaultClusterDomain is the DNS domain of the clusters installed with
+	// the default settings.
+	DefaultClusterDomain = "cluster.local"
+	// DefaultInternalRegistryHost is the service host of the OpenShift
+	// internal image registry.
+	DefaultInternalRegistryHost = "image-registry.openshift-image-registry.svc:5000"
+)
+
+// ClusterDNSConfig holds the DNS names of the cluster services, which differ
+// on the clusters installed with a custom cluster domain or exposing the
+// internal image registry on a custom host.
+type ClusterDNSConfig struct {
+	// ClusterDomain is the DNS domain of the cluster, DefaultClusterDomain if
+	// empty.
+	ClusterDomain string
+	// InternalRegistryHost is the <host>[:<port>] of the internal image
+	// registry, DefaultInternalRegistryHost if empty. IPv6 addresses are
+	// enclosed in brackets.
+	InternalRegistryHost string
+}
+
+// Validate checks the cluster domain and the internal registry host.
+func (c ClusterDNSConfig) Validate() error {
+	if errs := validation.IsDNS1123Subdomain(c.clusterDomain()); len(errs) > 0 {
+		return fmt.Errorf("invalid cluster domain %q: %s", c.clusterDomain(), strings.Join(errs, ", "))
+	}
+	host, port := splitRegistryHost(c.internalRegistryHost())
+	if net.ParseIP(strings.Trim(host, "[]")) == nil && len(validation.IsDNS1123Subdomain(host)) > 0 {
+		return fmt.Errorf("invalid internal registry host %q", c.internalRegistryHost())
+	}
+	if port != "" {
+		if number, err := strconv.Atoi(port); err != nil || len(validation.IsValidPortNum(number)) > 0 {
+			return fmt.Errorf("invalid internal registry port %q", port)
+		}
+	}
+	return nil
+}
+
+func (c ClusterDNSConfig) clusterDomain() string {
+	if c.ClusterDomain == "" {
+		return DefaultClusterDomain
+	}
+	return strings.TrimSuffix(c.ClusterDomain, ".")
+}
+
+func (c ClusterDNSConfig) internalRegistryHost() string {
+	if c.InternalRegistryHost == "" {
+		return DefaultInternalRegistryHost
+	}
+	return c.InternalRegistryHost
+}
+
+// splitRegistryHost splits the <host>[:<port>] of a registry, keeping the
+// brackets of the IPv6 addresses.
+func splitRegistryHost(hostPort string) (string, string) {
+	if host, port, err := net.SplitHostPort(hostPort); err == nil {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return host, port
+	}
+	return hostPort, ""
+}
+
+// InternalRegistryHosts returns the hosts the images of the internal registry
+// are pulled from. A service host is also reached through its fully qualified
+// name in the cluster domain.
+func (c ClusterDNSConfig) InternalRegistryHosts() []string {
+	hosts := []string{c.internalRegistryHost()}
+	host, port := splitRegistryHost(c.internalRegistryHost())
+	if strings.HasSuffix(host, ".svc") {
+		host = host + "." + c.clusterDomain()
+		if port != "" {
+			host = host + ":" + port
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// IsInternalRegistryImage returns true if the image is pulled from the
+// internal image registry.
+func (c ClusterDNSConfig) IsInternalRegistryImage(image string) bool {
+	for _, host := range c.InternalRegistryHosts() {
+		if strings.HasPrefix(image, host+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterDNSConfigValidate(t *testing.T) {
+	assert.NoError(t, ClusterDNSConfig{}.Validate())
+	assert.NoError(t, ClusterDNSConfig{ClusterDomain: "example.internal."}.Validate())
+	assert.NoError(t, ClusterDNSConfig{InternalRegistryHost: "[fd00::10]:5000"}.Validate())
+	assert.NoError(t, ClusterDNSConfig{InternalRegistryHost: "registry.example.com"}.Validate())
+
+	assert.Error(t, ClusterDNSConfig{ClusterDomain: "Example_Domain"}.Validate())
+	assert.Error(t, ClusterDNSConfig{InternalRegistryHost: "registry_host:5000"}.Validate())
+	assert.Error(t, ClusterDNSConfig{InternalRegistryHost: "registry.example.com:http"}.Validate())
+}
+
+func TestIsInternalRegistryImage(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ClusterDNSConfig
+		image  string
+		want   bool
+	}{
+		{"service host", ClusterDNSConfig{},
+			"image-registry.openshift-image-registry.svc:5000/ns/jupyter:1.0", true},
+		{"default cluster domain", ClusterDNSConfig{},
+			"image-registry.openshift-image-registry.svc.cluster.local:5000/ns/jupyter:1.0", true},
+		{"custom cluster domain", ClusterDNSConfig{ClusterDomain: "example.internal"},
+			"image-registry.openshift-image-registry.svc.example.internal:5000/ns/jupyter:1.0", true},
+		{"other cluster domain", ClusterDNSConfig{ClusterDomain: "example.internal"},
+			"image-registry.openshift-image-registry.svc.cluster.local:5000/ns/jupyter:1.0", false},
+		{"IPv6 registry", ClusterDNSConfig{InternalRegistryHost: "[fd00::10]:5000"},
+			"[fd00::10]:5000/ns/jupyter:1.0", true},
+		{"custom registry", ClusterDNSConfig{InternalRegistryHost: "registry.example.com"},
+			"image-registry.openshift-image-registry.svc:5000/ns/jupyter:1.0", false},
+		{"external image", ClusterDNSConfig{},
+			"quay.io/image-registry.openshift-image-registry.svc:5000/jupyter:1.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.config.IsInternalRegistryImage(tt.image))
+		})
+	}
+}
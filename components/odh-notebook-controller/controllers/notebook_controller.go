@@ -21,10 +21,10 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
-	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	netv1 "k8s.io/api/networking/v1"
@@ -33,45 +33,126 @@ import (
 	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
 	routev1 "github.com/openshift/api/route/v1"
+	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
 	AnnotationInjectOAuth             = "notebooks.opendatahub.io/inject-oauth"
+	LabelNamespaceInjectOAuth         = "opendatahub.io/inject-oauth"
 	AnnotationServiceMesh             = "opendatahub.io/service-mesh"
 	AnnotationValueReconciliationLock = "odh-notebook-controller-lock"
 	AnnotationLogoutUrl               = "notebooks.opendatahub.io/oauth-logout-url"
+	AnnotationLastAdmissionUID        = "notebooks.opendatahub.io/last-admission-uid"
 )
 
 // OpenshiftNotebookReconciler holds the controller configuration.
 type OpenshiftNotebookReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Log    logr.Logger
+	Scheme      *runtime.Scheme
+	Log         logr.Logger
+	OAuthConfig OAuthConfig
+	// AuthConfig holds the settings of the auth providers of the notebooks.
+	AuthConfig AuthConfig
+	// SpotConfig holds the settings of the notebooks running on spot nodes.
+	SpotConfig SpotConfig
+	// RouteConfig holds the router shards of the notebook routes.
+	RouteConfig RouteConfig
+	// ExposureConfig holds how the notebooks are exposed outside of the
+	// cluster.
+	ExposureConfig ExposureConfig
+	// SCCConfig holds the SecurityContextConstraints the notebooks may
+	// request.
+	SCCConfig SCCConfig
+	// NetworkConfig holds the settings of the notebook network policies.
+	NetworkConfig NetworkConfig
+	// CiliumEnabled is true if the CiliumNetworkPolicy resources are served.
+	CiliumEnabled bool
+	// MonitoringEnabled is true if the ServiceMonitor resources are served.
+	MonitoringEnabled bool
+	// DelayStartOnAttachedVolumes keeps the started notebooks stopped until
+	// their single-node volumes are detached from their previous node.
+	DelayStartOnAttachedVolumes bool
+	// Recorder records the events of the notebooks.
+	Recorder record.EventRecorder
+	// FieldManager is the field manager of the changes of the controller,
+	// DefaultFieldManager if empty.
+	FieldManager string
+	// DashboardConfigKey is the OdhDashboardConfig whose notebook settings
+	// are applied to the notebooks, none if empty.
+	DashboardConfigKey types.NamespacedName
+	// TrustedCABundleConfig holds the settings of the reconciles of the
+	// trusted CA bundles.
+	TrustedCABundleConfig TrustedCABundleConfig
+	// TrustedCABundleCache caches the odh-trusted-ca-bundle ConfigMaps when
+	// they are left out of the restricted cache of the manager, their changes
+	// are then watched from it.
+	TrustedCABundleCache cache.Cache
+	// PlacementConfig holds the multi-cluster placement of the notebooks.
+	PlacementConfig PlacementConfig
+	// ManifestWorksEnabled is true if the ManifestWork resources are served.
+	ManifestWorksEnabled bool
+	// LabelPropagationConfig holds the labels of the notebooks propagated to
+	// the generated objects.
+	LabelPropagationConfig LabelPropagationConfig
+	// UpstreamAdoption leaves the notebooks created by the upstream notebook
+	// controller unmanaged until they are adopted.
+	UpstreamAdoption bool
+	// StartupPageConfig holds the settings of the page served while the
+	// notebooks start.
+	StartupPageConfig StartupPageConfig
+	// FakeOpenShiftAPIs is true if the OpenShift APIs are served from memory
+	// by the client, the Routes are then not watched.
+	FakeOpenShiftAPIs bool
+	// SpawnQueue staggers the starts of the new notebooks, started as soon
+	// as they are reconciled if nil.
+	SpawnQueue *SpawnQueue
+	// SubReconcileConcurrency is the number of independent sub-reconcilers
+	// run concurrently within a notebook reconcile, in order if 1 or less.
+	SubReconcileConcurrency int
+	// ReconcileCache skips the sub-reconcilers of the notebooks whose desired
+	// state is already applied, they always run if nil.
+	ReconcileCache *ReconcileCache
+	// Exposers are the names of the exposers run in order to expose the
+	// notebooks, the DefaultExposers if empty.
+	Exposers []string
+	// EffectiveConfigEnabled publishes the effective configuration of the
+	// notebooks in generated ConfigMaps.
+	EffectiveConfigEnabled bool
+	// PullSecretsConfig holds the pull secrets propagated to the service
+	// accounts of the notebooks.
+	PullSecretsConfig PullSecretsConfig
+
+	trustedCABundleLimiter *rate.Limiter
+	// spawnStarts holds the start time of the starting notebooks, to
+	// observe their spawn duration.
+	spawnStarts sync.Map
 }
 
 // ClusterRole permissions
 
 // +kubebuilder:rbac:groups=kubeflow.org,resources=notebooks,verbs=get;list;watch;patch
-// +kubebuilder:rbac:groups=kubeflow.org,resources=notebooks/status,verbs=get
 // +kubebuilder:rbac:groups=kubeflow.org,resources=notebooks/finalizers,verbs=update
-// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=services;serviceaccounts;secrets;configmaps,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups=config.openshift.io,resources=proxies,verbs=get;list;watch
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // CompareNotebooks checks if two notebooks are equal, if not return false.
 func CompareNotebooks(nb1 nbv1.Notebook, nb2 nbv1.Notebook) bool {
@@ -80,9 +161,37 @@ func CompareNotebooks(nb1 nbv1.Notebook, nb2 nbv1.Notebook) bool {
 		reflect.DeepEqual(nb1.Spec, nb2.Spec)
 }
 
+// InjectOAuthFromNamespace enables the oauth sidecar injection of the notebook
+// if its namespace requires it with the LabelNamespaceInjectOAuth label,
+// whatever the notebook annotations, removing the selection of the none auth
+// provider. Returns true if the annotations changed.
+func InjectOAuthFromNamespace(notebook *nbv1.Notebook, namespace *corev1.Namespace) bool {
+	required, _ := strconv.ParseBool(namespace.GetLabels()[LabelNamespaceInjectOAuth])
+	if !required {
+		return false
+	}
+	changed := false
+	if notebook.GetAnnotations()[AnnotationAuthProvider] == AuthProviderNone {
+		delete(notebook.Annotations, AnnotationAuthProvider)
+		changed = true
+	}
+	if notebook.GetAnnotations()[AnnotationInjectOAuth] == "true" {
+		return changed
+	}
+	if notebook.Annotations == nil {
+		notebook.Annotations = map[string]string{}
+	}
+	notebook.Annotations[AnnotationInjectOAuth] = "true"
+	return true
+}
+
 // OAuthInjectionIsEnabled returns true if the oauth sidecar injection
-// annotation is present in the notebook.
+// annotation is present in the notebook, or if it selects an auth provider
+// other than none (see notebook_auth_injectors.go file).
 func OAuthInjectionIsEnabled(meta metav1.ObjectMeta) bool {
+	if provider := meta.Annotations[AnnotationAuthProvider]; provider != "" {
+		return provider != AuthProviderNone
+	}
 	if meta.Annotations[AnnotationInjectOAuth] != "" {
 		result, _ := strconv.ParseBool(meta.Annotations[AnnotationInjectOAuth])
 		return result
@@ -102,6 +211,42 @@ func ServiceMeshIsEnabled(meta metav1.ObjectMeta) bool {
 	}
 }
 
+// notebookLogger returns the logger for the given notebook, including the UID
+// of the last admission request that mutated it, so that a single admission
+// can be traced across the webhook and the subsequent reconciles.
+func (r *OpenshiftNotebookReconciler) notebookLogger(notebook *nbv1.Notebook) logr.Logger {
+	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	if uid := notebook.GetAnnotations()[AnnotationLastAdmissionUID]; uid != "" {
+		log = log.WithValues("admissionUID", uid)
+	}
+	return log
+}
+
+// recordEvent records an event for the notebook, annotated with the UID of
+// its last admission request like the events of the webhook.
+func (r *OpenshiftNotebookReconciler) recordEvent(notebook *nbv1.Notebook, eventType, reason, messageFmt string,
+	args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	if uid := notebook.GetAnnotations()[AnnotationLastAdmissionUID]; uid != "" {
+		r.Recorder.AnnotatedEventf(notebook, map[string]string{AnnotationAdmissionUID: uid}, eventType, reason,
+			messageFmt, args...)
+		return
+	}
+	r.Recorder.Eventf(notebook, eventType, reason, messageFmt, args...)
+}
+
+// ownerLogger returns the logger for the given owner of the generated objects,
+// either a notebook or a workspace.
+func (r *OpenshiftNotebookReconciler) ownerLogger(owner client.Object) logr.Logger {
+	if notebook, ok := owner.(*nbv1.Notebook); ok {
+		return r.notebookLogger(notebook)
+	}
+	return r.Log.WithValues(strings.ToLower(owner.GetObjectKind().GroupVersionKind().Kind), owner.GetName(),
+		"namespace", owner.GetNamespace())
+}
+
 // ReconciliationLockIsEnabled returns true if the reconciliation lock
 // annotation is present in the notebook.
 func ReconciliationLockIsEnabled(meta metav1.ObjectMeta) bool {
@@ -113,7 +258,8 @@ func ReconciliationLockIsEnabled(meta metav1.ObjectMeta) bool {
 }
 
 // RemoveReconciliationLock waits until the image pull secret is mounted in the
-// notebook service account to remove the reconciliation lock annotation.
+// notebook service account to remove the reconciliation lock annotation. The
+// OAuth Service and Route are checked beforehand (see waitForOAuthRouting).
 func (r *OpenshiftNotebookReconciler) RemoveReconciliationLock(notebook *nbv1.Notebook,
 	ctx context.Context) error {
 	// Wait until the image pull secret is mounted in the notebook service
@@ -126,7 +272,7 @@ func (r *OpenshiftNotebookReconciler) RemoveReconciliationLock(notebook *nbv1.No
 		func() error {
 			serviceAccount := &corev1.ServiceAccount{}
 			if err := r.Get(ctx, types.NamespacedName{
-				Name:      notebook.Name,
+				Name:      OAuthServiceAccountName(notebook, r.OAuthConfig),
 				Namespace: notebook.Namespace,
 			}, serviceAccount); err != nil {
 				return err
@@ -156,85 +302,190 @@ func (r *OpenshiftNotebookReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	err := r.Get(ctx, req.NamespacedName, notebook)
 	if err != nil && apierrs.IsNotFound(err) {
 		log.Info("Stop Notebook reconciliation")
-		return ctrl.Result{}, nil
+		if r.SpawnQueue != nil {
+			r.SpawnQueue.Forget(req.NamespacedName)
+		}
+		if r.ReconcileCache != nil {
+			r.ReconcileCache.Invalidate(req.NamespacedName)
+		}
+		// Clean up after the notebooks deleted without the cleanup finalizer,
+		// e.g. created before it was introduced
+		return ctrl.Result{}, r.CleanupNotebook(ctx, req.NamespacedName)
 	} else if err != nil {
 		log.Error(err, "Unable to fetch the Notebook")
 		return ctrl.Result{}, err
 	}
 
-	// Create Configmap with the ODH notebook certificate
-	// With the ODH 2.8 Operator, user can provide their own certificate
-	// from DSCI initializer, that provides the certs in a ConfigMap odh-trusted-ca-bundle
-	// create a separate ConfigMap for the notebook which append the user provided certs
-	// with cluster self-signed certs.
-	err = r.CreateNotebookCertConfigMap(notebook, ctx)
+	// Requeue the notebook depending on the class of the error
+	result, err := r.reconcileNotebook(notebook, ctx)
+	return r.handleReconcileError(notebook, ctx, result, err)
+}
+
+// reconcileNotebook reconciles the objects of the notebook.
+func (r *OpenshiftNotebookReconciler) reconcileNotebook(notebook *nbv1.Notebook, ctx context.Context) (ctrl.Result,
+	error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+	if ReconcileIsRequested(notebook.ObjectMeta) {
+		log.Info("Reconcile of the notebook resources requested")
+	}
+
+	// Leave the upstream notebooks unmanaged until they are adopted
+	unmanaged, err := r.ReconcileAdoption(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if unmanaged {
+		log.Info("Upstream notebook not adopted, skipping the reconcile")
+		return ctrl.Result{}, nil
+	}
+
+	// Remove what the owner references do not cover once the notebook is
+	// deleted
+	deleted, err := r.ReconcileFinalizer(notebook, ctx)
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	// Skip the notebooks placed on a remote cluster, mirrored there through
+	// a ManifestWork
+	cluster, err := r.ReconcilePlacement(notebook, ctx)
+	if errors.Is(err, ErrPlacementPending) {
+		log.Info("Waiting for the placement decision of the notebook")
+		return ctrl.Result{RequeueAfter: placementRequeueInterval}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	if cluster != "" {
+		log.Info("Notebook placed on a remote cluster, skipping the local reconcile", "cluster", cluster)
+		return ctrl.Result{}, nil
+	}
+
+	// Stop all the notebooks of the namespace when requested
+	stopAfter, err := r.ReconcileNamespaceStop(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Stop or restart the notebook when it is hibernated or resumed
+	err = r.ReconcileHibernation(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Track the running hours and GPU-hours of the notebook
+	err = r.ReconcileUsage(notebook, ctx)
 	if err != nil {
 		return ctrl.Result{}, err
-	} else {
-		// If createNotebookCertConfigMap returns nil,
-		// and still the ConfigMap workbench-trusted-ca-bundle is not found,
-		// reconcile notebook to unset the env variable.
-		if r.IsConfigMapDeleted(notebook, ctx) {
-			// Unset the env variable in the notebook
-			err = r.UnsetNotebookCertConfig(notebook, ctx)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-		}
 	}
+	r.ObserveSpawnDuration(notebook)
 
-	// Call the Network Policies reconciler
-	err = r.ReconcileAllNetworkPolicies(notebook, ctx)
+	// Apply the culling and toleration settings of the dashboard
+	err = r.ReconcileDashboardDefaults(notebook, ctx)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// Call the Rolebinding reconciler
-	if strings.ToLower(strings.TrimSpace(os.Getenv("SET_PIPELINE_RBAC"))) == "true" {
-		err = r.ReconcileRoleBindings(notebook, ctx)
+	// Skip the sub-reconcilers if the desired state of the notebook did not
+	// change since it was last applied (see notebook_reconcile_cache.go file)
+	skip, cacheEntry, err := r.desiredStateApplied(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if skip {
+		log.V(1).Info("Desired state already applied, skipping the sub-reconcilers")
+		notebookReconcileCacheSkipsTotal.Inc()
+	} else {
+		// Adopt or delete the objects left behind by previous notebooks,
+		// before the sub-reconcilers reconcile the objects of the notebook
+		err = r.ReconcileOrphanedObjects(notebook, ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Run the independent sub-reconcilers, i.e. the trusted CA bundle,
+		// the network policies, the RBAC and the exposure of the notebook,
+		// in order or concurrently (see notebook_subreconcilers.go file)
+		err = r.runSubReconcilers(notebook, ctx, r.notebookSubReconcilers())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Propagate the notebook labels to the generated objects
+		err = r.ReconcilePropagatedLabels(notebook, ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		err = r.recordDesiredState(notebook, cacheEntry)
 		if err != nil {
-			log.Error(err, "Unable to Reconcile Rolebinding")
 			return ctrl.Result{}, err
 		}
 	}
 
-	if !ServiceMeshIsEnabled(notebook.ObjectMeta) {
-		// Create the objects required by the OAuth proxy sidecar (see notebook_oauth.go file)
-		if OAuthInjectionIsEnabled(notebook.ObjectMeta) {
+	// Publish the effective configuration of the notebook, outside of the
+	// skipped sub-reconcilers as its URL is only known once its Route is
+	// admitted
+	err = r.ReconcileEffectiveConfig(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-			err = r.ReconcileOAuthServiceAccount(notebook, ctx)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
+	// Restart the notebook on on-demand capacity if its spot node is reclaimed
+	err = r.ReconcileSpotInterruption(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-			// Call the OAuth Service reconciler
-			err = r.ReconcileOAuthService(notebook, ctx)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
+	// Report the container restarts and out-of-memory kills
+	err = r.ReconcileContainerRestarts(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-			// Call the OAuth Secret reconciler
-			err = r.ReconcileOAuthSecret(notebook, ctx)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
+	// Report the deprecated image of the notebook
+	err = r.ReconcileImageDeprecation(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-			// Call the OAuth Route reconciler
-			err = r.ReconcileOAuthRoute(notebook, ctx)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-		} else {
-			// Call the route reconciler (see notebook_route.go file)
-			err = r.ReconcileRoute(notebook, ctx)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
+	// Report the volumes still attached to another node, which delay the
+	// start of the notebook
+	delayed, err := r.ReconcileVolumeAttachments(notebook, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Clear the reconcile request now that the resources are reconciled
+	if ReconcileIsRequested(notebook.ObjectMeta) {
+		err = r.ClearReconcileRequest(notebook, ctx)
+		if err != nil {
+			return ctrl.Result{}, err
 		}
 	}
 
 	// Remove the reconciliation lock annotation
 	if ReconciliationLockIsEnabled(notebook.ObjectMeta) {
+		if delayed {
+			log.Info("Waiting for the notebook volumes to be detached")
+			return ctrl.Result{RequeueAfter: volumeDetachRequeueInterval}, nil
+		}
+		if OAuthInjectionIsEnabled(notebook.ObjectMeta) && !ServiceMeshIsEnabled(notebook.ObjectMeta) {
+			waiting, err := r.waitForOAuthRouting(notebook, ctx)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if waiting {
+				return ctrl.Result{RequeueAfter: oauthReadinessRequeueInterval}, nil
+			}
+		}
+		if r.SpawnQueue != nil {
+			admitted, retryAfter := r.SpawnQueue.Admit(client.ObjectKeyFromObject(notebook), time.Now())
+			if !admitted {
+				log.Info("Waiting in the spawn queue for the start of the notebook")
+				return ctrl.Result{RequeueAfter: retryAfter}, nil
+			}
+		}
 		log.Info("Removing reconciliation lock")
 		err = r.RemoveReconciliationLock(notebook, ctx)
 		if err != nil {
@@ -242,7 +493,7 @@ func (r *OpenshiftNotebookReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		}
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: stopAfter}, nil
 }
 
 // createNotebookCertConfigMap creates a ConfigMap workbench-trusted-ca-bundle
@@ -254,7 +505,7 @@ func (r *OpenshiftNotebookReconciler) CreateNotebookCertConfigMap(notebook *nbv1
 	ctx context.Context) error {
 
 	// Initialize logger format
-	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	log := r.notebookLogger(notebook)
 
 	rootCertPool := [][]byte{}                    // Root certificate pool
 	odhConfigMapName := "odh-trusted-ca-bundle"   // Use ODH Trusted CA Bundle Contains ca-bundle.crt and odh-ca-bundle.crt
@@ -315,7 +566,7 @@ func (r *OpenshiftNotebookReconciler) CreateNotebookCertConfigMap(notebook *nbv1
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "workbench-trusted-ca-bundle",
 				Namespace: notebook.Namespace,
-				Labels:    map[string]string{"opendatahub.io/managed-by": "workbenches"},
+				Labels:    trustedCABundleLabels(),
 			},
 			Data: map[string]string{
 				"ca-bundle.crt": string(bytes.Join(rootCertPool, []byte("\n"))),
@@ -329,22 +580,34 @@ func (r *OpenshiftNotebookReconciler) CreateNotebookCertConfigMap(notebook *nbv1
 		}, foundTrustedCAConfigMap)
 		if err != nil {
 			if apierrs.IsNotFound(err) {
-				r.Log.Info("Creating workbench-trusted-ca-bundle configmap", "namespace", notebook.Namespace, "notebook", notebook.Name)
+				log.Info("Creating workbench-trusted-ca-bundle configmap")
 				err = r.Create(ctx, desiredTrustedCAConfigMap)
 				if err != nil && !apierrs.IsAlreadyExists(err) {
-					r.Log.Error(err, "Unable to create the workbench-trusted-ca-bundle ConfigMap")
+					log.Error(err, "Unable to create the workbench-trusted-ca-bundle ConfigMap")
 					return err
 				} else {
-					r.Log.Info("Created workbench-trusted-ca-bundle ConfigMap", "namespace", notebook.Namespace, "notebook", notebook.Name)
+					log.Info("Created workbench-trusted-ca-bundle ConfigMap")
 				}
 			}
-		} else if err == nil && !reflect.DeepEqual(foundTrustedCAConfigMap.Data, desiredTrustedCAConfigMap.Data) {
+		} else if err == nil && (!reflect.DeepEqual(foundTrustedCAConfigMap.Data, desiredTrustedCAConfigMap.Data) ||
+			len(foundTrustedCAConfigMap.BinaryData) > 0) {
 			// some data has changed, update the ConfigMap
-			r.Log.Info("Updating workbench-trusted-ca-bundle ConfigMap", "namespace", notebook.Namespace, "notebook", notebook.Name)
+			log.Info("Updating workbench-trusted-ca-bundle ConfigMap")
+			r.reportTrustedCABundleTampering(foundTrustedCAConfigMap)
+			mergeLabels(foundTrustedCAConfigMap, desiredTrustedCAConfigMap.Labels)
 			foundTrustedCAConfigMap.Data = desiredTrustedCAConfigMap.Data
+			foundTrustedCAConfigMap.BinaryData = nil
+			err = r.Update(ctx, foundTrustedCAConfigMap)
+			if err != nil {
+				log.Error(err, "Unable to update the workbench-trusted-ca-bundle ConfigMap")
+				return err
+			}
+		} else if err == nil && mergeLabels(foundTrustedCAConfigMap, desiredTrustedCAConfigMap.Labels) {
+			// Created before it was labeled, it is cached once labeled
+			log.Info("Labeling workbench-trusted-ca-bundle ConfigMap")
 			err = r.Update(ctx, foundTrustedCAConfigMap)
 			if err != nil {
-				r.Log.Error(err, "Unable to update the workbench-trusted-ca-bundle ConfigMap")
+				log.Error(err, "Unable to label the workbench-trusted-ca-bundle ConfigMap")
 				return err
 			}
 		}
@@ -357,7 +620,7 @@ func (r *OpenshiftNotebookReconciler) CreateNotebookCertConfigMap(notebook *nbv1
 func (r *OpenshiftNotebookReconciler) IsConfigMapDeleted(notebook *nbv1.Notebook, ctx context.Context) bool {
 
 	// Initialize logger format
-	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	log := r.notebookLogger(notebook)
 
 	var workbenchConfigMapExists bool
 	workbenchConfigMapExists = false
@@ -386,7 +649,7 @@ func (r *OpenshiftNotebookReconciler) IsConfigMapDeleted(notebook *nbv1.Notebook
 func (r *OpenshiftNotebookReconciler) UnsetNotebookCertConfig(notebook *nbv1.Notebook, ctx context.Context) error {
 
 	// Initialize logger format
-	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	log := r.notebookLogger(notebook)
 
 	// Get the notebook object
 	envVars := []string{"PIP_CERT", "REQUESTS_CA_BUNDLE", "SSL_CERT_FILE", "PIPELINES_SSL_SA_CERTS", "GIT_SSL_CAINFO"}
@@ -401,7 +664,7 @@ func (r *OpenshiftNotebookReconciler) UnsetNotebookCertConfig(notebook *nbv1.Not
 	// Unset the env variables in the notebook
 	for _, container := range *notebookContainers {
 		// Update notebook image container with env Variables
-		if container.Name == notebook.Name {
+		if container.Name == PrimaryContainerName(notebook) {
 			imgContainer = container
 			for _, key := range envVars {
 				for index, env := range imgContainer.Env {
@@ -418,7 +681,7 @@ func (r *OpenshiftNotebookReconciler) UnsetNotebookCertConfig(notebook *nbv1.Not
 			}
 			// Update container with Env and Volume Mount Changes
 			for index, container := range *notebookContainers {
-				if container.Name == notebook.Name {
+				if container.Name == PrimaryContainerName(notebook) {
 					(*notebookContainers)[index] = imgContainer
 					notebookSpecChanged = true
 					break
@@ -453,79 +716,103 @@ func (r *OpenshiftNotebookReconciler) UnsetNotebookCertConfig(notebook *nbv1.Not
 // SetupWithManager sets up the controller with the Manager.
 func (r *OpenshiftNotebookReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	builder := ctrl.NewControllerManagedBy(mgr).
-		For(&nbv1.Notebook{}).
-		Owns(&routev1.Route{}).
+		For(&nbv1.Notebook{})
+	if !r.FakeOpenShiftAPIs {
+		builder = builder.Owns(&routev1.Route{})
+	}
+	builder = builder.
 		Owns(&corev1.ServiceAccount{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.Secret{}).
 		Owns(&netv1.NetworkPolicy{}).
-		Owns(&rbacv1.RoleBinding{}).
-
-		// Watch for all the required ConfigMaps
-		// odh-trusted-ca-bundle, kube-root-ca.crt, workbench-trusted-ca-bundle
-		// and reconcile the workbench-trusted-ca-bundle ConfigMap,
-		Watches(&corev1.ConfigMap{},
-			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, o client.Object) []reconcile.Request {
-				log := r.Log.WithValues("namespace", o.GetNamespace(), "name", o.GetName())
-
-				// If the ConfigMap is odh-trusted-ca-bundle or kube-root-ca.crt
-				// trigger a reconcile event for first notebook in the namespace
-				if o.GetName() == "odh-trusted-ca-bundle" {
-					// List all the notebooks in the namespace and trigger a reconcile event
-					var nbList nbv1.NotebookList
-					if err := r.List(ctx, &nbList, client.InNamespace(o.GetNamespace())); err != nil {
-						log.Error(err, "Unable to list Notebooks when attempting to handle Global CA Bundle event.")
-						return []reconcile.Request{}
-					}
+		Owns(&rbacv1.RoleBinding{})
+	// Reconcile the changes of the objects of the exposers
+	exposers, err := r.enabledExposers()
+	if err != nil {
+		return err
+	}
+	for _, exposer := range exposers {
+		if watching, ok := exposer.(WatchingExposer); ok {
+			for _, object := range watching.OwnedObjects() {
+				builder = builder.Owns(object)
+			}
+		}
+	}
+	if r.DashboardConfigKey.Name != "" {
+		// Apply the changes of the dashboard configuration to all the
+		// notebooks
+		dashboardConfig := &unstructured.Unstructured{}
+		dashboardConfig.SetGroupVersionKind(DashboardConfigGVK)
+		builder = builder.Watches(dashboardConfig, handler.EnqueueRequestsFromMapFunc(r.dashboardConfigNotebooks))
+	}
+	// Apply the stop requests of the namespaces to their notebooks
+	builder = builder.Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.namespaceNotebooks))
+	// Restart the notebooks whose spot node is reclaimed
+	builder = builder.Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.spotReclaimedPodNotebook))
+	if r.ReconcileCache != nil {
+		// Revert the drift of the controlled objects of the notebooks
+		builder = builder.WithEventFilter(r.ReconcileCache.InvalidatingPredicate())
+	}
+	err = builder.Complete(r)
+	if err != nil {
+		return err
+	}
 
-					// As there is only one configmap workbench-trusted-ca-bundle per namespace
-					// and is used by all the notebooks in the namespace, we can trigger
-					// reconcile event only for the first notebook in the list.
-					for _, nb := range nbList.Items {
-						return []reconcile.Request{
-							{
-								NamespacedName: types.NamespacedName{
-									Name:      nb.Name,
-									Namespace: o.GetNamespace(),
-								},
-							},
-						}
-					}
-				}
+	// The changes of the odh-trusted-ca-bundle and workbench-trusted-ca-bundle
+	// ConfigMaps are reconciled by namespace, by a dedicated controller
+	return r.setupTrustedCABundleWithManager(mgr)
+}
 
-				// If the ConfigMap is workbench-trusted-ca-bundle
-				// trigger a reconcile event for all the notebooks in the namespace
-				// containing the ConfigMap workbench-trusted-ca-bundle as a volume.
-				if o.GetName() == "workbench-trusted-ca-bundle" {
-					// List all the notebooks in the namespace and trigger a reconcile event
-					var nbList nbv1.NotebookList
-					if err := r.List(ctx, &nbList, client.InNamespace(o.GetNamespace())); err != nil {
-						log.Error(err, "Unable to list Notebook's when attempting to handle Global CA Bundle event.")
-						return []reconcile.Request{}
-					}
+// deleteControlledObject deletes the named object of the notebook namespace,
+// if it exists and is controlled by the notebook.
+func (r *OpenshiftNotebookReconciler) deleteControlledObject(ctx context.Context, notebook *nbv1.Notebook,
+	name string, object client.Object) error {
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: notebook.Namespace}, object)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(object, notebook) {
+		return nil
+	}
+	r.notebookLogger(notebook).Info("Deleting object", "name", name)
+	err = r.Delete(ctx, object)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
 
-					// For all the notebooks that mounted the ConfigMap workbench-trusted-ca-bundle
-					// as a volume, trigger a reconcile event.
-					reconcileRequests := []reconcile.Request{}
-					for _, nb := range nbList.Items {
-						for _, volume := range nb.Spec.Template.Spec.Volumes {
-							if volume.ConfigMap != nil && volume.ConfigMap.Name == o.GetName() {
-								namespacedName := types.NamespacedName{
-									Name:      nb.Name,
-									Namespace: o.GetNamespace(),
-								}
-								reconcileRequests = append(reconcileRequests, reconcile.Request{NamespacedName: namespacedName})
-							}
-						}
-					}
-					return reconcileRequests
-				}
-				return []reconcile.Request{}
-			}),
-		)
-	err := builder.Complete(r)
-	if err != nil {
+// reconcileUnstructured creates the given object of an optional API (e.g. a
+// CRD which may not be installed), controlled by the notebook, and reconciles
+// its spec.
+func (r *OpenshiftNotebookReconciler) reconcileUnstructured(ctx context.Context, notebook *nbv1.Notebook,
+	desired *unstructured.Unstructured) error {
+	log := r.notebookLogger(notebook)
+
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(desired.GroupVersionKind())
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), found)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating "+desired.GetKind(), "name", desired.GetName())
+		err = ctrl.SetControllerReference(notebook, desired, r.Scheme)
+		if err != nil {
+			return err
+		}
+		err = r.Create(ctx, desired)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	} else if err != nil {
 		return err
 	}
+
+	if !reflect.DeepEqual(found.Object["spec"], desired.Object["spec"]) {
+		log.Info("Reconciling "+desired.GetKind(), "name", found.GetName())
+		found.Object["spec"] = desired.Object["spec"]
+		return r.Update(ctx, found)
+	}
 	return nil
 }
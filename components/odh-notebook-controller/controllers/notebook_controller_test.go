@@ -98,6 +98,7 @@ var _ = Describe("The Openshift Notebook controller", func() {
 			time.Sleep(interval)
 
 			By("By checking that the controller has created the Route")
+			expectedRoute.Labels = NotebookObjectLabels(notebook, ComponentRoute)
 			Eventually(func() error {
 				key := types.NamespacedName{Name: Name, Namespace: Namespace}
 				return cli.Get(ctx, key, route)
@@ -493,6 +494,8 @@ var _ = Describe("The Openshift Notebook controller", func() {
 			time.Sleep(interval)
 
 			By("By checking that the controller has created Network policy to allow only controller traffic")
+			expectedNotebookNetworkPolicy.Labels = NotebookObjectLabels(notebook, ComponentNetworkPolicy)
+			expectedNotebookOAuthNetworkPolicy.Labels = NotebookObjectLabels(notebook, ComponentNetworkPolicy)
 			Eventually(func() error {
 				key := types.NamespacedName{Name: Name + "-ctrl-np", Namespace: Namespace}
 				return cli.Get(ctx, key, notebookNetworkPolicy)
@@ -669,6 +672,8 @@ var _ = Describe("The Openshift Notebook controller", func() {
 			time.Sleep(interval)
 
 			By("By checking that the webhook has injected the sidecar container")
+			Expect(notebook.Annotations).To(HaveKey(AnnotationLastAdmissionUID))
+			expectedNotebook.Annotations[AnnotationLastAdmissionUID] = notebook.Annotations[AnnotationLastAdmissionUID]
 			Expect(CompareNotebooks(*notebook, expectedNotebook)).Should(BeTrue())
 		})
 
@@ -698,6 +703,9 @@ var _ = Describe("The Openshift Notebook controller", func() {
 				key := types.NamespacedName{Name: Name, Namespace: Namespace}
 				return cli.Get(ctx, key, notebook)
 			}, duration, interval).Should(Succeed())
+			Expect(notebook.Annotations[AnnotationLastAdmissionUID]).
+				NotTo(Equal(expectedNotebook.Annotations[AnnotationLastAdmissionUID]))
+			expectedNotebook.Annotations[AnnotationLastAdmissionUID] = notebook.Annotations[AnnotationLastAdmissionUID]
 			Expect(CompareNotebooks(*notebook, expectedNotebook)).Should(BeTrue())
 		})
 
@@ -706,6 +714,7 @@ var _ = Describe("The Openshift Notebook controller", func() {
 
 		It("Should create a Service Account for the notebook", func() {
 			By("By checking that the controller has created the Service Account")
+			expectedServiceAccount.Labels = NotebookObjectLabels(notebook, ComponentOAuthProxy)
 			Eventually(func() error {
 				key := types.NamespacedName{Name: Name, Namespace: Namespace}
 				return cli.Get(ctx, key, serviceAccount)
@@ -750,6 +759,7 @@ var _ = Describe("The Openshift Notebook controller", func() {
 
 		It("Should create a Service to expose the OAuth proxy", func() {
 			By("By checking that the controller has created the Service")
+			expectedService.Labels = NotebookObjectLabels(notebook, ComponentOAuthProxy)
 			Eventually(func() error {
 				key := types.NamespacedName{Name: Name + "-tls", Namespace: Namespace}
 				return cli.Get(ctx, key, service)
@@ -826,6 +836,7 @@ var _ = Describe("The Openshift Notebook controller", func() {
 
 		It("Should create a Route to expose the traffic externally", func() {
 			By("By checking that the controller has created the Route")
+			expectedRoute.Labels = NotebookObjectLabels(notebook, ComponentRoute)
 			Eventually(func() error {
 				key := types.NamespacedName{Name: Name, Namespace: Namespace}
 				return cli.Get(ctx, key, route)
@@ -0,0 +1,51 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+)
+
+const (
+	// AnnotationIdleTimeout overrides the idle time, in minutes, after which
+	// the culler stops the notebook.
+	AnnotationIdleTimeout = "notebooks.opendatahub.io/idle-timeout"
+	// AnnotationCullingDisabled prevents the culler from stopping the notebook
+	// when set to "true".
+	AnnotationCullingDisabled = "notebooks.opendatahub.io/culling-disabled"
+)
+
+// ValidateCullingAnnotations checks the culling overrides of the notebook, so
+// that invalid values are rejected instead of silently ignored by the culler.
+func ValidateCullingAnnotations(notebook *nbv1.Notebook) error {
+	annotations := notebook.GetAnnotations()
+	if value, ok := annotations[AnnotationIdleTimeout]; ok {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			return fmt.Errorf("invalid %s annotation %q: expected a positive number of minutes",
+				AnnotationIdleTimeout, value)
+		}
+	}
+	if value, ok := annotations[AnnotationCullingDisabled]; ok {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid %s annotation %q: expected true or false", AnnotationCullingDisabled, value)
+		}
+	}
+	return nil
+}
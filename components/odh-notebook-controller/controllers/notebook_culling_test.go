@@ -0,0 +1,50 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateCullingAnnotations(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		annotations map[string]string
+		invalid     bool
+	}{
+		{name: "no override"},
+		{name: "idle timeout", annotations: map[string]string{AnnotationIdleTimeout: "120"}},
+		{name: "culling disabled", annotations: map[string]string{AnnotationCullingDisabled: "true"}},
+		{name: "duration idle timeout", annotations: map[string]string{AnnotationIdleTimeout: "2h"}, invalid: true},
+		{name: "zero idle timeout", annotations: map[string]string{AnnotationIdleTimeout: "0"}, invalid: true},
+		{name: "invalid culling disabled", annotations: map[string]string{AnnotationCullingDisabled: "yes"},
+			invalid: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Annotations: tt.annotations}}
+			err := ValidateCullingAnnotations(notebook)
+			if tt.invalid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// AnnotationDashboardDefaults records the comma-separated settings of
+	// the dashboard configuration injected in the notebook: the culling
+	// annotations and the toleration=<key> toleration. Only those are
+	// changed or removed when the dashboard configuration changes, the
+	// settings of the users are kept.
+	AnnotationDashboardDefaults = "notebooks.opendatahub.io/dashboard-defaults"
+
+	// dashboardTolerationPrefix prefixes the key of the injected toleration
+	// in the AnnotationDashboardDefaults annotation.
+	dashboardTolerationPrefix = "toleration="
+)
+
+// +kubebuilder:rbac:groups=opendatahub.io,resources=odhdashboardconfigs,verbs=get;list;watch
+
+// DashboardConfigGVK identifies the configuration of the dashboard.
+var DashboardConfigGVK = schema.GroupVersionKind{
+	Group:   "opendatahub.io",
+	Version: "v1alpha",
+	Kind:    "OdhDashboardConfig",
+}
+
+// DashboardConfigsAreServed returns true if the OdhDashboardConfig CRD is
+// installed in the cluster.
+func DashboardConfigsAreServed(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(DashboardConfigGVK.GroupKind(), DashboardConfigGVK.Version)
+	return err == nil
+}
+
+// ParseDashboardConfigKey parses the namespace/name of the dashboard
+// configuration, empty if the dashboard configuration is not consumed.
+func ParseDashboardConfigKey(value string) (types.NamespacedName, error) {
+	if value == "" {
+		return types.NamespacedName{}, nil
+	}
+	namespace, name, found := strings.Cut(value, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return types.NamespacedName{}, fmt.Errorf("invalid dashboard configuration %q, must be namespace/name", value)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// DashboardConfig holds the notebook settings of the dashboard configuration
+// applied by the controller, so that they are not duplicated in the
+// controller configuration.
+type DashboardConfig struct {
+	// CullingEnabled is spec.notebookController.culling.enabled, the
+	// culling of the notebooks is left to the culler configuration if nil.
+	CullingEnabled *bool
+	// IdleTimeoutMinutes is spec.notebookController.culling.
+	// idleTimeoutMinutes, the idle time after which the notebooks are
+	// stopped, the culler configuration applies if 0.
+	IdleTimeoutMinutes int64
+	// TolerationKey is spec.notebookController.notebookTolerationSettings.key
+	// when the toleration settings are enabled, tolerated by the notebook
+	// pods to run on the nodes tainted for the notebooks.
+	TolerationKey string
+}
+
+// ParseDashboardConfig reads the notebook settings of the dashboard
+// configuration.
+func ParseDashboardConfig(obj *unstructured.Unstructured) (DashboardConfig, error) {
+	config := DashboardConfig{}
+	enabled, found, err := unstructured.NestedBool(obj.Object, "spec", "notebookController", "culling", "enabled")
+	if err != nil {
+		return config, err
+	} else if found {
+		config.CullingEnabled = &enabled
+	}
+	config.IdleTimeoutMinutes, _, err = unstructured.NestedInt64(obj.Object,
+		"spec", "notebookController", "culling", "idleTimeoutMinutes")
+	if err != nil {
+		return config, err
+	} else if config.IdleTimeoutMinutes < 0 {
+		return config, fmt.Errorf("invalid idle timeout %d minutes", config.IdleTimeoutMinutes)
+	}
+	tolerations, _, err := unstructured.NestedBool(obj.Object,
+		"spec", "notebookController", "notebookTolerationSettings", "enabled")
+	if err != nil {
+		return config, err
+	}
+	if tolerations {
+		config.TolerationKey, _, err = unstructured.NestedString(obj.Object,
+			"spec", "notebookController", "notebookTolerationSettings", "key")
+		if err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// LoadDashboardConfig reads the dashboard configuration of the given key, the
+// settings are empty if it does not exist.
+func LoadDashboardConfig(ctx context.Context, reader client.Reader, key types.NamespacedName) (DashboardConfig, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(DashboardConfigGVK)
+	err := reader.Get(ctx, key, obj)
+	if apierrs.IsNotFound(err) {
+		return DashboardConfig{}, nil
+	} else if err != nil {
+		return DashboardConfig{}, err
+	}
+	config, err := ParseDashboardConfig(obj)
+	if err != nil {
+		return config, fmt.Errorf("invalid dashboard configuration %s: %w", key, err)
+	}
+	return config, nil
+}
+
+// cullingAnnotations returns the culling annotations of the dashboard
+// configuration.
+func (c DashboardConfig) cullingAnnotations() map[string]string {
+	annotations := map[string]string{}
+	if c.CullingEnabled == nil {
+		return annotations
+	}
+	if !*c.CullingEnabled {
+		annotations[AnnotationCullingDisabled] = "true"
+	} else if c.IdleTimeoutMinutes > 0 {
+		annotations[AnnotationIdleTimeout] = strconv.FormatInt(c.IdleTimeoutMinutes, 10)
+	}
+	return annotations
+}
+
+// dashboardToleration returns the toleration of the nodes tainted for the
+// notebooks with the given key.
+func dashboardToleration(key string) corev1.Toleration {
+	return corev1.Toleration{Key: key, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}
+}
+
+// InjectDashboardDefaults applies the dashboard configuration to the
+// notebook: the culling annotations and the toleration of the notebook nodes.
+// The annotations and tolerations set by the users are kept, the injected
+// ones are recorded in the notebook to be updated with the configuration. The
+// idle timeout of the notebooks with a session keepalive is left to it.
+func InjectDashboardDefaults(notebook *nbv1.Notebook, config DashboardConfig) {
+	previous := map[string]bool{}
+	previousToleration := ""
+	if value := notebook.GetAnnotations()[AnnotationDashboardDefaults]; value != "" {
+		for _, item := range strings.Split(value, ",") {
+			if key, found := strings.CutPrefix(item, dashboardTolerationPrefix); found {
+				previousToleration = key
+			} else {
+				previous[item] = true
+			}
+		}
+	}
+	if notebook.Annotations == nil {
+		notebook.Annotations = map[string]string{}
+	}
+	delete(notebook.Annotations, AnnotationDashboardDefaults)
+	injected := []string{}
+
+	desired := config.cullingAnnotations()
+	for _, key := range []string{AnnotationIdleTimeout, AnnotationCullingDisabled} {
+		_, exists := notebook.Annotations[key]
+		if exists && !previous[key] {
+			// Set by the user
+			continue
+		}
+		if _, keepalive := notebook.Annotations[AnnotationSessionKeepalive]; keepalive && key == AnnotationIdleTimeout {
+			// Set from the session keepalive
+			continue
+		}
+		if value, ok := desired[key]; ok {
+			notebook.Annotations[key] = value
+			injected = append(injected, key)
+		} else {
+			delete(notebook.Annotations, key)
+		}
+	}
+
+	podSpec := &notebook.Spec.Template.Spec
+	if previousToleration != "" && previousToleration != config.TolerationKey {
+		tolerations := []corev1.Toleration{}
+		for _, toleration := range podSpec.Tolerations {
+			if toleration != dashboardToleration(previousToleration) {
+				tolerations = append(tolerations, toleration)
+			}
+		}
+		podSpec.Tolerations = tolerations
+		if len(tolerations) == 0 {
+			podSpec.Tolerations = nil
+		}
+	}
+	if config.TolerationKey != "" {
+		tolerated := false
+		for _, toleration := range podSpec.Tolerations {
+			if toleration.Key == config.TolerationKey {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			podSpec.Tolerations = append(podSpec.Tolerations, dashboardToleration(config.TolerationKey))
+			injected = append(injected, dashboardTolerationPrefix+config.TolerationKey)
+		} else if previousToleration == config.TolerationKey {
+			injected = append(injected, dashboardTolerationPrefix+config.TolerationKey)
+		}
+	}
+
+	if len(injected) > 0 {
+		sort.Strings(injected)
+		notebook.Annotations[AnnotationDashboardDefaults] = strings.Join(injected, ",")
+	}
+}
+
+// ReconcileDashboardDefaults updates the dashboard settings injected in the
+// notebook when the dashboard configuration changes. Only the annotations are
+// patched, the webhook injects the toleration along with them, deferred to the
+// next restart of the running notebooks.
+func (r *OpenshiftNotebookReconciler) ReconcileDashboardDefaults(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if r.DashboardConfigKey.Name == "" {
+		return nil
+	}
+	config, err := LoadDashboardConfig(ctx, r.Client, r.DashboardConfigKey)
+	if err != nil {
+		log.Error(err, "Unable to read the dashboard configuration")
+		return err
+	}
+	desired := notebook.DeepCopy()
+	InjectDashboardDefaults(desired, config)
+
+	annotations := map[string]interface{}{}
+	for _, key := range []string{AnnotationIdleTimeout, AnnotationCullingDisabled, AnnotationDashboardDefaults} {
+		value, found := desired.Annotations[key]
+		current, exists := notebook.GetAnnotations()[key]
+		switch {
+		case found && (!exists || current != value):
+			annotations[key] = value
+		case !found && exists:
+			annotations[key] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Applying the dashboard configuration", "annotations", annotations)
+	err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+	if err != nil {
+		log.Error(err, "Unable to apply the dashboard configuration")
+		return err
+	}
+	return nil
+}
+
+// dashboardConfigNotebooks maps the changes of the dashboard configuration to
+// all the notebooks.
+func (r *OpenshiftNotebookReconciler) dashboardConfigNotebooks(ctx context.Context,
+	obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != r.DashboardConfigKey.Namespace || obj.GetName() != r.DashboardConfigKey.Name {
+		return nil
+	}
+	notebookList := &nbv1.NotebookList{}
+	if err := r.List(ctx, notebookList); err != nil {
+		r.Log.Error(err, "Unable to list the notebooks to apply the dashboard configuration")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(notebookList.Items))
+	for _, notebook := range notebookList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&notebook)})
+	}
+	return requests
+}
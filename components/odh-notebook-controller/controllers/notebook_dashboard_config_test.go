@@ -0,0 +1,108 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newDashboardConfig(t *testing.T, notebookController map[string]interface{}) *unstructured.Unstructured {
+	config := &unstructured.Unstructured{}
+	config.SetGroupVersionKind(DashboardConfigGVK)
+	config.SetNamespace("opendatahub")
+	config.SetName("odh-dashboard-config")
+	require.NoError(t, unstructured.SetNestedMap(config.Object, notebookController, "spec", "notebookController"))
+	return config
+}
+
+func TestParseDashboardConfigKey(t *testing.T) {
+	key, err := ParseDashboardConfigKey("opendatahub/odh-dashboard-config")
+	require.NoError(t, err)
+	assert.Equal(t, types.NamespacedName{Namespace: "opendatahub", Name: "odh-dashboard-config"}, key)
+	key, err = ParseDashboardConfigKey("")
+	require.NoError(t, err)
+	assert.Empty(t, key.Name)
+	_, err = ParseDashboardConfigKey("odh-dashboard-config")
+	assert.Error(t, err)
+}
+
+func TestInjectDashboardDefaults(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+	config := DashboardConfig{CullingEnabled: pointer.Bool(true), IdleTimeoutMinutes: 60, TolerationKey: "NotebooksOnly"}
+
+	InjectDashboardDefaults(notebook, config)
+	assert.Equal(t, "60", notebook.Annotations[AnnotationIdleTimeout])
+	assert.Equal(t, "notebooks.opendatahub.io/idle-timeout,toleration=NotebooksOnly",
+		notebook.Annotations[AnnotationDashboardDefaults])
+	assert.Equal(t, []corev1.Toleration{dashboardToleration("NotebooksOnly")},
+		notebook.Spec.Template.Spec.Tolerations)
+
+	// The injected settings follow the configuration
+	config = DashboardConfig{CullingEnabled: pointer.Bool(false), TolerationKey: "Workbenches"}
+	InjectDashboardDefaults(notebook, config)
+	assert.NotContains(t, notebook.Annotations, AnnotationIdleTimeout)
+	assert.Equal(t, "true", notebook.Annotations[AnnotationCullingDisabled])
+	assert.Equal(t, []corev1.Toleration{dashboardToleration("Workbenches")},
+		notebook.Spec.Template.Spec.Tolerations)
+
+	// The settings of the users are kept
+	notebook.Annotations[AnnotationCullingDisabled] = "false"
+	delete(notebook.Annotations, AnnotationDashboardDefaults)
+	notebook.Spec.Template.Spec.Tolerations = nil
+	InjectDashboardDefaults(notebook, DashboardConfig{CullingEnabled: pointer.Bool(false)})
+	InjectDashboardDefaults(notebook, DashboardConfig{})
+	assert.Equal(t, "false", notebook.Annotations[AnnotationCullingDisabled])
+	assert.NotContains(t, notebook.Annotations, AnnotationDashboardDefaults)
+	assert.Empty(t, notebook.Spec.Template.Spec.Tolerations)
+}
+
+func TestReconcileDashboardDefaults(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	dashboardConfig := newDashboardConfig(t, map[string]interface{}{
+		"culling":                    map[string]interface{}{"enabled": true, "idleTimeoutMinutes": int64(120)},
+		"notebookTolerationSettings": map[string]interface{}{"enabled": true, "key": "NotebooksOnly"},
+	})
+	r := newTestReconciler(t, OAuthConfig{}, notebook, dashboardConfig)
+	r.DashboardConfigKey = client.ObjectKeyFromObject(dashboardConfig)
+
+	config, err := LoadDashboardConfig(ctx, r.Client, r.DashboardConfigKey)
+	require.NoError(t, err)
+	assert.Equal(t, DashboardConfig{CullingEnabled: pointer.Bool(true), IdleTimeoutMinutes: 120,
+		TolerationKey: "NotebooksOnly"}, config)
+
+	// Only the annotations are patched, the webhook injects the toleration
+	require.NoError(t, r.ReconcileDashboardDefaults(notebook, ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	assert.Equal(t, "120", notebook.Annotations[AnnotationIdleTimeout])
+	assert.Equal(t, "notebooks.opendatahub.io/idle-timeout,toleration=NotebooksOnly",
+		notebook.Annotations[AnnotationDashboardDefaults])
+	assert.Empty(t, notebook.Spec.Template.Spec.Tolerations)
+
+	// The changes of the dashboard configuration reconcile all the notebooks
+	assert.Len(t, r.dashboardConfigNotebooks(ctx, dashboardConfig), 1)
+}
@@ -0,0 +1,124 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// AnnotationNotebookDefaults holds the namespace overrides of the default
+// notebook metadata, as a JSON MetadataDefaults object. An empty value
+// disables the cluster default of the same key in the namespace.
+const AnnotationNotebookDefaults = "notebooks.opendatahub.io/notebook-defaults"
+
+// MetadataDefaults holds the annotations and labels set on the new notebooks
+// which do not specify them, e.g. to inject the OAuth proxy by default.
+type MetadataDefaults struct {
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// ParseMetadataDefaults parses and checks the JSON metadata defaults.
+func ParseMetadataDefaults(defaults string) (MetadataDefaults, error) {
+	parsed := MetadataDefaults{}
+	if strings.TrimSpace(defaults) == "" {
+		return parsed, nil
+	}
+	if err := json.Unmarshal([]byte(defaults), &parsed); err != nil {
+		return parsed, fmt.Errorf("invalid notebook defaults: %w", err)
+	}
+	for key := range parsed.Annotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return parsed, fmt.Errorf("invalid default annotation %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	for key, value := range parsed.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return parsed, fmt.Errorf("invalid default label %q: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return parsed, fmt.Errorf("invalid value %q of the default label %s: %s", value, key,
+				strings.Join(errs, ", "))
+		}
+	}
+	return parsed, nil
+}
+
+// ForNamespace returns the defaults overridden by the ones of the namespace.
+func (d MetadataDefaults) ForNamespace(namespace *corev1.Namespace) (MetadataDefaults, error) {
+	value, ok := namespace.GetAnnotations()[AnnotationNotebookDefaults]
+	if !ok {
+		return d, nil
+	}
+	overrides, err := ParseMetadataDefaults(value)
+	if err != nil {
+		return d, fmt.Errorf("invalid %s annotation of the namespace %s: %w", AnnotationNotebookDefaults,
+			namespace.Name, err)
+	}
+	merge := func(defaults, overrides map[string]string) map[string]string {
+		merged := map[string]string{}
+		for key, value := range defaults {
+			merged[key] = value
+		}
+		for key, value := range overrides {
+			if value == "" {
+				delete(merged, key)
+			} else {
+				merged[key] = value
+			}
+		}
+		return merged
+	}
+	return MetadataDefaults{
+		Annotations: merge(d.Annotations, overrides.Annotations),
+		Labels:      merge(d.Labels, overrides.Labels),
+	}, nil
+}
+
+// ApplyMetadataDefaults sets the default annotations and labels the notebook
+// does not specify, and returns the keys of the applied ones.
+func ApplyMetadataDefaults(notebook *nbv1.Notebook, defaults MetadataDefaults) []string {
+	applied := []string{}
+	for key, value := range defaults.Annotations {
+		if _, ok := notebook.Annotations[key]; ok {
+			continue
+		}
+		if notebook.Annotations == nil {
+			notebook.Annotations = map[string]string{}
+		}
+		notebook.Annotations[key] = value
+		applied = append(applied, key)
+	}
+	for key, value := range defaults.Labels {
+		if _, ok := notebook.Labels[key]; ok {
+			continue
+		}
+		if notebook.Labels == nil {
+			notebook.Labels = map[string]string{}
+		}
+		notebook.Labels[key] = value
+		applied = append(applied, key)
+	}
+	sort.Strings(applied)
+	return applied
+}
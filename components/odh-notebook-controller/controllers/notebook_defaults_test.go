@@ -0,0 +1,71 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseMetadataDefaults(t *testing.T) {
+	defaults, err := ParseMetadataDefaults("")
+	require.NoError(t, err)
+	assert.Empty(t, defaults.Annotations)
+
+	defaults, err = ParseMetadataDefaults(`{"annotations":{"` + AnnotationInjectOAuth + `":"true"},` +
+		`"labels":{"cost-center":"ds"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{AnnotationInjectOAuth: "true"}, defaults.Annotations)
+	assert.Equal(t, map[string]string{"cost-center": "ds"}, defaults.Labels)
+
+	_, err = ParseMetadataDefaults(`{"labels":{"cost-center":"data science"}}`)
+	assert.ErrorContains(t, err, "cost-center")
+
+	_, err = ParseMetadataDefaults(`{"annotations":{"in valid":"true"}}`)
+	assert.Error(t, err)
+}
+
+func TestApplyMetadataDefaults(t *testing.T) {
+	defaults := MetadataDefaults{
+		Annotations: map[string]string{AnnotationInjectOAuth: "true", AnnotationIdleTimeout: "120"},
+		Labels:      map[string]string{"cost-center": "ds", "team": "platform"},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: map[string]string{
+		AnnotationNotebookDefaults: `{"annotations":{"` + AnnotationIdleTimeout + `":""},"labels":{"team":"research"}}`,
+	}}}
+
+	namespaceDefaults, err := defaults.ForNamespace(namespace)
+	require.NoError(t, err)
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name:        "nb",
+		Annotations: map[string]string{AnnotationInjectOAuth: "false"},
+	}}
+	applied := ApplyMetadataDefaults(notebook, namespaceDefaults)
+	assert.Equal(t, []string{"cost-center", "team"}, applied)
+	assert.Equal(t, map[string]string{AnnotationInjectOAuth: "false"}, notebook.Annotations)
+	assert.Equal(t, map[string]string{"cost-center": "ds", "team": "research"}, notebook.Labels)
+
+	// The invalid overrides of the namespace are ignored
+	namespace.Annotations[AnnotationNotebookDefaults] = "{"
+	namespaceDefaults, err = defaults.ForNamespace(namespace)
+	assert.Error(t, err)
+	assert.Equal(t, defaults, namespaceDefaults)
+}
@@ -0,0 +1,225 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ConditionEnvironmentDrift reports the differences between the running
+	// pod of the notebook and the pod the webhook would produce today.
+	ConditionEnvironmentDrift = "notebooks.opendatahub.io/EnvironmentDrift"
+
+	// maxDriftMessagePaths caps the number of changed fields listed in the
+	// message of the drift condition.
+	maxDriftMessagePaths = 10
+)
+
+// NotebookDrift returns the changes between the pod template the webhook
+// produces today (desired) and the running notebook, including the container
+// images of its pod not matching the notebook yet. The pod may be nil.
+func NotebookDrift(desired, notebook *nbv1.Notebook, pod *corev1.Pod) []PendingChange {
+	var reporter PendingChangesReporter
+	cmp.Equal(desired.Spec.Template.Spec, notebook.Spec.Template.Spec, cmp.Reporter(&reporter))
+	changes := reporter.Changes()
+	if pod == nil {
+		return changes
+	}
+	images := map[string]string{}
+	for _, container := range pod.Spec.Containers {
+		images[container.Name] = container.Image
+	}
+	for i, container := range notebook.Spec.Template.Spec.Containers {
+		if image, ok := images[container.Name]; ok && image != container.Image {
+			changes = append(changes, PendingChange{
+				Path: fmt.Sprintf("containers[%d].image", i),
+				Type: ChangeModified,
+				From: image,
+				To:   container.Image,
+			})
+		}
+	}
+	return changes
+}
+
+// NewEnvironmentDriftCondition returns the drift condition reporting the
+// changes, nil if there is none.
+func NewEnvironmentDriftCondition(changes []PendingChange) *nbv1.NotebookCondition {
+	if len(changes) == 0 {
+		return nil
+	}
+	paths := []string{}
+	for _, change := range changes {
+		if len(paths) == maxDriftMessagePaths {
+			paths = append(paths, "...")
+			break
+		}
+		paths = append(paths, change.Path)
+	}
+	return &nbv1.NotebookCondition{
+		Type:   ConditionEnvironmentDrift,
+		Status: string(corev1.ConditionTrue),
+		Reason: "RestartRequired",
+		Message: fmt.Sprintf("%d changes of the notebook pod are applied on its next restart: %s.", len(changes),
+			strings.Join(paths, ", ")),
+		LastProbeTime:      metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+	}
+}
+
+// DriftReporter periodically compares the running notebooks with the pod the
+// webhook would produce today, after e.g. a policy or image change, and
+// reports the notebooks needing a restart to be compliant.
+type DriftReporter struct {
+	client.Client
+	Log logr.Logger
+	// Interval is the interval between two drift reports.
+	Interval time.Duration
+}
+
+// Start reports the drift until the context is cancelled.
+func (d *DriftReporter) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := d.Report(ctx); err != nil {
+			d.Log.Error(err, "Unable to report the environment drift of the notebooks")
+		}
+	}, d.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes the drift reported by the leader only.
+func (d *DriftReporter) NeedLeaderElection() bool {
+	return true
+}
+
+// Report updates the drift condition of the running notebooks and the number
+// of drifted notebooks of each namespace.
+func (d *DriftReporter) Report(ctx context.Context) error {
+	notebookList := &nbv1.NotebookList{}
+	if err := d.List(ctx, notebookList); err != nil {
+		return err
+	}
+	drifted := map[string]int{}
+	for i := range notebookList.Items {
+		notebook := &notebookList.Items[i]
+		changes := []PendingChange{}
+		if !notebookIsStopped(notebook.ObjectMeta) {
+			var err error
+			changes, err = d.notebookDrift(ctx, notebook)
+			if err != nil {
+				d.Log.Error(err, "Unable to compute the environment drift of the notebook",
+					"notebook", notebook.Name, "namespace", notebook.Namespace)
+				continue
+			}
+		}
+		if len(changes) > 0 {
+			drifted[notebook.Namespace]++
+		}
+		if err := d.updateCondition(ctx, notebook, NewEnvironmentDriftCondition(changes)); err != nil {
+			return err
+		}
+	}
+
+	notebookEnvironmentDrift.Reset()
+	for namespace, count := range drifted {
+		notebookEnvironmentDrift.WithLabelValues(namespace).Set(float64(count))
+	}
+	return nil
+}
+
+// desiredNotebook returns the notebook the webhook produces today, obtained by
+// a dry-run update of the notebook requesting its restart so that the webhook
+// does not hold back the changes.
+func desiredNotebook(ctx context.Context, c client.Client, notebook *nbv1.Notebook) (*nbv1.Notebook, error) {
+	desired := notebook.DeepCopy()
+	if desired.Annotations == nil {
+		desired.Annotations = map[string]string{}
+	}
+	desired.Annotations[AnnotationNotebookRestart] = "true"
+	if err := c.Update(ctx, desired, client.DryRunAll); err != nil {
+		return nil, err
+	}
+	return desired, nil
+}
+
+// notebookDrift returns the drift of the running notebook.
+func (d *DriftReporter) notebookDrift(ctx context.Context, notebook *nbv1.Notebook) ([]PendingChange, error) {
+	desired, err := desiredNotebook(ctx, d.Client, notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	var running *corev1.Pod
+	pod := &corev1.Pod{}
+	err = d.Get(ctx, types.NamespacedName{Name: notebook.Name + "-0", Namespace: notebook.Namespace}, pod)
+	if err == nil {
+		running = pod
+	} else if !apierrs.IsNotFound(err) {
+		return nil, err
+	}
+	return NotebookDrift(desired, notebook, running), nil
+}
+
+// updateCondition sets or removes the drift condition of the notebook,
+// leaving the notebook untouched if the drift did not change.
+func (d *DriftReporter) updateCondition(ctx context.Context, notebook *nbv1.Notebook,
+	condition *nbv1.NotebookCondition) error {
+	var found *nbv1.NotebookCondition
+	for i := range notebook.Status.Conditions {
+		if notebook.Status.Conditions[i].Type == ConditionEnvironmentDrift {
+			found = &notebook.Status.Conditions[i]
+		}
+	}
+	if found == nil && condition == nil || found != nil && condition != nil && found.Message == condition.Message {
+		return nil
+	}
+	if condition != nil {
+		d.Log.Info("Notebook pod drifted from the webhook configuration", "notebook", notebook.Name,
+			"namespace", notebook.Namespace, "message", condition.Message)
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := d.Get(ctx, client.ObjectKeyFromObject(notebook), notebook); err != nil {
+			return err
+		}
+		conditions := []nbv1.NotebookCondition{}
+		for _, existing := range notebook.Status.Conditions {
+			if existing.Type != ConditionEnvironmentDrift {
+				conditions = append(conditions, existing)
+			}
+		}
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+		notebook.Status.Conditions = conditions
+		return d.Status().Update(ctx, notebook)
+	})
+}
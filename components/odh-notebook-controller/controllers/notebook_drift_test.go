@@ -0,0 +1,83 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNotebookDrift(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	notebook.Spec.Template.Spec.Containers = []corev1.Container{{Name: "nb", Image: "workbench:2024.1"}}
+	desired := notebook.DeepCopy()
+	desired.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "PIP_CERT", Value: "/etc/pki/ca.pem"}}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "nb", Image: "workbench:2023.2"}}}}
+
+	assert.Empty(t, NotebookDrift(notebook, notebook, nil))
+	changes := NotebookDrift(desired, notebook, pod)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "containers[0].env", changes[0].Path)
+	assert.Equal(t, PendingChange{Path: "containers[0].image", Type: ChangeModified,
+		From: "workbench:2023.2", To: "workbench:2024.1"}, changes[1])
+
+	condition := NewEnvironmentDriftCondition(changes)
+	require.NotNil(t, condition)
+	assert.Equal(t, "2 changes of the notebook pod are applied on its next restart: "+
+		"containers[0].env, containers[0].image.", condition.Message)
+	assert.Nil(t, NewEnvironmentDriftCondition(nil))
+}
+
+func TestDriftReporter(t *testing.T) {
+	ctx := context.Background()
+	running := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "ns-drift"}}
+	running.Spec.Template.Spec.Containers = []corev1.Container{{Name: "running", Image: "workbench:2024.1"}}
+	stopped := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "stopped", Namespace: "ns-drift",
+		Annotations: map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"}}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running-0", Namespace: "ns-drift"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "running", Image: "workbench:2023.2"}}},
+	}
+	cl := fake.NewClientBuilder().WithScheme(newTestReconciler(t, OAuthConfig{}).Scheme).
+		WithObjects(running, stopped, pod).WithStatusSubresource(&nbv1.Notebook{}).Build()
+	reporter := &DriftReporter{Client: cl, Log: logr.Discard()}
+
+	require.NoError(t, reporter.Report(ctx))
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(running), running))
+	require.Len(t, running.Status.Conditions, 1)
+	assert.Equal(t, ConditionEnvironmentDrift, running.Status.Conditions[0].Type)
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(stopped), stopped))
+	assert.Empty(t, stopped.Status.Conditions)
+	assert.NotContains(t, running.Annotations, AnnotationNotebookRestart)
+	assert.Equal(t, 1.0, metricValue(t, notebookEnvironmentDrift.WithLabelValues("ns-drift")).GetGauge().GetValue())
+
+	// The condition is removed once the pod runs the notebook image
+	pod.Spec.Containers[0].Image = "workbench:2024.1"
+	require.NoError(t, cl.Update(ctx, pod))
+	require.NoError(t, reporter.Report(ctx))
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(running), running))
+	assert.Empty(t, running.Status.Conditions)
+}
@@ -0,0 +1,194 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EffectiveConfigConfigMapSuffix is the suffix of the name of the ConfigMap
+// publishing the effective configuration of a notebook.
+const EffectiveConfigConfigMapSuffix = "-effective-config"
+
+// Keys of the effective configuration ConfigMaps.
+const (
+	EffectiveConfigKeyAuthProvider   = "authProvider"
+	EffectiveConfigKeyProxyImage     = "proxyImage"
+	EffectiveConfigKeyProxyArgs      = "proxyArgs"
+	EffectiveConfigKeyCABundle       = "caBundle"
+	EffectiveConfigKeyEnv            = "env"
+	EffectiveConfigKeyServiceAccount = "serviceAccount"
+	EffectiveConfigKeyURL            = "url"
+)
+
+// EffectiveConfigConfigMapName returns the name of the ConfigMap publishing
+// the effective configuration of the notebook.
+func EffectiveConfigConfigMapName(notebook *nbv1.Notebook) string {
+	return notebook.Name + EffectiveConfigConfigMapSuffix
+}
+
+// NewEffectiveConfigConfigMap returns the ConfigMap summarizing what the
+// webhook and the controller injected in the notebook, read from its pod
+// template: the auth provider and the arguments of its proxy, the mounted CA
+// bundle, the environment variables of the notebook container and the URL
+// the notebook is exposed at, empty until its Route is admitted. The secret
+// arguments of the proxy are redacted and only the names and the sources of
+// the environment variables are listed, never their values.
+func NewEffectiveConfigConfigMap(notebook *nbv1.Notebook, defaultAuthProvider, url string) *corev1.ConfigMap {
+	podSpec := notebook.Spec.Template.Spec
+	data := map[string]string{
+		EffectiveConfigKeyAuthProvider:   NotebookAuthProvider(notebook.ObjectMeta, defaultAuthProvider),
+		EffectiveConfigKeyServiceAccount: podSpec.ServiceAccountName,
+		EffectiveConfigKeyURL:            url,
+	}
+
+	for _, container := range append(append([]corev1.Container{}, podSpec.InitContainers...),
+		podSpec.Containers...) {
+		if container.Name != OAuthProxyContainerName {
+			continue
+		}
+		args := make([]string, 0, len(container.Args))
+		for _, arg := range container.Args {
+			args = append(args, RedactString(arg))
+		}
+		data[EffectiveConfigKeyProxyImage] = container.Image
+		data[EffectiveConfigKeyProxyArgs] = strings.Join(args, "\n")
+	}
+
+	if container := notebookContainer(notebook); container != nil {
+		for _, volume := range podSpec.Volumes {
+			if volume.Name != "trusted-ca" || volume.ConfigMap == nil {
+				continue
+			}
+			for _, mount := range container.VolumeMounts {
+				if mount.Name == volume.Name {
+					data[EffectiveConfigKeyCABundle] = fmt.Sprintf("%s mounted at %s", volume.ConfigMap.Name,
+						mount.MountPath)
+				}
+			}
+		}
+		env := make([]string, 0, len(container.Env))
+		for _, envVar := range container.Env {
+			env = append(env, envVar.Name+envVarSource(envVar))
+		}
+		data[EffectiveConfigKeyEnv] = strings.Join(env, "\n")
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EffectiveConfigConfigMapName(notebook),
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentEffectiveConfig),
+		},
+		Data: data,
+	}
+}
+
+// envVarSource describes where the value of the environment variable comes
+// from, empty for the literal values.
+func envVarSource(envVar corev1.EnvVar) string {
+	source := envVar.ValueFrom
+	switch {
+	case source == nil:
+		return ""
+	case source.SecretKeyRef != nil:
+		return fmt.Sprintf(" (from the %s key of the Secret %s)", source.SecretKeyRef.Key, source.SecretKeyRef.Name)
+	case source.ConfigMapKeyRef != nil:
+		return fmt.Sprintf(" (from the %s key of the ConfigMap %s)", source.ConfigMapKeyRef.Key,
+			source.ConfigMapKeyRef.Name)
+	case source.FieldRef != nil:
+		return fmt.Sprintf(" (from the field %s)", source.FieldRef.FieldPath)
+	case source.ResourceFieldRef != nil:
+		return fmt.Sprintf(" (from the resource %s)", source.ResourceFieldRef.Resource)
+	}
+	return ""
+}
+
+// ReconcileEffectiveConfig publishes the effective configuration of the
+// notebook in its ConfigMap. The ConfigMap is read-only: the changes of the
+// users are overwritten.
+func (r *OpenshiftNotebookReconciler) ReconcileEffectiveConfig(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	name := EffectiveConfigConfigMapName(notebook)
+	if !r.EffectiveConfigEnabled {
+		return r.deleteControlledObject(ctx, notebook, name, &corev1.ConfigMap{})
+	}
+
+	url := ""
+	route := &routev1.Route{}
+	err := r.Get(ctx, types.NamespacedName{Name: notebook.Name, Namespace: notebook.Namespace}, route)
+	if err == nil {
+		for _, ingress := range route.Status.Ingress {
+			if ingress.Host != "" {
+				url = "https://" + ingress.Host
+				break
+			}
+		}
+	} else if !apierrs.IsNotFound(err) {
+		return err
+	}
+
+	desired := NewEffectiveConfigConfigMap(notebook, r.AuthConfig.DefaultProvider, url)
+	found := &corev1.ConfigMap{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(desired), found)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating the effective configuration ConfigMap", "name", name)
+		err = ctrl.SetControllerReference(notebook, desired, r.Scheme)
+		if err != nil {
+			return err
+		}
+		err = r.Create(ctx, desired)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the effective configuration ConfigMap")
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(found, notebook) {
+		return NewUserActionableError("ConfigMapConflict",
+			fmt.Errorf("the ConfigMap %s is not controlled by the notebook", name))
+	}
+
+	if reflect.DeepEqual(found.Data, desired.Data) && len(found.BinaryData) == 0 &&
+		!mergeLabels(found, desired.Labels) {
+		return nil
+	}
+	log.V(1).Info("Updating the effective configuration ConfigMap", "name", name)
+	found.Data = desired.Data
+	found.BinaryData = nil
+	err = r.Update(ctx, found)
+	if err != nil {
+		log.Error(err, "Unable to update the effective configuration ConfigMap")
+		return err
+	}
+	return nil
+}
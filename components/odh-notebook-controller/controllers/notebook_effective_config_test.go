@@ -0,0 +1,110 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newEffectiveConfigNotebook() *nbv1.Notebook {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationInjectOAuth: "true"}}}
+	notebook.Spec.Template.Spec = corev1.PodSpec{
+		ServiceAccountName: "nb",
+		Containers: []corev1.Container{{
+			Name: "nb",
+			Env: []corev1.EnvVar{
+				{Name: "JUPYTER_TOKEN", Value: "t0k3n"},
+				{Name: "AWS_SECRET_ACCESS_KEY", ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "aws-connection"},
+						Key:                  "AWS_SECRET_ACCESS_KEY",
+					}}},
+			},
+			VolumeMounts: []corev1.VolumeMount{{Name: "trusted-ca",
+				MountPath: "/etc/pki/tls/custom-certs/ca-bundle.crt"}},
+		}, {
+			Name:  OAuthProxyContainerName,
+			Image: "oauth-proxy:latest",
+			Args:  []string{"--https-address=:8443", "--cookie-secret=s3cr3t"},
+		}},
+		Volumes: []corev1.Volume{{Name: "trusted-ca", VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: TrustedCABundleConfigMapName},
+			}}}},
+	}
+	return notebook
+}
+
+func TestNewEffectiveConfigConfigMap(t *testing.T) {
+	configMap := NewEffectiveConfigConfigMap(newEffectiveConfigNotebook(), AuthProviderOpenShiftOAuth,
+		"https://nb.apps.example.com")
+
+	assert.Equal(t, "nb-effective-config", configMap.Name)
+	assert.Equal(t, ComponentEffectiveConfig, configMap.Labels[LabelComponent])
+	assert.Equal(t, AuthProviderOpenShiftOAuth, configMap.Data[EffectiveConfigKeyAuthProvider])
+	assert.Equal(t, "oauth-proxy:latest", configMap.Data[EffectiveConfigKeyProxyImage])
+	assert.Equal(t, "--https-address=:8443\n--cookie-secret="+RedactedValue,
+		configMap.Data[EffectiveConfigKeyProxyArgs])
+	assert.Equal(t, TrustedCABundleConfigMapName+" mounted at /etc/pki/tls/custom-certs/ca-bundle.crt",
+		configMap.Data[EffectiveConfigKeyCABundle])
+	assert.Equal(t, "JUPYTER_TOKEN\n"+
+		"AWS_SECRET_ACCESS_KEY (from the AWS_SECRET_ACCESS_KEY key of the Secret aws-connection)",
+		configMap.Data[EffectiveConfigKeyEnv])
+	assert.Equal(t, "nb", configMap.Data[EffectiveConfigKeyServiceAccount])
+	assert.Equal(t, "https://nb.apps.example.com", configMap.Data[EffectiveConfigKeyURL])
+	for key, value := range configMap.Data {
+		assert.NotContains(t, value, "s3cr3t", key)
+		assert.NotContains(t, value, "t0k3n", key)
+	}
+}
+
+func TestReconcileEffectiveConfig(t *testing.T) {
+	notebook := newEffectiveConfigNotebook()
+	route := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+		Status: routev1.RouteStatus{Ingress: []routev1.RouteIngress{{Host: "nb.apps.example.com"}}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook, route)
+	r.EffectiveConfigEnabled = true
+	ctx := context.Background()
+	key := client.ObjectKey{Name: "nb-effective-config", Namespace: "ns"}
+
+	require.NoError(t, r.ReconcileEffectiveConfig(notebook, ctx))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, r.Get(ctx, key, configMap))
+	assert.True(t, metav1.IsControlledBy(configMap, notebook))
+	assert.Equal(t, "https://nb.apps.example.com", configMap.Data[EffectiveConfigKeyURL])
+
+	// The changes of the users are reverted
+	configMap.Data[EffectiveConfigKeyURL] = "https://elsewhere.example.com"
+	require.NoError(t, r.Update(ctx, configMap))
+	require.NoError(t, r.ReconcileEffectiveConfig(notebook, ctx))
+	require.NoError(t, r.Get(ctx, key, configMap))
+	assert.Equal(t, "https://nb.apps.example.com", configMap.Data[EffectiveConfigKeyURL])
+
+	r.EffectiveConfigEnabled = false
+	require.NoError(t, r.ReconcileEffectiveConfig(notebook, ctx))
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, key, &corev1.ConfigMap{})))
+}
@@ -0,0 +1,185 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ErrorClass classifies the reconcile errors by what resolves them, which
+// drives the requeue of the notebook.
+type ErrorClass string
+
+const (
+	// ErrorClassTransient errors, e.g. conflicts or an unavailable API
+	// server, are retried with the exponential backoff of the work queue.
+	ErrorClassTransient ErrorClass = "Transient"
+	// ErrorClassPermanent errors, e.g. an object rejected by the API server,
+	// are not retried until the notebook or its objects change.
+	ErrorClassPermanent ErrorClass = "Permanent"
+	// ErrorClassUserActionable errors are resolved by the users, e.g. by
+	// deleting an object conflicting with the notebook, and are retried
+	// slowly.
+	ErrorClassUserActionable ErrorClass = "UserActionable"
+
+	// ConditionReconcileError reports the permanent and user-actionable
+	// reconcile errors of the notebook, removed once it is reconciled.
+	ConditionReconcileError = "notebooks.opendatahub.io/ReconcileError"
+
+	// userActionableRequeueInterval is the interval between the reconciles
+	// of the notebooks failing with a user-actionable error, which may be
+	// resolved by changing objects the controller does not watch.
+	userActionableRequeueInterval = 5 * time.Minute
+)
+
+// ReconcileError is a classified reconcile error. Its reason is a CamelCase
+// summary, used as condition reason, event reason and metric label.
+type ReconcileError struct {
+	Class  ErrorClass
+	Reason string
+	Err    error
+}
+
+func (e *ReconcileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReconcileError) Unwrap() error {
+	return e.Err
+}
+
+// NewTransientError returns a transient reconcile error.
+func NewTransientError(reason string, err error) error {
+	return &ReconcileError{Class: ErrorClassTransient, Reason: reason, Err: err}
+}
+
+// NewPermanentError returns a permanent reconcile error.
+func NewPermanentError(reason string, err error) error {
+	return &ReconcileError{Class: ErrorClassPermanent, Reason: reason, Err: err}
+}
+
+// NewUserActionableError returns a reconcile error the users must resolve.
+func NewUserActionableError(reason string, err error) error {
+	return &ReconcileError{Class: ErrorClassUserActionable, Reason: reason, Err: err}
+}
+
+// ClassifyError returns the class and reason of the reconcile error. The
+// errors not classified by the reconcilers are classified from their API
+// status, and are transient by default.
+func ClassifyError(err error) (ErrorClass, string) {
+	var reconcileErr *ReconcileError
+	if errors.As(err, &reconcileErr) {
+		return reconcileErr.Class, reconcileErr.Reason
+	}
+	switch {
+	case apierrs.IsConflict(err) || apierrs.IsAlreadyExists(err):
+		return ErrorClassTransient, "Conflict"
+	case apierrs.IsNotFound(err):
+		return ErrorClassTransient, "NotFound"
+	case apierrs.IsServerTimeout(err) || apierrs.IsTimeout(err) || apierrs.IsTooManyRequests(err) ||
+		apierrs.IsServiceUnavailable(err) || apierrs.IsInternalError(err) || errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTransient, "APIUnavailable"
+	case apierrs.IsForbidden(err):
+		return ErrorClassPermanent, "Forbidden"
+	case apierrs.IsInvalid(err) || apierrs.IsBadRequest(err):
+		return ErrorClassPermanent, "Invalid"
+	}
+	return ErrorClassTransient, "Error"
+}
+
+// handleReconcileError returns the result of the reconcile of the notebook
+// from the class of its error: the transient errors are returned to be
+// retried with backoff, the permanent ones as terminal errors, and the
+// user-actionable ones are requeued slowly. The permanent and
+// user-actionable errors are reported in the notebook condition and events.
+func (r *OpenshiftNotebookReconciler) handleReconcileError(notebook *nbv1.Notebook, ctx context.Context,
+	result ctrl.Result, err error) (ctrl.Result, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if err == nil {
+		return result, r.updateReconcileErrorCondition(notebook, ctx, nil)
+	}
+	class, reason := ClassifyError(err)
+	notebookReconcileErrorsTotal.WithLabelValues(string(class), reason).Inc()
+	if class == ErrorClassTransient {
+		return result, err
+	}
+
+	log.Error(err, "Unable to reconcile the notebook", "class", class, "reason", reason)
+	r.recordEvent(notebook, corev1.EventTypeWarning, reason, "%s", err.Error())
+	condition := &nbv1.NotebookCondition{
+		Type:               ConditionReconcileError,
+		Status:             string(corev1.ConditionTrue),
+		Reason:             reason,
+		Message:            err.Error(),
+		LastProbeTime:      metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+	}
+	if conditionErr := r.updateReconcileErrorCondition(notebook, ctx, condition); conditionErr != nil {
+		return ctrl.Result{}, conditionErr
+	}
+	if class == ErrorClassPermanent {
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	return ctrl.Result{RequeueAfter: userActionableRequeueInterval}, nil
+}
+
+// updateReconcileErrorCondition sets or removes the reconcile error condition
+// of the notebook, leaving the notebook untouched if it did not change.
+func (r *OpenshiftNotebookReconciler) updateReconcileErrorCondition(notebook *nbv1.Notebook, ctx context.Context,
+	condition *nbv1.NotebookCondition) error {
+	var found *nbv1.NotebookCondition
+	for i := range notebook.Status.Conditions {
+		if notebook.Status.Conditions[i].Type == ConditionReconcileError {
+			found = &notebook.Status.Conditions[i]
+		}
+	}
+	if found == nil && condition == nil ||
+		found != nil && condition != nil && found.Reason == condition.Reason && found.Message == condition.Message {
+		return nil
+	}
+
+	// The notebook may be deleted once its finalizer is removed
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook); err != nil {
+			return err
+		}
+		conditions := []nbv1.NotebookCondition{}
+		for _, existing := range notebook.Status.Conditions {
+			if existing.Type != ConditionReconcileError {
+				conditions = append(conditions, existing)
+			}
+		}
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+		notebook.Status.Conditions = conditions
+		return r.Status().Update(ctx, notebook)
+	})
+	return client.IgnoreNotFound(err)
+}
@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestClassifyError(t *testing.T) {
+	resource := schema.GroupResource{Resource: "services"}
+	tests := []struct {
+		name   string
+		err    error
+		class  ErrorClass
+		reason string
+	}{
+		{"conflict", apierrs.NewConflict(resource, "nb", errors.New("modified")), ErrorClassTransient, "Conflict"},
+		{"unavailable", apierrs.NewServiceUnavailable("down"), ErrorClassTransient, "APIUnavailable"},
+		{"forbidden", apierrs.NewForbidden(resource, "nb", errors.New("denied")), ErrorClassPermanent, "Forbidden"},
+		{"invalid", apierrs.NewBadRequest("bad"), ErrorClassPermanent, "Invalid"},
+		{"unknown", errors.New("boom"), ErrorClassTransient, "Error"},
+		{"wrapped", fmt.Errorf("reconcile: %w", NewUserActionableError("ServiceConflict", errors.New("taken"))),
+			ErrorClassUserActionable, "ServiceConflict"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, reason := ClassifyError(tt.err)
+			assert.Equal(t, tt.class, class)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestHandleReconcileError(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	cl := fake.NewClientBuilder().WithScheme(newTestReconciler(t, OAuthConfig{}).Scheme).
+		WithObjects(notebook).WithStatusSubresource(&nbv1.Notebook{}).Build()
+	r := &OpenshiftNotebookReconciler{Client: cl, Log: logr.Discard()}
+
+	// The transient errors are retried with backoff
+	transient := apierrs.NewServiceUnavailable("down")
+	_, err := r.handleReconcileError(notebook, ctx, ctrl.Result{}, transient)
+	assert.Equal(t, transient, err)
+	assert.Empty(t, notebook.Status.Conditions)
+
+	// The user-actionable errors are requeued slowly and reported
+	conflict := NewUserActionableError("ServiceConflict", errors.New("service nb already exists"))
+	result, err := r.handleReconcileError(notebook, ctx, ctrl.Result{}, conflict)
+	require.NoError(t, err)
+	assert.Equal(t, userActionableRequeueInterval, result.RequeueAfter)
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	require.Len(t, notebook.Status.Conditions, 1)
+	assert.Equal(t, ConditionReconcileError, notebook.Status.Conditions[0].Type)
+	assert.Equal(t, "ServiceConflict", notebook.Status.Conditions[0].Reason)
+
+	// The permanent errors are terminal
+	_, err = r.handleReconcileError(notebook, ctx, ctrl.Result{}, apierrs.NewBadRequest("bad"))
+	assert.ErrorIs(t, err, reconcile.TerminalError(nil))
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	assert.Equal(t, "Invalid", notebook.Status.Conditions[0].Reason)
+
+	// The condition is removed once the notebook is reconciled
+	result, err = r.handleReconcileError(notebook, ctx, ctrl.Result{RequeueAfter: placementRequeueInterval}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, placementRequeueInterval, result.RequeueAfter)
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	assert.Empty(t, notebook.Status.Conditions)
+}
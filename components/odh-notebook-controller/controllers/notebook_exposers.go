@@ -0,0 +1,190 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The exposers compiled in the controller.
+const (
+	// ExposerStartupPage serves the startup page through the Route of the
+	// notebooks while they start.
+	ExposerStartupPage = "startup-page"
+	// ExposerRoute exposes the notebooks through their Route, authenticated
+	// by the OAuth proxy if injected.
+	ExposerRoute = "route"
+	// ExposerExposureService exposes the notebooks through the LoadBalancer
+	// or NodePort Services of the --exposure mode.
+	ExposerExposureService = "exposure-service"
+)
+
+// DefaultExposers are the exposers run in order when none are configured.
+var DefaultExposers = []string{ExposerStartupPage, ExposerRoute, ExposerExposureService}
+
+// Exposer exposes the notebooks outside of the cluster, e.g. through a Route
+// or a corporate API gateway. The exposers are registered by name with
+// RegisterExposer, e.g. from the init function of a package compiled in by a
+// downstream distribution, and enabled with the --exposers flag. They are not
+// run for the notebooks of the service mesh, exposed by the mesh, and are run
+// after the objects of the OAuth proxy are reconciled.
+type Exposer interface {
+	// Name is the name the exposer is registered and enabled with.
+	Name() string
+	// Expose creates, updates or deletes the objects exposing the notebook.
+	// The objects should be controlled by the notebook, and deleted once
+	// r.ExposureAllowed returns false.
+	Expose(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error
+}
+
+// WatchingExposer is an Exposer whose objects are watched, so that their
+// changes are reconciled.
+type WatchingExposer interface {
+	Exposer
+	// OwnedObjects returns the types of the objects controlled by the
+	// notebooks, e.g. &gatewayv1.HTTPRoute{}.
+	OwnedObjects() []client.Object
+}
+
+var (
+	exposersMu sync.RWMutex
+	exposers   = map[string]Exposer{}
+)
+
+// RegisterExposer registers the exposer under its name. It panics if the
+// name is empty or already registered.
+func RegisterExposer(exposer Exposer) {
+	exposersMu.Lock()
+	defer exposersMu.Unlock()
+	name := exposer.Name()
+	if name == "" {
+		panic("the exposers must have a name")
+	}
+	if _, ok := exposers[name]; ok {
+		panic(fmt.Sprintf("the exposer %s is already registered", name))
+	}
+	exposers[name] = exposer
+}
+
+// RegisteredExposers returns the sorted names of the registered exposers.
+func RegisteredExposers() []string {
+	exposersMu.RLock()
+	defer exposersMu.RUnlock()
+	names := make([]string, 0, len(exposers))
+	for name := range exposers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupExposer returns the exposer registered under the name, nil if none
+// is.
+func LookupExposer(name string) Exposer {
+	exposersMu.RLock()
+	defer exposersMu.RUnlock()
+	return exposers[name]
+}
+
+// ParseExposers parses the comma-separated names of the exposers, which
+// must be registered. The DefaultExposers are returned if empty.
+func ParseExposers(value string) ([]string, error) {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if LookupExposer(name) == nil {
+			return nil, fmt.Errorf("unknown exposer %q, must be one of %v", name, RegisteredExposers())
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return DefaultExposers, nil
+	}
+	return names, nil
+}
+
+// enabledExposers returns the exposers run in order by the reconciler.
+func (r *OpenshiftNotebookReconciler) enabledExposers() ([]Exposer, error) {
+	names := r.Exposers
+	if len(names) == 0 {
+		names = DefaultExposers
+	}
+	enabled := make([]Exposer, 0, len(names))
+	for _, name := range names {
+		exposer := LookupExposer(name)
+		if exposer == nil {
+			return nil, fmt.Errorf("unknown exposer %q", name)
+		}
+		enabled = append(enabled, exposer)
+	}
+	return enabled, nil
+}
+
+// ExposureAllowed returns true if the notebook is exposed outside of the
+// cluster: its expose annotation is not false, and it is not kept cluster
+// internal by the policy of the unprotected notebooks.
+func (r *OpenshiftNotebookReconciler) ExposureAllowed(notebook *nbv1.Notebook) bool {
+	return ExposureIsEnabled(notebook.ObjectMeta) && !r.RouteConfig.exposureIsRestricted(notebook)
+}
+
+// exposerFunc is an Exposer defined by its function.
+type exposerFunc struct {
+	name   string
+	expose func(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error
+}
+
+func (e exposerFunc) Name() string {
+	return e.name
+}
+
+func (e exposerFunc) Expose(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+	return e.expose(ctx, r, notebook)
+}
+
+func init() {
+	RegisterExposer(exposerFunc{name: ExposerStartupPage,
+		expose: func(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+			// Serve the startup page through the Route while the notebook starts
+			return r.ReconcileStartupPage(notebook, ctx)
+		}})
+	RegisterExposer(exposerFunc{name: ExposerRoute,
+		expose: func(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+			if OAuthInjectionIsEnabled(notebook.ObjectMeta) {
+				// Call the OAuth Route reconciler
+				return r.ReconcileOAuthRoute(notebook, ctx)
+			}
+			// Call the route reconciler (see notebook_route.go file)
+			return r.ReconcileRoute(notebook, ctx)
+		}})
+	RegisterExposer(exposerFunc{name: ExposerExposureService,
+		expose: func(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+			// Call the exposure Service reconciler, for the notebooks exposed
+			// without Route
+			return r.ReconcileExposureService(notebook, ctx)
+		}})
+}
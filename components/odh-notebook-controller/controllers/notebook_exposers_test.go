@@ -0,0 +1,74 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseExposers(t *testing.T) {
+	exposers, err := ParseExposers("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultExposers, exposers)
+
+	exposers, err = ParseExposers(" route, route,exposure-service ")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ExposerRoute, ExposerExposureService}, exposers)
+
+	_, err = ParseExposers("route,api-gateway")
+	assert.Error(t, err)
+}
+
+func TestRegisterExposer(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterExposer(exposerFunc{name: ExposerRoute})
+	})
+	assert.Panics(t, func() {
+		RegisterExposer(exposerFunc{})
+	})
+}
+
+func TestReconcileExposureSubsystemExposers(t *testing.T) {
+	exposed := []string{}
+	RegisterExposer(exposerFunc{name: "test-gateway",
+		expose: func(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+			if r.ExposureAllowed(notebook) {
+				exposed = append(exposed, notebook.Name)
+			}
+			return nil
+		}})
+	r := newTestReconciler(t, OAuthConfig{})
+	r.Exposers = []string{"test-gateway"}
+
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	assert.NoError(t, r.reconcileExposureSubsystem(notebook, context.Background()))
+	assert.Equal(t, []string{"nb"}, exposed)
+
+	// The notebooks of the service mesh are exposed by the mesh
+	mesh := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "mesh", Namespace: "ns",
+		Annotations: map[string]string{AnnotationServiceMesh: "true"}}}
+	assert.NoError(t, r.reconcileExposureSubsystem(mesh, context.Background()))
+	assert.Equal(t, []string{"nb"}, exposed)
+
+	// The exposers which are not registered fail the reconcile
+	r.Exposers = []string{"unknown"}
+	assert.Error(t, r.reconcileExposureSubsystem(notebook, context.Background()))
+}
@@ -0,0 +1,303 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Modes of exposure of the notebooks outside of the cluster.
+const (
+	// ExposureRoute exposes the notebooks through the OpenShift router.
+	ExposureRoute = "route"
+	// ExposureLoadBalancer exposes the notebooks through LoadBalancer
+	// Services, e.g. internal load balancers of the cloud provider.
+	ExposureLoadBalancer = "loadbalancer"
+	// ExposureNodePort exposes the notebooks through NodePort Services.
+	ExposureNodePort = "nodeport"
+)
+
+const (
+	// AnnotationOAuthRedirectURIExposure registers the address of the
+	// exposure Service in the OAuth redirect URIs of the notebook service
+	// account, as the redirect reference only resolves Routes.
+	AnnotationOAuthRedirectURIExposure = "serviceaccounts.openshift.io/oauth-redirecturi.exposure"
+
+	// notebookContainerPort is the default port of the notebook server.
+	notebookContainerPort = 8888
+)
+
+// ExposureConfig holds how the notebooks are exposed outside of the cluster,
+// for the clusters where the OpenShift router is not reachable from the
+// network of the users.
+type ExposureConfig struct {
+	// Mode is ExposureRoute, ExposureLoadBalancer or ExposureNodePort.
+	// ExposureRoute if empty.
+	Mode string
+	// LoadBalancerAnnotations are set on the LoadBalancer Services, e.g. to
+	// request an internal load balancer from the cloud provider.
+	LoadBalancerAnnotations map[string]string
+	// LoadBalancerSourceRanges are the CIDRs of the clients allowed to reach
+	// the LoadBalancer Services. Not restricted if empty.
+	LoadBalancerSourceRanges []string
+	// NodePortHost is the host the users reach the nodes at, registered with
+	// the NodePort in the OAuth redirect URIs.
+	NodePortHost string
+}
+
+// ParseExposureConfig parses the JSON object of the annotations of the
+// LoadBalancer Services, and checks the exposure settings.
+func ParseExposureConfig(mode, loadBalancerAnnotations string, loadBalancerSourceRanges []string,
+	nodePortHost string) (ExposureConfig, error) {
+	config := ExposureConfig{
+		Mode:                     mode,
+		LoadBalancerAnnotations:  map[string]string{},
+		LoadBalancerSourceRanges: loadBalancerSourceRanges,
+		NodePortHost:             nodePortHost,
+	}
+	if strings.TrimSpace(loadBalancerAnnotations) != "" {
+		if err := json.Unmarshal([]byte(loadBalancerAnnotations), &config.LoadBalancerAnnotations); err != nil {
+			return config, fmt.Errorf("invalid load balancer annotations: %w", err)
+		}
+	}
+	return config, config.Validate()
+}
+
+// Validate checks the exposure mode and the load balancer settings.
+func (c ExposureConfig) Validate() error {
+	switch c.Mode {
+	case "", ExposureRoute, ExposureLoadBalancer, ExposureNodePort:
+	default:
+		return fmt.Errorf("invalid exposure mode %q, must be one of [%s, %s, %s]", c.Mode,
+			ExposureRoute, ExposureLoadBalancer, ExposureNodePort)
+	}
+	for key := range c.LoadBalancerAnnotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid load balancer annotation %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	for _, cidr := range c.LoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid load balancer source range %q: %w", cidr, err)
+		}
+	}
+	return nil
+}
+
+// RouteIsEnabled returns true if the notebook is exposed through its Route.
+func (c ExposureConfig) RouteIsEnabled(notebook *nbv1.Notebook) bool {
+	return (c.Mode == "" || c.Mode == ExposureRoute) && ExposureIsEnabled(notebook.ObjectMeta)
+}
+
+// ExposureServiceName returns the name of the Service exposing the notebook.
+func ExposureServiceName(notebook *nbv1.Notebook) string {
+	return notebook.Name + "-external"
+}
+
+// NewNotebookExposureService defines the desired Service exposing the
+// notebook, nil if the notebook is exposed through its Route or not exposed.
+// The Service reaches the OAuth proxy of the notebooks injected with it.
+func NewNotebookExposureService(notebook *nbv1.Notebook, config ExposureConfig) *corev1.Service {
+	if config.Mode == "" || config.Mode == ExposureRoute || !ExposureIsEnabled(notebook.ObjectMeta) {
+		return nil
+	}
+
+	port := corev1.ServicePort{
+		Name:       "http-" + notebook.Name,
+		Port:       80,
+		TargetPort: intstr.FromInt(notebookContainerPort),
+		Protocol:   corev1.ProtocolTCP,
+	}
+	for _, container := range notebook.Spec.Template.Spec.Containers {
+		if container.Name == PrimaryContainerName(notebook) && len(container.Ports) > 0 {
+			port.TargetPort = intstr.FromInt(int(container.Ports[0].ContainerPort))
+		}
+	}
+	if OAuthInjectionIsEnabled(notebook.ObjectMeta) {
+		port = corev1.ServicePort{
+			Name:       OAuthServicePortName,
+			Port:       OAuthServicePort,
+			TargetPort: intstr.FromString(OAuthServicePortName),
+			Protocol:   corev1.ProtocolTCP,
+		}
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ExposureServiceName(notebook),
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentExposure),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{port},
+			Selector: map[string]string{
+				"statefulset": notebook.Name,
+			},
+		},
+	}
+	if config.Mode == ExposureLoadBalancer {
+		service.Spec.Type = corev1.ServiceTypeLoadBalancer
+		if len(config.LoadBalancerSourceRanges) > 0 {
+			service.Spec.LoadBalancerSourceRanges = config.LoadBalancerSourceRanges
+		}
+		if len(config.LoadBalancerAnnotations) > 0 {
+			service.Annotations = map[string]string{}
+			for key, value := range config.LoadBalancerAnnotations {
+				service.Annotations[key] = value
+			}
+		}
+	}
+	return service
+}
+
+// ReconcileExposureService creates, updates or deletes the Service exposing
+// the notebook instead of its Route.
+func (r *OpenshiftNotebookReconciler) ReconcileExposureService(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	desiredService := NewNotebookExposureService(notebook, r.ExposureConfig)
+	if desiredService == nil || r.RouteConfig.exposureIsRestricted(notebook) {
+		return r.deleteControlledObject(ctx, notebook, ExposureServiceName(notebook), &corev1.Service{})
+	}
+
+	foundService := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Name: desiredService.Name, Namespace: notebook.Namespace}, foundService)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating exposure Service", "type", desiredService.Spec.Type)
+		err = ctrl.SetControllerReference(notebook, desiredService, r.Scheme)
+		if err != nil {
+			log.Error(err, "Unable to add OwnerReference to the exposure Service")
+			return err
+		}
+		err = r.Create(ctx, desiredService)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the exposure Service")
+			return err
+		}
+		return nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the exposure Service")
+		return err
+	}
+	if !metav1.IsControlledBy(foundService, notebook) {
+		return NewUserActionableError("ServiceConflict",
+			fmt.Errorf("service %s already exists and is not controlled by the notebook", foundService.Name))
+	}
+
+	// Keep the node ports allocated to the Service
+	for i, port := range desiredService.Spec.Ports {
+		for _, foundPort := range foundService.Spec.Ports {
+			if foundPort.Name == port.Name {
+				desiredService.Spec.Ports[i].NodePort = foundPort.NodePort
+			}
+		}
+	}
+	update := mergeLabels(foundService, desiredService.Labels)
+	for key, value := range desiredService.Annotations {
+		if foundService.Annotations[key] != value {
+			if foundService.Annotations == nil {
+				foundService.Annotations = map[string]string{}
+			}
+			foundService.Annotations[key] = value
+			update = true
+		}
+	}
+	if foundService.Spec.Type != desiredService.Spec.Type ||
+		!reflect.DeepEqual(foundService.Spec.Ports, desiredService.Spec.Ports) ||
+		len(foundService.Spec.LoadBalancerSourceRanges)+len(desiredService.Spec.LoadBalancerSourceRanges) > 0 &&
+			!reflect.DeepEqual(foundService.Spec.LoadBalancerSourceRanges, desiredService.Spec.LoadBalancerSourceRanges) {
+		foundService.Spec.Type = desiredService.Spec.Type
+		foundService.Spec.Ports = desiredService.Spec.Ports
+		foundService.Spec.LoadBalancerSourceRanges = desiredService.Spec.LoadBalancerSourceRanges
+		update = true
+	}
+	if update {
+		log.Info("Reconciling exposure Service", "type", desiredService.Spec.Type)
+		err = r.Update(ctx, foundService)
+		if err != nil {
+			log.Error(err, "Unable to reconcile the exposure Service")
+			return err
+		}
+	}
+	return nil
+}
+
+// exposureRedirectURI returns the address the users reach the OAuth proxy at
+// through the exposure Service, or an empty string while the Service has no
+// address.
+func (r *OpenshiftNotebookReconciler) exposureRedirectURI(notebook *nbv1.Notebook, ctx context.Context) (string, error) {
+	if NewNotebookExposureService(notebook, r.ExposureConfig) == nil {
+		return "", nil
+	}
+	service := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Name: ExposureServiceName(notebook), Namespace: notebook.Namespace}, service)
+	if apierrs.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	var host string
+	var port int32
+	for _, servicePort := range service.Spec.Ports {
+		if servicePort.Name == OAuthServicePortName {
+			port = servicePort.Port
+			if service.Spec.Type == corev1.ServiceTypeNodePort {
+				port = servicePort.NodePort
+			}
+		}
+	}
+	switch service.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if host = ingress.Hostname; host == "" {
+				host = ingress.IP
+			}
+			if host != "" {
+				break
+			}
+		}
+	case corev1.ServiceTypeNodePort:
+		host = r.ExposureConfig.NodePortHost
+	}
+	if host == "" || port == 0 {
+		return "", nil
+	}
+	if port == 443 {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return "https://" + host, nil
+	}
+	return "https://" + net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
@@ -0,0 +1,123 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestParseExposureConfig(t *testing.T) {
+	config, err := ParseExposureConfig(ExposureLoadBalancer,
+		`{"service.beta.kubernetes.io/aws-load-balancer-internal":"true"}`, []string{"10.0.0.0/8", "fd00::/8"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "true"},
+		config.LoadBalancerAnnotations)
+
+	_, err = ParseExposureConfig("", "", nil, "")
+	assert.NoError(t, err)
+	_, err = ParseExposureConfig("ingress", "", nil, "")
+	assert.Error(t, err, "unknown mode")
+	_, err = ParseExposureConfig(ExposureLoadBalancer, `["internal"]`, nil, "")
+	assert.Error(t, err, "invalid JSON")
+	_, err = ParseExposureConfig(ExposureLoadBalancer, "", []string{"10.0.0.0"}, "")
+	assert.Error(t, err, "invalid CIDR")
+}
+
+func TestNewNotebookExposureService(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	assert.Nil(t, NewNotebookExposureService(notebook, ExposureConfig{}))
+	assert.Nil(t, NewNotebookExposureService(notebook, ExposureConfig{Mode: ExposureRoute}))
+
+	service := NewNotebookExposureService(notebook, ExposureConfig{Mode: ExposureNodePort})
+	require.NotNil(t, service)
+	assert.Equal(t, corev1.ServiceTypeNodePort, service.Spec.Type)
+	assert.Equal(t, intstr.FromInt(8888), service.Spec.Ports[0].TargetPort)
+
+	notebook.Annotations = map[string]string{AnnotationInjectOAuth: "true"}
+	service = NewNotebookExposureService(notebook, ExposureConfig{Mode: ExposureLoadBalancer,
+		LoadBalancerAnnotations: map[string]string{"cloud/internal": "true"}})
+	require.NotNil(t, service)
+	assert.Equal(t, corev1.ServiceTypeLoadBalancer, service.Spec.Type)
+	assert.Equal(t, OAuthServicePortName, service.Spec.Ports[0].Name)
+	assert.Equal(t, "true", service.Annotations["cloud/internal"])
+
+	notebook.Annotations[AnnotationExpose] = "false"
+	assert.Nil(t, NewNotebookExposureService(notebook, ExposureConfig{Mode: ExposureLoadBalancer}))
+}
+
+func TestReconcileExposureService(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationInjectOAuth: "true"}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	r.ExposureConfig = ExposureConfig{Mode: ExposureLoadBalancer}
+	serviceKey := client.ObjectKey{Namespace: "ns", Name: "nb-external"}
+
+	// The route is replaced by a LoadBalancer Service
+	require.NoError(t, r.Create(ctx, func() *routev1.Route {
+		route := NewNotebookOAuthRoute(notebook)
+		route.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(notebook,
+			nbv1.GroupVersion.WithKind("Notebook"))}
+		return route
+	}()))
+	require.NoError(t, r.ReconcileOAuthRoute(notebook, ctx))
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "nb"}, &routev1.Route{})))
+	require.NoError(t, r.ReconcileExposureService(notebook, ctx))
+	service := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, serviceKey, service))
+	assert.Equal(t, corev1.ServiceTypeLoadBalancer, service.Spec.Type)
+
+	// The address of the load balancer is registered in the OAuth redirect
+	// URIs once published
+	uri, err := r.exposureRedirectURI(notebook, ctx)
+	require.NoError(t, err)
+	assert.Empty(t, uri)
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.10"}}
+	require.NoError(t, r.Status().Update(ctx, service))
+	require.NoError(t, r.ReconcileOAuthServiceAccount(notebook, ctx))
+	serviceAccount := &corev1.ServiceAccount{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "nb"}, serviceAccount))
+	assert.Equal(t, "https://10.0.0.10", serviceAccount.Annotations[AnnotationOAuthRedirectURIExposure])
+
+	// The Service type follows the exposure mode, keeping the node port
+	require.NoError(t, r.Get(ctx, serviceKey, service))
+	service.Spec.Ports[0].NodePort = 30443
+	require.NoError(t, r.Update(ctx, service))
+	r.ExposureConfig = ExposureConfig{Mode: ExposureNodePort, NodePortHost: "nodes.example.com"}
+	require.NoError(t, r.ReconcileExposureService(notebook, ctx))
+	require.NoError(t, r.Get(ctx, serviceKey, service))
+	assert.Equal(t, corev1.ServiceTypeNodePort, service.Spec.Type)
+	assert.Equal(t, int32(30443), service.Spec.Ports[0].NodePort)
+	uri, err = r.exposureRedirectURI(notebook, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "https://nodes.example.com:30443", uri)
+
+	// The Service is deleted once the notebook is exposed through a Route
+	r.ExposureConfig = ExposureConfig{}
+	require.NoError(t, r.ReconcileExposureService(notebook, ctx))
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, serviceKey, &corev1.Service{})))
+}
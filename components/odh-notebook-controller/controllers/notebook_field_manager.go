@@ -0,0 +1,217 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// DefaultFieldManager is the field manager of the changes of the controller,
+// recorded in the managed fields of the objects it writes.
+const DefaultFieldManager = "odh-notebook-controller"
+
+// fieldManagerOrDefault returns the given field manager, or the default one if
+// empty.
+func fieldManagerOrDefault(fieldManager string) string {
+	if fieldManager == "" {
+		return DefaultFieldManager
+	}
+	return fieldManager
+}
+
+// FieldOwnerClient records the writes of the client, including the writes of
+// the subresources, under its field manager. The field manager set by the
+// callers on a write takes precedence.
+type FieldOwnerClient struct {
+	client.Client
+	FieldManager string
+}
+
+// NewFieldOwnerClient returns the client recording its writes under the
+// field manager, or the default one if empty.
+func NewFieldOwnerClient(c client.Client, fieldManager string) *FieldOwnerClient {
+	return &FieldOwnerClient{Client: c, FieldManager: fieldManagerOrDefault(fieldManager)}
+}
+
+func (c *FieldOwnerClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return c.Client.Create(ctx, obj, append([]client.CreateOption{client.FieldOwner(c.FieldManager)}, opts...)...)
+}
+
+func (c *FieldOwnerClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.Client.Update(ctx, obj, append([]client.UpdateOption{client.FieldOwner(c.FieldManager)}, opts...)...)
+}
+
+func (c *FieldOwnerClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	return c.Client.Patch(ctx, obj, patch, append([]client.PatchOption{client.FieldOwner(c.FieldManager)}, opts...)...)
+}
+
+func (c *FieldOwnerClient) Status() client.SubResourceWriter {
+	return &fieldOwnerSubResourceWriter{SubResourceWriter: c.Client.Status(), fieldManager: c.FieldManager}
+}
+
+func (c *FieldOwnerClient) SubResource(subResource string) client.SubResourceClient {
+	subResourceClient := c.Client.SubResource(subResource)
+	return &fieldOwnerSubResourceClient{
+		SubResourceReader: subResourceClient,
+		fieldOwnerSubResourceWriter: fieldOwnerSubResourceWriter{SubResourceWriter: subResourceClient,
+			fieldManager: c.FieldManager},
+	}
+}
+
+// fieldOwnerSubResourceWriter records the writes of the subresources under
+// the field manager.
+type fieldOwnerSubResourceWriter struct {
+	client.SubResourceWriter
+	fieldManager string
+}
+
+func (w *fieldOwnerSubResourceWriter) Create(ctx context.Context, obj client.Object, subResource client.Object,
+	opts ...client.SubResourceCreateOption) error {
+	return w.SubResourceWriter.Create(ctx, obj, subResource,
+		append([]client.SubResourceCreateOption{client.FieldOwner(w.fieldManager)}, opts...)...)
+}
+
+func (w *fieldOwnerSubResourceWriter) Update(ctx context.Context, obj client.Object,
+	opts ...client.SubResourceUpdateOption) error {
+	return w.SubResourceWriter.Update(ctx, obj,
+		append([]client.SubResourceUpdateOption{client.FieldOwner(w.fieldManager)}, opts...)...)
+}
+
+func (w *fieldOwnerSubResourceWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.SubResourcePatchOption) error {
+	return w.SubResourceWriter.Patch(ctx, obj, patch,
+		append([]client.SubResourcePatchOption{client.FieldOwner(w.fieldManager)}, opts...)...)
+}
+
+// fieldOwnerSubResourceClient reads the subresources as is and records their
+// writes under the field manager.
+type fieldOwnerSubResourceClient struct {
+	client.SubResourceReader
+	fieldOwnerSubResourceWriter
+}
+
+// managedFieldsContain returns true if the managed fields contain the given
+// path, e.g. f:spec.
+func managedFieldsContain(entry metav1.ManagedFieldsEntry, path ...string) bool {
+	if entry.FieldsV1 == nil {
+		return false
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+		return false
+	}
+	for _, key := range path {
+		child, ok := fields[key].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		fields = child
+	}
+	return true
+}
+
+// ConflictingFieldManagers returns the field managers, other than the given
+// one, which changed the fields of the object under the given path after the
+// last change of the given manager. The status subresource and the changes of
+// the controller under the default field manager are ignored.
+func ConflictingFieldManagers(obj metav1.Object, fieldManager string, path ...string) []string {
+	var since time.Time
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == fieldManager && entry.Subresource == "" && entry.Time != nil &&
+			entry.Time.Time.After(since) {
+			since = entry.Time.Time
+		}
+	}
+	managers := []string{}
+	found := map[string]bool{}
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == fieldManager || entry.Manager == DefaultFieldManager || entry.Subresource != "" ||
+			found[entry.Manager] {
+			continue
+		}
+		if entry.Time != nil && !entry.Time.Time.After(since) {
+			continue
+		}
+		if managedFieldsContain(entry, path...) {
+			found[entry.Manager] = true
+			managers = append(managers, entry.Manager)
+		}
+	}
+	sort.Strings(managers)
+	return managers
+}
+
+// reportFieldManagerConflicts reports the field managers whose changes to the
+// spec of the generated object are reverted by the controller, to tell which
+// tool keeps changing it.
+func (r *OpenshiftNotebookReconciler) reportFieldManagerConflicts(owner client.Object, found client.Object) {
+	managers := ConflictingFieldManagers(found, fieldManagerOrDefault(r.FieldManager), "f:spec")
+	if len(managers) == 0 {
+		return
+	}
+	kind := kindOf(r.Scheme, found)
+	for _, manager := range managers {
+		notebookFieldManagerConflictsTotal.WithLabelValues(kind, manager).Inc()
+	}
+	r.ownerLogger(owner).Info("Reverting the changes of other field managers", "kind", kind,
+		"name", found.GetName(), "managers", managers)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(owner, corev1.EventTypeWarning, "FieldManagerConflict",
+			"The %s %s was changed by %s, the changes are reverted", kind, found.GetName(),
+			strings.Join(managers, ", "))
+	}
+}
+
+// requestFieldManager returns the field manager of the admission request, or
+// the username if the client did not set one.
+func requestFieldManager(req admission.Request) string {
+	options := struct {
+		FieldManager string `json:"fieldManager"`
+	}{}
+	if len(req.Options.Raw) > 0 {
+		_ = json.Unmarshal(req.Options.Raw, &options)
+	}
+	if options.FieldManager != "" {
+		return options.FieldManager
+	}
+	return req.UserInfo.Username
+}
+
+// specManagerConflict describes the change of the notebook spec by a field
+// manager other than the managers of the spec so far, e.g. the dashboard and
+// a GitOps tool both changing the notebook. Empty if there is no conflict.
+func specManagerConflict(req admission.Request, notebook, oldNotebook *nbv1.Notebook) string {
+	manager := requestFieldManager(req)
+	previous := ConflictingFieldManagers(oldNotebook, manager, "f:spec")
+	if len(previous) == 0 {
+		return ""
+	}
+	notebookFieldManagerConflictsTotal.WithLabelValues("Notebook", manager).Inc()
+	return fmt.Sprintf("The spec of the notebook %s is changed by %s (user %s), it was changed by %s before",
+		notebook.Name, manager, req.UserInfo.Username, strings.Join(previous, ", "))
+}
@@ -0,0 +1,131 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func managedFieldsEntry(manager string, minutes int, fields string) metav1.ManagedFieldsEntry {
+	at := metav1.NewTime(time.Date(2024, 5, 1, 12, minutes, 0, 0, time.UTC))
+	return metav1.ManagedFieldsEntry{
+		Manager:   manager,
+		Operation: metav1.ManagedFieldsOperationUpdate,
+		Time:      &at,
+		FieldsV1:  &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func TestConflictingFieldManagers(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", ManagedFields: []metav1.ManagedFieldsEntry{
+		managedFieldsEntry("dashboard", 0, `{"f:spec":{"f:template":{}}}`),
+		managedFieldsEntry(DefaultFieldManager, 10, `{"f:spec":{}}`),
+		managedFieldsEntry("argocd", 20, `{"f:spec":{"f:template":{}}}`),
+		managedFieldsEntry("culler", 30, `{"f:metadata":{"f:annotations":{}}}`),
+	}}}
+
+	// Only the managers which changed the fields since the last change of the
+	// given manager are conflicts
+	assert.Equal(t, []string{"argocd"}, ConflictingFieldManagers(notebook, DefaultFieldManager, "f:spec"))
+	assert.Equal(t, []string{"argocd", "dashboard"}, ConflictingFieldManagers(notebook, "kubectl", "f:spec"))
+	assert.Equal(t, []string{"culler"}, ConflictingFieldManagers(notebook, "kubectl", "f:metadata"))
+
+	// The status subresource is ignored
+	status := managedFieldsEntry("notebook-controller", 40, `{"f:spec":{}}`)
+	status.Subresource = "status"
+	notebook.ManagedFields = append(notebook.ManagedFields, status)
+	assert.Equal(t, []string{"argocd"}, ConflictingFieldManagers(notebook, DefaultFieldManager, "f:spec"))
+}
+
+func TestSpecManagerConflict(t *testing.T) {
+	oldNotebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", ManagedFields: []metav1.ManagedFieldsEntry{
+		managedFieldsEntry("dashboard", 0, `{"f:spec":{}}`),
+	}}}
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UserInfo: authenticationv1.UserInfo{Username: "alice"},
+		Options:  runtime.RawExtension{Raw: []byte(`{"fieldManager":"argocd"}`)},
+	}}
+	assert.Equal(t, "argocd", requestFieldManager(req))
+	message := specManagerConflict(req, oldNotebook, oldNotebook)
+	assert.Contains(t, message, "changed by argocd (user alice)")
+	assert.Contains(t, message, "dashboard")
+
+	// The changes of the previous manager are not conflicts
+	req.Options.Raw = nil
+	oldNotebook.ManagedFields[0].Manager = "alice"
+	assert.Empty(t, specManagerConflict(req, oldNotebook, oldNotebook))
+}
+
+func TestReportFieldManagerConflicts(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	r := newTestReconciler(t, OAuthConfig{})
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	route := NewNotebookRoute(notebook)
+	route.ManagedFields = []metav1.ManagedFieldsEntry{
+		managedFieldsEntry(DefaultFieldManager, 0, `{"f:spec":{}}`),
+		managedFieldsEntry("kubectl-edit", 10, `{"f:spec":{"f:tls":{}}}`),
+	}
+	r.reportFieldManagerConflicts(notebook, route)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "FieldManagerConflict The Route nb was changed by kubectl-edit")
+	assert.Equal(t, 1.0, metricValue(t,
+		notebookFieldManagerConflictsTotal.WithLabelValues("Route", "kubectl-edit")).GetCounter().GetValue())
+}
+
+func TestFieldOwnerClient(t *testing.T) {
+	ctx := context.Background()
+	managers := []string{}
+	fakeClient := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{}).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			options := &client.CreateOptions{}
+			managers = append(managers, options.ApplyOptions(opts).FieldManager)
+			return c.Create(ctx, obj, opts...)
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+			opts ...client.SubResourceUpdateOption) error {
+			options := &client.SubResourceUpdateOptions{}
+			managers = append(managers, options.ApplyOptions(opts).FieldManager)
+			return nil
+		},
+	}).Build()
+	c := NewFieldOwnerClient(fakeClient, "")
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}}
+	require.NoError(t, c.Create(ctx, configMap))
+	require.NoError(t, c.Status().Update(ctx, configMap))
+	// The field manager of the callers takes precedence
+	configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"}}
+	require.NoError(t, c.Create(ctx, configMap, client.FieldOwner("caller")))
+	assert.Equal(t, []string{DefaultFieldManager, DefaultFieldManager, "caller"}, managers)
+}
@@ -0,0 +1,120 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// NotebookCleanupFinalizer keeps the deleted notebooks until the controller
+// removed what the owner references do not cover: the objects of other
+// namespaces or clusters, and the notebook metrics.
+const NotebookCleanupFinalizer = "notebooks.opendatahub.io/cleanup"
+
+// notebookCleanupStep removes an artifact of a deleted notebook which is not
+// garbage collected through the owner references.
+type notebookCleanupStep struct {
+	name    string
+	cleanup func(ctx context.Context, key types.NamespacedName) error
+}
+
+// notebookCleanupSteps returns the cleanup steps of the deleted notebooks. The
+// steps are idempotent, they also run when the deletion of a notebook without
+// finalizer is observed.
+func (r *OpenshiftNotebookReconciler) notebookCleanupSteps() []notebookCleanupStep {
+	return []notebookCleanupStep{
+		{
+			// The ManifestWorks live in the namespaces of the managed
+			// clusters
+			name: "ManifestWorks",
+			cleanup: func(ctx context.Context, key types.NamespacedName) error {
+				if r.PlacementConfig.Decider == nil {
+					return nil
+				}
+				return r.DeleteNotebookManifestWorks(ctx, key, "")
+			},
+		},
+		{
+			// The ClusterRoleBindings of kube-rbac-proxy are cluster scoped,
+			// they cannot be controlled by the notebook
+			name: "auth delegators",
+			cleanup: func(ctx context.Context, key types.NamespacedName) error {
+				return r.DeleteAuthDelegators(ctx, key)
+			},
+		},
+		{
+			name: "metrics",
+			cleanup: func(_ context.Context, key types.NamespacedName) error {
+				deleteContainerRestartsMetrics(key)
+				labels := prometheus.Labels{"namespace": key.Namespace, "notebook": key.Name}
+				notebookRunningHours.DeletePartialMatch(labels)
+				notebookGPUHours.DeletePartialMatch(labels)
+				return nil
+			},
+		},
+	}
+}
+
+// CleanupNotebook runs the cleanup steps of the deleted notebook.
+func (r *OpenshiftNotebookReconciler) CleanupNotebook(ctx context.Context, key types.NamespacedName) error {
+	for _, step := range r.notebookCleanupSteps() {
+		if err := step.cleanup(ctx, key); err != nil {
+			return fmt.Errorf("unable to clean up the %s of the notebook: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+// ReconcileFinalizer adds the cleanup finalizer to the notebook, or runs the
+// cleanup steps and removes the finalizer once the notebook is deleted.
+// Returns true if the notebook is deleted and must not be reconciled further.
+func (r *OpenshiftNotebookReconciler) ReconcileFinalizer(notebook *nbv1.Notebook, ctx context.Context) (bool, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	deleted := !notebook.DeletionTimestamp.IsZero()
+	if deleted == !controllerutil.ContainsFinalizer(notebook, NotebookCleanupFinalizer) {
+		// Nothing to add to a deleted notebook, or to remove from a live one
+		return deleted, nil
+	}
+
+	if deleted {
+		log.Info("Cleaning up the deleted notebook")
+		if err := r.CleanupNotebook(ctx, client.ObjectKeyFromObject(notebook)); err != nil {
+			log.Error(err, "Unable to clean up the deleted notebook")
+			return true, err
+		}
+	}
+
+	patch := client.MergeFromWithOptions(notebook.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if deleted {
+		controllerutil.RemoveFinalizer(notebook, NotebookCleanupFinalizer)
+	} else {
+		controllerutil.AddFinalizer(notebook, NotebookCleanupFinalizer)
+	}
+	if err := r.Patch(ctx, notebook, patch); err != nil {
+		log.Error(err, "Unable to update the cleanup finalizer of the notebook")
+		return deleted, err
+	}
+	return deleted, nil
+}
@@ -0,0 +1,53 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileFinalizer(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb-finalizer", Namespace: "ns"}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	key := client.ObjectKeyFromObject(notebook)
+
+	// The finalizer is added to the live notebooks
+	require.NoError(t, r.Get(ctx, key, notebook))
+	deleted, err := r.ReconcileFinalizer(notebook, ctx)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	require.NoError(t, r.Get(ctx, key, notebook))
+	assert.Contains(t, notebook.Finalizers, NotebookCleanupFinalizer)
+
+	// The deleted notebook is cleaned up before the finalizer is removed
+	notebookRunningHours.WithLabelValues("ns", "nb-finalizer").Set(1)
+	require.NoError(t, r.Delete(ctx, notebook))
+	require.NoError(t, r.Get(ctx, key, notebook))
+	deleted, err = r.ReconcileFinalizer(notebook, ctx)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, key, notebook)))
+	assert.False(t, notebookRunningHours.DeleteLabelValues("ns", "nb-finalizer"))
+}
@@ -0,0 +1,259 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationHibernate hibernates the notebook when set to "true", the
+	// notebook is resumed when the annotation is removed or set to "false".
+	AnnotationHibernate = "notebooks.opendatahub.io/hibernate"
+	// AnnotationHibernated is set by the controller to the time the notebook
+	// was hibernated, until it is resumed.
+	AnnotationHibernated = "notebooks.opendatahub.io/hibernated"
+	// AnnotationLastImageSelection is the image stream tag selected for the
+	// notebook in the dashboard.
+	AnnotationLastImageSelection = "notebooks.opendatahub.io/last-image-selection"
+
+	// HibernationConfigMapSuffix is the suffix of the ConfigMap holding the
+	// runtime metadata of the hibernated notebook.
+	HibernationConfigMapSuffix = "-hibernation"
+
+	// The keys of the hibernation ConfigMap.
+	hibernationKeyImage          = "image"
+	hibernationKeyImageSelection = "imageSelection"
+	hibernationKeyEnvHash        = "envHash"
+	hibernationKeyUpdatePending  = "updatePending"
+	hibernationKeyHibernatedAt   = "hibernatedAt"
+)
+
+// HibernationIsRequested returns true if the notebook should be hibernated.
+func HibernationIsRequested(meta metav1.ObjectMeta) bool {
+	result, _ := strconv.ParseBool(meta.Annotations[AnnotationHibernate])
+	return result
+}
+
+// ValidateHibernationAnnotation checks the hibernation request of the
+// notebook, so that invalid values are rejected instead of resuming it.
+func ValidateHibernationAnnotation(notebook *nbv1.Notebook) error {
+	if value, ok := notebook.GetAnnotations()[AnnotationHibernate]; ok {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid %s annotation %q: expected true or false", AnnotationHibernate, value)
+		}
+	}
+	return nil
+}
+
+// notebookEnvHash returns the hash of the environment variables of the
+// notebook container, to report their changes across a hibernation.
+func notebookEnvHash(container *corev1.Container) string {
+	if container == nil {
+		return ""
+	}
+	value, err := json.Marshal(container.Env)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// NewHibernationConfigMap returns the ConfigMap snapshotting the runtime
+// metadata of the notebook being hibernated: the open image, the hash of its
+// environment and its pending updates.
+func NewHibernationConfigMap(notebook *nbv1.Notebook, hibernatedAt string) *corev1.ConfigMap {
+	data := map[string]string{
+		hibernationKeyImageSelection: notebook.GetAnnotations()[AnnotationLastImageSelection],
+		hibernationKeyUpdatePending:  notebook.GetAnnotations()[AnnotationUpdatePending],
+		hibernationKeyHibernatedAt:   hibernatedAt,
+	}
+	if container := notebookContainer(notebook); container != nil {
+		data[hibernationKeyImage] = container.Image
+		data[hibernationKeyEnvHash] = notebookEnvHash(container)
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      notebook.Name + HibernationConfigMapSuffix,
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentHibernation),
+		},
+		Data: data,
+	}
+}
+
+// hibernationChanges describes the changes of the notebook since it was
+// hibernated, as reported when it is resumed.
+func hibernationChanges(notebook *nbv1.Notebook, snapshot *corev1.ConfigMap) []string {
+	changes := []string{}
+	container := notebookContainer(notebook)
+	if container == nil {
+		return changes
+	}
+	if image := snapshot.Data[hibernationKeyImage]; image != "" && image != container.Image {
+		changes = append(changes, fmt.Sprintf("the image changed from %s to %s", image, container.Image))
+	}
+	if hash := snapshot.Data[hibernationKeyEnvHash]; hash != "" && hash != notebookEnvHash(container) {
+		changes = append(changes, "the environment variables changed")
+	}
+	if pending := snapshot.Data[hibernationKeyUpdatePending]; pending != "" {
+		changes = append(changes, "the pending updates are applied")
+	}
+	return changes
+}
+
+// ReconcileHibernation stops the notebook when its hibernation is requested,
+// after snapshotting its runtime metadata in a ConfigMap, and restarts it
+// from the snapshot when it is resumed. Starting a hibernated notebook, e.g.
+// by removing the stop annotation, resumes it as well.
+func (r *OpenshiftNotebookReconciler) ReconcileHibernation(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	requested := HibernationIsRequested(notebook.ObjectMeta)
+	hibernated := metav1.HasAnnotation(notebook.ObjectMeta, AnnotationHibernated)
+	stopped := notebook.GetAnnotations()[culler.STOP_ANNOTATION] != ""
+	switch {
+	case requested && !hibernated:
+		return r.hibernateNotebook(notebook, ctx)
+	case hibernated && (!requested || !stopped):
+		return r.resumeNotebook(notebook, ctx)
+	}
+	log.V(1).Info("Notebook hibernation up to date", "hibernated", hibernated)
+	return nil
+}
+
+// hibernateNotebook snapshots the runtime metadata of the notebook and stops
+// it.
+func (r *OpenshiftNotebookReconciler) hibernateNotebook(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	desired := NewHibernationConfigMap(notebook, now)
+	err := ctrl.SetControllerReference(notebook, desired, r.Scheme)
+	if err != nil {
+		return err
+	}
+	found := &corev1.ConfigMap{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(desired), found)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating the hibernation ConfigMap", "name", desired.Name)
+		err = r.Create(ctx, desired)
+	} else if err == nil && !metav1.IsControlledBy(found, notebook) {
+		err = NewUserActionableError("ConfigMapConflict",
+			fmt.Errorf("the ConfigMap %s is not controlled by the notebook", found.Name))
+	} else if err == nil {
+		// Replace the snapshot left behind by an interrupted hibernation
+		found.Labels = desired.Labels
+		found.Data = desired.Data
+		err = r.Update(ctx, found)
+	}
+	if err != nil {
+		log.Error(err, "Unable to snapshot the notebook before its hibernation")
+		return err
+	}
+
+	// Keep the stop time of the notebooks already stopped
+	annotations := map[string]string{AnnotationHibernated: now}
+	if notebook.GetAnnotations()[culler.STOP_ANNOTATION] == "" ||
+		notebook.GetAnnotations()[culler.STOP_ANNOTATION] == AnnotationValueReconciliationLock {
+		annotations[culler.STOP_ANNOTATION] = now
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Hibernating the notebook")
+	err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+	if err != nil {
+		log.Error(err, "Unable to hibernate the notebook")
+		return err
+	}
+	r.recordEvent(notebook, corev1.EventTypeNormal, "Hibernated",
+		"Notebook hibernated, its runtime metadata is kept in the %s ConfigMap", desired.Name)
+	return nil
+}
+
+// resumeNotebook restores the runtime metadata of the hibernated notebook,
+// restarts it and deletes its snapshot.
+func (r *OpenshiftNotebookReconciler) resumeNotebook(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	name := notebook.Name + HibernationConfigMapSuffix
+	snapshot := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: notebook.Namespace}, snapshot)
+	if apierrs.IsNotFound(err) {
+		log.Info("Hibernation ConfigMap not found, resuming the notebook as is", "name", name)
+		snapshot = nil
+	} else if err != nil {
+		return err
+	}
+
+	annotations := map[string]interface{}{
+		AnnotationHibernate:    nil,
+		AnnotationHibernated:   nil,
+		culler.STOP_ANNOTATION: nil,
+	}
+	message := "Notebook resumed"
+	if snapshot != nil {
+		// Restore the image selection, e.g. removed while the notebook was
+		// hibernated
+		selection := snapshot.Data[hibernationKeyImageSelection]
+		if selection != "" && notebook.GetAnnotations()[AnnotationLastImageSelection] == "" {
+			annotations[AnnotationLastImageSelection] = selection
+		}
+		message = fmt.Sprintf("Notebook hibernated at %s resumed", snapshot.Data[hibernationKeyHibernatedAt])
+		if changes := hibernationChanges(notebook, snapshot); len(changes) > 0 {
+			message += ", " + strings.Join(changes, " and ")
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Resuming the notebook")
+	err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+	if err != nil {
+		log.Error(err, "Unable to resume the notebook")
+		return err
+	}
+	r.recordEvent(notebook, corev1.EventTypeNormal, "Resumed", "%s", message)
+
+	return r.deleteControlledObject(ctx, notebook, name, &corev1.ConfigMap{})
+}
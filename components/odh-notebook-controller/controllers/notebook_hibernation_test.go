@@ -0,0 +1,119 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestValidateHibernationAnnotation(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{AnnotationHibernate: "true"},
+	}}
+	assert.NoError(t, ValidateHibernationAnnotation(notebook))
+	notebook.Annotations[AnnotationHibernate] = "later"
+	assert.Error(t, ValidateHibernationAnnotation(notebook))
+}
+
+func TestReconcileHibernation(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nb",
+			Namespace: "ns",
+			UID:       "uid",
+			Annotations: map[string]string{
+				AnnotationHibernate:          "true",
+				AnnotationLastImageSelection: "jupyter:2024.1",
+			},
+		},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "nb",
+				Image: "jupyter@sha256:1",
+				Env:   []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+			}},
+		}}},
+	}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	// The notebook is snapshotted and stopped
+	require.NoError(t, r.ReconcileHibernation(notebook, ctx))
+	found := &nbv1.Notebook{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.NotEmpty(t, found.Annotations[AnnotationHibernated])
+	assert.Equal(t, found.Annotations[AnnotationHibernated], found.Annotations[culler.STOP_ANNOTATION])
+	snapshot := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: "ns", Name: "nb" + HibernationConfigMapSuffix}
+	require.NoError(t, r.Get(ctx, key, snapshot))
+	assert.Equal(t, "jupyter@sha256:1", snapshot.Data[hibernationKeyImage])
+	assert.Equal(t, "jupyter:2024.1", snapshot.Data[hibernationKeyImageSelection])
+	assert.NotEmpty(t, snapshot.Data[hibernationKeyEnvHash])
+	assert.True(t, metav1.IsControlledBy(snapshot, notebook))
+	assert.Contains(t, <-recorder.Events, "Hibernated")
+
+	// The hibernated notebook is left alone
+	require.NoError(t, r.ReconcileHibernation(found, ctx))
+	assert.Empty(t, recorder.Events)
+
+	// The resumed notebook is restored, restarted and its changes reported
+	delete(found.Annotations, AnnotationHibernate)
+	delete(found.Annotations, AnnotationLastImageSelection)
+	found.Spec.Template.Spec.Containers[0].Image = "jupyter@sha256:2"
+	require.NoError(t, r.Update(ctx, found))
+	require.NoError(t, r.ReconcileHibernation(found, ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.NotContains(t, found.Annotations, AnnotationHibernated)
+	assert.NotContains(t, found.Annotations, culler.STOP_ANNOTATION)
+	assert.Equal(t, "jupyter:2024.1", found.Annotations[AnnotationLastImageSelection])
+	event := <-recorder.Events
+	assert.Contains(t, event, "Resumed")
+	assert.Contains(t, event, "the image changed from jupyter@sha256:1 to jupyter@sha256:2")
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, key, snapshot)))
+}
+
+func TestReconcileHibernationStartedNotebook(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name:      "nb",
+		Namespace: "ns",
+		Annotations: map[string]string{
+			AnnotationHibernate:  "true",
+			AnnotationHibernated: "2024-05-01T10:00:00Z",
+		},
+	}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+
+	// Starting the hibernated notebook resumes it
+	require.NoError(t, r.ReconcileHibernation(notebook, ctx))
+	found := &nbv1.Notebook{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.NotContains(t, found.Annotations, AnnotationHibernate)
+	assert.NotContains(t, found.Annotations, AnnotationHibernated)
+}
@@ -0,0 +1,164 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// AnnotationImageTagOutdated marks a tag of a workbench ImageStream as
+	// deprecated when set to true, as done by the dashboard for the previous
+	// versions of the images.
+	AnnotationImageTagOutdated = "opendatahub.io/image-tag-outdated"
+	// AnnotationImageTagEndOfLife is the date, e.g. 2025-06-30, after which a
+	// tag of a workbench ImageStream is no longer supported and may be
+	// removed.
+	AnnotationImageTagEndOfLife = "opendatahub.io/image-tag-end-of-life"
+
+	// AnnotationImageDeprecated reports the deprecation of the image selected
+	// by the notebook, recorded by the webhook from its ImageStream tag.
+	AnnotationImageDeprecated = "notebooks.opendatahub.io/image-deprecated"
+
+	// ConditionImageDeprecated reports that the notebook selects a
+	// deprecated image, to be migrated before the image is removed.
+	ConditionImageDeprecated = "notebooks.opendatahub.io/ImageDeprecated"
+)
+
+// ImageStreamNamespaces are the namespaces of the workbench ImageStreams the
+// image selections of the notebooks are resolved from.
+var ImageStreamNamespaces = []string{"opendatahub", "redhat-ods-applications"}
+
+// +kubebuilder:rbac:groups=image.openshift.io,resources=imagestreams,verbs=get;list
+
+// ImageTagDeprecation returns the deprecation message of the tag of the
+// ImageStream, empty if the tag is neither outdated nor past or approaching
+// its end of life.
+func ImageTagDeprecation(imagestream *unstructured.Unstructured, tag string, now time.Time) string {
+	tags, _, _ := unstructured.NestedSlice(imagestream.Object, "spec", "tags")
+	for _, t := range tags {
+		tagMap, ok := t.(map[string]interface{})
+		if !ok || tagMap["name"] != tag {
+			continue
+		}
+		annotations, _, _ := unstructured.NestedStringMap(tagMap, "annotations")
+		image := imagestream.GetName() + ":" + tag
+		if value := annotations[AnnotationImageTagEndOfLife]; value != "" {
+			endOfLife, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				endOfLife, err = time.Parse(time.RFC3339, value)
+			}
+			if err == nil && !now.Before(endOfLife) {
+				return fmt.Sprintf("The image %s reached its end of life on %s and may be removed, "+
+					"migrate the notebook to a supported image.", image, endOfLife.Format(time.DateOnly))
+			} else if err == nil {
+				return fmt.Sprintf("The image %s reaches its end of life on %s, "+
+					"migrate the notebook to a supported image before it is removed.", image,
+					endOfLife.Format(time.DateOnly))
+			}
+		}
+		if outdated, err := strconv.ParseBool(annotations[AnnotationImageTagOutdated]); err == nil && outdated {
+			return fmt.Sprintf("The image %s is deprecated, migrate the notebook to a supported image.", image)
+		}
+		return ""
+	}
+	return ""
+}
+
+// NotebookImageDeprecation returns the deprecation message of the image
+// selected by the notebook, empty if it is not deprecated or the notebook
+// selects no ImageStream tag.
+func NotebookImageDeprecation(ctx context.Context, dynamicClient dynamic.Interface, notebook *nbv1.Notebook,
+	log logr.Logger) (string, error) {
+	selection := notebook.GetAnnotations()[AnnotationLastImageSelection]
+	name, tag, found := strings.Cut(selection, ":")
+	if !found || name == "" || tag == "" {
+		return "", nil
+	}
+	for _, namespace := range notebookImageStreamNamespaces(notebook) {
+		imagestream, err := getImageStream(ctx, dynamicClient, namespace, name, log)
+		if err != nil {
+			return "", err
+		}
+		if imagestream != nil {
+			return ImageTagDeprecation(imagestream, tag, time.Now()), nil
+		}
+	}
+	return "", nil
+}
+
+// NewImageDeprecatedCondition returns the deprecation condition of the
+// notebook, nil if its image is not deprecated.
+func NewImageDeprecatedCondition(notebook *nbv1.Notebook) *nbv1.NotebookCondition {
+	message := notebook.GetAnnotations()[AnnotationImageDeprecated]
+	if message == "" {
+		return nil
+	}
+	return &nbv1.NotebookCondition{
+		Type:               ConditionImageDeprecated,
+		Status:             string(corev1.ConditionTrue),
+		Reason:             "ImageDeprecated",
+		Message:            message,
+		LastProbeTime:      metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+	}
+}
+
+// ReconcileImageDeprecation reports the deprecation of the image of the
+// notebook, recorded by the webhook, with an event and the
+// ConditionImageDeprecated condition of the notebook.
+func (r *OpenshiftNotebookReconciler) ReconcileImageDeprecation(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	desired := NewImageDeprecatedCondition(notebook)
+	conditions := []nbv1.NotebookCondition{}
+	var found *nbv1.NotebookCondition
+	for i, condition := range notebook.Status.Conditions {
+		if condition.Type == ConditionImageDeprecated {
+			found = &notebook.Status.Conditions[i]
+			continue
+		}
+		conditions = append(conditions, condition)
+	}
+	if found == nil && desired == nil || found != nil && desired != nil && found.Message == desired.Message {
+		return nil
+	}
+
+	if desired != nil {
+		log.Info("Notebook image deprecated", "message", desired.Message)
+		r.recordEvent(notebook, corev1.EventTypeWarning, desired.Reason, "%s", desired.Message)
+		conditions = append(conditions, *desired)
+	}
+	notebook.Status.Conditions = conditions
+	err := r.Status().Update(ctx, notebook)
+	if err != nil {
+		log.Error(err, "Unable to update the ImageDeprecated condition of the notebook")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newDeprecatedTestImageStream() *unstructured.Unstructured {
+	imagestream := newTestImageStream("opendatahub", "jupyter")
+	imagestream.Object["spec"] = map[string]interface{}{"tags": []interface{}{
+		map[string]interface{}{"name": "2023.1", "annotations": map[string]interface{}{
+			AnnotationImageTagOutdated: "true", AnnotationImageTagEndOfLife: "2024-06-30"}},
+		map[string]interface{}{"name": "2023.2", "annotations": map[string]interface{}{
+			AnnotationImageTagOutdated: "true"}},
+		map[string]interface{}{"name": "2024.1", "annotations": map[string]interface{}{
+			AnnotationImageTagEndOfLife: "2025-06-30"}},
+		map[string]interface{}{"name": "2024.2", "annotations": map[string]interface{}{
+			AnnotationImageTagOutdated: "false"}},
+	}}
+	return imagestream
+}
+
+func TestImageTagDeprecation(t *testing.T) {
+	imagestream := newDeprecatedTestImageStream()
+	now := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Contains(t, ImageTagDeprecation(imagestream, "2023.1", now),
+		"jupyter:2023.1 reached its end of life on 2024-06-30")
+	assert.Contains(t, ImageTagDeprecation(imagestream, "2023.2", now), "jupyter:2023.2 is deprecated")
+	assert.Contains(t, ImageTagDeprecation(imagestream, "2024.1", now),
+		"jupyter:2024.1 reaches its end of life on 2025-06-30")
+	assert.Empty(t, ImageTagDeprecation(imagestream, "2024.2", now))
+	assert.Empty(t, ImageTagDeprecation(imagestream, "2025.1", now), "missing tag")
+}
+
+func TestNotebookImageDeprecation(t *testing.T) {
+	ctx := context.Background()
+	dynamicClient := newTestDynamicClient(newDeprecatedTestImageStream())
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+		Annotations: map[string]string{AnnotationLastImageSelection: "jupyter:2023.2"}}}
+
+	deprecation, err := NotebookImageDeprecation(ctx, dynamicClient, notebook, logr.Discard())
+	require.NoError(t, err)
+	assert.Contains(t, deprecation, "jupyter:2023.2 is deprecated")
+
+	notebook.Annotations[AnnotationLastImageSelection] = "minimal:2024.1"
+	deprecation, err = NotebookImageDeprecation(ctx, dynamicClient, notebook, logr.Discard())
+	require.NoError(t, err)
+	assert.Empty(t, deprecation, "missing ImageStream")
+}
+
+func TestReconcileImageDeprecation(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+		Annotations: map[string]string{AnnotationImageDeprecated: "The image jupyter:2023.2 is deprecated."}}}
+	r := newTestReconciler(t, OAuthConfig{})
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(notebook).
+		WithStatusSubresource(&nbv1.Notebook{}).Build()
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	// The deprecation is reported once
+	require.NoError(t, r.ReconcileImageDeprecation(notebook, ctx))
+	require.NoError(t, r.ReconcileImageDeprecation(notebook, ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	require.Len(t, notebook.Status.Conditions, 1)
+	assert.Equal(t, ConditionImageDeprecated, notebook.Status.Conditions[0].Type)
+	assert.Contains(t, <-recorder.Events, "jupyter:2023.2 is deprecated")
+	assert.Len(t, recorder.Events, 0)
+
+	// The condition is removed once the notebook migrates
+	delete(notebook.Annotations, AnnotationImageDeprecated)
+	require.NoError(t, r.Update(ctx, notebook))
+	require.NoError(t, r.ReconcileImageDeprecation(notebook, ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	assert.Empty(t, notebook.Status.Conditions)
+}
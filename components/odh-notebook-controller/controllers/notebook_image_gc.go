@@ -0,0 +1,141 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"regexp"
+	"sync"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// LabelImageGCProtected marks the notebook pods whose images should be kept
+// by the node image garbage collection. The kubelet does not honor it, the
+// node tooling pinning images (e.g. CRI-O pinned images) selects the pods by
+// this label.
+const LabelImageGCProtected = "notebooks.opendatahub.io/image-gc-protected"
+
+// imagePulledPattern matches the message of the events of the kubelet
+// reporting an image pull, as opposed to an image already present.
+var imagePulledPattern = regexp.MustCompile(`^Successfully pulled image "([^"]+)"`)
+
+// ImagePulledEventsSelector selects the events reporting the image pulls, to
+// restrict the events cached by the manager.
+var ImagePulledEventsSelector = fields.OneTermEqualSelector("reason", "Pulled")
+
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch
+
+// InjectImageGCProtection labels the notebook pods for the protection of their
+// images from the node image garbage collection, or removes the label once the
+// protection is disabled. The notebook labels are copied to the pods, their
+// changes restart the running notebooks, hence they are only changed while the
+// notebook is stopped or being created.
+func InjectImageGCProtection(notebook *nbv1.Notebook, enabled bool) {
+	if !metav1.HasAnnotation(notebook.ObjectMeta, culler.STOP_ANNOTATION) {
+		return
+	}
+	if !enabled {
+		delete(notebook.Labels, LabelImageGCProtected)
+		return
+	}
+	if notebook.Labels == nil {
+		notebook.Labels = map[string]string{}
+	}
+	notebook.Labels[LabelImageGCProtected] = "true"
+}
+
+// ImagePullReconciler counts the image pulls of the notebook pods, reported by
+// the kubelet events.
+type ImagePullReconciler struct {
+	client.Client
+	Log logr.Logger
+
+	mu sync.Mutex
+	// counts holds the last count of the events already observed.
+	counts map[types.NamespacedName]int32
+}
+
+// Reconcile increments the image pull counter with the new occurrences of the
+// event.
+func (r *ImagePullReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	event := &corev1.Event{}
+	err := r.Get(ctx, req.NamespacedName, event)
+	if apierrs.IsNotFound(err) {
+		r.mu.Lock()
+		delete(r.counts, req.NamespacedName)
+		r.mu.Unlock()
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	match := imagePulledPattern.FindStringSubmatch(event.Message)
+	if match == nil {
+		return ctrl.Result{}, nil
+	}
+	pod := &corev1.Pod{}
+	err = r.Get(ctx, types.NamespacedName{Name: event.InvolvedObject.Name, Namespace: event.Namespace}, pod)
+	if apierrs.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	if _, ok := pod.Labels["notebook-name"]; !ok {
+		return ctrl.Result{}, nil
+	}
+
+	count := event.Count
+	if event.Series != nil && event.Series.Count > count {
+		count = event.Series.Count
+	}
+	if count < 1 {
+		count = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = map[types.NamespacedName]int32{}
+	}
+	if pulls := count - r.counts[req.NamespacedName]; pulls > 0 {
+		r.Log.V(1).Info("Notebook image pulled", "image", match[1], "pod", pod.Name, "namespace", pod.Namespace)
+		notebookImagePullsTotal.WithLabelValues(match[1]).Add(float64(pulls))
+	}
+	r.counts[req.NamespacedName] = count
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ImagePullReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("imagepull").
+		For(&corev1.Event{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			event, ok := o.(*corev1.Event)
+			return ok && event.Reason == "Pulled" && event.InvolvedObject.Kind == "Pod"
+		}))).
+		Complete(r)
+}
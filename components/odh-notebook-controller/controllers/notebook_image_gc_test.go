@@ -0,0 +1,94 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestInjectImageGCProtection(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+
+	// The labels of the running notebooks are left untouched
+	InjectImageGCProtection(notebook, true)
+	assert.NotContains(t, notebook.Labels, LabelImageGCProtected)
+
+	notebook.Annotations = map[string]string{culler.STOP_ANNOTATION: AnnotationValueReconciliationLock}
+	InjectImageGCProtection(notebook, true)
+	assert.Equal(t, "true", notebook.Labels[LabelImageGCProtected])
+
+	InjectImageGCProtection(notebook, false)
+	assert.NotContains(t, notebook.Labels, LabelImageGCProtected)
+}
+
+func TestImagePullReconciler(t *testing.T) {
+	ctx := context.Background()
+	image := "quay.io/test/workbench-image-pulls:1"
+	pulls := func() float64 {
+		metric := &dto.Metric{}
+		require.NoError(t, notebookImagePullsTotal.WithLabelValues(image).Write(metric))
+		return metric.GetCounter().GetValue()
+	}
+	notebookPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nb-0", Namespace: "ns",
+		Labels: map[string]string{"notebook-name": "nb"}}}
+	otherPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"}}
+	newEvent := func(name, pod, message string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: pod, Namespace: "ns"},
+			Reason:         "Pulled",
+			Message:        message,
+			Count:          1,
+		}
+	}
+	pulled := newEvent("pulled", "nb-0", `Successfully pulled image "`+image+`" in 1m2.3s`)
+	present := newEvent("present", "nb-0", `Container image "`+image+`" already present on machine`)
+	other := newEvent("other", "other", `Successfully pulled image "`+image+`" in 1s`)
+	c := newTestReconciler(t, OAuthConfig{}, notebookPod, otherPod, pulled, present, other).Client
+	r := &ImagePullReconciler{Client: c, Log: logr.Discard()}
+	reconcile := func(event *corev1.Event) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(event)})
+		require.NoError(t, err)
+	}
+
+	initial := pulls()
+	reconcile(pulled)
+	reconcile(present)
+	reconcile(other)
+	assert.Equal(t, initial+1, pulls())
+
+	// The event is observed again without new occurrence
+	reconcile(pulled)
+	assert.Equal(t, initial+1, pulls())
+
+	// The event is aggregated with new occurrences
+	pulled.Count = 3
+	require.NoError(t, c.Update(ctx, pulled))
+	reconcile(pulled)
+	assert.Equal(t, initial+3, pulls())
+}
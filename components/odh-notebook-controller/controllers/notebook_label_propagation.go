@@ -0,0 +1,166 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AnnotationPropagatedLabels records the comma-separated keys of the labels
+// of the notebook propagated by the controller to the generated objects. Only
+// those are removed from the generated objects when they are removed from the
+// notebook.
+const AnnotationPropagatedLabels = "notebooks.opendatahub.io/propagated-labels"
+
+// LabelPropagationConfig holds the labels of the notebooks propagated to the
+// objects generated for them, e.g. for the chargeback and the policy engines.
+type LabelPropagationConfig struct {
+	// Keys are the keys of the propagated labels, e.g. team or cost-center.
+	Keys []string
+}
+
+// Validate checks the keys of the propagated labels, which must not be the
+// ownership labels of the generated objects.
+func (c LabelPropagationConfig) Validate() error {
+	for _, key := range c.Keys {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid propagated label %q: %s", key, strings.Join(errs, ", "))
+		}
+		switch key {
+		case LabelNotebookName, LabelNotebookUID, LabelManagedBy, LabelComponent:
+			return fmt.Errorf("the label %s is set by the controller and cannot be propagated", key)
+		}
+	}
+	return nil
+}
+
+// InjectPropagatedLabels records the configured labels of the notebook
+// propagated to the objects generated for it. The pod needs none, the Kubeflow
+// notebook controller copying all the labels of the notebook to the pod
+// template of its StatefulSet.
+func InjectPropagatedLabels(notebook *nbv1.Notebook, config LabelPropagationConfig) {
+	delete(notebook.Annotations, AnnotationPropagatedLabels)
+	propagated := []string{}
+	for _, key := range config.Keys {
+		if _, found := notebook.GetLabels()[key]; found {
+			propagated = append(propagated, key)
+		}
+	}
+	if len(propagated) == 0 {
+		return
+	}
+	sort.Strings(propagated)
+	if notebook.Annotations == nil {
+		notebook.Annotations = map[string]string{}
+	}
+	notebook.Annotations[AnnotationPropagatedLabels] = strings.Join(propagated, ",")
+}
+
+// propagatedLabels returns the labels of the notebook propagated to the
+// objects generated for it.
+func propagatedLabels(notebook *nbv1.Notebook) map[string]string {
+	labels := map[string]string{}
+	value := notebook.GetAnnotations()[AnnotationPropagatedLabels]
+	if value == "" {
+		return labels
+	}
+	for _, key := range strings.Split(value, ",") {
+		if label, found := notebook.GetLabels()[key]; found {
+			labels[key] = label
+		}
+	}
+	return labels
+}
+
+// propagatedObjectLists returns the lists of the types of the objects
+// generated for the notebooks the labels are propagated to.
+func propagatedObjectLists() []client.ObjectList {
+	return []client.ObjectList{
+		&routev1.RouteList{},
+		&corev1.ServiceList{},
+		&corev1.ServiceAccountList{},
+		&corev1.SecretList{},
+		&corev1.ConfigMapList{},
+		&netv1.NetworkPolicyList{},
+		&rbacv1.RoleBindingList{},
+	}
+}
+
+// ReconcilePropagatedLabels propagates the labels of the notebook to the
+// objects generated for it, and removes the configured ones the notebook does
+// not have anymore. The objects created afterwards get the propagated labels
+// along with their ownership labels.
+func (r *OpenshiftNotebookReconciler) ReconcilePropagatedLabels(notebook *nbv1.Notebook,
+	ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	desired := propagatedLabels(notebook)
+	if len(r.LabelPropagationConfig.Keys) == 0 && len(desired) == 0 {
+		return nil
+	}
+	for _, list := range propagatedObjectLists() {
+		err := ListNotebookObjects(ctx, r.Client, notebook, list)
+		if err != nil {
+			log.Error(err, "Unable to list the notebook objects")
+			return err
+		}
+		objects, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range objects {
+			object, ok := item.(client.Object)
+			if !ok || !object.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+			patch := client.MergeFrom(object.DeepCopyObject().(client.Object))
+			changed := mergeLabels(object, desired)
+			labels := object.GetLabels()
+			for _, key := range r.LabelPropagationConfig.Keys {
+				if _, found := desired[key]; found {
+					continue
+				}
+				if _, exists := labels[key]; exists {
+					delete(labels, key)
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+			object.SetLabels(labels)
+			log.Info("Propagating the notebook labels", "kind", kindOf(r.Scheme, object), "name", object.GetName())
+			if err := r.Patch(ctx, object, patch); err != nil {
+				log.Error(err, "Unable to propagate the notebook labels")
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestLabelPropagationConfigValidate(t *testing.T) {
+	assert.NoError(t, LabelPropagationConfig{Keys: []string{"team", "example.com/cost-center"}}.Validate())
+	assert.Error(t, LabelPropagationConfig{Keys: []string{"not a label"}}.Validate())
+	assert.Error(t, LabelPropagationConfig{Keys: []string{LabelNotebookUID}}.Validate())
+}
+
+func TestInjectPropagatedLabels(t *testing.T) {
+	config := LabelPropagationConfig{Keys: []string{"team", "cost-center", "project"}}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name:   "nb",
+		Labels: map[string]string{"team": "data", "cost-center": "cc-1", "other": "value"},
+	}}
+
+	InjectPropagatedLabels(notebook, config)
+	assert.Equal(t, "cost-center,team", notebook.Annotations[AnnotationPropagatedLabels])
+	assert.Equal(t, map[string]string{"team": "data", "cost-center": "cc-1", LabelNotebookName: "nb",
+		LabelNotebookUID: "", LabelManagedBy: ManagedByValue, LabelComponent: ComponentRoute},
+		NotebookObjectLabels(notebook, ComponentRoute))
+
+	// The labels removed from the notebook are no longer recorded
+	delete(notebook.Labels, "team")
+	InjectPropagatedLabels(notebook, config)
+	assert.Equal(t, "cost-center", notebook.Annotations[AnnotationPropagatedLabels])
+
+	// The labels are no longer propagated once the configuration is removed
+	InjectPropagatedLabels(notebook, LabelPropagationConfig{})
+	assert.NotContains(t, notebook.Annotations, AnnotationPropagatedLabels)
+}
+
+func TestReconcilePropagatedLabels(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name:        "nb",
+		Namespace:   "ns",
+		UID:         "uid",
+		Labels:      map[string]string{"team": "data"},
+		Annotations: map[string]string{AnnotationPropagatedLabels: "team"},
+	}}
+	serviceLabels := NotebookObjectLabels(&nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", UID: "uid"}},
+		ComponentOAuthProxy)
+	serviceLabels["cost-center"] = "cc-1"
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "nb-tls", Namespace: "ns", Labels: serviceLabels}}
+	other := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook, service, other)
+	r.LabelPropagationConfig = LabelPropagationConfig{Keys: []string{"team", "cost-center"}}
+
+	require.NoError(t, r.ReconcilePropagatedLabels(notebook, ctx))
+	found := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(service), found))
+	assert.Equal(t, "data", found.Labels["team"])
+	assert.NotContains(t, found.Labels, "cost-center")
+	assert.Equal(t, ComponentOAuthProxy, found.Labels[LabelComponent])
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(other), found))
+	assert.Empty(t, found.Labels)
+}
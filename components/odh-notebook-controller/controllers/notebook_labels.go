@@ -0,0 +1,110 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LabelNotebookName and LabelNotebookUID identify the notebook owning the
+	// objects created by the controller. The UID tells apart the objects of a
+	// deleted notebook from the ones of a new notebook with the same name.
+	LabelNotebookName = "notebook-name"
+	LabelNotebookUID  = "notebooks.opendatahub.io/notebook-uid"
+	// LabelManagedBy is set to ManagedByValue on the objects created by the
+	// controller.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	ManagedByValue = "odh-notebook-controller"
+	// LabelComponent is the part of the notebook setup the object belongs to.
+	LabelComponent = "app.kubernetes.io/component"
+
+	ComponentRoute           = "route"
+	ComponentOAuthProxy      = "oauth-proxy"
+	ComponentNetworkPolicy   = "network-policy"
+	ComponentRBAC            = "rbac"
+	ComponentStorage         = "storage"
+	ComponentExposure        = "exposure"
+	ComponentPlacement       = "placement"
+	ComponentHibernation     = "hibernation"
+	ComponentStartupPage     = "startup-page"
+	ComponentSidecars        = "sidecars"
+	ComponentEffectiveConfig = "effective-config"
+)
+
+// NotebookObjectLabels returns the ownership labels of an object created by the
+// controller for the given notebook component, along with the propagated
+// labels of the notebook.
+func NotebookObjectLabels(notebook *nbv1.Notebook, component string) map[string]string {
+	labels := propagatedLabels(notebook)
+	labels[LabelNotebookName] = notebook.Name
+	labels[LabelNotebookUID] = string(notebook.UID)
+	labels[LabelManagedBy] = ManagedByValue
+	labels[LabelComponent] = component
+	return labels
+}
+
+// NotebookObjectsSelector selects the objects created by the controller for
+// the given notebook, whatever their component.
+func NotebookObjectsSelector(notebook *nbv1.Notebook) client.MatchingLabels {
+	return client.MatchingLabels{
+		LabelNotebookName: notebook.Name,
+		LabelNotebookUID:  string(notebook.UID),
+		LabelManagedBy:    ManagedByValue,
+	}
+}
+
+// ListNotebookObjects lists the objects of the given type created by the
+// controller for the notebook, optionally restricted to some components.
+func ListNotebookObjects(ctx context.Context, c client.Client, notebook *nbv1.Notebook,
+	list client.ObjectList, components ...string) error {
+	selector := labels.SelectorFromSet(labels.Set(NotebookObjectsSelector(notebook)))
+	if len(components) > 0 {
+		requirement, err := labels.NewRequirement(LabelComponent, selection.In, components)
+		if err != nil {
+			return err
+		}
+		selector = selector.Add(*requirement)
+	}
+	return c.List(ctx, list, client.InNamespace(notebook.Namespace), client.MatchingLabelsSelector{Selector: selector})
+}
+
+// mergeLabels sets the given labels on the object, keeping its other labels.
+// Returns true if the object labels changed.
+func mergeLabels(obj metav1.Object, desired map[string]string) bool {
+	current := obj.GetLabels()
+	changed := false
+	for key, value := range desired {
+		if existing, found := current[key]; found && existing == value {
+			continue
+		}
+		if current == nil {
+			current = map[string]string{}
+		}
+		current[key] = value
+		changed = true
+	}
+	if changed {
+		obj.SetLabels(current)
+	}
+	return changed
+}
@@ -0,0 +1,81 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestListNotebookObjects(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	previous := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "previous-uid"}}
+	previousNetworkPolicy := NewOAuthNetworkPolicy(previous)
+	previousNetworkPolicy.Name = "nb-previous-np"
+	r := newTestReconciler(t, OAuthConfig{},
+		NewNotebookOAuthService(notebook, OAuthConfig{}),
+		NewNotebookOAuthSecret(notebook),
+		NewNotebookNetworkPolicy(notebook),
+		NewOAuthNetworkPolicy(notebook),
+		previousNetworkPolicy,
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "ns",
+			Labels: map[string]string{LabelNotebookName: "nb"}}})
+
+	services := &corev1.ServiceList{}
+	require.NoError(t, ListNotebookObjects(ctx, r.Client, notebook, services))
+	require.Len(t, services.Items, 1)
+	assert.Equal(t, "nb-tls", services.Items[0].Name)
+
+	// The objects of a previous notebook with the same name are left out
+	networkPolicies := &netv1.NetworkPolicyList{}
+	require.NoError(t, ListNotebookObjects(ctx, r.Client, notebook, networkPolicies, ComponentNetworkPolicy))
+	assert.Len(t, networkPolicies.Items, 2)
+
+	secrets := &corev1.SecretList{}
+	require.NoError(t, ListNotebookObjects(ctx, r.Client, notebook, secrets, ComponentRoute, ComponentRBAC))
+	assert.Empty(t, secrets.Items)
+	require.NoError(t, ListNotebookObjects(ctx, r.Client, notebook, secrets, ComponentRoute, ComponentOAuthProxy))
+	assert.Len(t, secrets.Items, 1)
+}
+
+func TestMergeLabels(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", UID: "nb-uid"}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+		LabelNotebookName: "nb",
+		"user":            "value",
+	}}}
+
+	assert.True(t, mergeLabels(secret, NotebookObjectLabels(notebook, ComponentOAuthProxy)))
+	assert.Equal(t, map[string]string{
+		LabelNotebookName: "nb",
+		LabelNotebookUID:  "nb-uid",
+		LabelManagedBy:    ManagedByValue,
+		LabelComponent:    ComponentOAuthProxy,
+		"user":            "value",
+	}, secret.Labels)
+	assert.False(t, mergeLabels(secret, NotebookObjectLabels(notebook, ComponentOAuthProxy)))
+
+	// Labels set to an empty value
+	assert.True(t, mergeLabels(&corev1.Secret{}, map[string]string{LabelNotebookUID: ""}))
+}
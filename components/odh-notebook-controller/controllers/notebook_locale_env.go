@@ -0,0 +1,149 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// The controller image has no time zone database to validate the
+	// timezone annotations against
+	_ "time/tzdata"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationTimezone holds the IANA time zone of the notebook, e.g.
+	// Europe/Paris, exposed to the workbench as the TZ environment variable
+	// so that its scheduled jobs run in the local time of the users.
+	AnnotationTimezone = "notebooks.opendatahub.io/timezone"
+	// AnnotationLocale holds the locale of the notebook, e.g. en_US.UTF-8,
+	// exposed to the workbench as the LANG environment variable. Only the
+	// locales allowed by the controller may be set.
+	AnnotationLocale = "notebooks.opendatahub.io/locale"
+
+	EnvTimezone = "TZ"
+	EnvLocale   = "LANG"
+)
+
+// DefaultAllowedLocales are the locales the notebooks may set by default,
+// the ones installed in the workbench images.
+var DefaultAllowedLocales = []string{"C.UTF-8", "en_US.UTF-8"}
+
+// ValidateTimezone checks that the time zone is an IANA time zone.
+func ValidateTimezone(timezone string) error {
+	// The local time zone of the controller is meaningless to the notebooks
+	if timezone == "" || timezone == "Local" {
+		return fmt.Errorf("invalid time zone %q", timezone)
+	}
+	_, err := time.LoadLocation(timezone)
+	return err
+}
+
+// ValidateAllowedLocales checks the locales the notebooks may set.
+func ValidateAllowedLocales(locales []string) error {
+	for _, locale := range locales {
+		if locale == "" || strings.ContainsAny(locale, " =,") {
+			return fmt.Errorf("invalid locale %q", locale)
+		}
+	}
+	return nil
+}
+
+// NewLocaleEnv returns the environment variables derived from the timezone
+// and locale annotations of the notebook. The locale must be one of the
+// allowed ones, DefaultAllowedLocales if nil.
+func NewLocaleEnv(notebook *nbv1.Notebook, allowedLocales []string) ([]corev1.EnvVar, error) {
+	envVars := []corev1.EnvVar{}
+	annotations := notebook.GetAnnotations()
+
+	if timezone, ok := annotations[AnnotationTimezone]; ok {
+		if err := ValidateTimezone(timezone); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationTimezone, err)
+		}
+		envVars = append(envVars, corev1.EnvVar{Name: EnvTimezone, Value: timezone})
+	}
+
+	if locale, ok := annotations[AnnotationLocale]; ok {
+		if allowedLocales == nil {
+			allowedLocales = DefaultAllowedLocales
+		}
+		allowed := false
+		for _, allowedLocale := range allowedLocales {
+			allowed = allowed || locale == allowedLocale
+		}
+		if !allowed {
+			return nil, fmt.Errorf("invalid %s annotation: the locale %q is not one of %s", AnnotationLocale,
+				locale, strings.Join(allowedLocales, ", "))
+		}
+		envVars = append(envVars, corev1.EnvVar{Name: EnvLocale, Value: locale})
+	}
+
+	return envVars, nil
+}
+
+// InjectLocaleEnv sets the TZ and LANG environment variables of the notebook
+// container from its timezone and locale annotations. The variables are only
+// removed when their annotation is removed, so that the ones set in the
+// notebook spec are kept. The old notebook is nil on creation.
+func InjectLocaleEnv(notebook, oldNotebook *nbv1.Notebook, allowedLocales []string) error {
+	envVars, err := NewLocaleEnv(notebook, allowedLocales)
+	if err != nil {
+		return err
+	}
+	removed := map[string]bool{}
+	if oldNotebook != nil {
+		for annotation, name := range map[string]string{AnnotationTimezone: EnvTimezone, AnnotationLocale: EnvLocale} {
+			_, ok := notebook.GetAnnotations()[annotation]
+			_, oldOk := oldNotebook.GetAnnotations()[annotation]
+			removed[name] = oldOk && !ok
+		}
+	}
+
+	container := notebookContainer(notebook)
+	if container == nil {
+		if len(envVars) == 0 {
+			return nil
+		}
+		return fmt.Errorf("notebook image container not found %v", notebook.Name)
+	}
+	env := []corev1.EnvVar{}
+	for _, envVar := range container.Env {
+		if !removed[envVar.Name] {
+			env = append(env, envVar)
+		}
+	}
+	for _, envVar := range envVars {
+		envExists := false
+		for i := range env {
+			if env[i].Name == envVar.Name {
+				env[i] = envVar
+				envExists = true
+				break
+			}
+		}
+		if !envExists {
+			env = append(env, envVar)
+		}
+	}
+	if len(env) == 0 {
+		env = nil
+	}
+	container.Env = env
+	return nil
+}
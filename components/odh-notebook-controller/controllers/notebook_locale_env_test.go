@@ -0,0 +1,81 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewLocaleEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		annotations map[string]string
+		allowed     []string
+		expected    []corev1.EnvVar
+		invalid     bool
+	}{
+		{name: "none", expected: []corev1.EnvVar{}},
+		{name: "timezone and locale",
+			annotations: map[string]string{AnnotationTimezone: "Europe/Paris", AnnotationLocale: "en_US.UTF-8"},
+			expected:    []corev1.EnvVar{{Name: EnvTimezone, Value: "Europe/Paris"}, {Name: EnvLocale, Value: "en_US.UTF-8"}}},
+		{name: "allowed locale", annotations: map[string]string{AnnotationLocale: "fr_FR.UTF-8"},
+			allowed: []string{"fr_FR.UTF-8"}, expected: []corev1.EnvVar{{Name: EnvLocale, Value: "fr_FR.UTF-8"}}},
+		{name: "unknown timezone", annotations: map[string]string{AnnotationTimezone: "Europe/Atlantis"}, invalid: true},
+		{name: "local timezone", annotations: map[string]string{AnnotationTimezone: "Local"}, invalid: true},
+		{name: "locale not allowed", annotations: map[string]string{AnnotationLocale: "fr_FR.UTF-8"}, invalid: true},
+		{name: "no locale allowed", annotations: map[string]string{AnnotationLocale: "C.UTF-8"},
+			allowed: []string{}, invalid: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Annotations: tt.annotations}}
+			envVars, err := NewLocaleEnv(notebook, tt.allowed)
+			if tt.invalid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, envVars)
+		})
+	}
+}
+
+func TestInjectLocaleEnv(t *testing.T) {
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb",
+			Annotations: map[string]string{AnnotationTimezone: "America/New_York"}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb", Env: []corev1.EnvVar{
+				{Name: EnvTimezone, Value: "UTC"}, {Name: EnvLocale, Value: "C.UTF-8"}}}},
+		}}},
+	}
+
+	require.NoError(t, InjectLocaleEnv(notebook, nil, nil))
+	assert.Equal(t, []corev1.EnvVar{{Name: EnvTimezone, Value: "America/New_York"}, {Name: EnvLocale, Value: "C.UTF-8"}},
+		notebook.Spec.Template.Spec.Containers[0].Env)
+
+	// The variables are removed with their annotation, the ones of the spec
+	// are kept
+	oldNotebook := notebook.DeepCopy()
+	delete(notebook.Annotations, AnnotationTimezone)
+	require.NoError(t, InjectLocaleEnv(notebook, oldNotebook, nil))
+	assert.Equal(t, []corev1.EnvVar{{Name: EnvLocale, Value: "C.UTF-8"}}, notebook.Spec.Template.Spec.Containers[0].Env)
+}
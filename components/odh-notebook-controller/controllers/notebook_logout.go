@@ -0,0 +1,143 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LogoutDefaultConsole defaults the logout URL of the notebooks to the
+	// logout redirect of the OpenShift console, or the console itself.
+	LogoutDefaultConsole = "console"
+	// LogoutDefaultDashboard defaults the logout URL of the notebooks to the
+	// sign out endpoint of the OAuth proxy of the dashboard.
+	LogoutDefaultDashboard = "dashboard"
+
+	// consoleConfigName is the name of the cluster Console configuration.
+	consoleConfigName = "cluster"
+	// dashboardSignOutPath is the sign out endpoint of the OAuth proxy
+	// protecting the dashboard.
+	dashboardSignOutPath = "/oauth/sign_out"
+)
+
+// +kubebuilder:rbac:groups=config.openshift.io,resources=consoles,verbs=get;list;watch
+
+// LogoutConfig configures the logout URL of the OAuth proxy of the notebooks
+// without logout annotation, so that the users logging out of a notebook are
+// also logged out of the OpenShift console or the dashboard.
+type LogoutConfig struct {
+	// Default is the source of the default logout URL, LogoutDefaultConsole
+	// or LogoutDefaultDashboard, no default if empty.
+	Default string
+	// DashboardRoute is the Route of the dashboard, required by
+	// LogoutDefaultDashboard.
+	DashboardRoute types.NamespacedName
+}
+
+// ParseLogoutConfig parses the source of the default logout URL and the
+// <namespace>/<name> of the dashboard Route.
+func ParseLogoutConfig(source, dashboardRoute string) (LogoutConfig, error) {
+	config := LogoutConfig{Default: source}
+	switch source {
+	case "", LogoutDefaultConsole:
+	case LogoutDefaultDashboard:
+		namespace, name, ok := strings.Cut(dashboardRoute, "/")
+		if !ok || namespace == "" || name == "" {
+			return config, fmt.Errorf("invalid dashboard Route %q, expected <namespace>/<name>", dashboardRoute)
+		}
+		config.DashboardRoute = types.NamespacedName{Namespace: namespace, Name: name}
+	default:
+		return config, fmt.Errorf("invalid default logout URL %q, expected %s or %s", source,
+			LogoutDefaultConsole, LogoutDefaultDashboard)
+	}
+	return config, nil
+}
+
+// DefaultLogoutURL returns the logout URL of the notebooks without logout
+// annotation, empty if there is none.
+func (c LogoutConfig) DefaultLogoutURL(ctx context.Context, reader client.Reader) (string, error) {
+	switch c.Default {
+	case LogoutDefaultConsole:
+		console := &configv1.Console{}
+		if err := reader.Get(ctx, types.NamespacedName{Name: consoleConfigName}, console); err != nil {
+			return "", err
+		}
+		if redirect := console.Spec.Authentication.LogoutRedirect; redirect != "" {
+			return normalizeLogoutURL(redirect)
+		}
+		if console.Status.ConsoleURL == "" {
+			return "", fmt.Errorf("the URL of the OpenShift console is not known yet")
+		}
+		return normalizeLogoutURL(console.Status.ConsoleURL)
+	case LogoutDefaultDashboard:
+		route := &routev1.Route{}
+		if err := reader.Get(ctx, c.DashboardRoute, route); err != nil {
+			return "", err
+		}
+		host := admittedRouteHost(route)
+		if host == "" {
+			return "", fmt.Errorf("the Route %s of the dashboard has no host", c.DashboardRoute)
+		}
+		return logoutURL(host, dashboardSignOutPath), nil
+	}
+	return "", nil
+}
+
+// admittedRouteHost returns the host the Route is served on, which is the one
+// the browsers send in the Host header: the host admitted by a router, e.g.
+// a custom domain of a router shard, or the host of its spec.
+func admittedRouteHost(route *routev1.Route) string {
+	for _, ingress := range route.Status.Ingress {
+		for _, condition := range ingress.Conditions {
+			if condition.Type == routev1.RouteAdmitted && condition.Status == "True" && ingress.Host != "" {
+				return ingress.Host
+			}
+		}
+	}
+	return route.Spec.Host
+}
+
+// logoutURL returns the HTTPS URL of the path on the host, which may be an
+// IPv6 address with or without brackets, and a port.
+func logoutURL(host, path string) string {
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil && strings.Contains(host, ":") {
+		host = "[" + strings.Trim(host, "[]") + "]"
+	}
+	return (&url.URL{Scheme: "https", Host: host, Path: path}).String()
+}
+
+// normalizeLogoutURL checks the logout URL is an absolute HTTP(S) URL and
+// returns it in its canonical form.
+func normalizeLogoutURL(value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid logout URL %q: %w", value, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return "", fmt.Errorf("invalid logout URL %q, expected an absolute HTTP(S) URL", value)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,115 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	configv1 "github.com/openshift/api/config/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDefaultLogoutURL(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, configv1.AddToScheme(scheme))
+	require.NoError(t, routev1.AddToScheme(scheme))
+	newClient := func(objects ...client.Object) client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	}
+	dashboardRoute := func(specHost, admittedHost string) *routev1.Route {
+		route := &routev1.Route{
+			ObjectMeta: metav1.ObjectMeta{Name: "odh-dashboard", Namespace: "opendatahub"},
+			Spec:       routev1.RouteSpec{Host: specHost},
+		}
+		if admittedHost != "" {
+			route.Status.Ingress = []routev1.RouteIngress{{Host: admittedHost, Conditions: []routev1.RouteIngressCondition{
+				{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue},
+			}}}
+		}
+		return route
+	}
+	console := func(redirect, consoleURL string) *configv1.Console {
+		return &configv1.Console{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+			Spec:       configv1.ConsoleSpec{Authentication: configv1.ConsoleAuthentication{LogoutRedirect: redirect}},
+			Status:     configv1.ConsoleStatus{ConsoleURL: consoleURL},
+		}
+	}
+
+	dashboard, err := ParseLogoutConfig(LogoutDefaultDashboard, "opendatahub/odh-dashboard")
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		config   LogoutConfig
+		objects  []client.Object
+		expected string
+	}{
+		{"no default", LogoutConfig{}, nil, ""},
+		{"console logout redirect", LogoutConfig{Default: LogoutDefaultConsole},
+			[]client.Object{console("https://sso.example.com/logout", "https://console.apps.example.com")},
+			"https://sso.example.com/logout"},
+		{"console URL", LogoutConfig{Default: LogoutDefaultConsole},
+			[]client.Object{console("", "https://console.apps.example.com")}, "https://console.apps.example.com"},
+		{"dashboard spec host", dashboard, []client.Object{dashboardRoute("dashboard.apps.example.com", "")},
+			"https://dashboard.apps.example.com/oauth/sign_out"},
+		{"dashboard admitted host", dashboard,
+			[]client.Object{dashboardRoute("dashboard.apps.example.com", "dashboard.shard.example.com")},
+			"https://dashboard.shard.example.com/oauth/sign_out"},
+		{"dashboard IPv6 host", dashboard, []client.Object{dashboardRoute("fd00::10", "")},
+			"https://[fd00::10]/oauth/sign_out"},
+		{"dashboard IPv6 host and port", dashboard, []client.Object{dashboardRoute("[fd00::10]:8443", "")},
+			"https://[fd00::10]:8443/oauth/sign_out"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, err := tt.config.DefaultLogoutURL(ctx, newClient(tt.objects...))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, url)
+		})
+	}
+
+	_, err = LogoutConfig{Default: LogoutDefaultConsole}.DefaultLogoutURL(ctx,
+		newClient(console("javascript:alert(1)", "")))
+	assert.Error(t, err)
+	_, err = ParseLogoutConfig(LogoutDefaultDashboard, "odh-dashboard")
+	assert.Error(t, err)
+	_, err = ParseLogoutConfig("oauth", "")
+	assert.Error(t, err)
+}
+
+func TestNewProxyArgsDefaultLogoutURL(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	oauth := OAuthConfig{SARTemplate: DefaultOAuthSARTemplate, DefaultLogoutURL: "https://console.example.com"}
+
+	args, err := NewProxyArgs(notebook, oauth)
+	require.NoError(t, err)
+	assert.Equal(t, "https://console.example.com", args.LogoutURL)
+
+	notebook.Annotations = map[string]string{AnnotationLogoutUrl: "https://dashboard.example.com/logout"}
+	args, err = NewProxyArgs(notebook, oauth)
+	require.NoError(t, err)
+	assert.Equal(t, "https://dashboard.example.com/logout", args.LogoutURL)
+}
@@ -0,0 +1,396 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// The operations of the management API.
+const (
+	ManagementOperationStart               = "start"
+	ManagementOperationStop                = "stop"
+	ManagementOperationRestart             = "restart"
+	ManagementOperationApplyPendingUpdates = "apply-pending-updates"
+	ManagementOperationConfig              = "config"
+)
+
+// managementAPIPrefix is the path prefix of the notebooks of the management
+// API: /apis/v1/namespaces/<namespace>/notebooks/<name>/<operation>.
+const managementAPIPrefix = "/apis/v1/namespaces/"
+
+// ManagementRequest is a request of the management API, authorized as the
+// given verb on the notebook.
+type ManagementRequest struct {
+	Notebook  types.NamespacedName
+	Operation string
+	Verb      string
+}
+
+// ManagementAuthorizer authenticates the bearer token of a request of the
+// management API and authorizes its user, it returns an empty username if
+// the token is not authenticated.
+type ManagementAuthorizer interface {
+	Authorize(ctx context.Context, token string, req ManagementRequest) (username string, allowed bool, err error)
+}
+
+// ReviewAuthorizer authorizes the requests of the management API as the
+// requests of the notebooks API: the token is authenticated by a
+// TokenReview, and the user must be allowed the verb on the notebook by a
+// SubjectAccessReview.
+type ReviewAuthorizer struct {
+	Client client.Client
+}
+
+// Authorize authenticates the token and authorizes its user.
+func (a *ReviewAuthorizer) Authorize(ctx context.Context, token string, req ManagementRequest) (string, bool,
+	error) {
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := a.Client.Create(ctx, review); err != nil {
+		return "", false, err
+	}
+	if !review.Status.Authenticated {
+		return "", false, nil
+	}
+	user := review.Status.User
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	access := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:   user.Username,
+		UID:    user.UID,
+		Groups: user.Groups,
+		Extra:  extra,
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: req.Notebook.Namespace,
+			Verb:      req.Verb,
+			Group:     nbv1.GroupVersion.Group,
+			Resource:  "notebooks",
+			Name:      req.Notebook.Name,
+		},
+	}}
+	if err := a.Client.Create(ctx, access); err != nil {
+		return user.Username, false, err
+	}
+	return user.Username, access.Status.Allowed, nil
+}
+
+// ManagementAPIServer serves the management API of the notebooks, so that
+// the CLIs and the automation start, stop and restart the notebooks without
+// patching their annotations. The requests are authorized as the requests of
+// the notebooks API, then issued by the controller.
+type ManagementAPIServer struct {
+	Client client.Client
+	Log    logr.Logger
+	// Recorder records the operations of the users on the notebooks.
+	Recorder record.EventRecorder
+	// Authorizer authorizes the requests.
+	Authorizer ManagementAuthorizer
+	// RestartPolicy restarts the notebooks.
+	RestartPolicy RestartPolicy
+	// BindAddress is the address the API is served on.
+	BindAddress string
+	// CertDir holds the tls.crt and tls.key serving certificate of the API.
+	CertDir string
+}
+
+// ManagementResponse is the response of the operations of the management
+// API.
+type ManagementResponse struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Operation string `json:"operation"`
+	// Changed is false if the notebook was already in the requested state.
+	Changed bool   `json:"changed"`
+	Message string `json:"message,omitempty"`
+}
+
+// EffectiveConfig is the configuration of a notebook applied by the webhook
+// and the controller, returned by the config operation.
+type EffectiveConfig struct {
+	Namespace   string   `json:"namespace"`
+	Name        string   `json:"name"`
+	State       string   `json:"state"`
+	Container   string   `json:"container"`
+	Image       string   `json:"image,omitempty"`
+	Sidecars    []string `json:"sidecars,omitempty"`
+	OAuth       bool     `json:"oauth"`
+	ServiceMesh bool     `json:"serviceMesh"`
+	Exposed     bool     `json:"exposed"`
+	// Settings holds the annotations of the users recognized by the
+	// controller.
+	Settings map[string]string `json:"settings,omitempty"`
+	// PendingUpdates holds the changes applied on the next restart of the
+	// notebook.
+	PendingUpdates json.RawMessage `json:"pendingUpdates,omitempty"`
+}
+
+// managementError is an error of the management API with its HTTP status.
+type managementError struct {
+	status  int
+	message string
+}
+
+func (e *managementError) Error() string {
+	return e.message
+}
+
+// parseManagementPath returns the notebook and the operation of the path.
+func parseManagementPath(path string) (types.NamespacedName, string, bool) {
+	if !strings.HasPrefix(path, managementAPIPrefix) {
+		return types.NamespacedName{}, "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(path, managementAPIPrefix), "/")
+	if len(parts) != 4 || parts[0] == "" || parts[1] != "notebooks" || parts[2] == "" {
+		return types.NamespacedName{}, "", false
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[2]}, parts[3], true
+}
+
+// ServeHTTP serves the operations of the management API.
+func (s *ManagementAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, operation, ok := parseManagementPath(r.URL.Path)
+	if !ok {
+		writeManagementError(w, &managementError{http.StatusNotFound, "not found"})
+		return
+	}
+	method, verb := http.MethodPost, "patch"
+	switch operation {
+	case ManagementOperationConfig:
+		method, verb = http.MethodGet, "get"
+	case ManagementOperationStart, ManagementOperationStop, ManagementOperationRestart,
+		ManagementOperationApplyPendingUpdates:
+	default:
+		writeManagementError(w, &managementError{http.StatusNotFound,
+			fmt.Sprintf("unknown operation %q", operation)})
+		return
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeManagementError(w, &managementError{http.StatusMethodNotAllowed,
+			fmt.Sprintf("the %s operation requires the %s method", operation, method)})
+		return
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		writeManagementError(w, &managementError{http.StatusUnauthorized, "missing bearer token"})
+		return
+	}
+	ctx := r.Context()
+	req := ManagementRequest{Notebook: key, Operation: operation, Verb: verb}
+	username, allowed, err := s.Authorizer.Authorize(ctx, token, req)
+	if err != nil {
+		s.Log.Error(err, "Unable to authorize the management API request")
+		writeManagementError(w, &managementError{http.StatusInternalServerError, "unable to authorize the request"})
+		return
+	} else if username == "" {
+		writeManagementError(w, &managementError{http.StatusUnauthorized, "invalid bearer token"})
+		return
+	} else if !allowed {
+		writeManagementError(w, &managementError{http.StatusForbidden, fmt.Sprintf(
+			"user %s cannot %s the notebook %s in the namespace %s", username, verb, key.Name, key.Namespace)})
+		return
+	}
+
+	notebook := &nbv1.Notebook{}
+	err = s.Client.Get(ctx, key, notebook)
+	if apierrs.IsNotFound(err) {
+		writeManagementError(w, &managementError{http.StatusNotFound,
+			fmt.Sprintf("notebook %s not found in the namespace %s", key.Name, key.Namespace)})
+		return
+	} else if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	var response interface{}
+	if operation == ManagementOperationConfig {
+		response = NotebookEffectiveConfig(notebook)
+	} else {
+		response, err = s.apply(ctx, notebook, operation, username)
+		if err != nil {
+			writeManagementError(w, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// apply applies the operation of the user to the notebook.
+func (s *ManagementAPIServer) apply(ctx context.Context, notebook *nbv1.Notebook, operation,
+	username string) (*ManagementResponse, error) {
+	response := &ManagementResponse{Namespace: notebook.Namespace, Name: notebook.Name, Operation: operation}
+	annotations := notebook.GetAnnotations()
+	stopped := notebookIsStopped(notebook.ObjectMeta)
+
+	var err error
+	switch operation {
+	case ManagementOperationStart:
+		if !stopped {
+			response.Message = "the notebook is already started"
+			return response, nil
+		}
+		if annotations[culler.STOP_ANNOTATION] == AnnotationValueReconciliationLock {
+			return nil, &managementError{http.StatusConflict, "the notebook is being started by the controller"}
+		}
+		err = s.patchAnnotations(ctx, notebook, map[string]interface{}{culler.STOP_ANNOTATION: nil})
+	case ManagementOperationStop:
+		if stopped {
+			response.Message = "the notebook is already stopped"
+			return response, nil
+		}
+		err = s.patchAnnotations(ctx, notebook, map[string]interface{}{
+			culler.STOP_ANNOTATION: time.Now().UTC().Format(time.RFC3339),
+		})
+	case ManagementOperationRestart:
+		if stopped {
+			return nil, &managementError{http.StatusConflict, "the notebook is stopped, start it instead"}
+		}
+		err = s.RestartPolicy.Restart(ctx, s.Client, notebook, nil)
+	case ManagementOperationApplyPendingUpdates:
+		if stopped || annotations[AnnotationUpdatePending] == "" {
+			response.Message = "the notebook has no pending updates"
+			return response, nil
+		}
+		// The pending updates are applied by the webhook on the updates
+		// restarting the notebook, rather than by deleting its pod
+		err = RestartPolicy{}.Restart(ctx, s.Client, notebook, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log.Info("Applied a management API operation", "notebook", notebook.Name, "namespace", notebook.Namespace,
+		"operation", operation, "username", username)
+	if s.Recorder != nil {
+		s.Recorder.Eventf(notebook, corev1.EventTypeNormal, "ManagementOperation",
+			"The %s operation was requested by %s through the management API", operation, username)
+	}
+	response.Changed = true
+	return response, nil
+}
+
+// patchAnnotations patches the annotations of the notebook, the nil values
+// remove the annotations.
+func (s *ManagementAPIServer) patchAnnotations(ctx context.Context, notebook *nbv1.Notebook,
+	annotations map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	return s.Client.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+}
+
+// NotebookEffectiveConfig returns the effective configuration of the
+// notebook.
+func NotebookEffectiveConfig(notebook *nbv1.Notebook) *EffectiveConfig {
+	config := &EffectiveConfig{
+		Namespace:   notebook.Namespace,
+		Name:        notebook.Name,
+		State:       NotebookState(notebook),
+		Container:   PrimaryContainerName(notebook),
+		Sidecars:    SidecarContainerNames(notebook),
+		OAuth:       OAuthInjectionIsEnabled(notebook.ObjectMeta),
+		ServiceMesh: ServiceMeshIsEnabled(notebook.ObjectMeta),
+		Exposed:     ExposureIsEnabled(notebook.ObjectMeta),
+		Settings:    map[string]string{},
+	}
+	for _, container := range notebook.Spec.Template.Spec.Containers {
+		if container.Name == config.Container {
+			config.Image = container.Image
+		}
+	}
+	for key, value := range notebook.GetAnnotations() {
+		if spec := annotationSpec(AnnotationTargetNotebook, key); spec != nil && !spec.ControllerOwned {
+			config.Settings[key] = value
+		}
+	}
+	if pending := notebook.GetAnnotations()[AnnotationUpdatePending]; pending != "" {
+		if json.Valid([]byte(pending)) {
+			config.PendingUpdates = json.RawMessage(pending)
+		} else {
+			config.PendingUpdates, _ = json.Marshal(pending)
+		}
+	}
+	return config
+}
+
+// writeManagementError writes the error as a JSON response.
+func writeManagementError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var managementErr *managementError
+	var statusErr apierrs.APIStatus
+	if errors.As(err, &managementErr) {
+		status = managementErr.status
+	} else if errors.As(err, &statusErr) {
+		status = int(statusErr.Status().Code)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// Start serves the management API over TLS until the context is cancelled.
+func (s *ManagementAPIServer) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.BindAddress,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	s.Log.Info("Serving the notebook management API", "address", s.BindAddress)
+	err := server.ListenAndServeTLS(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection serves the management API from all the replicas.
+func (s *ManagementAPIServer) NeedLeaderElection() bool {
+	return false
+}
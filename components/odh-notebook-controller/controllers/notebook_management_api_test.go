@@ -0,0 +1,124 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// testAuthorizer authenticates the token "alice", allowed to get the
+// notebooks, and the token "admin", allowed everything.
+type testAuthorizer struct{}
+
+func (testAuthorizer) Authorize(_ context.Context, token string, req ManagementRequest) (string, bool, error) {
+	switch token {
+	case "admin":
+		return "admin", true, nil
+	case "alice":
+		return "alice", req.Verb == "get", nil
+	}
+	return "", false, nil
+}
+
+func TestParseManagementPath(t *testing.T) {
+	key, operation, ok := parseManagementPath("/apis/v1/namespaces/ns/notebooks/nb/stop")
+	assert.True(t, ok)
+	assert.Equal(t, types.NamespacedName{Namespace: "ns", Name: "nb"}, key)
+	assert.Equal(t, ManagementOperationStop, operation)
+
+	for _, path := range []string{"/", "/apis/v1/namespaces/ns/notebooks/nb", "/apis/v1/namespaces/ns/pods/nb/stop",
+		"/apis/v1/namespaces//notebooks/nb/stop", "/apis/v1/namespaces/ns/notebooks/nb/stop/now"} {
+		_, _, ok := parseManagementPath(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestManagementAPIServer(t *testing.T) {
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", Annotations: map[string]string{
+			AnnotationInjectOAuth: "true",
+		}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb", Image: "jupyter:1"}},
+		}}},
+	}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	server := &ManagementAPIServer{Client: r.Client, Log: logr.Discard(), Authorizer: testAuthorizer{}}
+	request := func(method, operation, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/apis/v1/namespaces/ns/notebooks/nb/"+operation, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+	fetch := func() *nbv1.Notebook {
+		found := &nbv1.Notebook{}
+		require.NoError(t, r.Client.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: "nb"},
+			found))
+		return found
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "stop", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "stop", "unknown").Code)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "stop", "alice").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "stop", "admin").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "delete", "admin").Code)
+
+	// The users allowed to get the notebook get its effective configuration
+	response := request(http.MethodGet, "config", "alice")
+	require.Equal(t, http.StatusOK, response.Code)
+	config := &EffectiveConfig{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), config))
+	assert.Equal(t, "jupyter:1", config.Image)
+	assert.True(t, config.OAuth)
+	assert.Equal(t, "true", config.Settings[AnnotationInjectOAuth])
+
+	// Stop, then start the notebook
+	response = request(http.MethodPost, "stop", "admin")
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, fetch().Annotations, culler.STOP_ANNOTATION)
+	result := &ManagementResponse{}
+	require.NoError(t, json.Unmarshal(request(http.MethodPost, "stop", "admin").Body.Bytes(), result))
+	assert.False(t, result.Changed)
+	assert.Equal(t, http.StatusConflict, request(http.MethodPost, "restart", "admin").Code)
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "start", "admin").Code)
+	assert.NotContains(t, fetch().Annotations, culler.STOP_ANNOTATION)
+
+	// The pending updates are applied by restarting the notebook
+	require.NoError(t, json.Unmarshal(request(http.MethodPost, "apply-pending-updates", "admin").Body.Bytes(),
+		result))
+	assert.False(t, result.Changed)
+	pending := fetch()
+	pending.Annotations[AnnotationUpdatePending] = `{"changes":[]}`
+	require.NoError(t, r.Client.Update(context.Background(), pending))
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "apply-pending-updates", "admin").Code)
+	assert.Equal(t, "true", fetch().Annotations[AnnotationNotebookRestart])
+}
@@ -0,0 +1,300 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// clientThrottlingSeconds observes the time spent by API requests waiting
+	// on the client-side rate limiter.
+	clientThrottlingSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "odh_notebook_controller_client_throttling_seconds",
+			Help:    "Time spent by API requests waiting on the client-side rate limiter",
+			Buckets: []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+	)
+
+	// controllerCacheObjects reports the number of objects of the cache of
+	// the controller by kind.
+	controllerCacheObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_controller_cache_objects",
+			Help: "Number of objects of the cache of the controller by kind",
+		},
+		[]string{"kind"},
+	)
+
+	// controllerCacheEstimatedBytes reports the estimated memory of the
+	// objects of the cache of the controller by kind.
+	controllerCacheEstimatedBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_controller_cache_estimated_bytes",
+			Help: "Estimated memory in bytes of the objects of the cache of the controller by kind",
+		},
+		[]string{"kind"},
+	)
+
+	// notebookImagePullsTotal counts the image pulls of the notebook pods by
+	// image.
+	notebookImagePullsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_image_pulls_total",
+			Help: "Number of image pulls of the notebook pods by image",
+		},
+		[]string{"image"},
+	)
+
+	// webhookRequestDurationSeconds observes the time spent by the notebook
+	// webhook handling the admission requests, the latency percentiles are
+	// computed from its buckets.
+	webhookRequestDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "odh_notebook_webhook_request_duration_seconds",
+			Help:    "Time spent by the notebook webhook handling the admission requests by operation and result",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"operation", "result"},
+	)
+
+	// webhookTimeoutsTotal counts the admission requests handled after the
+	// API server stopped waiting for the webhook.
+	webhookTimeoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_webhook_timeouts_total",
+			Help: "Number of admission requests rejected by the API server because the notebook webhook timed out",
+		},
+		[]string{"operation"},
+	)
+
+	// webhookNoOpUpdatesTotal counts the updates allowed by the notebook
+	// webhook without running the mutation.
+	webhookNoOpUpdatesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_webhook_noop_updates_total",
+			Help: "Number of notebook updates allowed by the webhook without mutation, changing no field it uses",
+		},
+	)
+
+	// webhookSelfTestSuccess is 1 when the last dry-run notebook creation
+	// went through the API server, 0 otherwise.
+	webhookSelfTestSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_webhook_selftest_success",
+			Help: "Whether the last dry-run notebook creation of the webhook self-test succeeded",
+		},
+	)
+
+	// webhookSelfTestDurationSeconds is the duration of the last dry-run
+	// notebook creation.
+	webhookSelfTestDurationSeconds = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_webhook_selftest_duration_seconds",
+			Help: "Duration of the last dry-run notebook creation of the webhook self-test",
+		},
+	)
+
+	// webhookSelfTestFailuresTotal counts the failed dry-run notebook
+	// creations.
+	webhookSelfTestFailuresTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_webhook_selftest_failures_total",
+			Help: "Number of failed dry-run notebook creations of the webhook self-test",
+		},
+	)
+
+	// notebookContainerRestarts is the restart count of the containers of
+	// the notebook pods.
+	notebookContainerRestarts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_container_restarts",
+			Help: "Number of restarts of the containers of the notebook pods",
+		},
+		[]string{"namespace", "notebook", "container"},
+	)
+
+	// notebookOOMKillsTotal counts the containers of the notebook pods
+	// killed for running out of memory.
+	notebookOOMKillsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_container_oom_kills_total",
+			Help: "Number of containers of the notebook pods killed for running out of memory",
+		},
+		[]string{"namespace", "notebook", "container"},
+	)
+
+	// notebookRunningHours is the cumulative running hours of the
+	// notebooks, including their current runs.
+	notebookRunningHours = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_running_hours",
+			Help: "Cumulative hours the notebooks ran",
+		},
+		[]string{"namespace", "notebook"},
+	)
+
+	// notebookGPUHours is the cumulative GPU-hours requested by the
+	// notebooks, including their current runs.
+	notebookGPUHours = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_gpu_hours",
+			Help: "Cumulative GPU-hours requested by the notebooks",
+		},
+		[]string{"namespace", "notebook"},
+	)
+
+	// notebookFieldManagerConflictsTotal counts the changes of the notebooks
+	// and of their generated objects by concurrent field managers.
+	notebookFieldManagerConflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_field_manager_conflicts_total",
+			Help: "Number of changes of the notebooks and their generated objects by concurrent field managers",
+		},
+		[]string{"kind", "manager"},
+	)
+
+	// notebookTrustedCABundleRevertsTotal counts the changes of the
+	// workbench-trusted-ca-bundle ConfigMaps reverted by the controller.
+	notebookTrustedCABundleRevertsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_trusted_ca_bundle_reverts_total",
+			Help: "Number of changes of the workbench trusted CA bundles reverted by the controller",
+		},
+		[]string{"namespace", "manager"},
+	)
+
+	// notebookSpawnDurationSeconds observes the time the notebooks take to
+	// be ready once started, by namespace for the project admins.
+	notebookSpawnDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "odh_notebook_spawn_duration_seconds",
+			Help:    "Time the notebooks take to be ready once started",
+			Buckets: prometheus.ExponentialBuckets(5, 2, 8),
+		},
+		[]string{"namespace"},
+	)
+
+	// notebookSubReconcileDurationSeconds observes the time the independent
+	// sub-reconcilers take within a notebook reconcile, by subsystem.
+	notebookSubReconcileDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "odh_notebook_subreconcile_duration_seconds",
+			Help:    "Time the sub-reconcilers of the notebooks take by subsystem",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"subsystem"},
+	)
+
+	// notebookReconcileCacheSkipsTotal counts the reconciles skipping the
+	// sub-reconcilers, the desired state of the notebook being unchanged.
+	notebookReconcileCacheSkipsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_reconcile_cache_skips_total",
+			Help: "Number of notebook reconciles skipping the sub-reconcilers of an unchanged desired state",
+		},
+	)
+
+	// notebooksTotal is the number of notebooks by namespace and state.
+	notebooksTotal = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "odh_notebooks",
+			Help: "Number of notebooks by state",
+		},
+		[]string{"namespace", "state"},
+	)
+
+	// notebookUpdatesPending is the number of running notebooks whose
+	// updates are applied on their next restart.
+	notebookUpdatesPending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_updates_pending",
+			Help: "Number of running notebooks with updates applied on their next restart",
+		},
+		[]string{"namespace"},
+	)
+
+	// notebookEnvironmentDrift is the number of running notebooks whose pod
+	// differs from the pod the webhook would produce today.
+	notebookEnvironmentDrift = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_environment_drift",
+			Help: "Number of running notebooks whose pod differs from the pod the webhook would produce today",
+		},
+		[]string{"namespace"},
+	)
+
+	// notebookReconcileErrorsTotal counts the reconcile errors of the
+	// notebooks by class and reason, see ClassifyError.
+	notebookReconcileErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "odh_notebook_reconcile_errors_total",
+			Help: "Number of reconcile errors of the notebooks by class and reason",
+		},
+		[]string{"class", "reason"},
+	)
+
+	// spawnQueueLength is the number of new notebooks waiting in the spawn
+	// queue for their start.
+	spawnQueueLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_spawn_queue_length",
+			Help: "Number of new notebooks waiting in the spawn queue for their start",
+		},
+	)
+
+	// notebookAPIAvailable is 1 once the Notebook API is served and the
+	// notebook controllers are started, 0 while the controller waits for
+	// the Notebook CRD.
+	notebookAPIAvailable = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "odh_notebook_api_available",
+			Help: "Whether the Notebook API is served and the notebook controllers are started",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		clientThrottlingSeconds,
+		controllerCacheObjects,
+		controllerCacheEstimatedBytes,
+		notebookImagePullsTotal,
+		webhookRequestDurationSeconds,
+		webhookTimeoutsTotal,
+		webhookNoOpUpdatesTotal,
+		webhookSelfTestSuccess,
+		webhookSelfTestDurationSeconds,
+		webhookSelfTestFailuresTotal,
+		notebookAPIAvailable,
+		notebookContainerRestarts,
+		notebookOOMKillsTotal,
+		notebookRunningHours,
+		notebookGPUHours,
+		notebookFieldManagerConflictsTotal,
+		notebookTrustedCABundleRevertsTotal,
+		notebookSpawnDurationSeconds,
+		notebookSubReconcileDurationSeconds,
+		notebookReconcileCacheSkipsTotal,
+		notebooksTotal,
+		notebookUpdatesPending,
+		notebookEnvironmentDrift,
+		notebookReconcileErrorsTotal,
+		spawnQueueLength,
+	)
+}
@@ -0,0 +1,204 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationModelRegistryURL holds the model registry endpoint exposed to
+	// the workbench as the MODEL_REGISTRY_URL environment variable.
+	AnnotationModelRegistryURL = "notebooks.opendatahub.io/model-registry-url"
+	// AnnotationInferenceEndpoints holds a comma-separated list of
+	// <name>=<url> model serving (KServe) inference endpoints, each exposed to
+	// the workbench as an INFERENCE_ENDPOINT_<NAME> environment variable.
+	AnnotationInferenceEndpoints = "notebooks.opendatahub.io/inference-endpoints"
+	// AnnotationModelEnvInjected records the comma-separated environment
+	// variables injected by the webhook from the model annotations, so that
+	// only those are removed when the annotations change.
+	AnnotationModelEnvInjected = "notebooks.opendatahub.io/model-env-injected"
+
+	EnvModelRegistryURL        = "MODEL_REGISTRY_URL"
+	EnvInferenceEndpointPrefix = "INFERENCE_ENDPOINT_"
+	EnvTrustedCABundle         = "TRUSTED_CA_BUNDLE_PATH"
+)
+
+var envNameInvalidChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// parseEndpointURL validates that the given value is an absolute http(s) URL.
+func parseEndpointURL(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q in %q", u.Scheme, value)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host in %q", value)
+	}
+	return value, nil
+}
+
+// NewModelEndpointsEnv returns the environment variables derived from the
+// model registry and inference endpoints annotations of the notebook.
+func NewModelEndpointsEnv(notebook *nbv1.Notebook) ([]corev1.EnvVar, error) {
+	envVars := []corev1.EnvVar{}
+	annotations := notebook.GetAnnotations()
+
+	if value := annotations[AnnotationModelRegistryURL]; value != "" {
+		registryURL, err := parseEndpointURL(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationModelRegistryURL, err)
+		}
+		envVars = append(envVars, corev1.EnvVar{Name: EnvModelRegistryURL, Value: registryURL})
+	}
+
+	if value := annotations[AnnotationInferenceEndpoints]; value != "" {
+		endpoints := map[string]string{}
+		endpointNames := map[string]string{}
+		for _, entry := range strings.Split(value, ",") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			name, endpoint, found := strings.Cut(entry, "=")
+			name = strings.TrimSpace(name)
+			if !found || name == "" {
+				return nil, fmt.Errorf("invalid %s annotation: expected <name>=<url>, got %q",
+					AnnotationInferenceEndpoints, entry)
+			}
+			endpointURL, err := parseEndpointURL(endpoint)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationInferenceEndpoints, err)
+			}
+			envName := EnvInferenceEndpointPrefix +
+				envNameInvalidChars.ReplaceAllString(strings.ToUpper(name), "_")
+			if previous, found := endpointNames[envName]; found {
+				return nil, fmt.Errorf("invalid %s annotation: endpoints %q and %q both map to %s",
+					AnnotationInferenceEndpoints, previous, name, envName)
+			}
+			endpointNames[envName] = name
+			endpoints[envName] = endpointURL
+		}
+		// Keep a stable order so that the pod template does not change between
+		// admissions of the same notebook
+		names := make([]string, 0, len(endpoints))
+		for envName := range endpoints {
+			names = append(names, envName)
+		}
+		sort.Strings(names)
+		for _, envName := range names {
+			envVars = append(envVars, corev1.EnvVar{Name: envName, Value: endpoints[envName]})
+		}
+	}
+
+	return envVars, nil
+}
+
+// setInjectedModelEnv records the injected environment variables in the
+// annotations of the notebook, removing the annotation if there are none.
+func setInjectedModelEnv(notebook *nbv1.Notebook, envVars []corev1.EnvVar) {
+	if len(envVars) == 0 {
+		delete(notebook.Annotations, AnnotationModelEnvInjected)
+		return
+	}
+	names := make([]string, 0, len(envVars))
+	for _, envVar := range envVars {
+		names = append(names, envVar.Name)
+	}
+	if notebook.Annotations == nil {
+		notebook.Annotations = map[string]string{}
+	}
+	notebook.Annotations[AnnotationModelEnvInjected] = strings.Join(names, ",")
+}
+
+// InjectModelEndpointsEnv sets the model registry and inference endpoints
+// environment variables in the notebook container, overriding the variables
+// of the same names set by the user. When any of them is set and the trusted
+// CA bundle is mounted, its path is exposed as well so the clients can verify
+// the endpoints without hardcoding cluster specific paths. The variables
+// previously injected and no longer derived from the annotations are removed.
+func InjectModelEndpointsEnv(notebook *nbv1.Notebook) error {
+	envVars, err := NewModelEndpointsEnv(notebook)
+	if err != nil {
+		return err
+	}
+
+	notebookContainers := notebook.Spec.Template.Spec.Containers
+	for index, container := range notebookContainers {
+		if container.Name != PrimaryContainerName(notebook) {
+			continue
+		}
+		if len(envVars) > 0 {
+			for _, volumeMount := range container.VolumeMounts {
+				if volumeMount.Name == "trusted-ca" {
+					envVars = append(envVars, corev1.EnvVar{Name: EnvTrustedCABundle, Value: volumeMount.MountPath})
+					break
+				}
+			}
+		}
+		desired := map[string]bool{}
+		for _, envVar := range envVars {
+			desired[envVar.Name] = true
+		}
+		previous := map[string]bool{}
+		for _, name := range strings.Split(notebook.GetAnnotations()[AnnotationModelEnvInjected], ",") {
+			previous[name] = true
+		}
+		// Remove the variables injected for endpoints that are gone, the ones
+		// set by the user are kept
+		env := []corev1.EnvVar{}
+		for _, envVar := range container.Env {
+			if !previous[envVar.Name] || desired[envVar.Name] {
+				env = append(env, envVar)
+			}
+		}
+		if len(env) == 0 {
+			env = nil
+		}
+		container.Env = env
+		for _, envVar := range envVars {
+			envExists := false
+			for i, env := range container.Env {
+				if env.Name == envVar.Name {
+					container.Env[i] = envVar
+					envExists = true
+					break
+				}
+			}
+			if !envExists {
+				container.Env = append(container.Env, envVar)
+			}
+		}
+		notebookContainers[index] = container
+		setInjectedModelEnv(notebook, envVars)
+		return nil
+	}
+
+	if len(envVars) == 0 {
+		return nil
+	}
+	return fmt.Errorf("notebook image container not found %v", notebook.Name)
+}
@@ -0,0 +1,120 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInjectModelEndpointsEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		annotations      map[string]string
+		mounts           []corev1.VolumeMount
+		env              []corev1.EnvVar
+		expected         []corev1.EnvVar
+		expectedInjected string
+		wantErr          bool
+	}{
+		{"no annotations", nil, nil, nil, nil, "", false},
+		{"model registry", map[string]string{
+			AnnotationModelRegistryURL: "https://registry.example.com:8443",
+		}, nil, nil, []corev1.EnvVar{
+			{Name: EnvModelRegistryURL, Value: "https://registry.example.com:8443"},
+		}, EnvModelRegistryURL, false},
+		{"inference endpoints with trusted ca", map[string]string{
+			AnnotationInferenceEndpoints: "sentiment=https://sentiment.example.com, fraud-v2=http://fraud.svc:8080",
+		}, []corev1.VolumeMount{
+			{Name: "trusted-ca", MountPath: "/etc/pki/tls/custom-certs/ca-bundle.crt"},
+		}, nil, []corev1.EnvVar{
+			{Name: "INFERENCE_ENDPOINT_FRAUD_V2", Value: "http://fraud.svc:8080"},
+			{Name: "INFERENCE_ENDPOINT_SENTIMENT", Value: "https://sentiment.example.com"},
+			{Name: EnvTrustedCABundle, Value: "/etc/pki/tls/custom-certs/ca-bundle.crt"},
+		}, "INFERENCE_ENDPOINT_FRAUD_V2,INFERENCE_ENDPOINT_SENTIMENT," + EnvTrustedCABundle, false},
+		{"endpoint removed", map[string]string{
+			AnnotationInferenceEndpoints: "sentiment=https://sentiment.example.com",
+			AnnotationModelEnvInjected:   "INFERENCE_ENDPOINT_FRAUD_V2,INFERENCE_ENDPOINT_SENTIMENT",
+		}, nil, []corev1.EnvVar{
+			{Name: "JUPYTER_IMAGE", Value: "jupyter"},
+			{Name: "INFERENCE_ENDPOINT_FRAUD_V2", Value: "http://fraud.svc:8080"},
+			{Name: "INFERENCE_ENDPOINT_SENTIMENT", Value: "https://old.example.com"},
+		}, []corev1.EnvVar{
+			{Name: "JUPYTER_IMAGE", Value: "jupyter"},
+			{Name: "INFERENCE_ENDPOINT_SENTIMENT", Value: "https://sentiment.example.com"},
+		}, "INFERENCE_ENDPOINT_SENTIMENT", false},
+		{"annotations removed", map[string]string{
+			AnnotationModelEnvInjected: EnvModelRegistryURL + "," + EnvTrustedCABundle,
+		}, []corev1.VolumeMount{
+			{Name: "trusted-ca", MountPath: "/etc/pki/tls/custom-certs/ca-bundle.crt"},
+		}, []corev1.EnvVar{
+			{Name: EnvModelRegistryURL, Value: "https://registry.example.com:8443"},
+			{Name: EnvTrustedCABundle, Value: "/etc/pki/tls/custom-certs/ca-bundle.crt"},
+		}, nil, "", false},
+		{"user variables kept", nil, []corev1.VolumeMount{
+			{Name: "trusted-ca", MountPath: "/etc/pki/tls/custom-certs/ca-bundle.crt"},
+		}, []corev1.EnvVar{
+			{Name: EnvModelRegistryURL, Value: "https://registry.example.com:8443"},
+			{Name: EnvTrustedCABundle, Value: "/etc/pki/tls/custom-certs/ca-bundle.crt"},
+			{Name: "INFERENCE_ENDPOINT_FRAUD", Value: "http://fraud.svc:8080"},
+		}, []corev1.EnvVar{
+			{Name: EnvModelRegistryURL, Value: "https://registry.example.com:8443"},
+			{Name: EnvTrustedCABundle, Value: "/etc/pki/tls/custom-certs/ca-bundle.crt"},
+			{Name: "INFERENCE_ENDPOINT_FRAUD", Value: "http://fraud.svc:8080"},
+		}, "", false},
+		{"user variable with a colliding name kept", map[string]string{
+			AnnotationInferenceEndpoints: "sentiment=https://sentiment.example.com",
+			AnnotationModelEnvInjected:   "INFERENCE_ENDPOINT_SENTIMENT," + EnvModelRegistryURL,
+		}, nil, []corev1.EnvVar{
+			{Name: "INFERENCE_ENDPOINT_FRAUD", Value: "http://fraud.svc:8080"},
+			{Name: EnvModelRegistryURL, Value: "https://registry.example.com:8443"},
+			{Name: "INFERENCE_ENDPOINT_SENTIMENT", Value: "https://sentiment.example.com"},
+		}, []corev1.EnvVar{
+			{Name: "INFERENCE_ENDPOINT_FRAUD", Value: "http://fraud.svc:8080"},
+			{Name: "INFERENCE_ENDPOINT_SENTIMENT", Value: "https://sentiment.example.com"},
+		}, "INFERENCE_ENDPOINT_SENTIMENT", false},
+		{"invalid scheme", map[string]string{
+			AnnotationModelRegistryURL: "ftp://registry.example.com",
+		}, nil, nil, nil, "", true},
+		{"missing endpoint name", map[string]string{
+			AnnotationInferenceEndpoints: "https://sentiment.example.com",
+		}, nil, nil, nil, "", true},
+		{"conflicting endpoint names", map[string]string{
+			AnnotationInferenceEndpoints: "a-b=https://a.example.com,a_b=https://b.example.com",
+		}, nil, nil, nil, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			notebook := &nbv1.Notebook{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: tt.annotations},
+				Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "test", VolumeMounts: tt.mounts, Env: tt.env}},
+				}}},
+			}
+			err := InjectModelEndpointsEnv(notebook)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, notebook.Spec.Template.Spec.Containers[0].Env)
+			assert.Equal(t, tt.expectedInjected, notebook.Annotations[AnnotationModelEnvInjected])
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// AnnotationNamespaceStopAll stops all the notebooks of the namespace,
+	// e.g. for the nights and weekends or an emergency maintenance. Set to
+	// true to stop them now, or to the RFC3339 time from which they are
+	// stopped. The notebooks it stopped are started again once it is removed
+	// or set to false.
+	AnnotationNamespaceStopAll = "opendatahub.io/stop-all-notebooks"
+	// AnnotationStoppedByNamespace records the stop request of the namespace
+	// which stopped the notebook, so that only the notebooks it stopped are
+	// started again.
+	AnnotationStoppedByNamespace = "notebooks.opendatahub.io/stopped-by-namespace"
+)
+
+// NamespaceStopTime returns the time from which the notebooks of the
+// namespace must be stopped, and false if they must not.
+func NamespaceStopTime(namespace *corev1.Namespace, now time.Time) (time.Time, bool, error) {
+	value, ok := namespace.GetAnnotations()[AnnotationNamespaceStopAll]
+	if !ok || value == "" {
+		return time.Time{}, false, nil
+	}
+	if stop, err := strconv.ParseBool(value); err == nil {
+		return now, stop, nil
+	}
+	stopTime, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s annotation %q: expected true, false or an RFC3339 time",
+			AnnotationNamespaceStopAll, value)
+	}
+	return stopTime, true, nil
+}
+
+// ReconcileNamespaceStop stops the notebook while its namespace requests all
+// its notebooks to be stopped, and starts the notebook again once the request
+// is lifted if it was stopped by it. Returns the delay after which the
+// scheduled stop of the namespace applies.
+func (r *OpenshiftNotebookReconciler) ReconcileNamespaceStop(notebook *nbv1.Notebook,
+	ctx context.Context) (time.Duration, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	// Wait for the reconciliation lock to be removed, the controller starts
+	// the notebook when removing it
+	if ReconciliationLockIsEnabled(notebook.ObjectMeta) {
+		return 0, nil
+	}
+
+	namespace := &corev1.Namespace{}
+	err := r.Get(ctx, types.NamespacedName{Name: notebook.Namespace}, namespace)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	stopTime, stop, err := NamespaceStopTime(namespace, now)
+	if err != nil {
+		log.Error(err, "Ignoring the stop request of the namespace")
+		r.recordEvent(notebook, corev1.EventTypeWarning, "InvalidNamespaceStop", err.Error())
+		return 0, nil
+	}
+	if stop && stopTime.After(now) {
+		log.Info("Scheduling the stop requested by the namespace", "time", stopTime)
+		return stopTime.Sub(now), nil
+	}
+
+	annotations := map[string]interface{}{}
+	stoppedBy, stoppedByNamespace := notebook.GetAnnotations()[AnnotationStoppedByNamespace]
+	switch {
+	case stop && !notebookIsStopped(notebook.ObjectMeta):
+		// The notebooks started during the stop request are stopped again
+		annotations[culler.STOP_ANNOTATION] = now.Format(time.RFC3339)
+		annotations[AnnotationStoppedByNamespace] = stopTime.Format(time.RFC3339)
+		log.Info("Stopping the notebook as requested by the namespace")
+		r.recordEvent(notebook, corev1.EventTypeNormal, "StoppedByNamespace",
+			"The notebook is stopped as requested by the %s annotation of the namespace", AnnotationNamespaceStopAll)
+	case !stop && stoppedByNamespace:
+		if !HibernationIsRequested(notebook.ObjectMeta) {
+			annotations[culler.STOP_ANNOTATION] = nil
+			log.Info("Starting the notebook stopped by the namespace", "stoppedAt", stoppedBy)
+			r.recordEvent(notebook, corev1.EventTypeNormal, "StartedByNamespace",
+				"The notebook is started as the stop request of the namespace is lifted")
+		}
+		annotations[AnnotationStoppedByNamespace] = nil
+	default:
+		return 0, nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+	if err != nil {
+		log.Error(err, "Unable to apply the stop request of the namespace")
+		return 0, err
+	}
+	return 0, nil
+}
+
+// namespaceNotebooks maps the changes of a namespace to its notebooks. The
+// notebooks are invalidated in the reconcile cache, the annotations of the
+// namespace (e.g. its pull secrets) being inputs of their sub-reconcilers.
+func (r *OpenshiftNotebookReconciler) namespaceNotebooks(ctx context.Context, obj client.Object) []reconcile.Request {
+	notebookList := &nbv1.NotebookList{}
+	if err := r.List(ctx, notebookList, client.InNamespace(obj.GetName())); err != nil {
+		r.Log.Error(err, "Unable to list the notebooks of the namespace", "namespace", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(notebookList.Items))
+	for _, notebook := range notebookList.Items {
+		if r.ReconcileCache != nil {
+			r.ReconcileCache.Invalidate(client.ObjectKeyFromObject(&notebook))
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&notebook)})
+	}
+	return requests
+}
@@ -0,0 +1,103 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNamespaceStopTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+	namespace := func(value string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns",
+			Annotations: map[string]string{AnnotationNamespaceStopAll: value}}}
+	}
+
+	_, stop, err := NamespaceStopTime(&corev1.Namespace{}, now)
+	require.NoError(t, err)
+	assert.False(t, stop)
+	_, stop, err = NamespaceStopTime(namespace("false"), now)
+	require.NoError(t, err)
+	assert.False(t, stop)
+	stopTime, stop, err := NamespaceStopTime(namespace("true"), now)
+	require.NoError(t, err)
+	assert.True(t, stop)
+	assert.Equal(t, now, stopTime)
+	stopTime, stop, err = NamespaceStopTime(namespace("2024-01-01T20:00:00Z"), now)
+	require.NoError(t, err)
+	assert.True(t, stop)
+	assert.Equal(t, now.Add(2*time.Hour), stopTime)
+	_, _, err = NamespaceStopTime(namespace("tonight"), now)
+	assert.Error(t, err)
+}
+
+func TestReconcileNamespaceStop(t *testing.T) {
+	ctx := context.Background()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns",
+		Annotations: map[string]string{AnnotationNamespaceStopAll: "true"}}}
+	running := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "ns"}}
+	stopped := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "stopped", Namespace: "ns",
+		Annotations: map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"}}}
+	r := newTestReconciler(t, OAuthConfig{}, namespace, running, stopped)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	// The running notebooks are stopped
+	for _, notebook := range []*nbv1.Notebook{running, stopped} {
+		_, err := r.ReconcileNamespaceStop(notebook, ctx)
+		require.NoError(t, err)
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	}
+	assert.Contains(t, running.Annotations, culler.STOP_ANNOTATION)
+	assert.Contains(t, running.Annotations, AnnotationStoppedByNamespace)
+	assert.NotContains(t, stopped.Annotations, AnnotationStoppedByNamespace)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "StoppedByNamespace")
+
+	// Only the notebooks stopped by the namespace are started again
+	delete(namespace.Annotations, AnnotationNamespaceStopAll)
+	require.NoError(t, r.Update(ctx, namespace))
+	for _, notebook := range []*nbv1.Notebook{running, stopped} {
+		_, err := r.ReconcileNamespaceStop(notebook, ctx)
+		require.NoError(t, err)
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	}
+	assert.NotContains(t, running.Annotations, culler.STOP_ANNOTATION)
+	assert.NotContains(t, running.Annotations, AnnotationStoppedByNamespace)
+	assert.Contains(t, stopped.Annotations, culler.STOP_ANNOTATION)
+	assert.Contains(t, <-recorder.Events, "StartedByNamespace")
+
+	// The scheduled stops are requeued until they apply
+	namespace.Annotations = map[string]string{
+		AnnotationNamespaceStopAll: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}
+	require.NoError(t, r.Update(ctx, namespace))
+	requeueAfter, err := r.ReconcileNamespaceStop(running, ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour.Seconds(), requeueAfter.Seconds(), 5)
+	assert.NotContains(t, running.Annotations, culler.STOP_ANNOTATION)
+}
@@ -30,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -41,7 +42,7 @@ const (
 // required by the notebook.
 func (r *OpenshiftNotebookReconciler) ReconcileAllNetworkPolicies(notebook *nbv1.Notebook, ctx context.Context) error {
 	// Initialize logger format
-	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	log := r.notebookLogger(notebook)
 
 	// Generate the desired Network Policies
 	desiredNotebookNetworkPolicy := NewNotebookNetworkPolicy(notebook)
@@ -62,24 +63,27 @@ func (r *OpenshiftNotebookReconciler) ReconcileAllNetworkPolicies(notebook *nbv1
 		}
 	}
 
-	return nil
+	// Allow the kubelet probes on the CNIs that drop them
+	return r.ReconcileProbesNetworkPolicies(notebook, ctx)
 }
 
-func (r *OpenshiftNotebookReconciler) reconcileNetworkPolicy(desiredNetworkPolicy *netv1.NetworkPolicy, ctx context.Context, notebook *nbv1.Notebook) error {
+func (r *OpenshiftNotebookReconciler) reconcileNetworkPolicy(desiredNetworkPolicy *netv1.NetworkPolicy, ctx context.Context, owner client.Object) error {
+	// Initialize logger format
+	log := r.ownerLogger(owner)
 
 	// Create the Network Policy if it does not already exist
 	foundNetworkPolicy := &netv1.NetworkPolicy{}
 	justCreated := false
 	err := r.Get(ctx, types.NamespacedName{
 		Name:      desiredNetworkPolicy.GetName(),
-		Namespace: notebook.GetNamespace(),
+		Namespace: owner.GetNamespace(),
 	}, foundNetworkPolicy)
 	if err != nil {
 		if apierrs.IsNotFound(err) {
-			r.Log.Info("Creating Network Policy", "name", desiredNetworkPolicy.Name)
+			log.Info("Creating Network Policy", "name", desiredNetworkPolicy.Name)
 			// Add .metatada.ownerReferences to the Network Policy to be deleted by
-			// the Kubernetes garbage collector if the notebook is deleted
-			err = ctrl.SetControllerReference(notebook, desiredNetworkPolicy, r.Scheme)
+			// the Kubernetes garbage collector if the owner is deleted
+			err = ctrl.SetControllerReference(owner, desiredNetworkPolicy, r.Scheme)
 			if err != nil {
 				return err
 			}
@@ -96,14 +100,15 @@ func (r *OpenshiftNotebookReconciler) reconcileNetworkPolicy(desiredNetworkPolic
 
 	// Reconcile the NetworkPolicy spec if it has been manually modified
 	if !justCreated && !CompareNotebookNetworkPolicies(*desiredNetworkPolicy, *foundNetworkPolicy) {
-		r.Log.Info("Reconciling Network policy", "name", foundNetworkPolicy.Name)
+		log.Info("Reconciling Network policy", "name", foundNetworkPolicy.Name)
+		r.reportFieldManagerConflicts(owner, foundNetworkPolicy)
 		// Retry the update operation when the ingress controller eventually
 		// updates the resource version field
 		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			// Get the last route revision
 			if err := r.Get(ctx, types.NamespacedName{
 				Name:      desiredNetworkPolicy.Name,
-				Namespace: notebook.Namespace,
+				Namespace: owner.GetNamespace(),
 			}, foundNetworkPolicy); err != nil {
 				return err
 			}
@@ -113,7 +118,7 @@ func (r *OpenshiftNotebookReconciler) reconcileNetworkPolicy(desiredNetworkPolic
 			return r.Update(ctx, foundNetworkPolicy)
 		})
 		if err != nil {
-			r.Log.Error(err, "Unable to reconcile the Network Policy")
+			log.Error(err, "Unable to reconcile the Network Policy")
 			return err
 		}
 	}
@@ -142,6 +147,7 @@ func NewNotebookNetworkPolicy(notebook *nbv1.Notebook) *netv1.NetworkPolicy {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      notebook.Name + "-ctrl-np",
 			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentNetworkPolicy),
 		},
 		Spec: netv1.NetworkPolicySpec{
 			PodSelector: metav1.LabelSelector{
@@ -183,6 +189,7 @@ func NewOAuthNetworkPolicy(notebook *nbv1.Notebook) *netv1.NetworkPolicy {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      notebook.Name + "-oauth-np",
 			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentNetworkPolicy),
 		},
 		Spec: netv1.NetworkPolicySpec{
 			PodSelector: metav1.LabelSelector{
@@ -210,6 +217,11 @@ func NewOAuthNetworkPolicy(notebook *nbv1.Notebook) *netv1.NetworkPolicy {
 	}
 }
 
+// ControllerNamespace returns the namespace the controller runs in.
+func ControllerNamespace() string {
+	return getControllerNamespace()
+}
+
 func getControllerNamespace() string {
 	// TODO:Add env variable that stores namespace for both controllers.
 	if data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
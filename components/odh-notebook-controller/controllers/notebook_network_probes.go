@@ -0,0 +1,254 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// CiliumNetworkPolicyGVK identifies the Cilium network policy resource, used
+// to allow the probes from Cilium entities.
+var CiliumNetworkPolicyGVK = schema.GroupVersionKind{
+	Group:   "cilium.io",
+	Version: "v2",
+	Kind:    "CiliumNetworkPolicy",
+}
+
+// ciliumEntities are the Cilium entities the probes may come from.
+var ciliumEntities = map[string]bool{
+	"host": true, "remote-node": true, "kube-apiserver": true, "ingress": true, "cluster": true,
+	"init": true, "health": true, "unmanaged": true, "world": true, "all": true,
+}
+
+// +kubebuilder:rbac:groups="networking.k8s.io",resources=networkpolicies,verbs=delete
+// +kubebuilder:rbac:groups="cilium.io",resources=ciliumnetworkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// NetworkConfig holds the cluster level settings of the notebook network
+// policies. Some CNIs drop the kubelet probes under the generated ingress-only
+// policies, the probe ports of the notebook containers are then opened to the
+// configured sources.
+type NetworkConfig struct {
+	// ProbeSourceCIDRs are the CIDRs of the nodes, allowed to reach the probe
+	// ports of the notebook pods through a NetworkPolicy.
+	ProbeSourceCIDRs []string
+	// ProbeSourceEntities are the Cilium entities (e.g. host, remote-node)
+	// allowed to reach the probe ports through a CiliumNetworkPolicy.
+	ProbeSourceEntities []string
+}
+
+// Validate checks the probe sources of the network policies.
+func (c NetworkConfig) Validate() error {
+	for _, cidr := range c.ProbeSourceCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid probe source CIDR %q: %w", cidr, err)
+		}
+	}
+	for _, entity := range c.ProbeSourceEntities {
+		if !ciliumEntities[entity] {
+			return fmt.Errorf("invalid probe source entity %q", entity)
+		}
+	}
+	return nil
+}
+
+// CiliumNetworkPoliciesAreServed returns true if the CiliumNetworkPolicy CRD
+// is installed in the cluster.
+func CiliumNetworkPoliciesAreServed(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(CiliumNetworkPolicyGVK.GroupKind(), CiliumNetworkPolicyGVK.Version)
+	return err == nil
+}
+
+// notebookProbePorts returns the ports targeted by the probes of the notebook
+// containers, named ports being resolved from the container ports.
+func notebookProbePorts(notebook *nbv1.Notebook) []int32 {
+	found := map[int32]bool{}
+	for _, container := range notebook.Spec.Template.Spec.Containers {
+		for _, probe := range []*corev1.Probe{container.LivenessProbe, container.ReadinessProbe, container.StartupProbe} {
+			if probe == nil {
+				continue
+			}
+			var port *intstr.IntOrString
+			switch {
+			case probe.HTTPGet != nil:
+				port = &probe.HTTPGet.Port
+			case probe.TCPSocket != nil:
+				port = &probe.TCPSocket.Port
+			case probe.GRPC != nil:
+				found[probe.GRPC.Port] = true
+				continue
+			default:
+				continue
+			}
+			if port.Type == intstr.Int {
+				found[port.IntVal] = true
+				continue
+			}
+			for _, containerPort := range container.Ports {
+				if containerPort.Name == port.StrVal {
+					found[containerPort.ContainerPort] = true
+				}
+			}
+		}
+	}
+
+	ports := []int32{}
+	for port := range found {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return ports
+}
+
+// NewProbesNetworkPolicy defines the network policy allowing the probes from
+// the node CIDRs, nil if there is nothing to allow.
+func NewProbesNetworkPolicy(notebook *nbv1.Notebook, config NetworkConfig) *netv1.NetworkPolicy {
+	ports := notebookProbePorts(notebook)
+	if len(ports) == 0 || len(config.ProbeSourceCIDRs) == 0 {
+		return nil
+	}
+
+	npProtocol := corev1.ProtocolTCP
+	policyPorts := []netv1.NetworkPolicyPort{}
+	for _, port := range ports {
+		policyPorts = append(policyPorts, netv1.NetworkPolicyPort{
+			Protocol: &npProtocol,
+			Port:     &intstr.IntOrString{IntVal: port},
+		})
+	}
+	peers := []netv1.NetworkPolicyPeer{}
+	for _, cidr := range config.ProbeSourceCIDRs {
+		peers = append(peers, netv1.NetworkPolicyPeer{IPBlock: &netv1.IPBlock{CIDR: cidr}})
+	}
+	return &netv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      notebook.Name + "-probes-np",
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentNetworkPolicy),
+		},
+		Spec: netv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"notebook-name": notebook.Name,
+				},
+			},
+			Ingress: []netv1.NetworkPolicyIngressRule{
+				{
+					Ports: policyPorts,
+					From:  peers,
+				},
+			},
+			PolicyTypes: []netv1.PolicyType{
+				netv1.PolicyTypeIngress,
+			},
+		},
+	}
+}
+
+// NewProbesCiliumNetworkPolicy defines the Cilium network policy allowing the
+// probes from the Cilium entities, nil if there is nothing to allow.
+func NewProbesCiliumNetworkPolicy(notebook *nbv1.Notebook, config NetworkConfig) *unstructured.Unstructured {
+	ports := notebookProbePorts(notebook)
+	if len(ports) == 0 || len(config.ProbeSourceEntities) == 0 {
+		return nil
+	}
+
+	policyPorts := []interface{}{}
+	for _, port := range ports {
+		policyPorts = append(policyPorts, map[string]interface{}{
+			"port":     strconv.Itoa(int(port)),
+			"protocol": "TCP",
+		})
+	}
+	entities := []interface{}{}
+	for _, entity := range config.ProbeSourceEntities {
+		entities = append(entities, entity)
+	}
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"notebook-name": notebook.Name,
+				},
+			},
+			"ingress": []interface{}{
+				map[string]interface{}{
+					"fromEntities": entities,
+					"toPorts":      []interface{}{map[string]interface{}{"ports": policyPorts}},
+				},
+			},
+		},
+	}}
+	policy.SetGroupVersionKind(CiliumNetworkPolicyGVK)
+	policy.SetName(notebook.Name + "-probes-cnp")
+	policy.SetNamespace(notebook.Namespace)
+	return policy
+}
+
+// ReconcileProbesNetworkPolicies manages the policies allowing the kubelet
+// probes, and deletes them when the probe sources are no longer configured.
+func (r *OpenshiftNotebookReconciler) ReconcileProbesNetworkPolicies(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	desiredNetworkPolicy := NewProbesNetworkPolicy(notebook, r.NetworkConfig)
+	if desiredNetworkPolicy != nil {
+		err := r.reconcileNetworkPolicy(desiredNetworkPolicy, ctx, notebook)
+		if err != nil {
+			log.Error(err, "error creating Notebook probes network policy")
+			return err
+		}
+	} else {
+		networkPolicy := &netv1.NetworkPolicy{}
+		err := r.deleteControlledObject(ctx, notebook, notebook.Name+"-probes-np", networkPolicy)
+		if err != nil {
+			log.Error(err, "Unable to delete the Notebook probes network policy")
+			return err
+		}
+	}
+
+	if !r.CiliumEnabled {
+		return nil
+	}
+	desiredCiliumPolicy := NewProbesCiliumNetworkPolicy(notebook, r.NetworkConfig)
+	if desiredCiliumPolicy == nil {
+		ciliumPolicy := &unstructured.Unstructured{}
+		ciliumPolicy.SetGroupVersionKind(CiliumNetworkPolicyGVK)
+		err := r.deleteControlledObject(ctx, notebook, notebook.Name+"-probes-cnp", ciliumPolicy)
+		if err != nil {
+			log.Error(err, "Unable to delete the Notebook probes Cilium network policy")
+		}
+		return err
+	}
+
+	err := r.reconcileUnstructured(ctx, notebook, desiredCiliumPolicy)
+	if err != nil {
+		log.Error(err, "Unable to reconcile the Notebook probes Cilium network policy")
+	}
+	return err
+}
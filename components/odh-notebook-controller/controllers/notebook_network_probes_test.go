@@ -0,0 +1,104 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestProbedNotebook() *nbv1.Notebook {
+	return &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "nb",
+					Ports: []corev1.ContainerPort{{Name: "notebook-port", ContainerPort: 8888}},
+					LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+						HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromString("notebook-port")},
+					}},
+					ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+						HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromInt(8888)},
+					}},
+				},
+				{
+					Name: "oauth-proxy",
+					LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+						HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromInt(NotebookOAuthPort)},
+					}},
+				},
+			},
+		}}},
+	}
+}
+
+func TestNetworkConfigValidate(t *testing.T) {
+	assert.NoError(t, NetworkConfig{}.Validate())
+	assert.NoError(t, NetworkConfig{ProbeSourceCIDRs: []string{"10.0.0.0/16"},
+		ProbeSourceEntities: []string{"host", "remote-node"}}.Validate())
+	assert.Error(t, NetworkConfig{ProbeSourceCIDRs: []string{"10.0.0.1"}}.Validate())
+	assert.Error(t, NetworkConfig{ProbeSourceEntities: []string{"nodes"}}.Validate())
+}
+
+func TestNotebookProbePorts(t *testing.T) {
+	assert.Equal(t, []int32{8443, 8888}, notebookProbePorts(newTestProbedNotebook()))
+	assert.Empty(t, notebookProbePorts(&nbv1.Notebook{}))
+}
+
+func TestReconcileProbesNetworkPolicies(t *testing.T) {
+	ctx := context.Background()
+	notebook := newTestProbedNotebook()
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	r.Scheme.AddKnownTypeWithName(CiliumNetworkPolicyGVK, &unstructured.Unstructured{})
+	r.CiliumEnabled = true
+	r.NetworkConfig = NetworkConfig{ProbeSourceCIDRs: []string{"10.0.0.0/16"}, ProbeSourceEntities: []string{"host"}}
+	networkPolicyKey := client.ObjectKey{Namespace: "ns", Name: "nb-probes-np"}
+	ciliumPolicyKey := client.ObjectKey{Namespace: "ns", Name: "nb-probes-cnp"}
+	newCiliumPolicy := func() *unstructured.Unstructured {
+		policy := &unstructured.Unstructured{}
+		policy.SetGroupVersionKind(CiliumNetworkPolicyGVK)
+		return policy
+	}
+
+	require.NoError(t, r.ReconcileProbesNetworkPolicies(notebook, ctx))
+	networkPolicy := &netv1.NetworkPolicy{}
+	require.NoError(t, r.Get(ctx, networkPolicyKey, networkPolicy))
+	require.Len(t, networkPolicy.Spec.Ingress, 1)
+	assert.Len(t, networkPolicy.Spec.Ingress[0].Ports, 2)
+	assert.Equal(t, "10.0.0.0/16", networkPolicy.Spec.Ingress[0].From[0].IPBlock.CIDR)
+	ciliumPolicy := newCiliumPolicy()
+	require.NoError(t, r.Get(ctx, ciliumPolicyKey, ciliumPolicy))
+	assert.True(t, metav1.IsControlledBy(ciliumPolicy, notebook))
+	entities, _, _ := unstructured.NestedSlice(ciliumPolicy.Object, "spec", "ingress")
+	assert.Equal(t, []interface{}{"host"}, entities[0].(map[string]interface{})["fromEntities"])
+
+	// The policies are deleted once the probe sources are unset
+	r.NetworkConfig = NetworkConfig{}
+	require.NoError(t, r.ReconcileProbesNetworkPolicies(notebook, ctx))
+	assert.Error(t, r.Get(ctx, networkPolicyKey, &netv1.NetworkPolicy{}))
+	assert.Error(t, r.Get(ctx, ciliumPolicyKey, newCiliumPolicy()))
+}
@@ -0,0 +1,89 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// LabelApp is the application label the dashboard sets on the notebooks
+	// and their pods.
+	LabelApp = "app"
+	// LabelODHManaged marks the notebooks managed by the ODH stack.
+	LabelODHManaged = "opendatahub.io/odh-managed"
+
+	// EnvJupyterImage and EnvNotebookPrefix are the environment variables of
+	// the notebook container set by the dashboard: the selected image and the
+	// path prefix the notebook server is served under.
+	EnvJupyterImage   = "JUPYTER_IMAGE"
+	EnvNotebookPrefix = "NB_PREFIX"
+)
+
+// NotebookPrefix returns the path prefix of the notebook server.
+func NotebookPrefix(namespace, name string) string {
+	return "/notebook/" + namespace + "/" + name
+}
+
+// NormalizeNotebook fills in the labels, annotations and environment variables
+// the dashboard sets on the notebooks it creates, so that the notebooks
+// created by GitOps tools or the CLI behave the same. Only the notebook is
+// labelled, not its pod template: the pods get the labels of the notebook and
+// the notebook-name label from the Kubeflow notebook controller. The values
+// set by the users are kept. Returns the keys applied to the notebook.
+func NormalizeNotebook(notebook *nbv1.Notebook, namespace string) []string {
+	applied := []string{}
+	setDefault := func(values *map[string]string, key, value, kind string) {
+		if _, ok := (*values)[key]; ok {
+			return
+		}
+		if *values == nil {
+			*values = map[string]string{}
+		}
+		(*values)[key] = value
+		applied = append(applied, kind+":"+key)
+	}
+
+	setDefault(&notebook.Labels, LabelApp, notebook.Name, "label")
+	setDefault(&notebook.Labels, LabelODHManaged, "true", "label")
+	setDefault(&notebook.Annotations, AnnotationDisplayName, notebook.Name, "annotation")
+
+	container := notebookContainer(notebook)
+	if container != nil {
+		image := notebook.GetAnnotations()[AnnotationLastImageSelection]
+		if image == "" {
+			image = container.Image
+		}
+		env := map[string]string{
+			EnvJupyterImage:   image,
+			EnvNotebookPrefix: NotebookPrefix(namespace, notebook.Name),
+		}
+		for _, existing := range container.Env {
+			delete(env, existing.Name)
+		}
+		for _, name := range []string{EnvJupyterImage, EnvNotebookPrefix} {
+			if value, ok := env[name]; ok && value != "" {
+				container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+				applied = append(applied, "env:"+name)
+			}
+		}
+	}
+	sort.Strings(applied)
+	return applied
+}
@@ -0,0 +1,56 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeNotebook(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name:        "nb",
+		Labels:      map[string]string{LabelApp: "gitops"},
+		Annotations: map[string]string{AnnotationLastImageSelection: "jupyter:2024.1"},
+	}}
+	notebook.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name:  "nb",
+		Image: "quay.io/jupyter:2024.1",
+		Env:   []corev1.EnvVar{{Name: EnvNotebookPrefix, Value: "/custom"}},
+	}}
+
+	applied := NormalizeNotebook(notebook, "ns")
+	assert.Equal(t, []string{
+		"annotation:" + AnnotationDisplayName,
+		"env:" + EnvJupyterImage,
+		"label:" + LabelODHManaged,
+	}, applied)
+
+	// The values set by the users are kept
+	assert.Equal(t, "gitops", notebook.Labels[LabelApp])
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: EnvNotebookPrefix, Value: "/custom"},
+		{Name: EnvJupyterImage, Value: "jupyter:2024.1"},
+	}, notebook.Spec.Template.Spec.Containers[0].Env)
+
+	// The normalized notebooks are left unchanged
+	assert.Empty(t, NormalizeNotebook(notebook, "ns"))
+	assert.Equal(t, "/notebook/ns/nb", NotebookPrefix("ns", "nb"))
+}
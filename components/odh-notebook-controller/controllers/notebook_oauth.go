@@ -19,7 +19,10 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/intstr"
 
@@ -30,6 +33,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -41,19 +45,77 @@ const (
 	OAuthProxyImage = "registry.redhat.io/openshift4/ose-oauth-proxy@sha256:4f8d66597feeb32bb18699326029f9a71a5aca4a57679d636b876377c2e95695"
 )
 
+// AnnotationOAuthServiceAccount records the name of the dedicated service
+// account of the notebook when it differs from the default one, e.g. because
+// a service account with the default name already belonged to the user.
+const AnnotationOAuthServiceAccount = "notebooks.opendatahub.io/oauth-service-account"
+
 type OAuthConfig struct {
 	ProxyImage string
+	// ServiceAccountSuffix is appended to the notebook name to build the name
+	// of its dedicated service account.
+	ServiceAccountSuffix string
+	// SARTemplate is the subject access review checked by the proxy, see
+	// DefaultOAuthSARTemplate.
+	SARTemplate string
+	// DelegateURLs authorizes the bearer tokens of the clients by path, see
+	// NotebookAccessDelegateURLsKey, none if empty.
+	DelegateURLs string
+	// MetricsPort is the port exposing the proxy metrics, disabled if zero.
+	MetricsPort int32
+	// MetricsNamespace is the namespace of the Prometheus instance scraping
+	// the proxy metrics.
+	MetricsNamespace string
+	// UpstreamCA is the path of the CA checking the upstream of the proxy,
+	// DefaultOAuthUpstreamCA if empty.
+	UpstreamCA string
+	// NativeSidecar runs the proxy as a native sidecar container, started
+	// before and stopped after the notebook containers.
+	NativeSidecar bool
+	// ReadinessTimeout is the maximum time the reconciliation lock of a new
+	// notebook is held until its OAuth Service and Route are ready, disabled
+	// if zero.
+	ReadinessTimeout time.Duration
+	// ImagePullPolicy is the pull policy of the proxy container, PullAlways
+	// if empty.
+	ImagePullPolicy corev1.PullPolicy
+	// CookieExpire is the lifetime of the session cookies of the proxy,
+	// DefaultOAuthCookieExpire if zero.
+	CookieExpire time.Duration
+	// CookieRefresh is the interval after which the session cookies are
+	// refreshed, never if zero.
+	CookieRefresh time.Duration
+	// ExtraArgs are the arguments of the cluster appended to the proxy
+	// arguments, see ValidateProxyExtraArgs.
+	ExtraArgs []string
+	// AllowedNotebookArgs are the names of the flags of the proxy the
+	// notebooks may set with the oauth-proxy-extra-args annotation, none if
+	// empty.
+	AllowedNotebookArgs []string
+	// Resources are the resources of the proxy container,
+	// DefaultOAuthProxyResources if nil.
+	Resources *corev1.ResourceRequirements
+	// DefaultLogoutURL is the logout URL of the notebooks without logout
+	// annotation, none if empty, see LogoutConfig.
+	DefaultLogoutURL string
+}
+
+// OAuthServiceAccountName returns the name of the dedicated service account of
+// the notebook.
+func OAuthServiceAccountName(notebook *nbv1.Notebook, oauth OAuthConfig) string {
+	if name := notebook.GetAnnotations()[AnnotationOAuthServiceAccount]; name != "" {
+		return name
+	}
+	return notebook.Name + oauth.ServiceAccountSuffix
 }
 
 // NewNotebookServiceAccount defines the desired service account object
-func NewNotebookServiceAccount(notebook *nbv1.Notebook) *corev1.ServiceAccount {
+func NewNotebookServiceAccount(notebook *nbv1.Notebook, name string) *corev1.ServiceAccount {
 	return &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      notebook.Name,
+			Name:      name,
 			Namespace: notebook.Namespace,
-			Labels: map[string]string{
-				"notebook-name": notebook.Name,
-			},
+			Labels:    NotebookObjectLabels(notebook, ComponentOAuthProxy),
 			Annotations: map[string]string{
 				"serviceaccounts.openshift.io/oauth-redirectreference.first": "" +
 					`{"kind":"OAuthRedirectReference","apiVersion":"v1",` +
@@ -72,18 +134,104 @@ func CompareNotebookServiceAccounts(sa1 corev1.ServiceAccount, sa2 corev1.Servic
 		reflect.DeepEqual(sa1.ObjectMeta.Annotations, sa2.ObjectMeta.Annotations)
 }
 
+// maxServiceAccountNameAttempts bounds the number of alternative names probed
+// when the service account name of the notebook is already in use.
+const maxServiceAccountNameAttempts = 10
+
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=delete
+
 // ReconcileOAuthServiceAccount will manage the service account reconciliation
 // required by the notebook OAuth proxy
 func (r *OpenshiftNotebookReconciler) ReconcileOAuthServiceAccount(notebook *nbv1.Notebook, ctx context.Context) error {
 	// Initialize logger format
-	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	log := r.notebookLogger(notebook)
+
+	// Keep the current service account of the notebook while it is available
+	currentName := OAuthServiceAccountName(notebook, r.OAuthConfig)
+	available, err := r.reconcileNotebookServiceAccount(notebook, ctx, currentName)
+	if err != nil {
+		return err
+	}
+
+	// Otherwise, the name is used by a service account that does not belong
+	// to the notebook, look for a free name derived from the configured one
+	serviceAccountName := currentName
+	if !available {
+		configuredName := notebook.Name + r.OAuthConfig.ServiceAccountSuffix
+		for attempt := 0; !available && attempt <= maxServiceAccountNameAttempts; attempt++ {
+			serviceAccountName = configuredName
+			if attempt > 0 {
+				serviceAccountName = fmt.Sprintf("%s-%d", configuredName, attempt)
+			}
+			if serviceAccountName == currentName {
+				continue
+			}
+			available, err = r.reconcileNotebookServiceAccount(notebook, ctx, serviceAccountName)
+			if err != nil {
+				return err
+			}
+		}
+		if !available {
+			return NewUserActionableError("ServiceAccountConflict", fmt.Errorf("service account %s and its "+
+				"alternatives are already in use, unable to create the notebook service account", currentName))
+		}
+
+		// Record the new name in the notebook, so that the webhook switches
+		// the OAuth proxy and the pod to the new service account
+		log.Info("Service Account already in use, switching the notebook to an alternative Service Account",
+			"serviceAccount", currentName, "alternative", serviceAccountName)
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{
+					AnnotationOAuthServiceAccount: serviceAccountName,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+		if err != nil {
+			log.Error(err, "Unable to record the Service Account of the notebook")
+			return err
+		}
+	}
+
+	return r.cleanupNotebookServiceAccounts(notebook, ctx, serviceAccountName)
+}
+
+// reconcileNotebookServiceAccount creates the service account with the given
+// name for the notebook. It returns false if the name is used by a service
+// account that does not belong to the notebook. Service accounts created for
+// the notebook by a previous version of the controller are adopted.
+func (r *OpenshiftNotebookReconciler) reconcileNotebookServiceAccount(notebook *nbv1.Notebook, ctx context.Context,
+	name string) (bool, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook).WithValues("serviceAccount", name)
 
-	// Generate the desired service account
-	desiredServiceAccount := NewNotebookServiceAccount(notebook)
+	// Generate the desired service account, registering the address of the
+	// exposure Service in its OAuth redirect URIs
+	desiredServiceAccount := NewNotebookServiceAccount(notebook, name)
+	redirectURI, err := r.exposureRedirectURI(notebook, ctx)
+	if err != nil {
+		log.Error(err, "Unable to fetch the exposure Service")
+		return false, err
+	}
+	if redirectURI != "" {
+		desiredServiceAccount.Annotations[AnnotationOAuthRedirectURIExposure] = redirectURI
+	}
+
+	// Propagate the configured pull secrets to the service account, so that
+	// all the pods of the notebook pull the private workbench images
+	pullSecrets, err := r.serviceAccountPullSecrets(notebook, ctx)
+	if err != nil {
+		return false, err
+	}
+	InjectServiceAccountPullSecrets(desiredServiceAccount, pullSecrets)
 
 	// Create the service account if it does not already exist
 	foundServiceAccount := &corev1.ServiceAccount{}
-	err := r.Get(ctx, types.NamespacedName{
+	err = r.Get(ctx, types.NamespacedName{
 		Name:      desiredServiceAccount.Name,
 		Namespace: notebook.Namespace,
 	}, foundServiceAccount)
@@ -95,16 +243,118 @@ func (r *OpenshiftNotebookReconciler) ReconcileOAuthServiceAccount(notebook *nbv
 			err = ctrl.SetControllerReference(notebook, desiredServiceAccount, r.Scheme)
 			if err != nil {
 				log.Error(err, "Unable to add OwnerReference to the Service Account")
-				return err
+				return false, err
 			}
 			// Create the service account in the Openshift cluster
 			err = r.Create(ctx, desiredServiceAccount)
 			if err != nil && !apierrs.IsAlreadyExists(err) {
 				log.Error(err, "Unable to create the Service Account")
-				return err
+				return false, err
 			}
-		} else {
-			log.Error(err, "Unable to fetch the Service Account")
+			return true, nil
+		}
+		log.Error(err, "Unable to fetch the Service Account")
+		return false, err
+	}
+
+	adopt := false
+	if !metav1.IsControlledBy(foundServiceAccount, notebook) {
+		if metav1.GetControllerOf(foundServiceAccount) != nil ||
+			foundServiceAccount.Labels["notebook-name"] != notebook.Name {
+			return false, nil
+		}
+		log.Info("Adopting Service Account")
+		err = ctrl.SetControllerReference(notebook, foundServiceAccount, r.Scheme)
+		if err != nil {
+			log.Error(err, "Unable to add OwnerReference to the Service Account")
+			return false, err
+		}
+		adopt = true
+	}
+
+	// Migrate the OAuth redirect reference, keeping the annotations set by
+	// the user or by other controllers (e.g. the image pull secrets)
+	if foundServiceAccount.Annotations == nil {
+		foundServiceAccount.Annotations = map[string]string{}
+	}
+	update := mergeLabels(foundServiceAccount, desiredServiceAccount.Labels) || adopt
+	if InjectServiceAccountPullSecrets(foundServiceAccount, pullSecrets) {
+		log.Info("Updating the pull secrets of the Service Account")
+		update = true
+	}
+	for key, value := range desiredServiceAccount.Annotations {
+		if key == AnnotationImagePullSecretsInjected {
+			continue
+		}
+		if foundServiceAccount.Annotations[key] != value {
+			foundServiceAccount.Annotations[key] = value
+			update = true
+		}
+	}
+	if update {
+		err = r.Update(ctx, foundServiceAccount)
+		if err != nil {
+			log.Error(err, "Unable to update the Service Account")
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// serviceAccountPullSecrets returns the pull secrets propagated to the
+// service account of the notebook, the cluster ones along with the ones of
+// its namespace, none if the propagation is disabled.
+func (r *OpenshiftNotebookReconciler) serviceAccountPullSecrets(notebook *nbv1.Notebook,
+	ctx context.Context) ([]string, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if !r.PullSecretsConfig.ServiceAccounts {
+		return nil, nil
+	}
+	namespace := &corev1.Namespace{}
+	err := r.Get(ctx, types.NamespacedName{Name: notebook.Namespace}, namespace)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the Namespace")
+		return nil, err
+	}
+	pullSecrets, err := r.PullSecretsConfig.ForNamespace(namespace)
+	if err != nil {
+		log.Error(err, "Ignoring the pull secrets of the namespace")
+		r.recordEvent(notebook, corev1.EventTypeWarning, "InvalidImagePullSecrets", err.Error())
+	}
+	return pullSecrets, nil
+}
+
+// cleanupNotebookServiceAccounts deletes the service accounts previously
+// created for the notebook under another name, e.g. before the service account
+// suffix was changed. The service accounts still referenced by the pod
+// template (the notebook is running with a pending update) are kept.
+func (r *OpenshiftNotebookReconciler) cleanupNotebookServiceAccounts(notebook *nbv1.Notebook, ctx context.Context,
+	serviceAccountName string) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	serviceAccounts := &corev1.ServiceAccountList{}
+	err := r.List(ctx, serviceAccounts, client.InNamespace(notebook.Namespace),
+		client.MatchingLabels{"notebook-name": notebook.Name})
+	if err != nil {
+		log.Error(err, "Unable to list the Service Accounts")
+		return err
+	}
+
+	for i := range serviceAccounts.Items {
+		serviceAccount := &serviceAccounts.Items[i]
+		if serviceAccount.Name == serviceAccountName ||
+			serviceAccount.Name == notebook.Spec.Template.Spec.ServiceAccountName ||
+			!metav1.IsControlledBy(serviceAccount, notebook) {
+			continue
+		}
+		log.Info("Deleting previous Service Account", "serviceAccount", serviceAccount.Name)
+		err = r.Delete(ctx, serviceAccount)
+		if err != nil && !apierrs.IsNotFound(err) {
+			log.Error(err, "Unable to delete the Service Account", "serviceAccount", serviceAccount.Name)
 			return err
 		}
 	}
@@ -113,14 +363,12 @@ func (r *OpenshiftNotebookReconciler) ReconcileOAuthServiceAccount(notebook *nbv
 }
 
 // NewNotebookOAuthService defines the desired OAuth service object
-func NewNotebookOAuthService(notebook *nbv1.Notebook) *corev1.Service {
-	return &corev1.Service{
+func NewNotebookOAuthService(notebook *nbv1.Notebook, oauth OAuthConfig) *corev1.Service {
+	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      notebook.Name + "-tls",
 			Namespace: notebook.Namespace,
-			Labels: map[string]string{
-				"notebook-name": notebook.Name,
-			},
+			Labels:    NotebookObjectLabels(notebook, ComponentOAuthProxy),
 			Annotations: map[string]string{
 				"service.beta.openshift.io/serving-cert-secret-name": notebook.Name + "-tls",
 			},
@@ -137,6 +385,20 @@ func NewNotebookOAuthService(notebook *nbv1.Notebook) *corev1.Service {
 			},
 		},
 	}
+	if oauth.MetricsPort != 0 {
+		service.Spec.Ports = append(service.Spec.Ports, oauthMetricsServicePort(oauth))
+	}
+	return service
+}
+
+// serviceHasPort returns true if the service exposes the named port.
+func serviceHasPort(service *corev1.Service, name string) bool {
+	for _, port := range service.Spec.Ports {
+		if port.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // CompareNotebookServices checks if two services are equal, if not return false
@@ -150,10 +412,10 @@ func CompareNotebookServices(s1 corev1.Service, s2 corev1.Service) bool {
 // by the notebook OAuth proxy
 func (r *OpenshiftNotebookReconciler) ReconcileOAuthService(notebook *nbv1.Notebook, ctx context.Context) error {
 	// Initialize logger format
-	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	log := r.notebookLogger(notebook)
 
 	// Generate the desired OAuth service
-	desiredService := NewNotebookOAuthService(notebook)
+	desiredService := NewNotebookOAuthService(notebook, r.OAuthConfig)
 
 	// Create the OAuth service if it does not already exist
 	foundService := &corev1.Service{}
@@ -181,6 +443,16 @@ func (r *OpenshiftNotebookReconciler) ReconcileOAuthService(notebook *nbv1.Noteb
 			log.Error(err, "Unable to fetch the OAuth Service")
 			return err
 		}
+	} else if mergeLabels(foundService, desiredService.Labels) ||
+		serviceHasPort(foundService, OAuthMetricsPortName) != (r.OAuthConfig.MetricsPort != 0) {
+		// Expose or hide the metrics port when the metrics are toggled
+		log.Info("Reconciling OAuth Service ports and labels")
+		foundService.Spec.Ports = desiredService.Spec.Ports
+		err = r.Update(ctx, foundService)
+		if err != nil {
+			log.Error(err, "Unable to reconcile the OAuth Service ports")
+			return err
+		}
 	}
 
 	return nil
@@ -199,12 +471,10 @@ func NewNotebookOAuthSecret(notebook *nbv1.Notebook) *corev1.Secret {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      notebook.Name + "-oauth-config",
 			Namespace: notebook.Namespace,
-			Labels: map[string]string{
-				"notebook-name": notebook.Name,
-			},
+			Labels:    NotebookObjectLabels(notebook, ComponentOAuthProxy),
 		},
 		StringData: map[string]string{
-			"cookie_secret": cookieSecret,
+			OAuthCookieSecretKey: cookieSecret,
 		},
 	}
 }
@@ -212,8 +482,13 @@ func NewNotebookOAuthSecret(notebook *nbv1.Notebook) *corev1.Secret {
 // ReconcileOAuthSecret will manage the OAuth secret reconciliation required by
 // the notebook OAuth proxy
 func (r *OpenshiftNotebookReconciler) ReconcileOAuthSecret(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Check the externally managed OAuth secret instead of generating one
+	if metav1.HasAnnotation(notebook.ObjectMeta, AnnotationOAuthSecret) {
+		return r.reconcileExternalOAuthSecret(notebook, ctx)
+	}
+
 	// Initialize logger format
-	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	log := r.notebookLogger(notebook)
 
 	// Generate the desired OAuth secret
 	desiredSecret := NewNotebookOAuthSecret(notebook)
@@ -244,6 +519,13 @@ func (r *OpenshiftNotebookReconciler) ReconcileOAuthSecret(notebook *nbv1.Notebo
 			log.Error(err, "Unable to fetch the OAuth Secret")
 			return err
 		}
+	} else if mergeLabels(foundSecret, desiredSecret.Labels) {
+		log.Info("Reconciling OAuth Secret labels")
+		err = r.Update(ctx, foundSecret)
+		if err != nil {
+			log.Error(err, "Unable to reconcile the OAuth Secret labels")
+			return err
+		}
 	}
 
 	return nil
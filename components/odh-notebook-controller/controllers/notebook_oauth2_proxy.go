@@ -0,0 +1,186 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// AnnotationOIDCClientSecret selects the Secret of the namespace of the
+// notebook holding the OpenID Connect client of its oauth2-proxy, instead of
+// the Secret of the controller configuration.
+const AnnotationOIDCClientSecret = "notebooks.opendatahub.io/oidc-client-secret"
+
+const (
+	// OAuth2ProxyImage is the default image of the oauth2-proxy sidecar.
+	OAuth2ProxyImage = "quay.io/oauth2-proxy/oauth2-proxy:v7.6.0"
+	// OAuth2ProxyClientSecretKey is the key of the client secret of the
+	// OpenID Connect client in the Secret of oauth2-proxy.
+	OAuth2ProxyClientSecretKey = "client-secret"
+	// OAuth2ProxyClientIDKey is the key of the client ID of the OpenID
+	// Connect client in the Secret of oauth2-proxy, read when the controller
+	// configures none.
+	OAuth2ProxyClientIDKey = "client-id"
+
+	oauth2ProxyClientVolume = "oauth2-proxy-client"
+	oauth2ProxyClientDir    = "/etc/oauth2-proxy/client"
+)
+
+// ValidateOIDCIssuerURL checks that the issuer of the OpenID Connect provider
+// is an absolute HTTPS URL, from which oauth2-proxy discovers the provider.
+func ValidateOIDCIssuerURL(value string) error {
+	issuer, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid OpenID Connect issuer %q: %w", value, err)
+	}
+	if issuer.Scheme != "https" || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return fmt.Errorf("invalid OpenID Connect issuer %q, must be an https URL without query", value)
+	}
+	return nil
+}
+
+// OIDCClientSecretName returns the name of the Secret holding the OpenID
+// Connect client of the oauth2-proxy of the notebook.
+func OIDCClientSecretName(notebook *nbv1.Notebook, auth AuthConfig) string {
+	if name := notebook.GetAnnotations()[AnnotationOIDCClientSecret]; name != "" {
+		return name
+	}
+	return auth.OIDCClientSecretName
+}
+
+// InjectOAuth2Proxy injects the oauth2-proxy sidecar, authenticating the
+// users with the OpenID Connect provider of the controller. The client
+// secret, and the client ID if the controller configures none, are read from
+// the Secret of the client.
+func InjectOAuth2Proxy(notebook *nbv1.Notebook, oauth OAuthConfig, auth AuthConfig) error {
+	secretName := OIDCClientSecretName(notebook, auth)
+	if auth.OIDCIssuerURL == "" {
+		return fmt.Errorf("the %s auth provider is not configured in the controller", AuthProviderOAuth2Proxy)
+	}
+	if secretName == "" {
+		return fmt.Errorf("the %s auth provider requires the Secret of the OpenID Connect client in the %s annotation",
+			AuthProviderOAuth2Proxy, AnnotationOIDCClientSecret)
+	}
+	image := auth.OAuth2ProxyImage
+	if image == "" {
+		image = OAuth2ProxyImage
+	}
+	cookieExpire, cookieRefresh := cookieSettings(notebook, oauth)
+
+	args := []string{
+		"--provider=oidc",
+		"--oidc-issuer-url=" + auth.OIDCIssuerURL,
+		"--client-secret-file=" + oauth2ProxyClientDir + "/" + OAuth2ProxyClientSecretKey,
+		"--cookie-secret-file=" + oauthCookieSecretFile,
+		"--cookie-secure=true",
+		"--cookie-expire=" + cookieExpire.String(),
+		"--https-address=:8443",
+		"--tls-cert-file=" + oauthTLSCertFile,
+		"--tls-key-file=" + oauthTLSKeyFile,
+		"--upstream=" + oauthUpstream,
+		"--reverse-proxy=true",
+		"--skip-provider-button=true",
+		"--email-domain=*",
+	}
+	if cookieRefresh > 0 {
+		args = append(args, "--cookie-refresh="+cookieRefresh.String())
+	}
+	var env []corev1.EnvVar
+	if auth.OIDCClientID != "" {
+		args = append(args, "--client-id="+auth.OIDCClientID)
+	} else {
+		env = append(env, corev1.EnvVar{
+			Name: "OAUTH2_PROXY_CLIENT_ID",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  OAuth2ProxyClientIDKey,
+				},
+			},
+		})
+	}
+
+	injectAuthProxy(notebook, oauth, corev1.Container{
+		Image: image,
+		Args:  args,
+		Env:   env,
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "oauth-config",
+				MountPath: "/etc/oauth/config",
+			},
+			{
+				Name:      oauth2ProxyClientVolume,
+				MountPath: oauth2ProxyClientDir,
+			},
+		},
+	}, corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path:   "/ping",
+			Port:   intstr.FromString(OAuthServicePortName),
+			Scheme: corev1.URISchemeHTTPS,
+		},
+	})
+	setNotebookVolume(notebook, secretVolume("oauth-config", OAuthSecretName(notebook)))
+	setNotebookVolume(notebook, secretVolume(oauth2ProxyClientVolume, secretName))
+	return nil
+}
+
+// reconcileOAuth2Proxy reconciles the cookie secret of oauth2-proxy, and
+// checks the Secret of the OpenID Connect client, which is never created.
+func reconcileOAuth2Proxy(ctx context.Context, r *OpenshiftNotebookReconciler, notebook *nbv1.Notebook) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	err := r.ReconcileOAuthSecret(notebook, ctx)
+	if err != nil {
+		return err
+	}
+
+	name := OIDCClientSecretName(notebook, r.AuthConfig)
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: notebook.Namespace}, secret)
+	if apierrs.IsNotFound(err) {
+		log.Info("Waiting for the Secret of the OpenID Connect client", "secret", name)
+		r.recordEvent(notebook, corev1.EventTypeWarning, "OIDCClientSecretNotFound",
+			"The Secret %s of the OpenID Connect client does not exist", name)
+		return NewUserActionableError("OIDCClientSecretNotFound", err)
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the Secret of the OpenID Connect client")
+		return err
+	}
+
+	required := []string{OAuth2ProxyClientSecretKey}
+	if r.AuthConfig.OIDCClientID == "" {
+		required = append(required, OAuth2ProxyClientIDKey)
+	}
+	for _, key := range required {
+		if len(secret.Data[key]) == 0 {
+			err = fmt.Errorf("the Secret %s of the OpenID Connect client has no %s key", name, key)
+			r.recordEvent(notebook, corev1.EventTypeWarning, "InvalidOIDCClientSecret", err.Error())
+			return NewUserActionableError("InvalidOIDCClientSecret", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateOIDCIssuerURL(t *testing.T) {
+	assert.NoError(t, ValidateOIDCIssuerURL("https://keycloak.example.com/realms/notebooks"))
+	for _, value := range []string{"http://keycloak.example.com", "keycloak.example.com",
+		"https://keycloak.example.com/?realm=notebooks", "https:///realms/notebooks"} {
+		assert.Error(t, ValidateOIDCIssuerURL(value), value)
+	}
+}
+
+func TestInjectOAuth2ProxyClientFromSecret(t *testing.T) {
+	auth := AuthConfig{OIDCIssuerURL: "https://sso.example.com", OIDCClientSecretName: "oidc-client"}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+		Annotations: map[string]string{AnnotationOIDCClientSecret: "team-client"}}}
+
+	require.NoError(t, InjectOAuth2Proxy(notebook, OAuthConfig{}, auth))
+	proxy := notebook.Spec.Template.Spec.Containers[0]
+	for _, arg := range proxy.Args {
+		assert.NotContains(t, arg, "--client-id")
+	}
+	require.Len(t, proxy.Env, 1)
+	assert.Equal(t, "OAUTH2_PROXY_CLIENT_ID", proxy.Env[0].Name)
+	assert.Equal(t, "team-client", proxy.Env[0].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, OAuth2ProxyClientIDKey, proxy.Env[0].ValueFrom.SecretKeyRef.Key)
+	for _, volume := range notebook.Spec.Template.Spec.Volumes {
+		if volume.Name == oauth2ProxyClientVolume {
+			assert.Equal(t, "team-client", volume.Secret.SecretName)
+		}
+	}
+
+	// The controller requires an issuer
+	assert.Error(t, InjectOAuth2Proxy(notebook, OAuthConfig{}, AuthConfig{}))
+}
+
+func TestReconcileOAuth2ProxyClientSecret(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationAuthProvider: AuthProviderOAuth2Proxy}}}
+	clientSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "oidc-client", Namespace: "ns"},
+		Data: map[string][]byte{OAuth2ProxyClientSecretKey: []byte("s3cr3t")}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook, clientSecret)
+	r.AuthConfig = AuthConfig{OIDCIssuerURL: "https://sso.example.com", OIDCClientSecretName: "oidc-client"}
+	ctx := context.Background()
+
+	// The client ID is read from the Secret without --oidc-client-id
+	err := reconcileOAuth2Proxy(ctx, r, notebook)
+	class, reason := ClassifyError(err)
+	assert.Equal(t, ErrorClassUserActionable, class)
+	assert.Equal(t, "InvalidOIDCClientSecret", reason)
+
+	r.AuthConfig.OIDCClientID = "notebooks"
+	assert.NoError(t, reconcileOAuth2Proxy(ctx, r, notebook))
+
+	// The Secret selected by the notebook must exist
+	notebook.Annotations[AnnotationOIDCClientSecret] = "missing"
+	_, reason = ClassifyError(reconcileOAuth2Proxy(ctx, r, notebook))
+	assert.Equal(t, "OIDCClientSecretNotFound", reason)
+}
@@ -0,0 +1,130 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NotebookAccessConfigMapName is the ConfigMap of a namespace replacing
+	// the subject access reviews of the OAuth proxies of its notebooks, e.g.
+	// to grant the members of a project group access to shared workbenches
+	// without granting them the notebook RBAC individually.
+	NotebookAccessConfigMapName = "notebook-access"
+	// NotebookAccessSARKey holds the subject access reviews replacing the
+	// ones of the cluster, in the format of --oauth-sar-template.
+	NotebookAccessSARKey = "openshift-sar"
+	// NotebookAccessDelegateURLsKey holds the subject access reviews of the
+	// bearer tokens by path, in the format of the OAuth proxy
+	// --openshift-delegate-urls argument, e.g. {"/":{"verb":"get",...}}.
+	NotebookAccessDelegateURLsKey = "openshift-delegate-urls"
+)
+
+// NotebookAccessConfig holds the subject access reviews of the OAuth proxies
+// of the notebooks of a namespace.
+type NotebookAccessConfig struct {
+	// SARTemplate replaces the subject access review template of the
+	// cluster, kept if empty.
+	SARTemplate string
+	// DelegateURLs authorizes the bearer tokens of the clients by path, none
+	// if empty.
+	DelegateURLs string
+}
+
+// validateDelegateURLs checks the subject access reviews of the bearer tokens
+// by path, whose paths must start with a slash.
+func validateDelegateURLs(value string) error {
+	urls := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(value), &urls); err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("no path")
+	}
+	for path, sar := range urls {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid path %q, must start with /", path)
+		}
+		if !bytes.HasPrefix(bytes.TrimSpace(sar), []byte("{")) {
+			return fmt.Errorf("the subject access review of %s must be a JSON object", path)
+		}
+		if _, err := parseSARs(string(sar)); err != nil {
+			return fmt.Errorf("invalid subject access review of %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// ParseNotebookAccessConfigMap returns the subject access reviews of the
+// notebook-access ConfigMap of a namespace.
+func ParseNotebookAccessConfigMap(configMap *corev1.ConfigMap) (NotebookAccessConfig, error) {
+	config := NotebookAccessConfig{}
+	if value := strings.TrimSpace(configMap.Data[NotebookAccessSARKey]); value != "" {
+		if err := ValidateSARTemplate(value); err != nil {
+			return NotebookAccessConfig{}, fmt.Errorf("invalid %s key: %w", NotebookAccessSARKey, err)
+		}
+		config.SARTemplate = value
+	}
+	if value := strings.TrimSpace(configMap.Data[NotebookAccessDelegateURLsKey]); value != "" {
+		err := validateDelegateURLs(strings.ReplaceAll(value, SARNotebookNamePlaceholder, "notebook"))
+		if err != nil {
+			return NotebookAccessConfig{}, fmt.Errorf("invalid %s key: %w", NotebookAccessDelegateURLsKey, err)
+		}
+		config.DelegateURLs = value
+	}
+	return config, nil
+}
+
+// LoadNotebookAccessConfig reads the notebook-access ConfigMap of the
+// namespace, if any.
+func LoadNotebookAccessConfig(ctx context.Context, reader client.Reader, namespace string) (NotebookAccessConfig,
+	error) {
+	configMap := &corev1.ConfigMap{}
+	err := reader.Get(ctx, types.NamespacedName{Name: NotebookAccessConfigMapName, Namespace: namespace}, configMap)
+	if apierrs.IsNotFound(err) {
+		return NotebookAccessConfig{}, nil
+	} else if err != nil {
+		return NotebookAccessConfig{}, err
+	}
+	config, err := ParseNotebookAccessConfigMap(configMap)
+	if err != nil {
+		return NotebookAccessConfig{}, fmt.Errorf("invalid ConfigMap %s/%s: %w", namespace,
+			NotebookAccessConfigMapName, err)
+	}
+	return config, nil
+}
+
+// Apply returns the OAuth configuration with the subject access reviews of
+// the namespace.
+func (c NotebookAccessConfig) Apply(oauth OAuthConfig) OAuthConfig {
+	if c.SARTemplate != "" {
+		oauth.SARTemplate = c.SARTemplate
+	}
+	if c.DelegateURLs != "" {
+		oauth.DelegateURLs = c.DelegateURLs
+	}
+	return oauth
+}
@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseNotebookAccessConfigMap(t *testing.T) {
+	groupSAR := `{"verb":"get","resource":"services","namespace":"$(NAMESPACE)"}`
+	delegateURLs := `{"/":{"verb":"get","resource":"notebooks","resourceName":"$(NOTEBOOK_NAME)"}}`
+
+	for _, tt := range []struct {
+		name     string
+		data     map[string]string
+		expected NotebookAccessConfig
+		invalid  bool
+	}{
+		{name: "empty"},
+		{name: "sar", data: map[string]string{NotebookAccessSARKey: groupSAR},
+			expected: NotebookAccessConfig{SARTemplate: groupSAR}},
+		{name: "delegate urls", data: map[string]string{NotebookAccessDelegateURLsKey: delegateURLs},
+			expected: NotebookAccessConfig{DelegateURLs: delegateURLs}},
+		{name: "invalid sar", data: map[string]string{NotebookAccessSARKey: `{"verb":"get"}`}, invalid: true},
+		{name: "delegate urls array", data: map[string]string{NotebookAccessDelegateURLsKey: `[` + groupSAR + `]`},
+			invalid: true},
+		{name: "delegate urls relative path", data: map[string]string{
+			NotebookAccessDelegateURLsKey: `{"api":` + groupSAR + `}`}, invalid: true},
+		{name: "delegate urls sar array", data: map[string]string{
+			NotebookAccessDelegateURLsKey: `{"/":[` + groupSAR + `]}`}, invalid: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseNotebookAccessConfigMap(&corev1.ConfigMap{Data: tt.data})
+			if tt.invalid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, config)
+		})
+	}
+}
+
+func TestNotebookAccessConfigProxyArgs(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: NotebookAccessConfigMapName, Namespace: "ns"},
+		Data: map[string]string{
+			NotebookAccessSARKey:          `{"verb":"get","resource":"services","namespace":"ns"}`,
+			NotebookAccessDelegateURLsKey: `{"/":{"verb":"get","resource":"notebooks","resourceName":"$(NOTEBOOK_NAME)"}}`,
+		},
+	}
+	ctx := context.Background()
+	reader := fake.NewClientBuilder().WithObjects(configMap).Build()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	oauth := OAuthConfig{ExtraArgs: []string{`--openshift-delegate-urls={"/":{"verb":"get","resource":"pods"}}`}}
+
+	access, err := LoadNotebookAccessConfig(ctx, reader, "ns")
+	require.NoError(t, err)
+	args, err := NewProxyArgs(notebook, access.Apply(oauth))
+	require.NoError(t, err)
+	assert.Equal(t, `{"verb":"get","resource":"services","namespace":"ns"}`, args.SAR)
+	// The delegate URLs of the namespace replace the ones of the cluster
+	assert.Contains(t, args.Args(),
+		`--openshift-delegate-urls={"/":{"verb":"get","resource":"notebooks","resourceName":"nb"}}`)
+	assert.Empty(t, args.ExtraArgs)
+
+	// The namespaces without ConfigMap keep the settings of the cluster
+	access, err = LoadNotebookAccessConfig(ctx, reader, "other")
+	require.NoError(t, err)
+	assert.Equal(t, oauth, access.Apply(oauth))
+
+	configMap.Data[NotebookAccessSARKey] = "invalid"
+	require.NoError(t, reader.Update(ctx, configMap))
+	_, err = LoadNotebookAccessConfig(ctx, reader, "ns")
+	assert.Error(t, err)
+}
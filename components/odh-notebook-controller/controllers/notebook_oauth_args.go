@@ -0,0 +1,391 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// DefaultOAuthCookieExpire is the default lifetime of the OAuth proxy
+	// session cookies.
+	DefaultOAuthCookieExpire = 24 * time.Hour
+
+	oauthProvider         = "openshift"
+	oauthCookieSecretFile = "/etc/oauth/config/cookie_secret"
+	oauthTLSCertFile      = "/etc/tls/private/tls.crt"
+	oauthTLSKeyFile       = "/etc/tls/private/tls.key"
+	oauthUpstream         = "http://localhost:8888"
+)
+
+// ProxyArgs are the arguments of the OAuth proxy container, built from the
+// controller configuration and the annotations of the notebook.
+type ProxyArgs struct {
+	Provider       string
+	HTTPSAddress   string
+	ServiceAccount string
+	// CookieSecretFile, CookieExpire and CookieRefresh configure the session
+	// cookies, which are not refreshed if CookieRefresh is zero.
+	CookieSecretFile string
+	CookieExpire     time.Duration
+	CookieRefresh    time.Duration
+	TLSCert          string
+	TLSKey           string
+	Upstream         string
+	UpstreamCA       string
+	EmailDomain      string
+	// SAR is the subject access review checked by the proxy.
+	SAR string
+	// DelegateURLs are the subject access reviews of the bearer tokens by
+	// path, none if empty.
+	DelegateURLs string
+	// MetricsAddress exposes the proxy metrics, disabled if empty.
+	MetricsAddress string
+	// LogoutURL is the URL the users are redirected to on logout, none if
+	// empty.
+	LogoutURL string
+	// ExtraArgs are the arguments of the cluster, then of the notebook,
+	// appended to the others.
+	ExtraArgs []string
+}
+
+// NewProxyArgs returns the arguments of the OAuth proxy of the notebook.
+func NewProxyArgs(notebook *nbv1.Notebook, oauth OAuthConfig) (ProxyArgs, error) {
+	sar, err := NewOAuthSAR(notebook, oauth)
+	if err != nil {
+		return ProxyArgs{}, err
+	}
+	args := ProxyArgs{
+		Provider:         oauthProvider,
+		HTTPSAddress:     ":8443",
+		ServiceAccount:   OAuthServiceAccountName(notebook, oauth),
+		CookieSecretFile: oauthCookieSecretFile,
+		CookieExpire:     oauth.CookieExpire,
+		CookieRefresh:    oauth.CookieRefresh,
+		TLSCert:          oauthTLSCertFile,
+		TLSKey:           oauthTLSKeyFile,
+		Upstream:         oauthUpstream,
+		UpstreamCA:       oauthUpstreamCA(notebook, oauth),
+		EmailDomain:      "*",
+		SAR:              sar,
+		DelegateURLs:     strings.ReplaceAll(oauth.DelegateURLs, SARNotebookNamePlaceholder, notebook.Name),
+		LogoutURL:        notebook.GetAnnotations()[AnnotationLogoutUrl],
+		ExtraArgs:        oauth.ExtraArgs,
+	}
+	args.CookieExpire, args.CookieRefresh = cookieSettings(notebook, oauth)
+	if args.LogoutURL == "" {
+		args.LogoutURL = oauth.DefaultLogoutURL
+	}
+	if oauth.MetricsPort != 0 {
+		args.MetricsAddress = ":" + strconv.Itoa(int(oauth.MetricsPort))
+	}
+	// An invalid annotation, denied by the webhook, is ignored
+	if notebookArgs, err := NotebookProxyExtraArgs(notebook, oauth); err == nil && len(notebookArgs) > 0 {
+		args.ExtraArgs = append(append([]string{}, oauth.ExtraArgs...), notebookArgs...)
+	}
+	if args.DelegateURLs != "" {
+		// The delegate URLs of the namespace replace the ones of the cluster
+		extraArgs := []string{}
+		for _, arg := range args.ExtraArgs {
+			if !strings.HasPrefix(arg, "--openshift-delegate-urls=") {
+				extraArgs = append(extraArgs, arg)
+			}
+		}
+		args.ExtraArgs = extraArgs
+	}
+	return args, nil
+}
+
+// Args returns the command-line arguments of the proxy.
+func (a ProxyArgs) Args() []string {
+	args := []string{
+		"--provider=" + a.Provider,
+		"--https-address=" + a.HTTPSAddress,
+		"--http-address=",
+		"--openshift-service-account=" + a.ServiceAccount,
+		"--cookie-secret-file=" + a.CookieSecretFile,
+		"--cookie-expire=" + a.CookieExpire.String(),
+	}
+	if a.CookieRefresh > 0 {
+		args = append(args, "--cookie-refresh="+a.CookieRefresh.String())
+	}
+	args = append(args,
+		"--tls-cert="+a.TLSCert,
+		"--tls-key="+a.TLSKey,
+		"--upstream="+a.Upstream,
+		"--upstream-ca="+a.UpstreamCA,
+		"--email-domain="+a.EmailDomain,
+		"--skip-provider-button",
+		"--openshift-sar="+a.SAR,
+	)
+	if a.DelegateURLs != "" {
+		args = append(args, "--openshift-delegate-urls="+a.DelegateURLs)
+	}
+	if a.MetricsAddress != "" {
+		args = append(args, "--metrics-address="+a.MetricsAddress)
+	}
+	if a.LogoutURL != "" {
+		args = append(args, "--logout-url="+a.LogoutURL)
+	}
+	return append(args, a.ExtraArgs...)
+}
+
+const (
+	// AnnotationOAuthCookieExpire shortens the lifetime of the session cookies
+	// of the OAuth proxy of the notebook, e.g. 8h. It cannot extend the
+	// lifetime configured in the controller.
+	AnnotationOAuthCookieExpire = "notebooks.opendatahub.io/oauth-cookie-expire"
+	// AnnotationOAuthCookieRefresh sets the interval after which the session
+	// cookies of the OAuth proxy of the notebook are refreshed, e.g. 1h.
+	AnnotationOAuthCookieRefresh = "notebooks.opendatahub.io/oauth-cookie-refresh"
+)
+
+// ValidateCookieSettings checks that the session cookies expire, and that
+// they are refreshed before they expire, if ever.
+func ValidateCookieSettings(expire, refresh time.Duration) error {
+	if expire <= 0 {
+		return fmt.Errorf("the lifetime of the session cookies must be positive, got %s", expire)
+	}
+	if refresh < 0 || refresh >= expire {
+		return fmt.Errorf("the session cookies must be refreshed before they expire after %s, got %s",
+			expire, refresh)
+	}
+	return nil
+}
+
+// NotebookCookieSettings returns the lifetime and the refresh interval of the
+// session cookies of the proxy of the notebook: the settings of the
+// controller, overridden by the oauth-cookie-expire and oauth-cookie-refresh
+// annotations of the notebook, or by its session keepalive for the refresh
+// interval. The refresh interval of the controller is dropped if it exceeds
+// the shorter lifetime of the annotation.
+func NotebookCookieSettings(notebook *nbv1.Notebook, oauth OAuthConfig) (time.Duration, time.Duration, error) {
+	expire, refresh := oauth.CookieExpire, oauth.CookieRefresh
+	if expire == 0 {
+		expire = DefaultOAuthCookieExpire
+	}
+	annotations := notebook.GetAnnotations()
+	if value, ok := annotations[AnnotationOAuthCookieExpire]; ok {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s annotation: %w", AnnotationOAuthCookieExpire, err)
+		}
+		if parsed <= 0 || parsed > expire {
+			return 0, 0, fmt.Errorf("invalid %s annotation: the session cookies must expire after at most %s",
+				AnnotationOAuthCookieExpire, expire)
+		}
+		expire = parsed
+	}
+	value, ok := annotations[AnnotationOAuthCookieRefresh]
+	if !ok {
+		keepalive, err := NotebookSessionKeepalive(notebook)
+		if err != nil {
+			return 0, 0, err
+		}
+		if keepalive > 0 {
+			return expire, sessionKeepaliveCookieRefresh(keepalive, expire), nil
+		}
+		if refresh >= expire {
+			refresh = 0
+		}
+		return expire, refresh, nil
+	}
+	refresh, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s annotation: %w", AnnotationOAuthCookieRefresh, err)
+	}
+	if err = ValidateCookieSettings(expire, refresh); err != nil {
+		return 0, 0, fmt.Errorf("invalid %s annotation: %w", AnnotationOAuthCookieRefresh, err)
+	}
+	return expire, refresh, nil
+}
+
+// cookieSettings returns the settings of the session cookies of the proxy of
+// the notebook. The invalid annotations, denied by the webhook, are ignored.
+func cookieSettings(notebook *nbv1.Notebook, oauth OAuthConfig) (time.Duration, time.Duration) {
+	expire, refresh, err := NotebookCookieSettings(notebook, oauth)
+	if err != nil {
+		expire, refresh, _ = NotebookCookieSettings(&nbv1.Notebook{}, oauth)
+	}
+	return expire, refresh
+}
+
+// managedProxyArgs are the arguments of the proxy set by the controller,
+// which the extra arguments cannot override.
+var managedProxyArgs = []string{
+	"provider", "https-address", "http-address", "openshift-service-account", "cookie-secret-file",
+	"cookie-expire", "cookie-refresh", "tls-cert", "tls-key", "upstream", "upstream-ca", "email-domain",
+	"skip-provider-button", "openshift-sar", "metrics-address", "logout-url",
+}
+
+// ValidateProxyExtraArgs checks that the extra arguments of the proxy are
+// flags not overriding the ones set by the controller.
+func ValidateProxyExtraArgs(extraArgs []string) error {
+	for _, arg := range extraArgs {
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !strings.HasPrefix(arg, "--") || name == "" {
+			return fmt.Errorf("invalid OAuth proxy argument %q, expected --<flag>[=<value>]", arg)
+		}
+		for _, managed := range managedProxyArgs {
+			if name == managed {
+				return fmt.Errorf("the OAuth proxy argument --%s is set by the controller", name)
+			}
+		}
+	}
+	return nil
+}
+
+// AnnotationOAuthProxyExtraArgs appends whitespace-separated arguments to the
+// arguments of the OAuth proxy of the notebook, e.g. --cookie-samesite=none
+// for the notebooks embedded in dashboards. Only the flags allowed by the
+// controller may be set.
+const AnnotationOAuthProxyExtraArgs = "notebooks.opendatahub.io/oauth-proxy-extra-args"
+
+// DefaultAllowedNotebookProxyArgs are the flags of the proxy the notebooks
+// may set by default, none of them weakening the authentication.
+var DefaultAllowedNotebookProxyArgs = []string{"cookie-samesite", "upstream-timeout"}
+
+// ValidateAllowedNotebookProxyArgs checks that the flags the notebooks may
+// set are flag names not set by the controller.
+func ValidateAllowedNotebookProxyArgs(names []string) error {
+	for _, name := range names {
+		if name == "" || strings.HasPrefix(name, "-") || strings.Contains(name, "=") {
+			return fmt.Errorf("invalid OAuth proxy flag %q, expected its name without dashes", name)
+		}
+		for _, managed := range managedProxyArgs {
+			if name == managed {
+				return fmt.Errorf("the OAuth proxy argument --%s is set by the controller", name)
+			}
+		}
+	}
+	return nil
+}
+
+// NotebookProxyExtraArgs parses the arguments of the oauth-proxy-extra-args
+// annotation of the notebook, which must be flags allowed by the controller.
+// Nil is returned if the notebook has none.
+func NotebookProxyExtraArgs(notebook *nbv1.Notebook, oauth OAuthConfig) ([]string, error) {
+	args := strings.Fields(notebook.GetAnnotations()[AnnotationOAuthProxyExtraArgs])
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if err := ValidateProxyExtraArgs(args); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationOAuthProxyExtraArgs, err)
+	}
+	allowed := map[string]bool{}
+	for _, name := range oauth.AllowedNotebookArgs {
+		allowed[name] = true
+	}
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !allowed[name] {
+			return nil, fmt.Errorf("invalid %s annotation: the OAuth proxy argument --%s is not allowed, "+
+				"the allowed arguments are %v", AnnotationOAuthProxyExtraArgs, name, oauth.AllowedNotebookArgs)
+		}
+	}
+	return args, nil
+}
+
+// AnnotationOAuthProxyResources overrides the resources of the proxy container
+// of the notebook, e.g. cpu=500m,memory=256Mi for the notebooks serving many
+// TLS connections, used as both its requests and limits.
+const AnnotationOAuthProxyResources = "notebooks.opendatahub.io/oauth-proxy-resources"
+
+// DefaultOAuthProxyResources returns the default resources of the proxy
+// container.
+func DefaultOAuthProxyResources() corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			"cpu":    resource.MustParse("100m"),
+			"memory": resource.MustParse("64Mi"),
+		},
+		Limits: corev1.ResourceList{
+			"cpu":    resource.MustParse("100m"),
+			"memory": resource.MustParse("64Mi"),
+		},
+	}
+}
+
+// oauthProxyResources returns the resources of the proxy container: the
+// resources of the controller, overridden by the resources of the
+// oauth-proxy-resources annotation of the notebook. An invalid annotation,
+// denied by the webhook, is ignored.
+func oauthProxyResources(notebook *nbv1.Notebook, oauth OAuthConfig) corev1.ResourceRequirements {
+	resources := DefaultOAuthProxyResources()
+	if oauth.Resources != nil {
+		resources = *oauth.Resources.DeepCopy()
+	}
+	override, err := NotebookProxyResources(notebook)
+	if err != nil || override == nil {
+		return resources
+	}
+	if resources.Requests == nil {
+		resources.Requests = corev1.ResourceList{}
+	}
+	if resources.Limits == nil {
+		resources.Limits = corev1.ResourceList{}
+	}
+	for name, quantity := range override.Requests {
+		resources.Requests[name] = quantity
+		resources.Limits[name] = quantity
+	}
+	return resources
+}
+
+// NotebookProxyResources parses the resources of the oauth-proxy-resources
+// annotation of the notebook, with the syntax of ParseProxyResources. Nil is
+// returned if the notebook has none.
+func NotebookProxyResources(notebook *nbv1.Notebook) (*corev1.ResourceRequirements, error) {
+	value, ok := notebook.GetAnnotations()[AnnotationOAuthProxyResources]
+	if !ok {
+		return nil, nil
+	}
+	resources, err := ParseProxyResources(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationOAuthProxyResources, err)
+	}
+	return resources, nil
+}
+
+// ParseProxyResources parses the comma-separated cpu=<quantity> and
+// memory=<quantity> resources of the proxy, used as both its requests and
+// limits. Nil is returned if the value is empty.
+func ParseProxyResources(value string) (*corev1.ResourceRequirements, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	resources := corev1.ResourceList{}
+	for _, item := range strings.Split(value, ",") {
+		name, quantity, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || name != string(corev1.ResourceCPU) && name != string(corev1.ResourceMemory) {
+			return nil, fmt.Errorf("invalid OAuth proxy resource %q, expected cpu=<quantity> or memory=<quantity>",
+				item)
+		}
+		parsed, err := resource.ParseQuantity(quantity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OAuth proxy resource %q: %w", item, err)
+		}
+		resources[corev1.ResourceName(name)] = parsed
+	}
+	return &corev1.ResourceRequirements{Requests: resources, Limits: resources.DeepCopy()}, nil
+}
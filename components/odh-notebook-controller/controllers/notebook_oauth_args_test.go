@@ -0,0 +1,173 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewProxyArgs(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+		Annotations: map[string]string{AnnotationLogoutUrl: "https://dashboard.example.com/logout"}}}
+	oauth := OAuthConfig{
+		SARTemplate:   DefaultOAuthSARTemplate,
+		MetricsPort:   9091,
+		CookieRefresh: time.Hour,
+		ExtraArgs:     []string{"--pass-access-token"},
+	}
+
+	args, err := NewProxyArgs(notebook, oauth)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"--provider=openshift",
+		"--https-address=:8443",
+		"--http-address=",
+		"--openshift-service-account=nb",
+		"--cookie-secret-file=/etc/oauth/config/cookie_secret",
+		"--cookie-expire=24h0m0s",
+		"--cookie-refresh=1h0m0s",
+		"--tls-cert=/etc/tls/private/tls.crt",
+		"--tls-key=/etc/tls/private/tls.key",
+		"--upstream=http://localhost:8888",
+		"--upstream-ca=" + DefaultOAuthUpstreamCA,
+		"--email-domain=*",
+		"--skip-provider-button",
+		"--openshift-sar=" + args.SAR,
+		"--metrics-address=:9091",
+		"--logout-url=https://dashboard.example.com/logout",
+		"--pass-access-token",
+	}, args.Args())
+}
+
+func TestValidateProxyExtraArgs(t *testing.T) {
+	assert.NoError(t, ValidateProxyExtraArgs([]string{"--pass-access-token", "--cookie-samesite=strict"}))
+	assert.Error(t, ValidateProxyExtraArgs([]string{"pass-access-token"}))
+	assert.Error(t, ValidateProxyExtraArgs([]string{"--"}))
+	assert.Error(t, ValidateProxyExtraArgs([]string{"--upstream=http://localhost:9999"}))
+}
+
+func TestOAuthProxyResources(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	assert.Equal(t, DefaultOAuthProxyResources(), oauthProxyResources(notebook, OAuthConfig{}))
+
+	// The annotation overrides the resources it lists
+	notebook.Annotations = map[string]string{AnnotationOAuthProxyResources: "memory=256Mi"}
+	resources := oauthProxyResources(notebook, OAuthConfig{})
+	assert.True(t, resources.Limits.Memory().Equal(resource.MustParse("256Mi")))
+	assert.True(t, resources.Requests.Memory().Equal(resource.MustParse("256Mi")))
+	assert.True(t, resources.Limits.Cpu().Equal(resource.MustParse("100m")))
+
+	configured, err := ParseProxyResources("cpu=200m")
+	require.NoError(t, err)
+	resources = oauthProxyResources(notebook, OAuthConfig{Resources: configured})
+	assert.True(t, resources.Limits.Cpu().Equal(resource.MustParse("200m")))
+	assert.True(t, resources.Limits.Memory().Equal(resource.MustParse("256Mi")))
+	// The configured resources are not changed
+	assert.NotContains(t, configured.Limits, corev1.ResourceMemory)
+
+	// The invalid annotations are denied by the webhook, and ignored
+	notebook.Annotations[AnnotationOAuthProxyResources] = "gpu=1"
+	_, err = NotebookProxyResources(notebook)
+	assert.Error(t, err)
+	assert.Equal(t, DefaultOAuthProxyResources(), oauthProxyResources(notebook, OAuthConfig{}))
+}
+
+func TestNotebookProxyExtraArgs(t *testing.T) {
+	oauth := OAuthConfig{
+		SARTemplate:         DefaultOAuthSARTemplate,
+		ExtraArgs:           []string{"--pass-access-token"},
+		AllowedNotebookArgs: DefaultAllowedNotebookProxyArgs,
+	}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+		Annotations: map[string]string{AnnotationOAuthProxyExtraArgs: "--cookie-samesite=none\n --upstream-timeout=5m"}}}
+
+	args, err := NewProxyArgs(notebook, oauth)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--pass-access-token", "--cookie-samesite=none", "--upstream-timeout=5m"}, args.ExtraArgs)
+	// The arguments of the cluster are not changed
+	assert.Equal(t, []string{"--pass-access-token"}, oauth.ExtraArgs)
+
+	// The flags which are not allowed are denied by the webhook, and ignored
+	for _, value := range []string{"--skip-auth-regex=^/api", "--upstream=http://localhost:9999", "cookie-samesite"} {
+		notebook.Annotations[AnnotationOAuthProxyExtraArgs] = value
+		_, err = NotebookProxyExtraArgs(notebook, oauth)
+		assert.Error(t, err, value)
+		args, err = NewProxyArgs(notebook, oauth)
+		require.NoError(t, err)
+		assert.Equal(t, oauth.ExtraArgs, args.ExtraArgs)
+	}
+
+	oauth.AllowedNotebookArgs = append(oauth.AllowedNotebookArgs, "skip-auth-regex")
+	notebook.Annotations[AnnotationOAuthProxyExtraArgs] = "--skip-auth-regex=^/api"
+	extraArgs, err := NotebookProxyExtraArgs(notebook, oauth)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--skip-auth-regex=^/api"}, extraArgs)
+
+	assert.NoError(t, ValidateAllowedNotebookProxyArgs([]string{"skip-auth-regex"}))
+	assert.Error(t, ValidateAllowedNotebookProxyArgs([]string{"--skip-auth-regex"}))
+	assert.Error(t, ValidateAllowedNotebookProxyArgs([]string{"openshift-sar"}))
+}
+
+func TestNotebookCookieSettings(t *testing.T) {
+	oauth := OAuthConfig{SARTemplate: DefaultOAuthSARTemplate, CookieRefresh: time.Hour}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+		Annotations: map[string]string{AnnotationOAuthCookieExpire: "8h"}}}
+
+	args, err := NewProxyArgs(notebook, oauth)
+	require.NoError(t, err)
+	assert.Equal(t, 8*time.Hour, args.CookieExpire)
+	assert.Equal(t, time.Hour, args.CookieRefresh)
+
+	// The refresh of the controller is dropped if the sessions are shorter
+	notebook.Annotations[AnnotationOAuthCookieExpire] = "30m"
+	expire, refresh, err := NotebookCookieSettings(notebook, oauth)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, expire)
+	assert.Zero(t, refresh)
+
+	notebook.Annotations[AnnotationOAuthCookieRefresh] = "10m"
+	_, refresh, err = NotebookCookieSettings(notebook, oauth)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, refresh)
+
+	// The invalid annotations are denied by the webhook, and ignored
+	for _, annotations := range []map[string]string{
+		{AnnotationOAuthCookieExpire: "48h"},
+		{AnnotationOAuthCookieExpire: "0s"},
+		{AnnotationOAuthCookieExpire: "1 day"},
+		{AnnotationOAuthCookieExpire: "30m", AnnotationOAuthCookieRefresh: "1h"},
+	} {
+		notebook.Annotations = annotations
+		_, _, err = NotebookCookieSettings(notebook, oauth)
+		assert.Error(t, err, annotations)
+		args, err = NewProxyArgs(notebook, oauth)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultOAuthCookieExpire, args.CookieExpire)
+		assert.Equal(t, time.Hour, args.CookieRefresh)
+	}
+
+	assert.NoError(t, ValidateCookieSettings(DefaultOAuthCookieExpire, 0))
+	assert.Error(t, ValidateCookieSettings(0, 0))
+	assert.Error(t, ValidateCookieSettings(time.Hour, time.Hour))
+}
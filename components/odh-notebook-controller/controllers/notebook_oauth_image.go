@@ -0,0 +1,377 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultClusterPullSecret is the global pull secret of the OpenShift
+	// clusters, used by the nodes to pull the images.
+	DefaultClusterPullSecret = "openshift-config/pull-secret"
+
+	// dockerHubRegistry is the registry of the images without registry, and
+	// dockerHubHost the host serving its API.
+	dockerHubRegistry = "docker.io"
+	dockerHubHost     = "registry-1.docker.io"
+
+	// registryTimeout bounds the registry requests checking the image.
+	registryTimeout = 30 * time.Second
+)
+
+var (
+	imagePathComponent   = `[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*`
+	imageReferenceRegexp = regexp.MustCompile(`^` +
+		`(?:((?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)(?:\.(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?))*(?::[0-9]+)?)/)?` +
+		`(` + imagePathComponent + `(?:/` + imagePathComponent + `)*)` +
+		`(?::([\w][\w.-]{0,127}))?` +
+		`(?:@([a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-fA-F0-9]{32,}))?$`)
+
+	bearerChallengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+	// manifestMediaTypes are the manifest types accepted from the registry,
+	// including the manifest lists of the multi-architecture images.
+	manifestMediaTypes = []string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}
+)
+
+// ImageReference is a parsed container image reference.
+type ImageReference struct {
+	// Registry is the registry hosting the image, e.g. registry.redhat.io.
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageReference parses an image reference of the form
+// [registry/]repository[:tag][@digest], as pulled by the kubelet.
+func ParseImageReference(image string) (*ImageReference, error) {
+	match := imageReferenceRegexp.FindStringSubmatch(image)
+	if match == nil {
+		return nil, fmt.Errorf("invalid image reference %q", image)
+	}
+	ref := &ImageReference{Registry: match[1], Repository: match[2], Tag: match[3], Digest: match[4]}
+
+	// The first component is a registry only if it looks like a host name,
+	// otherwise it is part of the repository of a Docker Hub image
+	if ref.Registry != "" && !strings.ContainsAny(ref.Registry, ".:") && ref.Registry != "localhost" {
+		if strings.ToLower(ref.Registry) != ref.Registry {
+			return nil, fmt.Errorf("invalid image reference %q: repository must be lowercase", image)
+		}
+		ref.Repository = ref.Registry + "/" + ref.Repository
+		ref.Registry = ""
+	}
+	if ref.Registry == "" {
+		ref.Registry = dockerHubRegistry
+		if !strings.Contains(ref.Repository, "/") {
+			ref.Repository = "library/" + ref.Repository
+		}
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// host returns the host serving the registry API.
+func (ref *ImageReference) host() string {
+	if ref.Registry == dockerHubRegistry {
+		return dockerHubHost
+	}
+	return ref.Registry
+}
+
+// manifestReference returns the digest of the image, or its tag.
+func (ref *ImageReference) manifestReference() string {
+	if ref.Digest != "" {
+		return ref.Digest
+	}
+	return ref.Tag
+}
+
+// registryAuths holds the credentials of the registries from a pull secret.
+type registryAuths map[string]string
+
+// credentials returns the base64 encoded user:password of the registry.
+func (auths registryAuths) credentials(registry string) string {
+	if registry == dockerHubRegistry {
+		for _, key := range []string{dockerHubRegistry, "https://index.docker.io/v1/", "index.docker.io"} {
+			if auth, ok := auths[key]; ok {
+				return auth
+			}
+		}
+	}
+	return auths[registry]
+}
+
+// parsePullSecret returns the registry credentials of a dockerconfigjson
+// pull secret.
+func parsePullSecret(secret *corev1.Secret) (registryAuths, error) {
+	config := struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}{}
+	data, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		return nil, fmt.Errorf("pull secret %s/%s has no %s key", secret.Namespace, secret.Name,
+			corev1.DockerConfigJsonKey)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid pull secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	auths := registryAuths{}
+	for registry, auth := range config.Auths {
+		if auth.Auth == "" && auth.Username != "" {
+			auth.Auth = base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+		}
+		auths[registry] = auth.Auth
+	}
+	return auths, nil
+}
+
+// parseBearerChallenge returns the parameters of the Bearer challenge of a
+// WWW-Authenticate header, e.g. the realm, service and scope.
+func parseBearerChallenge(header string) (map[string]string, bool) {
+	scheme, params, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, false
+	}
+	challenge := map[string]string{}
+	for _, param := range bearerChallengeParamRegexp.FindAllStringSubmatch(params, -1) {
+		challenge[strings.ToLower(param[1])] = param[2]
+	}
+	return challenge, challenge["realm"] != ""
+}
+
+// ResolveImageDigest returns the digest of the image manifest, checking that
+// the image can be pulled with the given credentials, following the token
+// authentication of the registry API.
+func ResolveImageDigest(ctx context.Context, httpClient *http.Client, ref *ImageReference,
+	credentials string) (string, error) {
+	manifestURL := "https://" + ref.host() + "/v2/" + ref.Repository + "/manifests/" + ref.manifestReference()
+	headManifest := func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		return resp, nil
+	}
+
+	resp, err := headManifest("")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization := ""
+		if challenge, ok := parseBearerChallenge(resp.Header.Get("WWW-Authenticate")); ok {
+			token, err := registryToken(ctx, httpClient, challenge, credentials)
+			if err != nil {
+				return "", err
+			}
+			authorization = "Bearer " + token
+		} else if credentials != "" {
+			authorization = "Basic " + credentials
+		} else {
+			return "", fmt.Errorf("registry %s requires credentials, none found in the pull secret", ref.Registry)
+		}
+		if resp, err = headManifest(authorization); err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to pull the manifest %s: %s", manifestURL, resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if ref.Digest != "" && digest != "" && digest != ref.Digest {
+		return "", fmt.Errorf("registry returned the digest %s instead of %s", digest, ref.Digest)
+	}
+	if digest == "" {
+		digest = ref.Digest
+	}
+	return digest, nil
+}
+
+// registryToken requests a pull token from the authorization server of the
+// registry.
+func registryToken(ctx context.Context, httpClient *http.Client, challenge map[string]string,
+	credentials string) (string, error) {
+	tokenURL, err := url.Parse(challenge["realm"])
+	if err != nil {
+		return "", err
+	}
+	query := tokenURL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if challenge[key] != "" {
+			query.Set(key, challenge[key])
+		}
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if credentials != "" {
+		req.Header.Set("Authorization", "Basic "+credentials)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get a pull token from %s: %s", tokenURL.Host, resp.Status)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", errors.New("no pull token returned by " + tokenURL.Host)
+	}
+	return token.Token, nil
+}
+
+// ProxyImageValidator periodically checks that the OAuth proxy image can be
+// pulled with the cluster pull secret, and fails the readiness of the
+// controller otherwise, rather than leaving the notebooks in ImagePullBackOff.
+type ProxyImageValidator struct {
+	// Reader reads the pull secret, it must not depend on the cache since
+	// the readiness is checked before the cache is started.
+	Reader client.Reader
+	Log    logr.Logger
+	Image  string
+	// PullSecret is the pull secret of the registry credentials.
+	PullSecret types.NamespacedName
+	// Interval is the interval between two checks.
+	Interval time.Duration
+	// HTTPClient queries the registry, a client with a default timeout if
+	// nil.
+	HTTPClient *http.Client
+
+	mu      sync.RWMutex
+	checked bool
+	err     error
+}
+
+// Start checks the image until the context is cancelled.
+func (v *ProxyImageValidator) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		err := v.Validate(ctx)
+		if err != nil {
+			v.Log.Error(err, "OAuth proxy image cannot be pulled, the notebooks would not start", "image", v.Image)
+		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.checked, v.err = true, err
+	}, v.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes every replica check the image for its readiness.
+func (v *ProxyImageValidator) NeedLeaderElection() bool {
+	return false
+}
+
+// Check is the readiness check of the image.
+func (v *ProxyImageValidator) Check(_ *http.Request) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if !v.checked {
+		return fmt.Errorf("OAuth proxy image %s not checked yet", v.Image)
+	}
+	if v.err != nil {
+		return fmt.Errorf("OAuth proxy image %s cannot be pulled: %w", v.Image, v.err)
+	}
+	return nil
+}
+
+// Validate resolves the digest of the image with the credentials of the pull
+// secret.
+func (v *ProxyImageValidator) Validate(ctx context.Context) error {
+	ref, err := ParseImageReference(v.Image)
+	if err != nil {
+		return err
+	}
+
+	credentials := ""
+	secret := &corev1.Secret{}
+	if err := v.Reader.Get(ctx, v.PullSecret, secret); err != nil {
+		v.Log.Info("Unable to read the cluster pull secret, checking the OAuth proxy image anonymously",
+			"secret", v.PullSecret, "error", err.Error())
+	} else {
+		auths, err := parsePullSecret(secret)
+		if err != nil {
+			return err
+		}
+		credentials = auths.credentials(ref.Registry)
+	}
+
+	httpClient := v.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: registryTimeout}
+	}
+	digest, err := ResolveImageDigest(ctx, httpClient, ref, credentials)
+	if err != nil {
+		return err
+	}
+	if ref.Digest == "" {
+		v.Log.Info("OAuth proxy image is referenced by tag, pin its digest for reproducible notebooks",
+			"image", v.Image, "digest", digest)
+	}
+	return nil
+}
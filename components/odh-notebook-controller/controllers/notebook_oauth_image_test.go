@@ -0,0 +1,127 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testDigest = "sha256:4f8d66597feeb32bb18699326029f9a71a5aca4a57679d636b876377c2e95695"
+
+func TestParseImageReference(t *testing.T) {
+	for _, tt := range []struct {
+		image    string
+		expected *ImageReference
+	}{
+		{OAuthProxyImage, &ImageReference{Registry: "registry.redhat.io",
+			Repository: "openshift4/ose-oauth-proxy", Digest: testDigest}},
+		{"quay.io/org/proxy:v4.14", &ImageReference{Registry: "quay.io", Repository: "org/proxy", Tag: "v4.14"}},
+		{"localhost:5000/proxy", &ImageReference{Registry: "localhost:5000", Repository: "proxy", Tag: "latest"}},
+		{"org/proxy:1", &ImageReference{Registry: "docker.io", Repository: "org/proxy", Tag: "1"}},
+		{"proxy", &ImageReference{Registry: "docker.io", Repository: "library/proxy", Tag: "latest"}},
+		{"Proxy", nil},
+		{"quay.io/org/proxy:", nil},
+		{"quay.io/org/proxy@sha256:abc", nil},
+		{"", nil},
+	} {
+		t.Run(tt.image, func(t *testing.T) {
+			ref, err := ParseImageReference(tt.image)
+			if tt.expected == nil {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ref)
+		})
+	}
+}
+
+// newTestRegistry serves the given image manifest to the clients
+// authenticated with the token of the user:password credentials.
+func newTestRegistry(t *testing.T, repository, tag string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.Header.Get("Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("user:password")) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:"+repository+":pull", r.URL.Query().Get("scope"))
+			_, _ = w.Write([]byte(`{"token":"pull-token"}`))
+		case r.Header.Get("Authorization") != "Bearer pull-token":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",`+
+				`scope="repository:`+repository+`:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method == http.MethodHead && r.URL.Path == "/v2/"+repository+"/manifests/"+tag:
+			w.Header().Set("Docker-Content-Digest", testDigest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxyImageValidator(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(t, "org/proxy", "v1")
+	host := strings.TrimPrefix(registry.URL, "https://")
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: "openshift-config"},
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"` + host +
+			`":{"username":"user","password":"password"}}}`)},
+	}
+	newValidator := func(image string, objects ...*corev1.Secret) *ProxyImageValidator {
+		builder := fake.NewClientBuilder().WithScheme(scheme)
+		for _, object := range objects {
+			builder = builder.WithObjects(object)
+		}
+		return &ProxyImageValidator{
+			Reader:     builder.Build(),
+			Log:        logr.Discard(),
+			Image:      image,
+			PullSecret: types.NamespacedName{Namespace: "openshift-config", Name: "pull-secret"},
+			HTTPClient: registry.Client(),
+		}
+	}
+
+	validator := newValidator(host+"/org/proxy:v1", pullSecret)
+	assert.Error(t, validator.Check(nil), "not ready until the image is checked")
+	require.NoError(t, validator.Validate(ctx))
+
+	// The image is not found, or the credentials are missing
+	assert.Error(t, newValidator(host+"/org/proxy:v2", pullSecret).Validate(ctx))
+	assert.Error(t, newValidator(host+"/org/proxy:v1").Validate(ctx))
+	assert.Error(t, newValidator(host+"/org/proxy@sha256:"+strings.Repeat("0", 64), pullSecret).Validate(ctx))
+}
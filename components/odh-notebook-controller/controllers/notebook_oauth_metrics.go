@@ -0,0 +1,184 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	OAuthMetricsPortName = "oauth-metrics"
+	// DefaultMetricsNamespace is the namespace of the Prometheus instance
+	// scraping the user workloads on OpenShift.
+	DefaultMetricsNamespace = "openshift-user-workload-monitoring"
+)
+
+// ServiceMonitorGVK identifies the Prometheus operator ServiceMonitor
+// resource.
+var ServiceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
+// +kubebuilder:rbac:groups="monitoring.coreos.com",resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+
+// ServiceMonitorsAreServed returns true if the ServiceMonitor CRD is
+// installed in the cluster.
+func ServiceMonitorsAreServed(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(ServiceMonitorGVK.GroupKind(), ServiceMonitorGVK.Version)
+	return err == nil
+}
+
+// oauthMetricsServicePort returns the Service port exposing the OAuth proxy
+// metrics.
+func oauthMetricsServicePort(oauth OAuthConfig) corev1.ServicePort {
+	return corev1.ServicePort{
+		Name:       OAuthMetricsPortName,
+		Port:       oauth.MetricsPort,
+		TargetPort: intstr.FromString(OAuthMetricsPortName),
+		Protocol:   corev1.ProtocolTCP,
+	}
+}
+
+// NewOAuthMetricsServiceMonitor defines the ServiceMonitor scraping the OAuth
+// proxy metrics of the notebook.
+func NewOAuthMetricsServiceMonitor(notebook *nbv1.Notebook) *unstructured.Unstructured {
+	serviceMonitor := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"notebook-name": notebook.Name,
+				},
+			},
+			"endpoints": []interface{}{
+				map[string]interface{}{
+					"port":   OAuthMetricsPortName,
+					"scheme": "http",
+				},
+			},
+		},
+	}}
+	serviceMonitor.SetGroupVersionKind(ServiceMonitorGVK)
+	serviceMonitor.SetName(notebook.Name + "-oauth-metrics")
+	serviceMonitor.SetNamespace(notebook.Namespace)
+	serviceMonitor.SetLabels(NotebookObjectLabels(notebook, ComponentOAuthProxy))
+	return serviceMonitor
+}
+
+// NewOAuthMetricsNetworkPolicy defines the network policy allowing Prometheus
+// to scrape the OAuth proxy metrics.
+func NewOAuthMetricsNetworkPolicy(notebook *nbv1.Notebook, oauth OAuthConfig) *netv1.NetworkPolicy {
+	npProtocol := corev1.ProtocolTCP
+	return &netv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      notebook.Name + "-oauth-metrics-np",
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentNetworkPolicy),
+		},
+		Spec: netv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"notebook-name": notebook.Name,
+				},
+			},
+			Ingress: []netv1.NetworkPolicyIngressRule{
+				{
+					Ports: []netv1.NetworkPolicyPort{
+						{
+							Protocol: &npProtocol,
+							Port: &intstr.IntOrString{
+								IntVal: oauth.MetricsPort,
+							},
+						},
+					},
+					From: []netv1.NetworkPolicyPeer{
+						{
+							NamespaceSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"kubernetes.io/metadata.name": oauth.MetricsNamespace,
+								},
+							},
+						},
+					},
+				},
+			},
+			PolicyTypes: []netv1.PolicyType{
+				netv1.PolicyTypeIngress,
+			},
+		},
+	}
+}
+
+// injectOAuthProxyMetrics exposes the metrics of the OAuth proxy container.
+func injectOAuthProxyMetrics(proxyContainer *corev1.Container, oauth OAuthConfig) {
+	if oauth.MetricsPort == 0 {
+		return
+	}
+	proxyContainer.Ports = append(proxyContainer.Ports, corev1.ContainerPort{
+		Name:          OAuthMetricsPortName,
+		ContainerPort: oauth.MetricsPort,
+		Protocol:      corev1.ProtocolTCP,
+	})
+}
+
+// ReconcileOAuthMetrics manages the ServiceMonitor and the network policy of
+// the OAuth proxy metrics, and deletes them when the metrics are disabled.
+func (r *OpenshiftNotebookReconciler) ReconcileOAuthMetrics(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if r.OAuthConfig.MetricsPort == 0 {
+		err := r.deleteControlledObject(ctx, notebook, notebook.Name+"-oauth-metrics-np", &netv1.NetworkPolicy{})
+		if err != nil {
+			log.Error(err, "Unable to delete the OAuth metrics network policy")
+			return err
+		}
+		if r.MonitoringEnabled {
+			serviceMonitor := &unstructured.Unstructured{}
+			serviceMonitor.SetGroupVersionKind(ServiceMonitorGVK)
+			err = r.deleteControlledObject(ctx, notebook, notebook.Name+"-oauth-metrics", serviceMonitor)
+			if err != nil {
+				log.Error(err, "Unable to delete the OAuth metrics ServiceMonitor")
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := r.reconcileNetworkPolicy(NewOAuthMetricsNetworkPolicy(notebook, r.OAuthConfig), ctx, notebook)
+	if err != nil {
+		log.Error(err, "Unable to reconcile the OAuth metrics network policy")
+		return err
+	}
+	if r.MonitoringEnabled {
+		err = r.reconcileUnstructured(ctx, notebook, NewOAuthMetricsServiceMonitor(notebook))
+		if err != nil {
+			log.Error(err, "Unable to reconcile the OAuth metrics ServiceMonitor")
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestInjectOAuthProxyMetrics(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	require.NoError(t, InjectOAuthProxy(notebook, OAuthConfig{MetricsPort: 9091}))
+	proxy := notebook.Spec.Template.Spec.Containers[0]
+	assert.Contains(t, proxy.Args, "--metrics-address=:9091")
+	assert.Contains(t, proxy.Ports, corev1.ContainerPort{Name: OAuthMetricsPortName, ContainerPort: 9091,
+		Protocol: corev1.ProtocolTCP})
+
+	notebook = &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	require.NoError(t, InjectOAuthProxy(notebook, OAuthConfig{}))
+	assert.Len(t, notebook.Spec.Template.Spec.Containers[0].Ports, 1)
+}
+
+func TestReconcileOAuthMetrics(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	oauth := OAuthConfig{MetricsPort: 9091, MetricsNamespace: DefaultMetricsNamespace}
+	r := newTestReconciler(t, oauth, notebook, NewNotebookOAuthService(notebook, OAuthConfig{}))
+	r.Scheme.AddKnownTypeWithName(ServiceMonitorGVK, &unstructured.Unstructured{})
+	r.MonitoringEnabled = true
+	serviceKey := client.ObjectKey{Namespace: "ns", Name: "nb-tls"}
+	networkPolicyKey := client.ObjectKey{Namespace: "ns", Name: "nb-oauth-metrics-np"}
+	serviceMonitorKey := client.ObjectKey{Namespace: "ns", Name: "nb-oauth-metrics"}
+	newServiceMonitor := func() *unstructured.Unstructured {
+		serviceMonitor := &unstructured.Unstructured{}
+		serviceMonitor.SetGroupVersionKind(ServiceMonitorGVK)
+		return serviceMonitor
+	}
+
+	// The metrics port is added to the existing service
+	require.NoError(t, r.ReconcileOAuthService(notebook, ctx))
+	require.NoError(t, r.ReconcileOAuthMetrics(notebook, ctx))
+	service := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, serviceKey, service))
+	assert.True(t, serviceHasPort(service, OAuthMetricsPortName))
+	networkPolicy := &netv1.NetworkPolicy{}
+	require.NoError(t, r.Get(ctx, networkPolicyKey, networkPolicy))
+	assert.Equal(t, int32(9091), networkPolicy.Spec.Ingress[0].Ports[0].Port.IntVal)
+	serviceMonitor := newServiceMonitor()
+	require.NoError(t, r.Get(ctx, serviceMonitorKey, serviceMonitor))
+	assert.True(t, metav1.IsControlledBy(serviceMonitor, notebook))
+
+	// Everything is removed once the metrics are disabled
+	r.OAuthConfig = OAuthConfig{}
+	require.NoError(t, r.ReconcileOAuthService(notebook, ctx))
+	require.NoError(t, r.ReconcileOAuthMetrics(notebook, ctx))
+	require.NoError(t, r.Get(ctx, serviceKey, service))
+	assert.False(t, serviceHasPort(service, OAuthMetricsPortName))
+	assert.Error(t, r.Get(ctx, networkPolicyKey, &netv1.NetworkPolicy{}))
+	assert.Error(t, r.Get(ctx, serviceMonitorKey, newServiceMonitor()))
+}
@@ -0,0 +1,109 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultOAuthReadinessTimeout bounds the time the reconciliation lock is
+	// held waiting for the OAuth Service and Route after the notebook
+	// creation, so that a cluster without router does not block the notebooks
+	// forever.
+	DefaultOAuthReadinessTimeout = 2 * time.Minute
+
+	// oauthReadinessRequeueInterval is the interval between two checks of the
+	// OAuth Service and Route while the reconciliation lock is held.
+	oauthReadinessRequeueInterval = 2 * time.Second
+)
+
+// +kubebuilder:rbac:groups="discovery.k8s.io",resources=endpointslices,verbs=get;list;watch
+
+// routeIsAdmitted returns true if the route is admitted by at least one router.
+func routeIsAdmitted(route *routev1.Route) bool {
+	for _, ingress := range route.Status.Ingress {
+		for _, condition := range ingress.Conditions {
+			if condition.Type == routev1.RouteAdmitted && condition.Status == corev1.ConditionTrue {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OAuthRoutingIsReady returns true once the OAuth Service of the notebook is
+// published in an EndpointSlice and its Route, if the notebook is exposed
+// through a Route, is admitted, otherwise it returns the reason of the wait. The notebook pod is not running while the
+// reconciliation lock is held, so the endpoints themselves are not ready yet,
+// but the traffic reaches the pod as soon as it is.
+func OAuthRoutingIsReady(ctx context.Context, c client.Client, notebook *nbv1.Notebook,
+	exposure ExposureConfig) (bool, string, error) {
+	endpointSlices := &discoveryv1.EndpointSliceList{}
+	err := c.List(ctx, endpointSlices, client.InNamespace(notebook.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: notebook.Name + "-tls"})
+	if err != nil {
+		return false, "", err
+	}
+	if len(endpointSlices.Items) == 0 {
+		return false, "OAuth Service has no endpoints", nil
+	}
+	if !exposure.RouteIsEnabled(notebook) {
+		return true, "", nil
+	}
+
+	route := &routev1.Route{}
+	err = c.Get(ctx, types.NamespacedName{Name: notebook.Name, Namespace: notebook.Namespace}, route)
+	if apierrs.IsNotFound(err) {
+		return false, "OAuth Route not found", nil
+	} else if err != nil {
+		return false, "", err
+	}
+	if !routeIsAdmitted(route) {
+		return false, "OAuth Route not admitted", nil
+	}
+	return true, "", nil
+}
+
+// waitForOAuthRouting returns true while the reconciliation lock must be held
+// until the notebook can be reached through its OAuth Route.
+func (r *OpenshiftNotebookReconciler) waitForOAuthRouting(notebook *nbv1.Notebook,
+	ctx context.Context) (bool, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if time.Since(notebook.CreationTimestamp.Time) > r.OAuthConfig.ReadinessTimeout {
+		return false, nil
+	}
+	ready, reason, err := OAuthRoutingIsReady(ctx, r.Client, notebook, r.ExposureConfig)
+	if err != nil {
+		log.Error(err, "Unable to check the OAuth Service and Route")
+		return false, err
+	}
+	if !ready {
+		log.Info("Waiting for the OAuth Service and Route", "reason", reason)
+	}
+	return !ready, nil
+}
@@ -0,0 +1,116 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestOAuthRoutingIsReady(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	endpointSlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nb-tls-abcde",
+			Namespace: "ns",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "nb-tls"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+	}
+	newRoute := func(admitted corev1.ConditionStatus) *routev1.Route {
+		route := NewNotebookOAuthRoute(notebook)
+		if admitted != "" {
+			route.Status.Ingress = []routev1.RouteIngress{{
+				RouterName: "default",
+				Conditions: []routev1.RouteIngressCondition{{Type: routev1.RouteAdmitted, Status: admitted}},
+			}}
+		}
+		return route
+	}
+
+	for _, tt := range []struct {
+		name     string
+		objects  []client.Object
+		expected bool
+		reason   string
+	}{
+		{name: "no endpoints", objects: []client.Object{newRoute(corev1.ConditionTrue)},
+			reason: "OAuth Service has no endpoints"},
+		{name: "no route", objects: []client.Object{endpointSlice}, reason: "OAuth Route not found"},
+		{name: "route pending", objects: []client.Object{endpointSlice, newRoute("")},
+			reason: "OAuth Route not admitted"},
+		{name: "route rejected", objects: []client.Object{endpointSlice, newRoute(corev1.ConditionFalse)},
+			reason: "OAuth Route not admitted"},
+		{name: "ready", objects: []client.Object{endpointSlice, newRoute(corev1.ConditionTrue)}, expected: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(t, OAuthConfig{}, tt.objects...)
+			ready, reason, err := OAuthRoutingIsReady(context.Background(), r.Client, notebook, ExposureConfig{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ready)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+
+	// The notebooks which are not exposed have no Route to wait for
+	internal := notebook.DeepCopy()
+	internal.Annotations = map[string]string{AnnotationExpose: "false"}
+	r := newTestReconciler(t, OAuthConfig{}, endpointSlice)
+	ready, _, err := OAuthRoutingIsReady(context.Background(), r.Client, internal, ExposureConfig{})
+	require.NoError(t, err)
+	assert.True(t, ready)
+	ready, _, err = OAuthRoutingIsReady(context.Background(), r.Client, notebook,
+		ExposureConfig{Mode: ExposureLoadBalancer})
+	require.NoError(t, err)
+	assert.True(t, ready)
+}
+
+func TestWaitForOAuthRouting(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name:              "nb",
+		Namespace:         "ns",
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Minute)),
+	}}
+
+	// Disabled
+	r := newTestReconciler(t, OAuthConfig{})
+	waiting, err := r.waitForOAuthRouting(notebook, ctx)
+	require.NoError(t, err)
+	assert.False(t, waiting)
+
+	// Waiting for the OAuth Service and Route
+	r = newTestReconciler(t, OAuthConfig{ReadinessTimeout: DefaultOAuthReadinessTimeout})
+	waiting, err = r.waitForOAuthRouting(notebook, ctx)
+	require.NoError(t, err)
+	assert.True(t, waiting)
+
+	// Timed out
+	notebook.CreationTimestamp = metav1.NewTime(time.Now().Add(-DefaultOAuthReadinessTimeout))
+	waiting, err = r.waitForOAuthRouting(notebook, ctx)
+	require.NoError(t, err)
+	assert.False(t, waiting)
+}
@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+)
+
+const (
+	// AnnotationOAuthSAR holds additional subject access reviews (a JSON
+	// object or array) the users must pass to access the notebook. They are
+	// checked on top of the cluster ones, so they can only restrict access.
+	AnnotationOAuthSAR = "notebooks.opendatahub.io/oauth-sar"
+
+	// SARNotebookNamePlaceholder is replaced by the notebook name in the
+	// subject access review template.
+	SARNotebookNamePlaceholder = "$(NOTEBOOK_NAME)"
+	// DefaultOAuthSARTemplate grants access to the users allowed to get the
+	// notebook.
+	DefaultOAuthSARTemplate = `{"verb":"get","resource":"notebooks","resourceAPIGroup":"kubeflow.org",` +
+		`"resourceName":"` + SARNotebookNamePlaceholder + `","namespace":"$(NAMESPACE)"}`
+)
+
+// parseSARs parses a JSON object or array of subject access reviews, in the
+// format of the OAuth proxy --openshift-sar argument.
+func parseSARs(value string) ([]json.RawMessage, error) {
+	value = strings.TrimSpace(value)
+	sars := []json.RawMessage{}
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &sars); err != nil {
+			return nil, err
+		}
+	} else {
+		sars = append(sars, json.RawMessage(value))
+	}
+	if len(sars) == 0 {
+		return nil, fmt.Errorf("no subject access review")
+	}
+
+	for index, sar := range sars {
+		review := struct {
+			Verb     string `json:"verb"`
+			Resource string `json:"resource"`
+		}{}
+		if err := json.Unmarshal(sar, &review); err != nil {
+			return nil, err
+		}
+		if review.Verb == "" || review.Resource == "" {
+			return nil, fmt.Errorf("subject access review %s must define a verb and a resource", sar)
+		}
+		compacted := &bytes.Buffer{}
+		if err := json.Compact(compacted, sar); err != nil {
+			return nil, err
+		}
+		sars[index] = compacted.Bytes()
+	}
+	return sars, nil
+}
+
+// ValidateSARTemplate checks the subject access review template of the OAuth
+// proxy.
+func ValidateSARTemplate(template string) error {
+	_, err := parseSARs(strings.ReplaceAll(template, SARNotebookNamePlaceholder, "notebook"))
+	return err
+}
+
+// NewOAuthSAR returns the subject access reviews checked by the OAuth proxy of
+// the notebook: the ones of the cluster template, plus the ones of the notebook
+// annotation, if any.
+func NewOAuthSAR(notebook *nbv1.Notebook, oauth OAuthConfig) (string, error) {
+	template := oauth.SARTemplate
+	if template == "" {
+		template = DefaultOAuthSARTemplate
+	}
+	template = strings.ReplaceAll(template, SARNotebookNamePlaceholder, notebook.Name)
+	sars, err := parseSARs(template)
+	if err != nil {
+		return "", fmt.Errorf("invalid subject access review template: %w", err)
+	}
+
+	value := notebook.GetAnnotations()[AnnotationOAuthSAR]
+	if value == "" {
+		return template, nil
+	}
+	notebookSARs, err := parseSARs(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s annotation: %w", AnnotationOAuthSAR, err)
+	}
+	// The OAuth proxy grants access only if all the reviews of the array pass
+	data, err := json.Marshal(append(sars, notebookSARs...))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
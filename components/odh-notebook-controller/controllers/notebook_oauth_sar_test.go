@@ -0,0 +1,71 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewOAuthSAR(t *testing.T) {
+	defaultSAR := `{"verb":"get","resource":"notebooks","resourceAPIGroup":"kubeflow.org",` +
+		`"resourceName":"nb","namespace":"$(NAMESPACE)"}`
+	dashboardSAR := `{"verb":"access","resource":"dashboards","namespace":"$(NAMESPACE)"}`
+
+	for _, tt := range []struct {
+		name       string
+		template   string
+		annotation string
+		expected   string
+		invalid    bool
+	}{
+		{name: "default", expected: defaultSAR},
+		{name: "template", template: `{"verb":"update","resource":"notebooks","resourceName":"$(NOTEBOOK_NAME)"}`,
+			expected: `{"verb":"update","resource":"notebooks","resourceName":"nb"}`},
+		{name: "annotation object", annotation: dashboardSAR, expected: "[" + defaultSAR + "," + dashboardSAR + "]"},
+		{name: "annotation array", annotation: "[\n  " + dashboardSAR + "\n]",
+			expected: "[" + defaultSAR + "," + dashboardSAR + "]"},
+		{name: "invalid template", template: `{"resource":"notebooks"}`, invalid: true},
+		{name: "invalid annotation", annotation: `{"verb":"get"`, invalid: true},
+		{name: "empty annotation array", annotation: `[]`, invalid: true},
+		{name: "annotation without resource", annotation: `[{"verb":"get"}]`, invalid: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+			if tt.annotation != "" {
+				notebook.Annotations = map[string]string{AnnotationOAuthSAR: tt.annotation}
+			}
+			sar, err := NewOAuthSAR(notebook, OAuthConfig{SARTemplate: tt.template})
+			if tt.invalid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sar)
+		})
+	}
+}
+
+func TestValidateSARTemplate(t *testing.T) {
+	assert.NoError(t, ValidateSARTemplate(DefaultOAuthSARTemplate))
+	assert.NoError(t, ValidateSARTemplate(`[{"verb":"get","resource":"notebooks"},{"verb":"get","resource":"pods"}]`))
+	assert.Error(t, ValidateSARTemplate(""))
+	assert.Error(t, ValidateSARTemplate(`{"verb":"get"}`))
+}
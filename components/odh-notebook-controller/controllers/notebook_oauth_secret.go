@@ -0,0 +1,106 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// AnnotationOAuthSecret references an externally managed Secret holding
+	// the OAuth proxy cookie secret (e.g. synced from Vault by the External
+	// Secrets operator), used instead of the generated one.
+	AnnotationOAuthSecret = "notebooks.opendatahub.io/oauth-secret"
+	// OAuthCookieSecretKey is the Secret key of the OAuth proxy cookie secret.
+	OAuthCookieSecretKey = "cookie_secret"
+)
+
+// OAuthSecretName returns the name of the Secret holding the OAuth proxy
+// cookie secret of the notebook.
+func OAuthSecretName(notebook *nbv1.Notebook) string {
+	if name := notebook.GetAnnotations()[AnnotationOAuthSecret]; name != "" {
+		return name
+	}
+	return notebook.Name + "-oauth-config"
+}
+
+// ValidateOAuthSecretAnnotation checks the name of the externally managed
+// OAuth Secret of the notebook.
+func ValidateOAuthSecretAnnotation(notebook *nbv1.Notebook) error {
+	name, ok := notebook.GetAnnotations()[AnnotationOAuthSecret]
+	if !ok {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid %s annotation %q: %s", AnnotationOAuthSecret, name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// ValidateOAuthSecret checks that the Secret holds a cookie secret the OAuth
+// proxy accepts, i.e. of 16, 24 or 32 bytes.
+func ValidateOAuthSecret(secret *corev1.Secret) error {
+	cookieSecret, ok := secret.Data[OAuthCookieSecretKey]
+	if !ok {
+		return fmt.Errorf("the Secret %s has no %s key", secret.Name, OAuthCookieSecretKey)
+	}
+	switch len(cookieSecret) {
+	case 16, 24, 32:
+		return nil
+	default:
+		return fmt.Errorf("the %s key of the Secret %s must hold 16, 24 or 32 bytes, got %d",
+			OAuthCookieSecretKey, secret.Name, len(cookieSecret))
+	}
+}
+
+// reconcileExternalOAuthSecret checks the externally managed OAuth Secret of
+// the notebook, the controller never creates nor updates it.
+func (r *OpenshiftNotebookReconciler) reconcileExternalOAuthSecret(notebook *nbv1.Notebook,
+	ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{
+		Name:      OAuthSecretName(notebook),
+		Namespace: notebook.Namespace,
+	}, secret)
+	if apierrs.IsNotFound(err) {
+		log.Info("Waiting for the external OAuth Secret", "secret", OAuthSecretName(notebook))
+		r.recordEvent(notebook, corev1.EventTypeWarning, "OAuthSecretNotFound",
+			"The external OAuth Secret %s does not exist", OAuthSecretName(notebook))
+		return err
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the external OAuth Secret")
+		return err
+	}
+
+	err = ValidateOAuthSecret(secret)
+	if err != nil {
+		log.Error(err, "Invalid external OAuth Secret")
+		r.recordEvent(notebook, corev1.EventTypeWarning, "InvalidOAuthSecret", err.Error())
+		return err
+	}
+	return nil
+}
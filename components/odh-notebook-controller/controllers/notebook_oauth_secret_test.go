@@ -0,0 +1,95 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestOAuthSecretName(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+	assert.Equal(t, "nb-oauth-config", OAuthSecretName(notebook))
+	assert.NoError(t, ValidateOAuthSecretAnnotation(notebook))
+
+	notebook.Annotations = map[string]string{AnnotationOAuthSecret: "vault-cookie"}
+	assert.Equal(t, "vault-cookie", OAuthSecretName(notebook))
+	assert.NoError(t, ValidateOAuthSecretAnnotation(notebook))
+
+	notebook.Annotations[AnnotationOAuthSecret] = "Vault_Cookie"
+	assert.Error(t, ValidateOAuthSecretAnnotation(notebook))
+}
+
+func TestValidateOAuthSecret(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    map[string][]byte
+		invalid bool
+	}{
+		{name: "16 bytes", data: map[string][]byte{OAuthCookieSecretKey: make([]byte, 16)}},
+		{name: "32 bytes", data: map[string][]byte{OAuthCookieSecretKey: make([]byte, 32)}},
+		{name: "20 bytes", data: map[string][]byte{OAuthCookieSecretKey: make([]byte, 20)}, invalid: true},
+		{name: "missing key", data: map[string][]byte{"cookie": make([]byte, 32)}, invalid: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOAuthSecret(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}, Data: tt.data})
+			if tt.invalid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReconcileExternalOAuthSecret(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationOAuthSecret: "vault-cookie"}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	// The controller waits for the external secret
+	err := r.ReconcileOAuthSecret(notebook, ctx)
+	assert.True(t, apierrs.IsNotFound(err))
+	assert.Contains(t, <-recorder.Events, "OAuthSecretNotFound")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault-cookie", Namespace: "ns"},
+		Data:       map[string][]byte{OAuthCookieSecretKey: []byte("short")},
+	}
+	require.NoError(t, r.Create(ctx, secret))
+	assert.Error(t, r.ReconcileOAuthSecret(notebook, ctx))
+	assert.Contains(t, <-recorder.Events, "InvalidOAuthSecret")
+
+	secret.Data[OAuthCookieSecretKey] = make([]byte, 32)
+	require.NoError(t, r.Update(ctx, secret))
+	require.NoError(t, r.ReconcileOAuthSecret(notebook, ctx))
+
+	// The generated secret is not created
+	err = r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "nb-oauth-config"}, &corev1.Secret{})
+	assert.True(t, apierrs.IsNotFound(err))
+}
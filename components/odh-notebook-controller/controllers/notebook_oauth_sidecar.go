@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// OAuthProxyContainerName is the name of the OAuth proxy sidecar container.
+const OAuthProxyContainerName = "oauth-proxy"
+
+// nativeSidecarsMinVersion is the first Kubernetes version enabling the
+// native sidecar containers by default.
+var nativeSidecarsMinVersion = version.MustParseGeneric("1.29.0")
+
+// NativeSidecarsAreSupported returns true if the Kubernetes API server runs
+// the native sidecar containers, i.e. the init containers with the Always
+// restart policy.
+func NativeSidecarsAreSupported(config *rest.Config) (bool, error) {
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return false, err
+	}
+	info, err := client.ServerVersion()
+	if err != nil {
+		return false, err
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return false, err
+	}
+	return serverVersion.AtLeast(nativeSidecarsMinVersion), nil
+}
+
+// setOAuthProxyContainer adds the OAuth proxy container to the notebook. As a
+// native sidecar, the proxy is started and ready before the notebook
+// containers and stopped after them, so that the route never reaches a pod
+// without proxy.
+func setOAuthProxyContainer(notebook *nbv1.Notebook, proxyContainer corev1.Container, nativeSidecar bool) {
+	podSpec := &notebook.Spec.Template.Spec
+	if !nativeSidecar {
+		podSpec.InitContainers = removeContainer(podSpec.InitContainers, OAuthProxyContainerName)
+		podSpec.Containers = upsertContainer(podSpec.Containers, proxyContainer)
+		return
+	}
+
+	restartPolicy := corev1.ContainerRestartPolicyAlways
+	proxyContainer.RestartPolicy = &restartPolicy
+	// The kubelet starts the next containers once the startup probe of the
+	// sidecar succeeds
+	startupProbe := proxyContainer.ReadinessProbe.DeepCopy()
+	startupProbe.InitialDelaySeconds = 0
+	startupProbe.PeriodSeconds = 1
+	startupProbe.FailureThreshold = 60
+	proxyContainer.StartupProbe = startupProbe
+	podSpec.Containers = removeContainer(podSpec.Containers, OAuthProxyContainerName)
+	podSpec.InitContainers = upsertContainer(podSpec.InitContainers, proxyContainer)
+}
+
+// upsertContainer replaces the container of the same name, or appends the
+// container.
+func upsertContainer(containers []corev1.Container, container corev1.Container) []corev1.Container {
+	for index := range containers {
+		if containers[index].Name == container.Name {
+			containers[index] = container
+			return containers
+		}
+	}
+	return append(containers, container)
+}
+
+// removeContainer removes the container of the given name.
+func removeContainer(containers []corev1.Container, name string) []corev1.Container {
+	for index := range containers {
+		if containers[index].Name == name {
+			return append(containers[:index], containers[index+1:]...)
+		}
+	}
+	return containers
+}
@@ -0,0 +1,86 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/rest"
+)
+
+func TestInjectOAuthProxyNativeSidecar(t *testing.T) {
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers:     []corev1.Container{{Name: "nb"}},
+		}}},
+	}
+	oauth := OAuthConfig{ProxyImage: "oauth-proxy:latest", NativeSidecar: true}
+
+	require.NoError(t, InjectOAuthProxy(notebook, oauth))
+	podSpec := notebook.Spec.Template.Spec
+	require.Len(t, podSpec.InitContainers, 2)
+	require.Len(t, podSpec.Containers, 1)
+	proxy := podSpec.InitContainers[1]
+	assert.Equal(t, OAuthProxyContainerName, proxy.Name)
+	require.NotNil(t, proxy.RestartPolicy)
+	assert.Equal(t, corev1.ContainerRestartPolicyAlways, *proxy.RestartPolicy)
+	require.NotNil(t, proxy.StartupProbe)
+	assert.Equal(t, proxy.ReadinessProbe.HTTPGet, proxy.StartupProbe.HTTPGet)
+
+	// The proxy is moved back to the regular containers once disabled
+	oauth.NativeSidecar = false
+	require.NoError(t, InjectOAuthProxy(notebook, oauth))
+	podSpec = notebook.Spec.Template.Spec
+	require.Len(t, podSpec.InitContainers, 1)
+	require.Len(t, podSpec.Containers, 2)
+	assert.Equal(t, OAuthProxyContainerName, podSpec.Containers[1].Name)
+	assert.Nil(t, podSpec.Containers[1].RestartPolicy)
+	assert.Nil(t, podSpec.Containers[1].StartupProbe)
+}
+
+func TestNativeSidecarsAreSupported(t *testing.T) {
+	for _, tt := range []struct {
+		gitVersion string
+		supported  bool
+	}{
+		{gitVersion: "v1.28.9", supported: false},
+		{gitVersion: "v1.29.0", supported: true},
+		{gitVersion: "v1.30.4+2c5f5e5", supported: true},
+	} {
+		t.Run(tt.gitVersion, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(apiversion.Info{GitVersion: tt.gitVersion})
+			}))
+			defer server.Close()
+
+			supported, err := NativeSidecarsAreSupported(&rest.Config{Host: server.URL})
+			require.NoError(t, err)
+			assert.Equal(t, tt.supported, supported)
+		})
+	}
+}
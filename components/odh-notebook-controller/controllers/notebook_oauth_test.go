@@ -0,0 +1,157 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestReconciler(t *testing.T, oauth OAuthConfig, objects ...client.Object) *OpenshiftNotebookReconciler {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, nbv1.AddToScheme(scheme))
+	require.NoError(t, routev1.AddToScheme(scheme))
+	return &OpenshiftNotebookReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+			WithIndex(&routev1.Route{}, RouteHostIndex, routeHost).Build(),
+		Scheme:      scheme,
+		Log:         logr.Discard(),
+		OAuthConfig: oauth,
+	}
+}
+
+func TestOAuthServiceAccountName(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+	assert.Equal(t, "nb", OAuthServiceAccountName(notebook, OAuthConfig{}))
+	assert.Equal(t, "nb-oauth", OAuthServiceAccountName(notebook, OAuthConfig{ServiceAccountSuffix: "-oauth"}))
+
+	notebook.Annotations = map[string]string{AnnotationOAuthServiceAccount: "nb-oauth-1"}
+	assert.Equal(t, "nb-oauth-1", OAuthServiceAccountName(notebook, OAuthConfig{ServiceAccountSuffix: "-oauth"}))
+}
+
+func TestReconcileOAuthServiceAccount(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	userServiceAccount := func(name string) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}}
+	}
+	previousServiceAccount := func(name string, controlled bool) *corev1.ServiceAccount {
+		serviceAccount := NewNotebookServiceAccount(notebook, name)
+		if controlled {
+			serviceAccount.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(notebook,
+				nbv1.GroupVersion.WithKind("Notebook"))}
+		}
+		delete(serviceAccount.Annotations, "serviceaccounts.openshift.io/oauth-redirectreference.first")
+		return serviceAccount
+	}
+
+	for _, tt := range []struct {
+		name             string
+		suffix           string
+		podAccount       string
+		existing         []client.Object
+		expected         string
+		expectedCount    int
+		expectedDeleted  []string
+		expectAnnotation bool
+	}{
+		{name: "create", expected: "nb", expectedCount: 1},
+		{name: "adopt", existing: []client.Object{previousServiceAccount("nb", false)}, expected: "nb",
+			expectedCount: 1},
+		{name: "user service account", existing: []client.Object{userServiceAccount("nb")},
+			expected: "nb-1", expectedCount: 2, expectAnnotation: true},
+		{name: "user service accounts with suffix", suffix: "-oauth",
+			existing: []client.Object{userServiceAccount("nb-oauth"), userServiceAccount("nb-oauth-1")},
+			expected: "nb-oauth-2", expectedCount: 3, expectAnnotation: true},
+		{name: "suffix changed", suffix: "-oauth", existing: []client.Object{previousServiceAccount("nb", true)},
+			expected: "nb-oauth", expectedCount: 1, expectedDeleted: []string{"nb"}},
+		{name: "suffix changed while running", suffix: "-oauth", podAccount: "nb",
+			existing: []client.Object{previousServiceAccount("nb", true)}, expected: "nb-oauth",
+			expectedCount: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			nb := notebook.DeepCopy()
+			nb.Spec.Template.Spec.ServiceAccountName = tt.podAccount
+			r := newTestReconciler(t, OAuthConfig{ServiceAccountSuffix: tt.suffix}, append(tt.existing, nb)...)
+
+			require.NoError(t, r.ReconcileOAuthServiceAccount(nb, ctx))
+
+			serviceAccount := &corev1.ServiceAccount{}
+			require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: tt.expected}, serviceAccount))
+			assert.True(t, metav1.IsControlledBy(serviceAccount, nb))
+			assert.Contains(t, serviceAccount.Annotations, "serviceaccounts.openshift.io/oauth-redirectreference.first")
+			assert.Equal(t, tt.expected, OAuthServiceAccountName(nb, r.OAuthConfig))
+			if tt.expectAnnotation {
+				assert.Equal(t, tt.expected, nb.Annotations[AnnotationOAuthServiceAccount])
+			} else {
+				assert.NotContains(t, nb.Annotations, AnnotationOAuthServiceAccount)
+			}
+
+			serviceAccounts := &corev1.ServiceAccountList{}
+			require.NoError(t, r.List(ctx, serviceAccounts))
+			assert.Len(t, serviceAccounts.Items, tt.expectedCount)
+			for _, name := range tt.expectedDeleted {
+				err := r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: name}, &corev1.ServiceAccount{})
+				assert.Error(t, err, name)
+			}
+		})
+	}
+}
+
+func TestInjectOAuthFromNamespace(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		label      string
+		annotation string
+		changed    bool
+	}{
+		{name: "unlabeled namespace"},
+		{name: "labeled namespace", label: "true", changed: true},
+		{name: "labeled namespace overrides the notebook", label: "true", annotation: "false", changed: true},
+		{name: "already enabled", label: "true", annotation: "true"},
+		{name: "disabled namespace", label: "false", annotation: "false"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}
+			if tt.label != "" {
+				namespace.Labels = map[string]string{LabelNamespaceInjectOAuth: tt.label}
+			}
+			notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+			if tt.annotation != "" {
+				notebook.Annotations = map[string]string{AnnotationInjectOAuth: tt.annotation}
+			}
+
+			assert.Equal(t, tt.changed, InjectOAuthFromNamespace(notebook, namespace))
+			expected, _ := strconv.ParseBool(tt.label)
+			expected = expected || tt.annotation == "true"
+			assert.Equal(t, expected, OAuthInjectionIsEnabled(notebook.ObjectMeta))
+		})
+	}
+}
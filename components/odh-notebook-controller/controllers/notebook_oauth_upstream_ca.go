@@ -0,0 +1,99 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	// DefaultOAuthUpstreamCA is the CA checking the upstream of the OAuth
+	// proxy by default, i.e. the CA of the service account.
+	DefaultOAuthUpstreamCA = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	// AnnotationOAuthUpstreamCA references a ConfigMap of the notebook
+	// namespace holding the CA of the upstream in its ca.crt key, for the
+	// upstreams re-encrypted with a custom CA.
+	AnnotationOAuthUpstreamCA = "notebooks.opendatahub.io/oauth-upstream-ca"
+
+	oauthUpstreamCAVolumeName = "oauth-upstream-ca"
+	oauthUpstreamCAMountPath  = "/etc/oauth/upstream-ca"
+	oauthUpstreamCAKey        = "ca.crt"
+)
+
+// ValidateOAuthUpstreamCAAnnotation checks the name of the upstream CA
+// ConfigMap of the notebook.
+func ValidateOAuthUpstreamCAAnnotation(notebook *nbv1.Notebook) error {
+	name, ok := notebook.GetAnnotations()[AnnotationOAuthUpstreamCA]
+	if !ok {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid %s annotation %q: %s", AnnotationOAuthUpstreamCA, name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// oauthUpstreamCA returns the path of the CA checking the upstream of the
+// OAuth proxy.
+func oauthUpstreamCA(notebook *nbv1.Notebook, oauth OAuthConfig) string {
+	if metav1.HasAnnotation(notebook.ObjectMeta, AnnotationOAuthUpstreamCA) {
+		return oauthUpstreamCAMountPath + "/" + oauthUpstreamCAKey
+	}
+	if oauth.UpstreamCA != "" {
+		return oauth.UpstreamCA
+	}
+	return DefaultOAuthUpstreamCA
+}
+
+// injectOAuthUpstreamCAVolume mounts the upstream CA ConfigMap of the
+// notebook in the OAuth proxy container, or removes its volume once the
+// notebook no longer references one.
+func injectOAuthUpstreamCAVolume(notebook *nbv1.Notebook, proxyContainer *corev1.Container) {
+	volumes := &notebook.Spec.Template.Spec.Volumes
+	for index, volume := range *volumes {
+		if volume.Name == oauthUpstreamCAVolumeName {
+			*volumes = append((*volumes)[:index], (*volumes)[index+1:]...)
+			break
+		}
+	}
+
+	configMap := notebook.GetAnnotations()[AnnotationOAuthUpstreamCA]
+	if configMap == "" {
+		return
+	}
+	*volumes = append(*volumes, corev1.Volume{
+		Name: oauthUpstreamCAVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+				Items:                []corev1.KeyToPath{{Key: oauthUpstreamCAKey, Path: oauthUpstreamCAKey}},
+				DefaultMode:          pointer.Int32Ptr(420),
+			},
+		},
+	})
+	proxyContainer.VolumeMounts = append(proxyContainer.VolumeMounts, corev1.VolumeMount{
+		Name:      oauthUpstreamCAVolumeName,
+		MountPath: oauthUpstreamCAMountPath,
+		ReadOnly:  true,
+	})
+}
@@ -0,0 +1,76 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInjectOAuthProxyUpstreamCA(t *testing.T) {
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb"}},
+		}}},
+	}
+	proxy := func() corev1.Container {
+		for _, container := range notebook.Spec.Template.Spec.Containers {
+			if container.Name == OAuthProxyContainerName {
+				return container
+			}
+		}
+		t.Fatal("OAuth proxy container not found")
+		return corev1.Container{}
+	}
+	hasVolume := func() bool {
+		for _, volume := range notebook.Spec.Template.Spec.Volumes {
+			if volume.Name == oauthUpstreamCAVolumeName {
+				return true
+			}
+		}
+		return false
+	}
+
+	require.NoError(t, InjectOAuthProxy(notebook, OAuthConfig{}))
+	assert.Contains(t, proxy().Args, "--upstream-ca="+DefaultOAuthUpstreamCA)
+
+	require.NoError(t, InjectOAuthProxy(notebook, OAuthConfig{UpstreamCA: "/etc/pki/upstream.crt"}))
+	assert.Contains(t, proxy().Args, "--upstream-ca=/etc/pki/upstream.crt")
+	assert.False(t, hasVolume())
+
+	// The upstream CA of the notebook is mounted from its ConfigMap
+	notebook.Annotations = map[string]string{AnnotationOAuthUpstreamCA: "ingress-ca"}
+	require.NoError(t, ValidateOAuthUpstreamCAAnnotation(notebook))
+	require.NoError(t, InjectOAuthProxy(notebook, OAuthConfig{UpstreamCA: "/etc/pki/upstream.crt"}))
+	assert.Contains(t, proxy().Args, "--upstream-ca=/etc/oauth/upstream-ca/ca.crt")
+	assert.Contains(t, proxy().VolumeMounts, corev1.VolumeMount{
+		Name: oauthUpstreamCAVolumeName, MountPath: "/etc/oauth/upstream-ca", ReadOnly: true})
+	assert.True(t, hasVolume())
+
+	// The volume is removed once the annotation is removed
+	notebook.Annotations = nil
+	require.NoError(t, InjectOAuthProxy(notebook, OAuthConfig{}))
+	assert.False(t, hasVolume())
+
+	notebook.Annotations = map[string]string{AnnotationOAuthUpstreamCA: "Ingress_CA"}
+	assert.Error(t, ValidateOAuthUpstreamCAAnnotation(notebook))
+}
@@ -0,0 +1,170 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// servingCertSecretAnnotation names the secret holding the serving
+	// certificate of a service, generated by the OpenShift service CA.
+	servingCertSecretAnnotation = "service.beta.openshift.io/serving-cert-secret-name"
+	// originatingServiceUIDAnnotation holds the UID of the service of a
+	// serving certificate secret.
+	originatingServiceUIDAnnotation = "service.beta.openshift.io/originating-service-uid"
+)
+
+// +kubebuilder:rbac:groups="",resources=secrets;services,verbs=delete
+
+// orphanableObjectLists returns the lists of the types of the objects created
+// for the notebooks which are left behind when the ownership is broken, e.g.
+// when a notebook is deleted with the orphan propagation policy.
+func orphanableObjectLists() []client.ObjectList {
+	return []client.ObjectList{
+		&corev1.SecretList{},
+		&corev1.ServiceList{},
+		&corev1.ServiceAccountList{},
+		&routev1.RouteList{},
+	}
+}
+
+// ReconcileOrphanedObjects finds the objects created by the controller which
+// lost their owner reference. The objects created for the notebook, or for a
+// deleted notebook with the same name, are adopted. The objects of the other
+// deleted notebooks, e.g. before the notebook was renamed, are deleted. The
+// objects are matched by the UID of their notebook rather than by name.
+func (r *OpenshiftNotebookReconciler) ReconcileOrphanedObjects(notebook *nbv1.Notebook,
+	ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	notebookList := &nbv1.NotebookList{}
+	if err := r.List(ctx, notebookList, client.InNamespace(notebook.Namespace)); err != nil {
+		log.Error(err, "Unable to list the notebooks")
+		return err
+	}
+	notebookUIDs := map[string]bool{}
+	for _, item := range notebookList.Items {
+		notebookUIDs[string(item.UID)] = true
+	}
+
+	for _, list := range orphanableObjectLists() {
+		err := r.List(ctx, list, client.InNamespace(notebook.Namespace),
+			client.MatchingLabels{LabelManagedBy: ManagedByValue}, client.HasLabels{LabelNotebookUID})
+		if err != nil {
+			log.Error(err, "Unable to list the notebook objects")
+			return err
+		}
+		objects, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range objects {
+			object, ok := item.(client.Object)
+			if !ok || metav1.GetControllerOf(object) != nil || !object.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+			labels := object.GetLabels()
+			uid := labels[LabelNotebookUID]
+			switch {
+			case uid == string(notebook.UID) || (!notebookUIDs[uid] && labels[LabelNotebookName] == notebook.Name):
+				if err := r.adoptOrphanedObject(ctx, notebook, object); err != nil {
+					return err
+				}
+			case !notebookUIDs[uid]:
+				if err := r.deleteOrphanedObject(ctx, notebook, object); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// adoptOrphanedObject makes the notebook the controller of the object, and
+// labels it with the notebook UID.
+func (r *OpenshiftNotebookReconciler) adoptOrphanedObject(ctx context.Context, notebook *nbv1.Notebook,
+	object client.Object) error {
+	log := r.notebookLogger(notebook).WithValues("kind", kindOf(r.Scheme, object), "name", object.GetName())
+
+	log.Info("Adopting orphaned object", "previousUID", object.GetLabels()[LabelNotebookUID])
+	if err := ctrl.SetControllerReference(notebook, object, r.Scheme); err != nil {
+		log.Error(err, "Unable to add OwnerReference to the orphaned object")
+		return err
+	}
+	mergeLabels(object, map[string]string{LabelNotebookUID: string(notebook.UID)})
+	if err := r.Update(ctx, object); err != nil {
+		log.Error(err, "Unable to adopt the orphaned object")
+		return err
+	}
+	return nil
+}
+
+// deleteOrphanedObject deletes the object of a deleted notebook. The serving
+// certificate secret of a service, which is not labeled, is deleted along.
+func (r *OpenshiftNotebookReconciler) deleteOrphanedObject(ctx context.Context, notebook *nbv1.Notebook,
+	object client.Object) error {
+	log := r.notebookLogger(notebook).WithValues("kind", kindOf(r.Scheme, object), "name", object.GetName())
+
+	log.Info("Deleting orphaned object of a deleted notebook", "notebookName", object.GetLabels()[LabelNotebookName],
+		"notebookUID", object.GetLabels()[LabelNotebookUID])
+	if err := r.Delete(ctx, object); err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to delete the orphaned object")
+		return err
+	}
+
+	secretName := object.GetAnnotations()[servingCertSecretAnnotation]
+	if _, ok := object.(*corev1.Service); !ok || secretName == "" {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: object.GetNamespace()}, secret)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if secret.Annotations[originatingServiceUIDAnnotation] != string(object.GetUID()) {
+		return nil
+	}
+	log.Info("Deleting the serving certificate of the orphaned Service", "secret", secretName)
+	if err := r.Delete(ctx, secret); err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to delete the serving certificate secret", "secret", secretName)
+		return err
+	}
+	return nil
+}
+
+// kindOf returns the kind of the object, for logging.
+func kindOf(scheme *runtime.Scheme, object client.Object) string {
+	gvks, _, err := scheme.ObjectKinds(object)
+	if err != nil || len(gvks) == 0 {
+		return ""
+	}
+	return gvks[0].Kind
+}
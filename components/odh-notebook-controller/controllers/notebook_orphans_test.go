@@ -0,0 +1,76 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileOrphanedObjects(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	other := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns", UID: "other-uid"}}
+	labels := func(name, uid string) map[string]string {
+		return map[string]string{LabelNotebookName: name, LabelNotebookUID: uid, LabelManagedBy: ManagedByValue}
+	}
+
+	// The secret of the previous notebook with the same name is adopted
+	recreatedSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "nb-oauth-config", Namespace: "ns",
+		Labels: labels("nb", "previous-nb-uid")}}
+	// The objects of the notebook renamed from "old" are deleted
+	renamedSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "old-oauth-config", Namespace: "ns",
+		Labels: labels("old", "old-uid")}}
+	renamedRoute := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "ns",
+		Labels: labels("old", "old-uid")}}
+	renamedService := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "old-tls", Namespace: "ns",
+		UID: "old-tls-uid", Labels: labels("old", "old-uid"),
+		Annotations: map[string]string{servingCertSecretAnnotation: "old-tls"}}}
+	renamedCertificate := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "old-tls", Namespace: "ns",
+		Annotations: map[string]string{originatingServiceUIDAnnotation: "old-tls-uid"}}}
+	// The objects of the existing notebooks and the user objects are kept
+	otherSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other-oauth-config", Namespace: "ns",
+		Labels: labels("other", "other-uid")}}
+	userSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "old-data", Namespace: "ns",
+		Labels: map[string]string{LabelNotebookName: "old"}}}
+
+	r := newTestReconciler(t, OAuthConfig{}, notebook, other, recreatedSecret, renamedSecret, renamedRoute,
+		renamedService, renamedCertificate, otherSecret, userSecret)
+	require.NoError(t, r.ReconcileOrphanedObjects(notebook, ctx))
+
+	adopted := &corev1.Secret{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(recreatedSecret), adopted))
+	assert.True(t, metav1.IsControlledBy(adopted, notebook))
+	assert.Equal(t, "nb-uid", adopted.Labels[LabelNotebookUID])
+
+	for _, object := range []client.Object{renamedSecret, renamedRoute, renamedService, renamedCertificate} {
+		err := r.Get(ctx, client.ObjectKeyFromObject(object), object)
+		assert.True(t, apierrs.IsNotFound(err), "%s should be deleted", object.GetName())
+	}
+	for _, object := range []client.Object{otherSecret, userSecret} {
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(object), object))
+		assert.Nil(t, metav1.GetControllerOf(object))
+	}
+}
@@ -0,0 +1,322 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationPlacementCluster requests the cluster the notebook runs on.
+	AnnotationPlacementCluster = "notebooks.opendatahub.io/placement-cluster"
+	// AnnotationPlacement names the Placement of the external scheduler
+	// deciding the cluster the notebook runs on, in the notebook namespace.
+	AnnotationPlacement = "notebooks.opendatahub.io/placement"
+	// AnnotationPlacementDecision records the cluster the notebook was
+	// placed on.
+	AnnotationPlacementDecision = "notebooks.opendatahub.io/placement-decision"
+
+	// LabelNotebookNamespace identifies the namespace of the notebook
+	// mirrored by a ManifestWork, as the ManifestWorks live in the namespaces
+	// of the managed clusters.
+	LabelNotebookNamespace = "notebooks.opendatahub.io/notebook-namespace"
+	// LabelPlacement is set by the external scheduler on the decisions of a
+	// Placement.
+	LabelPlacement = "cluster.open-cluster-management.io/placement"
+
+	// placementRequeueInterval is the interval between two checks of a
+	// pending placement decision.
+	placementRequeueInterval = 30 * time.Second
+)
+
+var (
+	// ManifestWorkGVK identifies the Work API resource mirroring the notebooks
+	// on the managed clusters.
+	ManifestWorkGVK = schema.GroupVersionKind{
+		Group:   "work.open-cluster-management.io",
+		Version: "v1",
+		Kind:    "ManifestWork",
+	}
+	// PlacementDecisionGVK identifies the decisions of the external
+	// scheduler.
+	PlacementDecisionGVK = schema.GroupVersionKind{
+		Group:   "cluster.open-cluster-management.io",
+		Version: "v1beta1",
+		Kind:    "PlacementDecision",
+	}
+
+	// ErrPlacementPending is returned while the external scheduler has not
+	// decided the cluster of the notebook.
+	ErrPlacementPending = errors.New("the placement of the notebook is not decided yet")
+)
+
+// +kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=placementdecisions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=work.open-cluster-management.io,resources=manifestworks,verbs=get;list;watch;create;update;patch;delete
+
+// PlacementDecider decides the cluster a notebook runs on, for hub-spoke
+// topologies where the notebooks are created on the hub cluster.
+type PlacementDecider interface {
+	// Decide returns the name of the cluster the notebook runs on, an empty
+	// string if the notebook is not placed, or ErrPlacementPending while the
+	// decision is not made.
+	Decide(ctx context.Context, notebook *nbv1.Notebook) (string, error)
+}
+
+// AnnotationPlacementDecider places the notebooks on the cluster of their
+// AnnotationPlacementCluster annotation, or on the cluster decided for the
+// Placement of their AnnotationPlacement annotation.
+type AnnotationPlacementDecider struct {
+	Reader client.Reader
+}
+
+// Decide returns the cluster requested by the notebook annotations.
+func (d *AnnotationPlacementDecider) Decide(ctx context.Context, notebook *nbv1.Notebook) (string, error) {
+	if cluster := notebook.GetAnnotations()[AnnotationPlacementCluster]; cluster != "" {
+		return cluster, nil
+	}
+	placement := notebook.GetAnnotations()[AnnotationPlacement]
+	if placement == "" {
+		return "", nil
+	}
+
+	decisions := &unstructured.UnstructuredList{}
+	decisions.SetGroupVersionKind(PlacementDecisionGVK.GroupVersion().WithKind(PlacementDecisionGVK.Kind + "List"))
+	if err := d.Reader.List(ctx, decisions, client.InNamespace(notebook.Namespace),
+		client.MatchingLabels{LabelPlacement: placement}); err != nil {
+		return "", err
+	}
+	for _, decision := range decisions.Items {
+		clusters, _, _ := unstructured.NestedSlice(decision.Object, "status", "decisions")
+		for _, item := range clusters {
+			if entry, ok := item.(map[string]interface{}); ok {
+				if cluster, _ := entry["clusterName"].(string); cluster != "" {
+					return cluster, nil
+				}
+			}
+		}
+	}
+	return "", ErrPlacementPending
+}
+
+// PlacementConfig holds the multi-cluster placement of the notebooks.
+type PlacementConfig struct {
+	// Decider decides the cluster of the notebooks. The placement is
+	// disabled if nil.
+	Decider PlacementDecider
+	// LocalClusterName is the name of the cluster the controller runs on,
+	// the notebooks placed on it are reconciled locally.
+	LocalClusterName string
+}
+
+// IsRemote returns true if the notebooks placed on the cluster run on a
+// remote cluster.
+func (c PlacementConfig) IsRemote(cluster string) bool {
+	return cluster != "" && cluster != c.LocalClusterName
+}
+
+// ManifestWorksAreServed returns true if the Work API is installed in the
+// cluster.
+func ManifestWorksAreServed(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(ManifestWorkGVK.GroupKind(), ManifestWorkGVK.Version)
+	return err == nil
+}
+
+// ManifestWorkName returns the name of the ManifestWork mirroring the
+// notebook, unique across the namespaces of the hub cluster.
+func ManifestWorkName(namespace, name string) string {
+	return "notebook-" + namespace + "-" + name
+}
+
+// notebookManifestWorkSelector selects the ManifestWorks mirroring the
+// notebook in every managed cluster.
+func notebookManifestWorkSelector(key types.NamespacedName) client.MatchingLabels {
+	return client.MatchingLabels{
+		LabelNotebookName:      key.Name,
+		LabelNotebookNamespace: key.Namespace,
+		LabelManagedBy:         ManagedByValue,
+	}
+}
+
+// NewNotebookManifestWork defines the ManifestWork mirroring the notebook on
+// the given managed cluster.
+func NewNotebookManifestWork(notebook *nbv1.Notebook, cluster string) (*unstructured.Unstructured, error) {
+	mirror := &nbv1.Notebook{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: nbv1.GroupVersion.String(),
+			Kind:       "Notebook",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        notebook.Name,
+			Namespace:   notebook.Namespace,
+			Labels:      notebook.Labels,
+			Annotations: map[string]string{},
+		},
+		Spec: notebook.Spec,
+	}
+	for key, value := range notebook.Annotations {
+		switch key {
+		case AnnotationPlacementCluster, AnnotationPlacement, AnnotationPlacementDecision:
+		default:
+			mirror.Annotations[key] = value
+		}
+	}
+	manifest, err := runtime.DefaultUnstructuredConverter.ToUnstructured(mirror)
+	if err != nil {
+		return nil, err
+	}
+	unstructured.RemoveNestedField(manifest, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(manifest, "status")
+
+	work := &unstructured.Unstructured{}
+	work.SetGroupVersionKind(ManifestWorkGVK)
+	work.SetName(ManifestWorkName(notebook.Namespace, notebook.Name))
+	work.SetNamespace(cluster)
+	labels := NotebookObjectLabels(notebook, ComponentPlacement)
+	labels[LabelNotebookNamespace] = notebook.Namespace
+	work.SetLabels(labels)
+	err = unstructured.SetNestedSlice(work.Object, []interface{}{manifest}, "spec", "workload", "manifests")
+	return work, err
+}
+
+// ReconcilePlacement decides the cluster of the notebook, records the decision
+// in the notebook annotations, and mirrors the notebooks placed on a remote
+// cluster through a ManifestWork. Returns the remote cluster of the notebook,
+// or an empty string if the notebook is reconciled locally.
+func (r *OpenshiftNotebookReconciler) ReconcilePlacement(notebook *nbv1.Notebook, ctx context.Context) (string, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if r.PlacementConfig.Decider == nil {
+		return "", nil
+	}
+	cluster, err := r.PlacementConfig.Decider.Decide(ctx, notebook)
+	if err != nil {
+		return "", err
+	}
+
+	if notebook.GetAnnotations()[AnnotationPlacementDecision] != cluster {
+		log.Info("Recording the placement decision", "cluster", cluster)
+		value, _ := json.Marshal(cluster)
+		if cluster == "" {
+			value = []byte("null")
+		}
+		patch := client.RawPatch(types.MergePatchType,
+			[]byte(`{"metadata":{"annotations":{"`+AnnotationPlacementDecision+`":`+string(value)+`}}}`))
+		if err := r.Patch(ctx, notebook, patch); err != nil {
+			log.Error(err, "Unable to record the placement decision")
+			return "", err
+		}
+		r.recordEvent(notebook, "Normal", "Placed", "Notebook placed on the cluster %q", cluster)
+	}
+
+	if !r.PlacementConfig.IsRemote(cluster) {
+		return "", r.DeleteNotebookManifestWorks(ctx, client.ObjectKeyFromObject(notebook), "")
+	}
+	if !r.ManifestWorksEnabled {
+		r.recordEvent(notebook, "Warning", "PlacementUnsupported",
+			"Notebook placed on the cluster %q, but the ManifestWork resources are not served", cluster)
+		return cluster, nil
+	}
+	if err := r.DeleteNotebookManifestWorks(ctx, client.ObjectKeyFromObject(notebook), cluster); err != nil {
+		return "", err
+	}
+	return cluster, r.reconcileManifestWork(notebook, cluster, ctx)
+}
+
+// reconcileManifestWork creates or updates the ManifestWork mirroring the
+// notebook on the remote cluster.
+func (r *OpenshiftNotebookReconciler) reconcileManifestWork(notebook *nbv1.Notebook, cluster string,
+	ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook).WithValues("cluster", cluster)
+
+	desiredWork, err := NewNotebookManifestWork(notebook, cluster)
+	if err != nil {
+		return err
+	}
+	foundWork := &unstructured.Unstructured{}
+	foundWork.SetGroupVersionKind(ManifestWorkGVK)
+	err = r.Get(ctx, client.ObjectKeyFromObject(desiredWork), foundWork)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating ManifestWork")
+		err = r.Create(ctx, desiredWork)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the ManifestWork")
+			return err
+		}
+		return nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the ManifestWork")
+		return err
+	}
+
+	update := mergeLabels(foundWork, desiredWork.GetLabels())
+	if !reflect.DeepEqual(foundWork.Object["spec"], desiredWork.Object["spec"]) {
+		foundWork.Object["spec"] = desiredWork.Object["spec"]
+		update = true
+	}
+	if update {
+		log.Info("Reconciling ManifestWork")
+		err = r.Update(ctx, foundWork)
+		if err != nil {
+			log.Error(err, "Unable to reconcile the ManifestWork")
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteNotebookManifestWorks deletes the ManifestWorks mirroring the notebook
+// on the clusters other than the given one, e.g. once the notebook is deleted
+// or placed on another cluster.
+func (r *OpenshiftNotebookReconciler) DeleteNotebookManifestWorks(ctx context.Context, key types.NamespacedName,
+	cluster string) error {
+	if !r.ManifestWorksEnabled {
+		return nil
+	}
+	works := &unstructured.UnstructuredList{}
+	works.SetGroupVersionKind(ManifestWorkGVK.GroupVersion().WithKind(ManifestWorkGVK.Kind + "List"))
+	if err := r.List(ctx, works, notebookManifestWorkSelector(key)); err != nil {
+		return err
+	}
+	for i := range works.Items {
+		work := &works.Items[i]
+		if work.GetNamespace() == cluster {
+			continue
+		}
+		r.Log.Info("Deleting ManifestWork", "notebook", key.Name, "namespace", key.Namespace,
+			"cluster", work.GetNamespace())
+		if err := r.Delete(ctx, work); err != nil && !apierrs.IsNotFound(err) {
+			return fmt.Errorf("unable to delete the ManifestWork of the cluster %s: %w", work.GetNamespace(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestAnnotationPlacementDecider(t *testing.T) {
+	ctx := context.Background()
+	decision := &unstructured.Unstructured{}
+	decision.SetGroupVersionKind(PlacementDecisionGVK)
+	decision.SetName("gpu-decision-1")
+	decision.SetNamespace("ns")
+	decision.SetLabels(map[string]string{LabelPlacement: "gpu"})
+	require.NoError(t, unstructured.SetNestedSlice(decision.Object, []interface{}{
+		map[string]interface{}{"clusterName": "spoke-2", "reason": ""},
+	}, "status", "decisions"))
+	r := newTestReconciler(t, OAuthConfig{}, decision)
+	decider := &AnnotationPlacementDecider{Reader: r.Client}
+
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	cluster, err := decider.Decide(ctx, notebook)
+	require.NoError(t, err)
+	assert.Empty(t, cluster)
+
+	notebook.Annotations = map[string]string{AnnotationPlacement: "gpu"}
+	cluster, err = decider.Decide(ctx, notebook)
+	require.NoError(t, err)
+	assert.Equal(t, "spoke-2", cluster)
+
+	notebook.Annotations[AnnotationPlacementCluster] = "spoke-1"
+	cluster, err = decider.Decide(ctx, notebook)
+	require.NoError(t, err)
+	assert.Equal(t, "spoke-1", cluster)
+
+	notebook.Annotations = map[string]string{AnnotationPlacement: "cpu"}
+	_, err = decider.Decide(ctx, notebook)
+	assert.ErrorIs(t, err, ErrPlacementPending)
+}
+
+func TestReconcilePlacement(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationPlacementCluster: "spoke-1"}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	r.PlacementConfig = PlacementConfig{
+		Decider:          &AnnotationPlacementDecider{Reader: r.Client},
+		LocalClusterName: "local-cluster",
+	}
+	r.ManifestWorksEnabled = true
+	listWorks := func() []unstructured.Unstructured {
+		works := &unstructured.UnstructuredList{}
+		works.SetGroupVersionKind(ManifestWorkGVK.GroupVersion().WithKind("ManifestWorkList"))
+		require.NoError(t, r.List(ctx, works))
+		return works.Items
+	}
+
+	// The notebook placed on a remote cluster is mirrored through a
+	// ManifestWork in the namespace of the cluster
+	cluster, err := r.ReconcilePlacement(notebook, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "spoke-1", cluster)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	assert.Equal(t, "spoke-1", notebook.Annotations[AnnotationPlacementDecision])
+	works := listWorks()
+	require.Len(t, works, 1)
+	assert.Equal(t, "spoke-1", works[0].GetNamespace())
+	assert.Equal(t, "notebook-ns-nb", works[0].GetName())
+	manifests, _, _ := unstructured.NestedSlice(works[0].Object, "spec", "workload", "manifests")
+	require.Len(t, manifests, 1)
+	manifest := manifests[0].(map[string]interface{})
+	assert.Equal(t, "Notebook", manifest["kind"])
+	_, placed, _ := unstructured.NestedString(manifest, "metadata", "annotations", AnnotationPlacementCluster)
+	assert.False(t, placed)
+
+	// The mirror moves with the placement of the notebook
+	notebook.Annotations[AnnotationPlacementCluster] = "spoke-2"
+	require.NoError(t, r.Update(ctx, notebook))
+	_, err = r.ReconcilePlacement(notebook, ctx)
+	require.NoError(t, err)
+	works = listWorks()
+	require.Len(t, works, 1)
+	assert.Equal(t, "spoke-2", works[0].GetNamespace())
+
+	// The notebook placed on the local cluster is reconciled locally
+	notebook.Annotations[AnnotationPlacementCluster] = "local-cluster"
+	require.NoError(t, r.Update(ctx, notebook))
+	cluster, err = r.ReconcilePlacement(notebook, ctx)
+	require.NoError(t, err)
+	assert.Empty(t, cluster)
+	assert.Empty(t, listWorks())
+}
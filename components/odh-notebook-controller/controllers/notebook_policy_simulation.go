@@ -0,0 +1,180 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// trustedCABundleKey is the key of the CA bundle in the trusted CA bundle
+// ConfigMaps.
+const trustedCABundleKey = "ca-bundle.crt"
+
+// PolicyChange is a proposed change of the configuration of the controller,
+// whose impact on the notebooks is simulated before it is rolled out.
+type PolicyChange struct {
+	// OAuthProxyImage is the proposed image of the OAuth proxy, unchanged if
+	// empty.
+	OAuthProxyImage string
+	// OAuthProxyResources are the proposed resources of the OAuth proxy,
+	// unchanged if nil.
+	OAuthProxyResources *corev1.ResourceRequirements
+	// TrustedCABundle is the proposed content of the trusted CA bundle,
+	// unchanged if nil.
+	TrustedCABundle *string
+}
+
+// Apply applies the change to the pod template of the notebook.
+func (p PolicyChange) Apply(notebook *nbv1.Notebook) {
+	for i := range notebook.Spec.Template.Spec.Containers {
+		container := &notebook.Spec.Template.Spec.Containers[i]
+		if container.Name != OAuthProxyContainerName {
+			continue
+		}
+		if p.OAuthProxyImage != "" {
+			container.Image = p.OAuthProxyImage
+		}
+		if p.OAuthProxyResources != nil {
+			// The resources of the annotation of the notebook still apply
+			container.Resources = oauthProxyResources(notebook, OAuthConfig{Resources: p.OAuthProxyResources})
+		}
+	}
+}
+
+// SimulatedNotebook is a notebook mutated by the proposed change.
+type SimulatedNotebook struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Restart is true if the notebook is running and must be restarted to
+	// apply the changes, the stopped notebooks apply them on their start.
+	Restart bool            `json:"restart"`
+	Changes []PendingChange `json:"changes"`
+}
+
+// SimulationReport reports the impact of a proposed change on the notebooks.
+type SimulationReport struct {
+	// Notebooks is the number of simulated notebooks.
+	Notebooks int `json:"notebooks"`
+	// Mutated is the number of notebooks mutated by the change, of which
+	// Restarted are running.
+	Mutated   int                 `json:"mutated"`
+	Restarted int                 `json:"restarted"`
+	Affected  []SimulatedNotebook `json:"affected"`
+}
+
+// PolicySimulation simulates a proposed change of the configuration of the
+// controller on the notebooks of the cluster, without changing them. The
+// pod templates the webhook produces today are obtained by dry-run updates,
+// so the client must be allowed to update the notebooks.
+type PolicySimulation struct {
+	client.Client
+	Log    logr.Logger
+	Change PolicyChange
+	// Namespace restricts the simulation to a namespace, all if empty.
+	Namespace string
+}
+
+// Run simulates the change on the notebooks.
+func (s *PolicySimulation) Run(ctx context.Context) (SimulationReport, error) {
+	report := SimulationReport{Affected: []SimulatedNotebook{}}
+	notebookList := &nbv1.NotebookList{}
+	if err := s.List(ctx, notebookList, client.InNamespace(s.Namespace)); err != nil {
+		return report, err
+	}
+	caBundles := map[string]*string{}
+	for i := range notebookList.Items {
+		notebook := &notebookList.Items[i]
+		report.Notebooks++
+		desired, err := desiredNotebook(ctx, s.Client, notebook)
+		if err != nil {
+			s.Log.Error(err, "Unable to simulate the change on the notebook", "notebook", notebook.Name,
+				"namespace", notebook.Namespace)
+			continue
+		}
+		proposed := desired.DeepCopy()
+		s.Change.Apply(proposed)
+
+		var reporter PendingChangesReporter
+		cmp.Equal(proposed.Spec.Template.Spec, desired.Spec.Template.Spec, cmp.Reporter(&reporter))
+		changes := reporter.Changes()
+		if s.Change.TrustedCABundle != nil && mountsTrustedCABundle(desired) {
+			if _, ok := caBundles[notebook.Namespace]; !ok {
+				caBundles[notebook.Namespace], err = s.trustedCABundle(ctx, notebook.Namespace)
+				if err != nil {
+					return report, err
+				}
+			}
+			// The bundle is mounted with a subPath, which the kubelet does
+			// not update in the running pods
+			if current := caBundles[notebook.Namespace]; current == nil || *current != *s.Change.TrustedCABundle {
+				changes = append(changes, PendingChange{
+					Path: "configMap[" + WorkbenchTrustedCABundleConfigMapName + "]." + trustedCABundleKey,
+					Type: ChangeModified,
+				})
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		simulated := SimulatedNotebook{
+			Name:      notebook.Name,
+			Namespace: notebook.Namespace,
+			Restart:   !notebookIsStopped(notebook.ObjectMeta),
+			Changes:   changes,
+		}
+		report.Mutated++
+		if simulated.Restart {
+			report.Restarted++
+		}
+		report.Affected = append(report.Affected, simulated)
+	}
+	return report, nil
+}
+
+// trustedCABundle returns the trusted CA bundle mounted by the notebooks of
+// the namespace, nil if there is none.
+func (s *PolicySimulation) trustedCABundle(ctx context.Context, namespace string) (*string, error) {
+	configMap := &corev1.ConfigMap{}
+	err := s.Get(ctx, types.NamespacedName{Namespace: namespace, Name: WorkbenchTrustedCABundleConfigMapName},
+		configMap)
+	if apierrs.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	bundle := configMap.Data[trustedCABundleKey]
+	return &bundle, nil
+}
+
+// mountsTrustedCABundle returns true if the notebook mounts the trusted CA
+// bundle of its namespace.
+func mountsTrustedCABundle(notebook *nbv1.Notebook) bool {
+	for _, volume := range notebook.Spec.Template.Spec.Volumes {
+		if volume.ConfigMap != nil && volume.ConfigMap.Name == WorkbenchTrustedCABundleConfigMapName {
+			return true
+		}
+	}
+	return false
+}
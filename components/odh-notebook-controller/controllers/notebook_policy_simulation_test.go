@@ -0,0 +1,106 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPolicySimulation(t *testing.T) {
+	ctx := context.Background()
+	newNotebook := func(name string, oauth, caBundle bool) *nbv1.Notebook {
+		notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}}
+		spec := &notebook.Spec.Template.Spec
+		spec.Containers = []corev1.Container{{Name: name, Image: "workbench:2024.1"}}
+		if oauth {
+			spec.Containers = append(spec.Containers, corev1.Container{Name: OAuthProxyContainerName,
+				Image: "oauth-proxy:4.14", Resources: DefaultOAuthProxyResources()})
+		}
+		if caBundle {
+			spec.Volumes = []corev1.Volume{{Name: "trusted-ca", VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{
+					Name: WorkbenchTrustedCABundleConfigMapName}},
+			}}}
+		}
+		return notebook
+	}
+	running := newNotebook("running", true, false)
+	stopped := newNotebook("stopped", true, true)
+	stopped.Annotations = map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"}
+	withoutProxy := newNotebook("without-proxy", false, true)
+	caBundle := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: WorkbenchTrustedCABundleConfigMapName, Namespace: "ns"},
+		Data:       map[string]string{"ca-bundle.crt": "old-bundle"},
+	}
+	r := newTestReconciler(t, OAuthConfig{}, running, stopped, withoutProxy, caBundle)
+
+	// A new proxy image mutates the notebooks injected with the proxy
+	simulation := &PolicySimulation{Client: r.Client, Log: logr.Discard(),
+		Change: PolicyChange{OAuthProxyImage: "oauth-proxy:4.15"}}
+	report, err := simulation.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Notebooks)
+	assert.Equal(t, 2, report.Mutated)
+	assert.Equal(t, 1, report.Restarted)
+	require.Len(t, report.Affected, 2)
+	assert.Equal(t, SimulatedNotebook{Name: "running", Namespace: "ns", Restart: true, Changes: []PendingChange{
+		{Path: "containers[1].image", Type: ChangeModified, From: "oauth-proxy:4.14", To: "oauth-proxy:4.15"},
+	}}, report.Affected[0])
+	assert.Equal(t, "stopped", report.Affected[1].Name)
+	assert.False(t, report.Affected[1].Restart)
+
+	// A new CA bundle mutates the notebooks mounting it
+	resources, err := ParseProxyResources("cpu=100m,memory=64Mi")
+	require.NoError(t, err)
+	bundle := "new-bundle"
+	simulation.Change = PolicyChange{OAuthProxyResources: resources, TrustedCABundle: &bundle}
+	report, err = simulation.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Mutated)
+	assert.Equal(t, 1, report.Restarted)
+	assert.Equal(t, "without-proxy", report.Affected[1].Name)
+
+	// The simulation does not change the notebooks
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(running), running))
+	assert.Equal(t, "oauth-proxy:4.14", running.Spec.Template.Spec.Containers[1].Image)
+	assert.NotContains(t, running.Annotations, AnnotationNotebookRestart)
+}
+
+func TestParseProxyResources(t *testing.T) {
+	resources, err := ParseProxyResources("cpu=200m, memory=128Mi")
+	require.NoError(t, err)
+	assert.Equal(t, resource.MustParse("200m"), resources.Requests[corev1.ResourceCPU])
+	assert.Equal(t, resource.MustParse("128Mi"), resources.Limits[corev1.ResourceMemory])
+
+	resources, err = ParseProxyResources("")
+	require.NoError(t, err)
+	assert.Nil(t, resources)
+	_, err = ParseProxyResources("gpu=1")
+	assert.Error(t, err)
+	_, err = ParseProxyResources("cpu=lots")
+	assert.Error(t, err)
+}
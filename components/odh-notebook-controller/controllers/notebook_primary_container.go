@@ -0,0 +1,88 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationPrimaryContainer names the notebook container of the pod template,
+// for the notebooks whose container is not named after the notebook, e.g.
+// created by other tools.
+const AnnotationPrimaryContainer = "notebooks.opendatahub.io/primary-container"
+
+// sidecarContainerNames are the containers injected next to the notebook
+// container, never considered as the notebook container.
+var sidecarContainerNames = map[string]bool{
+	OAuthProxyContainerName: true,
+	"istio-proxy":           true,
+}
+
+// PrimaryContainerName returns the name of the notebook container: the
+// container named by the primary-container annotation, else the container
+// named after the notebook, else the first container which is not a sidecar.
+// The notebook name is returned if there is no such container.
+func PrimaryContainerName(notebook *nbv1.Notebook) string {
+	if name := notebook.GetAnnotations()[AnnotationPrimaryContainer]; name != "" {
+		return name
+	}
+	containers := notebook.Spec.Template.Spec.Containers
+	for _, container := range containers {
+		if container.Name == notebook.Name {
+			return container.Name
+		}
+	}
+	for _, container := range containers {
+		if !isSidecarContainer(notebook, container.Name) {
+			return container.Name
+		}
+	}
+	return notebook.Name
+}
+
+// notebookContainer returns the notebook container of the pod template, or
+// nil if the notebook has no container.
+func notebookContainer(notebook *nbv1.Notebook) *corev1.Container {
+	name := PrimaryContainerName(notebook)
+	containers := notebook.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// ValidatePrimaryContainerAnnotation checks that the primary-container
+// annotation of the notebook names one of its containers.
+func ValidatePrimaryContainerAnnotation(notebook *nbv1.Notebook) error {
+	name, ok := notebook.GetAnnotations()[AnnotationPrimaryContainer]
+	if !ok {
+		return nil
+	}
+	if name == "" || sidecarContainerNames[name] {
+		return fmt.Errorf("invalid %s annotation %q: expected the name of the notebook container",
+			AnnotationPrimaryContainer, name)
+	}
+	if notebookContainer(notebook) == nil {
+		return fmt.Errorf("invalid %s annotation: the notebook has no container %q",
+			AnnotationPrimaryContainer, name)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPrimaryContainerName(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+	notebook.Spec.Template.Spec.Containers = []corev1.Container{
+		{Name: OAuthProxyContainerName}, {Name: "jupyter"}, {Name: "nb"},
+	}
+
+	// The container named after the notebook comes first
+	assert.Equal(t, "nb", PrimaryContainerName(notebook))
+
+	// Then the first container which is not a sidecar
+	notebook.Spec.Template.Spec.Containers[2].Name = "tools"
+	assert.Equal(t, "jupyter", PrimaryContainerName(notebook))
+	assert.Equal(t, "jupyter", notebookContainer(notebook).Name)
+
+	// The annotation takes precedence
+	notebook.Annotations = map[string]string{AnnotationPrimaryContainer: "tools"}
+	assert.Equal(t, "tools", PrimaryContainerName(notebook))
+	assert.NoError(t, ValidatePrimaryContainerAnnotation(notebook))
+
+	// The annotations naming no container or a sidecar are rejected
+	notebook.Annotations[AnnotationPrimaryContainer] = "missing"
+	assert.Nil(t, notebookContainer(notebook))
+	assert.Error(t, ValidatePrimaryContainerAnnotation(notebook))
+	notebook.Annotations[AnnotationPrimaryContainer] = OAuthProxyContainerName
+	assert.Error(t, ValidatePrimaryContainerAnnotation(notebook))
+}
@@ -0,0 +1,142 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationMutationProvenance records the hash of the pod template
+	// produced by the webhook, the version of the controller which produced
+	// it and, when a signing key is configured, its signature, as a JSON
+	// MutationProvenance.
+	AnnotationMutationProvenance = "notebooks.opendatahub.io/mutation-provenance"
+
+	provenanceHashPrefix      = "sha256:"
+	provenanceSignaturePrefix = "hmac-sha256:"
+)
+
+// MutationProvenance is the value of the mutation provenance annotation.
+type MutationProvenance struct {
+	// Version is the version of the controller which mutated the notebook.
+	Version string `json:"version"`
+	// Hash is the SHA-256 hash of the JSON pod template spec.
+	Hash string `json:"hash"`
+	// Signature is the HMAC-SHA256 of the version and hash, if the webhook
+	// has a signing key.
+	Signature string `json:"signature,omitempty"`
+}
+
+// ControllerVersion returns the version of the controller, the VCS revision
+// it was built from if known.
+func ControllerVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return info.Main.Version
+}
+
+// PodSpecHash returns the hash of the pod template spec.
+func PodSpecHash(spec *corev1.PodSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return provenanceHashPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+// provenanceSignature signs the version and hash of the provenance.
+func provenanceSignature(provenance MutationProvenance, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(provenance.Version + "\n" + provenance.Hash))
+	return provenanceSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RecordMutationProvenance records the provenance of the pod template of the
+// mutated notebook. The provenance of the old notebook is kept if its pod
+// template did not change, e.g. while the updates of a running notebook are
+// pending, so that it keeps the version which produced the template.
+func RecordMutationProvenance(notebook, oldNotebook *nbv1.Notebook, version string, key []byte) error {
+	hash, err := PodSpecHash(&notebook.Spec.Template.Spec)
+	if err != nil {
+		return err
+	}
+	if notebook.Annotations == nil {
+		notebook.Annotations = map[string]string{}
+	}
+	if oldNotebook != nil {
+		if oldValue, ok := oldNotebook.GetAnnotations()[AnnotationMutationProvenance]; ok {
+			old := MutationProvenance{}
+			if json.Unmarshal([]byte(oldValue), &old) == nil && old.Hash == hash &&
+				(len(key) == 0 || hmac.Equal([]byte(old.Signature), []byte(provenanceSignature(old, key)))) {
+				notebook.Annotations[AnnotationMutationProvenance] = oldValue
+				return nil
+			}
+		}
+	}
+
+	provenance := MutationProvenance{Version: version, Hash: hash}
+	if len(key) > 0 {
+		provenance.Signature = provenanceSignature(provenance, key)
+	}
+	value, err := json.Marshal(provenance)
+	if err != nil {
+		return err
+	}
+	notebook.Annotations[AnnotationMutationProvenance] = string(value)
+	return nil
+}
+
+// VerifyMutationProvenance checks that the pod template spec, e.g. the one of
+// the StatefulSet running the notebook, matches the recorded provenance, and
+// the signature of the provenance if a key is given.
+func VerifyMutationProvenance(spec *corev1.PodSpec, value string, key []byte) error {
+	provenance := MutationProvenance{}
+	if err := json.Unmarshal([]byte(value), &provenance); err != nil {
+		return fmt.Errorf("invalid %s annotation: %w", AnnotationMutationProvenance, err)
+	}
+	if len(key) > 0 {
+		if !hmac.Equal([]byte(provenance.Signature), []byte(provenanceSignature(provenance, key))) {
+			return errors.New("the signature of the mutation provenance does not match")
+		}
+	}
+	hash, err := PodSpecHash(spec)
+	if err != nil {
+		return err
+	}
+	if hash != provenance.Hash {
+		return fmt.Errorf("the pod template does not match the one produced by the controller version %s",
+			provenance.Version)
+	}
+	return nil
+}
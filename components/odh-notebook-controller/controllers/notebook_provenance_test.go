@@ -0,0 +1,53 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMutationProvenance(t *testing.T) {
+	key := []byte("signing-key")
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	notebook.Spec.Template.Spec.Containers = []corev1.Container{{Name: "nb", Image: "workbench:2024.1"}}
+
+	require.NoError(t, RecordMutationProvenance(notebook, nil, "v1", key))
+	value := notebook.Annotations[AnnotationMutationProvenance]
+	assert.Contains(t, value, `"version":"v1"`)
+	assert.NoError(t, VerifyMutationProvenance(&notebook.Spec.Template.Spec, value, key))
+
+	// The tampered pod templates and provenances are detected
+	tampered := notebook.Spec.Template.Spec.DeepCopy()
+	tampered.Containers[0].Image = "workbench:custom"
+	assert.Error(t, VerifyMutationProvenance(tampered, value, key))
+	assert.Error(t, VerifyMutationProvenance(&notebook.Spec.Template.Spec, value, []byte("other-key")))
+
+	// The provenance of the unchanged pod template keeps its version
+	updated := notebook.DeepCopy()
+	require.NoError(t, RecordMutationProvenance(updated, notebook, "v2", key))
+	assert.Equal(t, value, updated.Annotations[AnnotationMutationProvenance])
+	updated.Spec.Template.Spec.Containers[0].Image = "workbench:2024.2"
+	require.NoError(t, RecordMutationProvenance(updated, notebook, "v2", key))
+	assert.Contains(t, updated.Annotations[AnnotationMutationProvenance], `"version":"v2"`)
+	assert.NoError(t, VerifyMutationProvenance(&updated.Spec.Template.Spec,
+		updated.Annotations[AnnotationMutationProvenance], key))
+}
@@ -0,0 +1,60 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ParsePullPolicy parses an image pull policy, empty if the value is empty.
+func ParsePullPolicy(value string) (corev1.PullPolicy, error) {
+	switch policy := corev1.PullPolicy(value); policy {
+	case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid image pull policy %q, must be one of [%s, %s, %s]", value,
+			corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever)
+	}
+}
+
+// oauthProxyPullPolicy returns the pull policy of the OAuth proxy container,
+// PullAlways if not configured.
+func oauthProxyPullPolicy(oauth OAuthConfig) corev1.PullPolicy {
+	if oauth.ImagePullPolicy == "" {
+		return corev1.PullAlways
+	}
+	return oauth.ImagePullPolicy
+}
+
+// InjectWorkbenchPullPolicy sets the given pull policy on the workbench
+// containers of the notebook which do not set their own, e.g. for the
+// air-gapped nodes with pre-loaded images. The injected sidecars are left
+// unchanged, as well as all the containers if the policy is empty.
+func InjectWorkbenchPullPolicy(notebook *nbv1.Notebook, policy corev1.PullPolicy) {
+	if policy == "" {
+		return
+	}
+	containers := notebook.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name == OAuthProxyContainerName || containers[i].ImagePullPolicy != "" {
+			continue
+		}
+		containers[i].ImagePullPolicy = policy
+	}
+}
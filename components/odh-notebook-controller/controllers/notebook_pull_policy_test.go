@@ -0,0 +1,67 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParsePullPolicy(t *testing.T) {
+	policy, err := ParsePullPolicy("IfNotPresent")
+	require.NoError(t, err)
+	assert.Equal(t, corev1.PullIfNotPresent, policy)
+
+	policy, err = ParsePullPolicy("")
+	require.NoError(t, err)
+	assert.Empty(t, policy)
+
+	_, err = ParsePullPolicy("ifnotpresent")
+	assert.Error(t, err)
+}
+
+func TestOAuthProxyPullPolicy(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	require.NoError(t, InjectOAuthProxy(notebook, OAuthConfig{}))
+	assert.Equal(t, corev1.PullAlways, notebook.Spec.Template.Spec.Containers[0].ImagePullPolicy)
+
+	notebook = &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	require.NoError(t, InjectOAuthProxy(notebook, OAuthConfig{ImagePullPolicy: corev1.PullNever}))
+	assert.Equal(t, corev1.PullNever, notebook.Spec.Template.Spec.Containers[0].ImagePullPolicy)
+}
+
+func TestInjectWorkbenchPullPolicy(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	notebook.Spec.Template.Spec.Containers = []corev1.Container{
+		{Name: "nb", Image: "workbench"},
+		{Name: "sidecar", Image: "sidecar", ImagePullPolicy: corev1.PullAlways},
+		{Name: OAuthProxyContainerName, Image: "oauth-proxy"},
+	}
+
+	InjectWorkbenchPullPolicy(notebook, "")
+	assert.Empty(t, notebook.Spec.Template.Spec.Containers[0].ImagePullPolicy)
+
+	InjectWorkbenchPullPolicy(notebook, corev1.PullIfNotPresent)
+	assert.Equal(t, corev1.PullIfNotPresent, notebook.Spec.Template.Spec.Containers[0].ImagePullPolicy)
+	assert.Equal(t, corev1.PullAlways, notebook.Spec.Template.Spec.Containers[1].ImagePullPolicy,
+		"the pull policy of the user is kept")
+	assert.Empty(t, notebook.Spec.Template.Spec.Containers[2].ImagePullPolicy)
+}
@@ -0,0 +1,186 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// AnnotationImagePullSecrets lists the comma-separated pull secrets
+	// injected in the notebooks of the annotated namespace, in addition to
+	// the cluster ones.
+	AnnotationImagePullSecrets = "notebooks.opendatahub.io/image-pull-secrets"
+	// AnnotationImagePullSecretsInjected records the comma-separated pull
+	// secrets injected by the webhook in the notebook, or by the controller
+	// in its service account, so that only those are removed when the
+	// configuration changes.
+	AnnotationImagePullSecretsInjected = "notebooks.opendatahub.io/image-pull-secrets-injected"
+)
+
+// PullSecretsConfig holds the pull secrets injected in the pod template of
+// the notebooks and in their service accounts, so that the private workbench
+// images can be pulled in the namespaces not set up by the dashboard.
+type PullSecretsConfig struct {
+	// ImagePullSecrets are injected in all the notebooks, they must exist in
+	// the namespaces of the notebooks.
+	ImagePullSecrets []string
+	// ServiceAccounts propagates the pull secrets to the service accounts of
+	// the notebooks, keeping them up to date when the configuration or the
+	// namespace annotation changes.
+	ServiceAccounts bool
+}
+
+// validatePullSecretNames checks that the pull secret names are valid Secret
+// names.
+func validatePullSecretNames(names []string) error {
+	for _, name := range names {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("invalid image pull secret %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// Validate checks the names of the pull secrets.
+func (c PullSecretsConfig) Validate() error {
+	return validatePullSecretNames(c.ImagePullSecrets)
+}
+
+// ForNamespace returns the cluster pull secrets along with the ones of the
+// namespace annotation.
+func (c PullSecretsConfig) ForNamespace(namespace *corev1.Namespace) ([]string, error) {
+	names := append([]string{}, c.ImagePullSecrets...)
+	value := namespace.GetAnnotations()[AnnotationImagePullSecrets]
+	if value == "" {
+		return names, nil
+	}
+	namespaceNames := []string{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			namespaceNames = append(namespaceNames, name)
+		}
+	}
+	if err := validatePullSecretNames(namespaceNames); err != nil {
+		return names, fmt.Errorf("invalid %s annotation of the namespace %s: %w", AnnotationImagePullSecrets,
+			namespace.Name, err)
+	}
+	return append(names, namespaceNames...), nil
+}
+
+// mergeImagePullSecrets sets the given pull secrets in the pull secrets of a
+// pod or a service account, keeping the other ones. The pull secrets
+// previously injected, recorded in the given comma-separated list, and no
+// longer configured are removed. It returns the merged pull secrets along
+// with the new comma-separated list of the injected ones.
+func mergeImagePullSecrets(current []corev1.LocalObjectReference, previouslyInjected string,
+	names []string) ([]corev1.LocalObjectReference, string) {
+	desired := map[string]bool{}
+	for _, name := range names {
+		desired[name] = true
+	}
+	previous := map[string]bool{}
+	if previouslyInjected != "" {
+		for _, name := range strings.Split(previouslyInjected, ",") {
+			previous[name] = true
+		}
+	}
+
+	// Remove the previous injection, keeping the pull secrets still
+	// configured and the ones of the user
+	pullSecrets := []corev1.LocalObjectReference{}
+	found := map[string]bool{}
+	for _, pullSecret := range current {
+		if previous[pullSecret.Name] && !desired[pullSecret.Name] {
+			continue
+		}
+		pullSecrets = append(pullSecrets, pullSecret)
+		found[pullSecret.Name] = true
+	}
+
+	// Inject the missing pull secrets, the ones set by the user are not
+	// recorded as injected
+	injected := []string{}
+	for name := range desired {
+		if found[name] {
+			if previous[name] {
+				injected = append(injected, name)
+			}
+			continue
+		}
+		injected = append(injected, name)
+	}
+	for _, name := range names {
+		if !found[name] {
+			pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: name})
+			found[name] = true
+		}
+	}
+	if len(pullSecrets) == 0 {
+		pullSecrets = nil
+	}
+	sort.Strings(injected)
+	return pullSecrets, strings.Join(injected, ",")
+}
+
+// setInjectedPullSecrets records the injected pull secrets in the
+// annotations of the object, removing the annotation if there are none.
+func setInjectedPullSecrets(object metav1.Object, injected string) {
+	annotations := object.GetAnnotations()
+	if injected == "" {
+		delete(annotations, AnnotationImagePullSecretsInjected)
+		return
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AnnotationImagePullSecretsInjected] = injected
+	object.SetAnnotations(annotations)
+}
+
+// InjectImagePullSecrets sets the given pull secrets in the pod template of
+// the notebook, keeping the pull secrets of the user. The pull secrets
+// previously injected and no longer configured are removed.
+func InjectImagePullSecrets(notebook *nbv1.Notebook, names []string) {
+	podSpec := &notebook.Spec.Template.Spec
+	var injected string
+	podSpec.ImagePullSecrets, injected = mergeImagePullSecrets(podSpec.ImagePullSecrets,
+		notebook.GetAnnotations()[AnnotationImagePullSecretsInjected], names)
+	setInjectedPullSecrets(notebook, injected)
+}
+
+// InjectServiceAccountPullSecrets sets the given pull secrets in the service
+// account of the notebook, so that they are used by all its pods, keeping
+// the pull secrets of the user and the ones added by OpenShift. It returns
+// true if the service account changed.
+func InjectServiceAccountPullSecrets(serviceAccount *corev1.ServiceAccount, names []string) bool {
+	previous := serviceAccount.GetAnnotations()[AnnotationImagePullSecretsInjected]
+	pullSecrets, injected := mergeImagePullSecrets(serviceAccount.ImagePullSecrets, previous, names)
+	if injected == previous && reflect.DeepEqual(pullSecrets, serviceAccount.ImagePullSecrets) {
+		return false
+	}
+	serviceAccount.ImagePullSecrets = pullSecrets
+	setInjectedPullSecrets(serviceAccount, injected)
+	return true
+}
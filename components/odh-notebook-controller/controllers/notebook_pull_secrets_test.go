@@ -0,0 +1,108 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPullSecretsConfigForNamespace(t *testing.T) {
+	config := PullSecretsConfig{ImagePullSecrets: []string{"registry-creds"}}
+	require.NoError(t, config.Validate())
+	assert.Error(t, PullSecretsConfig{ImagePullSecrets: []string{"Registry_Creds"}}.Validate())
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}
+	names, err := config.ForNamespace(namespace)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"registry-creds"}, names)
+
+	namespace.Annotations = map[string]string{AnnotationImagePullSecrets: "team-creds, quay-creds"}
+	names, err = config.ForNamespace(namespace)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"registry-creds", "team-creds", "quay-creds"}, names)
+
+	namespace.Annotations[AnnotationImagePullSecrets] = "team creds"
+	names, err = config.ForNamespace(namespace)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"registry-creds"}, names, "the cluster pull secrets are kept")
+}
+
+func TestInjectImagePullSecrets(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	notebook.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "user-creds"}}
+
+	InjectImagePullSecrets(notebook, []string{"registry-creds", "user-creds"})
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "user-creds"}, {Name: "registry-creds"}},
+		notebook.Spec.Template.Spec.ImagePullSecrets)
+	assert.Equal(t, "registry-creds", notebook.Annotations[AnnotationImagePullSecretsInjected])
+
+	// The injection is idempotent
+	InjectImagePullSecrets(notebook, []string{"registry-creds", "user-creds"})
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "user-creds"}, {Name: "registry-creds"}},
+		notebook.Spec.Template.Spec.ImagePullSecrets)
+
+	// The pull secrets no longer configured are removed, the ones of the
+	// user are kept
+	InjectImagePullSecrets(notebook, []string{"quay-creds"})
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "user-creds"}, {Name: "quay-creds"}},
+		notebook.Spec.Template.Spec.ImagePullSecrets)
+	assert.Equal(t, "quay-creds", notebook.Annotations[AnnotationImagePullSecretsInjected])
+
+	InjectImagePullSecrets(notebook, nil)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "user-creds"}}, notebook.Spec.Template.Spec.ImagePullSecrets)
+	assert.NotContains(t, notebook.Annotations, AnnotationImagePullSecretsInjected)
+}
+
+func TestReconcileServiceAccountPullSecrets(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns",
+		Annotations: map[string]string{AnnotationImagePullSecrets: "team-creds"}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook, namespace)
+	r.PullSecretsConfig = PullSecretsConfig{ImagePullSecrets: []string{"registry-creds"}, ServiceAccounts: true}
+
+	require.NoError(t, r.ReconcileOAuthServiceAccount(notebook, ctx))
+	serviceAccount := &corev1.ServiceAccount{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "nb"}, serviceAccount))
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "registry-creds"}, {Name: "team-creds"}},
+		serviceAccount.ImagePullSecrets)
+
+	// The pull secrets added by OpenShift are kept on rotation
+	serviceAccount.ImagePullSecrets = append(serviceAccount.ImagePullSecrets,
+		corev1.LocalObjectReference{Name: "nb-dockercfg-x7k2p"})
+	require.NoError(t, r.Update(ctx, serviceAccount))
+	namespace.Annotations[AnnotationImagePullSecrets] = "team-creds-2024"
+	require.NoError(t, r.Update(ctx, namespace))
+	require.NoError(t, r.ReconcileOAuthServiceAccount(notebook, ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(serviceAccount), serviceAccount))
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "registry-creds"}, {Name: "nb-dockercfg-x7k2p"},
+		{Name: "team-creds-2024"}}, serviceAccount.ImagePullSecrets)
+
+	// The propagated pull secrets are removed once disabled
+	r.PullSecretsConfig.ServiceAccounts = false
+	require.NoError(t, r.ReconcileOAuthServiceAccount(notebook, ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(serviceAccount), serviceAccount))
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "nb-dockercfg-x7k2p"}}, serviceAccount.ImagePullSecrets)
+	assert.NotContains(t, serviceAccount.Annotations, AnnotationImagePullSecretsInjected)
+}
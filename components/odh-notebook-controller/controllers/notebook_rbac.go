@@ -17,35 +17,73 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
 
 	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// AnnotationPipelinesAccess records the RoleBindings granting the notebook
+// service account access to the data science pipelines, so that security
+// reviews can see the access of each workbench. The notebook status is owned
+// by the Kubeflow notebook controller, hence the annotation.
+const AnnotationPipelinesAccess = "notebooks.opendatahub.io/pipelines-access"
+
+// pipelinesRoleRefName is the Role granting access to the data science
+// pipelines of the namespace.
+const pipelinesRoleRefName = "ds-pipeline-user-access-dspa"
+
+// RoleBindingAccess describes the access granted by a RoleBinding.
+type RoleBindingAccess struct {
+	RoleBinding string   `json:"roleBinding"`
+	RoleRef     string   `json:"roleRef"`
+	Subjects    []string `json:"subjects"`
+}
+
+// NewRoleBindingAccess returns the access granted by the given RoleBinding.
+func NewRoleBindingAccess(roleBinding *rbacv1.RoleBinding) RoleBindingAccess {
+	access := RoleBindingAccess{
+		RoleBinding: roleBinding.Name,
+		RoleRef:     roleBinding.RoleRef.Kind + "/" + roleBinding.RoleRef.Name,
+		Subjects:    []string{},
+	}
+	for _, subject := range roleBinding.Subjects {
+		access.Subjects = append(access.Subjects, subject.Kind+"/"+subject.Namespace+"/"+subject.Name)
+	}
+	return access
+}
+
+// pipelinesRoleBindingName returns the name of the RoleBinding granting the
+// notebook access to the data science pipelines.
+func pipelinesRoleBindingName(notebook *nbv1.Notebook) string {
+	return "elyra-pipelines-" + notebook.Name
+}
+
 // NewRoleBinding defines the desired RoleBinding or ClusterRoleBinding object.
 // Parameters:
-//   - notebook:        The Notebook resource instance for which the RoleBinding or ClusterRoleBinding is being created.
-//   - rolebindingName: The name to assign to the RoleBinding or ClusterRoleBinding object.
-//   - roleRefKind:     The kind of role reference to bind to, which can be either Role or ClusterRole.
-//   - roleRefName:     The name of the Role or ClusterRole to reference.
-func NewRoleBinding(notebook *nbv1.Notebook, rolebindingName, roleRefKind, roleRefName string) *rbacv1.RoleBinding {
+//   - notebook:           The Notebook resource instance for which the RoleBinding or ClusterRoleBinding is being created.
+//   - serviceAccountName: The name of the notebook service account to bind.
+//   - rolebindingName:    The name to assign to the RoleBinding or ClusterRoleBinding object.
+//   - roleRefKind:        The kind of role reference to bind to, which can be either Role or ClusterRole.
+//   - roleRefName:        The name of the Role or ClusterRole to reference.
+func NewRoleBinding(notebook *nbv1.Notebook, serviceAccountName, rolebindingName, roleRefKind, roleRefName string) *rbacv1.RoleBinding {
 	return &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      rolebindingName,
 			Namespace: notebook.Namespace,
-			Labels: map[string]string{
-				"notebook-name": notebook.Name,
-			},
+			Labels:    NotebookObjectLabels(notebook, ComponentRBAC),
 		},
 		Subjects: []rbacv1.Subject{
 			{
 				Kind:      "ServiceAccount",
-				Name:      notebook.Name,
+				Name:      serviceAccountName,
 				Namespace: notebook.Namespace,
 			},
 		},
@@ -85,24 +123,26 @@ func (r *OpenshiftNotebookReconciler) checkRoleExists(ctx context.Context, roleR
 	return true, nil // Role or ClusterRole exists
 }
 
-// reconcileRoleBinding manages creation, update, and deletion of RoleBindings and ClusterRoleBindings
+// reconcileRoleBinding manages creation, update, and deletion of RoleBindings and ClusterRoleBindings.
+// It returns the RoleBinding bound to the notebook, or nil if the role does not exist.
 func (r *OpenshiftNotebookReconciler) reconcileRoleBinding(
-	notebook *nbv1.Notebook, ctx context.Context, rolebindingName, roleRefKind, roleRefName string) error {
+	notebook *nbv1.Notebook, ctx context.Context, rolebindingName, roleRefKind, roleRefName string) (*rbacv1.RoleBinding, error) {
 
-	log := r.Log.WithValues("notebook", types.NamespacedName{Name: notebook.Name, Namespace: notebook.Namespace})
+	log := r.notebookLogger(notebook)
 
 	// Check if the Role or ClusterRole exists before proceeding
 	roleExists, err := r.checkRoleExists(ctx, roleRefKind, roleRefName, notebook.Namespace)
 	if err != nil {
 		log.Error(err, "Error checking if Role exists", "Role.Kind", roleRefKind, "Role.Name", roleRefName)
-		return err
+		return nil, err
 	}
 	if !roleExists {
-		return nil // Skip if dspa Role is not found on the namespace
+		return nil, nil // Skip if dspa Role is not found on the namespace
 	}
 
 	// Create a new RoleBinding based on provided parameters
-	roleBinding := NewRoleBinding(notebook, rolebindingName, roleRefKind, roleRefName)
+	roleBinding := NewRoleBinding(notebook, OAuthServiceAccountName(notebook, r.OAuthConfig),
+		rolebindingName, roleRefKind, roleRefName)
 
 	// Check if the RoleBinding already exists
 	found := &rbacv1.RoleBinding{}
@@ -114,41 +154,115 @@ func (r *OpenshiftNotebookReconciler) reconcileRoleBinding(
 		// the Kubernetes garbage collector if the notebook is deleted
 		if err := ctrl.SetControllerReference(notebook, roleBinding, r.Scheme); err != nil {
 			log.Error(err, "Failed to set controller reference for RoleBinding")
-			return err
+			return nil, err
 		}
 		err = r.Create(ctx, roleBinding)
 		if err != nil {
 			log.Error(err, "Failed to create RoleBinding", "RoleBinding.Namespace", roleBinding.Namespace, "RoleBinding.Name", roleBinding.Name)
-			return err
+			return nil, err
 		}
-		return nil
+		r.recordRoleBindingEvent(notebook, "RoleBindingCreated", roleBinding)
+		return roleBinding, nil
 	} else if err != nil {
 		log.Error(err, "Failed to get RoleBinding")
-		return err
+		return nil, err
 	}
 
-	// Update RoleBinding if the subjects differ
-	if !reflect.DeepEqual(roleBinding.Subjects, found.Subjects) {
+	// Update RoleBinding if the subjects or labels differ
+	if mergeLabels(found, roleBinding.Labels) || !reflect.DeepEqual(roleBinding.Subjects, found.Subjects) {
 		log.Info("Updating RoleBinding", "RoleBinding.Namespace", roleBinding.Namespace, "RoleBinding.Name", roleBinding.Name)
-		err = r.Update(ctx, roleBinding)
+		found.Subjects = roleBinding.Subjects
+		err = r.Update(ctx, found)
 		if err != nil {
 			log.Error(err, "Failed to update RoleBinding", "RoleBinding.Namespace", roleBinding.Namespace, "RoleBinding.Name", roleBinding.Name)
+			return nil, err
+		}
+		r.recordRoleBindingEvent(notebook, "RoleBindingUpdated", found)
+	}
+
+	return found, nil
+}
+
+// recordRoleBindingEvent records an event describing the access granted to
+// the notebook by the given RoleBinding.
+func (r *OpenshiftNotebookReconciler) recordRoleBindingEvent(notebook *nbv1.Notebook, reason string,
+	roleBinding *rbacv1.RoleBinding) {
+	access := NewRoleBindingAccess(roleBinding)
+	r.recordEvent(notebook, corev1.EventTypeNormal, reason, "RoleBinding %s binds %s to %v",
+		access.RoleBinding, access.RoleRef, access.Subjects)
+}
+
+// setPipelinesAccess records the given access in the notebook annotations,
+// or removes the annotation if there is none.
+func (r *OpenshiftNotebookReconciler) setPipelinesAccess(notebook *nbv1.Notebook, ctx context.Context,
+	access []RoleBindingAccess) error {
+	var value interface{}
+	if len(access) > 0 {
+		data, err := json.Marshal(access)
+		if err != nil {
 			return err
 		}
+		value = string(data)
+	}
+	current, found := notebook.GetAnnotations()[AnnotationPipelinesAccess]
+	if (value == nil && !found) || (value != nil && value == current) {
+		return nil
 	}
 
-	return nil
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				AnnotationPipelinesAccess: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
 }
 
 // ReconcileRoleBindings will manage multiple RoleBinding and ClusterRoleBinding reconciliations
 func (r *OpenshiftNotebookReconciler) ReconcileRoleBindings(
 	notebook *nbv1.Notebook, ctx context.Context) error {
 
-	roleBindingName := "elyra-pipelines-" + notebook.Name
+	access := []RoleBindingAccess{}
+
 	// Reconcile a RoleBinding for pipelines for the notebook service account
-	if err := r.reconcileRoleBinding(notebook, ctx, roleBindingName, "Role", "ds-pipeline-user-access-dspa"); err != nil {
+	roleBinding, err := r.reconcileRoleBinding(notebook, ctx, pipelinesRoleBindingName(notebook), "Role", pipelinesRoleRefName)
+	if err != nil {
 		return err
 	}
+	if roleBinding != nil {
+		access = append(access, NewRoleBindingAccess(roleBinding))
+	}
+
+	return r.setPipelinesAccess(notebook, ctx, access)
+}
+
+// RemoveRoleBindings deletes the RoleBindings created for the notebook when
+// the pipelines access is disabled.
+func (r *OpenshiftNotebookReconciler) RemoveRoleBindings(
+	notebook *nbv1.Notebook, ctx context.Context) error {
+
+	log := r.notebookLogger(notebook)
+
+	roleBinding := &rbacv1.RoleBinding{}
+	err := r.Get(ctx, types.NamespacedName{Name: pipelinesRoleBindingName(notebook), Namespace: notebook.Namespace}, roleBinding)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Failed to get RoleBinding")
+		return err
+	}
+	if err == nil && metav1.IsControlledBy(roleBinding, notebook) {
+		log.Info("Deleting RoleBinding", "RoleBinding.Namespace", roleBinding.Namespace, "RoleBinding.Name", roleBinding.Name)
+		err = r.Delete(ctx, roleBinding)
+		if err != nil && !apierrs.IsNotFound(err) {
+			log.Error(err, "Failed to delete RoleBinding", "RoleBinding.Namespace", roleBinding.Namespace, "RoleBinding.Name", roleBinding.Name)
+			return err
+		}
+		r.recordEvent(notebook, corev1.EventTypeNormal, "RoleBindingDeleted",
+			"RoleBinding %s deleted, the pipelines access is disabled", roleBinding.Name)
+	}
 
-	return nil
+	return r.setPipelinesAccess(notebook, ctx, nil)
 }
@@ -0,0 +1,81 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileRoleBindings(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: pipelinesRoleRefName, Namespace: "ns"}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook, role)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	roleBindingKey := client.ObjectKey{Namespace: "ns", Name: "elyra-pipelines-nb"}
+
+	// Bind the pipelines role
+	require.NoError(t, r.ReconcileRoleBindings(notebook, ctx))
+	roleBinding := &rbacv1.RoleBinding{}
+	require.NoError(t, r.Get(ctx, roleBindingKey, roleBinding))
+	assert.JSONEq(t,
+		`[{"roleBinding":"elyra-pipelines-nb","roleRef":"Role/ds-pipeline-user-access-dspa","subjects":["ServiceAccount/ns/nb"]}]`,
+		notebook.Annotations[AnnotationPipelinesAccess])
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "RoleBindingCreated")
+
+	// Reconciling again is a no-op
+	require.NoError(t, r.ReconcileRoleBindings(notebook, ctx))
+	assert.Empty(t, recorder.Events)
+
+	// Remove the access when the feature is disabled
+	require.NoError(t, r.RemoveRoleBindings(notebook, ctx))
+	assert.Error(t, r.Get(ctx, roleBindingKey, roleBinding))
+	assert.NotContains(t, notebook.Annotations, AnnotationPipelinesAccess)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "RoleBindingDeleted")
+
+	// No access is recorded when the role does not exist
+	require.NoError(t, r.Delete(ctx, role))
+	require.NoError(t, r.ReconcileRoleBindings(notebook, ctx))
+	assert.NotContains(t, notebook.Annotations, AnnotationPipelinesAccess)
+}
+
+func TestRecordEventAdmissionUID(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	r := newTestReconciler(t, OAuthConfig{})
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	r.recordEvent(notebook, "Normal", "RoleBindingCreated", "Created %s", "rb")
+	assert.Equal(t, "Normal RoleBindingCreated Created rb", <-recorder.Events)
+
+	// The events correlate with the last admission of the notebook
+	notebook.Annotations = map[string]string{AnnotationLastAdmissionUID: "admission-uid"}
+	r.recordEvent(notebook, "Normal", "RoleBindingCreated", "Created %s", "rb")
+	assert.Equal(t, "Normal RoleBindingCreated Created rb map["+AnnotationAdmissionUID+":admission-uid]",
+		<-recorder.Events)
+}
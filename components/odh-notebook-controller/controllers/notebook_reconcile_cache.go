@@ -0,0 +1,380 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// DefaultReconcileCacheConfigMap is the default ConfigMap of the
+	// controller namespace persisting the reconcile cache.
+	DefaultReconcileCacheConfigMap = "odh-notebook-controller-reconcile-cache"
+	// DefaultReconcileCacheFlushInterval is the default interval between two
+	// writes of the reconcile cache to its ConfigMap.
+	DefaultReconcileCacheFlushInterval = 30 * time.Second
+
+	// reconcileCacheHashLength is the length of the hashes kept by the
+	// reconcile cache, short enough to persist tens of thousands of notebooks
+	// in a ConfigMap.
+	reconcileCacheHashLength = 16
+)
+
+// ReconcileCache remembers the hash of the desired state last applied to
+// each notebook, so that the reconciles of the notebooks whose desired state
+// did not change, e.g. after a restart of the controller or on the periodic
+// resyncs, skip the sub-reconcilers rather than issuing no-op requests to the
+// API server. The entry of a notebook is invalidated when an object it
+// controls is changed or deleted, so that the drift of the objects is still
+// reverted, including while its sub-reconcilers run.
+type ReconcileCache struct {
+	// Fingerprint identifies the configuration of the controller, e.g. its
+	// version and flags, the hashes recorded with another configuration never
+	// match.
+	Fingerprint string
+	// Client persists the cache in the ConfigMap, the cache is only kept in
+	// memory if nil.
+	Client client.Client
+	// ConfigMap is the ConfigMap persisting the cache.
+	ConfigMap types.NamespacedName
+	// Interval is the interval between two writes of the ConfigMap.
+	Interval time.Duration
+	Log      logr.Logger
+
+	mu     sync.Mutex
+	loaded bool
+	dirty  bool
+	hashes map[types.NamespacedName]string
+	// versions count the invalidations of the notebooks, so that the hashes
+	// of the reconciles started before an invalidation are not recorded
+	versions map[types.NamespacedName]uint64
+}
+
+// reconcileCacheKey returns the key of the notebook in the ConfigMap, the
+// underscore being allowed in neither the namespaces nor the names.
+func reconcileCacheKey(key types.NamespacedName) string {
+	return key.Namespace + "_" + key.Name
+}
+
+// DesiredStateHash returns the hash of the inputs of the sub-reconcilers of
+// the notebook: the notebook itself, and the other objects they read given
+// by their resource versions (see desiredStateInputs). The last activity of
+// the notebook, updated by the culler, is not an input.
+func (c *ReconcileCache) DesiredStateHash(notebook *nbv1.Notebook, inputs map[string]string) (string, error) {
+	annotations := map[string]string{}
+	for key, value := range notebook.Annotations {
+		if key != culler.LAST_ACTIVITY_ANNOTATION {
+			annotations[key] = value
+		}
+	}
+	data, err := json.Marshal(struct {
+		Fingerprint string            `json:"fingerprint"`
+		UID         types.UID         `json:"uid"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		Spec        nbv1.NotebookSpec `json:"spec"`
+		Inputs      map[string]string `json:"inputs"`
+	}{c.Fingerprint, notebook.UID, notebook.Labels, annotations, notebook.Spec, inputs})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:reconcileCacheHashLength], nil
+}
+
+// Matches returns true if the hash is the one last recorded for the
+// notebook, along with the version of its entry the hash applied by the
+// reconcile is recorded against. The cache is loaded from its ConfigMap on
+// the first call.
+func (c *ReconcileCache) Matches(ctx context.Context, key types.NamespacedName, hash string) (bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	version := c.versions[key]
+	if err := c.load(ctx); err != nil {
+		c.Log.Error(err, "Unable to load the reconcile cache, reconciling the notebook")
+		return false, version
+	}
+	return c.hashes[key] == hash, version
+}
+
+// Record records the hash of the desired state applied to the notebook,
+// unless the notebook was invalidated since Matches returned the version of
+// its entry, e.g. by a change of a controlled object while its
+// sub-reconcilers ran.
+func (c *ReconcileCache) Record(key types.NamespacedName, hash string, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versions[key] != version {
+		return
+	}
+	if c.hashes == nil {
+		c.hashes = map[types.NamespacedName]string{}
+	}
+	if c.hashes[key] != hash {
+		c.hashes[key] = hash
+		c.dirty = true
+	}
+}
+
+// Invalidate forgets the notebook, whose next reconcile runs the
+// sub-reconcilers.
+func (c *ReconcileCache) Invalidate(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versions == nil {
+		c.versions = map[types.NamespacedName]uint64{}
+	}
+	c.versions[key]++
+	if !c.loaded {
+		// Keep an empty hash, matching no desired state, so that the hash
+		// persisted in the ConfigMap is not loaded
+		if c.hashes == nil {
+			c.hashes = map[types.NamespacedName]string{}
+		}
+		c.hashes[key] = ""
+		return
+	}
+	if _, ok := c.hashes[key]; ok {
+		delete(c.hashes, key)
+		c.dirty = true
+	}
+}
+
+// InvalidatingPredicate invalidates the notebooks whose controlled objects
+// are changed or deleted. It filters out no event.
+func (c *ReconcileCache) InvalidatingPredicate() predicate.Funcs {
+	invalidateOwner := func(object client.Object) {
+		if _, ok := object.(*nbv1.Notebook); ok {
+			return
+		}
+		owner := metav1.GetControllerOf(object)
+		if owner != nil && owner.Kind == "Notebook" {
+			c.Invalidate(types.NamespacedName{Namespace: object.GetNamespace(), Name: owner.Name})
+		}
+	}
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			// The periodic resyncs do not change the objects
+			if e.ObjectOld.GetResourceVersion() != e.ObjectNew.GetResourceVersion() {
+				invalidateOwner(e.ObjectNew)
+			}
+			return true
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			invalidateOwner(e.Object)
+			return true
+		},
+	}
+}
+
+// load loads the cache from its ConfigMap once, the caller holds the lock.
+func (c *ReconcileCache) load(ctx context.Context) error {
+	if c.loaded {
+		return nil
+	}
+	if c.hashes == nil {
+		c.hashes = map[types.NamespacedName]string{}
+	}
+	if c.Client == nil {
+		c.loaded = true
+		return nil
+	}
+	configMap := &corev1.ConfigMap{}
+	err := c.Client.Get(ctx, c.ConfigMap, configMap)
+	if apierrs.IsNotFound(err) {
+		c.loaded = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for key, hash := range configMap.Data {
+		namespace, name, ok := strings.Cut(key, "_")
+		if !ok {
+			continue
+		}
+		// The hashes recorded since the start of the controller are newer
+		notebookKey := types.NamespacedName{Namespace: namespace, Name: name}
+		if _, recorded := c.hashes[notebookKey]; !recorded {
+			c.hashes[notebookKey] = hash
+		}
+	}
+	c.Log.Info("Loaded the reconcile cache", "notebooks", len(configMap.Data))
+	c.loaded = true
+	return nil
+}
+
+// Start writes the cache to its ConfigMap every interval, and once stopped.
+func (c *ReconcileCache) Start(ctx context.Context) error {
+	if c.Client == nil {
+		return nil
+	}
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.Flush(ctx); err != nil {
+			c.Log.Error(err, "Unable to persist the reconcile cache")
+		}
+	}, c.Interval)
+	// Persist the last changes before the controller stops
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Flush(flushCtx); err != nil {
+		c.Log.Error(err, "Unable to persist the reconcile cache")
+	}
+	return nil
+}
+
+// NeedLeaderElection makes the cache persisted by the leader only, which
+// reconciles the notebooks.
+func (c *ReconcileCache) NeedLeaderElection() bool {
+	return true
+}
+
+// Flush writes the cache to its ConfigMap if it changed since the last
+// write.
+func (c *ReconcileCache) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.dirty || !c.loaded {
+		c.mu.Unlock()
+		return nil
+	}
+	data := make(map[string]string, len(c.hashes))
+	for key, hash := range c.hashes {
+		if hash != "" {
+			data[reconcileCacheKey(key)] = hash
+		}
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	err := c.writeConfigMap(ctx, data)
+	if err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// writeConfigMap creates or replaces the data of the ConfigMap of the cache.
+func (c *ReconcileCache) writeConfigMap(ctx context.Context, data map[string]string) error {
+	configMap := &corev1.ConfigMap{}
+	err := c.Client.Get(ctx, c.ConfigMap, configMap)
+	if apierrs.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      c.ConfigMap.Name,
+				Namespace: c.ConfigMap.Namespace,
+				Labels:    map[string]string{LabelManagedBy: ManagedByValue},
+			},
+			Data: data,
+		}
+		return c.Client.Create(ctx, configMap)
+	} else if err != nil {
+		return err
+	}
+	mergeLabels(configMap, map[string]string{LabelManagedBy: ManagedByValue})
+	configMap.Data = data
+	return c.Client.Update(ctx, configMap)
+}
+
+// reconcileCacheEntry is the desired state of the notebook checked against
+// the reconcile cache before the sub-reconcilers run, recorded once they
+// applied it.
+type reconcileCacheEntry struct {
+	inputs  map[string]string
+	version uint64
+}
+
+// desiredStateInputs returns the resource versions of the objects other than
+// the notebook read by the sub-reconcilers: the namespace of the notebook and
+// its CA bundle ConfigMaps. The objects controlled by the notebook invalidate
+// it when changed instead, and the ImageStreams are only read by the webhook,
+// their changes reaching the notebook.
+func (r *OpenshiftNotebookReconciler) desiredStateInputs(notebook *nbv1.Notebook,
+	ctx context.Context) (map[string]string, error) {
+	inputs := map[string]string{}
+	objects := map[string]client.Object{
+		"Namespace": &corev1.Namespace{},
+		"ConfigMap/" + TrustedCABundleConfigMapName: &corev1.ConfigMap{},
+		"ConfigMap/" + KubeRootCAConfigMapName:      &corev1.ConfigMap{},
+	}
+	for input, object := range objects {
+		key := client.ObjectKey{Namespace: notebook.Namespace, Name: strings.TrimPrefix(input, "ConfigMap/")}
+		if input == "Namespace" {
+			key = client.ObjectKey{Name: notebook.Namespace}
+		}
+		err := r.Get(ctx, key, object)
+		if apierrs.IsNotFound(err) {
+			inputs[input] = ""
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		inputs[input] = object.GetResourceVersion()
+	}
+	return inputs, nil
+}
+
+// desiredStateApplied returns true if the reconcile cache recorded the
+// desired state of the notebook as applied, and the entry to record once the
+// sub-reconcilers applied it otherwise.
+func (r *OpenshiftNotebookReconciler) desiredStateApplied(notebook *nbv1.Notebook,
+	ctx context.Context) (bool, reconcileCacheEntry, error) {
+	if r.ReconcileCache == nil {
+		return false, reconcileCacheEntry{}, nil
+	}
+	inputs, err := r.desiredStateInputs(notebook, ctx)
+	if err != nil {
+		return false, reconcileCacheEntry{}, err
+	}
+	hash, err := r.ReconcileCache.DesiredStateHash(notebook, inputs)
+	if err != nil {
+		return false, reconcileCacheEntry{}, err
+	}
+	applied, version := r.ReconcileCache.Matches(ctx, client.ObjectKeyFromObject(notebook), hash)
+	return applied, reconcileCacheEntry{inputs: inputs, version: version}, nil
+}
+
+// recordDesiredState records the desired state of the notebook as applied
+// in the reconcile cache.
+func (r *OpenshiftNotebookReconciler) recordDesiredState(notebook *nbv1.Notebook, entry reconcileCacheEntry) error {
+	if r.ReconcileCache == nil {
+		return nil
+	}
+	hash, err := r.ReconcileCache.DesiredStateHash(notebook, entry.inputs)
+	if err != nil {
+		return err
+	}
+	r.ReconcileCache.Record(client.ObjectKeyFromObject(notebook), hash, entry.version)
+	return nil
+}
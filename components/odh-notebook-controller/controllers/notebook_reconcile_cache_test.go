@@ -0,0 +1,150 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestReconcileCacheDesiredStateHash(t *testing.T) {
+	cache := &ReconcileCache{Fingerprint: "v1"}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "uid",
+		Annotations: map[string]string{}}}
+	inputs := map[string]string{"Namespace": "1", "ConfigMap/" + TrustedCABundleConfigMapName: ""}
+	hash, err := cache.DesiredStateHash(notebook, inputs)
+	assert.NoError(t, err)
+	assert.Len(t, hash, reconcileCacheHashLength)
+
+	// The last activity is not an input of the sub-reconcilers
+	notebook.Annotations[culler.LAST_ACTIVITY_ANNOTATION] = "2024-01-01T00:00:00Z"
+	same, _ := cache.DesiredStateHash(notebook, inputs)
+	assert.Equal(t, hash, same)
+
+	notebook.Annotations[AnnotationInjectOAuth] = "true"
+	changed, _ := cache.DesiredStateHash(notebook, inputs)
+	assert.NotEqual(t, hash, changed)
+
+	// The changes of the other objects read by the sub-reconcilers change
+	// the hash
+	inputs["ConfigMap/"+TrustedCABundleConfigMapName] = "2"
+	changedInputs, _ := cache.DesiredStateHash(notebook, inputs)
+	assert.NotEqual(t, changed, changedInputs)
+
+	// The hashes of another configuration of the controller never match
+	other, _ := (&ReconcileCache{Fingerprint: "v2"}).DesiredStateHash(notebook, inputs)
+	assert.NotEqual(t, changedInputs, other)
+}
+
+func TestReconcileCachePersistence(t *testing.T) {
+	ctx := context.Background()
+	c := newTestReconciler(t, OAuthConfig{}).Client
+	configMap := types.NamespacedName{Namespace: "controller", Name: DefaultReconcileCacheConfigMap}
+	key := types.NamespacedName{Namespace: "ns", Name: "nb.with.dots"}
+	invalidated := types.NamespacedName{Namespace: "ns", Name: "invalidated"}
+
+	cache := &ReconcileCache{Client: c, ConfigMap: configMap}
+	matches, version := cache.Matches(ctx, key, "hash")
+	assert.False(t, matches)
+	cache.Record(key, "hash", version)
+	cache.Record(invalidated, "hash", 0)
+	matches, _ = cache.Matches(ctx, key, "hash")
+	assert.True(t, matches)
+	assert.NoError(t, cache.Flush(ctx))
+	found := &corev1.ConfigMap{}
+	assert.NoError(t, c.Get(ctx, configMap, found))
+	assert.Equal(t, map[string]string{"ns_nb.with.dots": "hash", "ns_invalidated": "hash"}, found.Data)
+
+	// The restarted controller loads the hashes, except those of the
+	// notebooks invalidated before the load
+	restarted := &ReconcileCache{Client: c, ConfigMap: configMap}
+	restarted.Invalidate(invalidated)
+	matches, _ = restarted.Matches(ctx, key, "hash")
+	assert.True(t, matches)
+	matches, _ = restarted.Matches(ctx, key, "other")
+	assert.False(t, matches)
+	matches, _ = restarted.Matches(ctx, invalidated, "hash")
+	assert.False(t, matches)
+}
+
+func TestReconcileCacheInvalidatingPredicate(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "uid"}}
+	notebook.SetGroupVersionKind(nbv1.GroupVersion.WithKind("Notebook"))
+	key := client.ObjectKeyFromObject(notebook)
+	newService := func(resourceVersion string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+			ResourceVersion: resourceVersion, OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(notebook, notebook.GroupVersionKind()),
+			}}}
+	}
+	cache := &ReconcileCache{}
+	predicate := cache.InvalidatingPredicate()
+	_, version := cache.Matches(ctx, key, "hash")
+	cache.Record(key, "hash", version)
+
+	// The resyncs do not invalidate the notebook
+	assert.True(t, predicate.Update(event.UpdateEvent{ObjectOld: newService("1"), ObjectNew: newService("1")}))
+	matches, version := cache.Matches(ctx, key, "hash")
+	assert.True(t, matches)
+
+	// The changes of the controlled objects invalidate the notebook
+	assert.True(t, predicate.Update(event.UpdateEvent{ObjectOld: newService("1"), ObjectNew: newService("2")}))
+	matches, version = cache.Matches(ctx, key, "hash")
+	assert.False(t, matches)
+	cache.Record(key, "hash", version)
+	assert.True(t, predicate.Delete(event.DeleteEvent{Object: newService("2")}))
+	matches, _ = cache.Matches(ctx, key, "hash")
+	assert.False(t, matches)
+
+	// The hashes of the reconciles started before a change of the controlled
+	// objects are not recorded
+	_, version = cache.Matches(ctx, key, "hash")
+	assert.True(t, predicate.Update(event.UpdateEvent{ObjectOld: newService("2"), ObjectNew: newService("3")}))
+	cache.Record(key, "hash", version)
+	matches, _ = cache.Matches(ctx, key, "hash")
+	assert.False(t, matches)
+}
+
+func TestDesiredStateInputs(t *testing.T) {
+	ctx := context.Background()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "uid"}}
+	r := newTestReconciler(t, OAuthConfig{}, namespace, notebook)
+	r.ReconcileCache = &ReconcileCache{}
+
+	applied, entry, err := r.desiredStateApplied(notebook, ctx)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+	assert.NoError(t, r.recordDesiredState(notebook, entry))
+	applied, _, _ = r.desiredStateApplied(notebook, ctx)
+	assert.True(t, applied)
+
+	// The creation of the CA bundle ConfigMap changes the desired state
+	assert.NoError(t, r.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: TrustedCABundleConfigMapName, Namespace: "ns"}}))
+	applied, _, _ = r.desiredStateApplied(notebook, ctx)
+	assert.False(t, applied)
+}
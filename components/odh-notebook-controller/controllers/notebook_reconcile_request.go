@@ -0,0 +1,60 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationReconcile requests an immediate reconcile of the notebook
+	// resources when set to AnnotationValueReconcileNow, as a safe
+	// alternative to deleting the child objects to kick the controller. The
+	// controller removes it once the resources are reconciled.
+	AnnotationReconcile         = "notebooks.opendatahub.io/reconcile"
+	AnnotationValueReconcileNow = "now"
+)
+
+// ReconcileIsRequested returns true if the notebook requests an immediate
+// reconcile of its resources.
+func ReconcileIsRequested(meta metav1.ObjectMeta) bool {
+	return meta.Annotations[AnnotationReconcile] == AnnotationValueReconcileNow
+}
+
+// ClearReconcileRequest removes the reconcile request annotation once the
+// notebook resources are reconciled. The patch fails with a conflict if the
+// notebook changed in the meantime, so that a new request is not lost.
+func (r *OpenshiftNotebookReconciler) ClearReconcileRequest(notebook *nbv1.Notebook,
+	ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	patch := client.MergeFromWithOptions(notebook.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	delete(notebook.Annotations, AnnotationReconcile)
+	if err := r.Patch(ctx, notebook, patch); err != nil {
+		log.Error(err, "Unable to clear the reconcile request")
+		return err
+	}
+	log.Info("Reconciled the notebook resources on request")
+	r.recordEvent(notebook, corev1.EventTypeNormal, "ReconcileRequestCompleted",
+		"The notebook resources were reconciled on request")
+	return nil
+}
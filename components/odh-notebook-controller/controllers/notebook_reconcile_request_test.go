@@ -0,0 +1,59 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileIsRequested(t *testing.T) {
+	assert.False(t, ReconcileIsRequested(metav1.ObjectMeta{}))
+	assert.False(t, ReconcileIsRequested(metav1.ObjectMeta{
+		Annotations: map[string]string{AnnotationReconcile: "later"}}))
+	assert.True(t, ReconcileIsRequested(metav1.ObjectMeta{
+		Annotations: map[string]string{AnnotationReconcile: AnnotationValueReconcileNow}}))
+}
+
+func TestClearReconcileRequest(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t, OAuthConfig{}, &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name: "nb", Namespace: "ns", Annotations: map[string]string{
+			AnnotationReconcile:   AnnotationValueReconcileNow,
+			AnnotationInjectOAuth: "true",
+		}}})
+
+	notebook := &nbv1.Notebook{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Name: "nb", Namespace: "ns"}, notebook))
+	stale := notebook.DeepCopy()
+	require.NoError(t, r.ClearReconcileRequest(notebook, ctx))
+
+	found := &nbv1.Notebook{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.NotContains(t, found.Annotations, AnnotationReconcile)
+	assert.Equal(t, "true", found.Annotations[AnnotationInjectOAuth])
+
+	// A request made while the resources were reconciled is not lost
+	err := r.ClearReconcileRequest(stale, ctx)
+	assert.True(t, apierrs.IsConflict(err))
+}
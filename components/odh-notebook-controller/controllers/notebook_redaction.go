@@ -0,0 +1,145 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"regexp"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// RedactedValue replaces sensitive values in logs and diffs.
+const RedactedValue = "<redacted>"
+
+var (
+	// sensitiveKeyPattern matches the names of log keys, environment variables
+	// and flags whose values must never be printed.
+	sensitiveKeyPattern = regexp.MustCompile(`(?i)(secret|token|passw(or)?d|credential|private[-_]?key|api[-_]?key|cookie)`)
+
+	// sensitiveValuePatterns match sensitive values embedded in free text, the
+	// first group is the part of the match that is kept.
+	sensitiveValuePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(--[a-z0-9-]*(?:secret|token|password)[a-z0-9-]*=)[^\s"',]+`),
+		regexp.MustCompile(`(?i)(bearer\s+)[a-z0-9\-._~+/]+=*`),
+		regexp.MustCompile(`(?i)("?(?:cookie_secret|client_secret|token|password)"?\s*[:=]\s*"?)[^\s"',}]+`),
+	}
+)
+
+// RedactString masks the sensitive values (secret flags, bearer tokens,
+// password and token assignments) found in the given string.
+func RedactString(s string) string {
+	for _, pattern := range sensitiveValuePatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+RedactedValue)
+	}
+	return s
+}
+
+// isSensitivePath returns true if the given go-cmp path walks through an
+// environment variable with a sensitive name or the data of a Secret.
+func isSensitivePath(path cmp.Path) bool {
+	for _, step := range path {
+		vx, vy := step.Values()
+		for _, v := range []interface{}{valueInterface(vx), valueInterface(vy)} {
+			switch obj := v.(type) {
+			case corev1.EnvVar:
+				if sensitiveKeyPattern.MatchString(obj.Name) {
+					return true
+				}
+			case corev1.Secret, *corev1.Secret:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func valueInterface(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// redactKeysAndValues returns a copy of the structured logging key/value pairs
+// with the sensitive values masked.
+func redactKeysAndValues(keysAndValues []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keysAndValues))
+	copy(redacted, keysAndValues)
+	for i := 1; i < len(redacted); i += 2 {
+		if key, ok := redacted[i-1].(string); ok && sensitiveKeyPattern.MatchString(key) {
+			redacted[i] = RedactedValue
+			continue
+		}
+		switch value := redacted[i].(type) {
+		case string:
+			redacted[i] = RedactString(value)
+		case []byte:
+			redacted[i] = RedactString(string(value))
+		case corev1.Secret, *corev1.Secret:
+			redacted[i] = RedactedValue
+		}
+	}
+	return redacted
+}
+
+// redactingLogSink is a logr.LogSink that masks sensitive values before they
+// reach the underlying sink.
+type redactingLogSink struct {
+	logr.LogSink
+}
+
+// NewRedactingLogger wraps the given logger so that cookie secrets, tokens and
+// Secret data are never written to the logs, even in debug mode.
+func NewRedactingLogger(log logr.Logger) logr.Logger {
+	sink := log.GetSink()
+	if sink == nil {
+		return log
+	}
+	// Account for the extra frame added by the wrapper
+	if callDepthSink, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = callDepthSink.WithCallDepth(1)
+	}
+	return logr.New(redactingLogSink{LogSink: sink})
+}
+
+// Init is a no-op, the wrapped sink has already been initialized.
+func (s redactingLogSink) Init(logr.RuntimeInfo) {}
+
+func (s redactingLogSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.LogSink.Info(level, RedactString(msg), redactKeysAndValues(keysAndValues)...)
+}
+
+func (s redactingLogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.LogSink.Error(err, RedactString(msg), redactKeysAndValues(keysAndValues)...)
+}
+
+func (s redactingLogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return redactingLogSink{LogSink: s.LogSink.WithValues(redactKeysAndValues(keysAndValues)...)}
+}
+
+func (s redactingLogSink) WithName(name string) logr.LogSink {
+	return redactingLogSink{LogSink: s.LogSink.WithName(name)}
+}
+
+func (s redactingLogSink) WithCallDepth(depth int) logr.LogSink {
+	if sink, ok := s.LogSink.(logr.CallDepthLogSink); ok {
+		return redactingLogSink{LogSink: sink.WithCallDepth(depth)}
+	}
+	return s
+}
@@ -0,0 +1,222 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationStrictReferenceValidation denies the admission of the
+	// notebook when it references Secrets, ConfigMaps or PVCs which do not
+	// exist in its namespace.
+	AnnotationStrictReferenceValidation = "notebooks.opendatahub.io/strict-reference-validation"
+
+	// The kinds of the objects referenced by the notebook pods.
+	referenceKindSecret    = "Secret"
+	referenceKindConfigMap = "ConfigMap"
+	referenceKindPVC       = "PersistentVolumeClaim"
+)
+
+// controllerVolumes are the volumes injected by the webhook whose objects are
+// created by the controller after the admission of the notebook.
+var controllerVolumes = map[string]bool{
+	"oauth-config":     true,
+	"tls-certificates": true,
+}
+
+// NotebookReference is a Secret, ConfigMap or PVC the notebook pod requires
+// to start, or one of its keys.
+type NotebookReference struct {
+	Kind string
+	Name string
+	// Key is the key of the Secret or ConfigMap required by an environment
+	// variable, empty if the whole object is required.
+	Key string
+}
+
+// String describes the reference for the users.
+func (r NotebookReference) String() string {
+	if r.Key != "" {
+		return fmt.Sprintf("the key %s of the %s %s", r.Key, r.Kind, r.Name)
+	}
+	return fmt.Sprintf("the %s %s", r.Kind, r.Name)
+}
+
+// isRequired returns true unless the reference is optional.
+func isRequired(optional *bool) bool {
+	return optional == nil || !*optional
+}
+
+// NotebookReferences returns the required Secrets, ConfigMaps and PVCs of the
+// notebook pod, missing ones leave the pod in the CreateContainerConfigError
+// state or unschedulable. The optional references and the objects created by
+// the controller are skipped.
+func NotebookReferences(notebook *nbv1.Notebook) []NotebookReference {
+	podSpec := &notebook.Spec.Template.Spec
+	references := []NotebookReference{}
+	found := map[NotebookReference]bool{}
+	add := func(reference NotebookReference) {
+		if reference.Name != "" && !found[reference] {
+			found[reference] = true
+			references = append(references, reference)
+		}
+	}
+
+	for _, volume := range podSpec.Volumes {
+		if controllerVolumes[volume.Name] {
+			continue
+		}
+		switch {
+		case volume.Secret != nil && isRequired(volume.Secret.Optional):
+			add(NotebookReference{Kind: referenceKindSecret, Name: volume.Secret.SecretName})
+		case volume.ConfigMap != nil && isRequired(volume.ConfigMap.Optional):
+			add(NotebookReference{Kind: referenceKindConfigMap, Name: volume.ConfigMap.Name})
+		case volume.PersistentVolumeClaim != nil:
+			add(NotebookReference{Kind: referenceKindPVC, Name: volume.PersistentVolumeClaim.ClaimName})
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil && isRequired(source.Secret.Optional) {
+					add(NotebookReference{Kind: referenceKindSecret, Name: source.Secret.Name})
+				}
+				if source.ConfigMap != nil && isRequired(source.ConfigMap.Optional) {
+					add(NotebookReference{Kind: referenceKindConfigMap, Name: source.ConfigMap.Name})
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil && isRequired(envFrom.SecretRef.Optional) {
+				add(NotebookReference{Kind: referenceKindSecret, Name: envFrom.SecretRef.Name})
+			}
+			if envFrom.ConfigMapRef != nil && isRequired(envFrom.ConfigMapRef.Optional) {
+				add(NotebookReference{Kind: referenceKindConfigMap, Name: envFrom.ConfigMapRef.Name})
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil && isRequired(ref.Optional) {
+				add(NotebookReference{Kind: referenceKindSecret, Name: ref.Name, Key: ref.Key})
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil && isRequired(ref.Optional) {
+				add(NotebookReference{Kind: referenceKindConfigMap, Name: ref.Name, Key: ref.Key})
+			}
+		}
+	}
+	return references
+}
+
+// MissingNotebookReferences returns the required references of the notebook
+// pod which do not exist in the namespace of the notebook.
+func MissingNotebookReferences(ctx context.Context, reader client.Reader,
+	notebook *nbv1.Notebook) ([]NotebookReference, error) {
+	missing := []NotebookReference{}
+	secrets := map[string]*corev1.Secret{}
+	configMaps := map[string]*corev1.ConfigMap{}
+	for _, reference := range NotebookReferences(notebook) {
+		key := client.ObjectKey{Namespace: notebook.Namespace, Name: reference.Name}
+		var err error
+		exists := true
+		switch reference.Kind {
+		case referenceKindSecret:
+			secret, fetched := secrets[reference.Name]
+			if !fetched {
+				secret = &corev1.Secret{}
+				if err = reader.Get(ctx, key, secret); apierrs.IsNotFound(err) {
+					secret, err = nil, nil
+				}
+				secrets[reference.Name] = secret
+			}
+			if secret == nil {
+				exists = false
+			} else if reference.Key != "" {
+				_, exists = secret.Data[reference.Key]
+			}
+		case referenceKindConfigMap:
+			configMap, fetched := configMaps[reference.Name]
+			if !fetched {
+				configMap = &corev1.ConfigMap{}
+				if err = reader.Get(ctx, key, configMap); apierrs.IsNotFound(err) {
+					configMap, err = nil, nil
+				}
+				configMaps[reference.Name] = configMap
+			}
+			if configMap == nil {
+				exists = false
+			} else if reference.Key != "" {
+				_, inData := configMap.Data[reference.Key]
+				_, inBinaryData := configMap.BinaryData[reference.Key]
+				exists = inData || inBinaryData
+			}
+		case referenceKindPVC:
+			if err = reader.Get(ctx, key, &corev1.PersistentVolumeClaim{}); apierrs.IsNotFound(err) {
+				exists, err = false, nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, reference)
+		}
+	}
+	return missing, nil
+}
+
+// MissingReferencesMessage describes the missing references of the notebook.
+func MissingReferencesMessage(notebook *nbv1.Notebook, missing []NotebookReference) string {
+	descriptions := make([]string, 0, len(missing))
+	for _, reference := range missing {
+		descriptions = append(descriptions, reference.String())
+	}
+	return fmt.Sprintf("The notebook references %s, missing from the namespace %s, "+
+		"the notebook cannot start until they are created", strings.Join(descriptions, ", "), notebook.Namespace)
+}
+
+// referencesNeedValidation returns true if the references of the notebook
+// are checked by the admission: when the notebook is created, started or its
+// pod changes, and it is not stopped. The notebooks can always be stopped.
+func referencesNeedValidation(notebook, oldNotebook *nbv1.Notebook) bool {
+	if notebookIsStopped(notebook.ObjectMeta) {
+		return false
+	}
+	return oldNotebook == nil || notebookIsStopped(oldNotebook.ObjectMeta) ||
+		!equality.Semantic.DeepEqual(oldNotebook.Spec.Template.Spec, notebook.Spec.Template.Spec)
+}
+
+// strictReferenceValidation returns true if the notebook must not be admitted
+// when its references are missing.
+func (w *NotebookWebhook) strictReferenceValidation(notebook *nbv1.Notebook) bool {
+	if value, ok := notebook.GetAnnotations()[AnnotationStrictReferenceValidation]; ok {
+		strict, err := strconv.ParseBool(value)
+		return err == nil && strict
+	}
+	return w.StrictReferenceValidation
+}
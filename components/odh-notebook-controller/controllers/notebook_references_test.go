@@ -0,0 +1,104 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func newReferencingNotebook() *nbv1.Notebook {
+	return &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{Name: "home", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "home"}}},
+				{Name: "certs", VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "certs"}}},
+				{Name: "optional", VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "optional"},
+						Optional:             pointer.Bool(true),
+					}}},
+				{Name: "oauth-config", VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "nb-oauth-config"}}},
+			},
+			Containers: []corev1.Container{{
+				Name: "nb",
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}}},
+				Env: []corev1.EnvVar{{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "certs"},
+						Key:                  "token",
+					}}}},
+			}},
+		}}},
+	}
+}
+
+func TestNotebookReferences(t *testing.T) {
+	assert.Equal(t, []NotebookReference{
+		{Kind: referenceKindPVC, Name: "home"},
+		{Kind: referenceKindSecret, Name: "certs"},
+		{Kind: referenceKindConfigMap, Name: "settings"},
+		{Kind: referenceKindSecret, Name: "certs", Key: "token"},
+	}, NotebookReferences(newReferencingNotebook()))
+}
+
+func TestMissingNotebookReferences(t *testing.T) {
+	ctx := context.Background()
+	notebook := newReferencingNotebook()
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "home", Namespace: "ns"}}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "certs", Namespace: "ns"},
+		Data:       map[string][]byte{"tls.crt": []byte("cert")},
+	}
+	r := newTestReconciler(t, OAuthConfig{}, pvc, secret)
+
+	missing, err := MissingNotebookReferences(ctx, r.Client, notebook)
+	require.NoError(t, err)
+	assert.Equal(t, []NotebookReference{
+		{Kind: referenceKindConfigMap, Name: "settings"},
+		{Kind: referenceKindSecret, Name: "certs", Key: "token"},
+	}, missing)
+	message := MissingReferencesMessage(notebook, missing)
+	assert.Contains(t, message, "the ConfigMap settings")
+	assert.Contains(t, message, "the key token of the Secret certs")
+}
+
+func TestReferencesNeedValidation(t *testing.T) {
+	notebook := newReferencingNotebook()
+	assert.True(t, referencesNeedValidation(notebook, nil))
+
+	// The unchanged running notebooks are not checked again
+	oldNotebook := notebook.DeepCopy()
+	assert.False(t, referencesNeedValidation(notebook, oldNotebook))
+
+	// The started notebooks are checked, not the stopped ones
+	oldNotebook.Annotations = map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"}
+	assert.True(t, referencesNeedValidation(notebook, oldNotebook))
+	assert.False(t, referencesNeedValidation(oldNotebook, notebook))
+}
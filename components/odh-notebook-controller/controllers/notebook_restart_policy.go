@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationRestartRequested holds the time the controller requested the
+	// confirmation of the restart of the notebook, e.g. displayed by the
+	// dashboard so that the users save their work.
+	AnnotationRestartRequested = "notebooks.opendatahub.io/restart-requested"
+	// AnnotationRestartAcknowledged set to true by the users confirms the
+	// requested restart of the notebook.
+	AnnotationRestartAcknowledged = "notebooks.opendatahub.io/restart-acknowledged"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=delete
+
+// RestartPolicy configures the restarts of the notebooks initiated by the
+// controller, e.g. by the NotebookRollouts.
+type RestartPolicy struct {
+	// TerminationGracePeriodSeconds is the grace period of the restarted
+	// notebook pods, the one of their spec if nil.
+	TerminationGracePeriodSeconds *int64
+	// ConfirmationTimeout is the time the restarts wait for the confirmation
+	// of the users before they are forced, none is waited for if 0.
+	ConfirmationTimeout time.Duration
+}
+
+// RestartConfirmed returns true if the restart of the notebook can proceed,
+// i.e. no confirmation is required, the users confirmed it or the
+// confirmation timed out since it was requested.
+func (p RestartPolicy) RestartConfirmed(notebook *nbv1.Notebook, now time.Time) bool {
+	if p.ConfirmationTimeout <= 0 {
+		return true
+	}
+	annotations := notebook.GetAnnotations()
+	if acknowledged, _ := strconv.ParseBool(annotations[AnnotationRestartAcknowledged]); acknowledged {
+		return true
+	}
+	requested, err := time.Parse(time.RFC3339, annotations[AnnotationRestartRequested])
+	return err == nil && !now.Before(requested.Add(p.ConfirmationTimeout))
+}
+
+// RequestRestartConfirmation records the request of the confirmation of the
+// restart, and returns false if it was already requested.
+func (p RestartPolicy) RequestRestartConfirmation(ctx context.Context, c client.Client, notebook *nbv1.Notebook,
+	now time.Time) (bool, error) {
+	if _, ok := notebook.GetAnnotations()[AnnotationRestartRequested]; ok {
+		return false, nil
+	}
+	patch := client.RawPatch(types.MergePatchType, []byte(`{"metadata":{"annotations":{"`+
+		AnnotationRestartRequested+`":"`+now.UTC().Format(time.RFC3339)+`"}}}`))
+	return true, c.Patch(ctx, notebook, patch)
+}
+
+// Restart restarts the notebook, setting the given annotations along. The
+// notebook pod is deleted with the grace period of the policy if set, the
+// Kubeflow notebook controller restarts it otherwise.
+func (p RestartPolicy) Restart(ctx context.Context, c client.Client, notebook *nbv1.Notebook,
+	annotations map[string]string) error {
+	values := map[string]interface{}{
+		AnnotationRestartRequested:    nil,
+		AnnotationRestartAcknowledged: nil,
+	}
+	for key, value := range annotations {
+		values[key] = value
+	}
+	if p.TerminationGracePeriodSeconds != nil {
+		// The Kubeflow notebook controller would delete the pod with the
+		// grace period of its spec
+		pod := &corev1.Pod{}
+		pod.Name, pod.Namespace = notebook.Name+"-0", notebook.Namespace
+		err := c.Delete(ctx, pod, client.GracePeriodSeconds(*p.TerminationGracePeriodSeconds))
+		if err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+	} else {
+		values[AnnotationNotebookRestart] = "true"
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": values},
+	})
+	if err != nil {
+		return err
+	}
+	return c.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+}
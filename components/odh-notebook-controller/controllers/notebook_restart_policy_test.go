@@ -0,0 +1,87 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nbv1alpha1 "github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/api/v1alpha1"
+)
+
+func TestRestartConfirmed(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Annotations: map[string]string{}}}
+	policy := RestartPolicy{ConfirmationTimeout: time.Hour}
+
+	assert.True(t, RestartPolicy{}.RestartConfirmed(notebook, now))
+	assert.False(t, policy.RestartConfirmed(notebook, now))
+	notebook.Annotations[AnnotationRestartRequested] = now.Add(-30 * time.Minute).Format(time.RFC3339)
+	assert.False(t, policy.RestartConfirmed(notebook, now))
+	notebook.Annotations[AnnotationRestartAcknowledged] = "true"
+	assert.True(t, policy.RestartConfirmed(notebook, now))
+	delete(notebook.Annotations, AnnotationRestartAcknowledged)
+	assert.True(t, policy.RestartConfirmed(notebook, now.Add(time.Hour)))
+}
+
+func TestReconcileNotebookRolloutRestartPolicy(t *testing.T) {
+	ctx := context.Background()
+	rollout := &nbv1alpha1.NotebookRollout{
+		ObjectMeta: metav1.ObjectMeta{Name: "cve", UID: "rollout-uid"},
+		Spec: nbv1alpha1.NotebookRolloutSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "jupyter"}},
+			MaxUnavailable: 1,
+		},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a-0", Namespace: "ns"}}
+	r := newTestRolloutReconciler(t, rollout, newTestRolloutNotebook("ns", "a", nil), pod)
+	gracePeriod := int64(300)
+	r.RestartPolicy = RestartPolicy{TerminationGracePeriodSeconds: &gracePeriod, ConfirmationTimeout: time.Hour}
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rollout)}
+	notebook := &nbv1.Notebook{}
+
+	// The confirmation of the users is requested first
+	_, err := r.Reconcile(ctx, request)
+	require.NoError(t, err)
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "a"}, notebook))
+	assert.Contains(t, notebook.Annotations, AnnotationRestartRequested)
+	assert.NotContains(t, notebook.Annotations, AnnotationRollout)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(pod), pod))
+
+	// The pod is deleted once the restart is confirmed
+	notebook.Annotations[AnnotationRestartAcknowledged] = "true"
+	require.NoError(t, r.Update(ctx, notebook))
+	_, err = r.Reconcile(ctx, request)
+	require.NoError(t, err)
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "a"}, notebook))
+	assert.Equal(t, "rollout-uid", notebook.Annotations[AnnotationRollout])
+	assert.NotContains(t, notebook.Annotations, AnnotationNotebookRestart)
+	assert.NotContains(t, notebook.Annotations, AnnotationRestartRequested)
+	assert.NotContains(t, notebook.Annotations, AnnotationRestartAcknowledged)
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(pod), pod)))
+	require.NoError(t, r.Get(ctx, request.NamespacedName, rollout))
+	assert.Equal(t, int32(1), rollout.Status.InProgress)
+}
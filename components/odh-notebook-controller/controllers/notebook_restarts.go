@@ -0,0 +1,148 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ConditionOOMKilled is set on the notebooks whose container was killed
+	// for running out of memory. Its type is qualified by a domain, so that
+	// the Kubeflow notebook controller keeps it along with the conditions
+	// mirrored from the pod.
+	ConditionOOMKilled = "notebooks.opendatahub.io/OOMKilled"
+
+	// reasonOOMKilled is the termination reason of the containers killed for
+	// running out of memory.
+	reasonOOMKilled = "OOMKilled"
+)
+
+// +kubebuilder:rbac:groups=kubeflow.org,resources=notebooks/status,verbs=get;update;patch
+
+// oomKilledContainer returns the status of the first container of the pod
+// whose last termination was caused by running out of memory, nil if none.
+func oomKilledContainer(pod *corev1.Pod) *corev1.ContainerStatus {
+	for i, status := range pod.Status.ContainerStatuses {
+		for _, terminated := range []*corev1.ContainerStateTerminated{
+			status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated != nil && terminated.Reason == reasonOOMKilled {
+				return &pod.Status.ContainerStatuses[i]
+			}
+		}
+	}
+	return nil
+}
+
+// NewOOMKilledCondition defines the OOMKilled condition of the notebook from
+// its pod, nil if no container of the pod ran out of memory.
+func NewOOMKilledCondition(pod *corev1.Pod) *nbv1.NotebookCondition {
+	status := oomKilledContainer(pod)
+	if status == nil {
+		return nil
+	}
+	terminated := status.State.Terminated
+	if terminated == nil || terminated.Reason != reasonOOMKilled {
+		terminated = status.LastTerminationState.Terminated
+	}
+	limit := "none"
+	for _, container := range pod.Spec.Containers {
+		if memory, ok := container.Resources.Limits[corev1.ResourceMemory]; ok && container.Name == status.Name {
+			limit = memory.String()
+		}
+	}
+	return &nbv1.NotebookCondition{
+		Type:   ConditionOOMKilled,
+		Status: string(corev1.ConditionTrue),
+		Reason: reasonOOMKilled,
+		Message: fmt.Sprintf("The container %s ran out of memory (limit %s) and was restarted %d times, "+
+			"the running kernels and their unsaved work were lost. Pick a larger size for the notebook.",
+			status.Name, limit, status.RestartCount),
+		LastProbeTime:      terminated.FinishedAt,
+		LastTransitionTime: terminated.FinishedAt,
+	}
+}
+
+// ReconcileContainerRestarts reports the restarts of the notebook containers
+// with a metric, and the out-of-memory kills with an event and the
+// ConditionOOMKilled condition of the notebook.
+func (r *OpenshiftNotebookReconciler) ReconcileContainerRestarts(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	// The notebook pod is managed by the notebook statefulset
+	var desired *nbv1.NotebookCondition
+	pod := &corev1.Pod{}
+	err := r.Get(ctx, types.NamespacedName{Name: notebook.Name + "-0", Namespace: notebook.Namespace}, pod)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the notebook Pod")
+		return err
+	} else if err == nil {
+		for _, status := range pod.Status.ContainerStatuses {
+			notebookContainerRestarts.WithLabelValues(notebook.Namespace, notebook.Name, status.Name).
+				Set(float64(status.RestartCount))
+		}
+		desired = NewOOMKilledCondition(pod)
+	}
+
+	conditions := []nbv1.NotebookCondition{}
+	var found *nbv1.NotebookCondition
+	for i, condition := range notebook.Status.Conditions {
+		if condition.Type == ConditionOOMKilled {
+			found = &notebook.Status.Conditions[i]
+			continue
+		}
+		conditions = append(conditions, condition)
+	}
+	if found == nil && desired == nil ||
+		found != nil && desired != nil && found.Message == desired.Message &&
+			found.LastTransitionTime.Equal(&desired.LastTransitionTime) {
+		return nil
+	}
+
+	// Report the new out-of-memory kills
+	if desired != nil && (found == nil || !found.LastTransitionTime.Equal(&desired.LastTransitionTime)) {
+		container := oomKilledContainer(pod).Name
+		log.Info("Notebook container killed for running out of memory", "container", container)
+		notebookOOMKillsTotal.WithLabelValues(notebook.Namespace, notebook.Name, container).Inc()
+		r.recordEvent(notebook, corev1.EventTypeWarning, reasonOOMKilled, desired.Message)
+	}
+	if desired != nil {
+		conditions = append(conditions, *desired)
+	}
+	notebook.Status.Conditions = conditions
+	err = r.Status().Update(ctx, notebook)
+	if err != nil {
+		log.Error(err, "Unable to update the OOMKilled condition of the notebook")
+		return err
+	}
+	return nil
+}
+
+// deleteContainerRestartsMetrics deletes the restart metrics of a deleted
+// notebook.
+func deleteContainerRestartsMetrics(key types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": key.Namespace, "notebook": key.Name}
+	notebookContainerRestarts.DeletePartialMatch(labels)
+	notebookOOMKillsTotal.DeletePartialMatch(labels)
+}
@@ -0,0 +1,104 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newOOMKilledPod(restarts int32, finishedAt time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb-0", Namespace: "ns"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "nb",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+			},
+		}}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:         "nb",
+			RestartCount: restarts,
+			State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason:     reasonOOMKilled,
+				ExitCode:   137,
+				FinishedAt: metav1.NewTime(finishedAt),
+			}},
+		}}},
+	}
+}
+
+func TestNewOOMKilledCondition(t *testing.T) {
+	pod := newOOMKilledPod(2, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	condition := NewOOMKilledCondition(pod)
+	require.NotNil(t, condition)
+	assert.Equal(t, ConditionOOMKilled, condition.Type)
+	assert.Contains(t, condition.Message, "limit 2Gi")
+	assert.Contains(t, condition.Message, "restarted 2 times")
+
+	pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.Reason = "Error"
+	assert.Nil(t, NewOOMKilledCondition(pod))
+}
+
+func TestReconcileContainerRestarts(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	pod := newOOMKilledPod(1, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	r := newTestReconciler(t, OAuthConfig{})
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(notebook, pod).
+		WithStatusSubresource(&nbv1.Notebook{}).Build()
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	oomKills := notebookOOMKillsTotal.WithLabelValues("ns", "nb", "nb")
+	kills := metricValue(t, oomKills).GetCounter().GetValue()
+
+	// The out-of-memory kill is reported once
+	require.NoError(t, r.ReconcileContainerRestarts(notebook, ctx))
+	require.NoError(t, r.ReconcileContainerRestarts(notebook, ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	require.Len(t, notebook.Status.Conditions, 1)
+	assert.Equal(t, ConditionOOMKilled, notebook.Status.Conditions[0].Type)
+	assert.Contains(t, <-recorder.Events, reasonOOMKilled)
+	assert.Len(t, recorder.Events, 0)
+	assert.Equal(t, kills+1, metricValue(t, oomKills).GetCounter().GetValue())
+	restarts := notebookContainerRestarts.WithLabelValues("ns", "nb", "nb")
+	assert.Equal(t, float64(1), metricValue(t, restarts).GetGauge().GetValue())
+
+	// The next kill is reported as well
+	require.NoError(t, r.Delete(ctx, pod))
+	require.NoError(t, r.Create(ctx, newOOMKilledPod(2, time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC))))
+	require.NoError(t, r.ReconcileContainerRestarts(notebook, ctx))
+	assert.Contains(t, <-recorder.Events, "restarted 2 times")
+	assert.Equal(t, kills+2, metricValue(t, oomKills).GetCounter().GetValue())
+
+	// The condition is removed with the pod
+	require.NoError(t, r.Delete(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nb-0", Namespace: "ns"}}))
+	require.NoError(t, r.ReconcileContainerRestarts(notebook, ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+	assert.Empty(t, notebook.Status.Conditions)
+}
@@ -0,0 +1,324 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nbv1alpha1 "github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/api/v1alpha1"
+)
+
+const (
+	// AnnotationRollout holds the UID of the last rollout that restarted the
+	// notebook.
+	AnnotationRollout = "notebooks.opendatahub.io/rollout"
+	// AnnotationRolloutRestartTime holds the time the rollout restarted the
+	// notebook.
+	AnnotationRolloutRestartTime = "notebooks.opendatahub.io/rollout-restart-time"
+
+	// rolloutRequeueInterval is the interval between two checks of the
+	// progress of a rollout.
+	rolloutRequeueInterval = 30 * time.Second
+)
+
+// NotebookRolloutReconciler restarts the notebooks selected by the
+// NotebookRollout objects.
+type NotebookRolloutReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// RestartPolicy configures the grace period of the restarts and the
+	// confirmation the users are given to save their work.
+	RestartPolicy RestartPolicy
+}
+
+// +kubebuilder:rbac:groups=notebooks.opendatahub.io,resources=notebookrollouts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=notebooks.opendatahub.io,resources=notebookrollouts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// rolloutNotebookState classifies the notebooks of a rollout.
+type rolloutNotebookState int
+
+const (
+	rolloutPending rolloutNotebookState = iota
+	rolloutInProgress
+	rolloutRestarted
+	rolloutSkipped
+)
+
+// Reconcile restarts the pending notebooks of the rollout, at most
+// MaxUnavailable at a time, and reports the progress in the status.
+func (r *NotebookRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("notebookrollout", req.Name)
+
+	rollout := &nbv1alpha1.NotebookRollout{}
+	err := r.Get(ctx, req.NamespacedName, rollout)
+	if apierrs.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the NotebookRollout")
+		return ctrl.Result{}, err
+	}
+
+	if meta.IsStatusConditionTrue(rollout.Status.Conditions, nbv1alpha1.ConditionComplete) {
+		return ctrl.Result{}, nil
+	}
+	now := time.Now()
+	if rollout.Spec.StartTime != nil && now.Before(rollout.Spec.StartTime.Time) {
+		log.Info("Waiting for the start time of the rollout", "startTime", rollout.Spec.StartTime)
+		return ctrl.Result{RequeueAfter: rollout.Spec.StartTime.Sub(now)}, nil
+	}
+
+	notebooks, err := r.rolloutNotebooks(ctx, rollout)
+	if err != nil {
+		log.Error(err, "Unable to list the notebooks of the rollout")
+		return ctrl.Result{}, err
+	}
+
+	states := make([]rolloutNotebookState, len(notebooks))
+	inProgress := 0
+	for i := range notebooks {
+		states[i], err = r.rolloutNotebookState(ctx, rollout, &notebooks[i])
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if states[i] == rolloutInProgress {
+			inProgress++
+		}
+	}
+
+	// Restart the pending notebooks within the unavailability budget, or all
+	// of them once the deadline is passed
+	budget := int(rollout.Spec.MaxUnavailable)
+	if budget < 1 {
+		budget = 1
+	}
+	pastDeadline := rollout.Spec.Deadline != nil && !now.Before(rollout.Spec.Deadline.Time)
+	for i := range notebooks {
+		if states[i] != rolloutPending || (!pastDeadline && inProgress >= budget) {
+			continue
+		}
+		restarted, err := r.restartNotebook(ctx, rollout, &notebooks[i], now)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if restarted {
+			states[i] = rolloutInProgress
+			inProgress++
+		}
+	}
+
+	status := nbv1alpha1.NotebookRolloutStatus{
+		Total:      int32(len(notebooks)),
+		Conditions: rollout.Status.Conditions,
+	}
+	for _, state := range states {
+		switch state {
+		case rolloutPending:
+			status.Pending++
+		case rolloutInProgress:
+			status.InProgress++
+		case rolloutRestarted:
+			status.Restarted++
+		case rolloutSkipped:
+			status.Skipped++
+		}
+	}
+	complete := status.Pending == 0 && status.InProgress == 0
+	condition := metav1.Condition{
+		Type:               nbv1alpha1.ConditionComplete,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: rollout.Generation,
+		Reason:             "Progressing",
+		Message:            fmt.Sprintf("%d of %d notebooks restarted", status.Restarted, status.Total),
+	}
+	if complete {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "AllNotebooksRestarted"
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	rollout.Status = status
+	if err := r.Status().Update(ctx, rollout); err != nil {
+		log.Error(err, "Unable to update the NotebookRollout status")
+		return ctrl.Result{}, err
+	}
+	if complete {
+		log.Info("Rollout complete", "restarted", status.Restarted, "skipped", status.Skipped)
+		r.Recorder.Eventf(rollout, corev1.EventTypeNormal, "RolloutComplete", condition.Message)
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: rolloutRequeueInterval}, nil
+}
+
+// rolloutNotebooks lists the notebooks selected by the rollout, sorted by
+// namespace and name.
+func (r *NotebookRolloutReconciler) rolloutNotebooks(ctx context.Context,
+	rollout *nbv1alpha1.NotebookRollout) ([]nbv1.Notebook, error) {
+	selector := labels.Everything()
+	if rollout.Spec.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(rollout.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+	notebookList := &nbv1.NotebookList{}
+	if err := r.List(ctx, notebookList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	notebooks := notebookList.Items
+
+	if rollout.Spec.NamespaceSelector != nil {
+		namespaceSelector, err := metav1.LabelSelectorAsSelector(rollout.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		namespaceList := &corev1.NamespaceList{}
+		err = r.List(ctx, namespaceList, client.MatchingLabelsSelector{Selector: namespaceSelector})
+		if err != nil {
+			return nil, err
+		}
+		namespaces := map[string]bool{}
+		for _, namespace := range namespaceList.Items {
+			namespaces[namespace.Name] = true
+		}
+		notebooks = notebooks[:0]
+		for _, notebook := range notebookList.Items {
+			if namespaces[notebook.Namespace] {
+				notebooks = append(notebooks, notebook)
+			}
+		}
+	}
+
+	sort.Slice(notebooks, func(i, j int) bool {
+		if notebooks[i].Namespace != notebooks[j].Namespace {
+			return notebooks[i].Namespace < notebooks[j].Namespace
+		}
+		return notebooks[i].Name < notebooks[j].Name
+	})
+	return notebooks, nil
+}
+
+// rolloutNotebookState returns the progress of the rollout for the notebook.
+// The restart of a notebook is done once the Kubeflow notebook controller
+// consumed the restart annotation and the new pod is ready.
+func (r *NotebookRolloutReconciler) rolloutNotebookState(ctx context.Context,
+	rollout *nbv1alpha1.NotebookRollout, notebook *nbv1.Notebook) (rolloutNotebookState, error) {
+	annotations := notebook.GetAnnotations()
+	if annotations[AnnotationRollout] != string(rollout.UID) {
+		if metav1.HasAnnotation(notebook.ObjectMeta, culler.STOP_ANNOTATION) &&
+			annotations[culler.STOP_ANNOTATION] != AnnotationValueReconciliationLock {
+			return rolloutSkipped, nil
+		}
+		return rolloutPending, nil
+	}
+	if metav1.HasAnnotation(notebook.ObjectMeta, AnnotationNotebookRestart) {
+		return rolloutInProgress, nil
+	}
+	// The notebook was stopped while restarting
+	if metav1.HasAnnotation(notebook.ObjectMeta, culler.STOP_ANNOTATION) {
+		return rolloutRestarted, nil
+	}
+
+	restartTime, err := time.Parse(time.RFC3339, annotations[AnnotationRolloutRestartTime])
+	if err != nil {
+		return rolloutInProgress, nil
+	}
+	pod := &corev1.Pod{}
+	err = r.Get(ctx, types.NamespacedName{Name: notebook.Name + "-0", Namespace: notebook.Namespace}, pod)
+	if apierrs.IsNotFound(err) {
+		return rolloutInProgress, nil
+	} else if err != nil {
+		return rolloutInProgress, err
+	}
+	if pod.CreationTimestamp.Time.Before(restartTime) || !podReady(pod) {
+		return rolloutInProgress, nil
+	}
+	return rolloutRestarted, nil
+}
+
+// podReady returns true if the pod is ready.
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// restartNotebook restarts the notebook according to the restart policy, and
+// records the restart on behalf of the rollout. Returns false while the
+// confirmation of the restart by the users is awaited, past the deadline of
+// the rollout excepted.
+func (r *NotebookRolloutReconciler) restartNotebook(ctx context.Context, rollout *nbv1alpha1.NotebookRollout,
+	notebook *nbv1.Notebook, now time.Time) (bool, error) {
+	log := r.Log.WithValues("notebookrollout", rollout.Name, "notebook", client.ObjectKeyFromObject(notebook))
+	pastDeadline := rollout.Spec.Deadline != nil && !now.Before(rollout.Spec.Deadline.Time)
+	if !pastDeadline && !r.RestartPolicy.RestartConfirmed(notebook, now) {
+		requested, err := r.RestartPolicy.RequestRestartConfirmation(ctx, r.Client, notebook, now)
+		if err != nil {
+			log.Error(err, "Unable to request the confirmation of the restart")
+			return false, err
+		}
+		if requested {
+			log.Info("Waiting for the confirmation of the restart of the notebook")
+			r.Recorder.Eventf(notebook, corev1.EventTypeNormal, "RestartRequested",
+				"The NotebookRollout %s restarts the notebook once the %s annotation is set to true, or in %s",
+				rollout.Name, AnnotationRestartAcknowledged, r.RestartPolicy.ConfirmationTimeout)
+		}
+		return false, nil
+	}
+
+	restartTime := now.UTC().Truncate(time.Second).Format(time.RFC3339)
+	err := r.RestartPolicy.Restart(ctx, r.Client, notebook, map[string]string{
+		AnnotationRollout:            string(rollout.UID),
+		AnnotationRolloutRestartTime: restartTime,
+	})
+	if err != nil {
+		log.Error(err, "Unable to restart the notebook")
+		return false, err
+	}
+	log.Info("Restarting the notebook")
+	r.Recorder.Eventf(notebook, corev1.EventTypeNormal, "RolloutRestart",
+		"Restarted by the NotebookRollout %s", rollout.Name)
+	return true, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NotebookRolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nbv1alpha1.NotebookRollout{}).
+		Complete(r)
+}
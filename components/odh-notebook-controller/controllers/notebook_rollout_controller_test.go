@@ -0,0 +1,184 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	nbv1alpha1 "github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/api/v1alpha1"
+)
+
+func newTestRolloutReconciler(t *testing.T, objects ...client.Object) *NotebookRolloutReconciler {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, nbv1.AddToScheme(scheme))
+	require.NoError(t, nbv1alpha1.AddToScheme(scheme))
+	return &NotebookRolloutReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+			WithStatusSubresource(&nbv1alpha1.NotebookRollout{}).Build(),
+		Scheme:   scheme,
+		Log:      logr.Discard(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
+
+func newTestRolloutNotebook(namespace, name string, annotations map[string]string) *nbv1.Notebook {
+	return &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name: name, Namespace: namespace, Labels: map[string]string{"app": "jupyter"}, Annotations: annotations,
+	}}
+}
+
+func TestReconcileNotebookRollout(t *testing.T) {
+	ctx := context.Background()
+	rollout := &nbv1alpha1.NotebookRollout{
+		ObjectMeta: metav1.ObjectMeta{Name: "cve", UID: "rollout-uid"},
+		Spec: nbv1alpha1.NotebookRolloutSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "jupyter"}},
+			MaxUnavailable: 1,
+		},
+	}
+	r := newTestRolloutReconciler(t, rollout,
+		newTestRolloutNotebook("ns", "a", nil),
+		newTestRolloutNotebook("ns", "b", nil),
+		newTestRolloutNotebook("ns", "stopped", map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"}),
+		&nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"}},
+	)
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rollout)}
+	getNotebook := func(name string) *nbv1.Notebook {
+		notebook := &nbv1.Notebook{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: name}, notebook))
+		return notebook
+	}
+	getStatus := func() nbv1alpha1.NotebookRolloutStatus {
+		require.NoError(t, r.Get(ctx, request.NamespacedName, rollout))
+		return rollout.Status
+	}
+	// Restart the notebook as the Kubeflow notebook controller would
+	restarted := func(name string) {
+		notebook := getNotebook(name)
+		delete(notebook.Annotations, AnnotationNotebookRestart)
+		require.NoError(t, r.Update(ctx, notebook))
+		require.NoError(t, r.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-0", Namespace: "ns",
+				CreationTimestamp: metav1.NewTime(time.Now().Add(time.Minute))},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			}},
+		}))
+	}
+
+	// One notebook restarts at a time
+	result, err := r.Reconcile(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, rolloutRequeueInterval, result.RequeueAfter)
+	assert.Equal(t, "true", getNotebook("a").Annotations[AnnotationNotebookRestart])
+	assert.Equal(t, "rollout-uid", getNotebook("a").Annotations[AnnotationRollout])
+	assert.NotContains(t, getNotebook("b").Annotations, AnnotationNotebookRestart)
+	assert.NotContains(t, getNotebook("other").Annotations, AnnotationNotebookRestart)
+	status := getStatus()
+	assert.Equal(t, int32(3), status.Total)
+	assert.Equal(t, int32(1), status.InProgress)
+	assert.Equal(t, int32(1), status.Pending)
+	assert.Equal(t, int32(1), status.Skipped)
+
+	// The next notebook restarts once the previous one is ready
+	_, err = r.Reconcile(ctx, request)
+	require.NoError(t, err)
+	assert.NotContains(t, getNotebook("b").Annotations, AnnotationNotebookRestart)
+	restarted("a")
+	_, err = r.Reconcile(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, "true", getNotebook("b").Annotations[AnnotationNotebookRestart])
+	assert.Equal(t, int32(1), getStatus().Restarted)
+
+	// The rollout completes once all the notebooks are restarted
+	restarted("b")
+	result, err = r.Reconcile(ctx, request)
+	require.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	status = getStatus()
+	assert.Equal(t, int32(2), status.Restarted)
+	assert.True(t, meta.IsStatusConditionTrue(status.Conditions, nbv1alpha1.ConditionComplete))
+}
+
+func TestReconcileNotebookRolloutSchedule(t *testing.T) {
+	ctx := context.Background()
+	newRollout := func(startTime, deadline time.Time) *nbv1alpha1.NotebookRollout {
+		return &nbv1alpha1.NotebookRollout{
+			ObjectMeta: metav1.ObjectMeta{Name: "cve", UID: "rollout-uid"},
+			Spec: nbv1alpha1.NotebookRolloutSpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "data"}},
+				MaxUnavailable:    1,
+				StartTime:         &metav1.Time{Time: startTime},
+				Deadline:          &metav1.Time{Time: deadline},
+			},
+		}
+	}
+	objects := func(rollout *nbv1alpha1.NotebookRollout) []client.Object {
+		return []client.Object{rollout,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "data", Labels: map[string]string{"team": "data"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+			newTestRolloutNotebook("data", "a", nil),
+			newTestRolloutNotebook("data", "b", nil),
+			newTestRolloutNotebook("web", "c", nil),
+		}
+	}
+	restarting := func(t *testing.T, r *NotebookRolloutReconciler) []string {
+		notebooks := &nbv1.NotebookList{}
+		require.NoError(t, r.List(ctx, notebooks))
+		names := []string{}
+		for _, notebook := range notebooks.Items {
+			if metav1.HasAnnotation(notebook.ObjectMeta, AnnotationNotebookRestart) {
+				names = append(names, notebook.Name)
+			}
+		}
+		return names
+	}
+
+	t.Run("before the start time", func(t *testing.T) {
+		rollout := newRollout(time.Now().Add(time.Hour), time.Now().Add(2*time.Hour))
+		r := newTestRolloutReconciler(t, objects(rollout)...)
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rollout)})
+		require.NoError(t, err)
+		assert.Greater(t, result.RequeueAfter, 59*time.Minute)
+		assert.Empty(t, restarting(t, r))
+	})
+
+	t.Run("after the deadline", func(t *testing.T) {
+		rollout := newRollout(time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+		r := newTestRolloutReconciler(t, objects(rollout)...)
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rollout)})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a", "b"}, restarting(t, r))
+	})
+}
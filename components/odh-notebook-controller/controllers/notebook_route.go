@@ -18,9 +18,11 @@ package controllers
 import (
 	"context"
 	"reflect"
+	"strconv"
 
 	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
 	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -30,15 +32,27 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
+// AnnotationExpose set to false keeps the notebook cluster internal: no Route
+// is created, the notebook is only reached through its Service (e.g. with a
+// port-forward), which is still protected by the OAuth proxy.
+const AnnotationExpose = "notebooks.opendatahub.io/expose"
+
+// ExposureIsEnabled returns false if the notebook opted out of its Route.
+func ExposureIsEnabled(meta metav1.ObjectMeta) bool {
+	if meta.Annotations[AnnotationExpose] != "" {
+		result, err := strconv.ParseBool(meta.Annotations[AnnotationExpose])
+		return err != nil || result
+	}
+	return true
+}
+
 // NewNotebookRoute defines the desired route object
 func NewNotebookRoute(notebook *nbv1.Notebook) *routev1.Route {
 	return &routev1.Route{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      notebook.Name,
 			Namespace: notebook.Namespace,
-			Labels: map[string]string{
-				"notebook-name": notebook.Name,
-			},
+			Labels:    NotebookObjectLabels(notebook, ComponentRoute),
 		},
 		Spec: routev1.RouteSpec{
 			To: routev1.RouteTargetReference{
@@ -63,11 +77,17 @@ func NewNotebookRoute(notebook *nbv1.Notebook) *routev1.Route {
 
 // CompareNotebookRoutes checks if two routes are equal, if not return false
 func CompareNotebookRoutes(r1 routev1.Route, r2 routev1.Route) bool {
-	// Omit the host field since it is reconciled by the ingress controller
-	r1.Spec.Host, r2.Spec.Host = "", ""
+	// Omit the host field since it is reconciled by the ingress controller,
+	// unless a custom hostname is requested
+	if r1.Spec.Host == "" {
+		r1.Spec.Host, r2.Spec.Host = "", ""
+	}
 
-	// Two routes will be equal if the labels and spec are identical
+	// Two routes will be equal if the labels, external-dns annotations and
+	// spec are identical
 	return reflect.DeepEqual(r1.ObjectMeta.Labels, r2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(routeExternalDNSAnnotations(&r1), routeExternalDNSAnnotations(&r2)) &&
+		reflect.DeepEqual(routeTimeoutAnnotations(&r1), routeTimeoutAnnotations(&r2)) &&
 		reflect.DeepEqual(r1.Spec, r2.Spec)
 }
 
@@ -76,18 +96,61 @@ func CompareNotebookRoutes(r1 routev1.Route, r2 routev1.Route) bool {
 func (r *OpenshiftNotebookReconciler) reconcileRoute(notebook *nbv1.Notebook,
 	ctx context.Context, newRoute func(*nbv1.Notebook) *routev1.Route) error {
 	// Initialize logger format
-	log := r.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+	log := r.notebookLogger(notebook)
 
-	// Generate the desired route
+	// Generate the desired route, published on the router shard of the
+	// notebook
 	desiredRoute := newRoute(notebook)
 
+	// Delete the route of the notebooks which are not exposed, exposed
+	// through a Service, or unprotected and restricted by the cluster policy
+	if !r.ExposureConfig.RouteIsEnabled(notebook) || r.RouteConfig.exposureIsRestricted(notebook) {
+		return r.deleteControlledObject(ctx, notebook, desiredRoute.Name, &routev1.Route{})
+	}
+
+	err := r.RouteConfig.applyRouterShard(notebook, desiredRoute)
+	if err != nil {
+		log.Error(err, "Unable to select the router shard of the Route")
+		return err
+	}
+	r.RouteConfig.applyExternalDNS(notebook, desiredRoute)
+	applySessionKeepalive(notebook, desiredRoute)
+	r.StartupPageConfig.applyStartupPage(notebook, desiredRoute)
+
 	// Create the route if it does not already exist
 	foundRoute := &routev1.Route{}
 	justCreated := false
-	err := r.Get(ctx, types.NamespacedName{
+	err = r.Get(ctx, types.NamespacedName{
 		Name:      desiredRoute.Name,
 		Namespace: notebook.Namespace,
 	}, foundRoute)
+	if err == nil && foundRoute.Labels[LabelRouterShard] != desiredRoute.Labels[LabelRouterShard] {
+		// The host of the route is generated from the domain of the router
+		// admitting it, recreate the route to move it to another shard
+		log.Info("Moving Route to another router shard", "from", foundRoute.Labels[LabelRouterShard],
+			"to", desiredRoute.Labels[LabelRouterShard])
+		err = r.Delete(ctx, foundRoute)
+		if err != nil && !apierrs.IsNotFound(err) {
+			log.Error(err, "Unable to delete the Route")
+			return err
+		}
+		r.recordEvent(notebook, corev1.EventTypeNormal, "RouteShardChanged",
+			"Route %s moved to the router shard %q", foundRoute.Name, desiredRoute.Labels[LabelRouterShard])
+		err = apierrs.NewNotFound(routev1.Resource("routes"), foundRoute.Name)
+	}
+	if err == nil && foundRoute.Annotations[ExternalDNSHostnameAnnotation] != "" &&
+		desiredRoute.Annotations[ExternalDNSHostnameAnnotation] == "" {
+		// The generated host is only restored on the route creation,
+		// recreate the route to drop the custom hostname
+		log.Info("Removing the custom hostname of the Route",
+			"hostname", foundRoute.Annotations[ExternalDNSHostnameAnnotation])
+		err = r.Delete(ctx, foundRoute)
+		if err != nil && !apierrs.IsNotFound(err) {
+			log.Error(err, "Unable to delete the Route")
+			return err
+		}
+		err = apierrs.NewNotFound(routev1.Resource("routes"), foundRoute.Name)
+	}
 	if err != nil {
 		if apierrs.IsNotFound(err) {
 			log.Info("Creating Route")
@@ -98,6 +161,12 @@ func (r *OpenshiftNotebookReconciler) reconcileRoute(notebook *nbv1.Notebook,
 				log.Error(err, "Unable to add OwnerReference to the Route")
 				return err
 			}
+			// Check the custom hostname is still free
+			err = r.checkRouteHost(ctx, notebook, desiredRoute)
+			if err != nil {
+				log.Error(err, "Unable to create the Route")
+				return err
+			}
 			// Create the route in the Openshift cluster
 			err = r.Create(ctx, desiredRoute)
 			if err != nil && !apierrs.IsAlreadyExists(err) {
@@ -113,7 +182,15 @@ func (r *OpenshiftNotebookReconciler) reconcileRoute(notebook *nbv1.Notebook,
 
 	// Reconcile the route spec if it has been manually modified
 	if !justCreated && !CompareNotebookRoutes(*desiredRoute, *foundRoute) {
+		if desiredRoute.Spec.Host != foundRoute.Spec.Host {
+			err = r.checkRouteHost(ctx, notebook, desiredRoute)
+			if err != nil {
+				log.Error(err, "Unable to reconcile the Route")
+				return err
+			}
+		}
 		log.Info("Reconciling Route")
+		r.reportFieldManagerConflicts(notebook, foundRoute)
 		// Retry the update operation when the ingress controller eventually
 		// updates the resource version field
 		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -124,9 +201,12 @@ func (r *OpenshiftNotebookReconciler) reconcileRoute(notebook *nbv1.Notebook,
 			}, foundRoute); err != nil {
 				return err
 			}
-			// Reconcile labels and spec field
+			// Reconcile labels, external-dns and timeout annotations and
+			// spec field
 			foundRoute.Spec = desiredRoute.Spec
 			foundRoute.ObjectMeta.Labels = desiredRoute.ObjectMeta.Labels
+			setRouteExternalDNSAnnotations(foundRoute, routeExternalDNSAnnotations(desiredRoute))
+			setRouteTimeoutAnnotations(foundRoute, routeTimeoutAnnotations(desiredRoute))
 			return r.Update(ctx, foundRoute)
 		})
 		if err != nil {
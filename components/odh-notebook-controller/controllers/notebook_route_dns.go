@@ -0,0 +1,109 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// AnnotationExternalDNSHostname sets a custom hostname on the notebook
+	// route, registered in the DNS by external-dns.
+	AnnotationExternalDNSHostname = "notebooks.opendatahub.io/external-dns-hostname"
+	// AnnotationExternalDNSTTL sets the TTL in seconds of the DNS records of
+	// the custom hostname.
+	AnnotationExternalDNSTTL = "notebooks.opendatahub.io/external-dns-ttl"
+
+	// ExternalDNSHostnameAnnotation and ExternalDNSTTLAnnotation are the
+	// route annotations watched by external-dns.
+	ExternalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+	ExternalDNSTTLAnnotation      = "external-dns.alpha.kubernetes.io/ttl"
+)
+
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes/custom-host,verbs=create;update
+
+// ValidateExternalDNSAnnotations checks the custom hostname and the TTL of the
+// notebook.
+func ValidateExternalDNSAnnotations(notebook *nbv1.Notebook) error {
+	annotations := notebook.GetAnnotations()
+	if hostname, ok := annotations[AnnotationExternalDNSHostname]; ok {
+		if errs := validation.IsDNS1123Subdomain(hostname); len(errs) > 0 {
+			return fmt.Errorf("invalid %s annotation %q: %s", AnnotationExternalDNSHostname, hostname,
+				strings.Join(errs, ", "))
+		}
+	}
+	if ttl, ok := annotations[AnnotationExternalDNSTTL]; ok {
+		if _, ok := annotations[AnnotationExternalDNSHostname]; !ok {
+			return fmt.Errorf("the %s annotation requires the %s annotation", AnnotationExternalDNSTTL,
+				AnnotationExternalDNSHostname)
+		}
+		if seconds, err := strconv.Atoi(ttl); err != nil || seconds <= 0 {
+			return fmt.Errorf("invalid %s annotation %q, must be a positive number of seconds",
+				AnnotationExternalDNSTTL, ttl)
+		}
+	}
+	return nil
+}
+
+// applyExternalDNS publishes the route on the custom hostname of the notebook
+// and annotates it for external-dns.
+func (c RouteConfig) applyExternalDNS(notebook *nbv1.Notebook, route *routev1.Route) {
+	hostname := notebook.GetAnnotations()[AnnotationExternalDNSHostname]
+	if !c.ExternalDNS || hostname == "" {
+		return
+	}
+	route.Spec.Host = hostname
+	if route.Annotations == nil {
+		route.Annotations = map[string]string{}
+	}
+	route.Annotations[ExternalDNSHostnameAnnotation] = hostname
+	if ttl, ok := notebook.GetAnnotations()[AnnotationExternalDNSTTL]; ok {
+		route.Annotations[ExternalDNSTTLAnnotation] = ttl
+	}
+}
+
+// routeExternalDNSAnnotations returns the external-dns annotations of the
+// route.
+func routeExternalDNSAnnotations(route *routev1.Route) map[string]string {
+	annotations := map[string]string{}
+	for _, key := range []string{ExternalDNSHostnameAnnotation, ExternalDNSTTLAnnotation} {
+		if value, ok := route.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+// setRouteExternalDNSAnnotations replaces the external-dns annotations of the
+// route, keeping the other ones.
+func setRouteExternalDNSAnnotations(route *routev1.Route, annotations map[string]string) {
+	for _, key := range []string{ExternalDNSHostnameAnnotation, ExternalDNSTTLAnnotation} {
+		if value, ok := annotations[key]; ok {
+			if route.Annotations == nil {
+				route.Annotations = map[string]string{}
+			}
+			route.Annotations[key] = value
+		} else {
+			delete(route.Annotations, key)
+		}
+	}
+}
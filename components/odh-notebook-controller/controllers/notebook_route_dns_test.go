@@ -0,0 +1,92 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestValidateExternalDNSAnnotations(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		annotations map[string]string
+		invalid     bool
+	}{
+		{name: "no hostname"},
+		{name: "hostname", annotations: map[string]string{AnnotationExternalDNSHostname: "nb.example.com"}},
+		{name: "hostname and ttl", annotations: map[string]string{
+			AnnotationExternalDNSHostname: "nb.example.com", AnnotationExternalDNSTTL: "300"}},
+		{name: "invalid hostname", annotations: map[string]string{AnnotationExternalDNSHostname: "NB_example"},
+			invalid: true},
+		{name: "ttl without hostname", annotations: map[string]string{AnnotationExternalDNSTTL: "300"},
+			invalid: true},
+		{name: "invalid ttl", annotations: map[string]string{
+			AnnotationExternalDNSHostname: "nb.example.com", AnnotationExternalDNSTTL: "5m"}, invalid: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			err := ValidateExternalDNSAnnotations(notebook)
+			if tt.invalid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReconcileRouteExternalDNS(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationExternalDNSHostname: "nb.example.com"}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	routeKey := client.ObjectKey{Namespace: "ns", Name: "nb"}
+
+	// The custom hostname is ignored while external-dns is disabled
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	route := &routev1.Route{}
+	require.NoError(t, r.Get(ctx, routeKey, route))
+	assert.Empty(t, route.Spec.Host)
+	assert.Empty(t, route.Annotations)
+
+	r.RouteConfig.ExternalDNS = true
+	notebook.Annotations[AnnotationExternalDNSTTL] = "300"
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	route = &routev1.Route{}
+	require.NoError(t, r.Get(ctx, routeKey, route))
+	assert.Equal(t, "nb.example.com", route.Spec.Host)
+	assert.Equal(t, map[string]string{
+		ExternalDNSHostnameAnnotation: "nb.example.com",
+		ExternalDNSTTLAnnotation:      "300",
+	}, route.Annotations)
+
+	// The route is recreated without custom hostname, to get a generated host
+	delete(notebook.Annotations, AnnotationExternalDNSHostname)
+	delete(notebook.Annotations, AnnotationExternalDNSTTL)
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	route = &routev1.Route{}
+	require.NoError(t, r.Get(ctx, routeKey, route))
+	assert.Empty(t, route.Spec.Host)
+	assert.Empty(t, route.Annotations)
+}
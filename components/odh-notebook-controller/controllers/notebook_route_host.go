@@ -0,0 +1,170 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RouteHostIndex is the field index of the Routes on their host, to find the
+// Routes conflicting with a custom hostname from the cache.
+const RouteHostIndex = "spec.host"
+
+// maxRouteHostSuffix is the largest suffix tried to resolve a conflicting
+// custom hostname.
+const maxRouteHostSuffix = 10
+
+// RouteHostConflictPolicy is the handling of the custom hostnames already used
+// by another Route, which the router would not admit.
+type RouteHostConflictPolicy string
+
+const (
+	// RouteHostConflictReject denies the admission of the notebook.
+	RouteHostConflictReject RouteHostConflictPolicy = "reject"
+	// RouteHostConflictSuffix suffixes the first label of the hostname with
+	// the first free number, e.g. nb-2.example.com.
+	RouteHostConflictSuffix RouteHostConflictPolicy = "suffix"
+)
+
+// ParseRouteHostConflictPolicy parses the handling of the conflicting custom
+// hostnames.
+func ParseRouteHostConflictPolicy(value string) (RouteHostConflictPolicy, error) {
+	switch policy := RouteHostConflictPolicy(value); policy {
+	case RouteHostConflictReject, RouteHostConflictSuffix:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid route host conflict policy %q, must be one of [%s, %s]", value,
+		RouteHostConflictReject, RouteHostConflictSuffix)
+}
+
+// IndexRouteHosts registers the field index of the Routes on their host.
+func IndexRouteHosts(ctx context.Context, indexer client.FieldIndexer) error {
+	return indexer.IndexField(ctx, &routev1.Route{}, RouteHostIndex, routeHost)
+}
+
+// routeHost returns the host the Route is indexed on.
+func routeHost(obj client.Object) []string {
+	route, ok := obj.(*routev1.Route)
+	if !ok || route.Spec.Host == "" {
+		return nil
+	}
+	return []string{route.Spec.Host}
+}
+
+// ConflictingRoute returns the Route of any namespace using the given host,
+// other than the Route of the notebook, or nil if the host is free.
+func ConflictingRoute(ctx context.Context, reader client.Reader, host string,
+	notebook *nbv1.Notebook) (*routev1.Route, error) {
+	routes := &routev1.RouteList{}
+	err := reader.List(ctx, routes, client.MatchingFields{RouteHostIndex: host})
+	if err != nil {
+		return nil, err
+	}
+	for i := range routes.Items {
+		route := &routes.Items[i]
+		if route.Namespace == notebook.Namespace && route.Name == notebook.Name {
+			continue
+		}
+		if route.DeletionTimestamp.IsZero() {
+			return route, nil
+		}
+	}
+	return nil, nil
+}
+
+// suffixedHost suffixes the first label of the host with the given number.
+func suffixedHost(host string, suffix int) string {
+	label, domain, _ := strings.Cut(host, ".")
+	number := "-" + strconv.Itoa(suffix)
+	if len(label)+len(number) > validation.DNS1123LabelMaxLength {
+		label = strings.TrimRight(label[:validation.DNS1123LabelMaxLength-len(number)], "-")
+	}
+	if domain == "" {
+		return label + number
+	}
+	return label + number + "." + domain
+}
+
+// resolveRouteHost checks the custom hostname of the notebook when it is set
+// or changed, against the Routes of the cluster. The conflicting hostnames are
+// denied or, with the suffix policy, replaced by the first free suffixed
+// hostname, reported with the returned warning. A RouteHostConflictError is
+// returned when the hostname is denied.
+func (w *NotebookWebhook) resolveRouteHost(ctx context.Context, notebook, oldNotebook *nbv1.Notebook) (string, error) {
+	host := notebook.GetAnnotations()[AnnotationExternalDNSHostname]
+	if !w.RouteConfig.ExternalDNS || host == "" ||
+		(oldNotebook != nil && oldNotebook.GetAnnotations()[AnnotationExternalDNSHostname] == host) {
+		return "", nil
+	}
+	conflict, err := ConflictingRoute(ctx, w.Client, host, notebook)
+	if err != nil || conflict == nil {
+		return "", err
+	}
+	message := fmt.Sprintf("the hostname %s of the %s annotation is already used by the Route %s/%s",
+		host, AnnotationExternalDNSHostname, conflict.Namespace, conflict.Name)
+	if w.RouteConfig.HostConflictPolicy != RouteHostConflictSuffix {
+		return "", &RouteHostConflictError{Message: message}
+	}
+	for suffix := 2; suffix <= maxRouteHostSuffix; suffix++ {
+		candidate := suffixedHost(host, suffix)
+		conflict, err := ConflictingRoute(ctx, w.Client, candidate, notebook)
+		if err != nil {
+			return "", err
+		}
+		if conflict == nil {
+			notebook.Annotations[AnnotationExternalDNSHostname] = candidate
+			return fmt.Sprintf("%s, the hostname %s is used instead", message, candidate), nil
+		}
+	}
+	return "", &RouteHostConflictError{Message: message + ", and no free suffixed hostname was found"}
+}
+
+// RouteHostConflictError is returned when the custom hostname of the notebook
+// is already used by another Route.
+type RouteHostConflictError struct {
+	Message string
+}
+
+func (e *RouteHostConflictError) Error() string {
+	return e.Message
+}
+
+// checkRouteHost checks the custom hostname of the route of the notebook is
+// not used by another Route before creating or updating it, the notebooks
+// admitted at the same time could have requested the same hostname.
+func (r *OpenshiftNotebookReconciler) checkRouteHost(ctx context.Context, notebook *nbv1.Notebook,
+	route *routev1.Route) error {
+	if route.Spec.Host == "" {
+		return nil
+	}
+	conflict, err := ConflictingRoute(ctx, r.Client, route.Spec.Host, notebook)
+	if err != nil || conflict == nil {
+		return err
+	}
+	r.recordEvent(notebook, corev1.EventTypeWarning, "RouteHostConflict",
+		"The hostname %s is already used by the Route %s/%s", route.Spec.Host, conflict.Namespace, conflict.Name)
+	return fmt.Errorf("the hostname %s is already used by the Route %s/%s", route.Spec.Host, conflict.Namespace,
+		conflict.Name)
+}
@@ -0,0 +1,96 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newHostRoute(namespace, name, host string) *routev1.Route {
+	return &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       routev1.RouteSpec{Host: host},
+	}
+}
+
+func TestSuffixedHost(t *testing.T) {
+	assert.Equal(t, "nb-2.example.com", suffixedHost("nb.example.com", 2))
+	assert.Equal(t, "nb-10", suffixedHost("nb", 10))
+	long := strings.Repeat("a", 63) + ".example.com"
+	assert.Equal(t, strings.Repeat("a", 61)+"-2.example.com", suffixedHost(long, 2))
+}
+
+func TestResolveRouteHost(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t, OAuthConfig{},
+		newHostRoute("other", "app", "nb.example.com"),
+		newHostRoute("other", "app-2", "nb-2.example.com"),
+		newHostRoute("ns", "nb", "own.example.com"))
+	w := &NotebookWebhook{Client: r.Client, RouteConfig: RouteConfig{ExternalDNS: true}}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+		Annotations: map[string]string{AnnotationExternalDNSHostname: "nb.example.com"}}}
+
+	// The hostnames used by another Route are denied
+	_, err := w.resolveRouteHost(ctx, notebook, nil)
+	var hostErr *RouteHostConflictError
+	require.ErrorAs(t, err, &hostErr)
+	assert.Contains(t, hostErr.Error(), "other/app")
+
+	// The unchanged hostnames are not checked again
+	warning, err := w.resolveRouteHost(ctx, notebook, notebook.DeepCopy())
+	require.NoError(t, err)
+	assert.Empty(t, warning)
+
+	// The hostname of the Route of the notebook is not a conflict
+	notebook.Annotations[AnnotationExternalDNSHostname] = "own.example.com"
+	warning, err = w.resolveRouteHost(ctx, notebook, nil)
+	require.NoError(t, err)
+	assert.Empty(t, warning)
+
+	// The conflicting hostnames are suffixed with the first free number
+	w.RouteConfig.HostConflictPolicy = RouteHostConflictSuffix
+	notebook.Annotations[AnnotationExternalDNSHostname] = "nb.example.com"
+	warning, err = w.resolveRouteHost(ctx, notebook, nil)
+	require.NoError(t, err)
+	assert.Contains(t, warning, "nb-3.example.com")
+	assert.Equal(t, "nb-3.example.com", notebook.Annotations[AnnotationExternalDNSHostname])
+}
+
+func TestReconcileRouteHostConflict(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationExternalDNSHostname: "nb.example.com"}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook, newHostRoute("other", "app", "nb.example.com"))
+	r.RouteConfig.ExternalDNS = true
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	// The Route is not created while its hostname is used
+	assert.Error(t, r.ReconcileRoute(notebook, ctx))
+	assert.Error(t, r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "nb"}, &routev1.Route{}))
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "RouteHostConflict")
+}
@@ -0,0 +1,121 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// AnnotationRouterShard selects the router shard (IngressController)
+	// publishing the notebook route, e.g. to publish sensitive notebooks on
+	// the internal router only.
+	AnnotationRouterShard = "notebooks.opendatahub.io/router-shard"
+	// LabelRouterShard records the router shard of the route.
+	LabelRouterShard = "notebooks.opendatahub.io/router-shard"
+)
+
+// RouteConfig holds the router shards the notebook routes can be published
+// on. The IngressControllers select their routes with a route selector, the
+// labels of the selected shard are set on the notebook routes.
+type RouteConfig struct {
+	// Shards maps the shard names to the route labels they select.
+	Shards map[string]map[string]string
+	// DefaultShard is the shard of the notebooks without router shard
+	// annotation. The routes are left to the default router if empty.
+	DefaultShard string
+	// ExternalDNS publishes the routes on the custom hostnames of the
+	// notebooks, annotated for external-dns.
+	ExternalDNS bool
+	// HostConflictPolicy is the handling of the custom hostnames already
+	// used by another Route.
+	HostConflictPolicy RouteHostConflictPolicy
+	// UnprotectedPolicy is the handling of the notebooks exposed without
+	// authentication, allowed if empty.
+	UnprotectedPolicy UnprotectedRoutePolicy
+}
+
+// ParseRouteConfig parses the JSON object mapping the shard names to their
+// route labels, and checks the default shard.
+func ParseRouteConfig(shards, defaultShard string) (RouteConfig, error) {
+	config := RouteConfig{Shards: map[string]map[string]string{}, DefaultShard: defaultShard}
+	if strings.TrimSpace(shards) != "" {
+		if err := json.Unmarshal([]byte(shards), &config.Shards); err != nil {
+			return config, fmt.Errorf("invalid router shards: %w", err)
+		}
+	}
+	for name, labels := range config.Shards {
+		for key, value := range labels {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				return config, fmt.Errorf("invalid label key %q of router shard %s: %s", key, name,
+					strings.Join(errs, ", "))
+			}
+			if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+				return config, fmt.Errorf("invalid label value %q of router shard %s: %s", value, name,
+					strings.Join(errs, ", "))
+			}
+		}
+	}
+	if defaultShard != "" {
+		if _, ok := config.Shards[defaultShard]; !ok {
+			return config, fmt.Errorf("unknown default router shard %q", defaultShard)
+		}
+	}
+	return config, nil
+}
+
+// RouterShard returns the router shard of the notebook, or an error if the
+// notebook selects an unknown shard.
+func (c RouteConfig) RouterShard(notebook *nbv1.Notebook) (string, error) {
+	shard, ok := notebook.GetAnnotations()[AnnotationRouterShard]
+	if !ok {
+		return c.DefaultShard, nil
+	}
+	if _, ok := c.Shards[shard]; !ok {
+		names := []string{}
+		for name := range c.Shards {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown router shard %q in the %s annotation, must be one of [%s]",
+			shard, AnnotationRouterShard, strings.Join(names, ", "))
+	}
+	return shard, nil
+}
+
+// applyRouterShard sets the labels of the router shard of the notebook on the
+// route.
+func (c RouteConfig) applyRouterShard(notebook *nbv1.Notebook, route *routev1.Route) error {
+	shard, err := c.RouterShard(notebook)
+	if err != nil || shard == "" {
+		return err
+	}
+	if route.Labels == nil {
+		route.Labels = map[string]string{}
+	}
+	for key, value := range c.Shards[shard] {
+		route.Labels[key] = value
+	}
+	route.Labels[LabelRouterShard] = shard
+	return nil
+}
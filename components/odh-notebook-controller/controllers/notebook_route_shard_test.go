@@ -0,0 +1,102 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestParseRouteConfig(t *testing.T) {
+	config, err := ParseRouteConfig(`{"internal":{"router":"internal"},"external":{"router":"external"}}`, "external")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"router": "internal"}, config.Shards["internal"])
+
+	config, err = ParseRouteConfig("", "")
+	require.NoError(t, err)
+	assert.Empty(t, config.Shards)
+
+	_, err = ParseRouteConfig(`{"internal":{"router":"internal"}}`, "external")
+	assert.Error(t, err, "unknown default shard")
+	_, err = ParseRouteConfig(`{"internal":{"router":"in ternal"}}`, "")
+	assert.Error(t, err, "invalid label value")
+	_, err = ParseRouteConfig(`["internal"]`, "")
+	assert.Error(t, err, "invalid JSON")
+}
+
+func TestRouterShard(t *testing.T) {
+	config := RouteConfig{Shards: map[string]map[string]string{
+		"internal": {"router": "internal"},
+		"external": {"router": "external"},
+	}, DefaultShard: "external"}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+
+	shard, err := config.RouterShard(notebook)
+	require.NoError(t, err)
+	assert.Equal(t, "external", shard)
+
+	notebook.Annotations = map[string]string{AnnotationRouterShard: "internal"}
+	shard, err = config.RouterShard(notebook)
+	require.NoError(t, err)
+	assert.Equal(t, "internal", shard)
+
+	notebook.Annotations[AnnotationRouterShard] = "dmz"
+	_, err = config.RouterShard(notebook)
+	assert.ErrorContains(t, err, "[external, internal]")
+}
+
+func TestReconcileRouteShard(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	r.RouteConfig = RouteConfig{Shards: map[string]map[string]string{
+		"internal": {"router": "internal"},
+		"external": {"router": "external"},
+	}, DefaultShard: "external"}
+	routeKey := client.ObjectKey{Namespace: "ns", Name: "nb"}
+
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	route := &routev1.Route{}
+	require.NoError(t, r.Get(ctx, routeKey, route))
+	assert.Equal(t, "external", route.Labels["router"])
+	assert.Equal(t, "external", route.Labels[LabelRouterShard])
+	route.Spec.Host = "nb-ns.apps.example.com"
+	require.NoError(t, r.Update(ctx, route))
+
+	// The route is recreated on the internal shard, to get a new host
+	notebook.Annotations = map[string]string{AnnotationRouterShard: "internal"}
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	route = &routev1.Route{}
+	require.NoError(t, r.Get(ctx, routeKey, route))
+	assert.Equal(t, "internal", route.Labels["router"])
+	assert.Empty(t, route.Spec.Host)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "RouteShardChanged")
+
+	// Reconciling again is a no-op
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	assert.Empty(t, recorder.Events)
+}
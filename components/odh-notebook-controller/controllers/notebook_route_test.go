@@ -0,0 +1,59 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestExposureIsEnabled(t *testing.T) {
+	assert.True(t, ExposureIsEnabled(metav1.ObjectMeta{}))
+	assert.True(t, ExposureIsEnabled(metav1.ObjectMeta{Annotations: map[string]string{AnnotationExpose: "true"}}))
+	assert.True(t, ExposureIsEnabled(metav1.ObjectMeta{Annotations: map[string]string{AnnotationExpose: "internal"}}))
+	assert.False(t, ExposureIsEnabled(metav1.ObjectMeta{Annotations: map[string]string{AnnotationExpose: "false"}}))
+}
+
+func TestReconcileRouteExposure(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	routeKey := client.ObjectKey{Namespace: "ns", Name: "nb"}
+
+	require.NoError(t, r.ReconcileOAuthRoute(notebook, ctx))
+	require.NoError(t, r.Get(ctx, routeKey, &routev1.Route{}))
+
+	// The route is deleted once the notebook is no longer exposed
+	notebook.Annotations = map[string]string{AnnotationExpose: "false"}
+	require.NoError(t, r.ReconcileOAuthRoute(notebook, ctx))
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, routeKey, &routev1.Route{})))
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, routeKey, &routev1.Route{})))
+
+	// The routes which are not controlled by the notebook are kept
+	userRoute := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	require.NoError(t, r.Create(ctx, userRoute))
+	require.NoError(t, r.ReconcileOAuthRoute(notebook, ctx))
+	assert.NoError(t, r.Get(ctx, routeKey, &routev1.Route{}))
+}
@@ -0,0 +1,81 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UnprotectedRoutePolicy is the handling of the notebooks exposed without
+// authentication, i.e. without the OAuth proxy nor the service mesh.
+type UnprotectedRoutePolicy string
+
+const (
+	// UnprotectedRouteAllow exposes the unprotected notebooks like the other
+	// notebooks.
+	UnprotectedRouteAllow UnprotectedRoutePolicy = "allow"
+	// UnprotectedRouteDeny denies the admission of the unprotected notebooks
+	// which are exposed, and does not expose the existing ones.
+	UnprotectedRouteDeny UnprotectedRoutePolicy = "deny"
+	// UnprotectedRouteInternalOnly keeps the unprotected notebooks cluster
+	// internal, as with the expose annotation set to false.
+	UnprotectedRouteInternalOnly UnprotectedRoutePolicy = "internal-only"
+)
+
+// ParseUnprotectedRoutePolicy parses the handling of the unprotected
+// notebooks.
+func ParseUnprotectedRoutePolicy(value string) (UnprotectedRoutePolicy, error) {
+	switch policy := UnprotectedRoutePolicy(value); policy {
+	case UnprotectedRouteAllow, UnprotectedRouteDeny, UnprotectedRouteInternalOnly:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid unprotected route policy %q, must be one of [%s, %s, %s]", value,
+		UnprotectedRouteAllow, UnprotectedRouteDeny, UnprotectedRouteInternalOnly)
+}
+
+// NotebookIsUnprotected returns true if the notebook opted out of both the
+// OAuth proxy and the service mesh, its Route is not authenticated.
+func NotebookIsUnprotected(meta metav1.ObjectMeta) bool {
+	return !OAuthInjectionIsEnabled(meta) && !ServiceMeshIsEnabled(meta)
+}
+
+// exposureIsRestricted returns true if the notebook must not be exposed
+// outside of the cluster because it is unprotected.
+func (c RouteConfig) exposureIsRestricted(notebook *nbv1.Notebook) bool {
+	return c.UnprotectedPolicy != "" && c.UnprotectedPolicy != UnprotectedRouteAllow &&
+		NotebookIsUnprotected(notebook.ObjectMeta)
+}
+
+// ValidateUnprotectedExposure denies the exposed unprotected notebooks when
+// the policy denies them. Only the notebooks created unprotected, or which
+// become unprotected or exposed, are denied, so that the existing notebooks
+// can still be updated, e.g. stopped. The old notebook is nil on creation.
+func (c RouteConfig) ValidateUnprotectedExposure(notebook, oldNotebook *nbv1.Notebook) error {
+	if c.UnprotectedPolicy != UnprotectedRouteDeny || !NotebookIsUnprotected(notebook.ObjectMeta) ||
+		!ExposureIsEnabled(notebook.ObjectMeta) {
+		return nil
+	}
+	if oldNotebook != nil && NotebookIsUnprotected(oldNotebook.ObjectMeta) &&
+		ExposureIsEnabled(oldNotebook.ObjectMeta) {
+		return nil
+	}
+	return fmt.Errorf("the notebooks exposed without authentication are not allowed by the cluster policy: "+
+		"set the %s or %s annotation to true, or the %s annotation to false",
+		AnnotationInjectOAuth, AnnotationServiceMesh, AnnotationExpose)
+}
@@ -0,0 +1,80 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestParseUnprotectedRoutePolicy(t *testing.T) {
+	policy, err := ParseUnprotectedRoutePolicy("internal-only")
+	require.NoError(t, err)
+	assert.Equal(t, UnprotectedRouteInternalOnly, policy)
+	_, err = ParseUnprotectedRoutePolicy("public")
+	assert.Error(t, err)
+}
+
+func TestValidateUnprotectedExposure(t *testing.T) {
+	newNotebook := func(annotations map[string]string) *nbv1.Notebook {
+		return &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", Annotations: annotations}}
+	}
+	unprotected := newNotebook(nil)
+	protected := newNotebook(map[string]string{AnnotationInjectOAuth: "true"})
+	internal := newNotebook(map[string]string{AnnotationExpose: "false"})
+	config := RouteConfig{UnprotectedPolicy: UnprotectedRouteDeny}
+
+	assert.Error(t, config.ValidateUnprotectedExposure(unprotected, nil))
+	assert.Error(t, config.ValidateUnprotectedExposure(unprotected, protected))
+	assert.Error(t, config.ValidateUnprotectedExposure(unprotected, internal))
+	assert.NoError(t, config.ValidateUnprotectedExposure(protected, nil))
+	assert.NoError(t, config.ValidateUnprotectedExposure(internal, nil))
+	mesh := newNotebook(map[string]string{AnnotationServiceMesh: "true"})
+	assert.NoError(t, config.ValidateUnprotectedExposure(mesh, nil))
+
+	// The existing unprotected notebooks can still be updated
+	assert.NoError(t, config.ValidateUnprotectedExposure(unprotected, unprotected.DeepCopy()))
+
+	for _, policy := range []UnprotectedRoutePolicy{"", UnprotectedRouteAllow, UnprotectedRouteInternalOnly} {
+		config.UnprotectedPolicy = policy
+		assert.NoError(t, config.ValidateUnprotectedExposure(unprotected, nil), policy)
+	}
+}
+
+func TestReconcileRouteUnprotected(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid"}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	routeKey := client.ObjectKey{Namespace: "ns", Name: "nb"}
+
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	require.NoError(t, r.Get(ctx, routeKey, &routev1.Route{}))
+
+	// The Route of the unprotected notebook is deleted by the internal-only
+	// policy
+	r.RouteConfig.UnprotectedPolicy = UnprotectedRouteInternalOnly
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	err := r.Get(ctx, routeKey, &routev1.Route{})
+	assert.True(t, apierrs.IsNotFound(err))
+}
@@ -0,0 +1,227 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AnnotationSCC requests the use of a SecurityContextConstraint configured by
+// the administrators, e.g. for the workbenches mounting datasets with FUSE.
+const AnnotationSCC = "notebooks.opendatahub.io/scc"
+
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get;list;watch
+
+// SCCPolicy describes a SecurityContextConstraint the notebooks may request.
+type SCCPolicy struct {
+	// ClusterRole grants the use of the SCC, "system:openshift:scc:<name>"
+	// by default. The controller must be allowed to bind it.
+	ClusterRole string `json:"clusterRole,omitempty"`
+	// NamespaceSelector selects the namespaces allowed to request the SCC,
+	// no namespace is allowed if unset.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector"`
+	// SecurityContext is injected in the notebook container.
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// SCCConfig holds the SecurityContextConstraints the notebooks may request,
+// by SCC name.
+type SCCConfig struct {
+	Policies map[string]SCCPolicy
+}
+
+// ParseSCCConfig parses the JSON object mapping the SCC names to their policy.
+func ParseSCCConfig(policies string) (SCCConfig, error) {
+	config := SCCConfig{Policies: map[string]SCCPolicy{}}
+	if strings.TrimSpace(policies) == "" {
+		return config, nil
+	}
+	if err := json.Unmarshal([]byte(policies), &config.Policies); err != nil {
+		return config, fmt.Errorf("invalid SCC policies: %w", err)
+	}
+	for name, policy := range config.Policies {
+		if policy.NamespaceSelector == nil {
+			return config, fmt.Errorf("the SCC policy %s has no namespace selector", name)
+		}
+		if _, err := metav1.LabelSelectorAsSelector(policy.NamespaceSelector); err != nil {
+			return config, fmt.Errorf("invalid namespace selector of the SCC policy %s: %w", name, err)
+		}
+		if policy.ClusterRole == "" {
+			policy.ClusterRole = "system:openshift:scc:" + name
+			config.Policies[name] = policy
+		}
+	}
+	return config, nil
+}
+
+// SCCPolicy returns the policy of the SCC requested by the notebook, nil if
+// none is requested, or an error if the SCC cannot be requested from the
+// namespace.
+func (c SCCConfig) SCCPolicy(notebook *nbv1.Notebook, namespace *corev1.Namespace) (*SCCPolicy, error) {
+	name, ok := notebook.GetAnnotations()[AnnotationSCC]
+	if !ok {
+		return nil, nil
+	}
+	policy, ok := c.Policies[name]
+	if !ok {
+		names := []string{}
+		for name := range c.Policies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown SCC %q in the %s annotation, must be one of [%s]",
+			name, AnnotationSCC, strings.Join(names, ", "))
+	}
+	selector, err := metav1.LabelSelectorAsSelector(policy.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	if !selector.Matches(labels.Set(namespace.Labels)) {
+		return nil, fmt.Errorf("the SCC %q cannot be requested from the namespace %s", name, namespace.Name)
+	}
+	return &policy, nil
+}
+
+// InjectSCCSecurityContext sets the security context of the requested SCC on
+// the notebook container, and removes it once the SCC is no longer
+// requested.
+func (c SCCConfig) InjectSCCSecurityContext(notebook *nbv1.Notebook, policy *SCCPolicy) {
+	for i, container := range notebook.Spec.Template.Spec.Containers {
+		if container.Name != PrimaryContainerName(notebook) {
+			continue
+		}
+		if policy != nil {
+			if policy.SecurityContext != nil {
+				notebook.Spec.Template.Spec.Containers[i].SecurityContext = policy.SecurityContext.DeepCopy()
+			}
+			return
+		}
+		for _, other := range c.Policies {
+			if other.SecurityContext != nil && reflect.DeepEqual(container.SecurityContext, other.SecurityContext) {
+				notebook.Spec.Template.Spec.Containers[i].SecurityContext = nil
+				return
+			}
+		}
+	}
+}
+
+// sccRoleBindingName returns the name of the RoleBinding granting the
+// notebook the use of its SCC.
+func sccRoleBindingName(notebook *nbv1.Notebook) string {
+	return notebook.Name + "-scc"
+}
+
+// notebookServiceAccountName returns the service account running the
+// notebook pod.
+func notebookServiceAccountName(notebook *nbv1.Notebook) string {
+	if name := notebook.Spec.Template.Spec.ServiceAccountName; name != "" {
+		return name
+	}
+	return "default"
+}
+
+// ReconcileSCCRoleBinding manages the RoleBinding granting the notebook
+// service account the use of the requested SCC, and deletes it once the SCC
+// is no longer requested or allowed.
+func (r *OpenshiftNotebookReconciler) ReconcileSCCRoleBinding(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	var policy *SCCPolicy
+	if metav1.HasAnnotation(notebook.ObjectMeta, AnnotationSCC) {
+		namespace := &corev1.Namespace{}
+		err := r.Get(ctx, types.NamespacedName{Name: notebook.Namespace}, namespace)
+		if err != nil {
+			log.Error(err, "Unable to fetch the Namespace")
+			return err
+		}
+		policy, err = r.SCCConfig.SCCPolicy(notebook, namespace)
+		if err != nil {
+			log.Error(err, "The SCC cannot be granted to the notebook")
+			r.recordEvent(notebook, corev1.EventTypeWarning, "SCCDenied", err.Error())
+			policy = nil
+		}
+	}
+
+	roleBindingName := sccRoleBindingName(notebook)
+	if policy == nil {
+		err := r.deleteControlledObject(ctx, notebook, roleBindingName, &rbacv1.RoleBinding{})
+		if err != nil {
+			log.Error(err, "Unable to delete the SCC RoleBinding")
+		}
+		return err
+	}
+
+	roleBinding := NewRoleBinding(notebook, notebookServiceAccountName(notebook), roleBindingName,
+		"ClusterRole", policy.ClusterRole)
+	found := &rbacv1.RoleBinding{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(roleBinding), found)
+	if err == nil && found.RoleRef != roleBinding.RoleRef {
+		// The role of a RoleBinding is immutable
+		log.Info("Deleting RoleBinding", "RoleBinding.Name", found.Name, "RoleRef", found.RoleRef.Name)
+		err = r.Delete(ctx, found)
+		if err != nil && !apierrs.IsNotFound(err) {
+			log.Error(err, "Failed to delete RoleBinding", "RoleBinding.Name", found.Name)
+			return err
+		}
+		err = apierrs.NewNotFound(rbacv1.Resource("rolebindings"), found.Name)
+	}
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating RoleBinding", "RoleBinding.Name", roleBinding.Name, "RoleRef", policy.ClusterRole)
+		err = ctrl.SetControllerReference(notebook, roleBinding, r.Scheme)
+		if err != nil {
+			log.Error(err, "Failed to set controller reference for RoleBinding")
+			return err
+		}
+		err = r.Create(ctx, roleBinding)
+		if err != nil {
+			log.Error(err, "Failed to create RoleBinding", "RoleBinding.Name", roleBinding.Name)
+			return err
+		}
+		r.recordRoleBindingEvent(notebook, "RoleBindingCreated", roleBinding)
+		return nil
+	} else if err != nil {
+		log.Error(err, "Failed to get RoleBinding")
+		return err
+	}
+
+	if !reflect.DeepEqual(roleBinding.Subjects, found.Subjects) {
+		log.Info("Updating RoleBinding", "RoleBinding.Name", found.Name)
+		found.Subjects = roleBinding.Subjects
+		err = r.Update(ctx, found)
+		if err != nil {
+			log.Error(err, "Failed to update RoleBinding", "RoleBinding.Name", found.Name)
+			return err
+		}
+		r.recordRoleBindingEvent(notebook, "RoleBindingUpdated", found)
+	}
+	return nil
+}
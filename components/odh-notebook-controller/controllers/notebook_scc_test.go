@@ -0,0 +1,135 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testSCCPolicies = `{"fuse":{"namespaceSelector":{"matchLabels":{"fuse":"true"}},` +
+	`"securityContext":{"capabilities":{"add":["SYS_ADMIN"]}}}}`
+
+func TestParseSCCConfig(t *testing.T) {
+	config, err := ParseSCCConfig("")
+	require.NoError(t, err)
+	assert.Empty(t, config.Policies)
+
+	config, err = ParseSCCConfig(testSCCPolicies)
+	require.NoError(t, err)
+	assert.Equal(t, "system:openshift:scc:fuse", config.Policies["fuse"].ClusterRole)
+
+	_, err = ParseSCCConfig(`{"fuse":{}}`)
+	assert.ErrorContains(t, err, "no namespace selector")
+
+	_, err = ParseSCCConfig(`{"fuse":`)
+	assert.Error(t, err)
+}
+
+func TestSCCPolicy(t *testing.T) {
+	config, err := ParseSCCConfig(testSCCPolicies)
+	require.NoError(t, err)
+	allowed := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Labels: map[string]string{"fuse": "true"}}}
+	denied := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+	policy, err := config.SCCPolicy(notebook, allowed)
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	notebook.Annotations = map[string]string{AnnotationSCC: "fuse"}
+	policy, err = config.SCCPolicy(notebook, allowed)
+	require.NoError(t, err)
+	assert.NotNil(t, policy)
+
+	_, err = config.SCCPolicy(notebook, denied)
+	assert.ErrorContains(t, err, "cannot be requested from the namespace other")
+
+	notebook.Annotations[AnnotationSCC] = "privileged"
+	_, err = config.SCCPolicy(notebook, allowed)
+	assert.ErrorContains(t, err, "[fuse]")
+}
+
+func TestInjectSCCSecurityContext(t *testing.T) {
+	config, err := ParseSCCConfig(testSCCPolicies)
+	require.NoError(t, err)
+	policy := config.Policies["fuse"]
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb"},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb"}, {Name: "sidecar"}},
+		}}},
+	}
+
+	config.InjectSCCSecurityContext(notebook, &policy)
+	assert.Equal(t, policy.SecurityContext, notebook.Spec.Template.Spec.Containers[0].SecurityContext)
+	assert.Nil(t, notebook.Spec.Template.Spec.Containers[1].SecurityContext)
+
+	// The injected security context is removed once the SCC is not requested
+	config.InjectSCCSecurityContext(notebook, nil)
+	assert.Nil(t, notebook.Spec.Template.Spec.Containers[0].SecurityContext)
+
+	// The security context set by the user is kept
+	userContext := &corev1.SecurityContext{RunAsNonRoot: pointer.Bool(true)}
+	notebook.Spec.Template.Spec.Containers[0].SecurityContext = userContext
+	config.InjectSCCSecurityContext(notebook, nil)
+	assert.Equal(t, userContext, notebook.Spec.Template.Spec.Containers[0].SecurityContext)
+}
+
+func TestReconcileSCCRoleBinding(t *testing.T) {
+	ctx := context.Background()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Labels: map[string]string{"fuse": "true"}}}
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+			Annotations: map[string]string{AnnotationSCC: "fuse"}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			ServiceAccountName: "nb",
+		}}},
+	}
+	r := newTestReconciler(t, OAuthConfig{}, namespace, notebook)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	var err error
+	r.SCCConfig, err = ParseSCCConfig(testSCCPolicies)
+	require.NoError(t, err)
+	roleBindingKey := client.ObjectKey{Namespace: "ns", Name: "nb-scc"}
+
+	require.NoError(t, r.ReconcileSCCRoleBinding(notebook, ctx))
+	roleBinding := &rbacv1.RoleBinding{}
+	require.NoError(t, r.Get(ctx, roleBindingKey, roleBinding))
+	assert.Equal(t, "system:openshift:scc:fuse", roleBinding.RoleRef.Name)
+	assert.Equal(t, "nb", roleBinding.Subjects[0].Name)
+	assert.Contains(t, <-recorder.Events, "RoleBindingCreated")
+
+	// The RoleBinding is deleted once the namespace is no longer allowed
+	namespace.Labels = nil
+	require.NoError(t, r.Update(ctx, namespace))
+	require.NoError(t, r.ReconcileSCCRoleBinding(notebook, ctx))
+	assert.Contains(t, <-recorder.Events, "SCCDenied")
+	err = r.Get(ctx, roleBindingKey, &rbacv1.RoleBinding{})
+	assert.True(t, apierrs.IsNotFound(err))
+}
@@ -0,0 +1,121 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// SchedulingConfig holds the cluster level scheduling defaults injected into
+// the notebook pods, so that simultaneously spawned workbenches are spread
+// across zones and nodes.
+type SchedulingConfig struct {
+	// TopologySpreadKeys are the node label keys used as topology domains,
+	// e.g. topology.kubernetes.io/zone. No constraint is injected if empty.
+	TopologySpreadKeys []string
+	// TopologySpreadMaxSkew is the maxSkew of the injected constraints.
+	TopologySpreadMaxSkew int32
+	// TopologySpreadWhenUnsatisfiable is the whenUnsatisfiable policy of the
+	// injected constraints.
+	TopologySpreadWhenUnsatisfiable corev1.UnsatisfiableConstraintAction
+	// AntiAffinityWeight is the weight of the preferred anti-affinity between
+	// notebook pods on the same node. No anti-affinity is injected if zero.
+	AntiAffinityWeight int32
+}
+
+// Validate checks the scheduling defaults, which would otherwise break the
+// creation of the pods of every notebook.
+func (c SchedulingConfig) Validate() error {
+	for _, key := range c.TopologySpreadKeys {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid topology spread key %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	if c.TopologySpreadMaxSkew < 1 {
+		return fmt.Errorf("invalid topology spread max skew %d, must be at least 1", c.TopologySpreadMaxSkew)
+	}
+	switch c.TopologySpreadWhenUnsatisfiable {
+	case corev1.DoNotSchedule, corev1.ScheduleAnyway:
+	default:
+		return fmt.Errorf("invalid topology spread whenUnsatisfiable %q, must be %s or %s",
+			c.TopologySpreadWhenUnsatisfiable, corev1.DoNotSchedule, corev1.ScheduleAnyway)
+	}
+	if c.AntiAffinityWeight < 0 || c.AntiAffinityWeight > 100 {
+		return fmt.Errorf("invalid pod anti-affinity weight %d, must be between 1 and 100, or 0 to disable it",
+			c.AntiAffinityWeight)
+	}
+	return nil
+}
+
+// notebookPodsSelector selects the pods of all the notebooks.
+func notebookPodsSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "notebook-name",
+			Operator: metav1.LabelSelectorOpExists,
+		}},
+	}
+}
+
+// InjectSchedulingDefaults injects the default topology spread constraints
+// and pod anti-affinity in the notebook pod spec. The scheduling settings
+// defined in the notebook take precedence over the defaults.
+func InjectSchedulingDefaults(notebook *nbv1.Notebook, config SchedulingConfig) {
+	podSpec := &notebook.Spec.Template.Spec
+
+	if len(podSpec.TopologySpreadConstraints) == 0 && len(config.TopologySpreadKeys) > 0 {
+		whenUnsatisfiable := config.TopologySpreadWhenUnsatisfiable
+		if whenUnsatisfiable == "" {
+			whenUnsatisfiable = corev1.ScheduleAnyway
+		}
+		maxSkew := config.TopologySpreadMaxSkew
+		if maxSkew < 1 {
+			maxSkew = 1
+		}
+		for _, key := range config.TopologySpreadKeys {
+			podSpec.TopologySpreadConstraints = append(podSpec.TopologySpreadConstraints,
+				corev1.TopologySpreadConstraint{
+					MaxSkew:           maxSkew,
+					TopologyKey:       key,
+					WhenUnsatisfiable: whenUnsatisfiable,
+					LabelSelector:     notebookPodsSelector(),
+				})
+		}
+	}
+
+	if config.AntiAffinityWeight > 0 {
+		if podSpec.Affinity == nil {
+			podSpec.Affinity = &corev1.Affinity{}
+		}
+		if podSpec.Affinity.PodAntiAffinity == nil {
+			podSpec.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+					Weight: config.AntiAffinityWeight,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: notebookPodsSelector(),
+						TopologyKey:   corev1.LabelHostname,
+					},
+				}},
+			}
+		}
+	}
+}
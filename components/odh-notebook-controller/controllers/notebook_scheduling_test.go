@@ -0,0 +1,90 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSchedulingConfigValidate(t *testing.T) {
+	valid := SchedulingConfig{
+		TopologySpreadKeys:              []string{corev1.LabelTopologyZone},
+		TopologySpreadMaxSkew:           1,
+		TopologySpreadWhenUnsatisfiable: corev1.ScheduleAnyway,
+		AntiAffinityWeight:              50,
+	}
+	assert.NoError(t, valid.Validate())
+
+	for name, mutate := range map[string]func(*SchedulingConfig){
+		"invalid key":               func(c *SchedulingConfig) { c.TopologySpreadKeys = []string{"not a key"} },
+		"invalid max skew":          func(c *SchedulingConfig) { c.TopologySpreadMaxSkew = 0 },
+		"invalid whenUnsatisfiable": func(c *SchedulingConfig) { c.TopologySpreadWhenUnsatisfiable = "ScheduleAnyWay" },
+		"weight too high":           func(c *SchedulingConfig) { c.AntiAffinityWeight = 101 },
+		"negative weight":           func(c *SchedulingConfig) { c.AntiAffinityWeight = -1 },
+	} {
+		config := valid
+		mutate(&config)
+		assert.Error(t, config.Validate(), name)
+	}
+}
+
+func TestInjectSchedulingDefaults(t *testing.T) {
+	config := SchedulingConfig{
+		TopologySpreadKeys:              []string{corev1.LabelTopologyZone, corev1.LabelHostname},
+		TopologySpreadMaxSkew:           2,
+		TopologySpreadWhenUnsatisfiable: corev1.DoNotSchedule,
+		AntiAffinityWeight:              50,
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		notebook := &nbv1.Notebook{}
+		InjectSchedulingDefaults(notebook, config)
+		podSpec := notebook.Spec.Template.Spec
+		assert.Len(t, podSpec.TopologySpreadConstraints, 2)
+		assert.Equal(t, corev1.LabelTopologyZone, podSpec.TopologySpreadConstraints[0].TopologyKey)
+		assert.Equal(t, int32(2), podSpec.TopologySpreadConstraints[0].MaxSkew)
+		assert.Equal(t, corev1.DoNotSchedule, podSpec.TopologySpreadConstraints[0].WhenUnsatisfiable)
+		assert.Equal(t, int32(50),
+			podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].Weight)
+
+		// Injecting again is a no-op
+		injected := notebook.DeepCopy()
+		InjectSchedulingDefaults(notebook, config)
+		assert.Equal(t, injected, notebook)
+	})
+
+	t.Run("notebook settings take precedence", func(t *testing.T) {
+		notebook := &nbv1.Notebook{}
+		constraints := []corev1.TopologySpreadConstraint{{MaxSkew: 5, TopologyKey: "rack"}}
+		affinity := &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{}}
+		notebook.Spec.Template.Spec.TopologySpreadConstraints = constraints
+		notebook.Spec.Template.Spec.Affinity = affinity
+		InjectSchedulingDefaults(notebook, config)
+		assert.Equal(t, constraints, notebook.Spec.Template.Spec.TopologySpreadConstraints)
+		assert.Equal(t, affinity, notebook.Spec.Template.Spec.Affinity)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		notebook := &nbv1.Notebook{}
+		InjectSchedulingDefaults(notebook, SchedulingConfig{})
+		assert.Empty(t, notebook.Spec.Template.Spec.TopologySpreadConstraints)
+		assert.Nil(t, notebook.Spec.Template.Spec.Affinity)
+	})
+}
@@ -0,0 +1,157 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+)
+
+const (
+	// AnnotationSessionKeepalive is the time, e.g. 4h, the idle sessions of
+	// the notebook are kept alive. It sets the timeouts of the Route, the
+	// refresh interval of the session cookies and the idle timeout of the
+	// culler consistently, instead of tuning the three separately.
+	AnnotationSessionKeepalive = "notebooks.opendatahub.io/session-keepalive"
+
+	// RouteTimeoutAnnotation and RouteTunnelTimeoutAnnotation are the
+	// timeouts of the router for the HTTP requests and the websockets.
+	RouteTimeoutAnnotation       = "haproxy.router.openshift.io/timeout"
+	RouteTunnelTimeoutAnnotation = "haproxy.router.openshift.io/timeout-tunnel"
+)
+
+// routeTimeoutAnnotationKeys are the annotations of the Route set from the
+// session keepalive of the notebook, recorded along with the timeouts.
+var routeTimeoutAnnotationKeys = []string{AnnotationSessionKeepalive, RouteTimeoutAnnotation,
+	RouteTunnelTimeoutAnnotation}
+
+// NotebookSessionKeepalive returns the session keepalive of the notebook, zero
+// if it has none.
+func NotebookSessionKeepalive(notebook *nbv1.Notebook) (time.Duration, error) {
+	value, ok := notebook.GetAnnotations()[AnnotationSessionKeepalive]
+	if !ok {
+		return 0, nil
+	}
+	keepalive, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation: %w", AnnotationSessionKeepalive, err)
+	}
+	if keepalive < time.Minute {
+		return 0, fmt.Errorf("invalid %s annotation %q: the sessions must be kept alive at least 1m",
+			AnnotationSessionKeepalive, value)
+	}
+	return keepalive, nil
+}
+
+// sessionKeepaliveIdleTimeout returns the idle timeout of the culler, in
+// minutes, of the session keepalive.
+func sessionKeepaliveIdleTimeout(keepalive time.Duration) string {
+	return strconv.FormatInt(int64((keepalive+time.Minute-1)/time.Minute), 10)
+}
+
+// sessionKeepaliveCookieRefresh returns the refresh interval of the session
+// cookies of the session keepalive, so that the cookies are refreshed twice
+// within the keepalive and before they expire.
+func sessionKeepaliveCookieRefresh(keepalive, expire time.Duration) time.Duration {
+	if keepalive > expire {
+		keepalive = expire
+	}
+	return keepalive / 2
+}
+
+// InjectSessionKeepalive sets the idle timeout of the culler from the session
+// keepalive of the notebook, overriding the idle timeout of the users and of
+// the dashboard. The idle timeout is removed along with the keepalive. The old
+// notebook is nil on creation.
+func InjectSessionKeepalive(notebook, oldNotebook *nbv1.Notebook) error {
+	keepalive, err := NotebookSessionKeepalive(notebook)
+	if err != nil {
+		return err
+	}
+	if keepalive > 0 {
+		notebook.Annotations[AnnotationIdleTimeout] = sessionKeepaliveIdleTimeout(keepalive)
+		return nil
+	}
+
+	// Remove the idle timeout derived from the previous keepalive, unless
+	// changed along with the removal
+	if oldNotebook == nil {
+		return nil
+	}
+	oldKeepalive, err := NotebookSessionKeepalive(oldNotebook)
+	if err != nil || oldKeepalive == 0 {
+		return nil
+	}
+	if notebook.Annotations[AnnotationIdleTimeout] == sessionKeepaliveIdleTimeout(oldKeepalive) {
+		delete(notebook.Annotations, AnnotationIdleTimeout)
+	}
+	return nil
+}
+
+// applySessionKeepalive sets the timeouts of the route from the session
+// keepalive of the notebook. The invalid keepalives, denied by the webhook,
+// are ignored.
+func applySessionKeepalive(notebook *nbv1.Notebook, route *routev1.Route) {
+	keepalive, err := NotebookSessionKeepalive(notebook)
+	if err != nil || keepalive == 0 {
+		return
+	}
+	if route.Annotations == nil {
+		route.Annotations = map[string]string{}
+	}
+	timeout := fmt.Sprintf("%ds", int64(keepalive/time.Second))
+	route.Annotations[AnnotationSessionKeepalive] = notebook.Annotations[AnnotationSessionKeepalive]
+	route.Annotations[RouteTimeoutAnnotation] = timeout
+	route.Annotations[RouteTunnelTimeoutAnnotation] = timeout
+}
+
+// routeTimeoutAnnotations returns the timeout annotations of the route set
+// from a session keepalive, none if the timeouts were set otherwise.
+func routeTimeoutAnnotations(route *routev1.Route) map[string]string {
+	annotations := map[string]string{}
+	if _, ok := route.Annotations[AnnotationSessionKeepalive]; !ok {
+		return annotations
+	}
+	for _, key := range routeTimeoutAnnotationKeys {
+		if value, ok := route.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+// setRouteTimeoutAnnotations replaces the timeout annotations of the route
+// set from a session keepalive, keeping the timeouts set otherwise, e.g. by
+// the cluster administrators.
+func setRouteTimeoutAnnotations(route *routev1.Route, annotations map[string]string) {
+	if _, ok := route.Annotations[AnnotationSessionKeepalive]; !ok && len(annotations) == 0 {
+		return
+	}
+	for _, key := range routeTimeoutAnnotationKeys {
+		if value, ok := annotations[key]; ok {
+			if route.Annotations == nil {
+				route.Annotations = map[string]string{}
+			}
+			route.Annotations[key] = value
+		} else {
+			delete(route.Annotations, key)
+		}
+	}
+}
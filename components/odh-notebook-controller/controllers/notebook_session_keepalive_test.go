@@ -0,0 +1,83 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestInjectSessionKeepalive(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb",
+		Annotations: map[string]string{AnnotationSessionKeepalive: "4h", AnnotationIdleTimeout: "30"}}}
+
+	// The keepalive overrides the idle timeout and the cookie refresh
+	require.NoError(t, InjectSessionKeepalive(notebook, nil))
+	assert.Equal(t, "240", notebook.Annotations[AnnotationIdleTimeout])
+	expire, refresh, err := NotebookCookieSettings(notebook, OAuthConfig{CookieRefresh: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultOAuthCookieExpire, expire)
+	assert.Equal(t, 2*time.Hour, refresh)
+
+	// The idle timeout is removed along with the keepalive
+	oldNotebook := notebook.DeepCopy()
+	delete(notebook.Annotations, AnnotationSessionKeepalive)
+	require.NoError(t, InjectSessionKeepalive(notebook, oldNotebook))
+	assert.NotContains(t, notebook.Annotations, AnnotationIdleTimeout)
+
+	notebook.Annotations[AnnotationSessionKeepalive] = "30s"
+	assert.Error(t, InjectSessionKeepalive(notebook, nil))
+	notebook.Annotations[AnnotationSessionKeepalive] = "4 hours"
+	assert.Error(t, InjectSessionKeepalive(notebook, nil))
+}
+
+func TestReconcileRouteSessionKeepalive(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationSessionKeepalive: "1h30m"}}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	routeKey := client.ObjectKey{Namespace: "ns", Name: "nb"}
+
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	route := &routev1.Route{}
+	require.NoError(t, r.Get(ctx, routeKey, route))
+	assert.Equal(t, map[string]string{
+		AnnotationSessionKeepalive:   "1h30m",
+		RouteTimeoutAnnotation:       "5400s",
+		RouteTunnelTimeoutAnnotation: "5400s",
+	}, route.Annotations)
+
+	// The timeouts are removed along with the keepalive
+	delete(notebook.Annotations, AnnotationSessionKeepalive)
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	require.NoError(t, r.Get(ctx, routeKey, route))
+	assert.Empty(t, route.Annotations)
+
+	// The timeouts set otherwise are kept
+	route.Annotations = map[string]string{RouteTimeoutAnnotation: "300s"}
+	require.NoError(t, r.Update(ctx, route))
+	require.NoError(t, r.ReconcileRoute(notebook, ctx))
+	require.NoError(t, r.Get(ctx, routeKey, route))
+	assert.Equal(t, map[string]string{RouteTimeoutAnnotation: "300s"}, route.Annotations)
+}
@@ -0,0 +1,251 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// AnnotationSidecars lists the comma-separated names of the containers added
+// by the users next to the notebook container, e.g. Dask workers or database
+// proxies. They are never considered as the notebook container, and their
+// declared ports are reachable from the pods of the namespace through the
+// <notebook>-sidecars Service.
+const AnnotationSidecars = "notebooks.opendatahub.io/sidecars"
+
+// SidecarContainerNames returns the names of the sidecars declared by the
+// users.
+func SidecarContainerNames(notebook *nbv1.Notebook) []string {
+	names := []string{}
+	for _, name := range strings.Split(notebook.GetAnnotations()[AnnotationSidecars], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isSidecarContainer returns true if the container is injected next to the
+// notebook container, or declared as a sidecar by the users.
+func isSidecarContainer(notebook *nbv1.Notebook, name string) bool {
+	if sidecarContainerNames[name] {
+		return true
+	}
+	for _, sidecar := range SidecarContainerNames(notebook) {
+		if sidecar == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSidecarsAnnotation checks that the sidecars declared by the users
+// are containers of the notebook, other than its notebook container and the
+// sidecars injected by the controller.
+func ValidateSidecarsAnnotation(notebook *nbv1.Notebook) error {
+	primary := PrimaryContainerName(notebook)
+	for _, name := range SidecarContainerNames(notebook) {
+		if sidecarContainerNames[name] {
+			return fmt.Errorf("invalid %s annotation: the container %s is injected by the controller",
+				AnnotationSidecars, name)
+		}
+		if name == primary {
+			return fmt.Errorf("invalid %s annotation: the container %s is the notebook container",
+				AnnotationSidecars, name)
+		}
+		found := false
+		for _, container := range notebook.Spec.Template.Spec.Containers {
+			found = found || container.Name == name
+		}
+		if !found {
+			return fmt.Errorf("invalid %s annotation: the notebook has no container %q", AnnotationSidecars, name)
+		}
+	}
+	return nil
+}
+
+// sidecarServicePorts returns the Service ports of the ports declared by the
+// sidecars of the users.
+func sidecarServicePorts(notebook *nbv1.Notebook) []corev1.ServicePort {
+	ports := []corev1.ServicePort{}
+	names := map[string]bool{}
+	for _, sidecar := range SidecarContainerNames(notebook) {
+		for _, container := range notebook.Spec.Template.Spec.Containers {
+			if container.Name != sidecar {
+				continue
+			}
+			for _, port := range container.Ports {
+				protocol := port.Protocol
+				if protocol == "" {
+					protocol = corev1.ProtocolTCP
+				}
+				name := port.Name
+				if name == "" {
+					name = strings.ToLower(string(protocol)) + "-" + strconv.Itoa(int(port.ContainerPort))
+				}
+				if names[name] {
+					continue
+				}
+				names[name] = true
+				ports = append(ports, corev1.ServicePort{
+					Name:       name,
+					Port:       port.ContainerPort,
+					TargetPort: intstr.FromInt(int(port.ContainerPort)),
+					Protocol:   protocol,
+				})
+			}
+		}
+	}
+	return ports
+}
+
+// SidecarServiceName returns the name of the Service of the sidecars.
+func SidecarServiceName(notebook *nbv1.Notebook) string {
+	return notebook.Name + "-sidecars"
+}
+
+// NewSidecarService defines the Service reaching the ports of the sidecars
+// of the users, nil if they declare no port.
+func NewSidecarService(notebook *nbv1.Notebook) *corev1.Service {
+	ports := sidecarServicePorts(notebook)
+	if len(ports) == 0 {
+		return nil
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SidecarServiceName(notebook),
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentSidecars),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: ports,
+			Selector: map[string]string{
+				"statefulset": notebook.Name,
+			},
+		},
+	}
+}
+
+// NewSidecarNetworkPolicy defines the NetworkPolicy allowing the pods of the
+// namespace to reach the ports of the sidecars of the users, nil if they
+// declare no port.
+func NewSidecarNetworkPolicy(notebook *nbv1.Notebook) *netv1.NetworkPolicy {
+	ports := sidecarServicePorts(notebook)
+	if len(ports) == 0 {
+		return nil
+	}
+	policyPorts := []netv1.NetworkPolicyPort{}
+	for _, port := range ports {
+		protocol, target := port.Protocol, port.TargetPort
+		policyPorts = append(policyPorts, netv1.NetworkPolicyPort{Protocol: &protocol, Port: &target})
+	}
+	return &netv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      notebook.Name + "-sidecars-np",
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentNetworkPolicy),
+		},
+		Spec: netv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"notebook-name": notebook.Name,
+				},
+			},
+			Ingress: []netv1.NetworkPolicyIngressRule{{
+				Ports: policyPorts,
+				From:  []netv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+			}},
+			PolicyTypes: []netv1.PolicyType{
+				netv1.PolicyTypeIngress,
+			},
+		},
+	}
+}
+
+// ReconcileSidecars reconciles the NetworkPolicy and the Service of the
+// sidecars of the users, which are deleted once the sidecars declare no port.
+func (r *OpenshiftNotebookReconciler) ReconcileSidecars(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	desiredNetworkPolicy := NewSidecarNetworkPolicy(notebook)
+	if desiredNetworkPolicy == nil {
+		err := r.deleteControlledObject(ctx, notebook, notebook.Name+"-sidecars-np", &netv1.NetworkPolicy{})
+		if err != nil {
+			log.Error(err, "Unable to delete the sidecars network policy")
+			return err
+		}
+	} else if err := r.reconcileNetworkPolicy(desiredNetworkPolicy, ctx, notebook); err != nil {
+		log.Error(err, "error creating the sidecars network policy")
+		return err
+	}
+
+	desiredService := NewSidecarService(notebook)
+	if desiredService == nil {
+		return r.deleteControlledObject(ctx, notebook, SidecarServiceName(notebook), &corev1.Service{})
+	}
+	foundService := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Name: desiredService.Name, Namespace: notebook.Namespace}, foundService)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating the sidecars Service")
+		err = ctrl.SetControllerReference(notebook, desiredService, r.Scheme)
+		if err != nil {
+			return err
+		}
+		err = r.Create(ctx, desiredService)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the sidecars Service")
+			return err
+		}
+		return nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the sidecars Service")
+		return err
+	}
+	if !metav1.IsControlledBy(foundService, notebook) {
+		return NewUserActionableError("ServiceConflict",
+			fmt.Errorf("service %s already exists and is not controlled by the notebook", foundService.Name))
+	}
+
+	update := mergeLabels(foundService, desiredService.Labels)
+	if !reflect.DeepEqual(foundService.Spec.Ports, desiredService.Spec.Ports) {
+		foundService.Spec.Ports = desiredService.Spec.Ports
+		update = true
+	}
+	if update {
+		log.Info("Reconciling the sidecars Service")
+		r.reportFieldManagerConflicts(notebook, foundService)
+		err = r.Update(ctx, foundService)
+		if err != nil {
+			log.Error(err, "Unable to update the sidecars Service")
+			return err
+		}
+	}
+	return nil
+}
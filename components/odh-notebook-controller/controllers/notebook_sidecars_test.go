@@ -0,0 +1,93 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newSidecarNotebook(sidecars string) *nbv1.Notebook {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", UID: "nb-uid",
+		Annotations: map[string]string{AnnotationSidecars: sidecars}}}
+	notebook.Spec.Template.Spec.Containers = []corev1.Container{
+		{Name: "dask-worker", Ports: []corev1.ContainerPort{{Name: "dask", ContainerPort: 8786}}},
+		{Name: "workbench", Ports: []corev1.ContainerPort{{ContainerPort: 8888}}},
+		{Name: "db-proxy", Ports: []corev1.ContainerPort{{ContainerPort: 5432}}},
+	}
+	return notebook
+}
+
+func TestSidecarsPrimaryContainer(t *testing.T) {
+	// The declared sidecars are skipped to find the notebook container
+	notebook := newSidecarNotebook("dask-worker, db-proxy")
+	assert.Equal(t, []string{"dask-worker", "db-proxy"}, SidecarContainerNames(notebook))
+	assert.Equal(t, "workbench", PrimaryContainerName(notebook))
+	assert.NoError(t, ValidateSidecarsAnnotation(notebook))
+
+	notebook.Annotations[AnnotationSidecars] = "dask-worker,workbench"
+	assert.Equal(t, "db-proxy", PrimaryContainerName(notebook))
+
+	notebook.Annotations[AnnotationPrimaryContainer] = "workbench"
+	assert.Error(t, ValidateSidecarsAnnotation(notebook))
+	notebook.Annotations[AnnotationSidecars] = "redis"
+	assert.Error(t, ValidateSidecarsAnnotation(notebook))
+	notebook.Annotations[AnnotationSidecars] = OAuthProxyContainerName
+	assert.Error(t, ValidateSidecarsAnnotation(notebook))
+}
+
+func TestReconcileSidecars(t *testing.T) {
+	ctx := context.Background()
+	notebook := newSidecarNotebook("dask-worker,db-proxy")
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+
+	// The ports of the sidecars are exposed to the namespace
+	require.NoError(t, r.ReconcileSidecars(notebook, ctx))
+	service := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: "nb-sidecars", Namespace: "ns"}, service))
+	require.Len(t, service.Spec.Ports, 2)
+	assert.Equal(t, "dask", service.Spec.Ports[0].Name)
+	assert.Equal(t, "tcp-5432", service.Spec.Ports[1].Name)
+	assert.Equal(t, "nb", service.Spec.Selector["statefulset"])
+	assert.True(t, metav1.IsControlledBy(service, notebook))
+	networkPolicy := &netv1.NetworkPolicy{}
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: "nb-sidecars-np", Namespace: "ns"}, networkPolicy))
+	require.Len(t, networkPolicy.Spec.Ingress, 1)
+	assert.Len(t, networkPolicy.Spec.Ingress[0].Ports, 2)
+
+	// The ports of the sidecars are updated
+	notebook.Annotations[AnnotationSidecars] = "dask-worker"
+	require.NoError(t, r.ReconcileSidecars(notebook, ctx))
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: "nb-sidecars", Namespace: "ns"}, service))
+	assert.Len(t, service.Spec.Ports, 1)
+
+	// The objects are deleted with the annotation
+	delete(notebook.Annotations, AnnotationSidecars)
+	require.NoError(t, r.ReconcileSidecars(notebook, ctx))
+	err := r.Get(ctx, types.NamespacedName{Name: "nb-sidecars", Namespace: "ns"}, service)
+	assert.True(t, apierrs.IsNotFound(err))
+	err = r.Get(ctx, types.NamespacedName{Name: "nb-sidecars-np", Namespace: "ns"}, networkPolicy)
+	assert.True(t, apierrs.IsNotFound(err))
+}
@@ -0,0 +1,361 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationRecommendedSize is set by the controller to the name of the
+	// size recommended for the notebook, advisory only.
+	AnnotationRecommendedSize = "notebooks.opendatahub.io/recommended-size"
+	// ConditionSizeRecommended explains the recommended size of the notebook.
+	ConditionSizeRecommended = "notebooks.opendatahub.io/SizeRecommended"
+
+	// DefaultThrottlingThreshold is the ratio of the CPU periods of the
+	// notebook pod throttled over the window above which a larger size is
+	// recommended.
+	DefaultThrottlingThreshold = 0.25
+	// DefaultThrottlingWindow is the window the CPU throttling is observed
+	// over.
+	DefaultThrottlingWindow = time.Hour
+
+	// cpuThrottlingQuery computes the ratio of the throttled CPU periods of
+	// the pods over the window.
+	cpuThrottlingQuery = `sum by (namespace, pod) (increase(container_cpu_cfs_throttled_periods_total{container!=""}[%[1]s]))` +
+		` / sum by (namespace, pod) (increase(container_cpu_cfs_periods_total{container!=""}[%[1]s]))`
+
+	// prometheusTimeout bounds the Prometheus queries.
+	prometheusTimeout = 30 * time.Second
+
+	// serviceAccountTokenFile and serviceCAFile are the token of the
+	// controller service account and the service CA of the cluster.
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceCAFile           = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+)
+
+// NotebookSize is a size the users can pick for their notebooks, e.g. one of
+// the notebook sizes of the dashboard.
+type NotebookSize struct {
+	Name      string                      `json:"name"`
+	Resources corev1.ResourceRequirements `json:"resources"`
+}
+
+// ParseNotebookSizes parses the JSON array of the notebook sizes, and sorts
+// them from the smallest to the largest.
+func ParseNotebookSizes(value string) ([]NotebookSize, error) {
+	sizes := []NotebookSize{}
+	if strings.TrimSpace(value) == "" {
+		return sizes, nil
+	}
+	if err := json.Unmarshal([]byte(value), &sizes); err != nil {
+		return nil, fmt.Errorf("invalid notebook sizes: %w", err)
+	}
+	names := map[string]bool{}
+	for _, size := range sizes {
+		if size.Name == "" || names[size.Name] {
+			return nil, fmt.Errorf("invalid notebook size name %q, the names must be unique and not empty", size.Name)
+		}
+		names[size.Name] = true
+		if size.Resources.Limits.Memory().IsZero() || size.Resources.Limits.Cpu().IsZero() {
+			return nil, fmt.Errorf("the notebook size %s has no cpu or memory limit", size.Name)
+		}
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		if c := sizes[i].Resources.Limits.Memory().Cmp(*sizes[j].Resources.Limits.Memory()); c != 0 {
+			return c < 0
+		}
+		return sizes[i].Resources.Limits.Cpu().Cmp(*sizes[j].Resources.Limits.Cpu()) < 0
+	})
+	return sizes, nil
+}
+
+// notebookLimits returns the memory and cpu limits of the notebook container.
+func notebookLimits(notebook *nbv1.Notebook) (memory, cpu resource.Quantity) {
+	if container := notebookContainer(notebook); container != nil {
+		return *container.Resources.Limits.Memory(), *container.Resources.Limits.Cpu()
+	}
+	return resource.Quantity{}, resource.Quantity{}
+}
+
+// RecommendSize returns the smallest size larger than the notebook in the
+// dimensions it lacks, and not smaller in the others. Returns false if no
+// size is large enough.
+func RecommendSize(notebook *nbv1.Notebook, sizes []NotebookSize, needsMemory, needsCPU bool) (NotebookSize, bool) {
+	memory, cpu := notebookLimits(notebook)
+	for _, size := range sizes {
+		memoryCmp := size.Resources.Limits.Memory().Cmp(memory)
+		cpuCmp := size.Resources.Limits.Cpu().Cmp(cpu)
+		if memoryCmp < 0 || cpuCmp < 0 || needsMemory && memoryCmp == 0 || needsCPU && cpuCmp == 0 {
+			continue
+		}
+		return size, true
+	}
+	return NotebookSize{}, false
+}
+
+// sizeIsApplied returns true if the notebook is at least as large as the
+// named size, or if the size is unknown.
+func sizeIsApplied(notebook *nbv1.Notebook, sizes []NotebookSize, name string) bool {
+	memory, cpu := notebookLimits(notebook)
+	for _, size := range sizes {
+		if size.Name == name {
+			return memory.Cmp(*size.Resources.Limits.Memory()) >= 0 && cpu.Cmp(*size.Resources.Limits.Cpu()) >= 0
+		}
+	}
+	return true
+}
+
+// SizeRecommender periodically recommends a larger size for the notebooks
+// killed for running out of memory or whose CPU is throttled, with the
+// AnnotationRecommendedSize annotation and the ConditionSizeRecommended
+// condition. The recommendation is kept until the notebook is resized.
+type SizeRecommender struct {
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// Sizes are the notebook sizes, from the smallest to the largest.
+	Sizes []NotebookSize
+	// Interval is the interval between two recommendations.
+	Interval time.Duration
+	// PrometheusURL is the URL of the Prometheus API reporting the CPU
+	// throttling of the pods. The throttling is ignored if empty.
+	PrometheusURL string
+	// BearerToken authenticates the Prometheus queries, if not empty.
+	BearerToken string
+	// ThrottlingThreshold and ThrottlingWindow define the throttled
+	// notebooks.
+	ThrottlingThreshold float64
+	ThrottlingWindow    time.Duration
+	// HTTPClient queries Prometheus, a client with a default timeout if nil.
+	HTTPClient *http.Client
+}
+
+// Start recommends the sizes until the context is cancelled.
+func (s *SizeRecommender) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.Recommend(ctx); err != nil {
+			s.Log.Error(err, "Unable to recommend the notebook sizes")
+		}
+	}, s.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes the recommendations written by the leader only.
+func (s *SizeRecommender) NeedLeaderElection() bool {
+	return true
+}
+
+// Recommend updates the size recommendations of all the notebooks.
+func (s *SizeRecommender) Recommend(ctx context.Context) error {
+	throttling := map[types.NamespacedName]float64{}
+	if s.PrometheusURL != "" {
+		var err error
+		throttling, err = s.cpuThrottling(ctx)
+		if err != nil {
+			s.Log.Error(err, "Unable to query the CPU throttling of the notebooks, only the OOM kills are considered")
+		}
+	}
+
+	notebookList := &nbv1.NotebookList{}
+	if err := s.List(ctx, notebookList); err != nil {
+		return err
+	}
+	for i := range notebookList.Items {
+		notebook := &notebookList.Items[i]
+		oomKilled := false
+		for _, condition := range notebook.Status.Conditions {
+			if condition.Type == ConditionOOMKilled && condition.Status == string(corev1.ConditionTrue) {
+				oomKilled = true
+			}
+		}
+		ratio := throttling[types.NamespacedName{Namespace: notebook.Namespace, Name: notebook.Name + "-0"}]
+		if err := s.recommendSize(ctx, notebook, oomKilled, ratio); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recommendSize updates the size recommendation of the notebook.
+func (s *SizeRecommender) recommendSize(ctx context.Context, notebook *nbv1.Notebook, oomKilled bool,
+	throttling float64) error {
+	log := s.Log.WithValues("notebook", notebook.Name, "namespace", notebook.Namespace)
+
+	throttled := throttling > s.ThrottlingThreshold
+	current := notebook.GetAnnotations()[AnnotationRecommendedSize]
+	var condition *nbv1.NotebookCondition
+	desired := ""
+	if size, found := RecommendSize(notebook, s.Sizes, oomKilled, throttled); found && (oomKilled || throttled) {
+		reasons := []string{}
+		if oomKilled {
+			reasons = append(reasons, "its container ran out of memory")
+		}
+		if throttled {
+			reasons = append(reasons, fmt.Sprintf("its CPU was throttled %.0f%% of the time over the last %s",
+				throttling*100, s.ThrottlingWindow))
+		}
+		desired = size.Name
+		condition = &nbv1.NotebookCondition{
+			Type:   ConditionSizeRecommended,
+			Status: string(corev1.ConditionTrue),
+			Reason: "Undersized",
+			Message: fmt.Sprintf("The %s size is recommended for the notebook, as %s.", size.Name,
+				strings.Join(reasons, " and ")),
+			LastProbeTime:      metav1.Now(),
+			LastTransitionTime: metav1.Now(),
+		}
+	} else if current != "" && !sizeIsApplied(notebook, s.Sizes, current) {
+		// Keep the recommendation until the notebook is resized, e.g. while
+		// it is stopped
+		return nil
+	}
+	if desired == current {
+		return nil
+	}
+
+	log.Info("Updating the recommended size of the notebook", "size", desired)
+	value, _ := json.Marshal(desired)
+	if desired == "" {
+		value = []byte("null")
+	}
+	patch := client.RawPatch(types.MergePatchType,
+		[]byte(`{"metadata":{"annotations":{"`+AnnotationRecommendedSize+`":`+string(value)+`}}}`))
+	if err := s.Patch(ctx, notebook, patch); err != nil {
+		return err
+	}
+	if condition != nil && s.Recorder != nil {
+		s.Recorder.Event(notebook, corev1.EventTypeNormal, "SizeRecommended", condition.Message)
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.Get(ctx, client.ObjectKeyFromObject(notebook), notebook); err != nil {
+			return err
+		}
+		conditions := []nbv1.NotebookCondition{}
+		for _, existing := range notebook.Status.Conditions {
+			if existing.Type != ConditionSizeRecommended {
+				conditions = append(conditions, existing)
+			}
+		}
+		if condition != nil {
+			conditions = append(conditions, *condition)
+		}
+		notebook.Status.Conditions = conditions
+		return s.Status().Update(ctx, notebook)
+	})
+}
+
+// prometheusResponse is the response of the Prometheus instant queries.
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// cpuThrottling returns the ratio of the throttled CPU periods of the pods
+// over the throttling window.
+func (s *SizeRecommender) cpuThrottling(ctx context.Context) (map[types.NamespacedName]float64, error) {
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: prometheusTimeout}
+	}
+	query := fmt.Sprintf(cpuThrottlingQuery, strconv.Itoa(int(s.ThrottlingWindow.Seconds()))+"s")
+	queryURL := strings.TrimSuffix(s.PrometheusURL, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.BearerToken)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	response := &prometheusResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("invalid Prometheus response: %s: %w", resp.Status, err)
+	}
+	if response.Status != "success" {
+		return nil, errors.New("Prometheus query failed: " + response.Error)
+	}
+	throttling := map[types.NamespacedName]float64{}
+	for _, sample := range response.Data.Result {
+		if len(sample.Value) != 2 {
+			continue
+		}
+		value, _ := sample.Value[1].(string)
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		throttling[types.NamespacedName{Namespace: sample.Metric["namespace"], Name: sample.Metric["pod"]}] = ratio
+	}
+	return throttling, nil
+}
+
+// NewPrometheusHTTPClient returns the HTTP client querying the in-cluster
+// Prometheus, trusting the service CA of the cluster along with the system
+// CAs, and the token of the controller service account authenticating the
+// queries. The token is empty outside of the cluster.
+func NewPrometheusHTTPClient() (*http.Client, string, error) {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if ca, err := os.ReadFile(serviceCAFile); err == nil {
+		roots.AppendCertsFromPEM(ca)
+	}
+	token, err := os.ReadFile(serviceAccountTokenFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: transport, Timeout: prometheusTimeout}, strings.TrimSpace(string(token)), nil
+}
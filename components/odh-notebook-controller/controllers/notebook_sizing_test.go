@@ -0,0 +1,163 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNotebookSizes = `[
+	{"name": "Large", "resources": {"limits": {"cpu": "4", "memory": "16Gi"}}},
+	{"name": "Small", "resources": {"limits": {"cpu": "1", "memory": "4Gi"}}},
+	{"name": "Medium", "resources": {"limits": {"cpu": "2", "memory": "8Gi"}}}
+]`
+
+func newSizedNotebook(cpu, memory string) *nbv1.Notebook {
+	return &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name: "nb",
+				Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}},
+		}}},
+	}
+}
+
+func TestParseNotebookSizes(t *testing.T) {
+	sizes, err := ParseNotebookSizes(testNotebookSizes)
+	require.NoError(t, err)
+	require.Len(t, sizes, 3)
+	assert.Equal(t, "Small", sizes[0].Name)
+	assert.Equal(t, "Medium", sizes[1].Name)
+	assert.Equal(t, "Large", sizes[2].Name)
+
+	sizes, err = ParseNotebookSizes("")
+	require.NoError(t, err)
+	assert.Empty(t, sizes)
+
+	_, err = ParseNotebookSizes(`[{"name": "Small", "resources": {"limits": {"memory": "4Gi"}}}]`)
+	assert.Error(t, err)
+	_, err = ParseNotebookSizes(`[{"name": "Small", "resources": {"limits": {"cpu": "1", "memory": "4Gi"}}},
+		{"name": "Small", "resources": {"limits": {"cpu": "2", "memory": "8Gi"}}}]`)
+	assert.Error(t, err)
+	_, err = ParseNotebookSizes(`{}`)
+	assert.Error(t, err)
+}
+
+func TestRecommendSize(t *testing.T) {
+	sizes, err := ParseNotebookSizes(testNotebookSizes)
+	require.NoError(t, err)
+
+	size, found := RecommendSize(newSizedNotebook("1", "4Gi"), sizes, true, false)
+	assert.True(t, found)
+	assert.Equal(t, "Medium", size.Name)
+
+	// The sizes smaller in the other dimension are skipped
+	size, found = RecommendSize(newSizedNotebook("3", "4Gi"), sizes, true, false)
+	assert.True(t, found)
+	assert.Equal(t, "Large", size.Name)
+
+	_, found = RecommendSize(newSizedNotebook("4", "16Gi"), sizes, false, true)
+	assert.False(t, found)
+}
+
+func TestSizeRecommender(t *testing.T) {
+	ctx := context.Background()
+	sizes, err := ParseNotebookSizes(testNotebookSizes)
+	require.NoError(t, err)
+
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/api/v1/query", req.URL.Path)
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"status": "success", "data": {"resultType": "vector", "result": [
+			{"metric": {"namespace": "ns", "pod": "nb-0"}, "value": [1714557600, "0.5"]}]}}`)
+	}))
+	defer prometheus.Close()
+
+	notebook := newSizedNotebook("1", "4Gi")
+	cl := fake.NewClientBuilder().WithScheme(newTestReconciler(t, OAuthConfig{}).Scheme).WithObjects(notebook).
+		WithStatusSubresource(&nbv1.Notebook{}).Build()
+	recorder := record.NewFakeRecorder(10)
+	recommender := &SizeRecommender{
+		Client:              cl,
+		Log:                 logr.Discard(),
+		Recorder:            recorder,
+		Sizes:               sizes,
+		Interval:            time.Minute,
+		PrometheusURL:       prometheus.URL,
+		BearerToken:         "token",
+		ThrottlingThreshold: DefaultThrottlingThreshold,
+		ThrottlingWindow:    DefaultThrottlingWindow,
+	}
+
+	// The throttled notebook gets a recommendation
+	require.NoError(t, recommender.Recommend(ctx))
+	found := &nbv1.Notebook{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.Equal(t, "Medium", found.Annotations[AnnotationRecommendedSize])
+	require.Len(t, found.Status.Conditions, 1)
+	assert.Equal(t, ConditionSizeRecommended, found.Status.Conditions[0].Type)
+	assert.Contains(t, found.Status.Conditions[0].Message, "CPU was throttled 50%")
+	assert.Len(t, recorder.Events, 1)
+
+	// The recommendation is kept while the signals are gone, until the
+	// notebook is resized
+	recommender.PrometheusURL = ""
+	require.NoError(t, recommender.Recommend(ctx))
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.Equal(t, "Medium", found.Annotations[AnnotationRecommendedSize])
+
+	found.Spec.Template.Spec.Containers[0].Resources.Limits = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}
+	require.NoError(t, cl.Update(ctx, found))
+	require.NoError(t, recommender.Recommend(ctx))
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.NotContains(t, found.Annotations, AnnotationRecommendedSize)
+	assert.Empty(t, found.Status.Conditions)
+
+	// The out-of-memory kills are recommended a larger memory
+	found.Status.Conditions = []nbv1.NotebookCondition{{
+		Type:   ConditionOOMKilled,
+		Status: string(corev1.ConditionTrue),
+	}}
+	require.NoError(t, cl.Status().Update(ctx, found))
+	require.NoError(t, recommender.Recommend(ctx))
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.Equal(t, "Large", found.Annotations[AnnotationRecommendedSize])
+	assert.Len(t, found.Status.Conditions, 2)
+}
@@ -0,0 +1,84 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationSkipMutation set to true admits the notebook as is, without
+	// the OAuth injection, the image resolution, the CA bundle mounting nor
+	// any other mutation of the webhook, so that the administrators debugging
+	// a broken notebook can apply its spec verbatim. It is only honored when
+	// the controller allows it, for the users authorized to skip the
+	// mutations.
+	AnnotationSkipMutation = "notebooks.opendatahub.io/skip-mutation"
+	// SkipMutationVerb is the verb on the notebooks the users must be allowed
+	// to skip the mutations of the webhook, granted to the cluster
+	// administrators through their wildcard verbs.
+	SkipMutationVerb = "skip-mutation"
+)
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// SkipMutationRequested returns true if the notebook requests the webhook
+// mutations to be skipped with the skip-mutation annotation.
+func SkipMutationRequested(notebook *nbv1.Notebook) bool {
+	skip, _ := strconv.ParseBool(notebook.GetAnnotations()[AnnotationSkipMutation])
+	return skip
+}
+
+// SkipMutationAuthorized returns true if the user is allowed to skip the
+// mutations of the notebook: a member of one of the admin groups, or allowed
+// the skip-mutation verb on the notebook by a SubjectAccessReview.
+func SkipMutationAuthorized(ctx context.Context, c client.Client, user authenticationv1.UserInfo,
+	notebook *nbv1.Notebook, adminGroups []string) (bool, error) {
+	for _, group := range user.Groups {
+		for _, adminGroup := range adminGroups {
+			if group == adminGroup {
+				return true, nil
+			}
+		}
+	}
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	access := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:   user.Username,
+		UID:    user.UID,
+		Groups: user.Groups,
+		Extra:  extra,
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: notebook.Namespace,
+			Verb:      SkipMutationVerb,
+			Group:     nbv1.GroupVersion.Group,
+			Resource:  "notebooks",
+			Name:      notebook.Name,
+		},
+	}}
+	if err := c.Create(ctx, access); err != nil {
+		return false, err
+	}
+	return access.Status.Allowed, nil
+}
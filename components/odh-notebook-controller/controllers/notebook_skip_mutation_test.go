@@ -0,0 +1,126 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// newSkipMutationClient returns a client whose SubjectAccessReviews allow the
+// skip-mutation verb to the allowed user only.
+func newSkipMutationClient(allowed string) client.Client {
+	return fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if access, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+				access.Status.Allowed = access.Spec.User == allowed &&
+					access.Spec.ResourceAttributes.Verb == SkipMutationVerb
+				return nil
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}).Build()
+}
+
+func TestSkipMutationRequested(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb"}}
+	assert.False(t, SkipMutationRequested(notebook))
+	notebook.Annotations = map[string]string{AnnotationSkipMutation: "false"}
+	assert.False(t, SkipMutationRequested(notebook))
+	notebook.Annotations[AnnotationSkipMutation] = "true"
+	assert.True(t, SkipMutationRequested(notebook))
+
+	// The label no longer excludes the notebooks from the webhook
+	notebook.Annotations = nil
+	notebook.Labels = map[string]string{AnnotationSkipMutation: "true"}
+	assert.False(t, SkipMutationRequested(notebook))
+}
+
+func TestSkipMutationAuthorized(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	c := newSkipMutationClient("admin")
+
+	authorized, err := SkipMutationAuthorized(ctx, c, authenticationv1.UserInfo{Username: "admin"}, notebook, nil)
+	require.NoError(t, err)
+	assert.True(t, authorized)
+	authorized, err = SkipMutationAuthorized(ctx, c, authenticationv1.UserInfo{Username: "user"}, notebook, nil)
+	require.NoError(t, err)
+	assert.False(t, authorized)
+
+	// The members of the admin groups are allowed without a review
+	authorized, err = SkipMutationAuthorized(ctx, c, authenticationv1.UserInfo{Username: "user",
+		Groups: []string{"system:authenticated", "notebook-admins"}}, notebook, []string{"notebook-admins"})
+	require.NoError(t, err)
+	assert.True(t, authorized)
+}
+
+func TestHandleSkipMutation(t *testing.T) {
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+			Annotations: map[string]string{AnnotationSkipMutation: "true", AnnotationInjectOAuth: "true"}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb", Image: "quay.io/jupyter:latest"}},
+		}}},
+	}
+	raw, err := json.Marshal(notebook)
+	require.NoError(t, err)
+	scheme := runtime.NewScheme()
+	require.NoError(t, nbv1.AddToScheme(scheme))
+	w := &NotebookWebhook{Log: logr.Discard(), Client: newSkipMutationClient("admin"),
+		DynamicClient: newTestDynamicClient(), Decoder: admission.NewDecoder(scheme), SkipMutationEnabled: true}
+	newRequest := func(username string) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "admission-uid",
+			Name:      "nb",
+			Namespace: "ns",
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		}}
+	}
+
+	// The notebook is admitted verbatim, without the OAuth proxy
+	response := w.Handle(context.Background(), newRequest("admin"))
+	assert.True(t, response.Allowed)
+	assert.Empty(t, response.Patches)
+	require.Len(t, response.Warnings, 1)
+	assert.Contains(t, response.Warnings[0], AnnotationSkipMutation)
+
+	// The annotation of the users not allowed to skip the mutations is
+	// ignored
+	response = w.Handle(context.Background(), newRequest("user"))
+	assert.True(t, response.Allowed)
+	assert.NotEmpty(t, response.Patches)
+	assert.Contains(t, response.Warnings, "The "+AnnotationSkipMutation+" annotation is ignored, user is not "+
+		"allowed to skip the mutations of the webhook.")
+}
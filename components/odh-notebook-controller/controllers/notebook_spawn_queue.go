@@ -0,0 +1,144 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DefaultSpawnBurst is the default number of notebook starts admitted at
+	// once by the spawn queue.
+	DefaultSpawnBurst = 10
+
+	// spawnQueueExpiry forgets the waiting notebooks not reconciled for this
+	// long, e.g. deleted while they were waiting.
+	spawnQueueExpiry = time.Minute
+	// spawnQueueMinRetryInterval and spawnQueueMaxRetryInterval bound the
+	// requeue interval of the waiting notebooks, which are reconciled again
+	// before they expire.
+	spawnQueueMinRetryInterval = 100 * time.Millisecond
+	spawnQueueMaxRetryInterval = spawnQueueExpiry / 2
+)
+
+// SpawnQueue staggers the starts of the new notebooks, i.e. the removal of
+// their reconciliation lock, so that the creation of many notebooks at once,
+// e.g. at the start of a class, does not overload the image registry and the
+// scheduler. The starts are fairly shared between the namespaces: a
+// namespace is not admitted a start while another namespace with waiting
+// notebooks was admitted fewer starts.
+type SpawnQueue struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	// waiting holds the time the waiting notebooks were last reconciled.
+	waiting map[types.NamespacedName]time.Time
+	// admitted holds the number of starts admitted to the namespaces with
+	// waiting notebooks.
+	admitted map[string]int
+}
+
+// NewSpawnQueue returns a queue admitting ratePerSecond notebook starts per
+// second, and burst at once.
+func NewSpawnQueue(ratePerSecond float64, burst int) *SpawnQueue {
+	return &SpawnQueue{
+		limiter:  rate.NewLimiter(rate.Limit(ratePerSecond), burst),
+		waiting:  map[types.NamespacedName]time.Time{},
+		admitted: map[string]int{},
+	}
+}
+
+// Admit returns true if the start of the notebook is admitted, or the delay
+// after which the notebook must be reconciled again to be admitted.
+func (q *SpawnQueue) Admit(key types.NamespacedName, now time.Time) (bool, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(now)
+
+	// A namespace starting to wait joins the fair share of the namespaces
+	// already waiting, rather than catching up with their past starts
+	if _, ok := q.admitted[key.Namespace]; !ok {
+		q.admitted[key.Namespace], _ = q.leastAdmitted()
+	}
+	q.waiting[key] = now
+	defer func() {
+		spawnQueueLength.Set(float64(len(q.waiting)))
+	}()
+
+	least, _ := q.leastAdmitted()
+	if q.admitted[key.Namespace] > least || !q.limiter.AllowN(now, 1) {
+		return false, q.retryInterval()
+	}
+	delete(q.waiting, key)
+	q.admitted[key.Namespace]++
+	q.expire(now)
+	return true, 0
+}
+
+// Forget removes the notebook from the queue, e.g. once it is deleted.
+func (q *SpawnQueue) Forget(key types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.waiting, key)
+	q.expire(time.Now())
+	spawnQueueLength.Set(float64(len(q.waiting)))
+}
+
+// leastAdmitted returns the fewest starts admitted to a namespace with
+// waiting notebooks, false if no notebook is waiting.
+func (q *SpawnQueue) leastAdmitted() (int, bool) {
+	least, found := 0, false
+	for key := range q.waiting {
+		if count := q.admitted[key.Namespace]; !found || count < least {
+			least, found = count, true
+		}
+	}
+	return least, found
+}
+
+// expire forgets the notebooks not reconciled recently, and the starts of the
+// namespaces without waiting notebooks.
+func (q *SpawnQueue) expire(now time.Time) {
+	namespaces := map[string]bool{}
+	for key, seen := range q.waiting {
+		if now.Sub(seen) > spawnQueueExpiry {
+			delete(q.waiting, key)
+			continue
+		}
+		namespaces[key.Namespace] = true
+	}
+	for namespace := range q.admitted {
+		if !namespaces[namespace] {
+			delete(q.admitted, namespace)
+		}
+	}
+}
+
+// retryInterval returns the requeue interval of the waiting notebooks, the
+// interval between two admitted starts.
+func (q *SpawnQueue) retryInterval() time.Duration {
+	interval := time.Duration(float64(time.Second) / float64(q.limiter.Limit()))
+	switch {
+	case interval < spawnQueueMinRetryInterval:
+		return spawnQueueMinRetryInterval
+	case interval > spawnQueueMaxRetryInterval:
+		return spawnQueueMaxRetryInterval
+	}
+	return interval
+}
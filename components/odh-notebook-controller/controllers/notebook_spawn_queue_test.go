@@ -0,0 +1,65 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSpawnQueue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := NewSpawnQueue(1, 2)
+	key := func(namespace, name string) types.NamespacedName {
+		return types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
+	// The burst is admitted at once
+	admitted, _ := q.Admit(key("class", "nb-1"), now)
+	assert.True(t, admitted)
+	admitted, _ = q.Admit(key("class", "nb-2"), now)
+	assert.True(t, admitted)
+	admitted, retryAfter := q.Admit(key("class", "nb-3"), now)
+	assert.False(t, admitted)
+	assert.Equal(t, time.Second, retryAfter)
+	admitted, _ = q.Admit(key("class", "nb-4"), now)
+	assert.False(t, admitted)
+
+	// A namespace is not admitted a start while another waiting namespace
+	// was admitted fewer starts
+	admitted, _ = q.Admit(key("team", "nb-1"), now)
+	assert.False(t, admitted)
+	now = now.Add(time.Second)
+	admitted, _ = q.Admit(key("class", "nb-3"), now)
+	assert.True(t, admitted)
+	admitted, _ = q.Admit(key("team", "nb-1"), now)
+	assert.False(t, admitted)
+	now = now.Add(time.Second)
+	admitted, _ = q.Admit(key("class", "nb-4"), now)
+	assert.False(t, admitted)
+	admitted, _ = q.Admit(key("team", "nb-1"), now)
+	assert.True(t, admitted)
+	assert.Equal(t, 1.0, metricValue(t, spawnQueueLength).GetGauge().GetValue())
+
+	// The notebooks not reconciled anymore are forgotten
+	q.Forget(key("class", "nb-4"))
+	assert.Empty(t, q.waiting)
+	admitted, _ = q.Admit(key("team", "nb-2"), now.Add(spawnQueueExpiry))
+	assert.True(t, admitted)
+}
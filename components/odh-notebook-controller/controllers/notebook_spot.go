@@ -0,0 +1,356 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// AnnotationSpotInstance opts the notebook in to run on spot/preemptible
+	// nodes.
+	AnnotationSpotInstance = "notebooks.opendatahub.io/spot-instance"
+	// AnnotationSpotInterrupted is set by the controller when the spot node of
+	// the notebook is reclaimed, the notebook is then restarted on on-demand
+	// capacity until the annotation is removed.
+	AnnotationSpotInterrupted = "notebooks.opendatahub.io/spot-interrupted"
+	// AnnotationNotebookRestart makes the Kubeflow notebook controller restart
+	// the notebook pod.
+	AnnotationNotebookRestart = "notebooks.opendatahub.io/notebook-restart"
+	// AnnotationSpotInjected records the spot settings injected by the
+	// webhook, so that only those are reverted when spot is disabled.
+	AnnotationSpotInjected = "notebooks.opendatahub.io/spot-injected"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// spotReclaimReasons are the DisruptionTarget condition reasons of a pod being
+// terminated because its node is going away. The evictions (e.g. a drain) and
+// the preemptions by the scheduler are not caused by a spot reclaim.
+var spotReclaimReasons = map[string]bool{
+	"TerminationByKubelet":   true,
+	"DeletionByTaintManager": true,
+}
+
+// SpotConfig holds the scheduling and termination settings of the notebooks
+// running on spot nodes.
+type SpotConfig struct {
+	// NodeSelector selects the spot node pools.
+	NodeSelector map[string]string
+	// Tolerations tolerate the taints of the spot node pools.
+	Tolerations []corev1.Toleration
+	// TerminationGracePeriodSeconds extends the termination grace period of
+	// the notebook pod to let the preStop hook checkpoint the work.
+	TerminationGracePeriodSeconds *int64
+	// PreStopCommand is run by the notebook container when the pod is
+	// terminated, e.g. to save the open notebooks.
+	PreStopCommand []string
+}
+
+// SpotIsEnabled returns true if the notebook should run on spot nodes, that
+// is, the notebook opted in and it has not been interrupted.
+func SpotIsEnabled(meta metav1.ObjectMeta) bool {
+	if meta.Annotations[AnnotationSpotInstance] == "" || metav1.HasAnnotation(meta, AnnotationSpotInterrupted) {
+		return false
+	}
+	result, _ := strconv.ParseBool(meta.Annotations[AnnotationSpotInstance])
+	return result
+}
+
+// ParseTolerations parses a comma-separated list of tolerations in the taint
+// format <key>[=<value>]:<effect>.
+func ParseTolerations(value string) ([]corev1.Toleration, error) {
+	tolerations := []corev1.Toleration{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		keyValue, effect, found := strings.Cut(item, ":")
+		if !found || keyValue == "" {
+			return nil, fmt.Errorf("invalid toleration %q, expected <key>[=<value>]:<effect>", item)
+		}
+		toleration := corev1.Toleration{
+			Effect:   corev1.TaintEffect(effect),
+			Operator: corev1.TolerationOpExists,
+		}
+		if key, val, hasValue := strings.Cut(keyValue, "="); hasValue {
+			toleration.Key, toleration.Value, toleration.Operator = key, val, corev1.TolerationOpEqual
+		} else {
+			toleration.Key = keyValue
+		}
+		tolerations = append(tolerations, toleration)
+	}
+	return tolerations, nil
+}
+
+// spotInjection holds the spot settings injected in the notebook pod spec.
+type spotInjection struct {
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+	// TerminationGracePeriodSeconds is the injected grace period, and
+	// PreviousTerminationGracePeriodSeconds the one it replaced.
+	TerminationGracePeriodSeconds         *int64   `json:"terminationGracePeriodSeconds,omitempty"`
+	PreviousTerminationGracePeriodSeconds *int64   `json:"previousTerminationGracePeriodSeconds,omitempty"`
+	PreStopCommand                        []string `json:"preStopCommand,omitempty"`
+}
+
+// revert removes the injected settings that have not been modified since.
+func (i *spotInjection) revert(notebook *nbv1.Notebook) {
+	podSpec := &notebook.Spec.Template.Spec
+
+	for key, value := range i.NodeSelector {
+		if podSpec.NodeSelector[key] == value {
+			delete(podSpec.NodeSelector, key)
+		}
+	}
+	if len(podSpec.NodeSelector) == 0 {
+		podSpec.NodeSelector = nil
+	}
+
+	for _, injected := range i.Tolerations {
+		for index, toleration := range podSpec.Tolerations {
+			if reflect.DeepEqual(toleration, injected) {
+				podSpec.Tolerations = append(podSpec.Tolerations[:index], podSpec.Tolerations[index+1:]...)
+				break
+			}
+		}
+	}
+	if len(podSpec.Tolerations) == 0 {
+		podSpec.Tolerations = nil
+	}
+
+	if i.TerminationGracePeriodSeconds != nil && podSpec.TerminationGracePeriodSeconds != nil &&
+		*podSpec.TerminationGracePeriodSeconds == *i.TerminationGracePeriodSeconds {
+		podSpec.TerminationGracePeriodSeconds = i.PreviousTerminationGracePeriodSeconds
+	}
+
+	if len(i.PreStopCommand) > 0 {
+		for index, container := range podSpec.Containers {
+			if container.Name != PrimaryContainerName(notebook) || container.Lifecycle == nil || container.Lifecycle.PreStop == nil ||
+				container.Lifecycle.PreStop.Exec == nil ||
+				!reflect.DeepEqual(container.Lifecycle.PreStop.Exec.Command, i.PreStopCommand) {
+				continue
+			}
+			container.Lifecycle.PreStop = nil
+			if container.Lifecycle.PostStart == nil {
+				container.Lifecycle = nil
+			}
+			podSpec.Containers[index] = container
+		}
+	}
+}
+
+// InjectSpotScheduling injects the node selector, tolerations and termination
+// settings of the spot node pools in the notebook pod spec. The injected
+// settings are recorded in the notebook, so that they are reverted when spot is
+// not enabled (anymore) and the notebook is scheduled on on-demand capacity,
+// while the identical settings defined by the user are kept.
+func InjectSpotScheduling(notebook *nbv1.Notebook, config SpotConfig) {
+	podSpec := &notebook.Spec.Template.Spec
+
+	// Revert the previous injection, so that the changes of the
+	// configuration are applied as well
+	if value := notebook.GetAnnotations()[AnnotationSpotInjected]; value != "" {
+		previous := &spotInjection{}
+		if err := json.Unmarshal([]byte(value), previous); err == nil {
+			previous.revert(notebook)
+		}
+		delete(notebook.Annotations, AnnotationSpotInjected)
+	}
+
+	if !SpotIsEnabled(notebook.ObjectMeta) {
+		return
+	}
+	injection := &spotInjection{}
+
+	// Node selector, do not override the node selector of the user
+	for key, value := range config.NodeSelector {
+		if _, found := podSpec.NodeSelector[key]; found {
+			continue
+		}
+		if podSpec.NodeSelector == nil {
+			podSpec.NodeSelector = map[string]string{}
+		}
+		podSpec.NodeSelector[key] = value
+		if injection.NodeSelector == nil {
+			injection.NodeSelector = map[string]string{}
+		}
+		injection.NodeSelector[key] = value
+	}
+
+	// Tolerations
+	for _, spotToleration := range config.Tolerations {
+		found := false
+		for _, toleration := range podSpec.Tolerations {
+			if reflect.DeepEqual(toleration, spotToleration) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			podSpec.Tolerations = append(podSpec.Tolerations, spotToleration)
+			injection.Tolerations = append(injection.Tolerations, spotToleration)
+		}
+	}
+
+	// Termination grace period
+	if config.TerminationGracePeriodSeconds != nil && (podSpec.TerminationGracePeriodSeconds == nil ||
+		*podSpec.TerminationGracePeriodSeconds < *config.TerminationGracePeriodSeconds) {
+		gracePeriod := *config.TerminationGracePeriodSeconds
+		injection.PreviousTerminationGracePeriodSeconds = podSpec.TerminationGracePeriodSeconds
+		injection.TerminationGracePeriodSeconds = &gracePeriod
+		podSpec.TerminationGracePeriodSeconds = &gracePeriod
+	}
+
+	// Termination notice handler, do not override the hook of the user
+	if len(config.PreStopCommand) > 0 {
+		for index, container := range podSpec.Containers {
+			if container.Name != PrimaryContainerName(notebook) {
+				continue
+			}
+			if container.Lifecycle == nil {
+				container.Lifecycle = &corev1.Lifecycle{}
+			}
+			if container.Lifecycle.PreStop == nil {
+				container.Lifecycle.PreStop = &corev1.LifecycleHandler{
+					Exec: &corev1.ExecAction{Command: config.PreStopCommand},
+				}
+				injection.PreStopCommand = config.PreStopCommand
+			}
+			podSpec.Containers[index] = container
+			break
+		}
+	}
+
+	if reflect.DeepEqual(injection, &spotInjection{}) {
+		return
+	}
+	value, err := json.Marshal(injection)
+	if err != nil {
+		return
+	}
+	if notebook.Annotations == nil {
+		notebook.Annotations = map[string]string{}
+	}
+	notebook.Annotations[AnnotationSpotInjected] = string(value)
+}
+
+// spotReclaimed returns true if the notebook pod is being terminated because
+// its spot node is going away.
+func spotReclaimed(pod *corev1.Pod, config SpotConfig) (bool, string) {
+	// The pod must run on the spot node pools
+	for key, value := range config.NodeSelector {
+		if pod.Spec.NodeSelector[key] != value {
+			return false, ""
+		}
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.DisruptionTarget && condition.Status == corev1.ConditionTrue &&
+			spotReclaimReasons[condition.Reason] {
+			return true, condition.Reason
+		}
+	}
+	return false, ""
+}
+
+// NotebookPodCacheByObject restricts the Pods cached by the manager to the
+// notebook pods, labeled by the Kubeflow notebook controller with the name of
+// their notebook, the only ones the controller reads.
+func NotebookPodCacheByObject() cache.ByObject {
+	requirement, _ := labels.NewRequirement("notebook-name", selection.Exists, nil)
+	return cache.ByObject{Label: labels.NewSelector().Add(*requirement)}
+}
+
+// spotReclaimedPodNotebook maps the notebook pods being terminated because
+// their spot node is reclaimed to their notebook, so that it is restarted on
+// on-demand capacity without waiting for its next resync.
+func (r *OpenshiftNotebookReconciler) spotReclaimedPodNotebook(ctx context.Context,
+	obj client.Object) []reconcile.Request {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Labels["notebook-name"] == "" {
+		return nil
+	}
+	if reclaimed, _ := spotReclaimed(pod, r.SpotConfig); !reclaimed {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Namespace: pod.Namespace,
+		Name:      pod.Labels["notebook-name"],
+	}}}
+}
+
+// ReconcileSpotInterruption marks the notebook for a restart on on-demand
+// capacity when its pod is being terminated because the spot node has been
+// reclaimed.
+func (r *OpenshiftNotebookReconciler) ReconcileSpotInterruption(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if !SpotIsEnabled(notebook.ObjectMeta) {
+		return nil
+	}
+
+	// The notebook pod is managed by the notebook statefulset
+	pod := &corev1.Pod{}
+	err := r.Get(ctx, types.NamespacedName{Name: notebook.Name + "-0", Namespace: notebook.Namespace}, pod)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the notebook Pod")
+		return err
+	}
+
+	reclaimed, reason := spotReclaimed(pod, r.SpotConfig)
+	if !reclaimed {
+		return nil
+	}
+	log.Info("Spot node reclaimed, restarting the notebook on on-demand capacity", "reason", reason)
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				AnnotationSpotInterrupted: time.Now().UTC().Format(time.RFC3339),
+				AnnotationNotebookRestart: "true",
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+	if err != nil {
+		log.Error(err, "Unable to mark the notebook as interrupted")
+		return err
+	}
+	return nil
+}
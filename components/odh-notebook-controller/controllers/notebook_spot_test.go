@@ -0,0 +1,175 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestParseTolerations(t *testing.T) {
+	tolerations, err := ParseTolerations("spot:NoSchedule, cloud.google.com/gke-spot=true:NoExecute,")
+	require.NoError(t, err)
+	assert.Equal(t, []corev1.Toleration{
+		{Key: "spot", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		{Key: "cloud.google.com/gke-spot", Operator: corev1.TolerationOpEqual, Value: "true",
+			Effect: corev1.TaintEffectNoExecute},
+	}, tolerations)
+
+	tolerations, err = ParseTolerations("")
+	require.NoError(t, err)
+	assert.Empty(t, tolerations)
+
+	_, err = ParseTolerations("spot")
+	assert.Error(t, err)
+	_, err = ParseTolerations(":NoSchedule")
+	assert.Error(t, err)
+}
+
+func TestInjectSpotScheduling(t *testing.T) {
+	spotToleration := corev1.Toleration{Key: "spot", Operator: corev1.TolerationOpExists,
+		Effect: corev1.TaintEffectNoSchedule}
+	config := SpotConfig{
+		NodeSelector:                  map[string]string{"pool": "spot"},
+		Tolerations:                   []corev1.Toleration{spotToleration},
+		TerminationGracePeriodSeconds: pointer.Int64(120),
+		PreStopCommand:                []string{"/bin/sh", "-c", "save"},
+	}
+	newNotebook := func() *nbv1.Notebook {
+		return &nbv1.Notebook{
+			ObjectMeta: metav1.ObjectMeta{Name: "nb", Annotations: map[string]string{AnnotationSpotInstance: "true"}},
+			Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+				TerminationGracePeriodSeconds: pointer.Int64(30),
+				Containers:                    []corev1.Container{{Name: "nb"}},
+			}}},
+		}
+	}
+
+	t.Run("inject and revert", func(t *testing.T) {
+		notebook := newNotebook()
+		original := notebook.DeepCopy()
+		InjectSpotScheduling(notebook, config)
+		podSpec := notebook.Spec.Template.Spec
+		assert.Equal(t, map[string]string{"pool": "spot"}, podSpec.NodeSelector)
+		assert.Equal(t, []corev1.Toleration{spotToleration}, podSpec.Tolerations)
+		assert.Equal(t, int64(120), *podSpec.TerminationGracePeriodSeconds)
+		assert.Equal(t, config.PreStopCommand, podSpec.Containers[0].Lifecycle.PreStop.Exec.Command)
+		assert.Contains(t, notebook.Annotations, AnnotationSpotInjected)
+
+		// Injecting again is a no-op
+		injected := notebook.DeepCopy()
+		InjectSpotScheduling(notebook, config)
+		assert.Equal(t, injected, notebook)
+
+		// Interrupted notebooks get back their original settings
+		notebook.Annotations[AnnotationSpotInterrupted] = "2024-01-01T00:00:00Z"
+		original.Annotations[AnnotationSpotInterrupted] = "2024-01-01T00:00:00Z"
+		InjectSpotScheduling(notebook, config)
+		assert.Equal(t, original, notebook)
+	})
+
+	t.Run("keep the settings of the user", func(t *testing.T) {
+		notebook := newNotebook()
+		podSpec := &notebook.Spec.Template.Spec
+		podSpec.NodeSelector = map[string]string{"pool": "spot"}
+		podSpec.Tolerations = []corev1.Toleration{spotToleration}
+		podSpec.Containers[0].Lifecycle = &corev1.Lifecycle{PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{Command: []string{"checkpoint"}},
+		}}
+		original := notebook.DeepCopy()
+
+		InjectSpotScheduling(notebook, config)
+		assert.Equal(t, []string{"checkpoint"}, podSpec.Containers[0].Lifecycle.PreStop.Exec.Command)
+
+		notebook.Annotations[AnnotationSpotInstance] = "false"
+		original.Annotations[AnnotationSpotInstance] = "false"
+		InjectSpotScheduling(notebook, config)
+		assert.Equal(t, original, notebook)
+	})
+}
+
+func TestReconcileSpotInterruption(t *testing.T) {
+	config := SpotConfig{NodeSelector: map[string]string{"pool": "spot"}}
+	newPod := func(nodeSelector map[string]string, status corev1.ConditionStatus, reason string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "nb-0", Namespace: "ns"},
+			Spec:       corev1.PodSpec{NodeSelector: nodeSelector},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{
+				{Type: corev1.DisruptionTarget, Status: status, Reason: reason},
+			}},
+		}
+	}
+
+	for _, tt := range []struct {
+		name        string
+		pod         *corev1.Pod
+		interrupted bool
+	}{
+		{"spot node reclaimed", newPod(config.NodeSelector, corev1.ConditionTrue, "TerminationByKubelet"), true},
+		{"spot node tainted", newPod(config.NodeSelector, corev1.ConditionTrue, "DeletionByTaintManager"), true},
+		{"condition not true", newPod(config.NodeSelector, corev1.ConditionFalse, "TerminationByKubelet"), false},
+		{"drain", newPod(config.NodeSelector, corev1.ConditionTrue, "EvictionByEvictionAPI"), false},
+		{"preemption", newPod(config.NodeSelector, corev1.ConditionTrue, "PreemptionByScheduler"), false},
+		{"on-demand node", newPod(nil, corev1.ConditionTrue, "TerminationByKubelet"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+				Name: "nb", Namespace: "ns", Annotations: map[string]string{AnnotationSpotInstance: "true"},
+			}}
+			r := newTestReconciler(t, OAuthConfig{}, notebook, tt.pod)
+			r.SpotConfig = config
+
+			require.NoError(t, r.ReconcileSpotInterruption(notebook, ctx))
+			require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook))
+			assert.Equal(t, tt.interrupted, metav1.HasAnnotation(notebook.ObjectMeta, AnnotationSpotInterrupted))
+			assert.Equal(t, tt.interrupted, metav1.HasAnnotation(notebook.ObjectMeta, AnnotationNotebookRestart))
+		})
+	}
+}
+
+func TestSpotReclaimedPodNotebook(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t, OAuthConfig{})
+	r.SpotConfig = SpotConfig{NodeSelector: map[string]string{"pool": "spot"}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb-0", Namespace: "ns", Labels: map[string]string{"notebook-name": "nb"}},
+		Spec:       corev1.PodSpec{NodeSelector: map[string]string{"pool": "spot"}},
+	}
+	assert.True(t, NotebookPodCacheByObject().Label.Matches(labels.Set(pod.Labels)))
+	assert.False(t, NotebookPodCacheByObject().Label.Matches(labels.Set{"app": "other"}))
+
+	// Only the reclaims of the spot nodes reconcile the notebooks
+	assert.Empty(t, r.spotReclaimedPodNotebook(ctx, pod))
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.DisruptionTarget, Status: corev1.ConditionTrue, Reason: "TerminationByKubelet"},
+	}
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "nb"}}},
+		r.spotReclaimedPodNotebook(ctx, pod))
+	pod.Labels = nil
+	assert.Empty(t, r.spotReclaimedPodNotebook(ctx, pod))
+}
@@ -0,0 +1,288 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// StartupPagePortName is the name of the port of the startup page
+	// Service, targeted by the notebook Route while the notebook starts.
+	StartupPagePortName = "http-starting"
+
+	// startupPageRetrySeconds is the delay after which the clients retry,
+	// the browsers reload the page after the same delay.
+	startupPageRetrySeconds = 5
+)
+
+// +kubebuilder:rbac:groups="",resources=endpoints,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=endpoints/restricted,verbs=create;update;patch
+
+// startupPage is the page served to the users while their notebook starts,
+// instead of the generic error page of the router.
+const startupPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>Your workbench is starting</title>
+<style>body{font-family:sans-serif;text-align:center;margin-top:20vh;color:#151515}</style>
+</head>
+<body>
+<h1>Your workbench is starting</h1>
+<p>This page reloads automatically once the workbench is ready.</p>
+</body>
+</html>
+`
+
+// StartupPageConfig holds the settings of the page served by the controller
+// while the notebooks start.
+type StartupPageConfig struct {
+	// Address is the IP address of the controller pod serving the startup
+	// page, the page is not served if empty.
+	Address string
+	// Port is the port the startup page is served on.
+	Port int32
+}
+
+// Enabled returns true if the startup page is served.
+func (c StartupPageConfig) Enabled() bool {
+	return c.Address != "" && c.Port > 0
+}
+
+// ParseStartupPageConfig returns the settings of the startup page served on
+// the given bind address, reached at the given IP address of the controller
+// pod. The startup page is disabled if the bind address is empty.
+func ParseStartupPageConfig(bindAddress, podIP string) (StartupPageConfig, error) {
+	if bindAddress == "" {
+		return StartupPageConfig{}, nil
+	}
+	_, port, err := net.SplitHostPort(bindAddress)
+	if err != nil {
+		return StartupPageConfig{}, fmt.Errorf("invalid startup page bind address %q: %w", bindAddress, err)
+	}
+	value, err := strconv.ParseInt(port, 10, 32)
+	if err != nil || value <= 0 {
+		return StartupPageConfig{}, fmt.Errorf("invalid startup page port %q", port)
+	}
+	if net.ParseIP(podIP) == nil {
+		return StartupPageConfig{}, fmt.Errorf("invalid startup page address %q, the IP address of the "+
+			"controller pod is required", podIP)
+	}
+	return StartupPageConfig{Address: podIP, Port: int32(value)}, nil
+}
+
+// notebookIsStarting returns true while the notebook is started but its pod
+// is not ready yet.
+func notebookIsStarting(notebook *nbv1.Notebook) bool {
+	return notebook.DeletionTimestamp == nil && !notebookIsStopped(notebook.ObjectMeta) &&
+		notebook.Status.ReadyReplicas == 0
+}
+
+// StartupPageServiceName returns the name of the Service of the startup page
+// of the notebook.
+func StartupPageServiceName(notebook *nbv1.Notebook) string {
+	return notebook.Name + "-starting"
+}
+
+// NewStartupPageService defines the Service of the startup page of the
+// notebook. It has no selector, its endpoints are the controller pod.
+func NewStartupPageService(notebook *nbv1.Notebook, config StartupPageConfig) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      StartupPageServiceName(notebook),
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentStartupPage),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       StartupPagePortName,
+				Port:       80,
+				TargetPort: intstr.FromInt(int(config.Port)),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+}
+
+// NewStartupPageEndpoints defines the endpoints of the startup page Service,
+// the address of the controller pod.
+func NewStartupPageEndpoints(notebook *nbv1.Notebook, config StartupPageConfig) *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      StartupPageServiceName(notebook),
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentStartupPage),
+		},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: config.Address}},
+			Ports: []corev1.EndpointPort{{
+				Name:     StartupPagePortName,
+				Port:     config.Port,
+				Protocol: corev1.ProtocolTCP,
+			}},
+		}},
+	}
+}
+
+// applyStartupPage routes the traffic of the starting notebook to the startup
+// page. The page is served without TLS to the router, the route is edge
+// terminated until the notebook is ready.
+func (c StartupPageConfig) applyStartupPage(notebook *nbv1.Notebook, route *routev1.Route) {
+	if !c.Enabled() || !notebookIsStarting(notebook) {
+		return
+	}
+	route.Spec.To.Name = StartupPageServiceName(notebook)
+	route.Spec.Port = &routev1.RoutePort{TargetPort: intstr.FromString(StartupPagePortName)}
+	route.Spec.TLS = &routev1.TLSConfig{
+		Termination:                   routev1.TLSTerminationEdge,
+		InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyRedirect,
+	}
+}
+
+// ReconcileStartupPage manages the Service and endpoints of the startup page
+// of the notebooks exposed through a Route.
+func (r *OpenshiftNotebookReconciler) ReconcileStartupPage(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	if !r.StartupPageConfig.Enabled() {
+		return nil
+	}
+	name := StartupPageServiceName(notebook)
+	if !r.ExposureConfig.RouteIsEnabled(notebook) {
+		if err := r.deleteControlledObject(ctx, notebook, name, &corev1.Endpoints{}); err != nil {
+			return err
+		}
+		return r.deleteControlledObject(ctx, notebook, name, &corev1.Service{})
+	}
+
+	desiredService := NewStartupPageService(notebook, r.StartupPageConfig)
+	foundService := &corev1.Service{}
+	err := r.reconcileStartupPageObject(notebook, ctx, desiredService, foundService, func() bool {
+		if reflect.DeepEqual(desiredService.Spec.Ports, foundService.Spec.Ports) {
+			return false
+		}
+		foundService.Spec.Ports = desiredService.Spec.Ports
+		return true
+	})
+	if err != nil {
+		log.Error(err, "Unable to reconcile the startup page Service")
+		return err
+	}
+
+	desiredEndpoints := NewStartupPageEndpoints(notebook, r.StartupPageConfig)
+	foundEndpoints := &corev1.Endpoints{}
+	err = r.reconcileStartupPageObject(notebook, ctx, desiredEndpoints, foundEndpoints, func() bool {
+		// The controller pod changes on restarts and leader elections
+		if reflect.DeepEqual(desiredEndpoints.Subsets, foundEndpoints.Subsets) {
+			return false
+		}
+		foundEndpoints.Subsets = desiredEndpoints.Subsets
+		return true
+	})
+	if err != nil {
+		log.Error(err, "Unable to reconcile the startup page endpoints")
+		return err
+	}
+	return nil
+}
+
+// reconcileStartupPageObject creates the desired object, or updates the found
+// one if the update function changed it.
+func (r *OpenshiftNotebookReconciler) reconcileStartupPageObject(notebook *nbv1.Notebook, ctx context.Context,
+	desired, found client.Object, update func() bool) error {
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), found)
+	if apierrs.IsNotFound(err) {
+		r.notebookLogger(notebook).Info("Creating startup page object", "kind", kindOf(r.Scheme, desired))
+		err = ctrl.SetControllerReference(notebook, desired, r.Scheme)
+		if err != nil {
+			return err
+		}
+		err = r.Create(ctx, desired)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if update() {
+		return r.Update(ctx, found)
+	}
+	return nil
+}
+
+// StartupPageServer serves the startup page of the notebooks, reached through
+// their Route while they start.
+type StartupPageServer struct {
+	Log logr.Logger
+	// BindAddress is the address the startup page is served on.
+	BindAddress string
+}
+
+// ServeHTTP serves the startup page, as unavailable so that the clients
+// other than the browsers retry.
+func (s *StartupPageServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", strconv.Itoa(startupPageRetrySeconds))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write([]byte(startupPage))
+}
+
+// Start serves the startup page until the context is cancelled.
+func (s *StartupPageServer) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.BindAddress,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	s.Log.Info("Serving the notebook startup page", "address", s.BindAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection serves the startup page from all the replicas.
+func (s *StartupPageServer) NeedLeaderElection() bool {
+	return false
+}
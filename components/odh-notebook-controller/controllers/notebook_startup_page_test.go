@@ -0,0 +1,98 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestParseStartupPageConfig(t *testing.T) {
+	config, err := ParseStartupPageConfig(":8090", "10.128.0.12")
+	require.NoError(t, err)
+	assert.Equal(t, StartupPageConfig{Address: "10.128.0.12", Port: 8090}, config)
+	config, err = ParseStartupPageConfig("", "")
+	require.NoError(t, err)
+	assert.False(t, config.Enabled())
+	_, err = ParseStartupPageConfig(":8090", "")
+	assert.Error(t, err)
+	_, err = ParseStartupPageConfig("8090", "10.128.0.12")
+	assert.Error(t, err)
+}
+
+func TestApplyStartupPage(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	config := StartupPageConfig{Address: "10.128.0.12", Port: 8090}
+
+	// The starting notebooks are routed to the startup page
+	route := NewNotebookOAuthRoute(notebook)
+	config.applyStartupPage(notebook, route)
+	assert.Equal(t, "nb-starting", route.Spec.To.Name)
+	assert.Equal(t, StartupPagePortName, route.Spec.Port.TargetPort.StrVal)
+	assert.Equal(t, routev1.TLSTerminationEdge, route.Spec.TLS.Termination)
+
+	// The ready and stopped notebooks are routed to the notebook
+	notebook.Status.ReadyReplicas = 1
+	route = NewNotebookOAuthRoute(notebook)
+	config.applyStartupPage(notebook, route)
+	assert.Equal(t, NewNotebookOAuthRoute(notebook), route)
+	notebook.Status.ReadyReplicas = 0
+	notebook.Annotations = map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"}
+	config.applyStartupPage(notebook, route)
+	assert.Equal(t, NewNotebookOAuthRoute(notebook), route)
+}
+
+func TestReconcileStartupPage(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	r.StartupPageConfig = StartupPageConfig{Address: "10.128.0.12", Port: 8090}
+
+	require.NoError(t, r.ReconcileStartupPage(notebook, ctx))
+	key := client.ObjectKey{Name: "nb-starting", Namespace: "ns"}
+	service := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, key, service))
+	assert.Empty(t, service.Spec.Selector)
+	assert.True(t, metav1.IsControlledBy(service, notebook))
+	endpoints := &corev1.Endpoints{}
+	require.NoError(t, r.Get(ctx, key, endpoints))
+	assert.Equal(t, "10.128.0.12", endpoints.Subsets[0].Addresses[0].IP)
+
+	// The endpoints follow the controller pod
+	r.StartupPageConfig.Address = "10.128.0.13"
+	require.NoError(t, r.ReconcileStartupPage(notebook, ctx))
+	require.NoError(t, r.Get(ctx, key, endpoints))
+	assert.Equal(t, "10.128.0.13", endpoints.Subsets[0].Addresses[0].IP)
+}
+
+func TestStartupPageServer(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	(&StartupPageServer{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/lab", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "5", recorder.Header().Get("Retry-After"))
+	assert.Contains(t, recorder.Body.String(), "Your workbench is starting")
+}
@@ -0,0 +1,227 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultStorageClassAnnotation marks the default StorageClass of the
+// cluster, provisioning the claims without StorageClass.
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+// +kubebuilder:rbac:groups="storage.k8s.io",resources=storageclasses,verbs=get;list;watch
+
+// StorageReport reports the claims of the notebook which prevent its pod from
+// starting, and those which may.
+type StorageReport struct {
+	// Problems leave the notebook pod pending, e.g. a claim provisioned by a
+	// StorageClass which does not exist.
+	Problems []string
+	// Warnings may leave the notebook pod pending, e.g. a single-node claim
+	// mounted by notebooks scheduled on other nodes.
+	Warnings []string
+}
+
+// claimMounts returns how the containers of the notebook use its claims: true
+// for the claims mounted as file systems, false for those attached as block
+// devices.
+func claimMounts(notebook *nbv1.Notebook) map[string]map[bool]bool {
+	podSpec := &notebook.Spec.Template.Spec
+	volumes := map[string]string{}
+	for _, volume := range podSpec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			volumes[volume.Name] = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	mounts := map[string]map[bool]bool{}
+	add := func(volume string, filesystem bool) {
+		if claim, ok := volumes[volume]; ok {
+			if mounts[claim] == nil {
+				mounts[claim] = map[bool]bool{}
+			}
+			mounts[claim][filesystem] = true
+		}
+	}
+	containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+	for _, container := range containers {
+		for _, mount := range container.VolumeMounts {
+			add(mount.Name, true)
+		}
+		for _, device := range container.VolumeDevices {
+			add(device.Name, false)
+		}
+	}
+	return mounts
+}
+
+// clusterDefaultStorageClass returns the default StorageClass of the cluster,
+// the most recent one if many are marked as default, nil if there is none.
+func clusterDefaultStorageClass(ctx context.Context, reader client.Reader) (*storagev1.StorageClass, error) {
+	classList := &storagev1.StorageClassList{}
+	if err := reader.List(ctx, classList); err != nil {
+		return nil, err
+	}
+	var found *storagev1.StorageClass
+	for i := range classList.Items {
+		class := &classList.Items[i]
+		if class.Annotations[defaultStorageClassAnnotation] != "true" {
+			continue
+		}
+		if found == nil || found.CreationTimestamp.Before(&class.CreationTimestamp) {
+			found = class
+		}
+	}
+	return found, nil
+}
+
+// ValidateNotebookStorage checks the claims of the notebook, which leave its
+// pod pending rather than failing otherwise: the StorageClass of the unbound
+// claims must exist, their volume mode must match how they are mounted, and
+// the single-node claims shared with the other running notebooks of the
+// namespace must be attached to a single node. The missing claims are
+// reported by MissingNotebookReferences.
+func ValidateNotebookStorage(ctx context.Context, reader client.Reader, notebook *nbv1.Notebook) (StorageReport,
+	error) {
+	report := StorageReport{}
+	mounts := claimMounts(notebook)
+	if len(mounts) == 0 {
+		return report, nil
+	}
+
+	// The claims mounted by the other running notebooks of the namespace
+	sharedWith := map[string][]string{}
+	notebookList := &nbv1.NotebookList{}
+	if err := reader.List(ctx, notebookList, client.InNamespace(notebook.Namespace)); err != nil {
+		return report, err
+	}
+	for i := range notebookList.Items {
+		other := &notebookList.Items[i]
+		if other.Name == notebook.Name || notebookIsStopped(other.ObjectMeta) {
+			continue
+		}
+		for claim := range claimMounts(other) {
+			sharedWith[claim] = append(sharedWith[claim], other.Name)
+		}
+	}
+
+	claims := make([]string, 0, len(mounts))
+	for claim := range mounts {
+		claims = append(claims, claim)
+	}
+	sort.Strings(claims)
+	var defaultClass *storagev1.StorageClass
+	defaultClassFetched := false
+	for _, claim := range claims {
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := reader.Get(ctx, client.ObjectKey{Namespace: notebook.Namespace, Name: claim}, pvc)
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return report, err
+		}
+
+		block := pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock
+		if block && mounts[claim][true] {
+			report.Problems = append(report.Problems, fmt.Sprintf(
+				"the PVC %s has the Block volume mode and cannot be mounted as a file system, "+
+					"use volumeDevices instead of volumeMounts", claim))
+		} else if !block && mounts[claim][false] {
+			report.Problems = append(report.Problems, fmt.Sprintf(
+				"the PVC %s has the Filesystem volume mode and cannot be attached as a block device, "+
+					"use volumeMounts instead of volumeDevices", claim))
+		}
+
+		if others := sharedWith[claim]; len(others) > 0 && singleNodeAccessMode(pvc) {
+			message := fmt.Sprintf("the PVC %s is also mounted by the notebooks %s but its access modes %v "+
+				"attach it to a single node, shared mounts require the ReadWriteMany access mode",
+				claim, strings.Join(others, ", "), pvc.Spec.AccessModes)
+			if accessModeIncludes(pvc, corev1.ReadWriteOncePod) {
+				report.Problems = append(report.Problems, message)
+			} else {
+				report.Warnings = append(report.Warnings, message)
+			}
+		}
+
+		// The StorageClass of the bound claims is not used anymore, and the
+		// claims with an empty StorageClass are bound to existing volumes
+		if pvc.Spec.VolumeName != "" || pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName == "" {
+			continue
+		}
+		if pvc.Spec.StorageClassName == nil {
+			if !defaultClassFetched {
+				defaultClass, err = clusterDefaultStorageClass(ctx, reader)
+				if err != nil {
+					return report, err
+				}
+				defaultClassFetched = true
+			}
+			if defaultClass == nil {
+				report.Problems = append(report.Problems, fmt.Sprintf(
+					"the PVC %s has no StorageClass and the cluster has no default StorageClass, "+
+						"it is never bound", claim))
+			}
+			continue
+		}
+		class := &storagev1.StorageClass{}
+		err = reader.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, class)
+		if apierrs.IsNotFound(err) {
+			report.Problems = append(report.Problems, fmt.Sprintf(
+				"the StorageClass %s of the PVC %s does not exist, it is never bound",
+				*pvc.Spec.StorageClassName, claim))
+		} else if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// accessModeIncludes returns true if the PVC has the access mode.
+func accessModeIncludes(pvc *corev1.PersistentVolumeClaim, mode corev1.PersistentVolumeAccessMode) bool {
+	for _, existing := range pvc.Spec.AccessModes {
+		if existing == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// StorageProblemsMessage describes the storage problems of the notebook.
+func StorageProblemsMessage(notebook *nbv1.Notebook, problems []string) string {
+	return fmt.Sprintf("The storage of the notebook %s would leave its pod pending: %s",
+		notebook.Name, strings.Join(problems, "; "))
+}
+
+// StorageWarningsMessage describes the storage warnings of the notebook.
+func StorageWarningsMessage(notebook *nbv1.Notebook, warnings []string) string {
+	return fmt.Sprintf("The storage of the notebook %s may leave its pod pending: %s",
+		notebook.Name, strings.Join(warnings, "; "))
+}
+
+// withoutStorageClass returns true if the claim is not provisioned by a
+// StorageClass, either the default one of the cluster or none.
+func withoutStorageClass(pvc *corev1.PersistentVolumeClaim) bool {
+	return pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == ""
+}
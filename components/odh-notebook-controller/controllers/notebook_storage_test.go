@@ -0,0 +1,107 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newStorageNotebook(name string, claims ...string) *nbv1.Notebook {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}}
+	container := corev1.Container{Name: name}
+	for _, claim := range claims {
+		notebook.Spec.Template.Spec.Volumes = append(notebook.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: claim, VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: claim, MountPath: "/" + claim})
+	}
+	notebook.Spec.Template.Spec.Containers = []corev1.Container{container}
+	return notebook
+}
+
+func newStorageClaim(name string, storageClass *string, mode corev1.PersistentVolumeAccessMode) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{mode},
+			StorageClassName: storageClass,
+		},
+	}
+}
+
+func TestValidateNotebookStorage(t *testing.T) {
+	ctx := context.Background()
+	gp3, missing := "gp3", "missing"
+	block := corev1.PersistentVolumeBlock
+	blockClaim := newStorageClaim("block", &gp3, corev1.ReadWriteOnce)
+	blockClaim.Spec.VolumeMode = &block
+	boundClaim := newStorageClaim("bound", &missing, corev1.ReadWriteOnce)
+	boundClaim.Spec.VolumeName = "pv-bound"
+	stopped := newStorageNotebook("stopped", "pod-claim")
+	stopped.Annotations = map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"}
+	r := newTestReconciler(t, OAuthConfig{},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "gp3"}, Provisioner: "ebs.csi.aws.com"},
+		newStorageClaim("home", &gp3, corev1.ReadWriteOnce),
+		newStorageClaim("shared", &gp3, corev1.ReadWriteOnce),
+		newStorageClaim("pod-claim", &gp3, corev1.ReadWriteOncePod),
+		newStorageClaim("unknown-class", &missing, corev1.ReadWriteOnce),
+		newStorageClaim("default-class", nil, corev1.ReadWriteOnce),
+		blockClaim, boundClaim, stopped,
+		newStorageNotebook("other", "shared"),
+	)
+
+	// The valid claims, the bound ones and the claims mounted by stopped
+	// notebooks are admitted
+	report, err := ValidateNotebookStorage(ctx, r.Client, newStorageNotebook("nb", "home", "bound", "pod-claim"))
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+	assert.Empty(t, report.Warnings)
+
+	// The single-node claims mounted by other running notebooks are warned
+	// about
+	report, err = ValidateNotebookStorage(ctx, r.Client, newStorageNotebook("nb", "shared"))
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+	require.Len(t, report.Warnings, 1)
+	assert.Contains(t, report.Warnings[0], "the PVC shared is also mounted by the notebooks other")
+
+	// The claims which would leave the pod pending are reported
+	report, err = ValidateNotebookStorage(ctx, r.Client,
+		newStorageNotebook("nb", "block", "default-class", "unknown-class"))
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 3)
+	assert.Contains(t, report.Problems[0], "the PVC block has the Block volume mode")
+	assert.Contains(t, report.Problems[1], "the cluster has no default StorageClass")
+	assert.Contains(t, report.Problems[2], "the StorageClass missing of the PVC unknown-class does not exist")
+
+	// The claims without StorageClass are provisioned by the default
+	// StorageClass of the cluster
+	require.NoError(t, r.Create(ctx, &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "nfs",
+		Annotations: map[string]string{defaultStorageClassAnnotation: "true"}}, Provisioner: "nfs.csi.k8s.io"}))
+	report, err = ValidateNotebookStorage(ctx, r.Client, newStorageNotebook("nb", "default-class"))
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+}
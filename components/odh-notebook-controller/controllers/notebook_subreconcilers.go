@@ -0,0 +1,252 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// DefaultSubReconcileConcurrency is the default number of sub-reconcilers
+// run concurrently within a notebook reconcile, i.e. in order.
+const DefaultSubReconcileConcurrency = 1
+
+// subReconciler reconciles a subsystem of the notebook, whose objects are
+// independent of the objects of the other subsystems.
+type subReconciler struct {
+	name      string
+	reconcile func(notebook *nbv1.Notebook, ctx context.Context) error
+}
+
+// notebookSubReconcilers returns the independent subsystems of the notebook,
+// run concurrently by runSubReconcilers.
+func (r *OpenshiftNotebookReconciler) notebookSubReconcilers() []subReconciler {
+	return []subReconciler{
+		{name: "trusted-ca-bundle", reconcile: r.reconcileTrustedCABundleSubsystem},
+		{name: "network-policies", reconcile: r.reconcileNetworkPoliciesSubsystem},
+		{name: "rbac", reconcile: r.reconcileRBACSubsystem},
+		{name: "exposure", reconcile: r.reconcileExposureSubsystem},
+	}
+}
+
+// runSubReconcilers runs the sub-reconcilers, at most SubReconcileConcurrency
+// at once, and returns the first error, cancelling the context of the
+// sub-reconcilers still running. The sub-reconcilers run in order when the
+// concurrency is 1 or less. Each concurrent sub-reconciler is given its own
+// copy of the notebook, merged back into the notebook once they all return
+// (see mergeNotebookCopies).
+func (r *OpenshiftNotebookReconciler) runSubReconcilers(notebook *nbv1.Notebook, ctx context.Context,
+	subReconcilers []subReconciler) error {
+	run := func(sub subReconciler, notebook *nbv1.Notebook, ctx context.Context) error {
+		start := time.Now()
+		defer func() {
+			notebookSubReconcileDurationSeconds.WithLabelValues(sub.name).
+				Observe(time.Since(start).Seconds())
+		}()
+		return sub.reconcile(notebook, ctx)
+	}
+
+	if r.SubReconcileConcurrency <= 1 {
+		for _, sub := range subReconcilers {
+			if err := run(sub, notebook, ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	semaphore := make(chan struct{}, r.SubReconcileConcurrency)
+	copies := make([]*nbv1.Notebook, len(subReconcilers))
+	for i, sub := range subReconcilers {
+		copies[i] = notebook.DeepCopy()
+		wg.Add(1)
+		go func(sub subReconciler, notebook *nbv1.Notebook) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-groupCtx.Done():
+				return
+			}
+			if err := run(sub, notebook, groupCtx); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(sub, copies[i])
+	}
+	wg.Wait()
+
+	if err := mergeNotebookCopies(notebook, subReconcilers, copies); err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// mergeNotebookCopies merges back into the notebook the copies changed by the
+// concurrent sub-reconcilers. At most one sub-reconciler may change more than
+// the annotations of the notebook, e.g. update it through the API, its copy is
+// then merged back whole. The annotations added, changed or removed in the
+// other copies, e.g. by a patch, are applied on top.
+func mergeNotebookCopies(notebook *nbv1.Notebook, subReconcilers []subReconciler,
+	copies []*nbv1.Notebook) error {
+	original := notebook.DeepCopy()
+	updated := -1
+	for i, changed := range copies {
+		withoutAnnotations := changed.DeepCopy()
+		withoutAnnotations.Annotations = original.Annotations
+		if equality.Semantic.DeepEqual(withoutAnnotations, original) {
+			continue
+		}
+		if updated >= 0 {
+			return fmt.Errorf("the %s and %s sub-reconcilers both changed the notebook, "+
+				"run them in order with a concurrency of 1", subReconcilers[updated].name, subReconcilers[i].name)
+		}
+		updated = i
+	}
+
+	if updated >= 0 {
+		copies[updated].DeepCopyInto(notebook)
+	}
+	for i, changed := range copies {
+		if i != updated {
+			mergeNotebookAnnotations(notebook, original, changed)
+		}
+	}
+	return nil
+}
+
+// mergeNotebookAnnotations applies to the notebook the annotations added,
+// changed or removed in the changed copy of the original notebook.
+func mergeNotebookAnnotations(notebook, original, changed *nbv1.Notebook) {
+	for key, value := range changed.Annotations {
+		if previous, ok := original.Annotations[key]; !ok || previous != value {
+			if notebook.Annotations == nil {
+				notebook.Annotations = map[string]string{}
+			}
+			notebook.Annotations[key] = value
+		}
+	}
+	for key := range original.Annotations {
+		if _, ok := changed.Annotations[key]; !ok {
+			delete(notebook.Annotations, key)
+		}
+	}
+}
+
+// reconcileTrustedCABundleSubsystem reconciles the ConfigMap of the trusted
+// CA bundle of the notebook.
+func (r *OpenshiftNotebookReconciler) reconcileTrustedCABundleSubsystem(notebook *nbv1.Notebook,
+	ctx context.Context) error {
+	// Create Configmap with the ODH notebook certificate
+	// With the ODH 2.8 Operator, user can provide their own certificate
+	// from DSCI initializer, that provides the certs in a ConfigMap odh-trusted-ca-bundle
+	// create a separate ConfigMap for the notebook which append the user provided certs
+	// with cluster self-signed certs.
+	err := r.CreateNotebookCertConfigMap(notebook, ctx)
+	if err != nil {
+		return err
+	}
+	// If createNotebookCertConfigMap returns nil,
+	// and still the ConfigMap workbench-trusted-ca-bundle is not found,
+	// reconcile notebook to unset the env variable.
+	if r.IsConfigMapDeleted(notebook, ctx) {
+		// Unset the env variable in the notebook
+		return r.UnsetNotebookCertConfig(notebook, ctx)
+	}
+	return nil
+}
+
+// reconcileNetworkPoliciesSubsystem reconciles the NetworkPolicies of the
+// notebook, and the Service of the sidecars declared by the users.
+func (r *OpenshiftNotebookReconciler) reconcileNetworkPoliciesSubsystem(notebook *nbv1.Notebook,
+	ctx context.Context) error {
+	// Call the Network Policies reconciler
+	err := r.ReconcileAllNetworkPolicies(notebook, ctx)
+	if err != nil {
+		return err
+	}
+
+	// Call the reconciler of the sidecars declared by the users (see
+	// notebook_sidecars.go file)
+	return r.ReconcileSidecars(notebook, ctx)
+}
+
+// reconcileRBACSubsystem reconciles the RoleBindings of the notebook.
+func (r *OpenshiftNotebookReconciler) reconcileRBACSubsystem(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	// Call the Rolebinding reconciler
+	if strings.ToLower(strings.TrimSpace(os.Getenv("SET_PIPELINE_RBAC"))) == "true" {
+		err := r.ReconcileRoleBindings(notebook, ctx)
+		if err != nil {
+			log.Error(err, "Unable to Reconcile Rolebinding")
+			return err
+		}
+	} else {
+		err := r.RemoveRoleBindings(notebook, ctx)
+		if err != nil {
+			log.Error(err, "Unable to remove Rolebinding")
+			return err
+		}
+	}
+
+	// Grant the notebook the use of its SCC
+	return r.ReconcileSCCRoleBinding(notebook, ctx)
+}
+
+// reconcileExposureSubsystem reconciles the objects exposing the notebook:
+// the objects of its auth proxy (see notebook_auth_injectors.go file), then
+// the objects of the enabled exposers (see notebook_exposers.go file). The
+// notebooks of the service mesh are exposed by the mesh.
+func (r *OpenshiftNotebookReconciler) reconcileExposureSubsystem(notebook *nbv1.Notebook, ctx context.Context) error {
+	if ServiceMeshIsEnabled(notebook.ObjectMeta) {
+		return nil
+	}
+
+	// Create the objects required by the auth proxy sidecar
+	err := r.ReconcileAuthProvider(notebook, ctx)
+	if err != nil {
+		return err
+	}
+
+	exposers, err := r.enabledExposers()
+	if err != nil {
+		return err
+	}
+	for _, exposer := range exposers {
+		if err := exposer.Expose(ctx, r, notebook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRunSubReconcilers(t *testing.T) {
+	count := func(name string) uint64 {
+		histogram := notebookSubReconcileDurationSeconds.WithLabelValues(name)
+		return metricValue(t, histogram.(prometheus.Metric)).GetHistogram().GetSampleCount()
+	}
+	newNotebook := func() *nbv1.Notebook {
+		return &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+			Annotations: map[string]string{"keep": "true", "removed": "true"}}}
+	}
+
+	for _, concurrency := range []int{DefaultSubReconcileConcurrency, 4} {
+		r := newTestReconciler(t, OAuthConfig{})
+		r.SubReconcileConcurrency = concurrency
+		notebook := newNotebook()
+		before := count("test-annotate")
+		err := r.runSubReconcilers(notebook, context.Background(), []subReconciler{
+			{name: "test-annotate", reconcile: func(notebook *nbv1.Notebook, ctx context.Context) error {
+				notebook.Annotations["added"] = "true"
+				return nil
+			}},
+			{name: "test-remove", reconcile: func(notebook *nbv1.Notebook, ctx context.Context) error {
+				delete(notebook.Annotations, "removed")
+				return nil
+			}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"keep": "true", "added": "true"}, notebook.Annotations, concurrency)
+		assert.Equal(t, before+1, count("test-annotate"))
+	}
+
+	// The first error is returned and cancels the sub-reconcilers still
+	// running
+	r := newTestReconciler(t, OAuthConfig{})
+	r.SubReconcileConcurrency = 4
+	failed := errors.New("failed")
+	err := r.runSubReconcilers(newNotebook(), context.Background(), []subReconciler{
+		{name: "test-fail", reconcile: func(notebook *nbv1.Notebook, ctx context.Context) error {
+			return failed
+		}},
+		{name: "test-wait", reconcile: func(notebook *nbv1.Notebook, ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	})
+	assert.Equal(t, failed, err)
+}
+
+func TestMergeNotebookCopies(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", ResourceVersion: "1",
+		Annotations: map[string]string{"keep": "true"}}}
+	subReconcilers := []subReconciler{{name: "test-update"}, {name: "test-annotate"}, {name: "test-noop"}}
+
+	// The copy changed beyond its annotations is merged back whole
+	updated := notebook.DeepCopy()
+	updated.ResourceVersion = "2"
+	updated.Spec.Template.Spec.ServiceAccountName = "sa"
+	annotated := notebook.DeepCopy()
+	annotated.Annotations["added"] = "true"
+	require.NoError(t, mergeNotebookCopies(notebook, subReconcilers,
+		[]*nbv1.Notebook{updated, annotated, notebook.DeepCopy()}))
+	assert.Equal(t, "2", notebook.ResourceVersion)
+	assert.Equal(t, "sa", notebook.Spec.Template.Spec.ServiceAccountName)
+	assert.Equal(t, map[string]string{"keep": "true", "added": "true"}, notebook.Annotations)
+
+	// The concurrent changes of the notebook beyond its annotations conflict
+	first, second := notebook.DeepCopy(), notebook.DeepCopy()
+	first.Labels = map[string]string{"first": "true"}
+	second.Spec.Template.Spec.ServiceAccountName = "other"
+	err := mergeNotebookCopies(notebook, subReconcilers, []*nbv1.Notebook{first, second, notebook.DeepCopy()})
+	assert.ErrorContains(t, err, "the test-update and test-annotate sub-reconcilers both changed the notebook")
+	assert.Equal(t, "sa", notebook.Spec.Template.Spec.ServiceAccountName)
+}
@@ -0,0 +1,311 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nbv1alpha1 "github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/api/v1alpha1"
+)
+
+const (
+	// AnnotationTemplateRequest holds the UID of the NotebookTemplateRequest
+	// that created the notebook.
+	AnnotationTemplateRequest = "notebooks.opendatahub.io/template-request"
+	// AnnotationDisplayName is the name of the notebook displayed by the
+	// dashboard.
+	AnnotationDisplayName = "openshift.io/display-name"
+)
+
+// templateExcludedAnnotations are the annotations of the source notebook
+// describing its state rather than its configuration, which are not copied
+// to the new notebook. The controller-owned annotations are not copied
+// either.
+var templateExcludedAnnotations = []string{
+	culler.STOP_ANNOTATION,
+	culler.LAST_ACTIVITY_ANNOTATION,
+	AnnotationNotebookRestart,
+	AnnotationReconcile,
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// NotebookTemplateRequestReconciler creates the notebooks requested by the
+// NotebookTemplateRequest objects.
+type NotebookTemplateRequestReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// DefaultStorageClass provisions the fresh claims whose source claim has
+	// no StorageClass, the default StorageClass of the cluster if empty.
+	DefaultStorageClass string
+}
+
+// +kubebuilder:rbac:groups=notebooks.opendatahub.io,resources=notebooktemplaterequests,verbs=get;list;watch
+// +kubebuilder:rbac:groups=notebooks.opendatahub.io,resources=notebooktemplaterequests/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=create
+// +kubebuilder:rbac:groups="storage.k8s.io",resources=storageclasses,verbs=get;list;watch
+
+// templateClaimName returns the name of the fresh claim replacing the given
+// claim volume of the source notebook. The workspace claim, named after the
+// source notebook, is named after the new notebook.
+func templateClaimName(source *nbv1.Notebook, name string, volume corev1.Volume) string {
+	if volume.PersistentVolumeClaim.ClaimName == source.Name {
+		return name
+	}
+	return name + "-" + volume.Name
+}
+
+// NewNotebookFromTemplate returns a new notebook with the configuration of
+// the source notebook, i.e. the same image, resources and data connections.
+// The claims map the claims of the source notebook to the ones of the new
+// notebook, the claims not mapped are shared by both notebooks.
+func NewNotebookFromTemplate(source *nbv1.Notebook, name string, claims map[string]string) *nbv1.Notebook {
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   source.Namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+
+	// The labels naming the source notebook name the new one
+	for key, value := range source.Labels {
+		if value == source.Name {
+			value = name
+		}
+		notebook.Labels[key] = value
+	}
+	for key, value := range source.Annotations {
+		notebook.Annotations[key] = value
+	}
+	for key := range controllerAnnotations {
+		delete(notebook.Annotations, key)
+	}
+	for _, key := range templateExcludedAnnotations {
+		delete(notebook.Annotations, key)
+	}
+
+	// The notebook container and base URL are named after the notebook
+	sourceURL := "/notebook/" + source.Namespace + "/" + source.Name
+	notebookURL := "/notebook/" + notebook.Namespace + "/" + name
+	podSpec := &notebook.Spec.Template.Spec
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != source.Name {
+			continue
+		}
+		container.Name = name
+		for j := range container.Env {
+			container.Env[j].Value = strings.ReplaceAll(container.Env[j].Value, sourceURL, notebookURL)
+		}
+	}
+
+	for i := range podSpec.Volumes {
+		volume := &podSpec.Volumes[i]
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		if claim, ok := claims[volume.PersistentVolumeClaim.ClaimName]; ok {
+			volume.PersistentVolumeClaim.ClaimName = claim
+		}
+	}
+	return notebook
+}
+
+// NewTemplateClaim returns a fresh claim with the storage settings of the
+// source claim, for the given notebook.
+func NewTemplateClaim(source *corev1.PersistentVolumeClaim, name string,
+	notebook *nbv1.Notebook) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: notebook.Namespace,
+			Labels:    NotebookObjectLabels(notebook, ComponentStorage),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      source.Spec.AccessModes,
+			Resources:        *source.Spec.Resources.DeepCopy(),
+			StorageClassName: source.Spec.StorageClassName,
+			VolumeMode:       source.Spec.VolumeMode,
+		},
+	}
+}
+
+// Reconcile creates the notebook of the template request, and its fresh
+// claims, once.
+func (r *NotebookTemplateRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("notebooktemplaterequest", req.Name, "namespace", req.Namespace)
+
+	request := &nbv1alpha1.NotebookTemplateRequest{}
+	err := r.Get(ctx, req.NamespacedName, request)
+	if apierrs.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the NotebookTemplateRequest")
+		return ctrl.Result{}, err
+	}
+	if meta.IsStatusConditionTrue(request.Status.Conditions, nbv1alpha1.ConditionNotebookCreated) {
+		return ctrl.Result{}, nil
+	}
+
+	name := request.Spec.NotebookName
+	if name == "" {
+		name = request.Name
+	}
+	request.Status.NotebookName = name
+	log = log.WithValues("notebook", name)
+
+	source := &nbv1.Notebook{}
+	err = r.Get(ctx, types.NamespacedName{Name: request.Spec.SourceNotebook, Namespace: request.Namespace}, source)
+	if apierrs.IsNotFound(err) {
+		return ctrl.Result{}, r.updateStatus(ctx, request, metav1.ConditionFalse, "SourceNotFound",
+			fmt.Sprintf("The notebook %s does not exist", request.Spec.SourceNotebook))
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the source notebook")
+		return ctrl.Result{}, err
+	}
+
+	// Replace the single-node claims of the source notebook, the claims
+	// attached to many nodes are shared by both notebooks
+	sourceClaims := map[string]*corev1.PersistentVolumeClaim{}
+	claims := map[string]string{}
+	for _, volume := range source.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Get(ctx, types.NamespacedName{
+			Name:      volume.PersistentVolumeClaim.ClaimName,
+			Namespace: source.Namespace,
+		}, pvc)
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, r.updateStatus(ctx, request, metav1.ConditionFalse, "SourceClaimNotFound",
+				fmt.Sprintf("The claim %s of the notebook %s does not exist",
+					volume.PersistentVolumeClaim.ClaimName, source.Name))
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+		if volume.PersistentVolumeClaim.ClaimName == source.Name || singleNodeAccessMode(pvc) {
+			claims[pvc.Name] = templateClaimName(source, name, volume)
+			sourceClaims[pvc.Name] = pvc
+		}
+	}
+
+	// The fresh claims without StorageClass are provisioned by the default
+	// StorageClass of the controller, which must exist
+	for _, pvc := range sourceClaims {
+		if r.DefaultStorageClass == "" || !withoutStorageClass(pvc) {
+			continue
+		}
+		err := r.Get(ctx, types.NamespacedName{Name: r.DefaultStorageClass}, &storagev1.StorageClass{})
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, r.updateStatus(ctx, request, metav1.ConditionFalse, "StorageClassNotFound",
+				fmt.Sprintf("The default StorageClass %s of the fresh claims does not exist", r.DefaultStorageClass))
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+		break
+	}
+
+	notebook := NewNotebookFromTemplate(source, name, claims)
+	notebook.Annotations[AnnotationTemplateRequest] = string(request.UID)
+	if request.Spec.DisplayName != "" {
+		notebook.Annotations[AnnotationDisplayName] = request.Spec.DisplayName
+	}
+	err = r.Create(ctx, notebook)
+	if apierrs.IsAlreadyExists(err) {
+		// The notebook was created by a previous reconcile of the request
+		if err := r.Get(ctx, client.ObjectKeyFromObject(notebook), notebook); err != nil {
+			return ctrl.Result{}, err
+		}
+		if notebook.Annotations[AnnotationTemplateRequest] != string(request.UID) {
+			return ctrl.Result{}, r.updateStatus(ctx, request, metav1.ConditionFalse, "NotebookExists",
+				fmt.Sprintf("The notebook %s already exists", name))
+		}
+	} else if err != nil {
+		log.Error(err, "Unable to create the notebook")
+		return ctrl.Result{}, err
+	} else {
+		log.Info("Created the notebook", "source", source.Name)
+	}
+
+	// Create the fresh claims, the notebook pod is pending until they are
+	// created
+	request.Status.Claims = []string{}
+	for sourceName, claim := range claims {
+		pvc := NewTemplateClaim(sourceClaims[sourceName], claim, notebook)
+		if r.DefaultStorageClass != "" && withoutStorageClass(pvc) {
+			pvc.Spec.StorageClassName = &r.DefaultStorageClass
+		}
+		err := r.Create(ctx, pvc)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the notebook claim", "claim", claim)
+			return ctrl.Result{}, err
+		}
+		request.Status.Claims = append(request.Status.Claims, claim)
+	}
+	sort.Strings(request.Status.Claims)
+
+	r.Recorder.Eventf(request, corev1.EventTypeNormal, "NotebookCreated",
+		"Created the notebook %s from the notebook %s", name, source.Name)
+	return ctrl.Result{}, r.updateStatus(ctx, request, metav1.ConditionTrue, "NotebookCreated",
+		fmt.Sprintf("Created the notebook %s from the notebook %s", name, source.Name))
+}
+
+// updateStatus sets the NotebookCreated condition of the request.
+func (r *NotebookTemplateRequestReconciler) updateStatus(ctx context.Context,
+	request *nbv1alpha1.NotebookTemplateRequest, status metav1.ConditionStatus, reason, message string) error {
+	meta.SetStatusCondition(&request.Status.Conditions, metav1.Condition{
+		Type:               nbv1alpha1.ConditionNotebookCreated,
+		Status:             status,
+		ObservedGeneration: request.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if err := r.Status().Update(ctx, request); err != nil {
+		r.Log.Error(err, "Unable to update the NotebookTemplateRequest status",
+			"notebooktemplaterequest", request.Name, "namespace", request.Namespace)
+		return err
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NotebookTemplateRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nbv1alpha1.NotebookTemplateRequest{}).
+		Complete(r)
+}
@@ -0,0 +1,215 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	nbv1alpha1 "github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/api/v1alpha1"
+)
+
+func newTestTemplateReconciler(t *testing.T, objects ...client.Object) *NotebookTemplateRequestReconciler {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, nbv1.AddToScheme(scheme))
+	require.NoError(t, nbv1alpha1.AddToScheme(scheme))
+	return &NotebookTemplateRequestReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+			WithStatusSubresource(&nbv1alpha1.NotebookTemplateRequest{}).Build(),
+		Scheme:   scheme,
+		Log:      logr.Discard(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
+
+func newTestClaim(name string, mode corev1.PersistentVolumeAccessMode) *corev1.PersistentVolumeClaim {
+	storageClass := "gp3"
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{mode},
+			StorageClassName: &storageClass,
+			Resources: corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse("20Gi"),
+			}},
+			VolumeName: "pv-" + name,
+		},
+	}
+}
+
+func TestReconcileNotebookTemplateRequest(t *testing.T) {
+	ctx := context.Background()
+	source := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+			Labels: map[string]string{"app": "nb", "opendatahub.io/dashboard": "true"},
+			Annotations: map[string]string{
+				AnnotationInjectOAuth:           "true",
+				AnnotationCreator:               "alice",
+				culler.STOP_ANNOTATION:          "2024-01-01T00:00:00Z",
+				culler.LAST_ACTIVITY_ANNOTATION: "2024-01-01T00:00:00Z",
+			}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "nb",
+				Image: "quay.io/jupyter:2024.1",
+				Env:   []corev1.EnvVar{{Name: "NOTEBOOK_ARGS", Value: "--ServerApp.base_url=/notebook/ns/nb"}},
+				EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "aws-connection-data"},
+				}}},
+			}},
+			Volumes: []corev1.Volume{
+				{Name: "nb", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "nb"}}},
+				{Name: "scratch", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "nb-scratch"}}},
+				{Name: "datasets", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "datasets"}}},
+			},
+		}}},
+	}
+	request := &nbv1alpha1.NotebookTemplateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb-copy", Namespace: "ns", UID: "request-uid"},
+		Spec:       nbv1alpha1.NotebookTemplateRequestSpec{SourceNotebook: "nb", DisplayName: "Copy of nb"},
+	}
+	r := newTestTemplateReconciler(t, source, request,
+		newTestClaim("nb", corev1.ReadWriteOnce),
+		newTestClaim("nb-scratch", corev1.ReadWriteOnce),
+		newTestClaim("datasets", corev1.ReadWriteMany),
+	)
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(request)})
+	require.NoError(t, err)
+
+	notebook := &nbv1.Notebook{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Name: "nb-copy", Namespace: "ns"}, notebook))
+	assert.Equal(t, map[string]string{"app": "nb-copy", "opendatahub.io/dashboard": "true"}, notebook.Labels)
+	assert.Equal(t, map[string]string{
+		AnnotationInjectOAuth:     "true",
+		AnnotationTemplateRequest: "request-uid",
+		AnnotationDisplayName:     "Copy of nb",
+	}, notebook.Annotations)
+	container := notebook.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "nb-copy", container.Name)
+	assert.Equal(t, "quay.io/jupyter:2024.1", container.Image)
+	assert.Equal(t, "--ServerApp.base_url=/notebook/ns/nb-copy", container.Env[0].Value)
+	assert.Equal(t, source.Spec.Template.Spec.Containers[0].EnvFrom, container.EnvFrom)
+	claimNames := []string{}
+	for _, volume := range notebook.Spec.Template.Spec.Volumes {
+		claimNames = append(claimNames, volume.PersistentVolumeClaim.ClaimName)
+	}
+	assert.Equal(t, []string{"nb-copy", "nb-copy-scratch", "datasets"}, claimNames)
+
+	// The single-node claims are replaced by fresh claims
+	for _, name := range []string{"nb-copy", "nb-copy-scratch"} {
+		pvc := &corev1.PersistentVolumeClaim{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Name: name, Namespace: "ns"}, pvc))
+		assert.Equal(t, "gp3", *pvc.Spec.StorageClassName)
+		assert.Empty(t, pvc.Spec.VolumeName)
+		assert.Equal(t, resource.MustParse("20Gi"), pvc.Spec.Resources.Requests[corev1.ResourceStorage])
+		assert.Equal(t, ManagedByValue, pvc.Labels[LabelManagedBy])
+		assert.Equal(t, "nb-copy", pvc.Labels[LabelNotebookName])
+	}
+
+	found := &nbv1alpha1.NotebookTemplateRequest{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(request), found))
+	assert.True(t, meta.IsStatusConditionTrue(found.Status.Conditions, nbv1alpha1.ConditionNotebookCreated))
+	assert.Equal(t, "nb-copy", found.Status.NotebookName)
+	assert.Equal(t, []string{"nb-copy", "nb-copy-scratch"}, found.Status.Claims)
+}
+
+func TestReconcileNotebookTemplateRequestConflicts(t *testing.T) {
+	ctx := context.Background()
+	existing := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "taken", Namespace: "ns"}}
+	source := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	missingSource := &nbv1alpha1.NotebookTemplateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "ns"},
+		Spec:       nbv1alpha1.NotebookTemplateRequestSpec{SourceNotebook: "missing"},
+	}
+	nameTaken := &nbv1alpha1.NotebookTemplateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "copy", Namespace: "ns", UID: "request-uid"},
+		Spec:       nbv1alpha1.NotebookTemplateRequestSpec{SourceNotebook: "nb", NotebookName: "taken"},
+	}
+	r := newTestTemplateReconciler(t, existing, source, missingSource, nameTaken)
+
+	for request, reason := range map[*nbv1alpha1.NotebookTemplateRequest]string{
+		missingSource: "SourceNotFound",
+		nameTaken:     "NotebookExists",
+	} {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(request)})
+		require.NoError(t, err)
+		found := &nbv1alpha1.NotebookTemplateRequest{}
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(request), found))
+		condition := meta.FindStatusCondition(found.Status.Conditions, nbv1alpha1.ConditionNotebookCreated)
+		require.NotNil(t, condition)
+		assert.Equal(t, metav1.ConditionFalse, condition.Status)
+		assert.Equal(t, reason, condition.Reason)
+	}
+}
+
+func TestReconcileNotebookTemplateRequestDefaultStorageClass(t *testing.T) {
+	ctx := context.Background()
+	source := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	source.Spec.Template.Spec.Volumes = []corev1.Volume{{Name: "nb", VolumeSource: corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "nb"}}}}
+	claim := newTestClaim("nb", corev1.ReadWriteOnce)
+	claim.Spec.StorageClassName = nil
+	request := &nbv1alpha1.NotebookTemplateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb-copy", Namespace: "ns", UID: "request-uid"},
+		Spec:       nbv1alpha1.NotebookTemplateRequestSpec{SourceNotebook: "nb"},
+	}
+	r := newTestTemplateReconciler(t, source, claim, request)
+	r.DefaultStorageClass = "nfs"
+
+	// The default StorageClass must exist
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(request)})
+	require.NoError(t, err)
+	found := &nbv1alpha1.NotebookTemplateRequest{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(request), found))
+	condition := meta.FindStatusCondition(found.Status.Conditions, nbv1alpha1.ConditionNotebookCreated)
+	require.NotNil(t, condition)
+	assert.Equal(t, "StorageClassNotFound", condition.Reason)
+	err = r.Get(ctx, client.ObjectKey{Name: "nb-copy", Namespace: "ns"}, &nbv1.Notebook{})
+	assert.True(t, apierrs.IsNotFound(err))
+
+	// The fresh claims without StorageClass are provisioned by the default
+	// StorageClass
+	require.NoError(t, r.Create(ctx, &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "nfs"},
+		Provisioner: "nfs.csi.k8s.io"}))
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(request)})
+	require.NoError(t, err)
+	pvc := &corev1.PersistentVolumeClaim{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Name: "nb-copy", Namespace: "ns"}, pvc))
+	assert.Equal(t, "nfs", *pvc.Spec.StorageClassName)
+}
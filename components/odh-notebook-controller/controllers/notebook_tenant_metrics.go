@@ -0,0 +1,81 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The states of the notebooks counted by the odh_notebooks metric.
+const (
+	NotebookStateRunning    = "running"
+	NotebookStateStarting   = "starting"
+	NotebookStateStopped    = "stopped"
+	NotebookStateHibernated = "hibernated"
+)
+
+// NotebookState returns the state of the notebook reported in the tenant
+// metrics.
+func NotebookState(notebook *nbv1.Notebook) string {
+	switch {
+	case metav1.HasAnnotation(notebook.ObjectMeta, AnnotationHibernated):
+		return NotebookStateHibernated
+	case notebookIsStopped(notebook.ObjectMeta):
+		return NotebookStateStopped
+	case notebook.Status.ReadyReplicas == 0:
+		return NotebookStateStarting
+	default:
+		return NotebookStateRunning
+	}
+}
+
+// ObserveSpawnDuration observes the time the notebook took to be ready since
+// it was started. Only the starts seen by the controller are observed, so that
+// the notebooks already running when the controller starts are not.
+func (r *OpenshiftNotebookReconciler) ObserveSpawnDuration(notebook *nbv1.Notebook) {
+	key := client.ObjectKeyFromObject(notebook)
+	if notebookIsStarting(notebook) {
+		since, err := time.Parse(time.RFC3339, notebook.GetAnnotations()[AnnotationRunningSince])
+		if err == nil {
+			r.spawnStarts.LoadOrStore(key, since)
+		}
+		return
+	}
+	start, ok := r.spawnStarts.LoadAndDelete(key)
+	if !ok || notebook.Status.ReadyReplicas == 0 {
+		return
+	}
+	notebookSpawnDurationSeconds.WithLabelValues(notebook.Namespace).
+		Observe(time.Since(start.(time.Time)).Seconds())
+}
+
+// aggregateTenantMetrics updates the per-namespace state metrics of the
+// notebooks.
+func aggregateTenantMetrics(notebooks []nbv1.Notebook) {
+	notebooksTotal.Reset()
+	notebookUpdatesPending.Reset()
+	for i := range notebooks {
+		notebook := &notebooks[i]
+		notebooksTotal.WithLabelValues(notebook.Namespace, NotebookState(notebook)).Inc()
+		if metav1.HasAnnotation(notebook.ObjectMeta, AnnotationUpdatePending) {
+			notebookUpdatesPending.WithLabelValues(notebook.Namespace).Inc()
+		}
+	}
+}
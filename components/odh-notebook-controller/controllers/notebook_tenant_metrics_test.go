@@ -0,0 +1,77 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestObserveSpawnDuration(t *testing.T) {
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns-spawn"}}
+	r := newTestReconciler(t, OAuthConfig{})
+	count := func() uint64 {
+		histogram := notebookSpawnDurationSeconds.WithLabelValues("ns-spawn")
+		return metricValue(t, histogram.(prometheus.Metric)).GetHistogram().GetSampleCount()
+	}
+
+	// The notebooks already running are not observed
+	notebook.Status.ReadyReplicas = 1
+	r.ObserveSpawnDuration(notebook)
+	assert.Equal(t, uint64(0), count())
+
+	// The starts are observed once the notebook is ready
+	notebook.Status.ReadyReplicas = 0
+	notebook.Annotations = map[string]string{
+		AnnotationRunningSince: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+	}
+	r.ObserveSpawnDuration(notebook)
+	assert.Equal(t, uint64(0), count())
+	notebook.Status.ReadyReplicas = 1
+	r.ObserveSpawnDuration(notebook)
+	r.ObserveSpawnDuration(notebook)
+	assert.Equal(t, uint64(1), count())
+}
+
+func TestAggregateTenantMetrics(t *testing.T) {
+	notebook := func(name string, ready int32, annotations map[string]string) nbv1.Notebook {
+		return nbv1.Notebook{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns-tenant", Annotations: annotations},
+			Status:     nbv1.NotebookStatus{ReadyReplicas: ready},
+		}
+	}
+	aggregateTenantMetrics([]nbv1.Notebook{
+		notebook("running", 1, map[string]string{AnnotationUpdatePending: "{}"}),
+		notebook("starting", 0, nil),
+		notebook("stopped", 0, map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"}),
+		notebook("hibernated", 0, map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z",
+			AnnotationHibernated: "2024-01-01T00:00:00Z"}),
+		notebook("running-2", 1, nil),
+	})
+	for state, expected := range map[string]float64{
+		NotebookStateRunning: 2, NotebookStateStarting: 1, NotebookStateStopped: 1, NotebookStateHibernated: 1,
+	} {
+		assert.Equal(t, expected, metricValue(t, notebooksTotal.WithLabelValues("ns-tenant", state)).GetGauge().GetValue(),
+			state)
+	}
+	assert.Equal(t, 1.0, metricValue(t, notebookUpdatesPending.WithLabelValues("ns-tenant")).GetGauge().GetValue())
+}
@@ -0,0 +1,211 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// TrustedCABundleConfigMapName is the ConfigMap holding the CA bundle
+	// provided by the ODH operator in each namespace.
+	TrustedCABundleConfigMapName = "odh-trusted-ca-bundle"
+	// WorkbenchTrustedCABundleConfigMapName is the ConfigMap created by the
+	// controller and mounted by the notebooks of the namespace.
+	WorkbenchTrustedCABundleConfigMapName = "workbench-trusted-ca-bundle"
+)
+
+// Defaults of the TrustedCABundleConfig settings.
+const (
+	DefaultTrustedCABundleConcurrency   = 1
+	DefaultTrustedCABundleCoalesceDelay = 5 * time.Second
+	DefaultTrustedCABundleQPS           = 5
+)
+
+// TrustedCABundleConfig holds the settings of the reconciles of the trusted CA
+// bundles. When the operator rotates the cluster trust bundle, the ConfigMaps
+// of all the namespaces change at once: the namespaces are reconciled by a
+// dedicated work queue, so the rotation does not starve the notebook
+// reconciles.
+type TrustedCABundleConfig struct {
+	// Concurrency is the number of namespaces reconciled in parallel.
+	Concurrency int
+	// CoalesceDelay delays the reconcile of a namespace, the changes of its
+	// CA bundle ConfigMaps within the delay are reconciled once.
+	CoalesceDelay time.Duration
+	// QPS is the maximum number of namespaces reconciled per second. Not
+	// limited if 0.
+	QPS float64
+}
+
+// trustedCABundleLabels returns the labels of the workbench-trusted-ca-bundle
+// ConfigMaps, labeled as created by the controller to be kept in the
+// restricted cache of the manager.
+func trustedCABundleLabels() map[string]string {
+	return map[string]string{
+		"opendatahub.io/managed-by": "workbenches",
+		LabelManagedBy:              ManagedByValue,
+	}
+}
+
+// trustedCABundleRequest returns the request reconciling the trusted CA
+// bundle of the namespace. The requests are only keyed by namespace, so the
+// work queue deduplicates the changes of the ConfigMaps of a namespace.
+func trustedCABundleRequest(namespace string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace}}
+}
+
+// trustedCABundleHandler enqueues the namespace of the changed trusted CA
+// bundle ConfigMaps once the coalesce delay has elapsed.
+type trustedCABundleHandler struct {
+	delay time.Duration
+}
+
+func (h trustedCABundleHandler) enqueue(object client.Object, q workqueue.RateLimitingInterface) {
+	switch object.GetName() {
+	case TrustedCABundleConfigMapName, WorkbenchTrustedCABundleConfigMapName:
+		// The delaying queue keeps a single occurrence of the namespaces
+		// waiting to be added
+		q.AddAfter(trustedCABundleRequest(object.GetNamespace()), h.delay)
+	}
+}
+
+func (h trustedCABundleHandler) Create(_ context.Context, e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.Object, q)
+}
+
+func (h trustedCABundleHandler) Update(_ context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.ObjectNew, q)
+}
+
+func (h trustedCABundleHandler) Delete(_ context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.Object, q)
+}
+
+func (h trustedCABundleHandler) Generic(_ context.Context, e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.Object, q)
+}
+
+// ReconcileTrustedCABundle reconciles the workbench-trusted-ca-bundle
+// ConfigMap of a namespace, and unsets the CA bundle of the notebooks mounting
+// it once it is deleted.
+func (r *OpenshiftNotebookReconciler) ReconcileTrustedCABundle(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Initialize logger format
+	log := r.Log.WithValues("namespace", req.Namespace)
+
+	if r.trustedCABundleLimiter != nil {
+		if err := r.trustedCABundleLimiter.Wait(ctx); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	notebooks := &nbv1.NotebookList{}
+	err := r.List(ctx, notebooks, client.InNamespace(req.Namespace))
+	if err != nil {
+		log.Error(err, "Unable to list the Notebooks when handling the trusted CA bundle change")
+		return ctrl.Result{}, err
+	}
+	if len(notebooks.Items) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	// As there is only one ConfigMap workbench-trusted-ca-bundle per
+	// namespace, shared by all the notebooks, it is created for the first one
+	err = r.CreateNotebookCertConfigMap(&notebooks.Items[0], ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	for i := range notebooks.Items {
+		notebook := &notebooks.Items[i]
+		if r.IsConfigMapDeleted(notebook, ctx) {
+			err = r.UnsetNotebookCertConfig(notebook, ctx)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setupTrustedCABundleWithManager sets up the controller of the trusted CA
+// bundles with the Manager.
+func (r *OpenshiftNotebookReconciler) setupTrustedCABundleWithManager(mgr ctrl.Manager) error {
+	config := r.TrustedCABundleConfig
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultTrustedCABundleConcurrency
+	}
+	if config.QPS > 0 {
+		r.trustedCABundleLimiter = rate.NewLimiter(rate.Limit(config.QPS), 1)
+	}
+
+	handler := trustedCABundleHandler{delay: config.CoalesceDelay}
+	builder := ctrl.NewControllerManagedBy(mgr).
+		Named("trusted-ca-bundle").
+		Watches(&corev1.ConfigMap{}, handler).
+		WithOptions(controller.Options{MaxConcurrentReconciles: config.Concurrency})
+	if r.TrustedCABundleCache != nil {
+		builder = builder.WatchesRawSource(source.Kind(r.TrustedCABundleCache, &corev1.ConfigMap{}), handler)
+	}
+	return builder.Complete(reconcile.Func(r.ReconcileTrustedCABundle))
+}
+
+// reportTrustedCABundleTampering reports the changes of the
+// workbench-trusted-ca-bundle ConfigMap by other field managers, which could
+// make the notebooks of the namespace trust arbitrary certificates. The
+// changes are reverted by the caller.
+func (r *OpenshiftNotebookReconciler) reportTrustedCABundleTampering(configMap *corev1.ConfigMap) {
+	fieldManager := fieldManagerOrDefault(r.FieldManager)
+	found := map[string]bool{}
+	managers := []string{}
+	for _, path := range []string{"f:data", "f:binaryData"} {
+		for _, manager := range ConflictingFieldManagers(configMap, fieldManager, path) {
+			if !found[manager] {
+				found[manager] = true
+				managers = append(managers, manager)
+			}
+		}
+	}
+	if len(managers) == 0 {
+		return
+	}
+	sort.Strings(managers)
+	for _, manager := range managers {
+		notebookTrustedCABundleRevertsTotal.WithLabelValues(configMap.Namespace, manager).Inc()
+	}
+	r.Log.Info("Reverting the changes of the trusted CA bundle", "namespace", configMap.Namespace,
+		"managers", managers)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, "TrustedCABundleTampered",
+			"The ConfigMap %s was changed by %s, the changes are reverted", configMap.Name,
+			strings.Join(managers, ", "))
+	}
+}
@@ -0,0 +1,137 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const testCACertificate = "-----BEGIN CERTIFICATE-----\nMIGrMF+gAwIBAgIBATAFBgMrZXAwADAeFw0yNDExMTMyMzI3MzdaFw0yNTExMTMy\nMzI3MzdaMAAwKjAFBgMrZXADIQDEMMlJ1P0gyxEV7A8PgpNosvKZgE4ttDDpu/w9\n35BHzjAFBgMrZXADQQDHT8ulalOcI6P5lGpoRcwLzpa4S/5pyqtbqw2zuj7dIJPI\ndNb1AkbARd82zc9bF+7yDkCNmLIHSlDORUYgTNEL\n-----END CERTIFICATE-----"
+
+func TestTrustedCABundleHandler(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+	configMap := func(name, namespace string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+
+	handler := trustedCABundleHandler{}
+	ctx := context.Background()
+	handler.Update(ctx, event.UpdateEvent{ObjectNew: configMap(TrustedCABundleConfigMapName, "ns1")}, queue)
+	handler.Update(ctx, event.UpdateEvent{ObjectNew: configMap(TrustedCABundleConfigMapName, "ns1")}, queue)
+	handler.Create(ctx, event.CreateEvent{Object: configMap(WorkbenchTrustedCABundleConfigMapName, "ns1")}, queue)
+	handler.Delete(ctx, event.DeleteEvent{Object: configMap(WorkbenchTrustedCABundleConfigMapName, "ns2")}, queue)
+	handler.Create(ctx, event.CreateEvent{Object: configMap("other", "ns3")}, queue)
+
+	// The changes of a namespace are reconciled once
+	require.Equal(t, 2, queue.Len())
+	first, _ := queue.Get()
+	second, _ := queue.Get()
+	assert.ElementsMatch(t, []interface{}{trustedCABundleRequest("ns1"), trustedCABundleRequest("ns2")},
+		[]interface{}{first, second})
+}
+
+func TestReconcileTrustedCABundle(t *testing.T) {
+	ctx := context.Background()
+	odhConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: TrustedCABundleConfigMapName, Namespace: "ns1"},
+		Data:       map[string]string{"ca-bundle.crt": testCACertificate, "odh-ca-bundle.crt": ""},
+	}
+	notebook := func(name, namespace string) *nbv1.Notebook {
+		return &nbv1.Notebook{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:         name,
+					Env:          []corev1.EnvVar{{Name: "SSL_CERT_FILE", Value: "/etc/pki/tls/custom-certs/ca-bundle.crt"}},
+					VolumeMounts: []corev1.VolumeMount{{Name: "trusted-ca", MountPath: "/etc/pki/tls/custom-certs"}},
+				}},
+				Volumes: []corev1.Volume{{
+					Name: "trusted-ca",
+					VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: WorkbenchTrustedCABundleConfigMapName},
+					}},
+				}},
+			}}},
+		}
+	}
+	r := newTestReconciler(t, OAuthConfig{}, odhConfigMap, notebook("nb1", "ns1"), notebook("nb2", "ns1"),
+		notebook("nb3", "ns2"))
+
+	// The workbench-trusted-ca-bundle ConfigMap is created from the CA bundle
+	_, err := r.ReconcileTrustedCABundle(ctx, trustedCABundleRequest("ns1"))
+	require.NoError(t, err)
+	workbenchConfigMap := &corev1.ConfigMap{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Name: WorkbenchTrustedCABundleConfigMapName, Namespace: "ns1"},
+		workbenchConfigMap))
+	assert.Equal(t, testCACertificate, workbenchConfigMap.Data["ca-bundle.crt"])
+
+	// The CA bundle is unset from the notebooks once the ConfigMap is deleted
+	_, err = r.ReconcileTrustedCABundle(ctx, trustedCABundleRequest("ns2"))
+	require.NoError(t, err)
+	found := &nbv1.Notebook{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Name: "nb3", Namespace: "ns2"}, found))
+	assert.Empty(t, found.Spec.Template.Spec.Volumes)
+	assert.Empty(t, found.Spec.Template.Spec.Containers[0].Env)
+	assert.Empty(t, found.Spec.Template.Spec.Containers[0].VolumeMounts)
+
+	// Nothing is reconciled in the namespaces without notebooks
+	_, err = r.ReconcileTrustedCABundle(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "ns3"}})
+	require.NoError(t, err)
+}
+
+func TestTrustedCABundleTamperingIsReverted(t *testing.T) {
+	ctx := context.Background()
+	odhConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: TrustedCABundleConfigMapName, Namespace: "ns"},
+		Data:       map[string]string{"ca-bundle.crt": testCACertificate, "odh-ca-bundle.crt": ""},
+	}
+	workbenchConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: WorkbenchTrustedCABundleConfigMapName, Namespace: "ns",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry(DefaultFieldManager, 0, `{"f:data":{}}`),
+				managedFieldsEntry("kubectl-edit", 10, `{"f:data":{"f:ca-bundle.crt":{}}}`),
+			}},
+		Data: map[string]string{"ca-bundle.crt": testCACertificate + "\n" + testCACertificate},
+	}
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	r := newTestReconciler(t, OAuthConfig{}, odhConfigMap, workbenchConfigMap, notebook)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	// The changes of the other field managers are reverted and reported
+	_, err := r.ReconcileTrustedCABundle(ctx, trustedCABundleRequest("ns"))
+	require.NoError(t, err)
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(workbenchConfigMap), workbenchConfigMap))
+	assert.Equal(t, map[string]string{"ca-bundle.crt": testCACertificate}, workbenchConfigMap.Data)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "TrustedCABundleTampered The ConfigMap workbench-trusted-ca-bundle "+
+		"was changed by kubectl-edit")
+	assert.Equal(t, 1.0, metricValue(t,
+		notebookTrustedCABundleRevertsTotal.WithLabelValues("ns", "kubectl-edit")).GetCounter().GetValue())
+}
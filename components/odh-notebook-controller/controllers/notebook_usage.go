@@ -0,0 +1,185 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationRunningHours is set by the controller to the cumulative hours
+	// the notebook ran, up to its last stop.
+	AnnotationRunningHours = "notebooks.opendatahub.io/running-hours"
+	// AnnotationGPUHours is set by the controller to the cumulative GPU-hours
+	// requested by the notebook, up to its last stop.
+	AnnotationGPUHours = "notebooks.opendatahub.io/gpu-hours"
+	// AnnotationRunningSince is set by the controller to the start time of
+	// the running notebook, it is removed when the notebook stops.
+	AnnotationRunningSince = "notebooks.opendatahub.io/running-since"
+
+	// DefaultUsageAggregationInterval is the interval between two updates of
+	// the usage metrics of the notebooks.
+	DefaultUsageAggregationInterval = 5 * time.Minute
+)
+
+// notebookIsRunning returns true if the notebook is neither stopped nor kept
+// stopped by the reconciliation lock.
+func notebookIsRunning(meta metav1.ObjectMeta) bool {
+	return !metav1.HasAnnotation(meta, culler.STOP_ANNOTATION)
+}
+
+// NotebookGPUs returns the number of GPUs requested by the notebook pod, the
+// GPU resources being the extended resources named <vendor>/gpu.
+func NotebookGPUs(notebook *nbv1.Notebook) int64 {
+	gpus := int64(0)
+	for _, container := range notebook.Spec.Template.Spec.Containers {
+		for name, quantity := range container.Resources.Limits {
+			if strings.HasSuffix(string(name), "/gpu") {
+				gpus += quantity.Value()
+			}
+		}
+	}
+	return gpus
+}
+
+// parseHours parses a usage annotation, 0 if it is not set or invalid.
+func parseHours(value string) float64 {
+	hours, err := strconv.ParseFloat(value, 64)
+	if err != nil || hours < 0 {
+		return 0
+	}
+	return hours
+}
+
+// formatHours formats a usage annotation.
+func formatHours(hours float64) string {
+	return strconv.FormatFloat(hours, 'f', 3, 64)
+}
+
+// NotebookUsage returns the cumulative running hours and GPU-hours of the
+// notebook, including its current run up to the given time.
+func NotebookUsage(notebook *nbv1.Notebook, now time.Time) (runningHours, gpuHours float64) {
+	annotations := notebook.GetAnnotations()
+	runningHours = parseHours(annotations[AnnotationRunningHours])
+	gpuHours = parseHours(annotations[AnnotationGPUHours])
+	if since, err := time.Parse(time.RFC3339, annotations[AnnotationRunningSince]); err == nil && now.After(since) {
+		hours := now.Sub(since).Hours()
+		runningHours += hours
+		gpuHours += hours * float64(NotebookGPUs(notebook))
+	}
+	return runningHours, gpuHours
+}
+
+// ReconcileUsage records the start time of the notebook when it starts, and
+// adds the hours of the run to its cumulative usage when it stops.
+func (r *OpenshiftNotebookReconciler) ReconcileUsage(notebook *nbv1.Notebook, ctx context.Context) error {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	running := notebookIsRunning(notebook.ObjectMeta)
+	since := notebook.GetAnnotations()[AnnotationRunningSince]
+	now := time.Now().UTC()
+	annotations := map[string]interface{}{}
+	switch {
+	case running && since == "":
+		annotations[AnnotationRunningSince] = now.Format(time.RFC3339)
+	case !running && since != "":
+		// The run ends when the notebook was stopped, if known
+		end := now
+		if stopped, err := time.Parse(time.RFC3339, notebook.GetAnnotations()[culler.STOP_ANNOTATION]); err == nil &&
+			stopped.Before(now) {
+			end = stopped
+		}
+		runningHours, gpuHours := NotebookUsage(notebook, end)
+		annotations[AnnotationRunningHours] = formatHours(runningHours)
+		annotations[AnnotationGPUHours] = formatHours(gpuHours)
+		annotations[AnnotationRunningSince] = nil
+	default:
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Updating the usage of the notebook", "running", running)
+	err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+	if err != nil {
+		log.Error(err, "Unable to update the usage of the notebook")
+		return err
+	}
+	return nil
+}
+
+// UsageAggregator periodically exports the cumulative usage of the notebooks,
+// including their current runs, as metrics for the chargeback.
+type UsageAggregator struct {
+	client.Client
+	Log logr.Logger
+	// Interval is the interval between two updates of the metrics.
+	Interval time.Duration
+}
+
+// Start updates the usage metrics until the context is cancelled.
+func (a *UsageAggregator) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := a.Aggregate(ctx); err != nil {
+			a.Log.Error(err, "Unable to aggregate the usage of the notebooks")
+		}
+	}, a.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes the usage exported by the leader only, so that it
+// is not counted twice.
+func (a *UsageAggregator) NeedLeaderElection() bool {
+	return true
+}
+
+// Aggregate updates the usage metrics of all the notebooks, the metrics of
+// the deleted notebooks are removed, along with the per-namespace state
+// metrics.
+func (a *UsageAggregator) Aggregate(ctx context.Context) error {
+	notebookList := &nbv1.NotebookList{}
+	if err := a.List(ctx, notebookList); err != nil {
+		return err
+	}
+	now := time.Now()
+	notebookRunningHours.Reset()
+	notebookGPUHours.Reset()
+	for i := range notebookList.Items {
+		notebook := &notebookList.Items[i]
+		runningHours, gpuHours := NotebookUsage(notebook, now)
+		notebookRunningHours.WithLabelValues(notebook.Namespace, notebook.Name).Set(runningHours)
+		notebookGPUHours.WithLabelValues(notebook.Namespace, notebook.Name).Set(gpuHours)
+	}
+	aggregateTenantMetrics(notebookList.Items)
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newUsageNotebook(gpus string) *nbv1.Notebook {
+	return &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name: "nb",
+				Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+					corev1.ResourceCPU:  resource.MustParse("2"),
+					"nvidia.com/gpu":    resource.MustParse(gpus),
+					"example.com/other": resource.MustParse("3"),
+				}},
+			}},
+		}}},
+	}
+}
+
+func TestNotebookUsage(t *testing.T) {
+	notebook := newUsageNotebook("2")
+	assert.Equal(t, int64(2), NotebookGPUs(notebook))
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	notebook.Annotations = map[string]string{
+		AnnotationRunningHours: "10.000",
+		AnnotationGPUHours:     "4.000",
+		AnnotationRunningSince: now.Add(-90 * time.Minute).Format(time.RFC3339),
+	}
+	runningHours, gpuHours := NotebookUsage(notebook, now)
+	assert.InDelta(t, 11.5, runningHours, 0.001)
+	assert.InDelta(t, 7, gpuHours, 0.001)
+
+	// The invalid annotations are ignored
+	notebook.Annotations = map[string]string{AnnotationRunningHours: "invalid", AnnotationRunningSince: "invalid"}
+	runningHours, gpuHours = NotebookUsage(notebook, now)
+	assert.Zero(t, runningHours)
+	assert.Zero(t, gpuHours)
+}
+
+func TestReconcileUsage(t *testing.T) {
+	ctx := context.Background()
+	notebook := newUsageNotebook("1")
+	r := newTestReconciler(t, OAuthConfig{}, notebook)
+	key := client.ObjectKeyFromObject(notebook)
+
+	// The start of the run is recorded
+	require.NoError(t, r.Get(ctx, key, notebook))
+	require.NoError(t, r.ReconcileUsage(notebook, ctx))
+	require.NoError(t, r.Get(ctx, key, notebook))
+	since, err := time.Parse(time.RFC3339, notebook.Annotations[AnnotationRunningSince])
+	require.NoError(t, err)
+
+	// The run is added to the usage when the notebook stops
+	notebook.Annotations[AnnotationRunningSince] = since.Add(-2 * time.Hour).Format(time.RFC3339)
+	notebook.Annotations[culler.STOP_ANNOTATION] = since.Add(-time.Hour).Format(time.RFC3339)
+	require.NoError(t, r.Update(ctx, notebook))
+	require.NoError(t, r.ReconcileUsage(notebook, ctx))
+	require.NoError(t, r.Get(ctx, key, notebook))
+	assert.NotContains(t, notebook.Annotations, AnnotationRunningSince)
+	assert.Equal(t, "1.000", notebook.Annotations[AnnotationRunningHours])
+	assert.Equal(t, "1.000", notebook.Annotations[AnnotationGPUHours])
+
+	// The usage is exported as metrics
+	aggregator := &UsageAggregator{Client: r.Client, Log: logr.Discard()}
+	require.NoError(t, aggregator.Aggregate(ctx))
+	assert.Equal(t, 1.0, metricValue(t, notebookRunningHours.WithLabelValues("ns", "nb")).GetGauge().GetValue())
+	assert.Equal(t, 1.0, metricValue(t, notebookGPUHours.WithLabelValues("ns", "nb")).GetGauge().GetValue())
+}
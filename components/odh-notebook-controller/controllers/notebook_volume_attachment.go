@@ -0,0 +1,169 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationVolumesAttached lists the single-node volumes of the notebook
+	// still attached to another node than the notebook pod, which prevent the
+	// pod from starting with multi-attach errors.
+	AnnotationVolumesAttached = "notebooks.opendatahub.io/volumes-attached"
+
+	// volumeDetachRequeueInterval is the interval between two checks of the
+	// detachment of the notebook volumes.
+	volumeDetachRequeueInterval = 5 * time.Second
+)
+
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="storage.k8s.io",resources=volumeattachments,verbs=get;list;watch
+
+// singleNodeAccessMode returns true if the PVC can only be attached to a
+// single node, i.e. its access modes are ReadWriteOnce or ReadWriteOncePod.
+func singleNodeAccessMode(pvc *corev1.PersistentVolumeClaim) bool {
+	for _, mode := range pvc.Spec.AccessModes {
+		if mode == corev1.ReadWriteMany || mode == corev1.ReadOnlyMany {
+			return false
+		}
+	}
+	return len(pvc.Spec.AccessModes) > 0
+}
+
+// AttachedVolumes returns the single-node PVCs of the notebook still attached
+// to another node than the one of the notebook pod, as "<pvc> (<node>)".
+func AttachedVolumes(ctx context.Context, c client.Client, notebook *nbv1.Notebook) ([]string, error) {
+	podNode := ""
+	pod := &corev1.Pod{}
+	err := c.Get(ctx, types.NamespacedName{Name: notebook.Name + "-0", Namespace: notebook.Namespace}, pod)
+	if err == nil {
+		podNode = pod.Spec.NodeName
+	} else if !apierrs.IsNotFound(err) {
+		return nil, err
+	}
+
+	volumes := map[string]string{}
+	for _, volume := range notebook.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := c.Get(ctx, types.NamespacedName{
+			Name:      volume.PersistentVolumeClaim.ClaimName,
+			Namespace: notebook.Namespace,
+		}, pvc)
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if pvc.Spec.VolumeName != "" && singleNodeAccessMode(pvc) {
+			volumes[pvc.Spec.VolumeName] = pvc.Name
+		}
+	}
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+
+	attachmentList := &storagev1.VolumeAttachmentList{}
+	if err := c.List(ctx, attachmentList); err != nil {
+		return nil, err
+	}
+	attached := []string{}
+	for _, attachment := range attachmentList.Items {
+		source := attachment.Spec.Source.PersistentVolumeName
+		if source == nil || attachment.Spec.NodeName == podNode || !attachment.Status.Attached {
+			continue
+		}
+		if pvc, ok := volumes[*source]; ok {
+			attached = append(attached, fmt.Sprintf("%s (%s)", pvc, attachment.Spec.NodeName))
+		}
+	}
+	sort.Strings(attached)
+	return attached, nil
+}
+
+// notebookIsStopped returns true if the notebook is stopped by the user or
+// the culler, as opposed to locked by the controller.
+func notebookIsStopped(meta metav1.ObjectMeta) bool {
+	return metav1.HasAnnotation(meta, culler.STOP_ANNOTATION) && !ReconciliationLockIsEnabled(meta)
+}
+
+// ReconcileVolumeAttachments reports the volumes of the notebook still
+// attached to another node, and returns true while the start of the notebook
+// must be delayed until they are detached.
+func (r *OpenshiftNotebookReconciler) ReconcileVolumeAttachments(notebook *nbv1.Notebook,
+	ctx context.Context) (bool, error) {
+	// Initialize logger format
+	log := r.notebookLogger(notebook)
+
+	attached := []string{}
+	if !notebookIsStopped(notebook.ObjectMeta) {
+		var err error
+		attached, err = AttachedVolumes(ctx, r.Client, notebook)
+		if err != nil {
+			log.Error(err, "Unable to check the attachments of the notebook volumes")
+			return false, err
+		}
+	}
+
+	value := strings.Join(attached, ", ")
+	if current := notebook.GetAnnotations()[AnnotationVolumesAttached]; current != value {
+		if value != "" {
+			log.Info("Notebook volumes attached to another node", "volumes", value)
+			r.recordEvent(notebook, corev1.EventTypeWarning, "VolumesAttached",
+				"The volumes %s are still attached to another node, the notebook starts once they are detached",
+				value)
+		} else {
+			log.Info("Notebook volumes detached")
+		}
+		var annotation interface{}
+		if value != "" {
+			annotation = value
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{
+					AnnotationVolumesAttached: annotation,
+				},
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+		err = r.Patch(ctx, notebook, client.RawPatch(types.MergePatchType, patch))
+		if err != nil {
+			log.Error(err, "Unable to report the attachments of the notebook volumes")
+			return false, err
+		}
+	}
+	return r.DelayStartOnAttachedVolumes && len(attached) > 0, nil
+}
@@ -0,0 +1,127 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestVolumeAttachment(name, pv, node string) *storagev1.VolumeAttachment {
+	return &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.VolumeAttachmentSpec{
+			NodeName: node,
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: pointer.String(pv)},
+		},
+		Status: storagev1.VolumeAttachmentStatus{Attached: true},
+	}
+}
+
+func TestAttachedVolumes(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{Name: "home", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "home"}}},
+				{Name: "shared", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "shared"}}},
+			},
+		}}},
+	}
+	newPVC := func(name string, mode corev1.PersistentVolumeAccessMode) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{mode},
+				VolumeName:  "pv-" + name,
+			},
+		}
+	}
+	r := newTestReconciler(t, OAuthConfig{}, notebook,
+		newPVC("home", corev1.ReadWriteOnce),
+		newPVC("shared", corev1.ReadWriteMany),
+		newTestVolumeAttachment("home", "pv-home", "node-a"),
+		newTestVolumeAttachment("shared", "pv-shared", "node-a"))
+
+	// The single-node volume is attached to the node of the previous pod
+	attached, err := AttachedVolumes(ctx, r.Client, notebook)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"home (node-a)"}, attached)
+
+	// The volume is attached to the node of the new pod
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb-0", Namespace: "ns"},
+		Spec:       corev1.PodSpec{NodeName: "node-a"},
+	}
+	require.NoError(t, r.Create(ctx, pod))
+	attached, err = AttachedVolumes(ctx, r.Client, notebook)
+	require.NoError(t, err)
+	assert.Empty(t, attached)
+}
+
+func TestReconcileVolumeAttachments(t *testing.T) {
+	ctx := context.Background()
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", Annotations: map[string]string{
+			culler.STOP_ANNOTATION: AnnotationValueReconciliationLock,
+		}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{Name: "home", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "home"}}}},
+		}}},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "home", Namespace: "ns"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOncePod},
+			VolumeName:  "pv-home",
+		},
+	}
+	attachment := newTestVolumeAttachment("home", "pv-home", "node-a")
+	r := newTestReconciler(t, OAuthConfig{}, notebook, pvc, attachment)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	r.DelayStartOnAttachedVolumes = true
+
+	delayed, err := r.ReconcileVolumeAttachments(notebook, ctx)
+	require.NoError(t, err)
+	assert.True(t, delayed)
+	assert.Equal(t, "home (node-a)", notebook.Annotations[AnnotationVolumesAttached])
+	assert.Contains(t, <-recorder.Events, "VolumesAttached")
+
+	// The start is no longer delayed once the volume is detached
+	require.NoError(t, r.Delete(ctx, attachment))
+	delayed, err = r.ReconcileVolumeAttachments(notebook, ctx)
+	require.NoError(t, err)
+	assert.False(t, delayed)
+	found := &nbv1.Notebook{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(notebook), found))
+	assert.NotContains(t, found.Annotations, AnnotationVolumesAttached)
+}
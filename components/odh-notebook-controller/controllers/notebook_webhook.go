@@ -18,9 +18,11 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/go-logr/logr"
@@ -29,26 +31,156 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
-//+kubebuilder:webhook:path=/mutate-notebook-v1,mutating=true,failurePolicy=fail,sideEffects=None,groups=kubeflow.org,resources=notebooks,verbs=create;update,versions=v1,name=notebooks.opendatahub.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/mutate-notebook-v1,mutating=true,failurePolicy=fail,sideEffects=NoneOnDryRun,groups=kubeflow.org,resources=notebooks,verbs=create;update,versions=v1,name=notebooks.opendatahub.io,admissionReviewVersions=v1
+
+const (
+	// AnnotationStrictImageResolution set to true denies the admission of the
+	// notebook when its selected image cannot be resolved from the
+	// ImageStreams, even if the controller does not.
+	AnnotationStrictImageResolution = "notebooks.opendatahub.io/strict-image-resolution"
+	// AnnotationUpdatePending reports the changes of a running notebook
+	// applied on its next restart, as a PendingChanges JSON document.
+	AnnotationUpdatePending = "notebooks.opendatahub.io/update-pending"
+	// AnnotationAllowInPlaceUpdates applies the changes of the webhook to the
+	// running notebook right away, restarting it, rather than holding them
+	// until its next restart.
+	AnnotationAllowInPlaceUpdates = "notebooks.opendatahub.io/allow-inplace-updates"
+	// AnnotationAdmissionUID is set on the events recorded by the webhook.
+	AnnotationAdmissionUID = "notebooks.opendatahub.io/admission-uid"
+)
 
 // NotebookWebhook holds the webhook configuration.
 type NotebookWebhook struct {
-	Log         logr.Logger
-	Client      client.Client
-	Config      *rest.Config
-	Decoder     *admission.Decoder
-	OAuthConfig OAuthConfig
+	Log    logr.Logger
+	Client client.Client
+	Config *rest.Config
+	// DynamicClient reads the ImageStreams, a client of the Config if nil.
+	DynamicClient dynamic.Interface
+	Decoder       *admission.Decoder
+	Recorder      record.EventRecorder
+	OAuthConfig   OAuthConfig
+	// LogoutConfig holds the default logout URL of the OAuth proxy.
+	LogoutConfig LogoutConfig
+	// AuthConfig holds the settings of the auth providers of the notebooks.
+	AuthConfig AuthConfig
+	// SchedulingConfig holds the scheduling defaults of the notebook pods.
+	SchedulingConfig SchedulingConfig
+	// SpotConfig holds the settings of the notebooks running on spot nodes.
+	SpotConfig SpotConfig
+	// RouteConfig holds the router shards of the notebook routes.
+	RouteConfig RouteConfig
+	// SCCConfig holds the SecurityContextConstraints the notebooks may
+	// request.
+	SCCConfig SCCConfig
+	// ClusterDNSConfig holds the DNS names of the cluster services.
+	ClusterDNSConfig ClusterDNSConfig
+	// PullSecretsConfig holds the pull secrets injected in the notebook
+	// pods.
+	PullSecretsConfig PullSecretsConfig
+	// WorkbenchPullPolicy is set on the workbench containers without pull
+	// policy, unchanged if empty.
+	WorkbenchPullPolicy corev1.PullPolicy
+	// LabelPropagationConfig holds the labels of the notebooks propagated to
+	// the pod template.
+	LabelPropagationConfig LabelPropagationConfig
+	// MetadataDefaults holds the annotations and labels set on the new
+	// notebooks which do not specify them.
+	MetadataDefaults MetadataDefaults
+	// StrictImageResolution denies the admission of all the notebooks whose
+	// selected image cannot be resolved.
+	StrictImageResolution bool
+	// StrictReferenceValidation denies the admission of all the notebooks
+	// referencing missing Secrets, ConfigMaps or PVCs, they are only warned
+	// about otherwise.
+	StrictReferenceValidation bool
+	// StorageValidation denies the admission of the notebooks whose claims
+	// would leave their pod pending, e.g. provisioned by a StorageClass which
+	// does not exist.
+	StorageValidation bool
+	// NoOpUpdateFastPath allows the updates changing none of the fields the
+	// webhook mutates or validates without running the image resolution and
+	// the lookups of the mutation, e.g. the updates of the culler.
+	NoOpUpdateFastPath bool
+	// DelayStartOnAttachedVolumes keeps the started notebooks stopped until
+	// their single-node volumes are detached from their previous node.
+	DelayStartOnAttachedVolumes bool
+	// AllowedLocales are the locales the notebooks may set with their locale
+	// annotation, DefaultAllowedLocales if nil.
+	AllowedLocales []string
+	// UpstreamAdoption leaves the notebooks created by the upstream notebook
+	// controller unchanged until they are adopted.
+	UpstreamAdoption bool
+	// SkipMutationEnabled admits the notebooks requesting it with the
+	// skip-mutation annotation as is, for the administrators debugging them.
+	SkipMutationEnabled bool
+	// SkipMutationGroups are the groups of the administrators allowed to
+	// skip the mutations, in addition to the users allowed the
+	// skip-mutation verb on the notebooks.
+	SkipMutationGroups []string
+	// NormalizeNotebooks fills in the labels, annotations and environment
+	// variables of the dashboard notebooks in the notebooks created otherwise.
+	NormalizeNotebooks bool
+	// ImageGCProtection labels the notebook pods for the protection of their
+	// images from the node image garbage collection.
+	ImageGCProtection bool
+	// MutationProvenance records the hash of the pod template of the
+	// notebooks, for the audits of the running pods.
+	MutationProvenance bool
+	// MutationSigningKey signs the recorded mutation provenance, unsigned if
+	// empty.
+	MutationSigningKey []byte
+	// DashboardConfigKey is the OdhDashboardConfig whose notebook settings
+	// are injected in the notebooks, none if empty.
+	DashboardConfigKey types.NamespacedName
+	// ControllerUsername is the username of the controller service account,
+	// the only one allowed to change the controller-owned annotations.
+	ControllerUsername string
+}
+
+// ImageResolutionError is returned when the image selected for the notebook
+// cannot be resolved from the ImageStreams.
+type ImageResolutionError struct {
+	Message string
+}
+
+func (e *ImageResolutionError) Error() string {
+	return e.Message
+}
+
+// strictImageResolution returns true if the notebook must not be admitted
+// when its selected image cannot be resolved. The annotation of the notebook
+// can only make the resolution stricter than the configuration of the
+// controller.
+func (w *NotebookWebhook) strictImageResolution(notebook *nbv1.Notebook) bool {
+	strict, _ := strconv.ParseBool(notebook.GetAnnotations()[AnnotationStrictImageResolution])
+	return w.StrictImageResolution || strict
+}
+
+// recordEvent records an event for the notebook being admitted, annotated
+// with the admission request UID. No event is recorded for dry-run requests,
+// nor for the notebooks being created, which have no UID the event could be
+// correlated with yet; the warnings returned to the client cover them.
+func (w *NotebookWebhook) recordEvent(req admission.Request, notebook *nbv1.Notebook,
+	eventType, reason, message string) {
+	if w.Recorder == nil || (req.DryRun != nil && *req.DryRun) || notebook.UID == "" {
+		return
+	}
+	w.Recorder.AnnotatedEventf(notebook, map[string]string{AnnotationAdmissionUID: string(req.UID)},
+		eventType, reason, message)
 }
 
 // InjectReconciliationLock injects the kubeflow notebook controller culling
@@ -72,11 +204,16 @@ func InjectReconciliationLock(meta *metav1.ObjectMeta) error {
 // InjectOAuthProxy injects the OAuth proxy sidecar container in the Notebook
 // spec
 func InjectOAuthProxy(notebook *nbv1.Notebook, oauth OAuthConfig) error {
+	proxyArgs, err := NewProxyArgs(notebook, oauth)
+	if err != nil {
+		return err
+	}
+
 	// https://pkg.go.dev/k8s.io/api/core/v1#Container
 	proxyContainer := corev1.Container{
-		Name:            "oauth-proxy",
+		Name:            OAuthProxyContainerName,
 		Image:           oauth.ProxyImage,
-		ImagePullPolicy: corev1.PullAlways,
+		ImagePullPolicy: oauthProxyPullPolicy(oauth),
 		Env: []corev1.EnvVar{{
 			Name: "NAMESPACE",
 			ValueFrom: &corev1.EnvVarSource{
@@ -85,22 +222,7 @@ func InjectOAuthProxy(notebook *nbv1.Notebook, oauth OAuthConfig) error {
 				},
 			},
 		}},
-		Args: []string{
-			"--provider=openshift",
-			"--https-address=:8443",
-			"--http-address=",
-			"--openshift-service-account=" + notebook.Name,
-			"--cookie-secret-file=/etc/oauth/config/cookie_secret",
-			"--cookie-expire=24h0m0s",
-			"--tls-cert=/etc/tls/private/tls.crt",
-			"--tls-key=/etc/tls/private/tls.key",
-			"--upstream=http://localhost:8888",
-			"--upstream-ca=/var/run/secrets/kubernetes.io/serviceaccount/ca.crt",
-			"--email-domain=*",
-			"--skip-provider-button",
-			`--openshift-sar={"verb":"get","resource":"notebooks","resourceAPIGroup":"kubeflow.org",` +
-				`"resourceName":"` + notebook.Name + `","namespace":"$(NAMESPACE)"}`,
-		},
+		Args: proxyArgs.Args(),
 		Ports: []corev1.ContainerPort{{
 			Name:          OAuthServicePortName,
 			ContainerPort: 8443,
@@ -134,16 +256,7 @@ func InjectOAuthProxy(notebook *nbv1.Notebook, oauth OAuthConfig) error {
 			SuccessThreshold:    1,
 			FailureThreshold:    3,
 		},
-		Resources: corev1.ResourceRequirements{
-			Requests: corev1.ResourceList{
-				"cpu":    resource.MustParse("100m"),
-				"memory": resource.MustParse("64Mi"),
-			},
-			Limits: corev1.ResourceList{
-				"cpu":    resource.MustParse("100m"),
-				"memory": resource.MustParse("64Mi"),
-			},
-		},
+		Resources: oauthProxyResources(notebook, oauth),
 		VolumeMounts: []corev1.VolumeMount{
 			{
 				Name:      "oauth-config",
@@ -155,26 +268,11 @@ func InjectOAuthProxy(notebook *nbv1.Notebook, oauth OAuthConfig) error {
 			},
 		},
 	}
-
-	// Add logout url if logout annotation is present in the notebook
-	if notebook.ObjectMeta.Annotations[AnnotationLogoutUrl] != "" {
-		proxyContainer.Args = append(proxyContainer.Args,
-			"--logout-url="+notebook.ObjectMeta.Annotations[AnnotationLogoutUrl])
-	}
+	injectOAuthUpstreamCAVolume(notebook, &proxyContainer)
+	injectOAuthProxyMetrics(&proxyContainer, oauth)
 
 	// Add the sidecar container to the notebook
-	notebookContainers := &notebook.Spec.Template.Spec.Containers
-	proxyContainerExists := false
-	for index, container := range *notebookContainers {
-		if container.Name == "oauth-proxy" {
-			(*notebookContainers)[index] = proxyContainer
-			proxyContainerExists = true
-			break
-		}
-	}
-	if !proxyContainerExists {
-		*notebookContainers = append(*notebookContainers, proxyContainer)
-	}
+	setOAuthProxyContainer(notebook, proxyContainer, oauth.NativeSidecar)
 
 	// Add the OAuth configuration volume:
 	// https://pkg.go.dev/k8s.io/api/core/v1#Volume
@@ -184,7 +282,7 @@ func InjectOAuthProxy(notebook *nbv1.Notebook, oauth OAuthConfig) error {
 		Name: "oauth-config",
 		VolumeSource: corev1.VolumeSource{
 			Secret: &corev1.SecretVolumeSource{
-				SecretName:  notebook.Name + "-oauth-config",
+				SecretName:  OAuthSecretName(notebook),
 				DefaultMode: pointer.Int32Ptr(420),
 			},
 		},
@@ -224,7 +322,7 @@ func InjectOAuthProxy(notebook *nbv1.Notebook, oauth OAuthConfig) error {
 	}
 
 	// Set a dedicated service account, do not use default
-	notebook.Spec.Template.Spec.ServiceAccountName = notebook.Name
+	notebook.Spec.Template.Spec.ServiceAccountName = OAuthServiceAccountName(notebook, oauth)
 	return nil
 }
 
@@ -232,15 +330,142 @@ func InjectOAuthProxy(notebook *nbv1.Notebook, oauth OAuthConfig) error {
 func (w *NotebookWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
 
 	// Initialize logger format
-	log := w.Log.WithValues("notebook", req.Name, "namespace", req.Namespace)
+	log := w.Log.WithValues("notebook", req.Name, "namespace", req.Namespace, "admissionUID", req.UID)
 	ctx = logr.NewContext(ctx, log)
 
 	notebook := &nbv1.Notebook{}
+	// Warnings returned to the client along with the admission response
+	var warnings []string
 
 	err := w.Decoder.Decode(req, notebook)
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
+	original := notebook.DeepCopy()
+
+	// The old notebook is only defined on update
+	var oldNotebook *nbv1.Notebook
+	if req.Operation == admissionv1.Update {
+		oldNotebook = &nbv1.Notebook{}
+		err = w.Decoder.DecodeRaw(req.OldObject, oldNotebook)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	// Revert the changes of the controller-owned annotations by the users
+	if w.protectsAnnotations(req) {
+		if reverted := ProtectControllerAnnotations(notebook, oldNotebook); len(reverted) > 0 {
+			log.Info("Reverting the changes of the controller-owned annotations", "annotations", reverted,
+				"username", req.UserInfo.Username)
+			warnings = append(warnings, protectedAnnotationsWarning(reverted))
+		}
+	}
+
+	// Allow the no-op updates, e.g. of the last activity by the culler,
+	// without running the mutation (see notebook_webhook_noop.go file)
+	if w.NoOpUpdateFastPath && len(warnings) == 0 && req.Operation == admissionv1.Update &&
+		NotebookUpdateIsNoOp(notebook, oldNotebook) {
+		webhookNoOpUpdatesTotal.Inc()
+		return admission.Allowed("no-op update")
+	}
+
+	// Leave the upstream notebooks unchanged until they are adopted, the new
+	// notebooks are always managed
+	if w.UpstreamAdoption && req.Operation == admissionv1.Update && NotebookIsUnmanaged(notebook) {
+		log.Info("Upstream notebook not adopted, skipping the mutation")
+		if len(warnings) > 0 {
+			marshaledNotebook, err := json.Marshal(notebook)
+			if err != nil {
+				return admission.Errored(http.StatusInternalServerError, err)
+			}
+			response := admission.PatchResponseFromRaw(req.Object.Raw, marshaledNotebook)
+			response.Warnings = warnings
+			return response
+		}
+		return admission.Allowed("upstream notebook not adopted")
+	}
+
+	// Admit the notebooks being debugged as is, only the changes of the
+	// controller-owned annotations are still reverted
+	skipMutation := false
+	if w.SkipMutationEnabled && SkipMutationRequested(notebook) {
+		authorized, err := SkipMutationAuthorized(ctx, w.Client, req.UserInfo, notebook, w.SkipMutationGroups)
+		if err != nil {
+			log.Error(err, "Unable to authorize the mutation skip, mutating the notebook")
+		}
+		if !authorized {
+			warnings = append(warnings, fmt.Sprintf("The %s annotation is ignored, %s is not allowed to skip "+
+				"the mutations of the webhook.", AnnotationSkipMutation, req.UserInfo.Username))
+		}
+		skipMutation = authorized
+	}
+	if skipMutation {
+		log.Info("Skipping the mutation requested by the notebook", "username", req.UserInfo.Username)
+		w.recordEvent(req, notebook, corev1.EventTypeWarning, "MutationSkipped",
+			fmt.Sprintf("The webhook mutations were skipped at the request of %s", req.UserInfo.Username))
+		warnings = append(warnings, fmt.Sprintf("The notebook is admitted without the mutations of the webhook, "+
+			"remove the %s annotation to restore them.", AnnotationSkipMutation))
+		marshaledNotebook, err := json.Marshal(notebook)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		response := admission.PatchResponseFromRaw(req.Object.Raw, marshaledNotebook)
+		response.Warnings = warnings
+		return response
+	}
+
+	// Report the changes of the notebook spec by concurrent field managers,
+	// e.g. the dashboard and a GitOps tool
+	if oldNotebook != nil && (req.DryRun == nil || !*req.DryRun) &&
+		!equality.Semantic.DeepEqual(oldNotebook.Spec, notebook.Spec) {
+		if message := specManagerConflict(req, notebook, oldNotebook); message != "" {
+			log.Info(message)
+			w.recordEvent(req, notebook, corev1.EventTypeWarning, "FieldManagerConflict", message)
+		}
+	}
+
+	// Keep the started notebook stopped until its volumes are detached from
+	// the node of its previous pod, the controller then removes the lock
+	if oldNotebook != nil && w.DelayStartOnAttachedVolumes && notebookIsStopped(oldNotebook.ObjectMeta) &&
+		!metav1.HasAnnotation(notebook.ObjectMeta, culler.STOP_ANNOTATION) {
+		attached, err := AttachedVolumes(ctx, w.Client, notebook)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if len(attached) > 0 {
+			log.Info("Delaying the start of the notebook until its volumes are detached", "volumes", attached)
+			err = InjectReconciliationLock(&notebook.ObjectMeta)
+			if err != nil {
+				return admission.Errored(http.StatusInternalServerError, err)
+			}
+			warnings = append(warnings, fmt.Sprintf("The volumes %s are still attached to another node, "+
+				"the notebook starts once they are detached", strings.Join(attached, ", ")))
+		}
+	}
+
+	namespace := &corev1.Namespace{}
+	err = w.Client.Get(ctx, client.ObjectKey{Name: req.Namespace}, namespace)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	// Apply the default metadata of the namespace to the new notebooks
+	if req.Operation == admissionv1.Create {
+		defaults, err := w.MetadataDefaults.ForNamespace(namespace)
+		if err != nil {
+			log.Error(err, "Ignoring the notebook defaults of the namespace")
+			warnings = append(warnings, err.Error())
+		}
+		if applied := ApplyMetadataDefaults(notebook, defaults); len(applied) > 0 {
+			log.Info("Applied the notebook defaults", "keys", applied)
+		}
+	}
+
+	// Inject the OAuth proxy in all the notebooks of the labeled namespaces
+	if InjectOAuthFromNamespace(notebook, namespace) {
+		log.Info("Enabling the OAuth proxy injection required by the namespace")
+	}
 
 	// Inject the reconciliation lock only on new notebook creation
 	if req.Operation == admissionv1.Create {
@@ -249,31 +474,271 @@ func (w *NotebookWebhook) Handle(ctx context.Context, req admission.Request) adm
 			return admission.Errored(http.StatusInternalServerError, err)
 		}
 
+		// Record the creator of the notebook
+		if req.UserInfo.Username != "" {
+			notebook.Annotations[AnnotationCreator] = req.UserInfo.Username
+		}
 	}
 
 	// Check Imagestream Info both on create and update operations
 	if req.Operation == admissionv1.Create || req.Operation == admissionv1.Update {
-		// Check Imagestream Info
-		err = SetContainerImageFromRegistry(ctx, w.Config, notebook, log)
+		// Reject the primary containers and sidecars the notebook does not
+		// have, before updating the notebook container
+		err = ValidatePrimaryContainerAnnotation(notebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		err = ValidateSidecarsAnnotation(notebook)
 		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Fill in the metadata and environment of the dashboard notebooks
+		// missing from the notebooks created otherwise
+		if w.NormalizeNotebooks {
+			if applied := NormalizeNotebook(notebook, req.Namespace); len(applied) > 0 {
+				log.Info("Normalized the notebook", "keys", applied)
+			}
+		}
+
+		// Check Imagestream Info
+		dynamicClient := w.DynamicClient
+		if dynamicClient == nil {
+			dynamicClient, err = dynamic.NewForConfig(w.Config)
+			if err != nil {
+				log.Error(err, "Error creating dynamic client")
+				return admission.Errored(http.StatusInternalServerError, err)
+			}
+		}
+		image := containerImage(notebook)
+		err = SetContainerImageFromRegistry(ctx, dynamicClient, notebook, w.ClusterDNSConfig, log)
+		var imageErr *ImageResolutionError
+		if errors.As(err, &imageErr) {
+			if w.strictImageResolution(notebook) {
+				return admission.Denied(imageErr.Error())
+			}
+			warnings = append(warnings, imageErr.Error())
+			w.recordEvent(req, notebook, corev1.EventTypeWarning, "ImageResolutionFailed", imageErr.Error())
+		} else if err != nil {
 			return admission.Errored(http.StatusInternalServerError, err)
 		}
+		if warning := imageRewriteWarning(notebook, image); warning != "" {
+			warnings = append(warnings, warning)
+		}
+
+		// Warn about the deprecated images, reported by the controller with
+		// a condition of the notebook
+		deprecation, err := NotebookImageDeprecation(ctx, dynamicClient, notebook, log)
+		if err != nil {
+			log.Error(err, "Unable to check the deprecation of the notebook image")
+		} else if deprecation != "" {
+			warnings = append(warnings, deprecation)
+			notebook.Annotations[AnnotationImageDeprecated] = deprecation
+		} else {
+			delete(notebook.Annotations, AnnotationImageDeprecated)
+		}
 
 		// Mount ca bundle on notebook creation and update
+		env := containerEnv(notebook)
 		err = CheckAndMountCACertBundle(ctx, w.Client, notebook, log)
 		if err != nil {
 			return admission.Errored(http.StatusInternalServerError, err)
 		}
+		if warning := caBundleWarning(notebook, env); warning != "" {
+			warnings = append(warnings, warning)
+		}
+
+		// Reject the culling overrides the culler would ignore
+		err = ValidateCullingAnnotations(notebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Reject the annotations whose values do not match their schema
+		err = ValidateAnnotations(notebook, oldNotebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Reject the invalid hibernation requests
+		err = ValidateHibernationAnnotation(notebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Reject the unknown router shards
+		_, err = w.RouteConfig.RouterShard(notebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Reject the invalid custom hostnames
+		err = ValidateExternalDNSAnnotations(notebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Reject the unprotected notebooks exposed against the cluster policy
+		err = w.RouteConfig.ValidateUnprotectedExposure(notebook, oldNotebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+		if w.RouteConfig.UnprotectedPolicy == UnprotectedRouteInternalOnly &&
+			NotebookIsUnprotected(notebook.ObjectMeta) && ExposureIsEnabled(notebook.ObjectMeta) {
+			warnings = append(warnings, "The notebook is not exposed outside of the cluster: the notebooks "+
+				"without authentication are kept cluster internal by the cluster policy")
+		}
+
+		// Reject or suffix the custom hostnames already used by another
+		// Route, which the router would not admit
+		warning, err := w.resolveRouteHost(ctx, notebook, oldNotebook)
+		var hostErr *RouteHostConflictError
+		if errors.As(err, &hostErr) {
+			return admission.Denied(hostErr.Error())
+		} else if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if warning != "" {
+			log.Info("Suffixed the conflicting custom hostname",
+				"hostname", notebook.Annotations[AnnotationExternalDNSHostname])
+			warnings = append(warnings, warning)
+		}
+
+		// Reject the invalid external OAuth secrets
+		err = ValidateOAuthSecretAnnotation(notebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Reject the invalid upstream CAs
+		err = ValidateOAuthUpstreamCAAnnotation(notebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Inject the security context of the requested SCC, which can only be
+		// requested from the namespaces allowed by its policy
+		var sccPolicy *SCCPolicy
+		if metav1.HasAnnotation(notebook.ObjectMeta, AnnotationSCC) {
+			namespace := &corev1.Namespace{}
+			err = w.Client.Get(ctx, client.ObjectKey{Name: req.Namespace}, namespace)
+			if err != nil {
+				return admission.Errored(http.StatusInternalServerError, err)
+			}
+			sccPolicy, err = w.SCCConfig.SCCPolicy(notebook, namespace)
+			if err != nil {
+				return admission.Denied(err.Error())
+			}
+		}
+		w.SCCConfig.InjectSCCSecurityContext(notebook, sccPolicy)
+
+		// Expose the model registry and serving endpoints to the workbench
+		err = InjectModelEndpointsEnv(notebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Run the workbench in the time zone and the locale of its users
+		err = InjectLocaleEnv(notebook, oldNotebook, w.AllowedLocales)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Spread the notebook pods across zones and nodes
+		InjectSchedulingDefaults(notebook, w.SchedulingConfig)
+
+		// Schedule the notebooks that opted in on spot nodes
+		InjectSpotScheduling(notebook, w.SpotConfig)
+
+		// Keep the large workbench images on the nodes
+		InjectImageGCProtection(notebook, w.ImageGCProtection)
+
+		// Inject the configured pull secrets in the notebook pod, the pods
+		// created before they are propagated to the service account included
+		pullSecrets, err := w.PullSecretsConfig.ForNamespace(namespace)
+		if err != nil {
+			log.Error(err, "Ignoring the pull secrets of the namespace")
+			warnings = append(warnings, err.Error())
+		}
+		InjectImagePullSecrets(notebook, pullSecrets)
+
+		// Default the pull policy of the workbench containers
+		InjectWorkbenchPullPolicy(notebook, w.WorkbenchPullPolicy)
+
+		// Record the notebook labels propagated to the generated objects,
+		// for the chargeback and the policy engines
+		InjectPropagatedLabels(notebook, w.LabelPropagationConfig)
+
+		// Keep the sessions alive, the culler stopping the notebook after
+		// the same idle time
+		err = InjectSessionKeepalive(notebook, oldNotebook)
+		if err != nil {
+			return admission.Denied(err.Error())
+		}
+
+		// Apply the culling and toleration settings of the dashboard
+		if w.DashboardConfigKey.Name != "" {
+			dashboardConfig, err := LoadDashboardConfig(ctx, w.Client, w.DashboardConfigKey)
+			if err != nil {
+				log.Error(err, "Ignoring the dashboard configuration")
+				warnings = append(warnings, err.Error())
+			} else {
+				InjectDashboardDefaults(notebook, dashboardConfig)
+			}
+		}
+
+		// Report the missing Secrets, ConfigMaps and PVCs, which would leave
+		// the notebook pod in the CreateContainerConfigError state
+		if referencesNeedValidation(notebook, oldNotebook) {
+			missing, err := MissingNotebookReferences(ctx, w.Client, notebook)
+			if err != nil {
+				log.Error(err, "Unable to check the references of the notebook")
+			} else if len(missing) > 0 {
+				message := MissingReferencesMessage(notebook, missing)
+				if w.strictReferenceValidation(notebook) {
+					return admission.Denied(message)
+				}
+				warnings = append(warnings, message)
+				w.recordEvent(req, notebook, corev1.EventTypeWarning, "MissingReferences", message)
+			}
+		}
+
+		// Report the claims which would leave the notebook pod pending
+		if w.StorageValidation && referencesNeedValidation(notebook, oldNotebook) {
+			report, err := ValidateNotebookStorage(ctx, w.Client, notebook)
+			if err != nil {
+				log.Error(err, "Unable to check the storage of the notebook")
+			} else if len(report.Problems) > 0 {
+				return admission.Denied(StorageProblemsMessage(notebook, report.Problems))
+			} else if len(report.Warnings) > 0 {
+				message := StorageWarningsMessage(notebook, report.Warnings)
+				warnings = append(warnings, message)
+				w.recordEvent(req, notebook, corev1.EventTypeWarning, "StorageWarning", message)
+			}
+		}
 	}
 
-	// Inject the OAuth proxy if the annotation is present but only if Service Mesh is disabled
+	// Inject the proxy of the auth provider if the annotation is present but
+	// only if Service Mesh is disabled (see notebook_auth_injectors.go file)
 	if OAuthInjectionIsEnabled(notebook.ObjectMeta) {
 		if ServiceMeshIsEnabled(notebook.ObjectMeta) {
 			return admission.Denied(fmt.Sprintf("Cannot have both %s and %s set to true. Pick one.", AnnotationServiceMesh, AnnotationInjectOAuth))
 		}
-		err = InjectOAuthProxy(notebook, w.OAuthConfig)
+		injector, err := notebookAuthInjector(notebook, w.AuthConfig)
 		if err != nil {
-			return admission.Errored(http.StatusInternalServerError, err)
+			return admission.Denied(err.Error())
+		}
+		if _, err = NotebookProxyResources(notebook); err != nil {
+			return admission.Denied(err.Error())
+		}
+		if _, err = NotebookProxyExtraArgs(notebook, w.OAuthConfig); err != nil {
+			return admission.Denied(err.Error())
+		}
+		if _, _, err = NotebookCookieSettings(notebook, w.OAuthConfig); err != nil {
+			return admission.Denied(err.Error())
+		}
+		if err = injector.Inject(ctx, w, notebook); err != nil {
+			return admission.Denied(err.Error())
 		}
 	}
 
@@ -283,11 +748,33 @@ func (w *NotebookWebhook) Handle(ctx context.Context, req admission.Request) adm
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
-	updatePendingAnnotation := "notebooks.opendatahub.io/update-pending"
+	if mutatedNotebook.ObjectMeta.Annotations == nil {
+		mutatedNotebook.ObjectMeta.Annotations = map[string]string{}
+	}
 	if needsRestart != NoPendingUpdates {
-		mutatedNotebook.ObjectMeta.Annotations[updatePendingAnnotation] = needsRestart.Reason
+		if warning := updatePendingWarning(oldNotebook, needsRestart.Reason); warning != "" {
+			warnings = append(warnings, warning)
+		}
+		mutatedNotebook.ObjectMeta.Annotations[AnnotationUpdatePending] = needsRestart.Reason
 	} else {
-		delete(mutatedNotebook.ObjectMeta.Annotations, updatePendingAnnotation)
+		delete(mutatedNotebook.ObjectMeta.Annotations, AnnotationUpdatePending)
+	}
+
+	// Record the provenance of the pod template, so that the audits detect
+	// the changes of the running pods bypassing the webhook
+	if w.MutationProvenance {
+		err = RecordMutationProvenance(mutatedNotebook, oldNotebook, ControllerVersion(), w.MutationSigningKey)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+
+	// Record the admission request UID to correlate the webhook logs with the
+	// logs of the subsequent reconciles of the notebook. Only the admissions
+	// mutating the notebook are recorded, so that e.g. the periodic updates of
+	// the culler do not churn the annotation.
+	if notebookMutated(original, mutatedNotebook) {
+		mutatedNotebook.ObjectMeta.Annotations[AnnotationLastAdmissionUID] = string(req.UID)
 	}
 
 	// Create the mutated notebook object
@@ -296,7 +783,96 @@ func (w *NotebookWebhook) Handle(ctx context.Context, req admission.Request) adm
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
 
-	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledNotebook)
+	response := admission.PatchResponseFromRaw(req.Object.Raw, marshaledNotebook)
+	response.Warnings = warnings
+	return response
+}
+
+// notebookMutated returns true if the webhook changed the labels, annotations
+// or spec of the admitted notebook, the admission trail excepted.
+func notebookMutated(original, mutated *nbv1.Notebook) bool {
+	return !equality.Semantic.DeepEqual(original.Spec, mutated.Spec) ||
+		!equality.Semantic.DeepEqual(original.Labels, mutated.Labels) ||
+		!equality.Semantic.DeepEqual(withoutAdmissionTrail(original.Annotations),
+			withoutAdmissionTrail(mutated.Annotations))
+}
+
+// containerImage returns the image of the notebook container, empty if the
+// notebook has none.
+func containerImage(notebook *nbv1.Notebook) string {
+	if container := notebookContainer(notebook); container != nil {
+		return container.Image
+	}
+	return ""
+}
+
+// containerEnv returns the names of the environment variables of the
+// notebook container.
+func containerEnv(notebook *nbv1.Notebook) map[string]bool {
+	names := map[string]bool{}
+	if container := notebookContainer(notebook); container != nil {
+		for _, envVar := range container.Env {
+			names[envVar.Name] = true
+		}
+	}
+	return names
+}
+
+// imageRewriteWarning returns the warning reporting the replacement of the
+// given image of the notebook container by the one resolved from its
+// ImageStream selection, empty if the image was not replaced.
+func imageRewriteWarning(notebook *nbv1.Notebook, image string) string {
+	resolved := containerImage(notebook)
+	if image == "" || resolved == image {
+		return ""
+	}
+	return fmt.Sprintf("The image %s of the notebook was replaced by %s, resolved from its %s selection.", image,
+		resolved, notebook.GetAnnotations()[AnnotationLastImageSelection])
+}
+
+// caBundleWarning returns the warning reporting the environment variables
+// injected in the notebook container with the trusted CA bundle, given the
+// names of its variables before the injection, empty if none was injected.
+func caBundleWarning(notebook *nbv1.Notebook, env map[string]bool) string {
+	injected := []string{}
+	for name := range containerEnv(notebook) {
+		if !env[name] {
+			injected = append(injected, name)
+		}
+	}
+	if len(injected) == 0 {
+		return ""
+	}
+	sort.Strings(injected)
+	return fmt.Sprintf("The trusted CA bundle was mounted in the notebook, setting the environment variables %s.",
+		strings.Join(injected, ", "))
+}
+
+// updatePendingWarning returns the warning reporting the changes of the pod
+// template deferred until the notebook is restarted, empty if they were
+// already reported to a previous update.
+func updatePendingWarning(oldNotebook *nbv1.Notebook, reason string) string {
+	if oldNotebook != nil && oldNotebook.GetAnnotations()[AnnotationUpdatePending] == reason {
+		return ""
+	}
+	return fmt.Sprintf("The notebook is running, the changes of its pod template are deferred until it is "+
+		"restarted: %s", reason)
+}
+
+// withoutAdmissionTrail returns a copy of the annotations without the ones
+// recording the admission trail, nil if no annotation is left.
+func withoutAdmissionTrail(annotations map[string]string) map[string]string {
+	var filtered map[string]string
+	for key, value := range annotations {
+		if key == AnnotationLastAdmissionUID {
+			continue
+		}
+		if filtered == nil {
+			filtered = map[string]string{}
+		}
+		filtered[key] = value
+	}
+	return filtered
 }
 
 // InjectDecoder injects the decoder.
@@ -331,6 +907,12 @@ func (w *NotebookWebhook) maybeRestartRunningNotebook(ctx context.Context, req a
 		return mutatedNotebook, NoPendingUpdates, nil
 	}
 
+	// The users of the notebook prefer it restarted with the changes
+	if allowed, _ := strconv.ParseBool(mutatedNotebook.GetAnnotations()[AnnotationAllowInPlaceUpdates]); allowed {
+		log.Info("Not blocking update, notebook allows in-place updates")
+		return mutatedNotebook, NoPendingUpdates, nil
+	}
+
 	// fetch the updated Notebook CR that was sent to the Webhook
 	updatedNotebook := &nbv1.Notebook{}
 	err = w.Decoder.Decode(req, updatedNotebook)
@@ -367,53 +949,44 @@ func (w *NotebookWebhook) maybeRestartRunningNotebook(ctx context.Context, req a
 	return mutatedNotebook, &UpdatesPending{Reason: diff}, nil
 }
 
-// CheckAndMountCACertBundle checks if the odh-trusted-ca-bundle ConfigMap is present
+// CheckAndMountCACertBundle mounts the workbench-trusted-ca-bundle ConfigMap
+// in the notebook when the odh-trusted-ca-bundle ConfigMap is present. The
+// webhook performs no write: the workbench-trusted-ca-bundle ConfigMap is
+// created by the controller (see CreateNotebookCertConfigMap), before the
+// reconciliation lock of the new notebooks is removed, and the ConfigMaps
+// are read from the cache of the manager.
 func CheckAndMountCACertBundle(ctx context.Context, cli client.Client, notebook *nbv1.Notebook, log logr.Logger) error {
-
-	workbenchConfigMapName := "workbench-trusted-ca-bundle"
-	odhConfigMapName := "odh-trusted-ca-bundle"
-
 	// if the odh-trusted-ca-bundle ConfigMap is not present, skip the process
 	// as operator might have disabled the feature.
 	odhConfigMap := &corev1.ConfigMap{}
-	odhErr := cli.Get(ctx, client.ObjectKey{Namespace: notebook.Namespace, Name: odhConfigMapName}, odhConfigMap)
+	odhErr := cli.Get(ctx, client.ObjectKey{Namespace: notebook.Namespace, Name: TrustedCABundleConfigMapName},
+		odhConfigMap)
 	if odhErr != nil {
 		log.Info("odh-trusted-ca-bundle ConfigMap is not present, not starting mounting process.")
 		return nil
 	}
 
-	// if the workbench-trusted-ca-bundle ConfigMap is not present,
-	// controller was not successful in creating the ConfigMap, skip the process
+	// if the workbench-trusted-ca-bundle ConfigMap is not present yet, only
+	// mount it if the controller is going to create it, i.e. the
+	// odh-trusted-ca-bundle ConfigMap holds a CA bundle, otherwise the
+	// controller would unmount it
 	workbenchConfigMap := &corev1.ConfigMap{}
-	err := cli.Get(ctx, client.ObjectKey{Namespace: notebook.Namespace, Name: workbenchConfigMapName}, workbenchConfigMap)
-	if err != nil {
-		log.Info("workbench-trusted-ca-bundle ConfigMap is not present, start creating it...")
-		// create the ConfigMap if it does not exist
-		workbenchConfigMap = &corev1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      workbenchConfigMapName,
-				Namespace: notebook.Namespace,
-				Labels:    map[string]string{"opendatahub.io/managed-by": "workbenches"},
-			},
-			Data: map[string]string{
-				"ca-bundle.crt": odhConfigMap.Data["ca-bundle.crt"],
-			},
-		}
-		err = cli.Create(ctx, workbenchConfigMap)
-		if err != nil {
-			log.Info("Failed to create workbench-trusted-ca-bundle ConfigMap")
+	err := cli.Get(ctx, client.ObjectKey{Namespace: notebook.Namespace, Name: WorkbenchTrustedCABundleConfigMapName},
+		workbenchConfigMap)
+	if apierrs.IsNotFound(err) {
+		if odhConfigMap.Data["ca-bundle.crt"] == "" {
+			log.Info("odh-trusted-ca-bundle ConfigMap holds no CA bundle, not starting mounting process.")
 			return nil
 		}
-		log.Info("Created workbench-trusted-ca-bundle ConfigMap")
+		log.Info("workbench-trusted-ca-bundle ConfigMap is not present yet, it is created by the controller")
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the workbench-trusted-ca-bundle ConfigMap, not starting mounting process.")
+		return nil
 	}
 
-	cm := workbenchConfigMap
-	if cm.Name == workbenchConfigMapName {
-		// Inject the trusted-ca volume and environment variables
-		log.Info("Injecting trusted-ca volume and environment variables")
-		return InjectCertConfig(notebook, workbenchConfigMapName)
-	}
-	return nil
+	// Inject the trusted-ca volume and environment variables
+	log.Info("Injecting trusted-ca volume and environment variables")
+	return InjectCertConfig(notebook, WorkbenchTrustedCABundleConfigMapName)
 }
 
 func InjectCertConfig(notebook *nbv1.Notebook, configMapName string) error {
@@ -467,7 +1040,7 @@ func InjectCertConfig(notebook *nbv1.Notebook, configMapName string) error {
 	// Update Notebook Image container with env variables and Volume Mounts
 	for _, container := range *notebookContainers {
 		// Update notebook image container with env Variables
-		if container.Name == notebook.Name {
+		if container.Name == PrimaryContainerName(notebook) {
 			var newVars []corev1.EnvVar
 			imgContainer = container
 
@@ -516,7 +1089,7 @@ func InjectCertConfig(notebook *nbv1.Notebook, configMapName string) error {
 
 			// Update container with Env and Volume Mount Changes
 			for index, container := range *notebookContainers {
-				if container.Name == notebook.Name {
+				if container.Name == PrimaryContainerName(notebook) {
 					(*notebookContainers)[index] = imgContainer
 					imgContainerExists = true
 					break
@@ -532,37 +1105,91 @@ func InjectCertConfig(notebook *nbv1.Notebook, configMapName string) error {
 	return nil
 }
 
-// SetContainerImageFromRegistry checks if there is an internal registry and takes the corresponding actions to set the container.image value.
-// If an internal registry is detected, it uses the default values specified in the Notebook Custom Resource (CR).
-// Otherwise, it checks the last-image-selection annotation to find the image stream and fetches the image from status.dockerImageReference,
-// assigning it to the container.image value.
-func SetContainerImageFromRegistry(ctx context.Context, config *rest.Config, notebook *nbv1.Notebook, log logr.Logger) error {
-	// Create a dynamic client
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		log.Error(err, "Error creating dynamic client")
-		return err
+// notebookImageStreamNamespaces returns the namespaces the image selection of
+// the notebook is resolved from, in order: the ImageStreamNamespaces, then the
+// namespace of the notebook, for the bring-your-own-notebook images published
+// by the project teams without cluster-level access.
+func notebookImageStreamNamespaces(notebook *nbv1.Notebook) []string {
+	namespaces := append([]string{}, ImageStreamNamespaces...)
+	if notebook.Namespace == "" {
+		return namespaces
 	}
-	// Specify the GroupVersionResource for imagestreams
+	for _, namespace := range ImageStreamNamespaces {
+		if namespace == notebook.Namespace {
+			return namespaces
+		}
+	}
+	return append(namespaces, notebook.Namespace)
+}
+
+// getImageStream fetches the named ImageStream from the given namespace
+// directly by name. A nil object is returned when the ImageStream does not
+// exist, the other errors, e.g. forbidden, are returned.
+func getImageStream(ctx context.Context, dynamicClient dynamic.Interface, namespace, name string,
+	log logr.Logger) (*unstructured.Unstructured, error) {
 	ims := schema.GroupVersionResource{
 		Group:    "image.openshift.io",
 		Version:  "v1",
 		Resource: "imagestreams",
 	}
 
+	imagestream, err := dynamicClient.Resource(ims).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		log.V(1).Info("Imagestream not found", "namespace", namespace, "name", name)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return imagestream, nil
+}
+
+// getImageStreamTagReference returns the most recent dockerImageReference of
+// the given tag in the ImageStream status, or an empty string if the tag has
+// not been imported.
+func getImageStreamTagReference(imagestream *unstructured.Unstructured, tag string) string {
+	tags, _, _ := unstructured.NestedSlice(imagestream.Object, "status", "tags")
+	for _, t := range tags {
+		tagMap, ok := t.(map[string]interface{})
+		if !ok || tagMap["tag"] != tag {
+			continue
+		}
+		items, _, _ := unstructured.NestedSlice(tagMap, "items")
+		if len(items) == 0 {
+			continue
+		}
+		// Sort items by creationTimestamp to get the most recent one
+		sort.Slice(items, func(i, j int) bool {
+			iTime, _ := items[i].(map[string]interface{})["created"].(string)
+			jTime, _ := items[j].(map[string]interface{})["created"].(string)
+			return iTime > jTime // Lexicographical comparison of RFC3339 timestamps
+		})
+		imageHash, _ := items[0].(map[string]interface{})["dockerImageReference"].(string)
+		return imageHash
+	}
+	return ""
+}
+
+// SetContainerImageFromRegistry checks if there is an internal registry and takes the corresponding actions to set the container.image value.
+// If an internal registry is detected, it uses the default values specified in the Notebook Custom Resource (CR).
+// Otherwise, it checks the last-image-selection annotation to find the image stream and fetches the image from status.dockerImageReference,
+// assigning it to the container.image value.
+// An ImageResolutionError is returned when the selected image cannot be resolved.
+func SetContainerImageFromRegistry(ctx context.Context, dynamicClient dynamic.Interface, notebook *nbv1.Notebook,
+	dns ClusterDNSConfig, log logr.Logger) error {
 	annotations := notebook.GetAnnotations()
 	if annotations != nil {
-		if imageSelection, exists := annotations["notebooks.opendatahub.io/last-image-selection"]; exists {
+		if imageSelection, exists := annotations[AnnotationLastImageSelection]; exists {
 
 			containerFound := false
 			// Iterate over containers to find the one matching the notebook name
 			for i, container := range notebook.Spec.Template.Spec.Containers {
-				if container.Name == notebook.Name {
+				if container.Name == PrimaryContainerName(notebook) {
 					containerFound = true
 
 					// Check if the container.Image value has an internal registry, if so  will pickup this without extra checks.
 					// This value constructed on the initialization of the Notebook CR.
-					if strings.Contains(container.Image, "image-registry.openshift-image-registry.svc:5000") {
+					if dns.IsInternalRegistryImage(container.Image) {
 						log.Info("Internal registry found. Will pick up the default value from image field.")
 						return nil
 					} else {
@@ -575,69 +1202,53 @@ func SetContainerImageFromRegistry(ctx context.Context, config *rest.Config, not
 							return fmt.Errorf("invalid image selection format")
 						}
 
-						// Specify the namespaces to search in
-						namespaces := []string{"opendatahub", "redhat-ods-applications"}
+						// Specify the namespaces to search in, the namespace of
+						// the notebook last
+						namespaces := notebookImageStreamNamespaces(notebook)
 						imagestreamFound := false
 						for _, namespace := range namespaces {
-							// List imagestreams in the specified namespace
-							imagestreams, err := dynamicClient.Resource(ims).Namespace(namespace).List(ctx, metav1.ListOptions{})
+							// Fetch the selected imagestream in the specified namespace
+							imagestream, err := getImageStream(ctx, dynamicClient, namespace, imageSelected[0], log)
 							if err != nil {
-								log.Info("Cannot list imagestreams", "error", err)
+								log.Info("Cannot get the imagestream", "namespace", namespace,
+									"name", imageSelected[0], "error", err)
+								continue
+							}
+							if imagestream == nil {
 								continue
 							}
 
-							// Iterate through the imagestreams to find matches
-							for _, item := range imagestreams.Items {
-								metadata := item.Object["metadata"].(map[string]interface{})
-								name := metadata["name"].(string)
-
-								// Match with the ImageStream name
-								if name == imageSelected[0] {
-									status := item.Object["status"].(map[string]interface{})
-
-									// Match to the corresponding tag of the image
-									tags := status["tags"].([]interface{})
-									for _, t := range tags {
-										tagMap := t.(map[string]interface{})
-										tagName := tagMap["tag"].(string)
-										if tagName == imageSelected[1] {
-											items := tagMap["items"].([]interface{})
-											if len(items) > 0 {
-												// Sort items by creationTimestamp to get the most recent one
-												sort.Slice(items, func(i, j int) bool {
-													iTime := items[i].(map[string]interface{})["created"].(string)
-													jTime := items[j].(map[string]interface{})["created"].(string)
-													return iTime > jTime // Lexicographical comparison of RFC3339 timestamps
-												})
-												imageHash := items[0].(map[string]interface{})["dockerImageReference"].(string)
-												// Update the Containers[i].Image value
-												notebook.Spec.Template.Spec.Containers[i].Image = imageHash
-												// Update the JUPYTER_IMAGE environment variable with the image selection for example "jupyter-datascience-notebook:2023.2"
-												for i, envVar := range container.Env {
-													if envVar.Name == "JUPYTER_IMAGE" {
-														container.Env[i].Value = imageSelection
-														break
-													}
-												}
-												imagestreamFound = true
-												break
-											}
-										}
-									}
-								}
+							// Match to the corresponding tag of the image
+							imageHash := getImageStreamTagReference(imagestream, imageSelected[1])
+							if imageHash == "" {
+								continue
 							}
-							if imagestreamFound {
-								break
+							// Update the Containers[i].Image value
+							notebook.Spec.Template.Spec.Containers[i].Image = imageHash
+							// Update the JUPYTER_IMAGE environment variable with the image selection for example "jupyter-datascience-notebook:2023.2"
+							for i, envVar := range container.Env {
+								if envVar.Name == "JUPYTER_IMAGE" {
+									container.Env[i].Value = imageSelection
+									break
+								}
 							}
+							imagestreamFound = true
+							break
 						}
 						if !imagestreamFound {
 							log.Error(nil, "Imagestream not found in any of the specified namespaces", "imageSelected", imageSelected[0], "tag", imageSelected[1])
+							return &ImageResolutionError{Message: fmt.Sprintf(
+								"unable to resolve the image %q from the ImageStreams in %v, the image %q is used as is",
+								imageSelection, namespaces, container.Image)}
 						}
 					}
 				}
 			}
 			if !containerFound {
 				log.Error(nil, "No container found matching the notebook name", "notebookName", notebook.Name)
+				return &ImageResolutionError{Message: fmt.Sprintf(
+					"unable to resolve the image %q, no container found matching the notebook name %q",
+					imageSelection, notebook.Name)}
 			}
 		}
 	}
@@ -0,0 +1,142 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DefaultWebhookTimeout is the time the API server waits for the webhook
+	// when the timeoutSeconds of the webhook configuration is not set.
+	DefaultWebhookTimeout = 10 * time.Second
+
+	// WebhookSelfTestNamePrefix prefixes the name of the notebooks sent by
+	// the self-test.
+	WebhookSelfTestNamePrefix = "odh-notebook-webhook-selftest-"
+)
+
+// +kubebuilder:rbac:groups=kubeflow.org,resources=notebooks,verbs=create
+
+// webhookResult returns the result label of an admission response.
+func webhookResult(response admission.Response) string {
+	switch {
+	case response.Allowed:
+		return "allowed"
+	case response.Result != nil && response.Result.Code >= http.StatusInternalServerError:
+		return "error"
+	default:
+		return "denied"
+	}
+}
+
+// instrumentedWebhook observes the latency of the admission requests handled
+// by the wrapped handler.
+type instrumentedWebhook struct {
+	admission.Handler
+	timeout time.Duration
+}
+
+// InstrumentWebhook wraps the admission handler to export the latency of the
+// requests, and to count the requests that took longer than the timeout of the
+// API server, which rejected them whatever the response.
+func InstrumentWebhook(handler admission.Handler, timeout time.Duration) admission.Handler {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	return &instrumentedWebhook{Handler: handler, timeout: timeout}
+}
+
+func (w *instrumentedWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	start := time.Now()
+	response := w.Handler.Handle(ctx, req)
+	duration := time.Since(start)
+
+	operation := string(req.Operation)
+	webhookRequestDurationSeconds.WithLabelValues(operation, webhookResult(response)).Observe(duration.Seconds())
+	if duration > w.timeout {
+		webhookTimeoutsTotal.WithLabelValues(operation).Inc()
+		logr.FromContextOrDiscard(ctx).Info("Admission request handled after the API server timeout",
+			"duration", duration, "timeout", w.timeout)
+	}
+	return response
+}
+
+// WebhookSelfTest periodically sends a dry-run notebook creation through the
+// API server, so that the webhook degradation is observed before the users
+// fail to create their notebooks.
+type WebhookSelfTest struct {
+	client.Client
+	Log logr.Logger
+	// Namespace is the namespace of the dry-run notebooks, the controller
+	// namespace if empty.
+	Namespace string
+	// Interval is the interval between two self-tests.
+	Interval time.Duration
+}
+
+// Start runs the self-tests until the context is cancelled.
+func (s *WebhookSelfTest) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.Run(ctx); err != nil {
+			s.Log.Error(err, "Notebook webhook self-test failed")
+		}
+	}, s.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes the self-tests run by the leader only.
+func (s *WebhookSelfTest) NeedLeaderElection() bool {
+	return true
+}
+
+// Run sends a dry-run notebook creation and records its outcome.
+func (s *WebhookSelfTest) Run(ctx context.Context) error {
+	namespace := s.Namespace
+	if namespace == "" {
+		namespace = getControllerNamespace()
+	}
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: WebhookSelfTestNamePrefix,
+			Namespace:    namespace,
+		},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "notebook", Image: "notebook"}},
+		}}},
+	}
+
+	start := time.Now()
+	err := s.Create(ctx, notebook, client.DryRunAll)
+	webhookSelfTestDurationSeconds.Set(time.Since(start).Seconds())
+	if err != nil {
+		webhookSelfTestSuccess.Set(0)
+		webhookSelfTestFailuresTotal.Inc()
+		return err
+	}
+	webhookSelfTestSuccess.Set(1)
+	return nil
+}
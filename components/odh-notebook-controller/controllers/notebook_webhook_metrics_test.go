@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func metricValue(t *testing.T, collector prometheus.Metric) *dto.Metric {
+	metric := &dto.Metric{}
+	require.NoError(t, collector.Write(metric))
+	return metric
+}
+
+func TestWebhookResult(t *testing.T) {
+	assert.Equal(t, "allowed", webhookResult(admission.Allowed("")))
+	assert.Equal(t, "denied", webhookResult(admission.Denied("")))
+	assert.Equal(t, "error", webhookResult(admission.Errored(http.StatusInternalServerError, errors.New("boom"))))
+	assert.Equal(t, "denied", webhookResult(admission.Errored(http.StatusBadRequest, errors.New("bad"))))
+}
+
+func TestInstrumentWebhook(t *testing.T) {
+	ctx := context.Background()
+	delay := 20 * time.Millisecond
+	handler := admission.HandlerFunc(func(ctx context.Context, req admission.Request) admission.Response {
+		time.Sleep(delay)
+		return admission.Allowed("")
+	})
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Delete}}
+	timeouts := func() float64 {
+		return metricValue(t, webhookTimeoutsTotal.WithLabelValues(string(admissionv1.Delete))).GetCounter().GetValue()
+	}
+	observations := func() uint64 {
+		histogram := webhookRequestDurationSeconds.WithLabelValues(string(admissionv1.Delete), "allowed")
+		return metricValue(t, histogram.(prometheus.Metric)).GetHistogram().GetSampleCount()
+	}
+	initialTimeouts, initialObservations := timeouts(), observations()
+
+	// The requests handled within the timeout are only observed
+	response := InstrumentWebhook(handler, time.Minute).Handle(ctx, req)
+	assert.True(t, response.Allowed)
+	assert.Equal(t, initialObservations+1, observations())
+	assert.Equal(t, initialTimeouts, timeouts())
+
+	// The requests handled after the timeout are counted as rejected
+	InstrumentWebhook(handler, delay/2).Handle(ctx, req)
+	assert.Equal(t, initialObservations+2, observations())
+	assert.Equal(t, initialTimeouts+1, timeouts())
+}
+
+func TestWebhookSelfTest(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, nbv1.AddToScheme(scheme))
+	var createErr error
+	var dryRun bool
+	selfTest := &WebhookSelfTest{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				createOpts := &client.CreateOptions{}
+				createOpts.ApplyOptions(opts)
+				dryRun = len(createOpts.DryRun) > 0
+				return createErr
+			},
+		}).Build(),
+		Log:       logr.Discard(),
+		Namespace: "ns",
+	}
+	failures := func() float64 {
+		return metricValue(t, webhookSelfTestFailuresTotal).GetCounter().GetValue()
+	}
+	initialFailures := failures()
+
+	require.NoError(t, selfTest.Run(ctx))
+	assert.True(t, dryRun)
+	assert.Equal(t, 1.0, metricValue(t, webhookSelfTestSuccess).GetGauge().GetValue())
+	assert.Equal(t, initialFailures, failures())
+
+	createErr = errors.New("context deadline exceeded")
+	assert.Error(t, selfTest.Run(ctx))
+	assert.Equal(t, 0.0, metricValue(t, webhookSelfTestSuccess).GetGauge().GetValue())
+	assert.Equal(t, initialFailures+1, failures())
+}
@@ -0,0 +1,59 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// noOpUpdateAnnotations are the annotations updated periodically by the
+// culler and the controller, which the webhook neither reads nor mutates.
+var noOpUpdateAnnotations = map[string]bool{
+	culler.LAST_ACTIVITY_ANNOTATION: true,
+	AnnotationGPUHours:              true,
+	AnnotationRecommendedSize:       true,
+	AnnotationRunningHours:          true,
+}
+
+// NotebookUpdateIsNoOp returns true if the update changes none of the fields
+// the webhook mutates or validates, i.e. only the status, the metadata other
+// than the labels and annotations, or the annotations updated periodically,
+// e.g. the last activity updated by the culler. The mutation of such updates
+// would not change the notebook, which was mutated when its spec last
+// changed.
+func NotebookUpdateIsNoOp(notebook, oldNotebook *nbv1.Notebook) bool {
+	if oldNotebook == nil {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(notebook.Spec, oldNotebook.Spec) ||
+		!equality.Semantic.DeepEqual(notebook.Labels, oldNotebook.Labels) {
+		return false
+	}
+	annotations, oldAnnotations := notebook.GetAnnotations(), oldNotebook.GetAnnotations()
+	for key, value := range annotations {
+		if oldValue, ok := oldAnnotations[key]; !noOpUpdateAnnotations[key] && (!ok || oldValue != value) {
+			return false
+		}
+	}
+	for key := range oldAnnotations {
+		if _, ok := annotations[key]; !noOpUpdateAnnotations[key] && !ok {
+			return false
+		}
+	}
+	return true
+}
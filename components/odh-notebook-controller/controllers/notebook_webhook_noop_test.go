@@ -0,0 +1,96 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestNotebookUpdateIsNoOp(t *testing.T) {
+	newNotebook := func(image string, labels, annotations map[string]string) *nbv1.Notebook {
+		return &nbv1.Notebook{
+			ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", Labels: labels, Annotations: annotations},
+			Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "nb", Image: image}},
+			}}},
+		}
+	}
+	old := newNotebook("jupyter:1", map[string]string{"app": "nb"},
+		map[string]string{culler.LAST_ACTIVITY_ANNOTATION: "2024-01-01T00:00:00Z", AnnotationInjectOAuth: "true"})
+
+	for _, tt := range []struct {
+		name     string
+		notebook *nbv1.Notebook
+		noOp     bool
+	}{
+		{name: "unchanged", notebook: old.DeepCopy(), noOp: true},
+		{name: "last activity", notebook: newNotebook("jupyter:1", map[string]string{"app": "nb"},
+			map[string]string{culler.LAST_ACTIVITY_ANNOTATION: "2024-01-02T00:00:00Z", AnnotationInjectOAuth: "true",
+				AnnotationRunningHours: "1.5"}), noOp: true},
+		{name: "spec", notebook: newNotebook("jupyter:2", map[string]string{"app": "nb"}, old.Annotations)},
+		{name: "labels", notebook: newNotebook("jupyter:1", nil, old.Annotations)},
+		{name: "stopped", notebook: newNotebook("jupyter:1", map[string]string{"app": "nb"},
+			map[string]string{culler.LAST_ACTIVITY_ANNOTATION: "2024-01-01T00:00:00Z", AnnotationInjectOAuth: "true",
+				culler.STOP_ANNOTATION: "2024-01-02T00:00:00Z"})},
+		{name: "annotation removed", notebook: newNotebook("jupyter:1", map[string]string{"app": "nb"},
+			map[string]string{culler.LAST_ACTIVITY_ANNOTATION: "2024-01-01T00:00:00Z"})},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.noOp, NotebookUpdateIsNoOp(tt.notebook, old))
+		})
+	}
+	assert.False(t, NotebookUpdateIsNoOp(old, nil))
+}
+
+func TestHandleNoOpUpdate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, nbv1.AddToScheme(scheme))
+	notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+		Annotations: map[string]string{culler.LAST_ACTIVITY_ANNOTATION: "2024-01-01T00:00:00Z"}}}
+	oldRaw, err := json.Marshal(notebook)
+	require.NoError(t, err)
+	notebook.Annotations[culler.LAST_ACTIVITY_ANNOTATION] = "2024-01-02T00:00:00Z"
+	raw, err := json.Marshal(notebook)
+	require.NoError(t, err)
+
+	// The webhook has neither a client nor a configuration, the mutation
+	// would fail
+	w := &NotebookWebhook{Log: logr.Discard(), Decoder: admission.NewDecoder(scheme), NoOpUpdateFastPath: true}
+	response := w.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Name:      "nb",
+		Namespace: "ns",
+		Operation: admissionv1.Update,
+		Object:    runtime.RawExtension{Raw: raw},
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+	}})
+	assert.True(t, response.Allowed)
+	assert.Empty(t, response.Patches)
+}
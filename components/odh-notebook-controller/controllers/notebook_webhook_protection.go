@@ -0,0 +1,129 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// AnnotationCreator records the user who created the notebook.
+const AnnotationCreator = "notebooks.opendatahub.io/creator"
+
+// controllerAnnotations are the annotations managed by the controller. The
+// users cannot set or change them, only the ones mapped to true can be removed
+// by the users, e.g. to opt back in to spot nodes after an interruption.
+var controllerAnnotations = map[string]bool{
+	AnnotationAdoptionPlan:             false,
+	AnnotationCreator:                  false,
+	AnnotationDashboardDefaults:        false,
+	AnnotationGPUHours:                 false,
+	AnnotationHibernated:               false,
+	AnnotationImagePullSecretsInjected: false,
+	AnnotationModelEnvInjected:         false,
+	AnnotationMutationProvenance:       false,
+	AnnotationOAuthServiceAccount:      false,
+	AnnotationPropagatedLabels:         false,
+	AnnotationPipelinesAccess:          false,
+	AnnotationRestartRequested:         false,
+	AnnotationRollout:                  false,
+	AnnotationRunningHours:             false,
+	AnnotationRunningSince:             false,
+	AnnotationStoppedByNamespace:       false,
+	AnnotationRolloutRestartTime:       false,
+	AnnotationSpotInjected:             false,
+	AnnotationTemplateRequest:          false,
+	AnnotationSpotInterrupted:          true,
+	AnnotationImageDeprecated:          true,
+	AnnotationLastAdmissionUID:         true,
+	AnnotationRecommendedSize:          true,
+	AnnotationUpdatePending:            true,
+	AnnotationVolumesAttached:          true,
+}
+
+// ServiceAccountUsername returns the username of the given service account.
+func ServiceAccountUsername(namespace, name string) string {
+	return "system:serviceaccount:" + namespace + ":" + name
+}
+
+// ControllerUsername returns the username of the given service account of the
+// controller namespace.
+func ControllerUsername(serviceAccount string) string {
+	return ServiceAccountUsername(getControllerNamespace(), serviceAccount)
+}
+
+// protectsAnnotations returns true if the controller-owned annotations must
+// be protected from the admission request, i.e. the request does not come from
+// the controller service account. The protection is disabled if the controller
+// username is unknown.
+func (w *NotebookWebhook) protectsAnnotations(req admission.Request) bool {
+	return w.ControllerUsername != "" && req.UserInfo.Username != w.ControllerUsername
+}
+
+// ProtectControllerAnnotations reverts the changes of the controller-owned
+// annotations of the notebook, including the setting of the reconciliation
+// lock, and returns the reverted annotations. The old notebook is nil on
+// creation.
+func ProtectControllerAnnotations(notebook, oldNotebook *nbv1.Notebook) []string {
+	var oldAnnotations map[string]string
+	if oldNotebook != nil {
+		oldAnnotations = oldNotebook.GetAnnotations()
+	}
+	annotations := notebook.GetAnnotations()
+
+	reverted := []string{}
+	revert := func(key string) {
+		if oldValue, ok := oldAnnotations[key]; ok {
+			annotations[key] = oldValue
+		} else {
+			delete(annotations, key)
+		}
+		reverted = append(reverted, key)
+	}
+	for key, removable := range controllerAnnotations {
+		value, ok := annotations[key]
+		oldValue, oldOk := oldAnnotations[key]
+		if ok && (!oldOk || value != oldValue) {
+			revert(key)
+		} else if !ok && oldOk && !removable {
+			if annotations == nil {
+				annotations = map[string]string{}
+				notebook.SetAnnotations(annotations)
+			}
+			revert(key)
+		}
+	}
+	// The users can stop the notebooks, but not lock their reconciliation
+	if annotations[culler.STOP_ANNOTATION] == AnnotationValueReconciliationLock &&
+		oldAnnotations[culler.STOP_ANNOTATION] != AnnotationValueReconciliationLock {
+		revert(culler.STOP_ANNOTATION)
+	}
+
+	sort.Strings(reverted)
+	return reverted
+}
+
+// protectedAnnotationsWarning returns the warning returned to the users whose
+// changes of the controller-owned annotations are reverted.
+func protectedAnnotationsWarning(reverted []string) string {
+	return fmt.Sprintf("The annotations %s are managed by the controller, their changes are ignored",
+		strings.Join(reverted, ", "))
+}
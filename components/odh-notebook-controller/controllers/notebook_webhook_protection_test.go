@@ -0,0 +1,117 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestProtectControllerAnnotations(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		old         map[string]string
+		annotations map[string]string
+		expected    map[string]string
+		reverted    []string
+	}{
+		{
+			name:        "create",
+			annotations: map[string]string{AnnotationCreator: "mallory", AnnotationUpdatePending: "x", "user": "value"},
+			expected:    map[string]string{"user": "value"},
+			reverted:    []string{AnnotationCreator, AnnotationUpdatePending},
+		},
+		{
+			name:        "create with lock",
+			annotations: map[string]string{culler.STOP_ANNOTATION: AnnotationValueReconciliationLock},
+			expected:    map[string]string{},
+			reverted:    []string{culler.STOP_ANNOTATION},
+		},
+		{
+			name:        "unchanged",
+			old:         map[string]string{AnnotationCreator: "alice", AnnotationUpdatePending: "x"},
+			annotations: map[string]string{AnnotationCreator: "alice", AnnotationUpdatePending: "x", "user": "value"},
+			expected:    map[string]string{AnnotationCreator: "alice", AnnotationUpdatePending: "x", "user": "value"},
+			reverted:    []string{},
+		},
+		{
+			name:        "changed",
+			old:         map[string]string{AnnotationCreator: "alice"},
+			annotations: map[string]string{AnnotationCreator: "mallory"},
+			expected:    map[string]string{AnnotationCreator: "alice"},
+			reverted:    []string{AnnotationCreator},
+		},
+		{
+			name:        "removed",
+			old:         map[string]string{AnnotationCreator: "alice", AnnotationSpotInterrupted: "true"},
+			annotations: nil,
+			expected:    map[string]string{AnnotationCreator: "alice"},
+			reverted:    []string{AnnotationCreator},
+		},
+		{
+			name:        "stopped",
+			old:         map[string]string{},
+			annotations: map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"},
+			expected:    map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"},
+			reverted:    []string{},
+		},
+		{
+			name:        "locked",
+			old:         map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"},
+			annotations: map[string]string{culler.STOP_ANNOTATION: AnnotationValueReconciliationLock},
+			expected:    map[string]string{culler.STOP_ANNOTATION: "2024-01-01T00:00:00Z"},
+			reverted:    []string{culler.STOP_ANNOTATION},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			notebook := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			var oldNotebook *nbv1.Notebook
+			if tt.old != nil {
+				oldNotebook = &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{Annotations: tt.old}}
+			}
+			reverted := ProtectControllerAnnotations(notebook, oldNotebook)
+			assert.Equal(t, tt.reverted, reverted)
+			if len(tt.expected) == 0 {
+				assert.Empty(t, notebook.Annotations)
+			} else {
+				assert.Equal(t, tt.expected, notebook.Annotations)
+			}
+		})
+	}
+}
+
+func TestProtectsAnnotations(t *testing.T) {
+	controller := ServiceAccountUsername("opendatahub", "odh-notebook-controller-manager")
+	request := func(username string) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			UserInfo: authenticationv1.UserInfo{Username: username},
+		}}
+	}
+
+	w := &NotebookWebhook{ControllerUsername: controller}
+	assert.True(t, w.protectsAnnotations(request("alice")))
+	assert.False(t, w.protectsAnnotations(request(controller)))
+
+	w = &NotebookWebhook{}
+	assert.False(t, w.protectsAnnotations(request("alice")))
+}
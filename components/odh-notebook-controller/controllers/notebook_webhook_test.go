@@ -0,0 +1,374 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/culler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var imageStreamGVR = schema.GroupVersionResource{Group: "image.openshift.io", Version: "v1", Resource: "imagestreams"}
+
+func newTestImageStream(namespace, name string, tags ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "image.openshift.io/v1",
+		"kind":       "ImageStream",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"status":     map[string]interface{}{"tags": tags},
+	}}
+}
+
+func newTestDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{imageStreamGVR: "ImageStreamList"}, objects...)
+}
+
+func TestGetImageStream(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("get", func(t *testing.T) {
+		dynamicClient := newTestDynamicClient(newTestImageStream("opendatahub", "jupyter"))
+		imagestream, err := getImageStream(ctx, dynamicClient, "opendatahub", "jupyter", logr.Discard())
+		require.NoError(t, err)
+		require.NotNil(t, imagestream)
+		assert.Equal(t, "jupyter", imagestream.GetName())
+		for _, action := range dynamicClient.Actions() {
+			assert.Equal(t, "get", action.GetVerb())
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		dynamicClient := newTestDynamicClient(newTestImageStream("opendatahub", "jupyter"))
+		imagestream, err := getImageStream(ctx, dynamicClient, "opendatahub", "code-server", logr.Discard())
+		require.NoError(t, err)
+		assert.Nil(t, imagestream)
+		assert.Len(t, dynamicClient.Actions(), 1)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		dynamicClient := newTestDynamicClient(newTestImageStream("opendatahub", "jupyter"))
+		dynamicClient.PrependReactor("get", "imagestreams", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrs.NewForbidden(imageStreamGVR.GroupResource(), "", nil)
+		})
+		_, err := getImageStream(ctx, dynamicClient, "opendatahub", "jupyter", logr.Discard())
+		assert.True(t, apierrs.IsForbidden(err))
+		assert.Len(t, dynamicClient.Actions(), 1)
+	})
+}
+
+func TestGetImageStreamTagReference(t *testing.T) {
+	imagestream := newTestImageStream("opendatahub", "jupyter",
+		map[string]interface{}{"tag": "2023.2", "items": []interface{}{
+			map[string]interface{}{"created": "2024-01-01T00:00:00Z", "dockerImageReference": "quay.io/jupyter@sha256:old"},
+			map[string]interface{}{"created": "2024-06-01T00:00:00Z", "dockerImageReference": "quay.io/jupyter@sha256:new"},
+		}},
+		map[string]interface{}{"tag": "2024.1"},
+	)
+
+	assert.Equal(t, "quay.io/jupyter@sha256:new", getImageStreamTagReference(imagestream, "2023.2"))
+	assert.Equal(t, "", getImageStreamTagReference(imagestream, "2024.1"), "tag not imported")
+	assert.Equal(t, "", getImageStreamTagReference(imagestream, "2025.1"), "missing tag")
+}
+
+func TestSetContainerImageFromNotebookNamespace(t *testing.T) {
+	ctx := context.Background()
+	newNotebook := func(selection string) *nbv1.Notebook {
+		return &nbv1.Notebook{
+			ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "team",
+				Annotations: map[string]string{AnnotationLastImageSelection: selection}},
+			Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "nb", Image: "quay.io/jupyter:latest"}},
+			}}},
+		}
+	}
+	newTag := func(reference string) map[string]interface{} {
+		return map[string]interface{}{"tag": "2024.1", "items": []interface{}{
+			map[string]interface{}{"created": "2024-06-01T00:00:00Z", "dockerImageReference": reference}}}
+	}
+	dynamicClient := newTestDynamicClient(
+		newTestImageStream("opendatahub", "jupyter", newTag("quay.io/opendatahub/jupyter@sha256:odh")),
+		newTestImageStream("team", "jupyter", newTag("quay.io/team/jupyter@sha256:team")),
+		newTestImageStream("team", "byon", newTag("quay.io/team/byon@sha256:team")),
+	)
+
+	// The ImageStreams of the controller namespaces take precedence
+	notebook := newNotebook("jupyter:2024.1")
+	require.NoError(t, SetContainerImageFromRegistry(ctx, dynamicClient, notebook, ClusterDNSConfig{}, logr.Discard()))
+	assert.Equal(t, "quay.io/opendatahub/jupyter@sha256:odh", notebook.Spec.Template.Spec.Containers[0].Image)
+
+	notebook = newNotebook("byon:2024.1")
+	require.NoError(t, SetContainerImageFromRegistry(ctx, dynamicClient, notebook, ClusterDNSConfig{}, logr.Discard()))
+	assert.Equal(t, "quay.io/team/byon@sha256:team", notebook.Spec.Template.Spec.Containers[0].Image)
+
+	// The ImageStreams of the other namespaces are not used
+	notebook = newNotebook("byon:2024.1")
+	notebook.Namespace = "other-team"
+	var imageErr *ImageResolutionError
+	assert.ErrorAs(t, SetContainerImageFromRegistry(ctx, dynamicClient, notebook, ClusterDNSConfig{}, logr.Discard()),
+		&imageErr)
+}
+
+func TestCheckAndMountCACertBundle(t *testing.T) {
+	newConfigMap := func(name, bundle string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Data: map[string]string{"ca-bundle.crt": bundle}}
+	}
+	for _, tt := range []struct {
+		name     string
+		existing []client.Object
+		mounted  bool
+	}{
+		{name: "no CA bundle"},
+		{name: "empty CA bundle", existing: []client.Object{newConfigMap(TrustedCABundleConfigMapName, "")}},
+		{name: "workbench CA bundle created by the controller", mounted: true,
+			existing: []client.Object{newConfigMap(TrustedCABundleConfigMapName, "bundle")}},
+		{name: "workbench CA bundle", mounted: true, existing: []client.Object{
+			newConfigMap(TrustedCABundleConfigMapName, ""),
+			newConfigMap(WorkbenchTrustedCABundleConfigMapName, "bundle"),
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			notebook := &nbv1.Notebook{
+				ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"},
+				Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "nb", Image: "quay.io/jupyter:latest"}},
+				}}},
+			}
+			cli := newTestReconciler(t, OAuthConfig{}, tt.existing...).Client
+			configMaps := &corev1.ConfigMapList{}
+			require.NoError(t, cli.List(ctx, configMaps))
+			count := len(configMaps.Items)
+
+			require.NoError(t, CheckAndMountCACertBundle(ctx, cli, notebook, logr.Discard()))
+			if tt.mounted {
+				require.Len(t, notebook.Spec.Template.Spec.Volumes, 1)
+				assert.Equal(t, WorkbenchTrustedCABundleConfigMapName,
+					notebook.Spec.Template.Spec.Volumes[0].ConfigMap.Name)
+			} else {
+				assert.Empty(t, notebook.Spec.Template.Spec.Volumes)
+			}
+
+			// The webhook performs no write
+			require.NoError(t, cli.List(ctx, configMaps))
+			assert.Len(t, configMaps.Items, count)
+		})
+	}
+}
+
+func TestNotebookMutated(t *testing.T) {
+	original := &nbv1.Notebook{ObjectMeta: metav1.ObjectMeta{
+		Name:        "nb",
+		Annotations: map[string]string{AnnotationLastAdmissionUID: "previous"},
+	}}
+
+	mutated := original.DeepCopy()
+	mutated.Annotations = map[string]string{}
+	assert.False(t, notebookMutated(original, mutated), "no mutation")
+
+	mutated.Annotations[culler.STOP_ANNOTATION] = AnnotationValueReconciliationLock
+	assert.True(t, notebookMutated(original, mutated), "annotation added")
+
+	mutated = original.DeepCopy()
+	mutated.Spec.Template.Spec.ServiceAccountName = "nb"
+	assert.True(t, notebookMutated(original, mutated), "pod template changed")
+}
+
+func TestMutationWarnings(t *testing.T) {
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns",
+			Annotations: map[string]string{AnnotationLastImageSelection: "jupyter:2024.1"}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb", Image: "quay.io/jupyter:2024.1",
+				Env: []corev1.EnvVar{{Name: "JUPYTER_IMAGE", Value: "jupyter:2024.1"}}}},
+		}}},
+	}
+
+	image := containerImage(notebook)
+	assert.Empty(t, imageRewriteWarning(notebook, image))
+	notebook.Spec.Template.Spec.Containers[0].Image = "image-registry.openshift-image-registry.svc:5000/" +
+		"opendatahub/jupyter:2024.1"
+	assert.Equal(t, "The image quay.io/jupyter:2024.1 of the notebook was replaced by image-registry.openshift-"+
+		"image-registry.svc:5000/opendatahub/jupyter:2024.1, resolved from its jupyter:2024.1 selection.",
+		imageRewriteWarning(notebook, image))
+
+	env := containerEnv(notebook)
+	assert.Empty(t, caBundleWarning(notebook, env))
+	require.NoError(t, InjectCertConfig(notebook, "workbench-trusted-ca-bundle"))
+	assert.Equal(t, "The trusted CA bundle was mounted in the notebook, setting the environment variables "+
+		"GIT_SSL_CAINFO, PIPELINES_SSL_SA_CERTS, PIP_CERT, REQUESTS_CA_BUNDLE, SSL_CERT_FILE.",
+		caBundleWarning(notebook, env))
+	assert.Empty(t, caBundleWarning(notebook, containerEnv(notebook)), "already mounted")
+
+	// The deferred changes are reported once
+	assert.Contains(t, updatePendingWarning(notebook, "image changed"), "deferred until it is restarted: image changed")
+	notebook.Annotations[AnnotationUpdatePending] = "image changed"
+	assert.Empty(t, updatePendingWarning(notebook, "image changed"))
+}
+
+func TestHandleImageResolution(t *testing.T) {
+	// API server without any ImageStream
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(apierrs.NewNotFound(imageStreamGVR.GroupResource(), "jupyter").Status())
+	}))
+	defer server.Close()
+
+	newRequest := func(t *testing.T, operation admissionv1.Operation, dryRun bool,
+		annotations map[string]string) admission.Request {
+		notebook := &nbv1.Notebook{
+			ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", Annotations: annotations},
+			Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "nb", Image: "quay.io/jupyter:latest"}},
+			}}},
+		}
+		if operation == admissionv1.Update {
+			notebook.UID = "nb-uid"
+		}
+		raw, err := json.Marshal(notebook)
+		require.NoError(t, err)
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "admission-uid",
+			Name:      "nb",
+			Namespace: "ns",
+			Operation: operation,
+			DryRun:    &dryRun,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	for _, tt := range []struct {
+		name        string
+		operation   admissionv1.Operation
+		dryRun      bool
+		strict      bool
+		annotations map[string]string
+		denied      bool
+		event       bool
+	}{
+		{name: "warning on create", operation: admissionv1.Create},
+		{name: "warning and event on update", operation: admissionv1.Update, event: true},
+		{name: "no event on dry-run", operation: admissionv1.Update, dryRun: true},
+		{name: "strict", operation: admissionv1.Create, denied: true,
+			annotations: map[string]string{AnnotationStrictImageResolution: "true"}},
+		{name: "strict controller", operation: admissionv1.Create, strict: true, denied: true},
+		// The annotation cannot relax the resolution of the controller
+		{name: "strict controller not relaxed", operation: admissionv1.Create, strict: true, denied: true,
+			annotations: map[string]string{AnnotationStrictImageResolution: "false"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := map[string]string{
+				"notebooks.opendatahub.io/last-image-selection": "jupyter:2023.2",
+				culler.STOP_ANNOTATION:                          "2024-01-01T00:00:00Z",
+			}
+			for key, value := range tt.annotations {
+				annotations[key] = value
+			}
+			scheme := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(scheme))
+			require.NoError(t, nbv1.AddToScheme(scheme))
+			recorder := record.NewFakeRecorder(10)
+			w := &NotebookWebhook{
+				Log:                   logr.Discard(),
+				Client:                fake.NewClientBuilder().WithScheme(scheme).Build(),
+				Config:                &rest.Config{Host: server.URL},
+				Decoder:               admission.NewDecoder(scheme),
+				Recorder:              recorder,
+				StrictImageResolution: tt.strict,
+			}
+
+			response := w.Handle(context.Background(), newRequest(t, tt.operation, tt.dryRun, annotations))
+			if tt.denied {
+				assert.False(t, response.Allowed)
+				assert.Contains(t, response.Result.Message, "jupyter:2023.2")
+				assert.Empty(t, recorder.Events)
+				return
+			}
+			assert.True(t, response.Allowed)
+			require.Len(t, response.Warnings, 1)
+			assert.Contains(t, response.Warnings[0], "jupyter:2023.2")
+			if tt.event {
+				require.Len(t, recorder.Events, 1)
+				assert.Contains(t, <-recorder.Events, "ImageResolutionFailed")
+			} else {
+				assert.Empty(t, recorder.Events)
+			}
+		})
+	}
+}
+
+func TestMaybeRestartRunningNotebookInPlace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, nbv1.AddToScheme(scheme))
+	w := &NotebookWebhook{Log: logr.Discard(), Decoder: admission.NewDecoder(scheme)}
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns", Annotations: map[string]string{}},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb", Image: "jupyter:1"}},
+		}}},
+	}
+
+	for _, allowed := range []bool{false, true} {
+		if allowed {
+			notebook.Annotations[AnnotationAllowInPlaceUpdates] = "true"
+		}
+		raw, err := json.Marshal(notebook)
+		require.NoError(t, err)
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: raw},
+		}}
+		mutated := notebook.DeepCopy()
+		mutated.Spec.Template.Spec.Containers[0].Image = "jupyter:2"
+
+		result, pending, err := w.maybeRestartRunningNotebook(context.Background(), req, mutated)
+		require.NoError(t, err)
+		if allowed {
+			assert.Equal(t, NoPendingUpdates, pending)
+			assert.Equal(t, "jupyter:2", result.Spec.Template.Spec.Containers[0].Image)
+		} else {
+			assert.NotEqual(t, NoPendingUpdates, pending)
+			assert.Equal(t, "jupyter:1", result.Spec.Template.Spec.Containers[0].Image)
+		}
+	}
+}
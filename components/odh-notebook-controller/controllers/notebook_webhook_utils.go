@@ -16,8 +16,13 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
+
 	"github.com/google/go-cmp/cmp"
 
 	"github.com/go-logr/logr"
@@ -32,49 +37,200 @@ var (
 	NoPendingUpdates = &UpdatesPending{}
 )
 
-// FirstDifferenceReporter is a custom go-cmp reporter that only records the first difference.
-type FirstDifferenceReporter struct {
-	path cmp.Path
-	diff string
+const (
+	// ChangeAdded, ChangeRemoved and ChangeModified are the types of the
+	// pending changes.
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+
+	// maxPendingChangesSize caps the size of the serialized pending changes,
+	// well below the size limit of the annotations.
+	maxPendingChangesSize = 4096
+	// maxPendingChangeValueLength caps the length of the values of a change.
+	maxPendingChangeValueLength = 64
+)
+
+// PendingChange is a change of the notebook pod template blocked until the
+// notebook is restarted. The values are only reported for the scalar fields,
+// and redacted when sensitive.
+type PendingChange struct {
+	// Path is the JSON path of the changed field, relative to the compared
+	// object, e.g. containers[1].image.
+	Path string `json:"path"`
+	// Type is one of ChangeAdded, ChangeRemoved or ChangeModified.
+	Type string `json:"type"`
+	// From is the current value, and To the value applied on restart.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// PendingChanges is the value of the update-pending annotation.
+type PendingChanges struct {
+	Changes []PendingChange `json:"changes"`
+	// Truncated is true if some changes were left out to cap the size.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// PendingChangesReporter is a custom go-cmp reporter that records all the
+// differences between the pending (x) and the current (y) values. Sensitive
+// values, such as secret environment variables, are redacted.
+type PendingChangesReporter struct {
+	path    cmp.Path
+	changes []PendingChange
 }
 
-func (r *FirstDifferenceReporter) PushStep(ps cmp.PathStep) {
+func (r *PendingChangesReporter) PushStep(ps cmp.PathStep) {
 	r.path = append(r.path, ps)
 }
 
-func (r *FirstDifferenceReporter) Report(rs cmp.Result) {
-	if r.diff == "" && !rs.Equal() {
-		vx, vy := r.path.Last().Values()
-		r.diff = fmt.Sprintf("%#v: %+v != %+v", r.path, vx, vy)
+func (r *PendingChangesReporter) Report(rs cmp.Result) {
+	if rs.Equal() {
+		return
+	}
+	vx, vy := r.path.Last().Values()
+	change := PendingChange{Path: jsonPath(r.path), Type: ChangeModified}
+	switch {
+	case isNil(vy):
+		change.Type = ChangeAdded
+	case isNil(vx):
+		change.Type = ChangeRemoved
 	}
+	if isSensitivePath(r.path) {
+		if !isNil(vy) {
+			change.From = RedactedValue
+		}
+		if !isNil(vx) {
+			change.To = RedactedValue
+		}
+	} else {
+		change.From, change.To = scalarValue(vy), scalarValue(vx)
+	}
+	r.changes = append(r.changes, change)
 }
 
-func (r *FirstDifferenceReporter) PopStep() {
+func (r *PendingChangesReporter) PopStep() {
 	r.path = r.path[:len(r.path)-1]
 }
 
-func (r *FirstDifferenceReporter) String() string {
-	return r.diff
+// Changes returns the recorded changes.
+func (r *PendingChangesReporter) Changes() []PendingChange {
+	return r.changes
+}
+
+// isNil returns true if the value is missing or a nil pointer, slice or map.
+func isNil(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// jsonPath renders the go-cmp path with the JSON names of the struct fields.
+func jsonPath(path cmp.Path) string {
+	var b strings.Builder
+	for i, step := range path {
+		switch s := step.(type) {
+		case cmp.StructField:
+			name := s.Name()
+			if field, ok := path[i-1].Type().FieldByName(name); ok {
+				if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" && tag != "-" {
+					name = tag
+				}
+			}
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(name)
+		case cmp.SliceIndex:
+			index := s.Key()
+			if index < 0 {
+				// Added or removed element
+				x, y := s.SplitKeys()
+				index = max(x, y)
+			}
+			fmt.Fprintf(&b, "[%d]", index)
+		case cmp.MapIndex:
+			fmt.Fprintf(&b, "[%q]", fmt.Sprint(s.Key()))
+		}
+	}
+	return b.String()
+}
+
+// scalarValue renders the value of a scalar field, redacting the sensitive
+// strings and capping the length. Returns an empty string for the other
+// values.
+func scalarValue(v reflect.Value) string {
+	for v.IsValid() && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+	default:
+		return ""
+	}
+	value := RedactString(fmt.Sprint(v.Interface()))
+	if runes := []rune(value); len(runes) > maxPendingChangeValueLength {
+		value = string(runes[:maxPendingChangeValueLength-3]) + "..."
+	}
+	return value
+}
+
+// marshalPendingChanges serializes the changes, leaving out the last ones when
+// the size exceeds maxPendingChangesSize.
+func marshalPendingChanges(changes []PendingChange) (string, error) {
+	pending := PendingChanges{Changes: append([]PendingChange{}, changes...)}
+	for {
+		var value bytes.Buffer
+		encoder := json.NewEncoder(&value)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(pending); err != nil {
+			return "", err
+		}
+		result := strings.TrimSuffix(value.String(), "\n")
+		if len(result) <= maxPendingChangesSize || len(pending.Changes) == 0 {
+			return result, nil
+		}
+		pending.Changes = pending.Changes[:len(pending.Changes)-1]
+		pending.Truncated = true
+	}
 }
 
-// getStructDiff compares a and b, reporting the first difference it found in a human-readable single-line string.
+// getStructDiff compares the pending (a) and current (b) values, reporting the
+// differences as a compact PendingChanges JSON document.
 func getStructDiff(ctx context.Context, a any, b any) (result string) {
 	log := logr.FromContextOrDiscard(ctx)
 
 	// calling cmp.Equal may panic, get ready for it
-	result = "failed to compute the reason for why there is a pending restart"
+	result = `{"changes":[],"truncated":true}`
 	defer func() {
 		if r := recover(); r != nil {
 			log.Error(fmt.Errorf("failed to compute struct difference: %+v", r), "Cannot determine reason for restart")
 		}
 	}()
 
-	var comparator FirstDifferenceReporter
-	eq := cmp.Equal(a, b, cmp.Reporter(&comparator))
+	var reporter PendingChangesReporter
+	eq := cmp.Equal(a, b, cmp.Reporter(&reporter))
 	if eq {
 		log.Error(nil, "Unexpectedly attempted to diff structs that are actually equal")
 	}
-	result = comparator.String()
+	value, err := marshalPendingChanges(reporter.Changes())
+	if err != nil {
+		log.Error(err, "Cannot serialize the reason for restart")
+		return
+	}
+	result = value
 
 	return
 }
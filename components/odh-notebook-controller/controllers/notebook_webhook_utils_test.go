@@ -17,29 +17,45 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestFirstDifferenceReporter(t *testing.T) {
+func TestPendingChangesReporter(t *testing.T) {
 	for _, tt := range []struct {
-		name string
-		a    any
-		b    any
-		diff string
+		name    string
+		a       any
+		b       any
+		changes []PendingChange
 	}{
-		{"", 42, 42, ""},
-		{"", v1.Pod{Spec: v1.PodSpec{NodeName: "node1"}}, v1.Pod{Spec: v1.PodSpec{NodeName: "node2"}}, "{v1.Pod}.Spec.NodeName: node1 != node2"},
+		{"equal", 42, 42, nil},
+		{"modified", v1.Pod{Spec: v1.PodSpec{NodeName: "node2"}}, v1.Pod{Spec: v1.PodSpec{NodeName: "node1"}},
+			[]PendingChange{{Path: "spec.nodeName", Type: ChangeModified, From: "node1", To: "node2"}}},
+		{"added",
+			v1.PodSpec{Containers: []v1.Container{{Name: "nb"}, {Name: "oauth-proxy"}}},
+			v1.PodSpec{Containers: []v1.Container{{Name: "nb"}}},
+			[]PendingChange{{Path: "containers[1]", Type: ChangeAdded}}},
+		{"removed",
+			v1.PodSpec{NodeSelector: map[string]string{}},
+			v1.PodSpec{NodeSelector: map[string]string{"pool": "spot"}},
+			[]PendingChange{{Path: `nodeSelector["pool"]`, Type: ChangeRemoved, From: "spot"}}},
+		{"nil",
+			v1.PodSpec{SecurityContext: &v1.PodSecurityContext{}},
+			v1.PodSpec{},
+			[]PendingChange{{Path: "securityContext", Type: ChangeAdded}}},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			var reporter FirstDifferenceReporter
+			var reporter PendingChangesReporter
 			eq := cmp.Equal(tt.a, tt.b, cmp.Reporter(&reporter))
-			assert.Equal(t, tt.diff == "", eq)
-			assert.Equal(t, tt.diff, reporter.String())
+			assert.Equal(t, tt.changes == nil, eq)
+			assert.Equal(t, tt.changes, reporter.Changes())
 		})
 	}
 }
@@ -51,14 +67,55 @@ func TestGetStructDiff(t *testing.T) {
 		b        any
 		expected string
 	}{
-		{"simple numbers", 42, 42, ""},
-		{"differing pods", v1.Pod{Spec: v1.PodSpec{NodeName: "node1"}}, v1.Pod{Spec: v1.PodSpec{NodeName: "node2"}}, "{v1.Pod}.Spec.NodeName: node1 != node2"},
+		{"simple numbers", 42, 42, `{"changes":[]}`},
+		{"differing pods", v1.Pod{Spec: v1.PodSpec{NodeName: "node2"}}, v1.Pod{Spec: v1.PodSpec{NodeName: "node1"}},
+			`{"changes":[{"path":"spec.nodeName","type":"modified","from":"node1","to":"node2"}]}`},
+		{"differing secret env",
+			v1.Container{Env: []v1.EnvVar{{Name: "AWS_SECRET_ACCESS_KEY", Value: "abc"}}},
+			v1.Container{Env: []v1.EnvVar{{Name: "AWS_SECRET_ACCESS_KEY", Value: "xyz"}}},
+			`{"changes":[{"path":"env[0].value","type":"modified","from":"<redacted>","to":"<redacted>"}]}`},
+		{"differing proxy args",
+			v1.Container{Args: []string{"--client-secret=abc"}},
+			v1.Container{Args: []string{"--client-secret=xyz"}},
+			`{"changes":[{"path":"args[0]","type":"modified","from":"--client-secret=<redacted>","to":"--client-secret=<redacted>"}]}`},
 	}
 
 	for _, v := range tests {
 		t.Run(v.name, func(t *testing.T) {
 			diff := getStructDiff(context.Background(), v.a, v.b)
-			assert.Equal(t, diff, v.expected)
+			assert.Equal(t, v.expected, diff)
+		})
+	}
+}
+
+func TestGetStructDiffTruncated(t *testing.T) {
+	a, b := v1.Container{}, v1.Container{Args: []string{}}
+	for i := 0; i < 200; i++ {
+		a.Args = append(a.Args, strings.Repeat("x", 100))
+	}
+	diff := getStructDiff(context.Background(), a, b)
+	assert.LessOrEqual(t, len(diff), maxPendingChangesSize)
+
+	pending := PendingChanges{}
+	require.NoError(t, json.Unmarshal([]byte(diff), &pending))
+	assert.True(t, pending.Truncated)
+	assert.NotEmpty(t, pending.Changes)
+	assert.Equal(t, PendingChange{Path: "args[0]", Type: ChangeAdded, To: strings.Repeat("x", 61) + "..."},
+		pending.Changes[0])
+}
+
+func TestRedactString(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		expected string
+	}{
+		{"--upstream=http://localhost:8888", "--upstream=http://localhost:8888"},
+		{"--cookie-secret=s3cr3t --tls-cert=/etc/tls", "--cookie-secret=<redacted> --tls-cert=/etc/tls"},
+		{"Authorization: Bearer eyJhbGciOi.abc", "Authorization: Bearer <redacted>"},
+		{`{"cookie_secret":"s3cr3t"}`, `{"cookie_secret":"<redacted>"}`},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, RedactString(tt.input))
 		})
 	}
 }
@@ -0,0 +1,229 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// FakeRouterDomain is the domain of the hosts of the Routes admitted by the
+// fake OpenShift APIs.
+const FakeRouterDomain = "apps.fixture.local"
+
+// fakeOpenShiftGroups are the OpenShift API groups served in memory by the
+// fake OpenShift APIs: the Routes, the ImageStreams and the cluster Proxy.
+var fakeOpenShiftGroups = map[string]bool{
+	routev1.GroupName:     true,
+	"image.openshift.io":  true,
+	"config.openshift.io": true,
+}
+
+// imageStreamsGVR is the resource of the ImageStreams, read through the
+// dynamic client.
+var imageStreamsGVR = schema.GroupVersionResource{Group: "image.openshift.io", Version: "v1", Resource: "imagestreams"}
+
+// FakeOpenShiftClient serves the OpenShift APIs from memory and delegates the
+// other APIs to the cluster, so that the reconciles and the webhook run in
+// envtest or kind clusters without OpenShift. The created Routes are admitted
+// right away.
+type FakeOpenShiftClient struct {
+	client.Client
+	fake client.Client
+}
+
+// NewFakeOpenShiftClient returns the client serving the OpenShift APIs from
+// memory, seeded with the given OpenShift objects of the scheme.
+func NewFakeOpenShiftClient(c client.Client, objects ...client.Object) *FakeOpenShiftClient {
+	return &FakeOpenShiftClient{
+		Client: c,
+		fake: fake.NewClientBuilder().WithScheme(c.Scheme()).WithObjects(objects...).
+			WithIndex(&routev1.Route{}, RouteHostIndex, routeHost).Build(),
+	}
+}
+
+// clientFor returns the in-memory client for the OpenShift objects, the
+// cluster client otherwise.
+func (c *FakeOpenShiftClient) clientFor(obj runtime.Object) client.Client {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err == nil && fakeOpenShiftGroups[gvk.Group] {
+		return c.fake
+	}
+	return c.Client
+}
+
+// Get implements client.Client.
+func (c *FakeOpenShiftClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+	return c.clientFor(obj).Get(ctx, key, obj, opts...)
+}
+
+// List implements client.Client.
+func (c *FakeOpenShiftClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.clientFor(list).List(ctx, list, opts...)
+}
+
+// Create implements client.Client, admitting the Routes.
+func (c *FakeOpenShiftClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if route, ok := obj.(*routev1.Route); ok {
+		admitFakeRoute(route)
+	}
+	return c.clientFor(obj).Create(ctx, obj, opts...)
+}
+
+// Delete implements client.Client.
+func (c *FakeOpenShiftClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return c.clientFor(obj).Delete(ctx, obj, opts...)
+}
+
+// Update implements client.Client.
+func (c *FakeOpenShiftClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.clientFor(obj).Update(ctx, obj, opts...)
+}
+
+// Patch implements client.Client.
+func (c *FakeOpenShiftClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	return c.clientFor(obj).Patch(ctx, obj, patch, opts...)
+}
+
+// DeleteAllOf implements client.Client.
+func (c *FakeOpenShiftClient) DeleteAllOf(ctx context.Context, obj client.Object,
+	opts ...client.DeleteAllOfOption) error {
+	return c.clientFor(obj).DeleteAllOf(ctx, obj, opts...)
+}
+
+// Status implements client.Client.
+func (c *FakeOpenShiftClient) Status() client.SubResourceWriter {
+	return &fakeOpenShiftStatusWriter{client: c}
+}
+
+// fakeOpenShiftStatusWriter writes the status of the OpenShift objects in
+// memory, and of the other objects in the cluster.
+type fakeOpenShiftStatusWriter struct {
+	client *FakeOpenShiftClient
+}
+
+// Create implements client.SubResourceWriter.
+func (w *fakeOpenShiftStatusWriter) Create(ctx context.Context, obj client.Object, subResource client.Object,
+	opts ...client.SubResourceCreateOption) error {
+	return w.client.clientFor(obj).Status().Create(ctx, obj, subResource, opts...)
+}
+
+// Update implements client.SubResourceWriter.
+func (w *fakeOpenShiftStatusWriter) Update(ctx context.Context, obj client.Object,
+	opts ...client.SubResourceUpdateOption) error {
+	return w.client.clientFor(obj).Status().Update(ctx, obj, opts...)
+}
+
+// Patch implements client.SubResourceWriter.
+func (w *fakeOpenShiftStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.SubResourcePatchOption) error {
+	return w.client.clientFor(obj).Status().Patch(ctx, obj, patch, opts...)
+}
+
+// admitFakeRoute defaults the host of the Route and admits it, as the
+// default router of an OpenShift cluster would.
+func admitFakeRoute(route *routev1.Route) {
+	if route.Spec.Host == "" {
+		route.Spec.Host = route.Name + "-" + route.Namespace + "." + FakeRouterDomain
+	}
+	route.Status.Ingress = []routev1.RouteIngress{{
+		Host:       route.Spec.Host,
+		RouterName: "default",
+		Conditions: []routev1.RouteIngressCondition{{
+			Type:   routev1.RouteAdmitted,
+			Status: corev1.ConditionTrue,
+		}},
+	}}
+}
+
+// NewFakeImageStreamClient returns the dynamic client serving the given
+// ImageStreams from memory, in place of the ImageStream API of the cluster.
+func NewFakeImageStreamClient(imageStreams ...runtime.Object) dynamic.Interface {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{imageStreamsGVR: "ImageStreamList"}, imageStreams...)
+}
+
+// LoadFakeOpenShiftObjects reads the OpenShift objects seeding the fake
+// OpenShift APIs from the given multi-document YAML file, e.g. the
+// ImageStreams of the workbench images and the cluster Proxy. The
+// ImageStreams are returned apart, as they are read through the dynamic
+// client, the other objects are converted to the types of the scheme.
+func LoadFakeOpenShiftObjects(path string, scheme *runtime.Scheme) ([]client.Object, []runtime.Object, error) {
+	objects := []client.Object{}
+	imageStreams := []runtime.Object{}
+	if path == "" {
+		return objects, imageStreams, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		object := &unstructured.Unstructured{}
+		err := decoder.Decode(&object.Object)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("invalid fake OpenShift objects %s: %w", path, err)
+		}
+		if len(object.Object) == 0 {
+			continue
+		}
+		gvk := object.GroupVersionKind()
+		if !fakeOpenShiftGroups[gvk.Group] {
+			return nil, nil, fmt.Errorf("the %s %s is not an object of the fake OpenShift APIs", gvk.Kind,
+				object.GetName())
+		}
+		if gvk.Group == imageStreamsGVR.Group {
+			imageStreams = append(imageStreams, object)
+			continue
+		}
+		typed, err := scheme.New(gvk)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unsupported fake OpenShift object %s %s: %w", gvk.Kind, object.GetName(), err)
+		}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, typed)
+		if err != nil {
+			return nil, nil, err
+		}
+		clientObject, ok := typed.(client.Object)
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported fake OpenShift object %s %s", gvk.Kind, object.GetName())
+		}
+		objects = append(objects, clientObject)
+	}
+	return objects, imageStreams, nil
+}
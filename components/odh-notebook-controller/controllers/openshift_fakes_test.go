@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testFakeOpenShiftObjects = `
+apiVersion: image.openshift.io/v1
+kind: ImageStream
+metadata:
+  name: jupyter
+  namespace: opendatahub
+status:
+  tags:
+  - tag: "2024.1"
+    items:
+    - created: "2024-05-01T10:00:00Z"
+      dockerImageReference: quay.io/opendatahub/jupyter@sha256:1
+---
+apiVersion: route.openshift.io/v1
+kind: Route
+metadata:
+  name: existing
+  namespace: ns
+`
+
+func TestFakeOpenShiftClient(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "objects.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testFakeOpenShiftObjects), 0o600))
+	r := newTestReconciler(t, OAuthConfig{})
+	objects, imageStreams, err := LoadFakeOpenShiftObjects(path, r.Scheme)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Len(t, imageStreams, 1)
+
+	fakeClient := NewFakeOpenShiftClient(r.Client, objects...)
+
+	// The OpenShift objects are served from memory
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "existing"}, &routev1.Route{}))
+	assert.True(t, apierrs.IsNotFound(r.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "existing"},
+		&routev1.Route{})))
+
+	// The created Routes are admitted
+	route := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	require.NoError(t, fakeClient.Create(ctx, route))
+	found := &routev1.Route{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(route), found))
+	assert.Equal(t, "nb-ns."+FakeRouterDomain, found.Spec.Host)
+	assert.True(t, routeIsAdmitted(found))
+
+	// The other objects are delegated to the cluster
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "nb", Namespace: "ns"}}
+	require.NoError(t, fakeClient.Create(ctx, service))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(service), &corev1.Service{}))
+
+	// The ImageStreams are read through the dynamic client
+	notebook := &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "nb",
+			Namespace:   "ns",
+			Annotations: map[string]string{AnnotationLastImageSelection: "jupyter:2024.1"},
+		},
+		Spec: nbv1.NotebookSpec{Template: nbv1.NotebookTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nb", Image: "jupyter:2024.1"}},
+		}}},
+	}
+	require.NoError(t, SetContainerImageFromRegistry(ctx, NewFakeImageStreamClient(imageStreams...), notebook,
+		ClusterDNSConfig{}, logr.Discard()))
+	assert.Equal(t, "quay.io/opendatahub/jupyter@sha256:1", notebook.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestLoadFakeOpenShiftObjects(t *testing.T) {
+	r := newTestReconciler(t, OAuthConfig{})
+	objects, imageStreams, err := LoadFakeOpenShiftObjects("", r.Scheme)
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+	assert.Empty(t, imageStreams)
+
+	// Only the objects of the OpenShift APIs can be seeded
+	path := filepath.Join(t.TempDir(), "objects.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"), 0o600))
+	_, _, err = LoadFakeOpenShiftObjects(path, r.Scheme)
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments;replicasets,verbs=get
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=list
+
+// ReplicaTopology describes the Deployment running the controller, for the
+// availability of the webhook served by all its replicas.
+type ReplicaTopology struct {
+	// Deployment is the name of the Deployment of the controller.
+	Deployment string
+	// Replicas is the number of replicas of the Deployment.
+	Replicas int32
+	// RollingUpdateKeepsReplicas is true if the rolling updates of the
+	// Deployment keep at least one replica available.
+	RollingUpdateKeepsReplicas bool
+	// PodAntiAffinity is true if the pods are spread across the nodes.
+	PodAntiAffinity bool
+	// PodDisruptionBudget is true if a PodDisruptionBudget selects the pods.
+	PodDisruptionBudget bool
+}
+
+// MultiReplica returns true if the controller runs several replicas.
+func (t ReplicaTopology) MultiReplica() bool {
+	return t.Replicas > 1
+}
+
+// DetectReplicaTopology returns the topology of the Deployment owning the
+// controller pod. The reader must not depend on the cache, which is not
+// started yet.
+func DetectReplicaTopology(ctx context.Context, reader client.Reader, namespace, podName string) (ReplicaTopology,
+	error) {
+	topology := ReplicaTopology{}
+	pod := &corev1.Pod{}
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: podName}, pod); err != nil {
+		return topology, err
+	}
+	replicaSetRef := metav1.GetControllerOf(pod)
+	if replicaSetRef == nil || replicaSetRef.Kind != "ReplicaSet" {
+		return topology, fmt.Errorf("the pod %s is not managed by a Deployment", podName)
+	}
+	replicaSet := &appsv1.ReplicaSet{}
+	err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: replicaSetRef.Name}, replicaSet)
+	if err != nil {
+		return topology, err
+	}
+	deploymentRef := metav1.GetControllerOf(replicaSet)
+	if deploymentRef == nil || deploymentRef.Kind != "Deployment" {
+		return topology, fmt.Errorf("the pod %s is not managed by a Deployment", podName)
+	}
+	deployment := &appsv1.Deployment{}
+	err = reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: deploymentRef.Name}, deployment)
+	if err != nil {
+		return topology, err
+	}
+
+	topology.Deployment = deployment.Name
+	topology.Replicas = 1
+	if deployment.Spec.Replicas != nil {
+		topology.Replicas = *deployment.Spec.Replicas
+	}
+	topology.RollingUpdateKeepsReplicas = rollingUpdateKeepsReplicas(deployment)
+	affinity := deployment.Spec.Template.Spec.Affinity
+	topology.PodAntiAffinity = affinity != nil && affinity.PodAntiAffinity != nil ||
+		len(deployment.Spec.Template.Spec.TopologySpreadConstraints) > 0
+
+	budgets := &policyv1.PodDisruptionBudgetList{}
+	if err := reader.List(ctx, budgets, client.InNamespace(namespace)); err != nil {
+		return topology, err
+	}
+	for _, budget := range budgets.Items {
+		selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+		if err == nil && !selector.Empty() && selector.Matches(labels.Set(pod.Labels)) {
+			topology.PodDisruptionBudget = true
+		}
+	}
+	return topology, nil
+}
+
+// rollingUpdateKeepsReplicas returns true if the rolling updates of the
+// Deployment keep at least one of its replicas available.
+func rollingUpdateKeepsReplicas(deployment *appsv1.Deployment) bool {
+	if deployment.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+		return false
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	maxUnavailable := intstr.FromString("25%")
+	if update := deployment.Spec.Strategy.RollingUpdate; update != nil && update.MaxUnavailable != nil {
+		maxUnavailable = *update.MaxUnavailable
+	}
+	unavailable, err := intstr.GetScaledValueFromIntOrPercent(&maxUnavailable, int(replicas), false)
+	return err == nil && int32(unavailable) < replicas
+}
+
+// Guidance returns the changes of the Deployment keeping a replica serving
+// the webhook during the upgrades and the node drains.
+func (t ReplicaTopology) Guidance() []string {
+	guidance := []string{}
+	if !t.MultiReplica() {
+		guidance = append(guidance, fmt.Sprintf("Scale the Deployment %s to at least 2 replicas, so that the "+
+			"notebook webhook is served during the upgrades and the node drains", t.Deployment))
+		return guidance
+	}
+	if !t.RollingUpdateKeepsReplicas {
+		guidance = append(guidance, fmt.Sprintf("Set the maxUnavailable of the rolling update strategy of the "+
+			"Deployment %s to 1, its upgrades stop all the replicas serving the notebook webhook", t.Deployment))
+	}
+	if !t.PodAntiAffinity {
+		guidance = append(guidance, fmt.Sprintf("Spread the pods of the Deployment %s across the nodes with a "+
+			"preferred pod anti-affinity on the kubernetes.io/hostname topology key", t.Deployment))
+	}
+	if !t.PodDisruptionBudget {
+		guidance = append(guidance, fmt.Sprintf("Create a PodDisruptionBudget with maxUnavailable 1 selecting the "+
+			"pods of the Deployment %s, so that the node drains keep a replica serving the notebook webhook",
+			t.Deployment))
+	}
+	return guidance
+}
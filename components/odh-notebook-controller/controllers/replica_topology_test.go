@@ -0,0 +1,73 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+)
+
+func TestDetectReplicaTopology(t *testing.T) {
+	ctx := context.Background()
+	podLabels := map[string]string{"app": "odh-notebook-controller"}
+	maxUnavailable := intstr.FromString("100%")
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "controller", Namespace: "odh", UID: "deployment-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: pointer.Int32(2),
+			Strategy: appsv1.DeploymentStrategy{RollingUpdate: &appsv1.RollingUpdateDeployment{
+				MaxUnavailable: &maxUnavailable,
+			}},
+		},
+	}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "controller-1", Namespace: "odh",
+		UID: "replicaset-uid", OwnerReferences: []metav1.OwnerReference{
+			{Kind: "Deployment", Name: "controller", UID: "deployment-uid", Controller: pointer.Bool(true)},
+		}}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "controller-1-a", Namespace: "odh", Labels: podLabels,
+		OwnerReferences: []metav1.OwnerReference{
+			{Kind: "ReplicaSet", Name: "controller-1", UID: "replicaset-uid", Controller: pointer.Bool(true)},
+		}}}
+	budget := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "controller", Namespace: "odh"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: podLabels}},
+	}
+	r := newTestReconciler(t, OAuthConfig{}, deployment, replicaSet, pod, budget)
+
+	topology, err := DetectReplicaTopology(ctx, r.Client, "odh", "controller-1-a")
+	require.NoError(t, err)
+	assert.Equal(t, ReplicaTopology{Deployment: "controller", Replicas: 2, PodDisruptionBudget: true}, topology)
+	assert.True(t, topology.MultiReplica())
+	guidance := topology.Guidance()
+	require.Len(t, guidance, 2)
+	assert.Contains(t, guidance[0], "maxUnavailable of the rolling update strategy")
+	assert.Contains(t, guidance[1], "pod anti-affinity")
+
+	// The single replica is reported first
+	assert.Len(t, ReplicaTopology{Deployment: "controller", Replicas: 1}.Guidance(), 1)
+
+	_, err = DetectReplicaTopology(ctx, r.Client, "odh", "missing")
+	assert.Error(t, err)
+}
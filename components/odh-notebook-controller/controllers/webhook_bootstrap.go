@@ -0,0 +1,323 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Names of the webhook objects, matching the names of the kustomize
+	// manifests so that the bootstrap updates an existing installation.
+	DefaultWebhookServiceName               = "odh-notebook-controller-webhook-service"
+	DefaultWebhookCertSecretName            = "odh-notebook-controller-webhook-cert"
+	DefaultMutatingWebhookConfigurationName = "odh-notebook-controller-mutating-webhook-configuration"
+
+	// NotebookWebhookPath is the path the notebook webhook is served at.
+	NotebookWebhookPath = "/mutate-notebook-v1"
+	// DefaultWebhookCertValidity is the validity of the certificates generated
+	// by the bootstrap.
+	DefaultWebhookCertValidity = 365 * 24 * time.Hour
+
+	// webhookCertRenewBefore is the remaining validity under which the
+	// certificates are renewed by the bootstrap.
+	webhookCertRenewBefore = 30 * 24 * time.Hour
+)
+
+// WebhookBootstrap creates or updates the webhook configuration, its Service
+// and its serving certificates, for the installations without the ODH
+// operator nor the OpenShift service CA. It is idempotent: the certificates
+// are only regenerated when they are about to expire or do not match the
+// Service anymore.
+type WebhookBootstrap struct {
+	Client client.Client
+	Log    logr.Logger
+	// Namespace is the namespace of the controller, the namespace of the
+	// controller pod if empty.
+	Namespace string
+	// ServiceName, SecretName and ConfigurationName name the webhook Service,
+	// the Secret of its certificates and the MutatingWebhookConfiguration.
+	ServiceName       string
+	SecretName        string
+	ConfigurationName string
+	// Selector selects the controller pods.
+	Selector map[string]string
+	// Port is the port of the webhook server of the controller pods.
+	Port int
+	// Timeout is the timeout of the API server calling the webhook.
+	Timeout time.Duration
+	// CertValidity is the validity of the generated certificates.
+	CertValidity time.Duration
+	// ClusterDNSConfig holds the cluster domain of the Service DNS names.
+	ClusterDNSConfig ClusterDNSConfig
+}
+
+// Run creates or updates the webhook objects.
+func (b *WebhookBootstrap) Run(ctx context.Context) error {
+	if b.Namespace == "" {
+		b.Namespace = getControllerNamespace()
+		if b.Namespace == "" {
+			return fmt.Errorf("unable to find the controller namespace")
+		}
+	}
+
+	caBundle, err := b.reconcileCertificates(ctx)
+	if err != nil {
+		return err
+	}
+	if err = b.reconcileService(ctx); err != nil {
+		return err
+	}
+	return b.reconcileMutatingWebhookConfiguration(ctx, caBundle)
+}
+
+// serviceDNSNames returns the DNS names the API server reaches the webhook
+// Service at.
+func (b *WebhookBootstrap) serviceDNSNames() []string {
+	service := b.ServiceName + "." + b.Namespace + ".svc"
+	return []string{service, service + "." + b.ClusterDNSConfig.clusterDomain()}
+}
+
+// reconcileCertificates generates the CA and the serving certificate of the
+// webhook Service, unless the Secret already holds valid ones, and returns
+// the CA bundle.
+func (b *WebhookBootstrap) reconcileCertificates(ctx context.Context) ([]byte, error) {
+	secret := &corev1.Secret{}
+	err := b.Client.Get(ctx, types.NamespacedName{Name: b.SecretName, Namespace: b.Namespace}, secret)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return nil, err
+	}
+	exists := err == nil
+	if exists && webhookCertIsValid(secret, b.serviceDNSNames(), time.Now().Add(webhookCertRenewBefore)) {
+		b.Log.Info("Keeping the webhook certificates", "secret", b.SecretName)
+		return secret.Data["ca.crt"], nil
+	}
+
+	caPEM, certPEM, keyPEM, err := generateWebhookCertificates(b.serviceDNSNames(), b.CertValidity)
+	if err != nil {
+		return nil, err
+	}
+	secret.Name = b.SecretName
+	secret.Namespace = b.Namespace
+	secret.Type = corev1.SecretTypeTLS
+	secret.Data = map[string][]byte{
+		corev1.TLSCertKey:       certPEM,
+		corev1.TLSPrivateKeyKey: keyPEM,
+		"ca.crt":                caPEM,
+	}
+	if exists {
+		b.Log.Info("Renewing the webhook certificates", "secret", b.SecretName)
+		err = b.Client.Update(ctx, secret)
+	} else {
+		b.Log.Info("Creating the webhook certificates", "secret", b.SecretName)
+		err = b.Client.Create(ctx, secret)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return caPEM, nil
+}
+
+// webhookCertIsValid returns true if the Secret holds a serving certificate
+// for the DNS names, signed by its CA and valid until the given time.
+func webhookCertIsValid(secret *corev1.Secret, dnsNames []string, until time.Time) bool {
+	caBlock, _ := pem.Decode(secret.Data["ca.crt"])
+	certBlock, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if caBlock == nil || certBlock == nil || len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+		return false
+	}
+	ca, err := x509.ParseCertificate(caBlock.Bytes)
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil || cert.NotAfter.Before(until) || ca.NotAfter.Before(until) {
+		return false
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, dnsName := range dnsNames {
+		if _, err = cert.Verify(x509.VerifyOptions{DNSName: dnsName, Roots: roots}); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// generateWebhookCertificates generates a CA and a serving certificate for
+// the DNS names, signed by the CA, and returns them PEM encoded along with the
+// key of the serving certificate.
+func generateWebhookCertificates(dnsNames []string, validity time.Duration) ([]byte, []byte, []byte, error) {
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.Add(validity)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "odh-notebook-controller-webhook-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	certTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, certTemplate, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// reconcileService creates or updates the webhook Service.
+func (b *WebhookBootstrap) reconcileService(ctx context.Context) error {
+	ports := []corev1.ServicePort{{
+		Name:       "webhook",
+		Port:       443,
+		TargetPort: intstr.FromInt(b.Port),
+		Protocol:   corev1.ProtocolTCP,
+	}}
+	service := &corev1.Service{}
+	err := b.Client.Get(ctx, types.NamespacedName{Name: b.ServiceName, Namespace: b.Namespace}, service)
+	if apierrs.IsNotFound(err) {
+		b.Log.Info("Creating the webhook Service", "service", b.ServiceName)
+		return b.Client.Create(ctx, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: b.ServiceName, Namespace: b.Namespace},
+			Spec:       corev1.ServiceSpec{Ports: ports, Selector: b.Selector},
+		})
+	} else if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(service.Spec.Ports, ports) && reflect.DeepEqual(service.Spec.Selector, b.Selector) {
+		return nil
+	}
+	b.Log.Info("Updating the webhook Service", "service", b.ServiceName)
+	service.Spec.Ports = ports
+	service.Spec.Selector = b.Selector
+	return b.Client.Update(ctx, service)
+}
+
+// newNotebookMutatingWebhook defines the notebook webhook, called through the
+// webhook Service.
+func (b *WebhookBootstrap) newNotebookMutatingWebhook(caBundle []byte) admissionregistrationv1.MutatingWebhook {
+	path := NotebookWebhookPath
+	port := int32(443)
+	failurePolicy := admissionregistrationv1.Fail
+	sideEffects := admissionregistrationv1.SideEffectClassNoneOnDryRun
+	timeoutSeconds := int32(b.Timeout.Seconds())
+	return admissionregistrationv1.MutatingWebhook{
+		Name:                    "notebooks.opendatahub.io",
+		AdmissionReviewVersions: []string{"v1"},
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{
+			Service: &admissionregistrationv1.ServiceReference{
+				Name:      b.ServiceName,
+				Namespace: b.Namespace,
+				Path:      &path,
+				Port:      &port,
+			},
+			CABundle: caBundle,
+		},
+		Rules: []admissionregistrationv1.RuleWithOperations{{
+			Operations: []admissionregistrationv1.OperationType{
+				admissionregistrationv1.Create, admissionregistrationv1.Update,
+			},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"kubeflow.org"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"notebooks"},
+			},
+		}},
+		FailurePolicy:  &failurePolicy,
+		SideEffects:    &sideEffects,
+		TimeoutSeconds: &timeoutSeconds,
+	}
+}
+
+// reconcileMutatingWebhookConfiguration creates or updates the webhook
+// configuration with the CA bundle.
+func (b *WebhookBootstrap) reconcileMutatingWebhookConfiguration(ctx context.Context, caBundle []byte) error {
+	webhooks := []admissionregistrationv1.MutatingWebhook{b.newNotebookMutatingWebhook(caBundle)}
+	configuration := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	err := b.Client.Get(ctx, types.NamespacedName{Name: b.ConfigurationName}, configuration)
+	if apierrs.IsNotFound(err) {
+		b.Log.Info("Creating the MutatingWebhookConfiguration", "name", b.ConfigurationName)
+		return b.Client.Create(ctx, &admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: b.ConfigurationName},
+			Webhooks:   webhooks,
+		})
+	} else if err != nil {
+		return err
+	}
+	if len(configuration.Webhooks) == 1 && bytes.Equal(configuration.Webhooks[0].ClientConfig.CABundle, caBundle) &&
+		reflect.DeepEqual(configuration.Webhooks[0].ClientConfig.Service, webhooks[0].ClientConfig.Service) &&
+		reflect.DeepEqual(configuration.Webhooks[0].TimeoutSeconds, webhooks[0].TimeoutSeconds) {
+		return nil
+	}
+	b.Log.Info("Updating the MutatingWebhookConfiguration", "name", b.ConfigurationName)
+	configuration.Webhooks = webhooks
+	return b.Client.Update(ctx, configuration)
+}
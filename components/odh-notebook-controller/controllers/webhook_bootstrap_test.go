@@ -0,0 +1,81 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestWebhookBootstrap(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t, OAuthConfig{})
+	bootstrap := &WebhookBootstrap{
+		Client:            r.Client,
+		Log:               logr.Discard(),
+		Namespace:         "opendatahub",
+		ServiceName:       DefaultWebhookServiceName,
+		SecretName:        DefaultWebhookCertSecretName,
+		ConfigurationName: DefaultMutatingWebhookConfigurationName,
+		Selector:          map[string]string{"app": "odh-notebook-controller"},
+		Port:              8443,
+		Timeout:           DefaultWebhookTimeout,
+		CertValidity:      DefaultWebhookCertValidity,
+	}
+	require.NoError(t, bootstrap.Run(ctx))
+
+	secret := &corev1.Secret{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "opendatahub", Name: DefaultWebhookCertSecretName}, secret))
+	assert.True(t, webhookCertIsValid(secret, []string{
+		"odh-notebook-controller-webhook-service.opendatahub.svc",
+		"odh-notebook-controller-webhook-service.opendatahub.svc.cluster.local",
+	}, time.Now()))
+
+	service := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "opendatahub", Name: DefaultWebhookServiceName}, service))
+	assert.Equal(t, bootstrap.Selector, service.Spec.Selector)
+
+	configuration := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Name: DefaultMutatingWebhookConfigurationName}, configuration))
+	require.Len(t, configuration.Webhooks, 1)
+	assert.Equal(t, secret.Data["ca.crt"], configuration.Webhooks[0].ClientConfig.CABundle)
+	assert.Equal(t, NotebookWebhookPath, *configuration.Webhooks[0].ClientConfig.Service.Path)
+
+	// The valid certificates are kept by the next bootstrap
+	require.NoError(t, bootstrap.Run(ctx))
+	found := &corev1.Secret{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "opendatahub", Name: DefaultWebhookCertSecretName}, found))
+	assert.Equal(t, secret.Data, found.Data)
+
+	// The certificates are renewed once they are about to expire
+	bootstrap.CertValidity = 24 * time.Hour
+	found.Data = nil
+	require.NoError(t, r.Update(ctx, found))
+	require.NoError(t, bootstrap.Run(ctx))
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "opendatahub", Name: DefaultWebhookCertSecretName}, found))
+	assert.NotEqual(t, secret.Data["ca.crt"], found.Data["ca.crt"])
+	assert.False(t, webhookCertIsValid(found, bootstrap.serviceDNSNames(), time.Now().Add(webhookCertRenewBefore)))
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Name: DefaultMutatingWebhookConfigurationName}, configuration))
+	assert.Equal(t, found.Data["ca.crt"], configuration.Webhooks[0].ClientConfig.CABundle)
+}
@@ -0,0 +1,141 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	nbv1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1"
+	netv1 "k8s.io/api/networking/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// WorkspaceGVK identifies the Kubeflow Notebooks 2.0 Workspace resource.
+var WorkspaceGVK = schema.GroupVersionKind{
+	Group:   "kubeflow.org",
+	Version: "v1beta1",
+	Kind:    "Workspace",
+}
+
+// WorkspaceNameLabel is set by the Kubeflow Notebooks 2.0 controller on the
+// pods of a workspace.
+const WorkspaceNameLabel = "notebooks.kubeflow.org/workspace-name"
+
+// +kubebuilder:rbac:groups=kubeflow.org,resources=workspaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kubeflow.org,resources=workspacekinds,verbs=get;list;watch
+
+// newWorkspace returns an empty Workspace object.
+func newWorkspace() *unstructured.Unstructured {
+	workspace := &unstructured.Unstructured{}
+	workspace.SetGroupVersionKind(WorkspaceGVK)
+	return workspace
+}
+
+// WorkspacesAreServed returns true if the Workspace CRD is installed in the
+// cluster.
+func WorkspacesAreServed(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(WorkspaceGVK.GroupKind(), WorkspaceGVK.Version)
+	return err == nil
+}
+
+// notebookForWorkspace returns a Notebook holding the metadata of the given
+// workspace, so that the notebook object generators can be reused for the
+// workspace.
+func notebookForWorkspace(workspace *unstructured.Unstructured) *nbv1.Notebook {
+	return &nbv1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        workspace.GetName(),
+			Namespace:   workspace.GetNamespace(),
+			UID:         workspace.GetUID(),
+			Labels:      workspace.GetLabels(),
+			Annotations: workspace.GetAnnotations(),
+		},
+	}
+}
+
+// NewWorkspaceNetworkPolicies defines the desired network policies of the
+// workspace pods. Only the controller network policy of the notebook pods
+// applies, the workspace pods have no OAuth proxy.
+func NewWorkspaceNetworkPolicies(workspace *unstructured.Unstructured) []*netv1.NetworkPolicy {
+	notebook := notebookForWorkspace(workspace)
+	networkPolicies := []*netv1.NetworkPolicy{NewNotebookNetworkPolicy(notebook)}
+	for _, networkPolicy := range networkPolicies {
+		// Avoid collisions with the network policies of a notebook with the
+		// same name
+		networkPolicy.Name = "ws-" + networkPolicy.Name
+		networkPolicy.Spec.PodSelector = metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				WorkspaceNameLabel: workspace.GetName(),
+			},
+		}
+	}
+	return networkPolicies
+}
+
+// ReconcileWorkspace performs the reconciling of the Openshift objects for a
+// Kubeflow Notebooks 2.0 Workspace, so the CA bundle and network policies of
+// the v1 notebooks carry over to the workspaces. The OAuth proxy, the Route and
+// the other pod level injections of the webhook are out of scope: the
+// workspaces are exposed by the Notebooks 2.0 gateway and their pods are
+// defined by the WorkspaceKind pod templates, which mount the CA bundle
+// ConfigMap created here.
+func (r *OpenshiftNotebookReconciler) ReconcileWorkspace(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Initialize logger format
+	log := r.Log.WithValues("workspace", req.Name, "namespace", req.Namespace)
+
+	workspace := newWorkspace()
+	err := r.Get(ctx, req.NamespacedName, workspace)
+	if err != nil && apierrs.IsNotFound(err) {
+		log.Info("Stop Workspace reconciliation")
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the Workspace")
+		return ctrl.Result{}, err
+	}
+
+	// Create the workbench-trusted-ca-bundle ConfigMap shared by all the
+	// notebooks and workspaces of the namespace
+	err = r.CreateNotebookCertConfigMap(notebookForWorkspace(workspace), ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Call the Network Policies reconciler
+	for _, networkPolicy := range NewWorkspaceNetworkPolicies(workspace) {
+		err = r.reconcileNetworkPolicy(networkPolicy, ctx, workspace)
+		if err != nil {
+			log.Error(err, "error creating Workspace network policy")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWorkspacesWithManager sets up the Workspace controller with the Manager.
+func (r *OpenshiftNotebookReconciler) SetupWorkspacesWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("workspace").
+		For(newWorkspace()).
+		Owns(&netv1.NetworkPolicy{}).
+		Complete(reconcile.Func(r.ReconcileWorkspace))
+}
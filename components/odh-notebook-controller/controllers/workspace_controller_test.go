@@ -0,0 +1,70 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestWorkspace(name, namespace string) *unstructured.Unstructured {
+	workspace := newWorkspace()
+	workspace.SetName(name)
+	workspace.SetNamespace(namespace)
+	workspace.SetUID("ws-uid")
+	return workspace
+}
+
+func TestNewWorkspaceNetworkPolicies(t *testing.T) {
+	networkPolicies := NewWorkspaceNetworkPolicies(newTestWorkspace("ws", "ns"))
+	require.Len(t, networkPolicies, 1)
+	assert.Equal(t, "ws-ws-ctrl-np", networkPolicies[0].Name)
+	assert.Equal(t, "ns", networkPolicies[0].Namespace)
+	assert.Equal(t, map[string]string{WorkspaceNameLabel: "ws"}, networkPolicies[0].Spec.PodSelector.MatchLabels)
+}
+
+func TestReconcileWorkspace(t *testing.T) {
+	ctx := context.Background()
+	workspace := newTestWorkspace("ws", "ns")
+	r := newTestReconciler(t, OAuthConfig{}, workspace,
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: "ns"},
+			Data:       map[string]string{"ca.crt": ""},
+		})
+
+	_, err := r.ReconcileWorkspace(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "ws", Namespace: "ns"}})
+	require.NoError(t, err)
+
+	networkPolicies := &netv1.NetworkPolicyList{}
+	require.NoError(t, r.List(ctx, networkPolicies, client.InNamespace("ns")))
+	require.Len(t, networkPolicies.Items, 1)
+	assert.Equal(t, "ws-ws-ctrl-np", networkPolicies.Items[0].Name)
+	assert.True(t, metav1.IsControlledBy(&networkPolicies.Items[0], workspace))
+
+	// Deleted workspaces are ignored
+	_, err = r.ReconcileWorkspace(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "gone", Namespace: "ns"}})
+	assert.NoError(t, err)
+}
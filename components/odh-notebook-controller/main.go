@@ -16,8 +16,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -28,10 +33,16 @@ import (
 
 	"github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/controllers"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -41,6 +52,8 @@ import (
 	configv1 "github.com/openshift/api/config/v1"
 	routev1 "github.com/openshift/api/route/v1"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	nbv1alpha1 "github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/api/v1alpha1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,26 +68,423 @@ func init() {
 	utilruntime.Must(nbv1.AddToScheme(scheme))
 	utilruntime.Must(routev1.AddToScheme(scheme))
 	utilruntime.Must(configv1.AddToScheme(scheme))
+	utilruntime.Must(nbv1alpha1.AddToScheme(scheme))
 
 	//+kubebuilder:scaffold:scheme
 }
 
+// splitList splits a comma-separated flag value, ignoring empty items.
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
-	var metricsAddr, probeAddr, oauthProxyImage string
-	var webhookPort int
-	var enableLeaderElection, enableDebugLogging bool
+	// Create the webhook objects of the installations without the ODH
+	// operator, see bootstrap.go
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap" {
+		bootstrap(os.Args[2:])
+		return
+	}
+	// Report the impact of a configuration change on the notebooks, see
+	// simulate.go
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		simulate(os.Args[2:])
+		return
+	}
+	// Print the schema of the annotations at build time, see
+	// annotations_schema.go
+	if len(os.Args) > 1 && os.Args[1] == "annotations-schema" {
+		annotationsSchema(os.Args[2:])
+		return
+	}
+
+	var metricsAddr, probeAddr, oauthProxyImage, oauthServiceAccountSuffix, oauthSARTemplate string
+	var oauthMetricsPort int
+	var oauthUpstreamCA, oauthImagePullPolicy, workbenchImagePullPolicy string
+	var oauthMetricsNamespace string
+	var oauthReadinessTimeout, oauthCookieExpire, oauthCookieRefresh time.Duration
+	var oauthProxyExtraArgs, oauthProxyResources, defaultLogoutURL, dashboardRoute string
+	var oauthProxyAllowedNotebookArgs string
+	var allowedLocales string
+	var oauthImageCheckInterval time.Duration
+	var clusterPullSecret string
+	var clusterDomain, internalRegistryHost, imagePullSecrets string
+	var webhookPort, kubeAPIBurst, topologySpreadMaxSkew, antiAffinityWeight int
+	var topologySpreadKeys, topologySpreadWhenUnsatisfiable string
+	var spotNodeSelector, spotTolerations, spotPreStopCommand string
+	var probeSourceCIDRs, probeSourceEntities string
+	var routerShards, defaultRouterShard, routeHostConflictPolicy, fieldManager string
+	var unprotectedRoutePolicy string
+	var dashboardConfig string
+	var startupPageBindAddress, startupPageAddress string
+	var managementAPIBindAddress, managementAPICertDir string
+	var exposureMode, loadBalancerAnnotations, loadBalancerSourceRanges, nodePortHost string
+	var exposerNames string
+	var authProvider, kubeRBACProxyImage, oauth2ProxyImage string
+	var oidcIssuerURL, oidcClientID, oidcClientSecret string
+	var sccPolicies, notebookDefaults string
+	var controllerServiceAccount string
+	var accessReportInterval, sizeRecommendationInterval, cpuThrottlingWindow time.Duration
+	var usageAggregationInterval, driftReportInterval time.Duration
+	var notebookSizes, prometheusURL string
+	var cpuThrottlingThreshold float64
+	var webhookTimeout, webhookSelfTestInterval time.Duration
+	var webhookSelfTestNamespace string
+	var spotTerminationGracePeriod, restartGracePeriod, restartConfirmationTimeout time.Duration
+	var kubeAPIQPS, trustedCABundleQPS, spawnRate float64
+	var trustedCABundleConcurrency, spawnBurst, subReconcileConcurrency int
+	var trustedCABundleCoalesceDelay time.Duration
+	var reconcileCache, cacheStripFields, cacheRestrictSecretsConfigMaps bool
+	var cacheMetricsInterval time.Duration
+	var reconcileCacheConfigMap string
+	var throttlingWarningThreshold time.Duration
+	var enableLeaderElection, enableDebugLogging, strictImageResolution, enableWorkspaces, replicaAware bool
+	var enableExternalDNS, oauthNativeSidecar, imageGCProtection, imagePullMetrics bool
+	var delayStartOnAttachedVolumes, oauthImageCheck, enablePlacement, fakeOpenShiftAPIs bool
+	var strictReferenceValidation, normalizeNotebooks, upstreamAdoption, mutationProvenance bool
+	var storageValidation, webhookNoOpFastPath, effectiveConfig, serviceAccountPullSecrets bool
+	var allowSkipMutation bool
+	var skipMutationGroups string
+	var defaultStorageClass string
+	var mutationSigningKeyFile string
+	var propagatedLabels string
+	var fakeOpenShiftObjects string
+	var localClusterName string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080",
 		"The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081",
 		"The address the probe endpoint binds to.")
 	flag.StringVar(&oauthProxyImage, "oauth-proxy-image", controllers.OAuthProxyImage,
 		"Image of the OAuth proxy sidecar container.")
+	flag.BoolVar(&oauthImageCheck, "oauth-proxy-image-check", false,
+		"Check that the OAuth proxy image can be pulled with the cluster pull secret, "+
+			"failing the readiness of the controller otherwise.")
+	flag.DurationVar(&oauthImageCheckInterval, "oauth-proxy-image-check-interval", 10*time.Minute,
+		"Interval between two checks of the OAuth proxy image.")
+	flag.StringVar(&clusterPullSecret, "cluster-pull-secret", controllers.DefaultClusterPullSecret,
+		"<namespace>/<name> of the pull secret holding the registry credentials of the cluster nodes.")
+	flag.StringVar(&clusterDomain, "cluster-domain", controllers.DefaultClusterDomain,
+		"DNS domain of the cluster, for the clusters installed with a custom domain.")
+	flag.StringVar(&internalRegistryHost, "internal-registry-host", controllers.DefaultInternalRegistryHost,
+		"<host>[:<port>] of the internal image registry. The notebook images pulled from it are used as is "+
+			"instead of being resolved from the ImageStreams.")
+	flag.StringVar(&propagatedLabels, "propagated-labels", "",
+		"Comma-separated keys of the notebook labels (e.g. team,cost-center,project) propagated to the objects "+
+			"generated for the notebooks, e.g. for the chargeback and the policy engines.")
+	flag.StringVar(&imagePullSecrets, "image-pull-secrets", "",
+		"Comma-separated pull secrets injected in the pod template and the service account of the notebooks. "+
+			"They must exist in the namespaces of the notebooks, which can add their own with the "+
+			controllers.AnnotationImagePullSecrets+" annotation.")
+	flag.BoolVar(&serviceAccountPullSecrets, "service-account-pull-secrets", true,
+		"Propagate the pull secrets of --image-pull-secrets and of the "+controllers.AnnotationImagePullSecrets+
+			" namespace annotation to the service accounts of the notebooks.")
+	flag.StringVar(&oauthServiceAccountSuffix, "oauth-service-account-suffix", "",
+		"Suffix appended to the notebook name to build the name of its dedicated service account.")
+	flag.StringVar(&oauthSARTemplate, "oauth-sar-template", controllers.DefaultOAuthSARTemplate,
+		"Subject access review (JSON object or array) checked by the OAuth proxy to grant access to a notebook. "+
+			controllers.SARNotebookNamePlaceholder+" is replaced by the notebook name.")
+	flag.StringVar(&oauthUpstreamCA, "oauth-proxy-upstream-ca", controllers.DefaultOAuthUpstreamCA,
+		"Path of the CA checking the upstream of the OAuth proxy. The notebooks can mount their own upstream CA "+
+			"from a ConfigMap with the "+controllers.AnnotationOAuthUpstreamCA+" annotation.")
+	flag.StringVar(&oauthImagePullPolicy, "oauth-proxy-image-pull-policy", string(corev1.PullAlways),
+		"Pull policy of the OAuth proxy container (Always, IfNotPresent or Never), e.g. IfNotPresent to spare "+
+			"the registries of large fleets or Never on the air-gapped nodes with pre-loaded images.")
+	flag.StringVar(&workbenchImagePullPolicy, "workbench-image-pull-policy", "",
+		"Pull policy (Always, IfNotPresent or Never) set on the workbench containers without pull policy. "+
+			"Unchanged if empty.")
+	flag.DurationVar(&oauthCookieExpire, "oauth-cookie-expire", controllers.DefaultOAuthCookieExpire,
+		"Lifetime of the session cookies of the OAuth proxy. The notebooks may shorten it with the "+
+			controllers.AnnotationOAuthCookieExpire+" annotation.")
+	flag.DurationVar(&oauthCookieRefresh, "oauth-cookie-refresh", 0,
+		"Interval after which the session cookies of the OAuth proxy are refreshed. Never refreshed if 0. "+
+			"The notebooks may set it with the "+controllers.AnnotationOAuthCookieRefresh+" annotation.")
+	flag.StringVar(&oauthProxyExtraArgs, "oauth-proxy-extra-args", "",
+		"Comma-separated arguments of the cluster appended to the arguments of the OAuth proxy, "+
+			"e.g. --pass-access-token. They cannot override the arguments set by the controller.")
+	flag.StringVar(&oauthProxyAllowedNotebookArgs, "oauth-proxy-allowed-notebook-args",
+		strings.Join(controllers.DefaultAllowedNotebookProxyArgs, ","),
+		"Comma-separated names of the flags of the OAuth proxy the notebooks may set with the "+
+			controllers.AnnotationOAuthProxyExtraArgs+" annotation, e.g. skip-auth-regex. None if empty.")
+	flag.StringVar(&oauthProxyResources, "oauth-proxy-resources", "",
+		"Comma-separated cpu=<quantity> and memory=<quantity> resources of the OAuth proxy, used as both its "+
+			"requests and limits. 100m of CPU and 64Mi of memory if empty. The notebooks may override them with "+
+			"the "+controllers.AnnotationOAuthProxyResources+" annotation.")
+	flag.StringVar(&defaultLogoutURL, "default-logout-url", "",
+		"The logout URL of the OAuth proxy of the notebooks without logout annotation, discovered from the "+
+			"cluster: console for the logout redirect of the OpenShift console, or the console itself, "+
+			"dashboard for the sign out endpoint of the dashboard Route. None if empty.")
+	flag.StringVar(&dashboardRoute, "dashboard-route", "",
+		"The <namespace>/<name> of the dashboard Route, e.g. opendatahub/odh-dashboard, for "+
+			"--default-logout-url=dashboard.")
+	flag.IntVar(&oauthMetricsPort, "oauth-proxy-metrics-port", 0,
+		"Port exposing the metrics of the OAuth proxy, scraped through a ServiceMonitor. Disabled if 0.")
+	flag.StringVar(&oauthMetricsNamespace, "oauth-proxy-metrics-namespace", controllers.DefaultMetricsNamespace,
+		"Namespace of the Prometheus instance allowed to scrape the OAuth proxy metrics.")
+	flag.BoolVar(&oauthNativeSidecar, "oauth-proxy-native-sidecar", false,
+		"Run the OAuth proxy as a native sidecar container, ready before and stopped after the notebook, "+
+			"on Kubernetes 1.29 and later.")
+	flag.DurationVar(&oauthReadinessTimeout, "oauth-readiness-timeout", controllers.DefaultOAuthReadinessTimeout,
+		"Maximum time a new notebook is kept stopped until its OAuth Service is published and its Route admitted. "+
+			"Disabled if 0.")
+	flag.BoolVar(&imageGCProtection, "image-gc-protection", false,
+		"Label the notebook pods with "+controllers.LabelImageGCProtected+
+			" for the node tooling to protect their images from the image garbage collection.")
+	flag.BoolVar(&imagePullMetrics, "image-pull-metrics", false,
+		"Count the image pulls of the notebook pods by image, from the kubelet events.")
+	flag.StringVar(&allowedLocales, "allowed-locales", strings.Join(controllers.DefaultAllowedLocales, ","),
+		"Comma-separated locales the notebooks may set with the "+controllers.AnnotationLocale+
+			" annotation, installed in the workbench images. None if empty.")
+	flag.BoolVar(&delayStartOnAttachedVolumes, "delay-start-on-attached-volumes", false,
+		"Keep the started notebooks stopped until their ReadWriteOnce volumes are detached from their previous node, "+
+			"instead of failing with multi-attach errors.")
+	flag.BoolVar(&effectiveConfig, "effective-config-configmaps", true,
+		"Publish the effective configuration of each notebook, without its secrets, in a read-only "+
+			"<notebook>"+controllers.EffectiveConfigConfigMapSuffix+" ConfigMap.")
+	flag.BoolVar(&strictImageResolution, "strict-image-resolution", false,
+		"Deny the admission of notebooks whose selected image cannot be resolved from the ImageStreams.")
+	flag.BoolVar(&upstreamAdoption, "upstream-adoption", false,
+		"Leave the notebooks created by the upstream notebook controller, without ODH annotations and labels, "+
+			"unmanaged until their "+controllers.AnnotationAdopt+" annotation is set to true, reporting the "+
+			"changes of their adoption in the "+controllers.AnnotationAdoptionPlan+" annotation.")
+	flag.BoolVar(&normalizeNotebooks, "normalize-notebooks", true,
+		"Fill in the labels, annotations and environment variables set by the dashboard "+
+			"in the notebooks created by other tools.")
+	flag.BoolVar(&mutationProvenance, "mutation-provenance", true,
+		"Record the hash of the pod template produced by the webhook and the controller version in the "+
+			controllers.AnnotationMutationProvenance+" annotation of the notebooks, for the audits of the running pods.")
+	flag.StringVar(&mutationSigningKeyFile, "mutation-signing-key-file", "",
+		"File holding the HMAC key signing the "+controllers.AnnotationMutationProvenance+
+			" annotation, e.g. mounted from a Secret. The provenance is not signed if empty.")
+	flag.BoolVar(&strictReferenceValidation, "strict-reference-validation", false,
+		"Deny the admission of notebooks referencing Secrets, ConfigMaps or PVCs missing from their namespace.")
+	flag.BoolVar(&webhookNoOpFastPath, "webhook-noop-fast-path", true,
+		"Allow the notebook updates changing neither the spec, the labels nor the annotations used by the webhook, "+
+			"e.g. the last activity updated by the culler, without running the image resolution and the CA lookups.")
+	flag.BoolVar(&allowSkipMutation, "allow-skip-mutation", false,
+		"Admit the notebooks annotated with "+controllers.AnnotationSkipMutation+": \"true\" without any mutation "+
+			"of the webhook, for the administrators debugging them. The annotation is only honored for the users "+
+			"allowed the "+controllers.SkipMutationVerb+" verb on the notebook or members of the "+
+			"--skip-mutation-groups.")
+	flag.StringVar(&skipMutationGroups, "skip-mutation-groups", "",
+		"Comma-separated groups of the administrators allowed to skip the mutations of the webhook with "+
+			"--allow-skip-mutation, in addition to the users allowed the "+controllers.SkipMutationVerb+
+			" verb on the notebooks.")
+	flag.BoolVar(&storageValidation, "storage-validation", true,
+		"Deny the admission of notebooks whose PVCs would leave their pod pending, e.g. provisioned by a "+
+			"StorageClass which does not exist or mounted with another volume mode.")
+	flag.StringVar(&defaultStorageClass, "default-storage-class", "",
+		"StorageClass of the PVCs provisioned by the controller whose source PVC has no StorageClass, "+
+			"the default StorageClass of the cluster if empty.")
+	flag.BoolVar(&enableWorkspaces, "enable-workspaces", false,
+		"Reconcile the Kubeflow Notebooks 2.0 Workspace resources along with the v1 Notebooks.")
+	flag.BoolVar(&fakeOpenShiftAPIs, "fake-openshift-apis", false,
+		"Fixture mode: serve the Route, ImageStream and Proxy APIs from memory, so that the reconciles and the "+
+			"webhook run in envtest or kind clusters without OpenShift. Not for production use.")
+	flag.StringVar(&fakeOpenShiftObjects, "fake-openshift-objects", "",
+		"Multi-document YAML file of the OpenShift objects seeding the fake OpenShift APIs, e.g. the "+
+			"ImageStreams of the workbench images.")
+	flag.StringVar(&topologySpreadKeys, "topology-spread-keys", "",
+		"Comma-separated node label keys (e.g. topology.kubernetes.io/zone) used as topology domains "+
+			"to spread the notebook pods. No topology spread constraint is injected if empty.")
+	flag.IntVar(&topologySpreadMaxSkew, "topology-spread-max-skew", 1,
+		"Max skew of the topology spread constraints injected in the notebook pods.")
+	flag.StringVar(&topologySpreadWhenUnsatisfiable, "topology-spread-when-unsatisfiable", string(corev1.ScheduleAnyway),
+		"Policy of the injected topology spread constraints when they cannot be satisfied (ScheduleAnyway or DoNotSchedule).")
+	flag.IntVar(&antiAffinityWeight, "pod-anti-affinity-weight", 0,
+		"Weight (1-100) of the preferred anti-affinity between notebook pods on the same node. Disabled if 0.")
+	flag.StringVar(&spotNodeSelector, "spot-node-selector", "",
+		"Comma-separated <key>=<value> node labels selecting the spot node pools for the notebooks that opt in.")
+	flag.StringVar(&spotTolerations, "spot-tolerations", "",
+		"Comma-separated <key>[=<value>]:<effect> tolerations of the spot node pool taints.")
+	flag.DurationVar(&restartGracePeriod, "restart-grace-period", 0,
+		"Termination grace period of the notebook pods restarted by the controller, e.g. by the NotebookRollouts. "+
+			"The grace period of the pods is used if 0.")
+	flag.DurationVar(&restartConfirmationTimeout, "restart-confirmation-timeout", 0,
+		"Time the restarts initiated by the controller wait for the users to confirm them with the "+
+			controllers.AnnotationRestartAcknowledged+" annotation before they are forced. Disabled if 0.")
+	flag.DurationVar(&spotTerminationGracePeriod, "spot-termination-grace-period", 0,
+		"Termination grace period of the notebooks running on spot nodes. Unchanged if 0.")
+	flag.StringVar(&spotPreStopCommand, "spot-prestop-command", "",
+		"Shell command run by the notebooks running on spot nodes when the node is reclaimed, "+
+			"e.g. to checkpoint the work. Disabled if empty.")
+	flag.StringVar(&routerShards, "router-shards", "",
+		"JSON object mapping the router shard names to the route labels selected by their IngressController, "+
+			`e.g. {"internal":{"router":"internal"}}. The notebooks select their shard with the `+
+			controllers.AnnotationRouterShard+" annotation.")
+	flag.StringVar(&defaultRouterShard, "default-router-shard", "",
+		"Router shard of the notebooks without router shard annotation. The default router is used if empty.")
+	flag.StringVar(&dashboardConfig, "dashboard-config", "",
+		"Namespace/name of the OdhDashboardConfig whose culling and notebook toleration settings are applied to "+
+			"the notebooks, e.g. opendatahub/odh-dashboard-config. Not applied if empty.")
+	flag.StringVar(&fieldManager, "field-manager", controllers.DefaultFieldManager,
+		"Field manager of the changes of the controller, recorded in the managed fields of the objects it writes. "+
+			"The changes of the other field managers reverted by the controller are reported with events.")
+	flag.StringVar(&routeHostConflictPolicy, "route-host-conflict-policy", string(controllers.RouteHostConflictReject),
+		"Handling of the custom hostnames already used by another Route of the cluster: "+
+			string(controllers.RouteHostConflictReject)+" denies the notebook, "+
+			string(controllers.RouteHostConflictSuffix)+" suffixes the hostname with the first free number.")
+	flag.StringVar(&unprotectedRoutePolicy, "route-unprotected-notebooks", string(controllers.UnprotectedRouteAllow),
+		"Handling of the notebooks without OAuth proxy nor service mesh, exposed without authentication: "+
+			string(controllers.UnprotectedRouteAllow)+" exposes them, "+
+			string(controllers.UnprotectedRouteDeny)+" denies them unless they are not exposed, "+
+			string(controllers.UnprotectedRouteInternalOnly)+" keeps them cluster internal.")
+	flag.StringVar(&exposureMode, "exposure", controllers.ExposureRoute,
+		"How the notebooks are exposed outside of the cluster: "+controllers.ExposureRoute+", "+
+			controllers.ExposureLoadBalancer+" or "+controllers.ExposureNodePort+
+			" Services, for the clusters where the router is not reachable from the network of the users.")
+	flag.StringVar(&exposerNames, "exposers", strings.Join(controllers.DefaultExposers, ","),
+		"Comma-separated exposers run in order to expose the notebooks, among the compiled in "+
+			strings.Join(controllers.RegisteredExposers(), ", ")+".")
+	flag.StringVar(&authProvider, "auth-provider", controllers.AuthProviderOpenShiftOAuth,
+		"Auth provider of the notebooks whose inject-oauth annotation is true, unless selected by their "+
+			controllers.AnnotationAuthProvider+" annotation, among the compiled in "+
+			strings.Join(controllers.RegisteredAuthInjectors(), ", ")+".")
+	flag.StringVar(&kubeRBACProxyImage, "kube-rbac-proxy-image", controllers.KubeRBACProxyImage,
+		"Image of the kube-rbac-proxy sidecar container.")
+	flag.StringVar(&oauth2ProxyImage, "oauth2-proxy-image", controllers.OAuth2ProxyImage,
+		"Image of the oauth2-proxy sidecar container.")
+	flag.StringVar(&oidcIssuerURL, "oidc-issuer-url", "",
+		"HTTPS issuer of the OpenID Connect provider authenticating the users of oauth2-proxy, e.g. "+
+			"https://keycloak.example.com/realms/notebooks, which cannot be selected if empty.")
+	flag.StringVar(&oidcClientID, "oidc-client-id", "",
+		"OpenID Connect client of oauth2-proxy, read from the "+controllers.OAuth2ProxyClientIDKey+
+			" key of the Secret of the client if empty.")
+	flag.StringVar(&oidcClientSecret, "oidc-client-secret", "oauth2-proxy-client",
+		"Secret of the namespaces of the notebooks holding the secret of the OpenID Connect client of oauth2-proxy, "+
+			"under the "+controllers.OAuth2ProxyClientSecretKey+" key, unless the notebooks select another one with "+
+			"the "+controllers.AnnotationOIDCClientSecret+" annotation.")
+	flag.StringVar(&loadBalancerAnnotations, "load-balancer-annotations", "",
+		"JSON object of the annotations of the LoadBalancer Services exposing the notebooks, e.g. "+
+			`{"service.beta.kubernetes.io/aws-load-balancer-internal":"true"} for an internal load balancer.`)
+	flag.StringVar(&loadBalancerSourceRanges, "load-balancer-source-ranges", "",
+		"Comma-separated CIDRs of the clients allowed to reach the LoadBalancer Services exposing the notebooks.")
+	flag.StringVar(&nodePortHost, "node-port-host", "",
+		"Host the users reach the nodes at, registered with the node ports in the OAuth redirect URIs "+
+			"of the notebooks exposed through NodePort Services.")
+	flag.BoolVar(&enablePlacement, "enable-placement", false,
+		"Place the notebooks on the cluster of their "+controllers.AnnotationPlacementCluster+" annotation, or on "+
+			"the cluster decided for the Placement of their "+controllers.AnnotationPlacement+" annotation, "+
+			"and mirror the notebooks placed on remote clusters through ManifestWorks instead of reconciling them.")
+	flag.StringVar(&localClusterName, "local-cluster-name", "local-cluster",
+		"Name of the cluster the controller runs on, the notebooks placed on it are reconciled locally.")
+	flag.BoolVar(&enableExternalDNS, "enable-external-dns", false,
+		"Publish the notebook routes on the custom hostnames set by the "+controllers.AnnotationExternalDNSHostname+
+			" annotation, and annotate them for external-dns to manage their DNS records.")
+	flag.StringVar(&notebookDefaults, "notebook-defaults", "",
+		"JSON object of the annotations and labels set on the new notebooks which do not specify them, e.g. "+
+			`{"annotations":{"notebooks.opendatahub.io/inject-oauth":"true"},"labels":{"cost-center":"ds"}}. `+
+			"The namespaces override them with the "+controllers.AnnotationNotebookDefaults+" annotation.")
+	flag.StringVar(&sccPolicies, "scc-policies", "",
+		"JSON object mapping the SecurityContextConstraints the notebooks may request with the "+
+			controllers.AnnotationSCC+" annotation to their policy, e.g. "+
+			`{"fuse":{"namespaceSelector":{"matchLabels":{"fuse":"true"}},"securityContext":{...}}}. `+
+			"The controller must be allowed to bind the ClusterRoles granting the use of the SCCs.")
+	flag.StringVar(&probeSourceCIDRs, "probe-source-cidrs", "",
+		"Comma-separated node CIDRs allowed to reach the probe ports of the notebook pods, "+
+			"for the CNIs dropping the kubelet probes under the notebook network policies.")
+	flag.StringVar(&probeSourceEntities, "probe-source-entities", "",
+		"Comma-separated Cilium entities (e.g. host,remote-node) allowed to reach the probe ports "+
+			"of the notebook pods through a CiliumNetworkPolicy.")
+	flag.StringVar(&controllerServiceAccount, "controller-service-account", os.Getenv("CONTROLLER_SERVICE_ACCOUNT"),
+		"Service account of the controller, the only one allowed to change the controller-owned notebook "+
+			"annotations. The annotations are not protected if empty.")
+	flag.DurationVar(&sizeRecommendationInterval, "size-recommendation-interval", 0,
+		"Interval between two recommendations of a larger size, with the "+controllers.AnnotationRecommendedSize+
+			" annotation, for the notebooks killed for running out of memory or whose CPU is throttled. "+
+			"Disabled if 0.")
+	flag.StringVar(&notebookSizes, "notebook-sizes", "",
+		`JSON array of the notebook sizes the recommendations are picked from, e.g. `+
+			`[{"name":"Small","resources":{"limits":{"cpu":"2","memory":"8Gi"}}}].`)
+	flag.StringVar(&prometheusURL, "prometheus-url", "",
+		"URL of the Prometheus API reporting the CPU throttling of the notebook pods, e.g. "+
+			"https://thanos-querier.openshift-monitoring.svc:9091. The CPU throttling is ignored if empty.")
+	flag.Float64Var(&cpuThrottlingThreshold, "cpu-throttling-threshold", controllers.DefaultThrottlingThreshold,
+		"Ratio of throttled CPU periods above which a larger size is recommended for the notebook.")
+	flag.DurationVar(&cpuThrottlingWindow, "cpu-throttling-window", controllers.DefaultThrottlingWindow,
+		"Window the CPU throttling of the notebooks is observed over.")
+	flag.DurationVar(&usageAggregationInterval, "usage-aggregation-interval",
+		controllers.DefaultUsageAggregationInterval,
+		"Interval between two updates of the running hours and GPU-hours metrics of the notebooks. Disabled if 0.")
+	flag.StringVar(&startupPageBindAddress, "startup-page-bind-address", "",
+		"The address the page served through the Route of the notebooks while they start binds to, "+
+			"e.g. :8090, instead of the error page of the router. Disabled if empty.")
+	flag.StringVar(&startupPageAddress, "startup-page-address", os.Getenv("POD_IP"),
+		"IP address of the controller pod the routers reach the startup page at.")
+	flag.StringVar(&managementAPIBindAddress, "management-api-bind-address", "",
+		"The address the authenticated management API of the notebooks (start, stop, restart, "+
+			"apply-pending-updates and config) binds to, e.g. :8444. Disabled if empty.")
+	flag.StringVar(&managementAPICertDir, "management-api-cert-dir", "",
+		"Directory holding the tls.crt and tls.key serving certificate of the management API, "+
+			"the one of the webhook server if empty.")
+	flag.DurationVar(&accessReportInterval, "access-report-interval", 0,
+		"Interval between two generations of the "+controllers.AccessReportConfigMapName+" ConfigMaps, "+
+			"summarizing the exposure of the notebooks of each namespace for the auditors. Disabled if 0.")
+	flag.DurationVar(&driftReportInterval, "drift-report-interval", 0,
+		"Interval between two comparisons of the running notebooks with the pod the webhook would produce "+
+			"today, reported by the "+controllers.ConditionEnvironmentDrift+" condition. Disabled if 0.")
 	flag.IntVar(&webhookPort, "webhook-port", 8443,
 		"Port that the webhook server serves at.")
+	flag.DurationVar(&webhookTimeout, "webhook-timeout", controllers.DefaultWebhookTimeout,
+		"Timeout of the API server calling the notebook webhook, matching the timeoutSeconds of the webhook "+
+			"configuration. The admission requests handled after the timeout are counted as rejected.")
+	flag.DurationVar(&webhookSelfTestInterval, "webhook-selftest-interval", 0,
+		"Interval between two dry-run notebook creations checking that the API server admits the notebooks "+
+			"through the webhook. Disabled if 0.")
+	flag.StringVar(&webhookSelfTestNamespace, "webhook-selftest-namespace", "",
+		"Namespace of the dry-run notebooks of the webhook self-test. The controller namespace is used if empty.")
+	flag.IntVar(&trustedCABundleConcurrency, "trusted-ca-bundle-concurrency", controllers.DefaultTrustedCABundleConcurrency,
+		"Number of namespaces whose trusted CA bundle ConfigMaps are reconciled in parallel.")
+	flag.DurationVar(&trustedCABundleCoalesceDelay, "trusted-ca-bundle-coalesce-delay",
+		controllers.DefaultTrustedCABundleCoalesceDelay,
+		"Delay before reconciling the trusted CA bundle of a namespace, the changes of its ConfigMaps within "+
+			"the delay are reconciled once.")
+	flag.Float64Var(&trustedCABundleQPS, "trusted-ca-bundle-qps", controllers.DefaultTrustedCABundleQPS,
+		"Maximum number of namespaces whose trusted CA bundle is reconciled per second. Not limited if 0.")
+	flag.Float64Var(&spawnRate, "spawn-rate", 0,
+		"Maximum number of new notebooks started per second, fairly shared between the namespaces, e.g. to "+
+			"protect the image registry and the scheduler when a class starts. Not limited if 0.")
+	flag.IntVar(&spawnBurst, "spawn-burst", controllers.DefaultSpawnBurst,
+		"Number of new notebooks started at once before --spawn-rate applies.")
+	flag.IntVar(&subReconcileConcurrency, "subreconcile-concurrency", controllers.DefaultSubReconcileConcurrency,
+		"Number of independent sub-reconcilers, e.g. the Route and the network policies, run concurrently "+
+			"within a notebook reconcile. Run in order if 1, the default.")
+	flag.BoolVar(&reconcileCache, "reconcile-cache", false,
+		"Skip the sub-reconcilers of the notebooks whose desired state is already applied, e.g. on the periodic "+
+			"resyncs, rather than issuing no-op requests to the API server.")
+	flag.StringVar(&reconcileCacheConfigMap, "reconcile-cache-configmap", "",
+		"ConfigMap of the controller namespace persisting the reconcile cache across the restarts of the "+
+			"controller, e.g. "+controllers.DefaultReconcileCacheConfigMap+". Only kept in memory if empty.")
+	flag.BoolVar(&cacheStripFields, "cache-strip-fields", true,
+		"Strip the managed fields of the cached objects, but the ones the controller reads, and the kubectl last "+
+			"applied configuration of the cached ConfigMaps and Secrets, to reduce the memory of the controller.")
+	flag.BoolVar(&cacheRestrictSecretsConfigMaps, "cache-restrict-secrets-configmaps", true,
+		"Only cache the Secrets and ConfigMaps created by the controller, along with the CA bundle ConfigMaps "+
+			"read by name, rather than the ones of all the namespaces. The others are read from the API server.")
+	flag.DurationVar(&cacheMetricsInterval, "cache-metrics-interval", controllers.DefaultCacheMetricsInterval,
+		"Interval of the reports of the number and the estimated memory of the cached objects by kind. "+
+			"Disabled if 0.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&replicaAware, "replica-aware", false,
+		"Detect the replicas of the controller Deployment, enabling the leader election when several replicas run "+
+			"so that the controllers run on the leader only while the webhook is served by all the replicas, and "+
+			"report the replicas ready once they serve the webhook. Logs how to keep the webhook available "+
+			"during the upgrades and the node drains.")
 	flag.BoolVar(&enableDebugLogging, "debug-log", false, "Enable debug logging mode.")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 20,
+		"Maximum queries per second from the controller to the Kubernetes API server.")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 30,
+		"Maximum burst of queries from the controller to the Kubernetes API server.")
+	flag.DurationVar(&throttlingWarningThreshold, "kube-api-throttling-warning-threshold", time.Second,
+		"Log a warning when an API request is delayed by client-side throttling for longer than this duration. "+
+			"Set to 0 to disable the warning.")
 	opts := zap.Options{
 		Development: enableDebugLogging,
 		TimeEncoder: zapcore.TimeEncoderOfLayout(time.RFC3339),
@@ -82,13 +492,248 @@ func main() {
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	var err error
+
 	// Setup logger
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	ctrl.SetLogger(controllers.NewRedactingLogger(zap.New(zap.UseFlagOptions(&opts))))
+
+	// Parse the extra arguments of the OAuth proxy
+	if err = controllers.ValidateProxyExtraArgs(splitList(oauthProxyExtraArgs)); err != nil {
+		setupLog.Error(err, "Invalid --oauth-proxy-extra-args")
+		os.Exit(1)
+	}
+	if err = controllers.ValidateCookieSettings(oauthCookieExpire, oauthCookieRefresh); err != nil {
+		setupLog.Error(err, "Invalid --oauth-cookie-expire or --oauth-cookie-refresh")
+		os.Exit(1)
+	}
+	if err = controllers.ValidateAllowedNotebookProxyArgs(splitList(oauthProxyAllowedNotebookArgs)); err != nil {
+		setupLog.Error(err, "Invalid --oauth-proxy-allowed-notebook-args")
+		os.Exit(1)
+	}
+	if err = controllers.ValidateAllowedLocales(splitList(allowedLocales)); err != nil {
+		setupLog.Error(err, "Invalid --allowed-locales")
+		os.Exit(1)
+	}
+	proxyResources, err := controllers.ParseProxyResources(oauthProxyResources)
+	if err != nil {
+		setupLog.Error(err, "Invalid --oauth-proxy-resources")
+		os.Exit(1)
+	}
+	logoutConfig, err := controllers.ParseLogoutConfig(defaultLogoutURL, dashboardRoute)
+	if err != nil {
+		setupLog.Error(err, "Invalid --default-logout-url")
+		os.Exit(1)
+	}
+
+	// Parse the scheduling defaults of the notebook pods
+	schedulingConfig := controllers.SchedulingConfig{
+		TopologySpreadKeys:              splitList(topologySpreadKeys),
+		TopologySpreadMaxSkew:           int32(topologySpreadMaxSkew),
+		TopologySpreadWhenUnsatisfiable: corev1.UnsatisfiableConstraintAction(topologySpreadWhenUnsatisfiable),
+		AntiAffinityWeight:              int32(antiAffinityWeight),
+	}
+	if err = schedulingConfig.Validate(); err != nil {
+		setupLog.Error(err, "Invalid scheduling defaults")
+		os.Exit(1)
+	}
+
+	if _, err = controllers.ParseImageReference(oauthProxyImage); err != nil {
+		setupLog.Error(err, "Invalid --oauth-proxy-image")
+		os.Exit(1)
+	}
+
+	if err = controllers.ValidateSARTemplate(oauthSARTemplate); err != nil {
+		setupLog.Error(err, "Invalid --oauth-sar-template")
+		os.Exit(1)
+	}
+
+	// Parse the spot node pools settings
+	spotConfig := controllers.SpotConfig{}
+	if spotConfig.NodeSelector, err = labels.ConvertSelectorToLabelsMap(spotNodeSelector); err != nil {
+		setupLog.Error(err, "Invalid --spot-node-selector")
+		os.Exit(1)
+	}
+	if spotConfig.Tolerations, err = controllers.ParseTolerations(spotTolerations); err != nil {
+		setupLog.Error(err, "Invalid --spot-tolerations")
+		os.Exit(1)
+	}
+	if spotTerminationGracePeriod > 0 {
+		gracePeriodSeconds := int64(spotTerminationGracePeriod.Seconds())
+		spotConfig.TerminationGracePeriodSeconds = &gracePeriodSeconds
+	}
+	if spotPreStopCommand != "" {
+		spotConfig.PreStopCommand = []string{"/bin/sh", "-c", spotPreStopCommand}
+	}
+
+	// Parse the router shards of the notebook routes
+	routeConfig, err := controllers.ParseRouteConfig(routerShards, defaultRouterShard)
+	if err != nil {
+		setupLog.Error(err, "Invalid router shards")
+		os.Exit(1)
+	}
+	routeConfig.ExternalDNS = enableExternalDNS
+	routeConfig.HostConflictPolicy, err = controllers.ParseRouteHostConflictPolicy(routeHostConflictPolicy)
+	if err != nil {
+		setupLog.Error(err, "Invalid route host conflict policy")
+		os.Exit(1)
+	}
+	routeConfig.UnprotectedPolicy, err = controllers.ParseUnprotectedRoutePolicy(unprotectedRoutePolicy)
+	if err != nil {
+		setupLog.Error(err, "Invalid --route-unprotected-notebooks")
+		os.Exit(1)
+	}
+
+	// Parse the exposure of the notebooks without Route
+	exposureConfig, err := controllers.ParseExposureConfig(exposureMode, loadBalancerAnnotations,
+		splitList(loadBalancerSourceRanges), nodePortHost)
+	if err != nil {
+		setupLog.Error(err, "Invalid notebook exposure")
+		os.Exit(1)
+	}
+
+	exposers, err := controllers.ParseExposers(exposerNames)
+	if err != nil {
+		setupLog.Error(err, "Invalid --exposers")
+		os.Exit(1)
+	}
+
+	defaultAuthProvider, err := controllers.ParseAuthProvider(authProvider)
+	if err != nil {
+		setupLog.Error(err, "Invalid --auth-provider")
+		os.Exit(1)
+	}
+	if defaultAuthProvider == controllers.AuthProviderOAuth2Proxy && oidcIssuerURL == "" {
+		setupLog.Error(nil, "--auth-provider="+controllers.AuthProviderOAuth2Proxy+" requires --oidc-issuer-url")
+		os.Exit(1)
+	}
+	if oidcIssuerURL != "" {
+		if err = controllers.ValidateOIDCIssuerURL(oidcIssuerURL); err != nil {
+			setupLog.Error(err, "Invalid --oidc-issuer-url")
+			os.Exit(1)
+		}
+	}
+	authConfig := controllers.AuthConfig{
+		DefaultProvider:      defaultAuthProvider,
+		KubeRBACProxyImage:   kubeRBACProxyImage,
+		OAuth2ProxyImage:     oauth2ProxyImage,
+		OIDCIssuerURL:        oidcIssuerURL,
+		OIDCClientID:         oidcClientID,
+		OIDCClientSecretName: oidcClientSecret,
+	}
+
+	// Configure the restarts initiated by the controller
+	restartPolicy := controllers.RestartPolicy{ConfirmationTimeout: restartConfirmationTimeout}
+	if restartGracePeriod > 0 {
+		gracePeriodSeconds := int64(restartGracePeriod.Seconds())
+		restartPolicy.TerminationGracePeriodSeconds = &gracePeriodSeconds
+	}
+
+	// Parse the startup page served while the notebooks start
+	startupPageConfig, err := controllers.ParseStartupPageConfig(startupPageBindAddress, startupPageAddress)
+	if err != nil {
+		setupLog.Error(err, "Invalid startup page")
+		os.Exit(1)
+	}
+
+	// Read the key signing the mutation provenance
+	var mutationSigningKey []byte
+	if mutationSigningKeyFile != "" {
+		data, err := os.ReadFile(mutationSigningKeyFile)
+		if err == nil && strings.TrimSpace(string(data)) == "" {
+			err = fmt.Errorf("empty signing key file %s", mutationSigningKeyFile)
+		}
+		if err != nil {
+			setupLog.Error(err, "Invalid --mutation-signing-key-file")
+			os.Exit(1)
+		}
+		mutationSigningKey = []byte(strings.TrimSpace(string(data)))
+	}
+
+	// Parse the default metadata of the new notebooks
+	metadataDefaults, err := controllers.ParseMetadataDefaults(notebookDefaults)
+	if err != nil {
+		setupLog.Error(err, "Invalid notebook defaults")
+		os.Exit(1)
+	}
+
+	// Parse the SecurityContextConstraints the notebooks may request
+	sccConfig, err := controllers.ParseSCCConfig(sccPolicies)
+	if err != nil {
+		setupLog.Error(err, "Invalid SCC policies")
+		os.Exit(1)
+	}
+
+	// Parse the notebook sizes of the size recommendations
+	sizes, err := controllers.ParseNotebookSizes(notebookSizes)
+	if err != nil {
+		setupLog.Error(err, "Invalid notebook sizes")
+		os.Exit(1)
+	}
+	if sizeRecommendationInterval > 0 && len(sizes) == 0 {
+		setupLog.Error(nil, "--size-recommendation-interval requires --notebook-sizes")
+		os.Exit(1)
+	}
+
+	// Parse the image pull policies
+	oauthPullPolicy, err := controllers.ParsePullPolicy(oauthImagePullPolicy)
+	if err != nil {
+		setupLog.Error(err, "Invalid OAuth proxy image pull policy")
+		os.Exit(1)
+	}
+	workbenchPullPolicy, err := controllers.ParsePullPolicy(workbenchImagePullPolicy)
+	if err != nil {
+		setupLog.Error(err, "Invalid workbench image pull policy")
+		os.Exit(1)
+	}
+
+	// Parse the DNS names of the cluster services
+	clusterDNSConfig := controllers.ClusterDNSConfig{
+		ClusterDomain:        clusterDomain,
+		InternalRegistryHost: internalRegistryHost,
+	}
+	if err = clusterDNSConfig.Validate(); err != nil {
+		setupLog.Error(err, "Invalid cluster DNS settings")
+		os.Exit(1)
+	}
+
+	// Parse the pull secrets injected in the notebook pods
+	pullSecretsConfig := controllers.PullSecretsConfig{
+		ImagePullSecrets: splitList(imagePullSecrets),
+		ServiceAccounts:  serviceAccountPullSecrets,
+	}
+	if err = pullSecretsConfig.Validate(); err != nil {
+		setupLog.Error(err, "Invalid image pull secrets")
+		os.Exit(1)
+	}
+
+	// Parse the labels propagated to the generated objects
+	labelPropagationConfig := controllers.LabelPropagationConfig{Keys: splitList(propagatedLabels)}
+	if err = labelPropagationConfig.Validate(); err != nil {
+		setupLog.Error(err, "Invalid propagated labels")
+		os.Exit(1)
+	}
+
+	// Parse the probe sources of the network policies
+	networkConfig := controllers.NetworkConfig{
+		ProbeSourceCIDRs:    splitList(probeSourceCIDRs),
+		ProbeSourceEntities: splitList(probeSourceEntities),
+	}
+	if err = networkConfig.Validate(); err != nil {
+		setupLog.Error(err, "Invalid network policy probe sources")
+		os.Exit(1)
+	}
 
 	// Setup controller manager
 	mgrConfig := ctrl.Options{
-		Scheme:                 scheme,
-		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		Scheme: scheme,
+		Metrics: metricsserver.Options{
+			BindAddress: metricsAddr,
+			// Serve the schema of the annotations to the dashboard and the
+			// CLI, see notebook_annotation_schema.go
+			ExtraHandlers: map[string]http.Handler{
+				"/annotations-schema": controllers.AnnotationSchemaHandler(),
+			},
+		},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "odh-notebook-controller",
@@ -96,38 +741,466 @@ func main() {
 			Port: webhookPort,
 		}),
 	}
+	if imagePullMetrics {
+		// Only cache the events reporting the image pulls
+		mgrConfig.Cache = cache.Options{ByObject: map[client.Object]cache.ByObject{
+			&corev1.Event{}: {Field: controllers.ImagePulledEventsSelector},
+		}}
+	}
+	// Only cache the notebook pods
+	if mgrConfig.Cache.ByObject == nil {
+		mgrConfig.Cache.ByObject = map[client.Object]cache.ByObject{}
+	}
+	mgrConfig.Cache.ByObject[&corev1.Pod{}] = controllers.NotebookPodCacheByObject()
+	if cacheRestrictSecretsConfigMaps {
+		// Only cache the Secrets and ConfigMaps created by the controller
+		if mgrConfig.Cache.ByObject == nil {
+			mgrConfig.Cache.ByObject = map[client.Object]cache.ByObject{}
+		}
+		for object, byObject := range controllers.RestrictedCacheByObject() {
+			mgrConfig.Cache.ByObject[object] = byObject
+		}
+	}
+	if cacheStripFields {
+		// Do not keep the fields the controller never reads in memory
+		mgrConfig.Cache.DefaultTransform = controllers.StripCachedObject
+	}
+
+	// Setup the client-side rate limiting of the Kubernetes clients, each
+	// client created from the config gets its own token bucket
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = float32(kubeAPIQPS)
+	restConfig.Burst = kubeAPIBurst
+	controllers.RegisterThrottlingObserver(controllers.NewThrottlingObserver(throttlingWarningThreshold,
+		ctrl.Log.WithName("client")))
+
+	// Run the controllers on the leader only when several replicas serve the
+	// webhook
+	if replicaAware {
+		reader, err := client.New(restConfig, client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "Unable to create the client detecting the controller replicas")
+			os.Exit(1)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		topology, err := controllers.DetectReplicaTopology(ctx, reader, controllers.ControllerNamespace(),
+			os.Getenv("POD_NAME"))
+		cancel()
+		if err != nil {
+			setupLog.Error(err, "Unable to detect the controller replicas, assuming several replicas")
+		} else {
+			setupLog.Info("Detected the controller replicas", "deployment", topology.Deployment,
+				"replicas", topology.Replicas)
+			for _, guidance := range topology.Guidance() {
+				setupLog.Info(guidance)
+			}
+		}
+		if (err != nil || topology.MultiReplica()) && !mgrConfig.LeaderElection {
+			setupLog.Info("Enabling the leader election, the controllers run on the leader replica only")
+			mgrConfig.LeaderElection = true
+		}
+	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), mgrConfig)
+	mgr, err := ctrl.NewManager(restConfig, mgrConfig)
 	if err != nil {
 		setupLog.Error(err, "Unable to start manager")
 		os.Exit(1)
 	}
 
+	// Read the Secrets and ConfigMaps left out of the restricted cache from
+	// their caches by name, or from the API server
+	managerClient := mgr.GetClient()
+	var trustedCABundleCache cache.Cache
+	if cacheRestrictSecretsConfigMaps {
+		restrictedClient := &controllers.RestrictedCacheClient{
+			Client:     managerClient,
+			APIReader:  mgr.GetAPIReader(),
+			ConfigMaps: map[string]client.Reader{},
+		}
+		for _, name := range controllers.CachedConfigMapNames {
+			configMapCache, err := controllers.NewNamedConfigMapCache(mgr.GetConfig(), cache.Options{
+				Scheme:           mgr.GetScheme(),
+				Mapper:           mgr.GetRESTMapper(),
+				DefaultTransform: mgrConfig.Cache.DefaultTransform,
+			}, name)
+			if err != nil {
+				setupLog.Error(err, "Unable to create the cache of the ConfigMaps", "name", name)
+				os.Exit(1)
+			}
+			if err := mgr.Add(configMapCache); err != nil {
+				setupLog.Error(err, "Unable to set up the cache of the ConfigMaps", "name", name)
+				os.Exit(1)
+			}
+			restrictedClient.ConfigMaps[name] = configMapCache
+			if name == controllers.TrustedCABundleConfigMapName {
+				trustedCABundleCache = configMapCache
+			}
+		}
+		managerClient = restrictedClient
+	}
+
+	// Serve the OpenShift APIs from memory in the fixture mode
+	apiClient := managerClient
+	var imageStreamClient dynamic.Interface
+	if fakeOpenShiftAPIs {
+		objects, imageStreams, err := controllers.LoadFakeOpenShiftObjects(fakeOpenShiftObjects, mgr.GetScheme())
+		if err != nil {
+			setupLog.Error(err, "Invalid fake OpenShift objects")
+			os.Exit(1)
+		}
+		setupLog.Info("Fixture mode, the OpenShift APIs are served from memory")
+		apiClient = controllers.NewFakeOpenShiftClient(managerClient, objects...)
+		imageStreamClient = controllers.NewFakeImageStreamClient(imageStreams...)
+	} else if fakeOpenShiftObjects != "" {
+		setupLog.Error(nil, "--fake-openshift-objects requires --fake-openshift-apis")
+		os.Exit(1)
+	}
+
+	// Apply the notebook settings of the dashboard configuration
+	dashboardConfigKey, err := controllers.ParseDashboardConfigKey(dashboardConfig)
+	if err != nil {
+		setupLog.Error(err, "Invalid dashboard configuration")
+		os.Exit(1)
+	}
+	if dashboardConfigKey.Name != "" && !controllers.DashboardConfigsAreServed(mgr.GetRESTMapper()) {
+		setupLog.Info("OdhDashboardConfig resources are not served by the cluster, " +
+			"the dashboard configuration is not applied")
+		dashboardConfigKey = types.NamespacedName{}
+	}
+
+	// Record the changes of the controller under a stable field manager
+	apiClient = controllers.NewFieldOwnerClient(apiClient, fieldManager)
+
+	// Index the Routes on their host, to detect the conflicting custom
+	// hostnames from the cache
+	if enableExternalDNS && !fakeOpenShiftAPIs {
+		if err := controllers.IndexRouteHosts(context.Background(), mgr.GetFieldIndexer()); err != nil {
+			setupLog.Error(err, "Unable to index the Routes")
+			os.Exit(1)
+		}
+	}
+
 	// Setup notebook controller
-	if err = (&controllers.OpenshiftNotebookReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Notebook"),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Notebook")
+	oauthConfig := controllers.OAuthConfig{
+		ProxyImage:           oauthProxyImage,
+		ServiceAccountSuffix: oauthServiceAccountSuffix,
+		SARTemplate:          oauthSARTemplate,
+		UpstreamCA:           oauthUpstreamCA,
+		MetricsPort:          int32(oauthMetricsPort),
+		MetricsNamespace:     oauthMetricsNamespace,
+		ReadinessTimeout:     oauthReadinessTimeout,
+		ImagePullPolicy:      oauthPullPolicy,
+		CookieExpire:         oauthCookieExpire,
+		CookieRefresh:        oauthCookieRefresh,
+		ExtraArgs:            splitList(oauthProxyExtraArgs),
+		AllowedNotebookArgs:  splitList(oauthProxyAllowedNotebookArgs),
+		Resources:            proxyResources,
+	}
+	if oauthNativeSidecar {
+		supported, err := controllers.NativeSidecarsAreSupported(mgr.GetConfig())
+		if err != nil {
+			setupLog.Error(err, "Unable to check the support of the native sidecar containers")
+			os.Exit(1)
+		}
+		if !supported {
+			setupLog.Info("Native sidecar containers are not supported by the cluster, " +
+				"the OAuth proxy runs as a regular container")
+		}
+		oauthConfig.NativeSidecar = supported
+	}
+	monitoringEnabled := controllers.ServiceMonitorsAreServed(mgr.GetRESTMapper())
+	if oauthMetricsPort != 0 && !monitoringEnabled {
+		setupLog.Info("ServiceMonitor resources are not served by the cluster, the OAuth proxy metrics are not scraped")
+	}
+	ciliumEnabled := controllers.CiliumNetworkPoliciesAreServed(mgr.GetRESTMapper())
+	if len(networkConfig.ProbeSourceEntities) > 0 && !ciliumEnabled {
+		setupLog.Error(nil, "CiliumNetworkPolicy resources are not served by the cluster, "+
+			"--probe-source-entities requires Cilium")
 		os.Exit(1)
 	}
+	placementConfig := controllers.PlacementConfig{LocalClusterName: localClusterName}
+	manifestWorksEnabled := false
+	if enablePlacement {
+		placementConfig.Decider = &controllers.AnnotationPlacementDecider{Reader: mgr.GetAPIReader()}
+		manifestWorksEnabled = controllers.ManifestWorksAreServed(mgr.GetRESTMapper())
+		if !manifestWorksEnabled {
+			setupLog.Info("ManifestWork resources are not served by the cluster, " +
+				"the notebooks placed on remote clusters are not mirrored")
+		}
+	}
+	var spawnQueue *controllers.SpawnQueue
+	if spawnRate > 0 {
+		if spawnBurst < 1 {
+			setupLog.Error(nil, "Invalid --spawn-burst, must be at least 1")
+			os.Exit(1)
+		}
+		spawnQueue = controllers.NewSpawnQueue(spawnRate, spawnBurst)
+	}
+	// Setup the notebook controllers once the Notebook API is served
+	var notebookReconcileCache *controllers.ReconcileCache
+	if reconcileCache {
+		// The desired state of the notebooks depends on the version and the
+		// flags of the controller
+		fingerprint := []string{controllers.ControllerVersion(),
+			"SET_PIPELINE_RBAC=" + os.Getenv("SET_PIPELINE_RBAC")}
+		flag.VisitAll(func(f *flag.Flag) {
+			fingerprint = append(fingerprint, f.Name+"="+f.Value.String())
+		})
+		notebookReconcileCache = &controllers.ReconcileCache{
+			Fingerprint: strings.Join(fingerprint, "\n"),
+			Log:         ctrl.Log.WithName("controllers").WithName("ReconcileCache"),
+		}
+		if reconcileCacheConfigMap != "" {
+			notebookReconcileCache.Client = managerClient
+			notebookReconcileCache.ConfigMap = types.NamespacedName{
+				Namespace: controllers.ControllerNamespace(),
+				Name:      reconcileCacheConfigMap,
+			}
+			notebookReconcileCache.Interval = controllers.DefaultReconcileCacheFlushInterval
+		}
+	}
+	setupNotebookControllers := func() error {
+		if err := (&controllers.OpenshiftNotebookReconciler{
+			Client:                      apiClient,
+			Log:                         ctrl.Log.WithName("controllers").WithName("Notebook"),
+			Scheme:                      mgr.GetScheme(),
+			OAuthConfig:                 oauthConfig,
+			AuthConfig:                  authConfig,
+			SpotConfig:                  spotConfig,
+			RouteConfig:                 routeConfig,
+			ExposureConfig:              exposureConfig,
+			StartupPageConfig:           startupPageConfig,
+			UpstreamAdoption:            upstreamAdoption,
+			SCCConfig:                   sccConfig,
+			NetworkConfig:               networkConfig,
+			CiliumEnabled:               ciliumEnabled,
+			MonitoringEnabled:           monitoringEnabled,
+			DelayStartOnAttachedVolumes: delayStartOnAttachedVolumes,
+			Recorder:                    mgr.GetEventRecorderFor("odh-notebook-controller"),
+			FieldManager:                fieldManager,
+			DashboardConfigKey:          dashboardConfigKey,
+			TrustedCABundleConfig: controllers.TrustedCABundleConfig{
+				Concurrency:   trustedCABundleConcurrency,
+				CoalesceDelay: trustedCABundleCoalesceDelay,
+				QPS:           trustedCABundleQPS,
+			},
+			TrustedCABundleCache:    trustedCABundleCache,
+			PlacementConfig:         placementConfig,
+			ManifestWorksEnabled:    manifestWorksEnabled,
+			FakeOpenShiftAPIs:       fakeOpenShiftAPIs,
+			LabelPropagationConfig:  labelPropagationConfig,
+			SpawnQueue:              spawnQueue,
+			SubReconcileConcurrency: subReconcileConcurrency,
+			ReconcileCache:          notebookReconcileCache,
+			Exposers:                exposers,
+			EffectiveConfigEnabled:  effectiveConfig,
+			PullSecretsConfig:       pullSecretsConfig,
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller Notebook: %w", err)
+		}
+		if notebookReconcileCache != nil && notebookReconcileCache.Client != nil {
+			if err := mgr.Add(notebookReconcileCache); err != nil {
+				return fmt.Errorf("unable to set up the persistence of the reconcile cache: %w", err)
+			}
+		}
+
+		// Setup notebook rollout controller
+		if err := (&controllers.NotebookRolloutReconciler{
+			Client:        mgr.GetClient(),
+			Log:           ctrl.Log.WithName("controllers").WithName("NotebookRollout"),
+			Scheme:        mgr.GetScheme(),
+			Recorder:      mgr.GetEventRecorderFor("odh-notebook-controller"),
+			RestartPolicy: restartPolicy,
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller NotebookRollout: %w", err)
+		}
+
+		// Setup notebook template request controller
+		if err := (&controllers.NotebookTemplateRequestReconciler{
+			Client:              mgr.GetClient(),
+			Log:                 ctrl.Log.WithName("controllers").WithName("NotebookTemplateRequest"),
+			Scheme:              mgr.GetScheme(),
+			Recorder:            mgr.GetEventRecorderFor("odh-notebook-controller"),
+			DefaultStorageClass: defaultStorageClass,
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller NotebookTemplateRequest: %w", err)
+		}
+
+		// Setup notebook access reports
+		if accessReportInterval > 0 {
+			if err := mgr.Add(&controllers.AccessReporter{
+				Client:   managerClient,
+				Log:      ctrl.Log.WithName("controllers").WithName("AccessReport"),
+				Interval: accessReportInterval,
+			}); err != nil {
+				return fmt.Errorf("unable to set up the notebook access reports: %w", err)
+			}
+		}
+
+		// Setup notebook size recommendations
+		if sizeRecommendationInterval > 0 {
+			recommender := &controllers.SizeRecommender{
+				Client:              mgr.GetClient(),
+				Log:                 ctrl.Log.WithName("controllers").WithName("SizeRecommendation"),
+				Recorder:            mgr.GetEventRecorderFor("odh-notebook-controller"),
+				Sizes:               sizes,
+				Interval:            sizeRecommendationInterval,
+				PrometheusURL:       prometheusURL,
+				ThrottlingThreshold: cpuThrottlingThreshold,
+				ThrottlingWindow:    cpuThrottlingWindow,
+			}
+			if prometheusURL != "" {
+				httpClient, token, err := controllers.NewPrometheusHTTPClient()
+				if err != nil {
+					return fmt.Errorf("unable to set up the Prometheus client: %w", err)
+				}
+				recommender.HTTPClient, recommender.BearerToken = httpClient, token
+			}
+			if err := mgr.Add(recommender); err != nil {
+				return fmt.Errorf("unable to set up the notebook size recommendations: %w", err)
+			}
+		}
+		if startupPageConfig.Enabled() {
+			if err := mgr.Add(&controllers.StartupPageServer{
+				Log:         ctrl.Log.WithName("controllers").WithName("StartupPage"),
+				BindAddress: startupPageBindAddress,
+			}); err != nil {
+				return fmt.Errorf("unable to set up the notebook startup page: %w", err)
+			}
+		}
+		if usageAggregationInterval > 0 {
+			if err := mgr.Add(&controllers.UsageAggregator{
+				Client:   mgr.GetClient(),
+				Log:      ctrl.Log.WithName("controllers").WithName("Usage"),
+				Interval: usageAggregationInterval,
+			}); err != nil {
+				return fmt.Errorf("unable to set up the notebook usage aggregation: %w", err)
+			}
+		}
+		if managementAPIBindAddress != "" {
+			certDir := managementAPICertDir
+			if certDir == "" {
+				certDir = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+			}
+			if err := mgr.Add(&controllers.ManagementAPIServer{
+				Client:        mgr.GetClient(),
+				Log:           ctrl.Log.WithName("controllers").WithName("ManagementAPI"),
+				Recorder:      mgr.GetEventRecorderFor("odh-notebook-controller"),
+				Authorizer:    &controllers.ReviewAuthorizer{Client: mgr.GetClient()},
+				RestartPolicy: restartPolicy,
+				BindAddress:   managementAPIBindAddress,
+				CertDir:       certDir,
+			}); err != nil {
+				return fmt.Errorf("unable to set up the notebook management API: %w", err)
+			}
+		}
+		if cacheMetricsInterval > 0 {
+			if err := mgr.Add(&controllers.CacheMetricsReporter{
+				Reader:   mgr.GetCache(),
+				Log:      ctrl.Log.WithName("controllers").WithName("CacheMetrics"),
+				Interval: cacheMetricsInterval,
+			}); err != nil {
+				return fmt.Errorf("unable to set up the cache metrics: %w", err)
+			}
+		}
+		if driftReportInterval > 0 {
+			if err := mgr.Add(&controllers.DriftReporter{
+				Client:   mgr.GetClient(),
+				Log:      ctrl.Log.WithName("controllers").WithName("Drift"),
+				Interval: driftReportInterval,
+			}); err != nil {
+				return fmt.Errorf("unable to set up the notebook drift reports: %w", err)
+			}
+		}
+		return nil
+	}
+
+	// Setup workspace controller
+	if enableWorkspaces {
+		if !controllers.WorkspacesAreServed(mgr.GetRESTMapper()) {
+			setupLog.Info("Workspace resources are not served by the cluster, skipping the Workspace controller")
+		} else if err = (&controllers.OpenshiftNotebookReconciler{
+			Client:       apiClient,
+			Log:          ctrl.Log.WithName("controllers").WithName("Workspace"),
+			Scheme:       mgr.GetScheme(),
+			FieldManager: fieldManager,
+		}).SetupWorkspacesWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Workspace")
+			os.Exit(1)
+		}
+	}
+
+	// Setup notebook image pull metrics
+	if imagePullMetrics {
+		if err = (&controllers.ImagePullReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("ImagePull"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ImagePull")
+			os.Exit(1)
+		}
+	}
 
 	// Setup notebook mutating webhook
+	controllerUsername := ""
+	if controllerServiceAccount != "" {
+		controllerUsername = controllers.ControllerUsername(controllerServiceAccount)
+	} else {
+		setupLog.Info("The controller service account is unknown, the controller-owned notebook annotations are not protected")
+	}
 	hookServer := mgr.GetWebhookServer()
 	notebookWebhook := &webhook.Admission{
-		Handler: &controllers.NotebookWebhook{
-			Log:    ctrl.Log.WithName("controllers").WithName("Notebook"),
-			Client: mgr.GetClient(),
-			Config: mgr.GetConfig(),
-			OAuthConfig: controllers.OAuthConfig{
-				ProxyImage: oauthProxyImage,
-			},
-			Decoder: admission.NewDecoder(mgr.GetScheme()),
-		},
+		Handler: controllers.InstrumentWebhook(&controllers.NotebookWebhook{
+			Log:                         ctrl.Log.WithName("controllers").WithName("Notebook"),
+			Client:                      apiClient,
+			Config:                      mgr.GetConfig(),
+			DynamicClient:               imageStreamClient,
+			Recorder:                    mgr.GetEventRecorderFor("odh-notebook-controller"),
+			OAuthConfig:                 oauthConfig,
+			LogoutConfig:                logoutConfig,
+			AuthConfig:                  authConfig,
+			SchedulingConfig:            schedulingConfig,
+			SpotConfig:                  spotConfig,
+			RouteConfig:                 routeConfig,
+			SCCConfig:                   sccConfig,
+			ClusterDNSConfig:            clusterDNSConfig,
+			PullSecretsConfig:           pullSecretsConfig,
+			WorkbenchPullPolicy:         workbenchPullPolicy,
+			LabelPropagationConfig:      labelPropagationConfig,
+			DashboardConfigKey:          dashboardConfigKey,
+			MetadataDefaults:            metadataDefaults,
+			Decoder:                     admission.NewDecoder(mgr.GetScheme()),
+			StrictImageResolution:       strictImageResolution,
+			StrictReferenceValidation:   strictReferenceValidation,
+			StorageValidation:           storageValidation,
+			NoOpUpdateFastPath:          webhookNoOpFastPath,
+			ImageGCProtection:           imageGCProtection,
+			DelayStartOnAttachedVolumes: delayStartOnAttachedVolumes,
+			AllowedLocales:              splitList(allowedLocales),
+			NormalizeNotebooks:          normalizeNotebooks,
+			MutationProvenance:          mutationProvenance,
+			MutationSigningKey:          mutationSigningKey,
+			UpstreamAdoption:            upstreamAdoption,
+			SkipMutationEnabled:         allowSkipMutation,
+			SkipMutationGroups:          splitList(skipMutationGroups),
+			ControllerUsername:          controllerUsername,
+		}, webhookTimeout),
 	}
 	hookServer.Register("/mutate-notebook-v1", notebookWebhook)
 
+	// Setup notebook webhook self-test
+	if webhookSelfTestInterval > 0 {
+		if err = mgr.Add(&controllers.WebhookSelfTest{
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("controllers").WithName("WebhookSelfTest"),
+			Namespace: webhookSelfTestNamespace,
+			Interval:  webhookSelfTestInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up the notebook webhook self-test")
+			os.Exit(1)
+		}
+	}
+
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -139,6 +1212,55 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Wait for the Notebook API, reporting the APIUnavailable state through
+	// the readiness rather than crash-looping while the CRD is missing
+	notebookAPIWaiter := &controllers.NotebookAPIWaiter{
+		Mapper: mgr.GetRESTMapper(),
+		Log:    ctrl.Log.WithName("controllers").WithName("NotebookAPI"),
+		Setup:  setupNotebookControllers,
+	}
+	if err := mgr.Add(notebookAPIWaiter); err != nil {
+		setupLog.Error(err, "unable to set up the Notebook API wait")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("notebook-api", notebookAPIWaiter.Check); err != nil {
+		setupLog.Error(err, "unable to set up the Notebook API ready check")
+		os.Exit(1)
+	}
+
+	// Report the replicas ready once they serve the webhook, whether they
+	// lead or not
+	if replicaAware {
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up the webhook ready check")
+			os.Exit(1)
+		}
+	}
+
+	// Setup OAuth proxy image check
+	if oauthImageCheck {
+		pullSecretNamespace, pullSecretName, _ := strings.Cut(clusterPullSecret, "/")
+		imageValidator := &controllers.ProxyImageValidator{
+			Reader:     mgr.GetAPIReader(),
+			Log:        ctrl.Log.WithName("controllers").WithName("OAuthProxyImage"),
+			Image:      oauthProxyImage,
+			PullSecret: types.NamespacedName{Namespace: pullSecretNamespace, Name: pullSecretName},
+			Interval:   oauthImageCheckInterval,
+		}
+		if err := mgr.Add(imageValidator); err != nil {
+			setupLog.Error(err, "unable to set up the OAuth proxy image check")
+			os.Exit(1)
+		}
+		// The failed checks are only logged in the replica-aware mode, they
+		// would stop all the replicas serving the webhook
+		if !replicaAware {
+			if err := mgr.AddReadyzCheck("oauth-proxy-image", imageValidator.Check); err != nil {
+				setupLog.Error(err, "unable to set up the OAuth proxy image ready check")
+				os.Exit(1)
+			}
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
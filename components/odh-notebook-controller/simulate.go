@@ -0,0 +1,102 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/opendatahub-io/kubeflow/components/odh-notebook-controller/controllers"
+	"go.uber.org/zap/zapcore"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// simulateTimeout bounds the time spent simulating the change.
+const simulateTimeout = 10 * time.Minute
+
+// simulate reports the notebooks mutated and restarted by a proposed change of
+// the configuration of the controller, as JSON on the standard output, before
+// it is rolled out. The cluster is reached with the KUBECONFIG credentials,
+// which must be allowed to list and update the notebooks of all the
+// namespaces, e.g. a cluster administrator.
+func simulate(args []string) {
+	var namespace, oauthProxyImage, oauthProxyResources, trustedCABundleFile string
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	flags.StringVar(&namespace, "namespace", "",
+		"Namespace of the simulated notebooks, all the namespaces if empty.")
+	flags.StringVar(&oauthProxyImage, "oauth-proxy-image", "",
+		"Proposed image of the OAuth proxy, unchanged if empty.")
+	flags.StringVar(&oauthProxyResources, "oauth-proxy-resources", "",
+		"Proposed comma-separated cpu=<quantity> and memory=<quantity> resources of the OAuth proxy, "+
+			"unchanged if empty.")
+	flags.StringVar(&trustedCABundleFile, "trusted-ca-bundle-file", "",
+		"File holding the proposed trusted CA bundle, unchanged if empty.")
+	opts := zap.Options{
+		TimeEncoder: zapcore.TimeEncoderOfLayout(time.RFC3339),
+	}
+	opts.BindFlags(flags)
+	_ = flags.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("simulate")
+
+	change := controllers.PolicyChange{OAuthProxyImage: oauthProxyImage}
+	resources, err := controllers.ParseProxyResources(oauthProxyResources)
+	if err != nil {
+		log.Error(err, "Invalid --oauth-proxy-resources")
+		os.Exit(1)
+	}
+	change.OAuthProxyResources = resources
+	if trustedCABundleFile != "" {
+		bundle, err := os.ReadFile(trustedCABundleFile)
+		if err != nil {
+			log.Error(err, "Unable to read the --trusted-ca-bundle-file")
+			os.Exit(1)
+		}
+		value := string(bundle)
+		change.TrustedCABundle = &value
+	}
+
+	cli, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		log.Error(err, "Unable to create the Kubernetes client")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), simulateTimeout)
+	defer cancel()
+	report, err := (&controllers.PolicySimulation{
+		Client:    cli,
+		Log:       log,
+		Change:    change,
+		Namespace: namespace,
+	}).Run(ctx)
+	if err != nil {
+		log.Error(err, "Unable to simulate the change")
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(report); err != nil {
+		log.Error(err, "Unable to write the simulation report")
+		os.Exit(1)
+	}
+}